@@ -0,0 +1,274 @@
+// Package audit records who ran what: one record per step with the session,
+// user, model, tool calls with their arguments, hashes of the tool results and
+// usage including cost. Records form a hash chain, so edits, insertions and
+// deletions in the log are detected by Verify. The log is kept apart from
+// provider debug logs, which hold full payloads and are not tamper-evident.
+//
+//	logger, err := audit.Open("audit.jsonl")
+//	...
+//	res, err := step.Run(ctx, agent, history, step.WithMiddleware(logger.Middleware()))
+//
+// Identity comes from the step context; see step.WithSessionID, step.WithUserID
+// and step.WithTenantID.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// ErrTampered is returned by Verify for a log whose chain is broken.
+var ErrTampered = errors.New("audit: log has been tampered with")
+
+// Record is one audit entry. Seq, Time, PrevHash and Hash are set by Logger.
+type Record struct {
+	Seq  int64  `json:"seq"`
+	Time string `json:"time"`
+
+	SessionID string `json:"session_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	TenantID  string `json:"tenant_id,omitempty"`
+	RunID     string `json:"run_id,omitempty"`
+	Step      *int   `json:"step,omitempty"`
+
+	Provider   string          `json:"provider,omitempty"`
+	Model      string          `json:"model,omitempty"`
+	Upstream   string          `json:"upstream,omitempty"`
+	MessageID  string          `json:"message_id,omitempty"`
+	StopReason step.StopReason `json:"stop_reason,omitempty"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	Usage      *step.Usage     `json:"usage,omitempty"`
+	// Error is the step's error, if it failed.
+	Error string `json:"error,omitempty"`
+
+	// PrevHash is the Hash of the previous record, empty for the first.
+	PrevHash string `json:"prev_hash"`
+	// Hash covers every other field of the record, including PrevHash.
+	Hash string `json:"hash"`
+}
+
+// ToolCall is a tool call made in a step. The result is stored as a hash
+// only, so the log proves what a tool returned without retaining it.
+type ToolCall struct {
+	CallID     string          `json:"call_id"`
+	Name       string          `json:"name"`
+	Args       json.RawMessage `json:"args,omitempty"`
+	ResultHash string          `json:"result_hash,omitempty"`
+	IsError    bool            `json:"is_error,omitempty"`
+}
+
+// hash returns the hash of r with its Hash field cleared.
+func (r Record) hash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ResultHash returns the hash stored in ToolCall.ResultHash for msg, covering
+// its parts and error flag.
+func ResultHash(msg step.ToolResultMessage) (string, error) {
+	data, err := json.Marshal(step.ToolResultMessage{CallID: msg.CallID, Name: msg.Name, Parts: msg.Parts, IsError: msg.IsError})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Sink stores records. Implementations must only ever append, e.g. to a
+// JSONL file or a database table without update or delete grants.
+type Sink interface {
+	Append(rec Record) error
+}
+
+// JSONL returns a Sink writing one JSON record per line to w.
+func JSONL(w io.Writer) Sink {
+	return jsonlSink{enc: json.NewEncoder(w)}
+}
+
+type jsonlSink struct{ enc *json.Encoder }
+
+func (s jsonlSink) Append(rec Record) error { return s.enc.Encode(rec) }
+
+// Logger chains records and appends them to a Sink. It is safe for
+// concurrent use.
+type Logger struct {
+	mu     sync.Mutex
+	sink   Sink
+	seq    int64
+	prev   string
+	closer io.Closer
+}
+
+// NewLogger starts a new chain on sink.
+func NewLogger(sink Sink) *Logger {
+	return &Logger{sink: sink}
+}
+
+// Continue resumes the chain ending with last on sink, e.g. a database whose
+// newest record is last.
+func Continue(sink Sink, last Record) *Logger {
+	return &Logger{sink: sink, seq: last.Seq, prev: last.Hash}
+}
+
+// Open opens the JSONL log at path for appending, creating it if needed. An
+// existing log is verified first and the chain continues from its last record.
+func Open(path string) (*Logger, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	last, err := Verify(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	l := Continue(JSONL(f), last)
+	l.closer = f
+	return l, nil
+}
+
+// Close closes the file of a Logger created by Open.
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// Log appends rec, setting its Seq, Time, PrevHash and Hash. The chain only
+// advances if the sink accepted the record.
+func (l *Logger) Log(rec Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec.Seq = l.seq + 1
+	rec.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	rec.PrevHash = l.prev
+	hash, err := rec.hash()
+	if err != nil {
+		return err
+	}
+	rec.Hash = hash
+	if err := l.sink.Append(rec); err != nil {
+		return err
+	}
+	l.seq, l.prev = rec.Seq, rec.Hash
+	return nil
+}
+
+// Middleware returns a step middleware that logs every step, including
+// failed ones. A step whose record cannot be written returns its result with
+// the write error, so deployments fail closed when the audit log is down.
+func (l *Logger) Middleware() step.Middleware {
+	return func(next step.StepFunc) step.StepFunc {
+		return func(ctx context.Context, req step.StepRequest) (step.StepResult, error) {
+			res, err := next(ctx, req)
+			rec, recErr := StepRecord(ctx, res, err)
+			if recErr == nil {
+				recErr = l.Log(rec)
+			}
+			if recErr != nil {
+				return res, errors.Join(err, fmt.Errorf("audit: %w", recErr))
+			}
+			return res, err
+		}
+	}
+}
+
+// StepRecord builds the record of a step from its context, result and error.
+func StepRecord(ctx context.Context, res step.StepResult, stepErr error) (Record, error) {
+	rec := Record{
+		SessionID: step.SessionID(ctx),
+		UserID:    step.UserID(ctx),
+		TenantID:  step.TenantID(ctx),
+		RunID:     step.RunID(ctx),
+	}
+	if i, ok := step.StepIndex(ctx); ok {
+		rec.Step = &i
+	}
+	if stepErr != nil {
+		rec.Error = stepErr.Error()
+	}
+	calls := make(map[string]int)
+	for _, msg := range res {
+		switch m := msg.(type) {
+		case step.AssistantMessage:
+			rec.MessageID, rec.StopReason, rec.Usage = m.ID, m.StopReason, m.Usage
+			if m.Provenance != nil {
+				rec.Provider, rec.Model, rec.Upstream = m.Provenance.Provider, m.Provenance.Model, m.Provenance.Upstream
+			}
+			for _, part := range m.Parts {
+				if call, ok := part.(step.ToolCallPart); ok {
+					calls[call.CallID] = len(rec.ToolCalls)
+					rec.ToolCalls = append(rec.ToolCalls, ToolCall{CallID: call.CallID, Name: call.Name, Args: validArgs(call.ArgsJSON)})
+				}
+			}
+		case step.ToolResultMessage:
+			i, ok := calls[m.CallID]
+			if !ok {
+				continue
+			}
+			hash, err := ResultHash(m)
+			if err != nil {
+				return Record{}, err
+			}
+			rec.ToolCalls[i].ResultHash, rec.ToolCalls[i].IsError = hash, m.IsError
+		}
+	}
+	return rec, nil
+}
+
+// validArgs returns args, or args as a JSON string if the model produced
+// invalid JSON, so the record still encodes.
+func validArgs(args []byte) json.RawMessage {
+	if len(args) == 0 || json.Valid(args) {
+		return args
+	}
+	quoted, _ := json.Marshal(string(args))
+	return quoted
+}
+
+// Verify reads a JSONL log and checks its chain: sequence numbers, hashes and
+// links to the previous record. It returns the last record, or the zero
+// Record for an empty log. A broken chain returns an error wrapping
+// ErrTampered naming the first bad line.
+func Verify(r io.Reader) (Record, error) {
+	var last Record
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return last, fmt.Errorf("%w: line %d: %v", ErrTampered, line, err)
+		}
+		hash, err := rec.hash()
+		if err != nil {
+			return last, err
+		}
+		switch {
+		case rec.Seq != last.Seq+1:
+			return last, fmt.Errorf("%w: line %d: sequence %d after %d", ErrTampered, line, rec.Seq, last.Seq)
+		case rec.PrevHash != last.Hash:
+			return last, fmt.Errorf("%w: line %d: chain broken", ErrTampered, line)
+		case rec.Hash != hash:
+			return last, fmt.Errorf("%w: line %d: hash mismatch", ErrTampered, line)
+		}
+		last = rec
+	}
+	return last, sc.Err()
+}
@@ -0,0 +1,81 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/audit"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+type echoTool struct{}
+
+func (echoTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "echo"} }
+
+func (echoTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: string(call.ArgsJSON)}}}, nil
+}
+
+func TestLogger_Middleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := audit.NewLogger(audit.JSONL(&buf))
+	call := mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "echo", ArgsJSON: []byte(`{"x":1}`)})
+	call.Message.Usage = &step.Usage{TotalTokens: 10, Cost: 0.002}
+	agent := step.Agent{Provider: mock.New(call, mock.Text("done")), Tools: []step.Tool{echoTool{}}}
+
+	ctx := step.WithUserID(step.WithSessionID(context.Background(), "s1"), "alice")
+	res, err := step.Run(ctx, agent, nil, step.WithMiddleware(logger.Middleware()))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	last, err := audit.Verify(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if last.Seq != 2 || last.UserID != "alice" || last.RunID != res.RunID || *last.Step != 1 {
+		t.Errorf("unexpected last record: %+v", last)
+	}
+	first, _ := audit.Verify(strings.NewReader(strings.SplitAfter(buf.String(), "\n")[0]))
+	want, _ := audit.ResultHash(res.Messages[1].(step.ToolResultMessage))
+	if len(first.ToolCalls) != 1 || string(first.ToolCalls[0].Args) != `{"x":1}` || first.ToolCalls[0].ResultHash != want || first.Usage.Cost != 0.002 {
+		t.Errorf("unexpected first record: %+v", first)
+	}
+
+	tampered := strings.Replace(buf.String(), `"alice"`, `"mallory"`, 1)
+	if _, err := audit.Verify(strings.NewReader(tampered)); !errors.Is(err, audit.ErrTampered) {
+		t.Errorf("expected an edited record to be detected, got %v", err)
+	}
+	dropped := strings.SplitAfter(buf.String(), "\n")[1]
+	if _, err := audit.Verify(strings.NewReader(dropped)); !errors.Is(err, audit.ErrTampered) {
+		t.Errorf("expected a deleted record to be detected, got %v", err)
+	}
+}
+
+func TestOpen_ContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := range 2 {
+		logger, err := audit.Open(path)
+		if err != nil {
+			t.Fatalf("Open %d: %v", i, err)
+		}
+		if err := logger.Log(audit.Record{UserID: "bob"}); err != nil {
+			t.Fatal(err)
+		}
+		logger.Close()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if last, err := audit.Verify(f); err != nil || last.Seq != 2 {
+		t.Fatalf("expected a valid chain of 2 records, got %d: %v", last.Seq, err)
+	}
+}
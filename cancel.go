@@ -0,0 +1,100 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// CancelReason explains why a step was aborted. Step records it on the
+// aborted assistant message under MetadataCancelReason.
+type CancelReason string
+
+const (
+	// CancelUser means the context was cancelled, e.g. by the user.
+	CancelUser CancelReason = "user"
+	// CancelTimeout means the context deadline passed.
+	CancelTimeout CancelReason = "timeout"
+	// CancelBudget means the context was cancelled with a cause matching
+	// ErrBudgetExceeded or ErrQuotaExceeded.
+	CancelBudget CancelReason = "budget"
+	// CancelDisconnect means the provider connection dropped mid-response.
+	CancelDisconnect CancelReason = "disconnect"
+)
+
+// MetadataCancelReason is the AssistantMessage metadata key holding the
+// CancelReason of a message with StopAborted.
+const MetadataCancelReason = "step.cancel_reason"
+
+// ErrBudgetExceeded is a cancellation cause for callers enforcing a token or
+// cost budget while a step runs, e.g.
+//
+//	ctx, cancel := context.WithCancelCause(ctx)
+//	...
+//	cancel(step.ErrBudgetExceeded)
+var ErrBudgetExceeded = errors.New("step: budget exceeded")
+
+// CancelReasonOf returns the reason msg was aborted, if it was.
+func CancelReasonOf(msg AssistantMessage) (CancelReason, bool) {
+	if msg.StopReason != StopAborted {
+		return "", false
+	}
+	s, _ := msg.Metadata.String(MetadataCancelReason)
+	return CancelReason(s), true
+}
+
+// contextCancelReason classifies the cancellation of a done ctx.
+func contextCancelReason(ctx context.Context) CancelReason {
+	cause := context.Cause(ctx)
+	switch {
+	case errors.Is(cause, ErrBudgetExceeded), errors.Is(cause, ErrQuotaExceeded):
+		return CancelBudget
+	case errors.Is(cause, context.DeadlineExceeded):
+		return CancelTimeout
+	default:
+		return CancelUser
+	}
+}
+
+// isDisconnect reports whether err from a provider stream means the
+// connection dropped, as opposed to an error reported by the provider.
+func isDisconnect(err error) bool {
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE), errors.Is(err, net.ErrClosed), errors.As(err, &opErr):
+		return true
+	}
+	// HTTP/2 resets are not exported as types.
+	return strings.Contains(err.Error(), "stream error:")
+}
+
+// abort marks msg as aborted for reason.
+func abort(msg *AssistantMessage, reason CancelReason) {
+	msg.StopReason = StopAborted
+	msg.Metadata = msg.Metadata.Clone()
+	msg.Metadata.Set(MetadataCancelReason, string(reason))
+}
+
+// partialMessage collects the text streamed before a stream broke off, for
+// providers that do not finalize a partial message themselves. Thinking and
+// unfinished tool calls are left out: neither is safe to send back.
+type partialMessage struct {
+	text strings.Builder
+}
+
+func (p *partialMessage) add(d MessageDelta) {
+	if t, ok := d.(TextDelta); ok {
+		p.text.WriteString(t.Delta)
+	}
+}
+
+func (p *partialMessage) message() (AssistantMessage, bool) {
+	if p.text.Len() == 0 {
+		return AssistantMessage{}, false
+	}
+	return AssistantMessage{Parts: []Part{TextPart{Text: p.text.String()}}}, true
+}
@@ -0,0 +1,108 @@
+package step_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// stallingProvider streams "partial", then blocks until the context is done
+// or fails with err. With finalize set it ends like the built-in providers,
+// with a message holding the partial text.
+type stallingProvider struct {
+	err      error
+	finalize bool
+}
+
+func (p stallingProvider) Stream(context.Context, step.ProviderRequest) (step.ProviderStream, error) {
+	return &stallingStream{p: p}, nil
+}
+
+type stallingStream struct {
+	p    stallingProvider
+	sent int
+}
+
+func (s *stallingStream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	s.sent++
+	switch {
+	case s.sent == 1:
+		return step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: "partial"}}, nil
+	case s.p.err != nil:
+		return nil, s.p.err
+	case s.sent == 2:
+		<-ctx.Done()
+		if !s.p.finalize {
+			return nil, ctx.Err()
+		}
+		msg := step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "partial"}}, StopReason: step.StopStop}
+		return step.ProviderMessageUpdate{Message: msg}, nil
+	}
+	return nil, io.EOF
+}
+
+func (s *stallingStream) Close() error { return nil }
+
+func TestStep_CancelReason(t *testing.T) {
+	budget := func() (context.Context, func()) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		time.AfterFunc(10*time.Millisecond, func() { cancel(step.ErrBudgetExceeded) })
+		return ctx, func() { cancel(nil) }
+	}
+	user := func() (context.Context, func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return ctx, cancel
+	}
+	timeout := func() (context.Context, func()) {
+		return context.WithTimeout(context.Background(), 10*time.Millisecond)
+	}
+	tests := []struct {
+		name     string
+		provider stallingProvider
+		ctx      func() (context.Context, func())
+		reason   step.CancelReason
+		err      error
+	}{
+		{"user", stallingProvider{}, user, step.CancelUser, context.Canceled},
+		{"user finalized", stallingProvider{finalize: true}, user, step.CancelUser, context.Canceled},
+		{"timeout", stallingProvider{}, timeout, step.CancelTimeout, context.DeadlineExceeded},
+		{"budget", stallingProvider{finalize: true}, budget, step.CancelBudget, context.Canceled},
+		{"disconnect", stallingProvider{err: io.ErrUnexpectedEOF}, user, step.CancelDisconnect, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			var status step.StepStatusDelta
+			result, err := step.Step(ctx, step.StepRequest{Provider: tt.provider}, step.WithOnDelta(func(d step.MessageDelta) {
+				if s, ok := d.(step.StepStatusDelta); ok {
+					status = s
+				}
+			}))
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if len(result) != 1 {
+				t.Fatalf("got %d messages, want the partial assistant message", len(result))
+			}
+			msg := result[0].(step.AssistantMessage)
+			if reason, ok := step.CancelReasonOf(msg); !ok || reason != tt.reason || msg.Text() != "partial" {
+				t.Errorf("message %+v has reason %q, want %q", msg, reason, tt.reason)
+			}
+			if !status.Cancelled || status.Reason != tt.reason {
+				t.Errorf("status = %+v", status)
+			}
+		})
+	}
+
+	// A provider error is not an abort.
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: stallingProvider{err: errors.New("overloaded")}})
+	if err == nil || result != nil {
+		t.Errorf("got %v, %v; want the error and no result", result, err)
+	}
+}
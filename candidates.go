@@ -0,0 +1,99 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+)
+
+// Candidates generates n alternative assistant messages for the same request,
+// for sampling-and-ranking strategies such as best-of-n.
+//
+// Providers that support ProviderRequest.N produce all candidates in one
+// request; otherwise, or when fewer come back, Candidates issues further
+// requests until it has n. Each candidate carries its own Usage; when a
+// provider only reports usage for a whole request, it is attached to the
+// first candidate of that request, so summing over candidates gives the total.
+//
+// Tools are offered to the model but never executed. Append the chosen
+// candidate to the history to continue the conversation.
+func Candidates(ctx context.Context, req StepRequest, n int) ([]AssistantMessage, error) {
+	if req.Provider == nil {
+		return nil, ErrNoProvider
+	}
+	n = max(n, 1)
+
+	parentID := ""
+	if len(req.History) > 0 {
+		parentID = MessageID(req.History[len(req.History)-1])
+	}
+	prefill, hasPrefill := Prefill(req.History)
+	if hasPrefill && len(req.History) > 1 {
+		parentID = MessageID(req.History[len(req.History)-2])
+	}
+
+	var out []AssistantMessage
+	for len(out) < n {
+		msgs, err := requestCandidates(ctx, req, n-len(out))
+		for _, msg := range msgs {
+			if hasPrefill {
+				msg = mergePrefill(prefill, msg)
+				// Candidates are alternatives to each other; only one can keep the prefill's ID.
+				msg.ID = ""
+			}
+			if msg.ID == "" {
+				msg.ID = NewMessageID()
+			}
+			if msg.ParentID == "" {
+				msg.ParentID = parentID
+			}
+			out = append(out, msg)
+		}
+		if err != nil {
+			return out, err
+		}
+		if len(msgs) == 0 {
+			return out, errors.New("step: provider stream finished without assistant message")
+		}
+	}
+	return out[:n], nil
+}
+
+func requestCandidates(ctx context.Context, req StepRequest, n int) ([]AssistantMessage, error) {
+	stream, err := req.Provider.Stream(ctx, ProviderRequest{
+		SystemPrompt: req.SystemPrompt,
+		History:      req.History,
+		Tools:        collectToolSpecs(req.Tools),
+		N:            n,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	byIndex := make(map[int]AssistantMessage)
+	for {
+		up, err := stream.Next(ctx)
+		if u, ok := up.(ProviderMessageUpdate); ok {
+			byIndex[u.Candidate] = u.Message
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	idxs := make([]int, 0, len(byIndex))
+	for idx := range byIndex {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+	msgs := make([]AssistantMessage, 0, len(idxs))
+	for _, idx := range idxs {
+		msgs = append(msgs, byIndex[idx])
+	}
+	return msgs, ctx.Err()
+}
@@ -0,0 +1,37 @@
+package step_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestCandidates(t *testing.T) {
+	// The mock provider ignores N, so Candidates tops up with one request per candidate.
+	provider := mock.New(mock.Text("a"), mock.Text("b"), mock.Text("c"))
+	history := step.AssignIDs([]step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "pick a letter"}}}})
+
+	cands, err := step.Candidates(context.Background(), step.StepRequest{Provider: provider, History: history}, 3)
+	if err != nil {
+		t.Fatalf("Candidates failed: %v", err)
+	}
+	if len(cands) != 3 {
+		t.Fatalf("expected 3 candidates, got %d", len(cands))
+	}
+	seen := map[string]bool{}
+	for i, c := range cands {
+		if c.ParentID != step.MessageID(history[0]) {
+			t.Errorf("candidate %d: expected parent %s, got %s", i, step.MessageID(history[0]), c.ParentID)
+		}
+		if c.ID == "" || seen[c.ID] {
+			t.Errorf("candidate %d: expected a unique ID, got %q", i, c.ID)
+		}
+		seen[c.ID] = true
+	}
+	reqs := provider.Requests()
+	if len(reqs) != 3 || reqs[0].N != 3 || reqs[2].N != 1 {
+		t.Errorf("expected requests for the remaining candidates, got %d requests", len(reqs))
+	}
+}
@@ -0,0 +1,243 @@
+// Command step is an interactive agent REPL on top of the step library.
+//
+//	step -provider anthropic -model claude-sonnet-4-5 \
+//	    -mcp fs="npx -y @modelcontextprotocol/server-filesystem ." \
+//	    -session chat.jsonl -debug debug.jsonl
+//
+// Each line read from stdin is a user turn; the agent runs steps until it stops
+// calling tools. Ctrl-C interrupts the running turn; at the prompt it exits.
+// With -session the transcript is appended to a JSONL file and resumed on the next run.
+//
+// With -replay the responses come from a Chat Completions debug log instead of
+// a provider, one logged request per step, to reproduce a reported session:
+//
+//	step -replay debug.jsonl -replay-speed 4
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+const (
+	ansiDim   = "\033[2m"
+	ansiBold  = "\033[1m"
+	ansiRed   = "\033[31m"
+	ansiReset = "\033[0m"
+)
+
+func main() {
+	var (
+		providerName = flag.String("provider", "anthropic", "provider: "+strings.Join(providerNames, ", "))
+		model        = flag.String("model", "", "model ID (required)")
+		system       = flag.String("system", "", "system prompt")
+		systemFile   = flag.String("system-file", "", "read the system prompt from a file")
+		session      = flag.String("session", "", "JSONL transcript to resume from and append to")
+		debug        = flag.String("debug", "", "write provider debug records to this JSONL file")
+		replayLog    = flag.String("replay", "", "replay responses from this Chat Completions debug log")
+		replaySpeed  = flag.Float64("replay-speed", 1, "replay speed factor; 0 replays without delays")
+		mcpSpecs     stringList
+	)
+	flag.Var(&mcpSpecs, "mcp", `MCP server as name="command args" (repeatable)`)
+	flag.Parse()
+
+	provider, err := openProvider(*providerName, *model, *debug, *replayLog, *replaySpeed)
+	if err == nil {
+		err = run(provider, *system, *systemFile, *session, mcpSpecs)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, ansiRed+"error: "+err.Error()+ansiReset)
+		os.Exit(1)
+	}
+}
+
+func run(provider step.Provider, system, systemFile, session string, mcpSpecs []string) error {
+	if systemFile != "" {
+		data, err := os.ReadFile(systemFile)
+		if err != nil {
+			return err
+		}
+		system = string(data)
+	}
+
+	ctx := context.Background()
+	var tools []step.Tool
+	for _, spec := range mcpSpecs {
+		server, err := startMCP(ctx, spec)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		serverTools, err := server.Tools(ctx)
+		if err != nil {
+			return err
+		}
+		tools = append(tools, serverTools...)
+	}
+
+	var history []step.Message
+	if session != "" {
+		var err error
+		if history, err = loadSession(session); err != nil {
+			return fmt.Errorf("load session: %w", err)
+		}
+		if len(history) > 0 {
+			fmt.Printf(ansiDim+"resumed %d messages from %s"+ansiReset+"\n", len(history), session)
+		}
+	}
+	save := func(msgs []step.Message) {
+		if session == "" {
+			return
+		}
+		if err := appendSession(session, msgs); err != nil {
+			fmt.Fprintln(os.Stderr, ansiRed+"save session: "+err.Error()+ansiReset)
+		}
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		fmt.Print(ansiBold + "> " + ansiReset)
+		var line string
+		select {
+		case <-interrupts:
+			fmt.Println()
+			return nil
+		case l, ok := <-lines:
+			if !ok {
+				fmt.Println()
+				return nil
+			}
+			line = strings.TrimSpace(l)
+		}
+		if line == "" {
+			continue
+		}
+		if line == "/exit" || line == "/quit" {
+			return nil
+		}
+
+		user := step.UserMessage{ID: step.NewMessageID(), Parts: []step.Part{step.TextPart{Text: line}}, Timestamp: time.Now().UnixMilli()}
+		if len(history) > 0 {
+			user.ParentID = step.MessageID(history[len(history)-1])
+		}
+		history = append(history, user)
+		save([]step.Message{user})
+
+		turnCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-interrupts:
+				cancel()
+			case <-turnCtx.Done():
+			}
+		}()
+		history = runTurn(turnCtx, step.StepRequest{Provider: provider, SystemPrompt: system, History: history, Tools: tools}, save)
+		cancel()
+	}
+}
+
+// runTurn runs steps until the model stops calling tools, printing the stream.
+// It returns the history extended with every message produced.
+func runTurn(ctx context.Context, req step.StepRequest, save func([]step.Message)) []step.Message {
+	var inThinking bool
+	onDelta := func(delta step.MessageDelta) {
+		switch d := delta.(type) {
+		case step.ThinkingDelta:
+			if d.Delta == "" {
+				return
+			}
+			if !inThinking {
+				fmt.Print(ansiDim)
+				inThinking = true
+			}
+			fmt.Print(d.Delta)
+		case step.TextDelta:
+			if inThinking {
+				fmt.Print(ansiReset + "\n\n")
+				inThinking = false
+			}
+			fmt.Print(d.Delta)
+		case step.ToolExecStartDelta:
+			if inThinking {
+				fmt.Print(ansiReset)
+				inThinking = false
+			}
+			fmt.Printf("\n"+ansiBold+"⏺ %s"+ansiReset+" %s\n", d.Call.Name, string(d.Call.ArgsJSON))
+		}
+	}
+	onMessage := func(msg step.Message) {
+		if m, ok := msg.(step.ToolResultMessage); ok {
+			color := ansiDim
+			if m.IsError {
+				color = ansiRed
+			}
+			fmt.Println(color + preview(m) + ansiReset)
+		}
+	}
+
+	for {
+		result, err := step.Step(ctx, req, step.WithOnDelta(onDelta), step.WithOnMessage(onMessage))
+		if inThinking {
+			fmt.Print(ansiReset)
+			inThinking = false
+		}
+		req.History = append(req.History, result...)
+		save(result)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				fmt.Println("\n" + ansiDim + "interrupted" + ansiReset)
+			} else {
+				fmt.Println("\n" + ansiRed + "error: " + err.Error() + ansiReset)
+			}
+			return req.History
+		}
+		if !result.HasToolCall() {
+			fmt.Println()
+			return req.History
+		}
+	}
+}
+
+// preview returns the first lines of a tool result.
+func preview(m step.ToolResultMessage) string {
+	const maxLines = 8
+	var texts []string
+	for _, part := range m.Parts {
+		switch p := part.(type) {
+		case step.TextPart:
+			texts = append(texts, p.Text)
+		case step.ImagePart:
+			texts = append(texts, "[image "+p.MimeType+"]")
+		}
+	}
+	lines := strings.Split(strings.TrimRight(strings.Join(texts, "\n"), "\n"), "\n")
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], fmt.Sprintf("… %d more lines", len(lines)-maxLines))
+	}
+	return "  " + strings.Join(lines, "\n  ")
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/inspirepan/step"
+)
+
+// mcpProtocolVersion is the Model Context Protocol revision the client speaks.
+const mcpProtocolVersion = "2024-11-05"
+
+// mcpServer is a minimal MCP client for a server speaking newline-delimited
+// JSON-RPC over stdio. Only tools are supported.
+type mcpServer struct {
+	name string
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	waiting map[int64]chan rpcResponse
+	err     error
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	ID     *int64          `json:"id"`
+	Method string          `json:"method,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message) }
+
+// startMCP launches spec, given as "name=command args...", and performs the
+// initialize handshake. The server's stderr is passed through.
+func startMCP(ctx context.Context, spec string) (*mcpServer, error) {
+	name, command, ok := strings.Cut(spec, "=")
+	fields := strings.Fields(command)
+	if !ok || name == "" || len(fields) == 0 {
+		return nil, fmt.Errorf("invalid -mcp %q, want name=command [args...]", spec)
+	}
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start mcp server %s: %w", name, err)
+	}
+	s := &mcpServer{name: name, cmd: cmd, in: in, waiting: make(map[int64]chan rpcResponse)}
+	go s.read(out)
+
+	_, err = s.call(ctx, "initialize", map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "step", "version": "0.1.0"},
+	})
+	if err == nil {
+		err = s.send(rpcRequest{JSONRPC: "2.0", Method: "notifications/initialized"})
+	}
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("initialize mcp server %s: %w", name, err)
+	}
+	return s, nil
+}
+
+func (s *mcpServer) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var resp rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+		if resp.Method != "" {
+			// A request or notification from the server. Answer pings and
+			// reject anything else so the server does not wait forever.
+			if resp.ID != nil {
+				s.reply(*resp.ID, resp.Method)
+			}
+			continue
+		}
+		if resp.ID == nil {
+			continue
+		}
+		s.mu.Lock()
+		ch := s.waiting[*resp.ID]
+		delete(s.waiting, *resp.ID)
+		s.mu.Unlock()
+		if ch != nil {
+			ch <- resp
+		}
+	}
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	s.mu.Lock()
+	s.err = fmt.Errorf("mcp server %s exited: %w", s.name, err)
+	for id, ch := range s.waiting {
+		close(ch)
+		delete(s.waiting, id)
+	}
+	s.mu.Unlock()
+}
+
+func (s *mcpServer) reply(id int64, method string) {
+	msg := map[string]any{"jsonrpc": "2.0", "id": id}
+	if method == "ping" {
+		msg["result"] = map[string]any{}
+	} else {
+		msg["error"] = rpcError{Code: -32601, Message: "method not supported: " + method}
+	}
+	_ = s.send(msg)
+}
+
+func (s *mcpServer) send(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err = s.in.Write(append(data, '\n'))
+	return err
+}
+
+func (s *mcpServer) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil, s.err
+	}
+	s.nextID++
+	id := s.nextID
+	ch := make(chan rpcResponse, 1)
+	s.waiting[id] = ch
+	s.mu.Unlock()
+
+	if err := s.send(rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.waiting, id)
+		s.mu.Unlock()
+		_ = s.send(rpcRequest{JSONRPC: "2.0", Method: "notifications/cancelled", Params: map[string]any{"requestId": id}})
+		return nil, ctx.Err()
+	case resp, ok := <-ch:
+		if !ok {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return nil, s.err
+		}
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	}
+}
+
+// Tools lists the server's tools as step.Tools named "<server>__<tool>".
+func (s *mcpServer) Tools(ctx context.Context) ([]step.Tool, error) {
+	var tools []step.Tool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		raw, err := s.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tools []struct {
+				Name        string          `json:"name"`
+				Description string          `json:"description"`
+				InputSchema map[string]any  `json:"inputSchema"`
+				Annotations *mcpAnnotations `json:"annotations"`
+			} `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, err
+		}
+		for _, t := range page.Tools {
+			tools = append(tools, &mcpTool{server: s, name: t.Name, spec: step.ToolSpec{
+				Name:        s.name + "__" + t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema,
+				Access:      t.Annotations.access(),
+			}})
+		}
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// mcpAnnotations are the behavior hints of an MCP tool.
+type mcpAnnotations struct {
+	ReadOnlyHint    bool  `json:"readOnlyHint"`
+	DestructiveHint *bool `json:"destructiveHint"`
+}
+
+// access maps the hints to a step.Access. Tools without annotations are
+// unspecified; with them, MCP presumes destructive unless hinted otherwise.
+func (a *mcpAnnotations) access() step.Access {
+	switch {
+	case a == nil:
+		return step.AccessUnspecified
+	case a.ReadOnlyHint:
+		return step.AccessReadOnly
+	case a.DestructiveHint != nil && !*a.DestructiveHint:
+		return step.AccessWrite
+	default:
+		return step.AccessDestructive
+	}
+}
+
+// Close stops the server.
+func (s *mcpServer) Close() {
+	_ = s.in.Close()
+	if s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+	}
+	_ = s.cmd.Wait()
+}
+
+type mcpTool struct {
+	server *mcpServer
+	name   string
+	spec   step.ToolSpec
+}
+
+func (t *mcpTool) Spec() step.ToolSpec { return t.spec }
+
+func (t *mcpTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	args := json.RawMessage(call.ArgsJSON)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	raw, err := t.server.call(ctx, "tools/call", map[string]any{"name": t.name, "arguments": args})
+	if err != nil {
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			return step.ToolResult{IsError: true, Parts: []step.Part{step.TextPart{Text: rpcErr.Message}}}, nil
+		}
+		return step.ToolResult{}, err
+	}
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			Data     string `json:"data"`
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return step.ToolResult{}, err
+	}
+	res := step.ToolResult{IsError: result.IsError}
+	for _, c := range result.Content {
+		switch c.Type {
+		case "text":
+			res.Parts = append(res.Parts, step.TextPart{Text: c.Text})
+		case "image":
+			res.Parts = append(res.Parts, step.ImagePart{MimeType: c.MimeType, DataB64: c.Data})
+		default:
+			res.Parts = append(res.Parts, step.TextPart{Text: fmt.Sprintf("[unsupported %s content]", c.Type)})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/anthropic"
+	"github.com/inspirepan/step/providers/chatcompletion"
+	"github.com/inspirepan/step/providers/google"
+	"github.com/inspirepan/step/providers/openrouter"
+	"github.com/inspirepan/step/providers/replay"
+	"github.com/inspirepan/step/providers/responses"
+)
+
+// providerNames lists the values accepted by -provider.
+var providerNames = []string{"anthropic", "openai", "responses", "google", "openrouter"}
+
+// openProvider returns a replay of replayLog when it is set, and otherwise
+// the named provider.
+func openProvider(name, model, debugPath, replayLog string, replaySpeed float64) (step.Provider, error) {
+	switch {
+	case replayLog != "":
+		return replay.Open(replayLog, replay.WithSpeed(replaySpeed))
+	case model == "":
+		return nil, errors.New("-model is required")
+	default:
+		return newProvider(name, model, debugPath)
+	}
+}
+
+// newProvider creates the named provider. Credentials come from the usual
+// environment variables of each provider package.
+func newProvider(name, model, debugPath string) (step.Provider, error) {
+	switch name {
+	case "anthropic":
+		return anthropic.New(model, anthropic.WithDebug(debugPath)), nil
+	case "openai":
+		return chatcompletion.New(model, chatcompletion.WithDebug(debugPath)), nil
+	case "responses":
+		return responses.New(model, responses.WithDebug(debugPath)), nil
+	case "google":
+		return google.New(model, google.WithDebug(debugPath)), nil
+	case "openrouter":
+		return openrouter.New(model, openrouter.WithDebug(debugPath)), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want one of %v)", name, providerNames)
+	}
+}
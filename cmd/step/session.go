@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/inspirepan/step"
+)
+
+// loadSession reads a JSONL transcript, one message per line. A missing file is an empty session.
+func loadSession(path string) ([]step.Message, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var history []step.Message
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		msg, err := step.UnmarshalMessage(scanner.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, msg)
+	}
+	return history, scanner.Err()
+}
+
+// appendSession appends msgs to the JSONL transcript at path.
+func appendSession(path string, msgs []step.Message) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, msg := range msgs {
+		if err := enc.Encode(msg); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,161 @@
+package step
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type coalesceConfig struct {
+	interval time.Duration
+	runes    int
+}
+
+func (c coalesceConfig) enabled() bool { return c.interval > 0 || c.runes > 0 }
+
+// WithDeltaCoalescing merges consecutive small text, thinking and tool call
+// deltas before they reach OnDelta (and StepStream events), reducing render
+// and network churn for consumers that redraw per delta. A merged delta is
+// flushed once interval has passed since its first fragment or once it holds
+// runes runes, whichever comes first; a zero value disables that trigger.
+// Pending deltas are always flushed before any other delta or message, so
+// ordering is preserved and nothing is held back past the end of generation.
+//
+// Without this option (or with both values zero) deltas pass through as the
+// provider produced them, which gives the lowest latency. With an interval,
+// the timed flush calls OnDelta from a timer goroutine; calls never overlap.
+func WithDeltaCoalescing(interval time.Duration, runes int) StepOption {
+	return func(c *stepConfig) {
+		c.coalesce = coalesceConfig{interval: interval, runes: runes}
+	}
+}
+
+// coalescer buffers mergeable deltas for a single step.
+type coalescer struct {
+	cfg coalesceConfig
+	out func(MessageDelta)
+
+	mu      sync.Mutex
+	pending MessageDelta
+	count   int
+	timer   *time.Timer
+	closed  bool
+}
+
+// coalesceEmitter returns e with deltas routed through a coalescer, and a
+// function that flushes it for good.
+func coalesceEmitter(e stepEmitter, cfg coalesceConfig) (stepEmitter, func()) {
+	if !cfg.enabled() || e.onDelta == nil {
+		return e, func() {}
+	}
+	c := &coalescer{cfg: cfg, out: e.onDelta}
+	onMessage := e.onMessage
+	e.onDelta = c.push
+	e.onMessage = func(m Message) {
+		c.flush()
+		if onMessage != nil {
+			onMessage(m)
+		}
+	}
+	return e, c.close
+}
+
+func (c *coalescer) push(d MessageDelta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		c.out(d)
+		return
+	}
+	if merged, ok := mergeDelta(c.pending, d); ok {
+		c.pending = merged
+	} else {
+		c.flushLocked()
+		if !coalescable(d) {
+			c.out(d)
+			return
+		}
+		c.pending = d
+		if c.cfg.interval > 0 {
+			c.timer = time.AfterFunc(c.cfg.interval, c.flush)
+		}
+	}
+	c.count += deltaRunes(d)
+	if (c.cfg.runes > 0 && c.count >= c.cfg.runes) || finalDelta(d) {
+		c.flushLocked()
+	}
+}
+
+func (c *coalescer) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+func (c *coalescer) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+	c.closed = true
+}
+
+func (c *coalescer) flushLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if c.pending != nil {
+		c.out(c.pending)
+		c.pending = nil
+	}
+	c.count = 0
+}
+
+func coalescable(d MessageDelta) bool {
+	switch d.(type) {
+	case TextDelta, ThinkingDelta, ToolCallDelta:
+		return true
+	}
+	return false
+}
+
+// finalDelta reports deltas that must not wait, such as a thinking signature.
+func finalDelta(d MessageDelta) bool {
+	t, ok := d.(ThinkingDelta)
+	return ok && t.Signature != ""
+}
+
+func deltaRunes(d MessageDelta) int {
+	switch d := d.(type) {
+	case TextDelta:
+		return utf8.RuneCountInString(d.Delta)
+	case ThinkingDelta:
+		return utf8.RuneCountInString(d.Delta)
+	case ToolCallDelta:
+		return utf8.RuneCountInString(d.ArgsDelta)
+	}
+	return 0
+}
+
+// mergeDelta appends next to pending when both continue the same block.
+func mergeDelta(pending, next MessageDelta) (MessageDelta, bool) {
+	switch p := pending.(type) {
+	case TextDelta:
+		if n, ok := next.(TextDelta); ok {
+			p.Delta += n.Delta
+			return p, true
+		}
+	case ThinkingDelta:
+		if n, ok := next.(ThinkingDelta); ok && n.ID == p.ID && p.Signature == "" {
+			p.Delta += n.Delta
+			p.Signature = n.Signature
+			return p, true
+		}
+	case ToolCallDelta:
+		if n, ok := next.(ToolCallDelta); ok && n.CallID == p.CallID && (n.Name == "" || n.Name == p.Name) {
+			p.ArgsDelta += n.ArgsDelta
+			return p, true
+		}
+	}
+	return nil, false
+}
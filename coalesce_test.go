@@ -0,0 +1,118 @@
+package step_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func fragmented() mock.Response {
+	return mock.Response{Message: step.AssistantMessage{
+		Parts: []step.Part{
+			step.ThinkingPart{ID: "t1", Thinking: "Hm"},
+			step.TextPart{Text: "He"}, step.TextPart{Text: "llo"}, step.TextPart{Text: ", w"}, step.TextPart{Text: "orld"},
+			step.ToolCallPart{CallID: "c1", Name: "missing", ArgsJSON: []byte(`{}`)},
+		},
+		StopReason: step.StopToolUse,
+	}}
+}
+
+func TestDeltaCoalescing_Runes(t *testing.T) {
+	var got []step.MessageDelta
+	record := step.WithOnDelta(func(d step.MessageDelta) { got = append(got, d) })
+	req := step.StepRequest{Provider: mock.New(fragmented())}
+	if _, err := step.Step(context.Background(), req, record, step.WithDeltaCoalescing(0, 5)); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	want := []step.MessageDelta{
+		step.ThinkingDelta{ID: "t1", Delta: "Hm"},
+		step.TextDelta{Delta: "Hello"},
+		step.TextDelta{Delta: ", world"},
+		step.ToolCallDelta{CallID: "c1", Name: "missing", ArgsDelta: "{}"},
+	}
+	if !reflect.DeepEqual(got[:len(want)], want) {
+		t.Errorf("unexpected deltas %#v", got)
+	}
+
+	// Without the option every fragment passes through.
+	got = nil
+	req.Provider = mock.New(fragmented())
+	if _, err := step.Step(context.Background(), req, record); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if texts := countText(got); texts != 4 {
+		t.Errorf("expected 4 passthrough text deltas, got %d", texts)
+	}
+}
+
+func countText(ds []step.MessageDelta) int {
+	n := 0
+	for _, d := range ds {
+		if _, ok := d.(step.TextDelta); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// pausingProvider emits one text delta, then waits for release before finishing.
+type pausingProvider struct{ release chan struct{} }
+
+func (p pausingProvider) Stream(context.Context, step.ProviderRequest) (step.ProviderStream, error) {
+	return &pausingStream{release: p.release}, nil
+}
+
+type pausingStream struct {
+	release chan struct{}
+	n       int
+}
+
+func (s *pausingStream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	s.n++
+	switch s.n {
+	case 1:
+		return step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: "a"}}, nil
+	case 2:
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return step.ProviderMessageUpdate{Message: step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "a"}}, StopReason: step.StopStop}}, nil
+	}
+	return nil, io.EOF
+}
+
+func (s *pausingStream) Close() error { return nil }
+
+func TestDeltaCoalescing_IntervalFlushesDuringPause(t *testing.T) {
+	release := make(chan struct{})
+	flushed := make(chan string, 1)
+	onDelta := step.WithOnDelta(func(d step.MessageDelta) {
+		if td, ok := d.(step.TextDelta); ok {
+			flushed <- td.Delta
+		}
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := step.Step(context.Background(), step.StepRequest{Provider: pausingProvider{release}}, onDelta, step.WithDeltaCoalescing(10*time.Millisecond, 0))
+		done <- err
+	}()
+	select {
+	case text := <-flushed:
+		if text != "a" {
+			t.Errorf("unexpected flush %q", text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pending delta was not flushed while the stream paused")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Package config builds providers and tool settings from a config file or the
+// environment, so operators can switch models without recompiling.
+//
+// A config file names providers by role and selects the tools an app exposes:
+//
+//	{
+//	  "providers": {
+//	    "main":  {"type": "anthropic", "model": "claude-sonnet-4-5"},
+//	    "fast":  {"type": "openai", "model": "gpt-4.1-mini", "api_key_env": "FAST_OPENAI_KEY"},
+//	    "local": {"type": "ollama", "model": "qwen3", "base_url": "http://gpu-box:11434/v1"}
+//	  },
+//	  "tools": {
+//	    "disabled": ["web_fetch"],
+//	    "policy": {"rules": [{"tool": "bash", "arg": "command", "match": "\\brm\\s+-rf", "effect": "deny"}]}
+//	  }
+//	}
+//
+// Environment variables override a provider's type, model and base URL, e.g.
+// STEP_MAIN_TYPE, STEP_MAIN_MODEL and STEP_MAIN_BASE_URL for "main"; a provider
+// can be configured by the environment alone. NewProvider reads the file named
+// by STEP_CONFIG, if any:
+//
+//	provider, err := config.NewProvider("main")
+//
+// Files are JSON, or YAML when named *.yaml or *.yml, with the same keys.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/policy"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfig names the environment variable holding the config file path read
+// by Default and NewProvider.
+const EnvConfig = "STEP_CONFIG"
+
+// ErrUnknownProvider is returned for provider names that are neither in the
+// config nor set in the environment.
+var ErrUnknownProvider = errors.New("config: unknown provider")
+
+// Config is a parsed config file.
+type Config struct {
+	// Providers maps names chosen by the app, such as "main" or "summarizer",
+	// to provider settings.
+	Providers map[string]ProviderConfig `json:"providers,omitempty" yaml:"providers,omitempty"`
+	Tools     ToolConfig                `json:"tools,omitzero" yaml:"tools,omitempty"`
+}
+
+// ProviderConfig configures one provider. Fields left empty fall back to the
+// provider package's defaults and environment variables.
+type ProviderConfig struct {
+	// Type selects the provider: anthropic, openai (Chat Completions),
+	// responses, google, openrouter, a compat profile name (vllm, litellm,
+	// llamacpp, lmstudio, ollama, together, fireworks), or compat for any
+	// other OpenAI-compatible server. See Register for custom types.
+	Type  string `json:"type" yaml:"type"`
+	Model string `json:"model" yaml:"model"`
+
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	// APIKeyEnv names an environment variable holding the API key, so keys
+	// stay out of the file. APIKey, if set, takes precedence.
+	APIKeyEnv string   `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`
+	APIKey    string   `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	APIKeys   []string `json:"api_keys,omitempty" yaml:"api_keys,omitempty"`
+
+	Temperature     *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	MaxOutputTokens *int     `json:"max_output_tokens,omitempty" yaml:"max_output_tokens,omitempty"`
+
+	// Debug is a JSONL file for provider debug records.
+	Debug string `json:"debug,omitempty" yaml:"debug,omitempty"`
+
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body    map[string]any    `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// ToolConfig selects which of an app's tools are exposed to the model.
+type ToolConfig struct {
+	// Enabled, if non-empty, lists the only tools to expose.
+	Enabled []string `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// Disabled lists tools to hide, even if enabled.
+	Disabled []string `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// Policy, if set, allows or denies calls of the exposed tools by their
+	// arguments.
+	Policy *policy.Policy `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// Filter returns the tools in tools allowed by c, in order, wrapped with
+// c.Policy if set.
+func (c ToolConfig) Filter(tools []step.Tool) []step.Tool {
+	var out []step.Tool
+	for _, t := range tools {
+		name := t.Spec().Name
+		if len(c.Enabled) > 0 && !slices.Contains(c.Enabled, name) {
+			continue
+		}
+		if slices.Contains(c.Disabled, name) {
+			continue
+		}
+		out = append(out, t)
+	}
+	if c.Policy != nil {
+		out = c.Policy.Wrap(out)
+	}
+	return out
+}
+
+// Load reads a config file: YAML if its extension is .yaml or .yml, JSON
+// otherwise.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parse := Parse
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		parse = ParseYAML
+	}
+	cfg, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse parses a JSON config. Unknown fields are rejected, so typos do not
+// silently fall back to defaults.
+func Parse(data []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return cfg.validate()
+}
+
+// ParseYAML parses a YAML config. Like Parse, it rejects unknown fields.
+func ParseYAML(data []byte) (*Config, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return cfg.validate()
+}
+
+func (c *Config) validate() (*Config, error) {
+	if p := c.Tools.Policy; p != nil {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("config: tools: %w", err)
+		}
+	}
+	return c, nil
+}
+
+var loadDefault = sync.OnceValues(func() (*Config, error) {
+	path := os.Getenv(EnvConfig)
+	if path == "" {
+		return &Config{}, nil
+	}
+	return Load(path)
+})
+
+// Default returns the config loaded from the file named by STEP_CONFIG, or an
+// empty config if it is unset. The file is read once.
+func Default() (*Config, error) {
+	return loadDefault()
+}
+
+// NewProvider creates the named provider from the default config.
+func NewProvider(name string) (step.Provider, error) {
+	cfg, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.NewProvider(name)
+}
+
+// Provider returns the settings for the named provider, with environment
+// overrides applied.
+func (c *Config) Provider(name string) (ProviderConfig, error) {
+	pc, ok := c.Providers[name]
+	prefix := envPrefix(name)
+	if v := os.Getenv(prefix + "TYPE"); v != "" {
+		pc.Type, ok = v, true
+	}
+	if v := os.Getenv(prefix + "MODEL"); v != "" {
+		pc.Model = v
+	}
+	if v := os.Getenv(prefix + "BASE_URL"); v != "" {
+		pc.BaseURL = v
+	}
+	if !ok {
+		return ProviderConfig{}, fmt.Errorf("%w %q", ErrUnknownProvider, name)
+	}
+	return pc, nil
+}
+
+// NewProvider creates the named provider.
+func (c *Config) NewProvider(name string) (step.Provider, error) {
+	pc, err := c.Provider(name)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := pc.New()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return provider, nil
+}
+
+// RegisterProviders registers every provider in c with step.RegisterProvider,
+// so they can be fetched by name with step.GetProvider. Providers are
+// constructed on first use.
+func (c *Config) RegisterProviders() {
+	for name := range c.Providers {
+		step.RegisterProvider(name, func() (step.Provider, error) {
+			return c.NewProvider(name)
+		})
+	}
+}
+
+// envPrefix returns the prefix of the environment overrides for name, e.g.
+// STEP_MAIN_ for "main" and STEP_FAST_MODEL_ for "fast-model".
+func envPrefix(name string) string {
+	return "STEP_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name) + "_"
+}
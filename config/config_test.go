@@ -0,0 +1,179 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/config"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+// recorded captures the settings a factory was called with.
+var recorded []config.ProviderConfig
+
+func init() {
+	config.Register("test", func(pc config.ProviderConfig) (step.Provider, error) {
+		recorded = append(recorded, pc)
+		return mock.New(), nil
+	})
+}
+
+const testConfig = `{
+	"providers": {
+		"main": {"type": "test", "model": "big", "api_key_env": "TEST_CONFIG_KEY", "temperature": 0.2},
+		"fast": {"type": "test", "model": "small", "api_key": "explicit", "api_key_env": "TEST_CONFIG_KEY"}
+	},
+	"tools": {"enabled": ["read", "bash", "write"], "disabled": ["bash"]}
+}`
+
+func TestConfig_NewProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "step.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	t.Setenv("TEST_CONFIG_KEY", "from-env")
+	t.Setenv("STEP_FAST_MODEL", "tiny")
+
+	recorded = nil
+	for _, name := range []string{"main", "fast"} {
+		if _, err := cfg.NewProvider(name); err != nil {
+			t.Fatalf("NewProvider(%q) failed: %v", name, err)
+		}
+	}
+	main, fast := recorded[0], recorded[1]
+	if main.Model != "big" || main.APIKey != "from-env" || main.Temperature == nil || *main.Temperature != 0.2 {
+		t.Errorf("unexpected main settings: %+v", main)
+	}
+	if fast.Model != "tiny" || fast.APIKey != "explicit" {
+		t.Errorf("expected the env model and explicit key, got %+v", fast)
+	}
+
+	if _, err := cfg.NewProvider("missing"); !errors.Is(err, config.ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestConfig_EnvOnly(t *testing.T) {
+	t.Setenv("STEP_SUMMARY_TYPE", "test")
+	t.Setenv("STEP_SUMMARY_MODEL", "env-model")
+
+	recorded = nil
+	if _, err := (&config.Config{}).NewProvider("summary"); err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if recorded[0].Model != "env-model" {
+		t.Errorf("expected the model from the environment, got %+v", recorded[0])
+	}
+
+	t.Setenv("STEP_SUMMARY_TYPE", "nonexistent")
+	if _, err := (&config.Config{}).NewProvider("summary"); !errors.Is(err, config.ErrUnknownType) {
+		t.Errorf("expected ErrUnknownType, got %v", err)
+	}
+}
+
+func TestLoad_YAML(t *testing.T) {
+	const yamlConfig = `
+providers:
+  main: {type: test, model: big, temperature: 0.2, body: {top_k: 40}}
+tools:
+  disabled: [bash]
+  policy:
+    rules:
+      - {tool: write, effect: deny}
+`
+	path := filepath.Join(t.TempDir(), "step.yml")
+	if err := os.WriteFile(path, []byte(yamlConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	main := cfg.Providers["main"]
+	if main.Model != "big" || main.Temperature == nil || *main.Temperature != 0.2 || main.Body["top_k"] != 40 {
+		t.Errorf("unexpected main settings: %+v", main)
+	}
+	if cfg.Tools.Policy == nil || len(cfg.Tools.Disabled) != 1 {
+		t.Errorf("unexpected tool settings: %+v", cfg.Tools)
+	}
+	if _, err := config.ParseYAML([]byte("providers:\n  main: {type: test, modle: x}\n")); err == nil {
+		t.Error("expected a misspelled field to be rejected")
+	}
+}
+
+func TestParse_UnknownField(t *testing.T) {
+	if _, err := config.Parse([]byte(`{"providers": {"main": {"type": "test", "modle": "x"}}}`)); err == nil {
+		t.Error("expected a misspelled field to be rejected")
+	}
+}
+
+type namedTool string
+
+func (n namedTool) Spec() step.ToolSpec { return step.ToolSpec{Name: string(n)} }
+
+func (namedTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{}, nil
+}
+
+func TestToolConfig_Filter(t *testing.T) {
+	cfg, err := config.Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := cfg.Tools.Filter([]step.Tool{namedTool("bash"), namedTool("edit"), namedTool("read"), namedTool("write")})
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Spec().Name)
+	}
+	if len(names) != 2 || names[0] != "read" || names[1] != "write" {
+		t.Errorf("expected [read write], got %v", names)
+	}
+}
+
+func TestToolConfig_Policy(t *testing.T) {
+	cfg, err := config.Parse([]byte(`{"tools": {"policy": {"rules": [{"tool": "write", "arg": "path", "within": ["/workspace"], "effect": "allow"}, {"tool": "write", "effect": "deny"}]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := cfg.Tools.Filter([]step.Tool{namedTool("write")})[0]
+	res, _ := write.Execute(context.Background(), step.ToolCallPart{Name: "write", ArgsJSON: []byte(`{"path": "/etc/passwd"}`)})
+	if !res.IsError {
+		t.Error("expected the policy to deny writes outside /workspace")
+	}
+	if _, err := config.Parse([]byte(`{"tools": {"policy": {"rules": [{"tool": "write", "effect": "block"}]}}}`)); err == nil {
+		t.Error("expected an invalid policy to be rejected")
+	}
+}
+
+func TestTypes(t *testing.T) {
+	types := config.Types()
+	for _, want := range []string{"anthropic", "openai", "compat", "ollama", "test"} {
+		if !slices.Contains(types, want) {
+			t.Errorf("expected type %q in %v", want, types)
+		}
+	}
+}
+
+func TestConfig_RegisterProviders(t *testing.T) {
+	cfg, err := config.Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded = nil
+	cfg.RegisterProviders()
+	if len(recorded) != 0 {
+		t.Fatal("expected providers to be constructed lazily")
+	}
+	if _, err := step.GetProvider("main"); err != nil || len(recorded) != 1 || recorded[0].Model != "big" {
+		t.Errorf("expected main to be constructed on first use, got %v (%+v)", err, recorded)
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/anthropic"
+	"github.com/inspirepan/step/providers/base"
+	"github.com/inspirepan/step/providers/chatcompletion"
+	"github.com/inspirepan/step/providers/compat"
+	"github.com/inspirepan/step/providers/google"
+	"github.com/inspirepan/step/providers/openrouter"
+	"github.com/inspirepan/step/providers/responses"
+)
+
+// ErrUnknownType is returned for provider types without a registered Factory.
+var ErrUnknownType = errors.New("config: unknown provider type")
+
+// Factory creates a provider from its settings. APIKey is already resolved
+// from APIKeyEnv.
+type Factory func(pc ProviderConfig) (step.Provider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"anthropic": func(pc ProviderConfig) (step.Provider, error) {
+			return anthropic.New(pc.Model, func(c *anthropic.Config) { pc.apply(&c.Config) }), nil
+		},
+		"openai": func(pc ProviderConfig) (step.Provider, error) {
+			return chatcompletion.New(pc.Model, func(c *chatcompletion.Config) { pc.apply(&c.Config) }), nil
+		},
+		"responses": func(pc ProviderConfig) (step.Provider, error) {
+			return responses.New(pc.Model, func(c *responses.Config) { pc.apply(&c.Config) }), nil
+		},
+		"google": func(pc ProviderConfig) (step.Provider, error) {
+			return google.New(pc.Model, func(c *google.Config) { pc.apply(&c.Config) }), nil
+		},
+		"openrouter": func(pc ProviderConfig) (step.Provider, error) {
+			return openrouter.New(pc.Model, func(c *openrouter.Config) { pc.apply(&c.Config) }), nil
+		},
+		"compat": func(pc ProviderConfig) (step.Provider, error) {
+			if pc.BaseURL == "" {
+				return nil, errors.New("config: compat requires base_url")
+			}
+			return newCompat(pc, compat.Profile{Name: "compat"}), nil
+		},
+	}
+)
+
+func init() {
+	for _, profile := range []compat.Profile{
+		compat.ProfileVLLM,
+		compat.ProfileLiteLLM,
+		compat.ProfileLlamaCpp,
+		compat.ProfileLMStudio,
+		compat.ProfileOllama,
+		compat.ProfileTogether,
+		compat.ProfileFireworks,
+	} {
+		factories[profile.Name] = func(pc ProviderConfig) (step.Provider, error) {
+			return newCompat(pc, profile), nil
+		}
+	}
+}
+
+func newCompat(pc ProviderConfig, profile compat.Profile) step.Provider {
+	return compat.New(pc.Model, profile, func(c *compat.Config) { pc.apply(&c.Config) })
+}
+
+// Register makes a provider type available to config files, replacing any
+// existing factory for typ. It is typically called from an init function.
+func Register(typ string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[typ] = factory
+}
+
+// Types returns the registered provider types, sorted.
+func Types() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	types := make([]string, 0, len(factories))
+	for typ := range factories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates the provider described by pc.
+func (pc ProviderConfig) New() (step.Provider, error) {
+	if pc.Model == "" {
+		return nil, errors.New("config: model is required")
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[pc.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (want one of %v)", ErrUnknownType, pc.Type, Types())
+	}
+	if pc.APIKey == "" && pc.APIKeyEnv != "" {
+		pc.APIKey = os.Getenv(pc.APIKeyEnv)
+	}
+	return factory(pc)
+}
+
+// apply copies the settings shared by every provider into cfg.
+func (pc ProviderConfig) apply(cfg *base.Config) {
+	if pc.BaseURL != "" {
+		cfg.BaseURL = pc.BaseURL
+	}
+	if pc.APIKey != "" {
+		cfg.APIKey = pc.APIKey
+	}
+	if len(pc.APIKeys) > 0 {
+		cfg.Keys = base.StaticKeys(pc.APIKeys)
+	}
+	cfg.Temperature = pc.Temperature
+	cfg.MaxOutputTokens = pc.MaxOutputTokens
+	cfg.DebugPath = pc.Debug
+	if len(pc.Headers) > 0 {
+		cfg.ExtraHeaders = pc.Headers
+	}
+	if len(pc.Body) > 0 {
+		cfg.ExtraBody = pc.Body
+	}
+}
@@ -0,0 +1,99 @@
+package step
+
+import (
+	"context"
+)
+
+// Request-scoped values travel in the context passed to Provider.Stream and
+// Tool.Execute, so tools can authorize and log without global state:
+//
+//   - the session, user and tenant IDs are set by the caller with
+//     WithSessionID, WithUserID and WithTenantID and read with SessionID,
+//     UserID and TenantID;
+//   - the run ID and step index are set by Run and Resume for each step and
+//     read with RunID and StepIndex;
+//   - the idempotency key is set by Step and read with IdempotencyKey.
+//
+// Nested runs, such as Agent.AsTool, get a run ID of their own and inherit
+// the session, user and tenant IDs.
+type (
+	sessionIDKey struct{}
+	userIDKey    struct{}
+	tenantIDKey  struct{}
+	runScopeKey  struct{}
+	idemKey      struct{}
+)
+
+type runScope struct {
+	runID string
+	index int
+}
+
+// WithSessionID returns a context carrying the session ID id.
+func WithSessionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, id)
+}
+
+// SessionID returns the session ID carried by ctx, or "".
+func SessionID(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDKey{}).(string)
+	return id
+}
+
+// WithUserID returns a context carrying the user ID id.
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, id)
+}
+
+// UserID returns the user ID carried by ctx, or "".
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey{}).(string)
+	return id
+}
+
+// WithTenantID returns a context carrying the tenant ID id, e.g. the
+// organization a user belongs to; see MemoryQuota.
+func WithTenantID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantIDKey{}, id)
+}
+
+// TenantID returns the tenant ID carried by ctx, or "".
+func TenantID(ctx context.Context) string {
+	id, _ := ctx.Value(tenantIDKey{}).(string)
+	return id
+}
+
+// RunID returns the ID of the run whose step ctx belongs to, or "" outside a
+// Run. It matches RunResult.RunID.
+func RunID(ctx context.Context) string {
+	s, _ := ctx.Value(runScopeKey{}).(runScope)
+	return s.runID
+}
+
+// StepIndex returns the zero-based index of the step ctx belongs to within its
+// run, counting steps taken before a pause. ok is false outside a Run.
+func StepIndex(ctx context.Context) (index int, ok bool) {
+	s, ok := ctx.Value(runScopeKey{}).(runScope)
+	return s.index, ok
+}
+
+func withRunStep(ctx context.Context, runID string, index int) context.Context {
+	return context.WithValue(ctx, runScopeKey{}, runScope{runID: runID, index: index})
+}
+
+// WithIdempotencyKey returns a context carrying the idempotency key key.
+// Step sets a new key for every step, outside its middlewares, so a retry
+// middleware calling next again resends the same key and the provider can
+// deduplicate the request. A middleware that changes the request between
+// attempts should give each attempt a key of its own with NewIdempotencyKey.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idemKey{}, key)
+}
+
+// IdempotencyKey returns the idempotency key carried by ctx, or "".
+// Providers that support it send it in the Idempotency-Key header, and
+// providers record it in their debug logs.
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idemKey{}).(string)
+	return key
+}
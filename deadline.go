@@ -0,0 +1,105 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Phase is a part of a step with its own time budget; see WithPhaseBudgets.
+type Phase string
+
+const (
+	// PhaseProvider is the provider call, up to the final assistant message.
+	PhaseProvider Phase = "provider"
+	// PhaseTools is the execution of the message's tool calls.
+	PhaseTools Phase = "tools"
+)
+
+// PhaseDeadlineError is returned when a phase ran out of its budget. It
+// matches context.DeadlineExceeded, so errors.Is checks keep working.
+type PhaseDeadlineError struct {
+	Phase Phase
+	// Budget is the time the phase had when it started.
+	Budget time.Duration
+}
+
+func (e *PhaseDeadlineError) Error() string {
+	return fmt.Sprintf("step: %s phase exceeded its %s budget", e.Phase, e.Budget.Round(time.Millisecond))
+}
+
+// Is reports whether target is context.DeadlineExceeded.
+func (e *PhaseDeadlineError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+type phaseConfig struct {
+	total time.Duration
+	share float64
+}
+
+// WithPhaseBudgets splits a step's time between the provider call and tool
+// execution, so a slow generation cannot leave the tools without time. The
+// provider call gets providerShare of the step's time, e.g. 0.8; the tools
+// get the rest plus whatever the provider call left unused.
+//
+// The step's time is total, or the time left until the context deadline when
+// that is earlier or total is zero. Without either the option has no effect.
+// providerShare outside (0, 1) disables the split. When a phase runs out,
+// the step returns a *PhaseDeadlineError naming it.
+func WithPhaseBudgets(total time.Duration, providerShare float64) StepOption {
+	return func(c *stepConfig) {
+		c.phases = phaseConfig{total: total, share: providerShare}
+	}
+}
+
+// phaseDeadlines holds the deadlines of one step. A nil *phaseDeadlines
+// leaves the context alone.
+type phaseDeadlines struct {
+	provider time.Time
+	step     time.Time
+	budget   map[Phase]time.Duration
+}
+
+// startPhases computes the deadlines of a step starting now.
+func startPhases(ctx context.Context, cfg phaseConfig) *phaseDeadlines {
+	if cfg.share <= 0 || cfg.share >= 1 {
+		return nil
+	}
+	now := time.Now()
+	end, ok := ctx.Deadline()
+	if cfg.total > 0 && (!ok || now.Add(cfg.total).Before(end)) {
+		end, ok = now.Add(cfg.total), true
+	}
+	if !ok {
+		return nil
+	}
+	budget := time.Duration(float64(end.Sub(now)) * cfg.share)
+	return &phaseDeadlines{
+		provider: now.Add(budget),
+		step:     end,
+		budget:   map[Phase]time.Duration{PhaseProvider: budget},
+	}
+}
+
+// context returns ctx bounded by phase's deadline.
+func (p *phaseDeadlines) context(ctx context.Context, phase Phase) (context.Context, context.CancelFunc) {
+	if p == nil {
+		return ctx, func() {}
+	}
+	if phase == PhaseProvider {
+		return context.WithDeadline(ctx, p.provider)
+	}
+	p.budget[phase] = time.Until(p.step)
+	return context.WithDeadline(ctx, p.step)
+}
+
+// err returns the error of a done phase context, typed when it ran out of
+// time.
+func (p *phaseDeadlines) err(ctx context.Context, phase Phase) error {
+	if p != nil && errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		return &PhaseDeadlineError{Phase: phase, Budget: p.budget[phase]}
+	}
+	return ctx.Err()
+}
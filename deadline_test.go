@@ -0,0 +1,77 @@
+package step_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+// waitTool blocks until its context is done and records the time it had.
+type waitTool struct{ budget *time.Duration }
+
+func (waitTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "wait"} }
+
+func (t waitTool) Execute(ctx context.Context, _ step.ToolCallPart) (step.ToolResult, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		*t.budget = time.Until(deadline)
+	}
+	<-ctx.Done()
+	return step.ToolResult{}, ctx.Err()
+}
+
+func TestStep_PhaseBudgets(t *testing.T) {
+	t.Run("provider", func(t *testing.T) {
+		start := time.Now()
+		result, err := step.Step(context.Background(), step.StepRequest{Provider: stallingProvider{finalize: true}},
+			step.WithPhaseBudgets(200*time.Millisecond, 0.25))
+		var phaseErr *step.PhaseDeadlineError
+		if !errors.As(err, &phaseErr) || phaseErr.Phase != step.PhaseProvider || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want a provider phase deadline", err)
+		}
+		if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+			t.Errorf("provider phase took %s, want about 50ms", elapsed)
+		}
+		msg := result[0].(step.AssistantMessage)
+		if reason, ok := step.CancelReasonOf(msg); !ok || reason != step.CancelTimeout {
+			t.Errorf("message %+v has reason %q, want timeout", msg, reason)
+		}
+	})
+
+	t.Run("tools", func(t *testing.T) {
+		var budget time.Duration
+		req := step.StepRequest{
+			Provider: mock.New(mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "wait", ArgsJSON: []byte(`{}`)})),
+			Tools:    []step.Tool{waitTool{budget: &budget}},
+		}
+		result, err := step.Step(context.Background(), req, step.WithPhaseBudgets(200*time.Millisecond, 0.25))
+		var phaseErr *step.PhaseDeadlineError
+		if !errors.As(err, &phaseErr) || phaseErr.Phase != step.PhaseTools {
+			t.Fatalf("err = %v, want a tools phase deadline", err)
+		}
+		// The provider answered at once, so the tools get its unused time too.
+		if budget < 150*time.Millisecond {
+			t.Errorf("tool had %s, want the rest of the step", budget)
+		}
+		if len(result) != 2 || !result[1].(step.ToolResultMessage).IsError {
+			t.Errorf("result = %+v, want an interrupted tool result", result)
+		}
+	})
+
+	t.Run("context deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := step.Step(ctx, step.StepRequest{Provider: stallingProvider{}}, step.WithPhaseBudgets(0, 0.5))
+		var phaseErr *step.PhaseDeadlineError
+		if !errors.As(err, &phaseErr) || phaseErr.Phase != step.PhaseProvider {
+			t.Fatalf("err = %v, want a provider phase deadline", err)
+		}
+		if elapsed := time.Since(start); elapsed > 90*time.Millisecond {
+			t.Errorf("provider phase took %s, want about 50ms", elapsed)
+		}
+	})
+}
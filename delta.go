@@ -1,5 +1,7 @@
 package step
 
+import "time"
+
 // DeltaKind describes the kind of a MessageDelta.
 type DeltaKind string
 
@@ -7,6 +9,7 @@ const (
 	DeltaStep     DeltaKind = "step"
 	DeltaThinking DeltaKind = "thinking"
 	DeltaText     DeltaKind = "text"
+	DeltaAudio    DeltaKind = "audio"
 	DeltaToolCall DeltaKind = "tool_call"
 	DeltaToolExec DeltaKind = "tool_exec"
 )
@@ -33,11 +36,26 @@ type TextDelta struct {
 
 func (TextDelta) deltaKind() DeltaKind { return DeltaText }
 
+// AudioDelta streams spoken output: a chunk of audio for playback, a fragment
+// of its transcript, or both.
+type AudioDelta struct {
+	MimeType   string
+	Data       []byte
+	Transcript string
+}
+
+func (AudioDelta) deltaKind() DeltaKind { return DeltaAudio }
+
 // ToolCallDelta streams tool call construction.
 type ToolCallDelta struct {
 	CallID    string
 	Name      string
 	ArgsDelta string
+
+	// PartialArgs holds the arguments parsed so far, including the current
+	// value of a string still being written. It is only set with
+	// WithPartialToolArgs, and is nil until the arguments parse as an object.
+	PartialArgs map[string]any
 }
 
 func (ToolCallDelta) deltaKind() DeltaKind { return DeltaToolCall }
@@ -49,9 +67,29 @@ type ToolExecStartDelta struct {
 
 func (ToolExecStartDelta) deltaKind() DeltaKind { return DeltaToolExec }
 
+// ToolExecEndDelta signals that a tool call announced by ToolExecStartDelta
+// finished. It is emitted before the call's result message.
+type ToolExecEndDelta struct {
+	Call ToolCallPart
+	// Duration is how long the call ran, until it was interrupted if it was.
+	Duration time.Duration
+	// Attempts is how often the tool was executed for the call, more than
+	// once when its RetryPolicy retried it, or 0 if it never ran.
+	Attempts int
+	IsError  bool
+	// Interrupted reports that the step's context ended the call.
+	Interrupted bool
+	// Truncated reports that the tool cut its output; see DetailsTruncated.
+	Truncated bool
+}
+
+func (ToolExecEndDelta) deltaKind() DeltaKind { return DeltaToolExec }
+
 // StepStatusDelta reports step-level status updates.
 type StepStatusDelta struct {
 	Cancelled bool
+	// Reason is why the step was cancelled; see CancelReason.
+	Reason CancelReason `json:",omitempty"`
 }
 
 func (StepStatusDelta) deltaKind() DeltaKind { return DeltaStep }
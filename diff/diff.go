@@ -0,0 +1,250 @@
+// Package diff compares two run transcripts, such as a recorded baseline and
+// a run after a prompt or model change, and reports the differences in
+// behavior: other tools called, other arguments, or text that changed beyond
+// a similarity threshold. It is meant for gating such changes in CI, e.g. on
+// the transcripts of eval.Run or of runs against a replay provider.
+//
+// Transcripts are compared turn by turn, one turn per assistant message. Tool
+// results are not compared: they follow from the calls.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/inspirepan/step"
+	unified "github.com/inspirepan/step/internal/diff"
+)
+
+// Kind is the kind of a Difference.
+type Kind string
+
+const (
+	// Turns differences are a different number of assistant turns.
+	Turns Kind = "turns"
+	// ToolCalls differences are a turn calling other tools.
+	ToolCalls Kind = "tool_calls"
+	// ToolArgs differences are a call of the same tool with other arguments.
+	ToolArgs Kind = "tool_args"
+	// Text differences are turn texts less similar than the threshold.
+	Text Kind = "text"
+)
+
+// Difference is one semantic difference between two transcripts.
+type Difference struct {
+	Kind Kind
+	// Turn is the index of the assistant turn, from 0; -1 for Turns.
+	Turn int
+	// Tool is the tool of ToolArgs differences.
+	Tool string
+	// Old and New describe the compared values: tool names, arguments as
+	// JSON, texts or turn counts.
+	Old, New string
+	// Similarity is the text similarity of Text differences, from 0 to 1.
+	Similarity float64
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case Turns:
+		return fmt.Sprintf("%s turns instead of %s", d.New, d.Old)
+	case ToolCalls:
+		return fmt.Sprintf("turn %d: called [%s] instead of [%s]", d.Turn+1, d.New, d.Old)
+	case ToolArgs:
+		return fmt.Sprintf("turn %d: called %s with %s instead of %s", d.Turn+1, d.Tool, d.New, d.Old)
+	case Text:
+		return fmt.Sprintf("turn %d: text similarity %.2f\n%s", d.Turn+1, d.Similarity, unified.Unified("old", "new", lines(d.Old), lines(d.New)))
+	default:
+		return string(d.Kind)
+	}
+}
+
+// lines terminates the last line of a non-empty text.
+func lines(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
+
+// Options configures Compare.
+type Options struct {
+	// TextThreshold is the Similarity below which the texts of a turn
+	// differ. Defaults to 0.8; a negative value ignores text.
+	TextThreshold float64
+	// IgnoreArgs lists top-level argument names left out of the comparison,
+	// e.g. volatile IDs or timestamps.
+	IgnoreArgs []string
+}
+
+// Report is the outcome of Compare.
+type Report struct {
+	Differences []Difference
+}
+
+// Equal reports whether the transcripts showed no differences.
+func (r Report) Equal() bool { return len(r.Differences) == 0 }
+
+// String lists the differences, one per line, with text diffs below theirs.
+func (r Report) String() string {
+	var sb strings.Builder
+	for _, d := range r.Differences {
+		line := d.String()
+		sb.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// turn is what Compare looks at in an assistant message.
+type turn struct {
+	text  string
+	calls []step.ToolCallPart
+}
+
+func turns(transcript []step.Message) []turn {
+	var out []turn
+	for _, m := range transcript {
+		a, ok := m.(step.AssistantMessage)
+		if !ok {
+			continue
+		}
+		t := turn{text: a.Text()}
+		for _, p := range a.Parts {
+			if c, ok := p.(step.ToolCallPart); ok {
+				t.calls = append(t.calls, c)
+			}
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// Compare returns the differences of transcript after from transcript before.
+// Calls within a turn are compared regardless of their order, since parallel
+// calls may be issued in any order; calls of the same tool pair up in order.
+func Compare(before, after []step.Message, opts Options) Report {
+	if opts.TextThreshold == 0 {
+		opts.TextThreshold = 0.8
+	}
+	a, b := turns(before), turns(after)
+	var r Report
+	if len(a) != len(b) {
+		r.Differences = append(r.Differences, Difference{Kind: Turns, Turn: -1, Old: fmt.Sprint(len(a)), New: fmt.Sprint(len(b))})
+	}
+	for i := range max(len(a), len(b)) {
+		var x, y turn
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		r.Differences = append(r.Differences, compareCalls(i, x.calls, y.calls, opts.IgnoreArgs)...)
+		if opts.TextThreshold > 0 && (x.text != "" || y.text != "") {
+			if s := Similarity(x.text, y.text); s < opts.TextThreshold {
+				r.Differences = append(r.Differences, Difference{Kind: Text, Turn: i, Old: x.text, New: y.text, Similarity: s})
+			}
+		}
+	}
+	return r
+}
+
+func compareCalls(turn int, before, after []step.ToolCallPart, ignore []string) []Difference {
+	names := func(calls []step.ToolCallPart) []string {
+		out := make([]string, len(calls))
+		for i, c := range calls {
+			out[i] = c.Name
+		}
+		slices.Sort(out)
+		return out
+	}
+	if a, b := names(before), names(after); !slices.Equal(a, b) {
+		return []Difference{{Kind: ToolCalls, Turn: turn, Old: strings.Join(a, " "), New: strings.Join(b, " ")}}
+	}
+
+	var out []Difference
+	used := make([]bool, len(after))
+	for _, c := range before {
+		j := 0
+		for used[j] || after[j].Name != c.Name {
+			j++
+		}
+		used[j] = true
+		x, y := args(c.ArgsJSON, ignore), args(after[j].ArgsJSON, ignore)
+		if !reflect.DeepEqual(x, y) {
+			out = append(out, Difference{Kind: ToolArgs, Turn: turn, Tool: c.Name, Old: encode(x), New: encode(y)})
+		}
+	}
+	return out
+}
+
+// args decodes raw arguments without the ignored names. Invalid JSON is
+// compared as a string.
+func args(raw json.RawMessage, ignore []string) any {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	if m, ok := v.(map[string]any); ok {
+		for _, name := range ignore {
+			delete(m, name)
+		}
+	}
+	return v
+}
+
+// encode returns v as JSON with sorted keys.
+func encode(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// maxCells bounds the table of Similarity; longer texts fall back to
+// comparing word counts.
+const maxCells = 4 << 20
+
+// Similarity returns how alike texts a and b are, from 0 to 1: twice the
+// length of the longest common subsequence of their words over the total
+// number of words. Equal texts, including two empty ones, score 1.
+func Similarity(a, b string) float64 {
+	x, y := strings.Fields(a), strings.Fields(b)
+	if len(x)+len(y) == 0 {
+		return 1
+	}
+	var common int
+	if len(x)*len(y) > maxCells {
+		counts := make(map[string]int, len(x))
+		for _, w := range x {
+			counts[w]++
+		}
+		for _, w := range y {
+			if counts[w] > 0 {
+				counts[w]--
+				common++
+			}
+		}
+	} else {
+		prev, cur := make([]int, len(y)+1), make([]int, len(y)+1)
+		for i := range x {
+			for j := range y {
+				if x[i] == y[j] {
+					cur[j+1] = prev[j] + 1
+				} else {
+					cur[j+1] = max(prev[j+1], cur[j])
+				}
+			}
+			prev, cur = cur, prev
+		}
+		common = prev[len(y)]
+	}
+	return 2 * float64(common) / float64(len(x)+len(y))
+}
@@ -0,0 +1,89 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/diff"
+)
+
+func assistant(text string, calls ...step.ToolCallPart) step.AssistantMessage {
+	parts := []step.Part{step.TextPart{Text: text}}
+	for _, c := range calls {
+		parts = append(parts, c)
+	}
+	return step.AssistantMessage{Parts: parts}
+}
+
+func call(name, args string) step.ToolCallPart {
+	return step.ToolCallPart{CallID: name, Name: name, ArgsJSON: []byte(args)}
+}
+
+func TestCompare(t *testing.T) {
+	base := []step.Message{
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "fix the test"}}},
+		assistant("Let me look at the test first.", call("read_file", `{"path":"a_test.go","id":1}`), call("grep", `{"pattern":"Foo"}`)),
+		step.ToolResultMessage{CallID: "read_file", Parts: []step.Part{step.TextPart{Text: "..."}}},
+		assistant("The assertion compares the wrong field, so I changed it to compare the name."),
+	}
+
+	// Reordered parallel calls, ignored arguments and reworded text are no
+	// differences.
+	same := []step.Message{
+		assistant("Let me look at the test first.", call("grep", `{"pattern":"Foo"}`), call("read_file", `{"id":2,"path":"a_test.go"}`)),
+		assistant("The assertion compares the wrong field, so I changed it to compare the name instead."),
+	}
+	if r := diff.Compare(base, same, diff.Options{IgnoreArgs: []string{"id"}}); !r.Equal() {
+		t.Errorf("unexpected differences:\n%s", r)
+	}
+
+	changed := []step.Message{
+		assistant("Let me look at the test first.", call("read_file", `{"path":"b_test.go"}`), call("grep", `{"pattern":"Foo"}`)),
+		assistant("Done.", call("bash", `{"command":"go test"}`)),
+		assistant("All tests pass."),
+	}
+	r := diff.Compare(base, changed, diff.Options{IgnoreArgs: []string{"id"}})
+	var kinds []diff.Kind
+	for _, d := range r.Differences {
+		kinds = append(kinds, d.Kind)
+	}
+	want := []diff.Kind{diff.Turns, diff.ToolArgs, diff.ToolCalls, diff.Text, diff.Text}
+	if strings.Join(strs(kinds), " ") != strings.Join(strs(want), " ") {
+		t.Fatalf("got differences %v, want %v:\n%s", kinds, want, r)
+	}
+	for _, line := range []string{
+		"3 turns instead of 2",
+		`turn 1: called read_file with {"path":"b_test.go"} instead of {"path":"a_test.go"}`,
+		"turn 2: called [bash] instead of []",
+		"-The assertion compares the wrong field, so I changed it to compare the name.\n+Done.",
+	} {
+		if !strings.Contains(r.String(), line) {
+			t.Errorf("report lacks %q:\n%s", line, r)
+		}
+	}
+}
+
+func strs(kinds []diff.Kind) []string {
+	out := make([]string, len(kinds))
+	for i, k := range kinds {
+		out[i] = string(k)
+	}
+	return out
+}
+
+func TestSimilarity(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"a b c", "a b c", 1},
+		{"a b c", "x y z", 0},
+		{"a b c d", "a c", 2 * 2.0 / 6},
+	} {
+		if got := diff.Similarity(tc.a, tc.b); got != tc.want {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
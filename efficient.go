@@ -0,0 +1,89 @@
+package step
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// WithEfficientTools asks for token-efficient tool use. Step strips keywords
+// that only annotate a schema (title, examples, $comment, $schema) from the
+// tool parameters, and providers with a native optimization enable it, e.g.
+// Anthropic's token-efficient tools beta. See ProviderRequest.EfficientTools.
+//
+// The input tokens saved by the smaller schemas are estimated and reported in
+// Usage.SavedInputTokens. Savings in output tokens are not measured.
+func WithEfficientTools() StepOption {
+	return func(c *stepConfig) { c.efficientTools = true }
+}
+
+// bytesPerToken is the rough size of a token of JSON, for estimating savings.
+const bytesPerToken = 4
+
+// annotationKeywords are schema keywords without effect on validation.
+var annotationKeywords = []string{"title", "examples", "$comment", "$schema"}
+
+// compactToolSpecs returns specs with annotation keywords removed from their
+// parameters, and the estimated input tokens saved.
+func compactToolSpecs(specs []ToolSpec) ([]ToolSpec, int) {
+	before, _ := json.Marshal(specs)
+	out := make([]ToolSpec, len(specs))
+	for i, spec := range specs {
+		if spec.Parameters != nil {
+			spec.Parameters = compactSchema(spec.Parameters).(map[string]any)
+		}
+		out[i] = spec
+	}
+	after, _ := json.Marshal(out)
+	return out, max(len(before)-len(after), 0) / bytesPerToken
+}
+
+// compactSchema returns a copy of schema without annotation keywords. The
+// keys of properties and definitions are names, not keywords, and are kept.
+func compactSchema(schema any) any {
+	switch s := schema.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(s))
+		for k, v := range s {
+			out[k] = compactSchema(v)
+		}
+		for _, k := range annotationKeywords {
+			delete(out, k)
+		}
+		for _, k := range []string{"properties", "patternProperties", "$defs", "definitions"} {
+			if named, ok := s[k].(map[string]any); ok {
+				m := make(map[string]any, len(named))
+				for name, sub := range named {
+					m[name] = compactSchema(sub)
+				}
+				out[k] = m
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(s))
+		for i, v := range s {
+			out[i] = compactSchema(v)
+		}
+		return out
+	default:
+		return schema
+	}
+}
+
+// savingsStream reports the tokens saved by compactToolSpecs in the usage of
+// the messages of a stream.
+type savingsStream struct {
+	ProviderStream
+	saved int
+}
+
+func (s savingsStream) Next(ctx context.Context) (ProviderUpdate, error) {
+	up, err := s.ProviderStream.Next(ctx)
+	if u, ok := up.(ProviderMessageUpdate); ok && u.Message.Usage != nil {
+		usage := *u.Message.Usage
+		usage.SavedInputTokens = s.saved
+		u.Message.Usage = &usage
+		up = u
+	}
+	return up, err
+}
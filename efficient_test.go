@@ -0,0 +1,72 @@
+package step_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+// specTool is a tool with a fixed spec that is never called.
+type specTool struct{ spec step.ToolSpec }
+
+func (t specTool) Spec() step.ToolSpec { return t.spec }
+
+func (specTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{}, nil
+}
+
+func TestWithEfficientTools(t *testing.T) {
+	tool := specTool{step.ToolSpec{Name: "search", Parameters: map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "SearchArgs",
+		"type":    "object",
+		"properties": map[string]any{
+			// A property may be named like an annotation keyword.
+			"title": map[string]any{"type": "string", "title": "Title", "examples": []any{"Go", "Rust"}},
+			"tags":  map[string]any{"type": "array", "items": map[string]any{"type": "string", "$comment": "lowercase"}},
+		},
+		"required": []any{"title"},
+	}}}
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"title": map[string]any{"type": "string"},
+			"tags":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+		"required": []any{"title"},
+	}
+
+	response := mock.Text("ok")
+	response.Message.Usage = &step.Usage{InputTokens: 100, OutputTokens: 1, TotalTokens: 101}
+	provider := mock.New(response, response)
+	req := step.StepRequest{Provider: provider, Tools: []step.Tool{tool}}
+
+	result, err := step.Step(context.Background(), req, step.WithEfficientTools())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := provider.Requests()[0]
+	if !sent.EfficientTools || !reflect.DeepEqual(sent.Tools[0].Parameters, want) {
+		t.Errorf("sent %+v, want compacted parameters %v", sent, want)
+	}
+	if _, ok := tool.spec.Parameters["title"]; !ok {
+		t.Error("the tool's own schema was modified")
+	}
+	if usage := result[0].(step.AssistantMessage).Usage; usage.SavedInputTokens <= 0 || usage.InputTokens != 100 {
+		t.Errorf("usage = %+v, want saved input tokens reported", usage)
+	}
+
+	result, err = step.Step(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := provider.Requests()[1]; sent.EfficientTools || !reflect.DeepEqual(sent.Tools[0].Parameters, tool.spec.Parameters) {
+		t.Errorf("tools compacted without WithEfficientTools: %+v", sent.Tools)
+	}
+	if usage := result[0].(step.AssistantMessage).Usage; usage.SavedInputTokens != 0 {
+		t.Errorf("usage = %+v, want no savings", usage)
+	}
+}
@@ -1,7 +1,24 @@
 package step
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrNoProvider = errors.New("step: provider is required")
 )
+
+// InvalidJSONError is returned when a provider configured for JSON output
+// finishes with text that does not parse as JSON.
+// Message is the complete assistant message, so callers can inspect or retry.
+type InvalidJSONError struct {
+	Message AssistantMessage
+	Err     error
+}
+
+func (e *InvalidJSONError) Error() string {
+	return fmt.Sprintf("step: response is not valid JSON: %v", e.Err)
+}
+
+func (e *InvalidJSONError) Unwrap() error { return e.Err }
@@ -0,0 +1,201 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// AssertionResult is the outcome of a single assertion.
+type AssertionResult struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// Assertion checks a property of a finished run.
+type Assertion interface {
+	Check(run Run) AssertionResult
+}
+
+// AssertionFunc adapts a function to the Assertion interface.
+type AssertionFunc struct {
+	Name string
+	Fn   func(run Run) (bool, string)
+}
+
+func (a AssertionFunc) Check(run Run) AssertionResult {
+	ok, msg := a.Fn(run)
+	return AssertionResult{Name: a.Name, Passed: ok, Message: msg}
+}
+
+// ToolCalled asserts that a tool with the given name was called.
+// If args is non-nil, at least one call must contain every key/value in args.
+func ToolCalled(name string, args map[string]any) Assertion {
+	return AssertionFunc{
+		Name: "tool_called:" + name,
+		Fn: func(run Run) (bool, string) {
+			calls := toolCalls(run.Transcript, name)
+			if len(calls) == 0 {
+				return false, fmt.Sprintf("tool %q was not called", name)
+			}
+			if args == nil {
+				return true, ""
+			}
+			for _, c := range calls {
+				var got map[string]any
+				if err := json.Unmarshal(c.ArgsJSON, &got); err != nil {
+					continue
+				}
+				if containsArgs(got, args) {
+					return true, ""
+				}
+			}
+			return false, fmt.Sprintf("no call to %q matched args %v", name, args)
+		},
+	}
+}
+
+// ToolNotCalled asserts that a tool with the given name was never called.
+func ToolNotCalled(name string) Assertion {
+	return AssertionFunc{
+		Name: "tool_not_called:" + name,
+		Fn: func(run Run) (bool, string) {
+			if n := len(toolCalls(run.Transcript, name)); n > 0 {
+				return false, fmt.Sprintf("tool %q was called %d time(s)", name, n)
+			}
+			return true, ""
+		},
+	}
+}
+
+// ToolCallCount asserts the total number of tool calls in the run.
+func ToolCallCount(n int) Assertion {
+	return AssertionFunc{
+		Name: fmt.Sprintf("tool_call_count:%d", n),
+		Fn: func(run Run) (bool, string) {
+			if got := len(toolCalls(run.Transcript, "")); got != n {
+				return false, fmt.Sprintf("expected %d tool calls, got %d", n, got)
+			}
+			return true, ""
+		},
+	}
+}
+
+// TextContains asserts that the final assistant text contains substr (case-insensitive).
+func TextContains(substr string) Assertion {
+	return AssertionFunc{
+		Name: "text_contains:" + substr,
+		Fn: func(run Run) (bool, string) {
+			text := FinalText(run.Transcript)
+			if !strings.Contains(strings.ToLower(text), strings.ToLower(substr)) {
+				return false, fmt.Sprintf("final text does not contain %q: %q", substr, text)
+			}
+			return true, ""
+		},
+	}
+}
+
+// TextMatches asserts that the final assistant text matches the regular expression.
+func TextMatches(pattern string) Assertion {
+	re := regexp.MustCompile(pattern)
+	return AssertionFunc{
+		Name: "text_matches:" + pattern,
+		Fn: func(run Run) (bool, string) {
+			text := FinalText(run.Transcript)
+			if !re.MatchString(text) {
+				return false, fmt.Sprintf("final text does not match %q: %q", pattern, text)
+			}
+			return true, ""
+		},
+	}
+}
+
+// MaxLatency asserts that the run completed within d.
+func MaxLatency(d time.Duration) Assertion {
+	return AssertionFunc{
+		Name: "max_latency:" + d.String(),
+		Fn: func(run Run) (bool, string) {
+			if run.Latency > d {
+				return false, fmt.Sprintf("latency %s exceeds %s", run.Latency, d)
+			}
+			return true, ""
+		},
+	}
+}
+
+// MaxCost asserts that the estimated cost does not exceed limit.
+func MaxCost(limit float64) Assertion {
+	return AssertionFunc{
+		Name: fmt.Sprintf("max_cost:%g", limit),
+		Fn: func(run Run) (bool, string) {
+			if run.Cost > limit {
+				return false, fmt.Sprintf("cost %g exceeds %g", run.Cost, limit)
+			}
+			return true, ""
+		},
+	}
+}
+
+// FinalText returns the concatenated text of the last assistant message in the transcript.
+func FinalText(transcript []step.Message) string {
+	for i := len(transcript) - 1; i >= 0; i-- {
+		m, ok := transcript[i].(step.AssistantMessage)
+		if !ok {
+			continue
+		}
+		var sb strings.Builder
+		for _, part := range m.Parts {
+			if p, ok := part.(step.TextPart); ok {
+				sb.WriteString(p.Text)
+			}
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// toolCalls returns calls with the given name, or all calls if name is empty.
+func toolCalls(transcript []step.Message, name string) []step.ToolCallPart {
+	var calls []step.ToolCallPart
+	for _, msg := range transcript {
+		m, ok := msg.(step.AssistantMessage)
+		if !ok {
+			continue
+		}
+		for _, part := range m.Parts {
+			tc, ok := part.(step.ToolCallPart)
+			if ok && (name == "" || tc.Name == name) {
+				calls = append(calls, tc)
+			}
+		}
+	}
+	return calls
+}
+
+func containsArgs(got, want map[string]any) bool {
+	for k, wv := range want {
+		gv, ok := got[k]
+		if !ok {
+			return false
+		}
+		// Normalize want through JSON so numeric types compare equal.
+		raw, err := json.Marshal(wv)
+		if err != nil {
+			return false
+		}
+		var norm any
+		if err := json.Unmarshal(raw, &norm); err != nil {
+			return false
+		}
+		if !reflect.DeepEqual(gv, norm) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+// Package eval runs scripted tasks against one or more providers and scores the results.
+package eval
+
+import (
+	"context"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+const defaultMaxSteps = 10
+
+// Task is a single scripted evaluation case.
+type Task struct {
+	Name         string
+	SystemPrompt string
+	History      []step.Message
+	Tools        []step.Tool
+	// MaxSteps bounds the agent loop. Defaults to 10.
+	MaxSteps   int
+	Assertions []Assertion
+}
+
+// Suite is a named collection of tasks.
+type Suite struct {
+	Name  string
+	Tasks []Task
+}
+
+// Pricing is the per-million-token price used to estimate cost.
+type Pricing struct {
+	InputPerMTok      float64
+	OutputPerMTok     float64
+	CachedReadPerMTok float64
+}
+
+// Cost estimates the cost of u in the pricing currency.
+func (p Pricing) Cost(u step.Usage) float64 {
+	uncached := u.InputTokens - u.CachedReadTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+	return (float64(uncached)*p.InputPerMTok +
+		float64(u.CachedReadTokens)*p.CachedReadPerMTok +
+		float64(u.OutputTokens)*p.OutputPerMTok) / 1e6
+}
+
+// Target is a provider under evaluation.
+type Target struct {
+	Name     string
+	Provider step.Provider
+	Pricing  Pricing
+}
+
+// Run is the outcome of one task against one target.
+type Run struct {
+	Task       string
+	Target     string
+	Transcript []step.Message
+	Usage      step.Usage
+	Cost       float64
+	Latency    time.Duration
+	Steps      int
+	Err        error
+	Results    []AssertionResult
+}
+
+// Score returns the fraction of passed assertions. A run with an error scores 0.
+func (r Run) Score() float64 {
+	if r.Err != nil {
+		return 0
+	}
+	if len(r.Results) == 0 {
+		return 1
+	}
+	passed := 0
+	for _, res := range r.Results {
+		if res.Passed {
+			passed++
+		}
+	}
+	return float64(passed) / float64(len(r.Results))
+}
+
+// Passed reports whether the run finished without error and all assertions passed.
+func (r Run) Passed() bool {
+	return r.Err == nil && r.Score() == 1
+}
+
+// Evaluate runs every task in the suite against every target sequentially.
+func Evaluate(ctx context.Context, suite Suite, targets ...Target) Report {
+	report := Report{Suite: suite.Name}
+	for _, target := range targets {
+		for _, task := range suite.Tasks {
+			report.Runs = append(report.Runs, RunTask(ctx, task, target))
+		}
+	}
+	return report
+}
+
+// RunTask runs a single task against a single target and evaluates its assertions.
+func RunTask(ctx context.Context, task Task, target Target) Run {
+	maxSteps := task.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultMaxSteps
+	}
+
+	run := Run{Task: task.Name, Target: target.Name}
+	history := append([]step.Message(nil), task.History...)
+	start := time.Now()
+
+	for run.Steps < maxSteps {
+		result, err := step.Step(ctx, step.StepRequest{
+			Provider:     target.Provider,
+			SystemPrompt: task.SystemPrompt,
+			History:      history,
+			Tools:        task.Tools,
+		})
+		run.Steps++
+		run.Transcript = append(run.Transcript, result...)
+		history = append(history, result...)
+		for _, msg := range result {
+			if m, ok := msg.(step.AssistantMessage); ok {
+				run.Usage.Add(m.Usage)
+			}
+		}
+		if err != nil {
+			run.Err = err
+			break
+		}
+		if !result.HasToolCall() {
+			break
+		}
+	}
+
+	run.Latency = time.Since(start)
+	run.Cost = target.Pricing.Cost(run.Usage)
+	for _, a := range task.Assertions {
+		run.Results = append(run.Results, a.Check(run))
+	}
+	return run
+}
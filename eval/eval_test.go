@@ -0,0 +1,87 @@
+package eval_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/eval"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+type echoTool struct{}
+
+func (echoTool) Spec() step.ToolSpec {
+	return step.ToolSpec{Name: "echo", Parameters: map[string]any{"type": "object"}}
+}
+
+func (echoTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: string(call.ArgsJSON)}}}, nil
+}
+
+func TestEvaluate(t *testing.T) {
+	provider := mock.New(
+		mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "echo", ArgsJSON: json.RawMessage(`{"text":"hi","n":2}`)}),
+		mock.Text("Done: hi"),
+	)
+	suite := eval.Suite{
+		Name: "smoke",
+		Tasks: []eval.Task{{
+			Name:    "echo",
+			History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "echo hi"}}}},
+			Tools:   []step.Tool{echoTool{}},
+			Assertions: []eval.Assertion{
+				eval.ToolCalled("echo", map[string]any{"text": "hi", "n": 2}),
+				eval.ToolCallCount(1),
+				eval.TextContains("done"),
+				eval.ToolNotCalled("rm"),
+			},
+		}},
+	}
+
+	report := eval.Evaluate(context.Background(), suite, eval.Target{Name: "mock", Provider: provider})
+	if len(report.Runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(report.Runs))
+	}
+	run := report.Runs[0]
+	if !run.Passed() {
+		t.Fatalf("expected run to pass: err=%v results=%+v", run.Err, run.Results)
+	}
+	if run.Steps != 2 {
+		t.Errorf("expected 2 steps, got %d", run.Steps)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<testcase name="echo"`) {
+		t.Errorf("unexpected junit output: %s", buf.String())
+	}
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"passed": true`) {
+		t.Errorf("unexpected json output: %s", buf.String())
+	}
+}
+
+func TestEvaluateFailure(t *testing.T) {
+	provider := mock.New(mock.Text("nope"))
+	task := eval.Task{
+		Name:       "fail",
+		History:    []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+		Assertions: []eval.Assertion{eval.TextContains("yes"), eval.TextMatches(`^no`)},
+	}
+	run := eval.RunTask(context.Background(), task, eval.Target{Name: "mock", Provider: provider})
+	if run.Passed() {
+		t.Fatal("expected run to fail")
+	}
+	if got := run.Score(); got != 0.5 {
+		t.Errorf("expected score 0.5, got %v", got)
+	}
+}
@@ -0,0 +1,151 @@
+package eval
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+// Report aggregates the runs of a suite.
+type Report struct {
+	Suite string
+	Runs  []Run
+}
+
+// Passed reports whether every run passed.
+func (r Report) Passed() bool {
+	for _, run := range r.Runs {
+		if !run.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+type jsonRun struct {
+	Task      string            `json:"task"`
+	Target    string            `json:"target"`
+	Passed    bool              `json:"passed"`
+	Score     float64           `json:"score"`
+	Steps     int               `json:"steps"`
+	LatencyMs int64             `json:"latency_ms"`
+	Cost      float64           `json:"cost"`
+	Usage     step.Usage        `json:"usage"`
+	Error     string            `json:"error,omitempty"`
+	Results   []AssertionResult `json:"results,omitempty"`
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	out := struct {
+		Suite  string    `json:"suite"`
+		Passed bool      `json:"passed"`
+		Runs   []jsonRun `json:"runs"`
+	}{Suite: r.Suite, Passed: r.Passed()}
+	for _, run := range r.Runs {
+		jr := jsonRun{
+			Task:      run.Task,
+			Target:    run.Target,
+			Passed:    run.Passed(),
+			Score:     run.Score(),
+			Steps:     run.Steps,
+			LatencyMs: run.Latency.Milliseconds(),
+			Cost:      run.Cost,
+			Usage:     run.Usage,
+			Results:   run.Results,
+		}
+		if run.Err != nil {
+			jr.Error = run.Err.Error()
+		}
+		out.Runs = append(out.Runs, jr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, one testsuite per target.
+func (r Report) WriteJUnit(w io.Writer) error {
+	var suites junitSuites
+	index := map[string]int{}
+	totals := map[int]float64{}
+	for _, run := range r.Runs {
+		i, ok := index[run.Target]
+		if !ok {
+			i = len(suites.Suites)
+			index[run.Target] = i
+			suites.Suites = append(suites.Suites, junitSuite{Name: r.Suite + "/" + run.Target})
+		}
+		s := &suites.Suites[i]
+		c := junitCase{
+			Name:      run.Task,
+			Classname: s.Name,
+			Time:      fmt.Sprintf("%.3f", run.Latency.Seconds()),
+		}
+		s.Tests++
+		totals[i] += run.Latency.Seconds()
+		switch {
+		case run.Err != nil:
+			s.Errors++
+			c.Error = &junitMessage{Message: run.Err.Error()}
+		case !run.Passed():
+			s.Failures++
+			var lines []string
+			for _, res := range run.Results {
+				if !res.Passed {
+					lines = append(lines, res.Name+": "+res.Message)
+				}
+			}
+			c.Failure = &junitMessage{
+				Message: fmt.Sprintf("score %.2f", run.Score()),
+				Body:    strings.Join(lines, "\n"),
+			}
+		}
+		s.Cases = append(s.Cases, c)
+	}
+	for i := range suites.Suites {
+		suites.Suites[i].Time = fmt.Sprintf("%.3f", totals[i])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -6,6 +6,7 @@ require (
 	github.com/anthropics/anthropic-sdk-go v1.19.0
 	github.com/joho/godotenv v1.5.1
 	github.com/openai/openai-go/v3 v3.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
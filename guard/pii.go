@@ -0,0 +1,268 @@
+// Package guard provides step middlewares that filter what flows between an
+// app and the model: personal data and secrets (PII) and prompt injection in
+// tool results (Injection).
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+// ErrBlocked matches every *BlockedError with errors.Is.
+var ErrBlocked = errors.New("guard: blocked")
+
+// BlockedError is returned when a policy blocks a step.
+type BlockedError struct {
+	// Direction is "input" for history sent to the model and "output" for
+	// messages the model produced.
+	Direction string
+	// Kinds are the kinds of data found, e.g. "email".
+	Kinds []string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("guard: %s blocked: contains %s", e.Direction, strings.Join(e.Kinds, ", "))
+}
+
+// Is reports whether target is ErrBlocked.
+func (e *BlockedError) Is(target error) bool { return target == ErrBlocked }
+
+// Finding is a span of text a Detector flagged.
+type Finding struct {
+	Kind       string
+	Start, End int
+}
+
+// Detector finds sensitive data in text.
+type Detector func(text string) []Finding
+
+// RegexpDetector returns a Detector reporting every match of re as kind.
+func RegexpDetector(kind string, re *regexp.Regexp) Detector {
+	return func(text string) []Finding {
+		var out []Finding
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			out = append(out, Finding{Kind: kind, Start: m[0], End: m[1]})
+		}
+		return out
+	}
+}
+
+var (
+	emailRe  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phoneRe  = regexp.MustCompile(`(?:\+\d{1,3}[ .-]?\(?\d{1,4}\)?[ .-]?\d{3,4}[ .-]?\d{3,4}|\(\d{3}\)[ .-]?\d{3}[ .-]\d{4}|\b\d{3}[.-]\d{3}[.-]\d{4})\b`)
+	keyRe    = regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})\b`)
+	cardRe   = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	ssnRe    = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	ipv4Re   = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)
+	digitsRe = regexp.MustCompile(`\D`)
+)
+
+// Built-in detectors.
+var (
+	Email  = RegexpDetector("email", emailRe)
+	Phone  = RegexpDetector("phone", phoneRe)
+	APIKey = RegexpDetector("api_key", keyRe)
+	SSN    = RegexpDetector("ssn", ssnRe)
+	IPv4   = RegexpDetector("ipv4", ipv4Re)
+	// CreditCard reports card numbers passing the Luhn check.
+	CreditCard Detector = func(text string) []Finding {
+		var out []Finding
+		for _, f := range RegexpDetector("credit_card", cardRe)(text) {
+			if luhn(digitsRe.ReplaceAllString(text[f.Start:f.End], "")) {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+)
+
+// DefaultDetectors returns the built-in detectors except IPv4, which flags
+// too many version numbers and addresses of public services to be a default.
+func DefaultDetectors() []Detector {
+	return []Detector{Email, APIKey, CreditCard, SSN, Phone}
+}
+
+func luhn(digits string) bool {
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range len(digits) {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// Action is what a PIIPolicy does with data it finds.
+type Action int
+
+const (
+	// Allow passes data through.
+	Allow Action = iota
+	// Mask replaces each finding, e.g. with "[EMAIL]".
+	Mask
+	// Block fails the step with a *BlockedError.
+	Block
+)
+
+// PIIPolicy configures PII.
+type PIIPolicy struct {
+	// Detectors default to DefaultDetectors.
+	Detectors []Detector
+	// Input applies to the history sent to the provider: user messages, tool
+	// results and earlier assistant messages. The caller's history is not
+	// modified.
+	Input Action
+	// Output applies to the messages a step returns. Deltas and message hooks
+	// see the output unfiltered, as they run while the step streams.
+	Output Action
+	// Replacement returns the text replacing a finding. Defaults to the kind
+	// in upper case in brackets, e.g. "[EMAIL]".
+	Replacement func(f Finding, text string) string
+}
+
+// PII returns a middleware that masks or blocks personal data and secrets
+// according to policy. It filters text parts only; tool call arguments,
+// images and files pass through.
+func PII(policy PIIPolicy) step.Middleware {
+	if policy.Detectors == nil {
+		policy.Detectors = DefaultDetectors()
+	}
+	if policy.Replacement == nil {
+		policy.Replacement = func(f Finding, _ string) string { return "[" + strings.ToUpper(f.Kind) + "]" }
+	}
+	return func(next step.StepFunc) step.StepFunc {
+		return func(ctx context.Context, req step.StepRequest) (step.StepResult, error) {
+			if policy.Input != Allow {
+				history, err := policy.filter(req.History, policy.Input, "input")
+				if err != nil {
+					return nil, err
+				}
+				req.History = history
+			}
+			res, err := next(ctx, req)
+			if policy.Output == Allow || len(res) == 0 {
+				return res, err
+			}
+			filtered, ferr := policy.filter(res, policy.Output, "output")
+			if ferr != nil {
+				return nil, ferr
+			}
+			return filtered, err
+		}
+	}
+}
+
+// filter applies action to the text parts of msgs, returning a copy if
+// anything changed.
+func (p PIIPolicy) filter(msgs []step.Message, action Action, direction string) ([]step.Message, error) {
+	var kinds []string
+	out, copied := msgs, false
+	for i, msg := range msgs {
+		parts := messageParts(msg)
+		var masked []step.Part
+		for j, part := range parts {
+			tp, ok := part.(step.TextPart)
+			if !ok {
+				continue
+			}
+			findings := p.detect(tp.Text)
+			if len(findings) == 0 {
+				continue
+			}
+			for _, f := range findings {
+				if !slices.Contains(kinds, f.Kind) {
+					kinds = append(kinds, f.Kind)
+				}
+			}
+			if action == Block {
+				continue
+			}
+			if masked == nil {
+				masked = slices.Clone(parts)
+			}
+			tp.Text = p.mask(tp.Text, findings)
+			masked[j] = tp
+		}
+		if masked != nil {
+			if !copied {
+				out, copied = slices.Clone(msgs), true
+			}
+			out[i] = withParts(msg, masked)
+		}
+	}
+	if action == Block && len(kinds) > 0 {
+		return nil, &BlockedError{Direction: direction, Kinds: kinds}
+	}
+	return out, nil
+}
+
+// detect returns the findings of every detector in text, sorted by position
+// with overlapping findings merged into the first.
+func (p PIIPolicy) detect(text string) []Finding {
+	var all []Finding
+	for _, d := range p.Detectors {
+		all = append(all, d(text)...)
+	}
+	slices.SortFunc(all, func(a, b Finding) int { return a.Start - b.Start })
+	var out []Finding
+	for _, f := range all {
+		if n := len(out); n > 0 && f.Start < out[n-1].End {
+			out[n-1].End = max(out[n-1].End, f.End)
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func (p PIIPolicy) mask(text string, findings []Finding) string {
+	var sb strings.Builder
+	prev := 0
+	for _, f := range findings {
+		sb.WriteString(text[prev:f.Start])
+		sb.WriteString(p.Replacement(f, text[f.Start:f.End]))
+		prev = f.End
+	}
+	sb.WriteString(text[prev:])
+	return sb.String()
+}
+
+func messageParts(msg step.Message) []step.Part {
+	switch m := msg.(type) {
+	case step.UserMessage:
+		return m.Parts
+	case step.AssistantMessage:
+		return m.Parts
+	case step.ToolResultMessage:
+		return m.Parts
+	}
+	return nil
+}
+
+func withParts(msg step.Message, parts []step.Part) step.Message {
+	switch m := msg.(type) {
+	case step.UserMessage:
+		m.Parts = parts
+		return m
+	case step.AssistantMessage:
+		m.Parts = parts
+		return m
+	case step.ToolResultMessage:
+		m.Parts = parts
+		return m
+	}
+	return msg
+}
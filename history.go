@@ -0,0 +1,185 @@
+package step
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HistoryProblem is a kind of corruption found by ValidateHistory.
+type HistoryProblem string
+
+const (
+	// ProblemMissingResult is a tool call without a result before the next
+	// user or assistant message, or the end of the history.
+	ProblemMissingResult HistoryProblem = "missing_result"
+	// ProblemOrphanResult is a tool result that answers no call of the
+	// assistant message before it, or answers one a second time.
+	ProblemOrphanResult HistoryProblem = "orphan_result"
+	// ProblemCallIDMismatch is a tool result whose CallID matches no call,
+	// while an unanswered call of the same tool precedes it, e.g. after a
+	// provider rewrote the call IDs.
+	ProblemCallIDMismatch HistoryProblem = "call_id_mismatch"
+	// ProblemDuplicateCallID is a tool call reusing the CallID of an earlier
+	// call.
+	ProblemDuplicateCallID HistoryProblem = "duplicate_call_id"
+	// ProblemEmptyMessage is an assistant message without parts.
+	ProblemEmptyMessage HistoryProblem = "empty_message"
+)
+
+// HistoryIssue is one problem found in a history.
+type HistoryIssue struct {
+	Problem HistoryProblem
+	// Index is the position of the message in the validated history.
+	Index int
+	// CallID is the call or result concerned, if any.
+	CallID string
+	// Repair describes what RepairHistory did about it, or "" if it left
+	// the issue alone.
+	Repair string
+}
+
+func (i HistoryIssue) String() string {
+	s := fmt.Sprintf("message %d: %s", i.Index, i.Problem)
+	if i.CallID != "" {
+		s += " (" + i.CallID + ")"
+	}
+	if i.Repair != "" {
+		s += ": " + i.Repair
+	}
+	return s
+}
+
+// HistoryReport lists the issues of a history.
+type HistoryReport struct {
+	Issues []HistoryIssue
+}
+
+// OK reports whether the history had no issues.
+func (r HistoryReport) OK() bool { return len(r.Issues) == 0 }
+
+func (r HistoryReport) String() string {
+	lines := make([]string, len(r.Issues))
+	for i, issue := range r.Issues {
+		lines[i] = issue.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidateHistory reports the corruption in history that providers reject:
+// tool calls without results, results without calls, mismatched or duplicate
+// call IDs and empty assistant messages. Results must follow the assistant
+// message with their calls, before the next user or assistant message.
+func ValidateHistory(history []Message) HistoryReport {
+	_, report := checkHistory(history, false)
+	return report
+}
+
+// RepairHistory returns a copy of history with the issues of ValidateHistory
+// repaired where possible, and the report of what was found and done:
+//
+//   - missing results are added as interrupted error results;
+//   - results with a mismatched CallID take the ID of the unanswered call;
+//   - orphan results and empty assistant messages are dropped.
+//
+// Duplicate call IDs are reported but left alone. The input is not modified.
+func RepairHistory(history []Message) ([]Message, HistoryReport) {
+	return checkHistory(history, true)
+}
+
+func checkHistory(history []Message, repair bool) ([]Message, HistoryReport) {
+	var (
+		out     []Message
+		report  HistoryReport
+		seen    = map[string]bool{}
+		pending []ToolCallPart // calls of the last assistant message
+		parent  string         // ID of the last assistant message
+		open    = map[string]bool{}
+	)
+	add := func(problem HistoryProblem, index int, callID, fix string) {
+		if !repair {
+			fix = ""
+		}
+		report.Issues = append(report.Issues, HistoryIssue{Problem: problem, Index: index, CallID: callID, Repair: fix})
+	}
+	// closeTurn reports the unanswered calls of the last assistant message,
+	// before the message at index.
+	closeTurn := func(index int) {
+		for _, call := range pending {
+			if !open[call.CallID] {
+				continue
+			}
+			add(ProblemMissingResult, index, call.CallID, "added an interrupted result")
+			res := interruptedToolResult(call)
+			out = append(out, ToolResultMessage{
+				ID:        NewMessageID(),
+				ParentID:  parent,
+				CallID:    res.CallID,
+				Name:      res.Name,
+				IsError:   true,
+				Parts:     res.Parts,
+				Timestamp: time.Now().UnixMilli(),
+			})
+		}
+		pending, open = nil, map[string]bool{}
+	}
+
+	for i, msg := range history {
+		switch m := msg.(type) {
+		case *UserMessage:
+			msg = *m
+		case *AssistantMessage:
+			msg = *m
+		case *ToolResultMessage:
+			msg = *m
+		}
+		switch m := msg.(type) {
+		case AssistantMessage:
+			closeTurn(i)
+			if len(m.Parts) == 0 {
+				add(ProblemEmptyMessage, i, "", "dropped the message")
+				continue
+			}
+			parent = m.ID
+			for _, call := range extractToolCalls(m) {
+				if seen[call.CallID] {
+					add(ProblemDuplicateCallID, i, call.CallID, "")
+				}
+				seen[call.CallID] = true
+				pending = append(pending, call)
+				open[call.CallID] = true
+			}
+		case ToolResultMessage:
+			if open[m.CallID] {
+				open[m.CallID] = false
+				break
+			}
+			match := -1
+			for j, call := range pending {
+				if open[call.CallID] && call.Name == m.Name {
+					match = j
+					break
+				}
+			}
+			if match < 0 || seen[m.CallID] {
+				add(ProblemOrphanResult, i, m.CallID, "dropped the result")
+				continue
+			}
+			callID := pending[match].CallID
+			add(ProblemCallIDMismatch, i, m.CallID, "set the call ID to "+callID)
+			open[callID] = false
+			if repair {
+				m.CallID = callID
+				msg = m
+			}
+		default:
+			closeTurn(i)
+		}
+		out = append(out, msg)
+	}
+	closeTurn(len(history))
+	if !repair {
+		return history, report
+	}
+	return out, report
+}
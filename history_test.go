@@ -0,0 +1,84 @@
+package step_test
+
+import (
+	"testing"
+
+	"github.com/inspirepan/step"
+)
+
+func user(text string) step.UserMessage {
+	return step.UserMessage{Parts: []step.Part{step.TextPart{Text: text}}}
+}
+
+func toolCalls(ids ...string) step.AssistantMessage {
+	var parts []step.Part
+	for _, id := range ids {
+		parts = append(parts, step.ToolCallPart{CallID: id, Name: "ls", ArgsJSON: []byte(`{}`)})
+	}
+	return step.AssistantMessage{ID: "a-" + ids[0], Parts: parts}
+}
+
+func result(id string) step.ToolResultMessage {
+	return step.ToolResultMessage{CallID: id, Name: "ls", Parts: []step.Part{step.TextPart{Text: "ok"}}}
+}
+
+func TestValidateHistory(t *testing.T) {
+	valid := []step.Message{user("hi"), toolCalls("a", "b"), result("b"), result("a"), step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "done"}}}}
+	if r := step.ValidateHistory(valid); !r.OK() {
+		t.Errorf("valid history reported:\n%s", r)
+	}
+
+	history := []step.Message{
+		user("hi"),
+		toolCalls("a", "b"),
+		result("a"),
+		result("x"), // mismatched: b is unanswered
+		result("a"), // answered twice
+		step.AssistantMessage{},
+		toolCalls("c", "a"),
+		user("never mind"),
+		result("c"), // after the user message
+	}
+	want := []step.HistoryIssue{
+		{Problem: step.ProblemCallIDMismatch, Index: 3, CallID: "x"},
+		{Problem: step.ProblemOrphanResult, Index: 4, CallID: "a"},
+		{Problem: step.ProblemEmptyMessage, Index: 5},
+		{Problem: step.ProblemDuplicateCallID, Index: 6, CallID: "a"},
+		{Problem: step.ProblemMissingResult, Index: 7, CallID: "c"},
+		{Problem: step.ProblemMissingResult, Index: 7, CallID: "a"},
+		{Problem: step.ProblemOrphanResult, Index: 8, CallID: "c"},
+	}
+	check := func(name string, r step.HistoryReport) {
+		t.Helper()
+		if len(r.Issues) != len(want) {
+			t.Fatalf("%s: got\n%s\nwant %d issues", name, r, len(want))
+		}
+		for i, issue := range r.Issues {
+			if issue.Problem != want[i].Problem || issue.Index != want[i].Index || issue.CallID != want[i].CallID {
+				t.Errorf("%s: issue %d = %v, want %v", name, i, issue, want[i])
+			}
+		}
+	}
+	check("validate", step.ValidateHistory(history))
+
+	repaired, r := step.RepairHistory(history)
+	check("repair", r)
+	if r.Issues[0].Repair != "set the call ID to b" {
+		t.Errorf("repair = %q", r.Issues[0].Repair)
+	}
+	// Only the duplicate call ID is left.
+	if r := step.ValidateHistory(repaired); len(r.Issues) != 1 || r.Issues[0].Problem != step.ProblemDuplicateCallID {
+		t.Errorf("repaired history reported:\n%s", r)
+	}
+	if len(repaired) != 8 {
+		t.Errorf("repaired to %d messages, want 8", len(repaired))
+	}
+	if history[3].(step.ToolResultMessage).CallID != "x" {
+		t.Error("the input was modified")
+	}
+	for _, m := range repaired[5:7] {
+		if r, ok := m.(step.ToolResultMessage); !ok || !r.IsError || r.ParentID != "a-c" {
+			t.Errorf("added %#v, want an interrupted result of a-c", m)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package step_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+type upperTool struct{}
+
+func (upperTool) Spec() step.ToolSpec {
+	return step.ToolSpec{Name: "upper", Parameters: map[string]any{"type": "object"}}
+}
+
+func (upperTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "OK"}}}, nil
+}
+
+func TestStepLifecycleHooks(t *testing.T) {
+	provider := mock.New(mock.ToolCalls(
+		step.ToolCallPart{CallID: "c1", Name: "upper", ArgsJSON: []byte(`{}`)},
+		step.ToolCallPart{CallID: "c2", Name: "upper", ArgsJSON: []byte(`{}`)},
+	))
+	history := []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "go"}}}}
+
+	var order []string
+	var assistantID string
+	var results []step.ToolResultMessage
+	_, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history, Tools: []step.Tool{upperTool{}}},
+		step.WithOnBeforeProviderCall(func(req *step.ProviderRequest) {
+			order = append(order, "before")
+			req.SystemPrompt = "injected"
+			req.History = append(req.History, step.UserMessage{Parts: []step.Part{step.TextPart{Text: "context"}}})
+		}),
+		step.WithOnBeforeProviderCall(func(req *step.ProviderRequest) { order = append(order, "before2") }),
+		step.WithOnAfterAssistant(func(msg step.AssistantMessage) {
+			order = append(order, "assistant")
+			assistantID = msg.ID
+		}),
+		step.WithOnAfterTools(func(r []step.ToolResultMessage) {
+			order = append(order, "tools")
+			results = r
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+
+	want := []string{"before", "before2", "assistant", "tools"}
+	if len(order) != len(want) {
+		t.Fatalf("expected hook order %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected hook order %v, got %v", want, order)
+		}
+	}
+
+	req := provider.Requests()[0]
+	if req.SystemPrompt != "injected" || len(req.History) != 2 {
+		t.Errorf("expected the modified request to reach the provider, got %+v", req)
+	}
+	if len(history) != 1 {
+		t.Errorf("hooks must not modify the caller's history")
+	}
+	if assistantID == "" {
+		t.Error("expected the assistant message to have an ID")
+	}
+	if len(results) != 2 || results[0].CallID != "c1" || results[1].CallID != "c2" || results[0].ParentID != assistantID {
+		t.Errorf("unexpected tool results: %+v", results)
+	}
+}
+
+// rawProvider emits a raw chunk before each update, but only when asked to.
+type rawProvider struct{ requests []step.ProviderRequest }
+
+func (p *rawProvider) Stream(_ context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	p.requests = append(p.requests, req)
+	var ups []step.ProviderUpdate
+	if req.Raw {
+		ups = append(ups, step.ProviderRawUpdate{Provider: "raw", Data: []byte(`{"text":"hi","x_extra":1}`)})
+	}
+	ups = append(ups,
+		step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: "hi"}},
+		step.ProviderMessageUpdate{Message: step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}, StopReason: step.StopStop}},
+	)
+	return &updateStream{ups: ups}, nil
+}
+
+func TestStep_OnRawChunk(t *testing.T) {
+	provider := &rawProvider{}
+	req := step.StepRequest{Provider: provider}
+	if _, err := step.Step(context.Background(), req); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+
+	var raw []string
+	result, err := step.Step(context.Background(), req, step.WithOnRawChunk(func(u step.ProviderRawUpdate) {
+		raw = append(raw, u.Provider+" "+string(u.Data))
+	}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if provider.requests[0].Raw || !provider.requests[1].Raw {
+		t.Errorf("expected raw chunks to be requested only with the hook, got %v then %v", provider.requests[0].Raw, provider.requests[1].Raw)
+	}
+	if len(raw) != 1 || raw[0] != `raw {"text":"hi","x_extra":1}` {
+		t.Errorf("unexpected raw chunks %q", raw)
+	}
+	if len(result) != 1 || result[0].(step.AssistantMessage).Text() != "hi" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestStep_WithModel(t *testing.T) {
+	provider := mock.New(mock.Text("a"), mock.Text("b"))
+	req := step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+	}
+	if _, err := step.Step(context.Background(), req, step.WithModel("small"), step.WithReasoningEffort(step.ReasoningEffortHigh)); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if _, err := step.Step(context.Background(), req); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	reqs := provider.Requests()
+	if reqs[0].ModelOverride != "small" || reqs[1].ModelOverride != "" {
+		t.Errorf("expected the override on the first request only, got %q and %q", reqs[0].ModelOverride, reqs[1].ModelOverride)
+	}
+	if reqs[0].ReasoningEffort != step.ReasoningEffortHigh || reqs[1].ReasoningEffort != "" {
+		t.Errorf("expected the effort on the first request only, got %q and %q", reqs[0].ReasoningEffort, reqs[1].ReasoningEffort)
+	}
+}
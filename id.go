@@ -0,0 +1,114 @@
+package step
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewMessageID returns a new random message ID.
+func NewMessageID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "msg_" + hex.EncodeToString(b[:])
+}
+
+// NewRunID returns a new random run ID.
+func NewRunID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "run_" + hex.EncodeToString(b[:])
+}
+
+// NewIdempotencyKey returns a new random (version 4) UUID for use as an
+// idempotency key; see IdempotencyKey.
+func NewIdempotencyKey() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// MessageID returns the ID of m, or "" if it has none.
+func MessageID(m Message) string {
+	switch v := m.(type) {
+	case UserMessage:
+		return v.ID
+	case *UserMessage:
+		return v.ID
+	case AssistantMessage:
+		return v.ID
+	case *AssistantMessage:
+		return v.ID
+	case ToolResultMessage:
+		return v.ID
+	case *ToolResultMessage:
+		return v.ID
+	default:
+		return ""
+	}
+}
+
+// AssignIDs returns a copy of history where every message without an ID gets a new one,
+// and every message without a ParentID is linked to the previous message.
+// Tool results are linked to the assistant message that issued their call when it is present.
+func AssignIDs(history []Message) []Message {
+	out := make([]Message, len(history))
+	prevID := ""
+	callOwner := map[string]string{}
+	for i, msg := range history {
+		switch m := msg.(type) {
+		case UserMessage:
+			fillIDs(&m.ID, &m.ParentID, prevID)
+			out[i], prevID = m, m.ID
+		case *UserMessage:
+			c := *m
+			fillIDs(&c.ID, &c.ParentID, prevID)
+			out[i], prevID = &c, c.ID
+		case AssistantMessage:
+			fillIDs(&m.ID, &m.ParentID, prevID)
+			recordCalls(callOwner, m)
+			out[i], prevID = m, m.ID
+		case *AssistantMessage:
+			c := *m
+			fillIDs(&c.ID, &c.ParentID, prevID)
+			recordCalls(callOwner, c)
+			out[i], prevID = &c, c.ID
+		case ToolResultMessage:
+			fillIDs(&m.ID, &m.ParentID, toolParent(callOwner, m.CallID, prevID))
+			out[i], prevID = m, m.ID
+		case *ToolResultMessage:
+			c := *m
+			fillIDs(&c.ID, &c.ParentID, toolParent(callOwner, c.CallID, prevID))
+			out[i], prevID = &c, c.ID
+		default:
+			out[i] = msg
+		}
+	}
+	return out
+}
+
+func fillIDs(id, parentID *string, parent string) {
+	if *id == "" {
+		*id = NewMessageID()
+	}
+	if *parentID == "" {
+		*parentID = parent
+	}
+}
+
+func recordCalls(owner map[string]string, m AssistantMessage) {
+	for _, part := range m.Parts {
+		if tc, ok := part.(ToolCallPart); ok {
+			owner[tc.CallID] = m.ID
+		}
+	}
+}
+
+func toolParent(owner map[string]string, callID, fallback string) string {
+	if id, ok := owner[callID]; ok {
+		return id
+	}
+	return fallback
+}
@@ -0,0 +1,166 @@
+// Package diff computes line-based unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines around each change.
+const context = 3
+
+// maxCells bounds the LCS table; larger inputs are diffed as a whole
+// replacement of the differing middle.
+const maxCells = 16 << 20
+
+type op struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns the unified diff turning a into b, with oldName and newName
+// in the header, or "" if they are equal.
+func Unified(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	ops := edits(lines(a), lines(b))
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, o := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if o.kind != '+' {
+			oldPos[i+1]++
+		}
+		if o.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start, end := max(0, i-context), i
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			k := end
+			for k < len(ops) && ops[k].kind == ' ' {
+				k++
+			}
+			if k == len(ops) || k-end > 2*context {
+				break
+			}
+			end = k
+		}
+		stop := min(len(ops), end+context)
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", span(oldPos[start], oldPos[stop]-oldPos[start]), span(newPos[start], newPos[stop]-newPos[start]))
+		for _, o := range ops[start:stop] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+	return sb.String()
+}
+
+// span formats a hunk range; start is zero-based.
+func span(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	out := strings.SplitAfter(s, "\n")
+	if out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// edits returns an edit script from a to b, trimming the common prefix and
+// suffix before running LCS on the rest.
+func edits(a, b []string) []op {
+	p := 0
+	for p < len(a) && p < len(b) && a[p] == b[p] {
+		p++
+	}
+	s := 0
+	for s < len(a)-p && s < len(b)-p && a[len(a)-1-s] == b[len(b)-1-s] {
+		s++
+	}
+	var ops []op
+	for _, l := range a[:p] {
+		ops = append(ops, op{' ', l})
+	}
+	ops = append(ops, lcs(a[p:len(a)-s], b[p:len(b)-s])...)
+	for _, l := range a[len(a)-s:] {
+		ops = append(ops, op{' ', l})
+	}
+	return ops
+}
+
+func lcs(a, b []string) []op {
+	n, m := len(a), len(b)
+	var ops []op
+	if n*m > maxCells {
+		for _, l := range a {
+			ops = append(ops, op{'-', l})
+		}
+		for _, l := range b {
+			ops = append(ops, op{'+', l})
+		}
+		return ops
+	}
+	w := m + 1
+	dp := make([]int32, (n+1)*w)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i*w+j] = dp[(i+1)*w+j+1] + 1
+			} else {
+				dp[i*w+j] = max(dp[(i+1)*w+j], dp[i*w+j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case dp[(i+1)*w+j] >= dp[i*w+j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
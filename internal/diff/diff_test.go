@@ -0,0 +1,38 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step/internal/diff"
+)
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\nTWO\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+	want := `--- a/f
++++ b/f
+@@ -1,5 +1,5 @@
+ one
+-two
++TWO
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+\ No newline at end of file
+`
+	if got := diff.Unified("a/f", "b/f", a, b); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := diff.Unified("a", "b", a, a); got != "" {
+		t.Errorf("expected no diff for equal inputs, got %q", got)
+	}
+	if got := diff.Unified("/dev/null", "b/new", "", "x\n"); !strings.Contains(got, "@@ -0,0 +1 @@\n+x\n") {
+		t.Errorf("unexpected diff for a new file:\n%s", got)
+	}
+}
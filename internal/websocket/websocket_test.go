@@ -0,0 +1,69 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step/internal/websocket"
+)
+
+func TestRoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(append([]byte("echo: "), msg...)); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	conn, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cover the one-byte, two-byte and eight-byte length encodings.
+	for _, msg := range []string{"hi", strings.Repeat("a", 300), strings.Repeat("b", 70000)} {
+		if err := conn.WriteMessage([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "echo: "+msg {
+			t.Errorf("got %d bytes, want the %d byte message echoed", len(got), len(msg))
+		}
+	}
+	conn.Close()
+}
+
+func TestServerClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, err := websocket.Accept(w, r); err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	conn, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ReadMessage(); !errors.Is(err, websocket.ErrClosed) {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
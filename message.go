@@ -3,6 +3,7 @@ package step
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // Role is the speaker role.
@@ -14,6 +15,12 @@ const (
 	RoleTool      Role = "tool"
 )
 
+// MessageVersion is the version of the JSON encoding of messages and parts,
+// written in their "version" field. UnmarshalMessage and UnmarshalPart read
+// any version: those written before versioning count as version 0, and parts
+// of types unknown to this version, e.g. from a newer one, become OpaquePart.
+const MessageVersion = 1
+
 // Message is the canonical conversation unit.
 type Message interface {
 	role() Role
@@ -21,8 +28,11 @@ type Message interface {
 
 // UserMessage represents a user input message.
 type UserMessage struct {
-	Parts     []Part `json:"parts,omitempty"`
-	Timestamp int64  `json:"timestamp"`
+	ID        string   `json:"id,omitempty"`
+	ParentID  string   `json:"parent_id,omitempty"`
+	Parts     []Part   `json:"parts,omitempty"`
+	Timestamp int64    `json:"timestamp"`
+	Metadata  Metadata `json:"metadata,omitempty"`
 }
 
 func (UserMessage) role() Role { return RoleUser }
@@ -30,37 +40,101 @@ func (UserMessage) role() Role { return RoleUser }
 func (m UserMessage) MarshalJSON() ([]byte, error) {
 	type alias UserMessage
 	return json.Marshal(struct {
-		Role Role `json:"role"`
+		Role    Role `json:"role"`
+		Version int  `json:"version"`
 		alias
-	}{RoleUser, alias(m)})
+	}{RoleUser, MessageVersion, alias(m)})
 }
 
 // AssistantMessage represents an assistant response message.
 type AssistantMessage struct {
-	Parts      []Part     `json:"parts,omitempty"`
-	Timestamp  int64      `json:"timestamp"`
-	Usage      *Usage     `json:"usage,omitempty"`
-	StopReason StopReason `json:"stop_reason,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	ParentID   string      `json:"parent_id,omitempty"`
+	Parts      []Part      `json:"parts,omitempty"`
+	Timestamp  int64       `json:"timestamp"`
+	Usage      *Usage      `json:"usage,omitempty"`
+	StopReason StopReason  `json:"stop_reason,omitempty"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+	Stats      *Stats      `json:"stats,omitempty"`
+	Metadata   Metadata    `json:"metadata,omitempty"`
 }
 
 func (AssistantMessage) role() Role { return RoleAssistant }
 
+// Text returns the concatenated text parts of m.
+func (m AssistantMessage) Text() string {
+	var sb strings.Builder
+	for _, part := range m.Parts {
+		switch p := part.(type) {
+		case TextPart:
+			sb.WriteString(p.Text)
+		case *TextPart:
+			sb.WriteString(p.Text)
+		}
+	}
+	return sb.String()
+}
+
 func (m AssistantMessage) MarshalJSON() ([]byte, error) {
 	type alias AssistantMessage
 	return json.Marshal(struct {
-		Role Role `json:"role"`
+		Role    Role `json:"role"`
+		Version int  `json:"version"`
 		alias
-	}{RoleAssistant, alias(m)})
+	}{RoleAssistant, MessageVersion, alias(m)})
+}
+
+// Provenance records which provider and model produced an assistant message.
+type Provenance struct {
+	// Provider is the provider implementation name, e.g. "openrouter".
+	Provider string `json:"provider,omitempty"`
+	// Model is the model ID the request was sent to.
+	Model string `json:"model,omitempty"`
+	// ServedModel is the model the provider reports as having served the request.
+	// It differs from Model when a fallback was used or the ID was resolved to a snapshot.
+	ServedModel string `json:"served_model,omitempty"`
+	// Upstream is the provider that served the request when Provider is a
+	// router, e.g. "Anthropic" or "Together" behind OpenRouter.
+	Upstream string `json:"upstream,omitempty"`
+	// RequestID is the provider-assigned response or generation ID, if any.
+	RequestID string `json:"request_id,omitempty"`
+	// LatencyMs is the wall time from sending the request to the final message.
+	LatencyMs int64 `json:"latency_ms,omitempty"`
+	// FinishReason is the raw finish reason reported by the provider before mapping to StopReason.
+	FinishReason string `json:"finish_reason,omitempty"`
+	// NativeFinishReason is the upstream model's own finish reason when the provider
+	// is a router that normalizes FinishReason (e.g. OpenRouter).
+	NativeFinishReason string `json:"native_finish_reason,omitempty"`
+	// RequestBytes is the size of the serialized request body, before any compression.
+	RequestBytes int64 `json:"request_bytes,omitempty"`
+	// ResponseBytes is the size of the response body read for this message.
+	ResponseBytes int64 `json:"response_bytes,omitempty"`
+}
+
+// Stats records the streaming timing of an assistant message, measured by Step
+// from sending the request to receiving the final message.
+type Stats struct {
+	// TimeToFirstTokenMs is the time until the first text, thinking or tool call
+	// delta. It is zero if the provider streamed no such delta.
+	TimeToFirstTokenMs int64 `json:"ttft_ms,omitempty"`
+	// DurationMs is the total time until the final message.
+	DurationMs int64 `json:"duration_ms"`
+	// OutputTokensPerSecond is Usage.OutputTokens over the time from the first
+	// token to the final message. It is zero without usage or streamed tokens.
+	OutputTokensPerSecond float64 `json:"output_tokens_per_second,omitempty"`
 }
 
 // ToolResultMessage represents a tool execution result message.
 type ToolResultMessage struct {
+	ID        string         `json:"id,omitempty"`
+	ParentID  string         `json:"parent_id,omitempty"`
 	CallID    string         `json:"call_id"`
 	Name      string         `json:"name"`
 	IsError   bool           `json:"is_error,omitempty"`
 	Parts     []Part         `json:"parts,omitempty"`
 	Timestamp int64          `json:"timestamp"`
 	Details   map[string]any `json:"details,omitempty"`
+	Metadata  Metadata       `json:"metadata,omitempty"`
 }
 
 func (ToolResultMessage) role() Role { return RoleTool }
@@ -68,9 +142,10 @@ func (ToolResultMessage) role() Role { return RoleTool }
 func (m ToolResultMessage) MarshalJSON() ([]byte, error) {
 	type alias ToolResultMessage
 	return json.Marshal(struct {
-		Role Role `json:"role"`
+		Role    Role `json:"role"`
+		Version int  `json:"version"`
 		alias
-	}{RoleTool, alias(m)})
+	}{RoleTool, MessageVersion, alias(m)})
 }
 
 // ToolMessage is kept for backward compatibility.
@@ -85,6 +160,9 @@ const (
 	StopToolUse StopReason = "tool_use"
 	StopError   StopReason = "error"
 	StopAborted StopReason = "aborted"
+	// StopContentFilter means the provider withheld or cut off output for
+	// safety or policy reasons, e.g. a refusal or a content filter match.
+	StopContentFilter StopReason = "content_filter"
 )
 
 // Usage reports token accounting.
@@ -92,7 +170,27 @@ type Usage struct {
 	InputTokens      int `json:"input_tokens"`
 	OutputTokens     int `json:"output_tokens"`
 	CachedReadTokens int `json:"cached_read_tokens"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
 	TotalTokens      int `json:"total_tokens"`
+	// Cost is the provider-reported cost in USD, if available (e.g. OpenRouter usage accounting).
+	Cost float64 `json:"cost,omitempty"`
+	// SavedInputTokens estimates the input tokens WithEfficientTools saved
+	// by compacting the tool schemas. They are not part of InputTokens.
+	SavedInputTokens int `json:"saved_input_tokens,omitempty"`
+}
+
+// Add accumulates other into u. A nil other is ignored.
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CachedReadTokens += other.CachedReadTokens
+	u.CacheWriteTokens += other.CacheWriteTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+	u.SavedInputTokens += other.SavedInputTokens
 }
 
 func (m *UserMessage) UnmarshalJSON(data []byte) error {
@@ -149,7 +247,8 @@ func (m *ToolResultMessage) UnmarshalJSON(data []byte) error {
 // UnmarshalMessage decodes a JSON object into a concrete Message type.
 func UnmarshalMessage(data []byte) (Message, error) {
 	var raw struct {
-		Role Role `json:"role"`
+		Role    Role `json:"role"`
+		Version int  `json:"version"`
 	}
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return nil, err
@@ -175,6 +274,9 @@ func UnmarshalMessage(data []byte) (Message, error) {
 		}
 		return m, nil
 	default:
+		if raw.Version > MessageVersion {
+			return nil, fmt.Errorf("message version %d is newer than %d: unknown role: %s", raw.Version, MessageVersion, raw.Role)
+		}
 		return nil, fmt.Errorf("unknown role: %s", raw.Role)
 	}
 }
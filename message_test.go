@@ -0,0 +1,193 @@
+package step_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	msg := step.AssistantMessage{
+		Parts: []step.Part{
+			step.TextPart{Text: "hi", Metadata: step.Metadata{"source": "cache"}},
+			step.ToolCallPart{CallID: "c1", Name: "ls", ArgsJSON: json.RawMessage(`{}`), Metadata: step.Metadata{"approved": true}},
+		},
+		StopReason: step.StopToolUse,
+	}
+	msg.Metadata.Set("turn", 3)
+	msg.Metadata.Set("ui", map[string]any{"collapsed": true})
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := step.UnmarshalMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := decoded.(step.AssistantMessage)
+	if !ok {
+		t.Fatalf("expected AssistantMessage, got %T", decoded)
+	}
+
+	if n, ok := got.Metadata.Int("turn"); !ok || n != 3 {
+		t.Errorf("turn = %v, %v", n, ok)
+	}
+	type ui struct {
+		Collapsed bool `json:"collapsed"`
+	}
+	if v, ok := step.MetadataValue[ui](got.Metadata, "ui"); !ok || !v.Collapsed {
+		t.Errorf("ui = %+v, %v", v, ok)
+	}
+	if s, ok := got.Parts[0].(step.TextPart).Metadata.String("source"); !ok || s != "cache" {
+		t.Errorf("part source = %q, %v", s, ok)
+	}
+	if b, ok := got.Parts[1].(step.ToolCallPart).Metadata.Bool("approved"); !ok || !b {
+		t.Errorf("part approved = %v, %v", b, ok)
+	}
+}
+
+func TestAssignIDs(t *testing.T) {
+	history := step.AssignIDs([]step.Message{
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}},
+		step.AssistantMessage{Parts: []step.Part{
+			step.ToolCallPart{CallID: "a", Name: "x"},
+			step.ToolCallPart{CallID: "b", Name: "x"},
+		}},
+		step.ToolResultMessage{CallID: "a"},
+		step.ToolResultMessage{CallID: "b"},
+	})
+	user := history[0].(step.UserMessage)
+	assistant := history[1].(step.AssistantMessage)
+	if user.ID == "" || user.ParentID != "" {
+		t.Errorf("user ids = %q, %q", user.ID, user.ParentID)
+	}
+	if assistant.ParentID != user.ID {
+		t.Errorf("assistant parent = %q, want %q", assistant.ParentID, user.ID)
+	}
+	for _, msg := range history[2:] {
+		tr := msg.(step.ToolResultMessage)
+		if tr.ID == "" || tr.ParentID != assistant.ID {
+			t.Errorf("tool result %s ids = %q, %q", tr.CallID, tr.ID, tr.ParentID)
+		}
+	}
+}
+
+func TestUnmarshalMessage_UnknownParts(t *testing.T) {
+	// A message from a newer version with a part type this one lacks.
+	data := `{"role":"assistant","version":7,"parts":[{"type":"text","text":"hi"},{"type":"hologram","frames":[1,2],"fps":24}],"timestamp":1}`
+	msg, err := step.UnmarshalMessage([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := msg.(step.AssistantMessage).Parts
+	opaque, ok := parts[1].(step.OpaquePart)
+	if !ok || opaque.Type != "hologram" {
+		t.Fatalf("parts = %#v, want an opaque hologram part", parts)
+	}
+	out, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `{"type":"hologram","frames":[1,2],"fps":24}`) || !strings.Contains(string(out), `"version":1`) {
+		t.Errorf("re-marshaled to %s", out)
+	}
+
+	if _, err := step.UnmarshalMessage([]byte(`{"role":"system","version":7}`)); err == nil || !strings.HasPrefix(err.Error(), "message version 7") {
+		t.Errorf("got %v, want an error naming the version first", err)
+	}
+	if out, _ := json.Marshal(step.TextPart{Text: "hi"}); string(out) != `{"type":"text","version":1,"text":"hi"}` {
+		t.Errorf("part marshaled to %s", out)
+	}
+	if _, err := step.UnmarshalPart([]byte(`null`)); err == nil {
+		t.Error("decoded null as a part")
+	}
+}
+
+func FuzzUnmarshalMessage(f *testing.F) {
+	for _, m := range []step.Message{
+		step.UserMessage{ID: "u", Parts: []step.Part{step.TextPart{Text: "hi"}, step.ImagePart{MimeType: "image/png", DataB64: "AAAA"}}},
+		step.AssistantMessage{Parts: []step.Part{step.ThinkingPart{Thinking: "hm"}, step.ToolCallPart{CallID: "c", Name: "ls", ArgsJSON: json.RawMessage(`{"a":1}`)}}, Usage: &step.Usage{InputTokens: 3}},
+		step.ToolResultMessage{CallID: "c", Name: "ls", Parts: []step.Part{step.TextPart{Text: "x"}}, Details: map[string]any{"n": 1}},
+	} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte(`{"role":"user","parts":[{"type":"future","x":{}}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := step.UnmarshalMessage(data)
+		if err != nil {
+			return
+		}
+		// Whatever decodes survives a round trip unchanged.
+		first, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("marshal %#v: %v", msg, err)
+		}
+		again, err := step.UnmarshalMessage(first)
+		if err != nil {
+			t.Fatalf("decode %s: %v", first, err)
+		}
+		second, err := json.Marshal(again)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, second) {
+			t.Errorf("round trip changed\n%s\nto\n%s", first, second)
+		}
+	})
+}
+
+func FuzzUnmarshalPart(f *testing.F) {
+	f.Add([]byte(`{"type":"text","text":"hi","metadata":{"k":1}}`))
+	f.Add([]byte(`{"type":"tool_call","call_id":"c","name":"ls","args_json":{}}`))
+	f.Add([]byte(`{"type":"citation","url":"https://example.com"}`))
+	f.Add([]byte(`{"type":"future","x":[1]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		part, err := step.UnmarshalPart(data)
+		if err != nil {
+			return
+		}
+		first, err := json.Marshal(part)
+		if err != nil {
+			t.Fatalf("marshal %#v: %v", part, err)
+		}
+		again, err := step.UnmarshalPart(first)
+		if err != nil {
+			t.Fatalf("decode %s: %v", first, err)
+		}
+		if second, _ := json.Marshal(again); !bytes.Equal(first, second) {
+			t.Errorf("round trip changed\n%s\nto\n%s", first, second)
+		}
+	})
+}
+
+func TestStep_OpaquePartsSkipped(t *testing.T) {
+	future := step.OpaquePart{Type: "hologram", Raw: json.RawMessage(`{"type":"hologram"}`)}
+	history := []step.Message{
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "look"}, future}},
+		step.AssistantMessage{Parts: []step.Part{future}},
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "well?"}}},
+	}
+	provider := mock.New(mock.Text("ok"))
+	if _, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history}); err != nil {
+		t.Fatal(err)
+	}
+	sent := provider.Requests()[0].History
+	if len(sent) != 2 || len(sent[0].(step.UserMessage).Parts) != 1 {
+		t.Errorf("sent %#v, want the history without opaque parts", sent)
+	}
+	if len(history[0].(step.UserMessage).Parts) != 2 {
+		t.Error("the caller's history was modified")
+	}
+}
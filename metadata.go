@@ -0,0 +1,112 @@
+package step
+
+import (
+	"encoding/json"
+	"maps"
+)
+
+// Metadata carries application-defined data (IDs, provenance, UI hints) on messages and parts.
+// It round-trips through JSON but is never sent to providers.
+//
+// Values decoded from JSON follow encoding/json rules (numbers become float64,
+// objects become map[string]any); the typed accessors and MetadataValue handle this.
+type Metadata map[string]any
+
+// Get returns the raw value for key.
+func (m Metadata) Get(key string) (any, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+// Set stores value under key, allocating the map if needed.
+func (m *Metadata) Set(key string, value any) {
+	if *m == nil {
+		*m = Metadata{}
+	}
+	(*m)[key] = value
+}
+
+// Delete removes key.
+func (m Metadata) Delete(key string) {
+	delete(m, key)
+}
+
+// String returns the value for key if it is a string.
+func (m Metadata) String(key string) (string, bool) {
+	v, ok := m[key].(string)
+	return v, ok
+}
+
+// Bool returns the value for key if it is a bool.
+func (m Metadata) Bool(key string) (bool, bool) {
+	v, ok := m[key].(bool)
+	return v, ok
+}
+
+// Int returns the value for key if it is an integer or a float64 holding an integer.
+func (m Metadata) Int(key string) (int64, bool) {
+	switch v := m[key].(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v), true
+		}
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Float returns the value for key if it is numeric.
+func (m Metadata) Float(key string) (float64, bool) {
+	switch v := m[key].(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Clone returns a shallow copy of m.
+func (m Metadata) Clone() Metadata {
+	if m == nil {
+		return nil
+	}
+	return maps.Clone(m)
+}
+
+// MetadataValue decodes the value for key into T.
+// It works both for values stored directly as T and for values that went through JSON.
+func MetadataValue[T any](m Metadata, key string) (T, bool) {
+	var zero T
+	v, ok := m[key]
+	if !ok {
+		return zero, false
+	}
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return zero, false
+	}
+	var out T
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return zero, false
+	}
+	return out, true
+}
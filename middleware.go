@@ -0,0 +1,45 @@
+package step
+
+import "context"
+
+// StepFunc runs one step. It is the unit that Middleware wraps.
+type StepFunc func(ctx context.Context, req StepRequest) (StepResult, error)
+
+// Middleware wraps a StepFunc to add behavior around a step, such as retries,
+// tracing, guardrails, budget enforcement or caching. It may modify the request,
+// inspect or replace the result, call next several times, or not at all.
+//
+// Callbacks and hooks configured on the step run inside next, so a middleware
+// that calls next twice (e.g. a retry) produces two sets of deltas and messages.
+type Middleware func(next StepFunc) StepFunc
+
+// Chain composes middlewares into one. The first middleware is the outermost:
+// Chain(a, b)(h) is a(b(h)).
+func Chain(mws ...Middleware) Middleware {
+	return func(next StepFunc) StepFunc {
+		for i := len(mws) - 1; i >= 0; i-- {
+			if mws[i] != nil {
+				next = mws[i](next)
+			}
+		}
+		return next
+	}
+}
+
+// WithMiddleware adds middlewares around the step. Middlewares from several
+// WithMiddleware options are chained in the order the options are given.
+func WithMiddleware(mws ...Middleware) StepOption {
+	return func(c *stepConfig) { c.middleware = append(c.middleware, mws...) }
+}
+
+// stepFunc returns runStep bound to cfg and wrapped in the configured
+// middlewares, with a new idempotency key for the step.
+func (cfg stepConfig) stepFunc() StepFunc {
+	run := func(ctx context.Context, req StepRequest) (StepResult, error) {
+		return runStep(ctx, req, cfg)
+	}
+	next := Chain(cfg.middleware...)(run)
+	return func(ctx context.Context, req StepRequest) (StepResult, error) {
+		return next(WithIdempotencyKey(ctx, NewIdempotencyKey()), req)
+	}
+}
@@ -0,0 +1,86 @@
+package step_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	var order []string
+	trace := func(name string) step.Middleware {
+		return func(next step.StepFunc) step.StepFunc {
+			return func(ctx context.Context, req step.StepRequest) (step.StepResult, error) {
+				order = append(order, name+">")
+				res, err := next(ctx, req)
+				order = append(order, "<"+name)
+				return res, err
+			}
+		}
+	}
+	provider := mock.New(mock.Text("hi"))
+	req := step.StepRequest{Provider: provider, History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hello"}}}}}
+
+	_, err := step.Step(context.Background(), req,
+		step.WithMiddleware(step.Chain(trace("a"), trace("b"))),
+		step.WithMiddleware(trace("c")),
+	)
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	want := []string{"a>", "b>", "c>", "<c", "<b", "<a"}
+	if len(order) != len(want) {
+		t.Fatalf("expected %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, order)
+		}
+	}
+}
+
+func TestMiddlewareRetry(t *testing.T) {
+	errTransient := errors.New("transient")
+	provider := mock.New(mock.Response{Err: errTransient}, mock.Text("ok"))
+	retry := func(next step.StepFunc) step.StepFunc {
+		return func(ctx context.Context, req step.StepRequest) (step.StepResult, error) {
+			res, err := next(ctx, req)
+			if errors.Is(err, errTransient) {
+				return next(ctx, req)
+			}
+			return res, err
+		}
+	}
+
+	s := step.StepStream(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hello"}}}},
+	}, step.WithMiddleware(retry))
+	result, err := s.Wait()
+	if err != nil || len(result) != 1 {
+		t.Fatalf("expected the retry to succeed, got %v %v", result, err)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	var keys []string
+	record := func(next step.StepFunc) step.StepFunc {
+		return func(ctx context.Context, req step.StepRequest) (step.StepResult, error) {
+			keys = append(keys, step.IdempotencyKey(ctx))
+			return next(ctx, req)
+		}
+	}
+	provider := mock.New(mock.Text("a"), mock.Text("b"))
+	req := step.StepRequest{Provider: provider}
+	for range 2 {
+		if _, err := step.Step(context.Background(), req, step.WithMiddleware(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(keys[0]) != 36 || keys[0] == keys[1] {
+		t.Errorf("keys = %q, want a new UUID per step", keys)
+	}
+}
@@ -0,0 +1,51 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrListModelsUnsupported is returned by ListModels when the provider cannot list its models.
+var ErrListModelsUnsupported = errors.New("step: provider does not support listing models")
+
+// ModelInfo describes a model offered by a provider. Fields other than ID
+// are zero when the provider does not report them.
+type ModelInfo struct {
+	ID string `json:"id"`
+	// Name is a human-readable name.
+	Name            string    `json:"name,omitempty"`
+	Created         time.Time `json:"created,omitzero"`
+	ContextWindow   int       `json:"context_window,omitempty"`
+	MaxOutputTokens int       `json:"max_output_tokens,omitempty"`
+}
+
+// ModelLister is implemented by providers that can list the models available
+// to the configured credentials.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// ListModels lists the models available from provider, for model pickers and
+// for validating configured model IDs at startup (see FindModel).
+// It returns ErrListModelsUnsupported if the provider does not implement ModelLister.
+func ListModels(ctx context.Context, provider Provider) ([]ModelInfo, error) {
+	if provider == nil {
+		return nil, ErrNoProvider
+	}
+	lister, ok := provider.(ModelLister)
+	if !ok {
+		return nil, ErrListModelsUnsupported
+	}
+	return lister.ListModels(ctx)
+}
+
+// FindModel returns the model with the given ID.
+func FindModel(models []ModelInfo, id string) (ModelInfo, bool) {
+	for _, m := range models {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return ModelInfo{}, false
+}
@@ -0,0 +1,27 @@
+package step_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestListModels_Unsupported(t *testing.T) {
+	_, err := step.ListModels(context.Background(), mock.New(mock.Text("hi")))
+	if !errors.Is(err, step.ErrListModelsUnsupported) {
+		t.Errorf("expected ErrListModelsUnsupported, got %v", err)
+	}
+}
+
+func TestFindModel(t *testing.T) {
+	models := []step.ModelInfo{{ID: "a"}, {ID: "b", Name: "Model B"}}
+	if m, ok := step.FindModel(models, "b"); !ok || m.Name != "Model B" {
+		t.Errorf("expected model b, got %+v", m)
+	}
+	if _, ok := step.FindModel(models, "c"); ok {
+		t.Error("expected no model c")
+	}
+}
@@ -0,0 +1,275 @@
+package orchestrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/inspirepan/step"
+)
+
+// DetailsHandoff is the ToolResult.Details key holding the HandoffResult of a transfer tool.
+const DetailsHandoff = "handoff"
+
+// HandoffResult records a transfer of the conversation between agents.
+type HandoffResult struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// HandoffFromDetails returns the HandoffResult stored under DetailsHandoff, or
+// nil. It accepts both the original value and the form decoded from JSON.
+func HandoffFromDetails(details map[string]any) *HandoffResult {
+	switch v := details[DetailsHandoff].(type) {
+	case nil:
+		return nil
+	case HandoffResult:
+		return &v
+	case *HandoffResult:
+		return v
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var h HandoffResult
+		if err := json.Unmarshal(raw, &h); err != nil || h.To == "" {
+			return nil
+		}
+		return &h
+	}
+}
+
+// HistoryFilter selects the part of the conversation an agent receives on handoff.
+type HistoryFilter func([]step.Message) []step.Message
+
+// Handoff lets an agent transfer the conversation to Agent.
+type Handoff struct {
+	Agent step.Agent
+	// Description tells the model when to transfer. Defaults to the agent's Description.
+	Description string
+	// Filter selects the history the receiving agent sees. Nil passes it all.
+	Filter HistoryFilter
+}
+
+func (h Handoff) toolName() string { return "transfer_to_" + h.Agent.Name }
+
+// tool returns the transfer tool offered to the agent named from.
+func (h Handoff) tool(from string) step.Tool {
+	desc := h.Description
+	if desc == "" {
+		desc = h.Agent.Description
+	}
+	return handoffTool{from: from, to: h.Agent.Name, spec: step.ToolSpec{
+		Name:        h.toolName(),
+		Description: "Transfer the conversation to the " + h.Agent.Name + " agent. " + desc,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"reason": map[string]any{"type": "string", "description": "Why the conversation is transferred"},
+			},
+		},
+	}}
+}
+
+type handoffTool struct {
+	from, to string
+	spec     step.ToolSpec
+}
+
+func (t handoffTool) Spec() step.ToolSpec { return t.spec }
+
+func (t handoffTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.Unmarshal(call.ArgsJSON, &args)
+	return step.ToolResult{
+		Parts:   []step.Part{step.TextPart{Text: "Transferred to " + t.to + "."}},
+		Details: map[string]any{DetailsHandoff: HandoffResult{From: t.from, To: t.to, Reason: args.Reason}},
+	}, nil
+}
+
+// Swarm runs agents that transfer the conversation to each other, in the style
+// of swarm frameworks. The active agent runs until it answers without tool
+// calls or calls a transfer tool, at which point the target continues with the
+// (filtered) conversation. Transfer calls are removed from the history the
+// target sees, since it does not have those tools.
+type Swarm struct {
+	// Handoffs maps an agent name to the transfers it may make.
+	Handoffs map[string][]Handoff
+	// MaxHandoffs bounds the number of transfers in one Run. Defaults to 5.
+	MaxHandoffs int
+}
+
+// SwarmResult is the outcome of Swarm.Run.
+type SwarmResult struct {
+	Result
+	// Messages is the full conversation produced, including transfer calls.
+	Messages []step.Message
+	// Handoffs lists the transfers in order.
+	Handoffs []HandoffResult
+	// Active is the agent that produced the final answer.
+	Active string
+}
+
+// Run starts the conversation with agent. On error the result holds
+// everything produced so far.
+func (s Swarm) Run(ctx context.Context, agent step.Agent, history []step.Message, opts ...step.StepOption) (SwarmResult, error) {
+	maxHandoffs := s.MaxHandoffs
+	if maxHandoffs <= 0 {
+		maxHandoffs = 5
+	}
+	var res SwarmResult
+	history = append([]step.Message(nil), history...)
+
+	for {
+		res.Active = agent.Name
+		handoffs := s.Handoffs[agent.Name]
+		tools := append([]step.Tool(nil), agent.Tools...)
+		for _, h := range handoffs {
+			tools = append(tools, h.tool(agent.Name))
+		}
+
+		run, transfer, err := runUntilHandoff(ctx, agent, tools, history, opts)
+		res.add(agent.Name, run)
+		res.Messages = append(res.Messages, run.Messages...)
+		history = append(history, run.Messages...)
+		if err != nil {
+			return res, err
+		}
+		if transfer == nil {
+			res.Text = run.Text()
+			return res, nil
+		}
+
+		res.Handoffs = append(res.Handoffs, *transfer)
+		if len(res.Handoffs) > maxHandoffs {
+			return res, fmt.Errorf("orchestrate: more than %d handoffs", maxHandoffs)
+		}
+		var next Handoff
+		for _, h := range handoffs {
+			if h.Agent.Name == transfer.To {
+				next = h
+			}
+		}
+		history = withoutHandoffCalls(history)
+		if next.Filter != nil {
+			history = next.Filter(history)
+		}
+		agent = next.Agent
+	}
+}
+
+// runUntilHandoff is step.Run that also stops after a transfer tool ran.
+func runUntilHandoff(ctx context.Context, agent step.Agent, tools []step.Tool, history []step.Message, opts []step.StepOption) (step.RunResult, *HandoffResult, error) {
+	maxSteps := agent.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = 10
+	}
+	var run step.RunResult
+	for {
+		if run.Steps >= maxSteps {
+			return run, nil, step.ErrMaxSteps
+		}
+		result, err := step.Step(ctx, step.StepRequest{Provider: agent.Provider, SystemPrompt: agent.SystemPrompt, History: history, Tools: tools}, opts...)
+		run.Steps++
+		run.Messages = append(run.Messages, result...)
+		history = append(history, result...)
+		var transfer *HandoffResult
+		for _, msg := range result {
+			switch m := msg.(type) {
+			case step.AssistantMessage:
+				run.Usage.Add(m.Usage)
+			case step.ToolResultMessage:
+				run.Usage.Add(step.UsageFromDetails(m.Details))
+				if h := HandoffFromDetails(m.Details); h != nil && transfer == nil {
+					transfer = h
+				}
+			}
+		}
+		if err != nil || transfer != nil || !result.HasToolCall() {
+			return run, transfer, err
+		}
+	}
+}
+
+// withoutHandoffCalls removes transfer tool calls and their results.
+func withoutHandoffCalls(history []step.Message) []step.Message {
+	calls := map[string]bool{}
+	for _, msg := range history {
+		if m, ok := msg.(step.ToolResultMessage); ok {
+			if HandoffFromDetails(m.Details) != nil {
+				calls[m.CallID] = true
+			}
+		}
+	}
+	return dropCalls(history, func(callID string) bool { return calls[callID] })
+}
+
+// StripTools is a HistoryFilter that removes all tool calls and results,
+// leaving only the user and assistant text of the conversation.
+func StripTools(history []step.Message) []step.Message {
+	return dropCalls(history, func(string) bool { return true })
+}
+
+// KeepLast returns a HistoryFilter that keeps the last n messages, extended
+// backwards so tool results are never separated from their calls.
+func KeepLast(n int) HistoryFilter {
+	return func(history []step.Message) []step.Message {
+		if len(history) <= n {
+			return history
+		}
+		start := len(history) - n
+		for start > 0 {
+			if _, ok := history[start].(step.ToolResultMessage); !ok {
+				break
+			}
+			start--
+		}
+		return append([]step.Message(nil), history[start:]...)
+	}
+}
+
+// dropCalls removes tool calls matching drop and their results. Assistant
+// messages left without parts are removed.
+func dropCalls(history []step.Message, drop func(callID string) bool) []step.Message {
+	out := make([]step.Message, 0, len(history))
+	for _, msg := range history {
+		switch m := msg.(type) {
+		case step.AssistantMessage:
+			parts := make([]step.Part, 0, len(m.Parts))
+			for _, part := range m.Parts {
+				if call, ok := part.(step.ToolCallPart); ok && drop(call.CallID) {
+					continue
+				}
+				parts = append(parts, part)
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			if len(parts) != len(m.Parts) && m.StopReason == step.StopToolUse && !hasCalls(parts) {
+				m.StopReason = step.StopStop
+			}
+			m.Parts = parts
+			out = append(out, m)
+		case step.ToolResultMessage:
+			if !drop(m.CallID) {
+				out = append(out, m)
+			}
+		default:
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+func hasCalls(parts []step.Part) bool {
+	for _, part := range parts {
+		if _, ok := part.(step.ToolCallPart); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+// Package orchestrate composes agents into common multi-agent patterns:
+// routing to one of several agents, fanning work out to parallel sub-agents and
+// merging the results, and critic/worker refinement loops. All patterns are
+// built on step.Run and report the combined usage of every agent involved.
+// Swarm lets agents hand the conversation to each other via transfer tools.
+package orchestrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/inspirepan/step"
+)
+
+// Result is the outcome of an orchestration.
+type Result struct {
+	// Text is the final answer.
+	Text string
+	// Runs holds each agent run in the order it started, keyed by agent name
+	// in Agents (names may repeat, e.g. across critic rounds).
+	Runs   []step.RunResult
+	Agents []string
+	// Usage sums the usage of every run.
+	Usage step.Usage
+}
+
+func (r *Result) add(name string, run step.RunResult) {
+	r.Agents = append(r.Agents, name)
+	r.Runs = append(r.Runs, run)
+	r.Usage.Add(&run.Usage)
+}
+
+// RouteFunc picks the name of the agent that should handle history.
+// The returned Usage, if any, is counted in the Result.
+type RouteFunc func(ctx context.Context, history []step.Message) (string, *step.Usage, error)
+
+// Router dispatches a conversation to one of several agents.
+type Router struct {
+	Agents []step.Agent
+	Route  RouteFunc
+}
+
+// Run routes history and runs the chosen agent on it.
+func (r Router) Run(ctx context.Context, history []step.Message, opts ...step.StepOption) (Result, error) {
+	var res Result
+	name, usage, err := r.Route(ctx, history)
+	res.Usage.Add(usage)
+	if err != nil {
+		return res, err
+	}
+	for _, agent := range r.Agents {
+		if agent.Name == name {
+			run, err := step.Run(ctx, agent, history, opts...)
+			res.add(agent.Name, run)
+			res.Text = run.Text()
+			return res, err
+		}
+	}
+	return res, fmt.Errorf("orchestrate: router chose unknown agent %q", name)
+}
+
+const routeToolName = "route"
+
+// ModelRoute returns a RouteFunc that asks a model to choose among agents by
+// their Name and Description, via a single forced-choice tool call.
+func ModelRoute(provider step.Provider, agents []step.Agent) RouteFunc {
+	names := make([]string, len(agents))
+	var sb strings.Builder
+	sb.WriteString("Choose the agent best suited to handle the conversation by calling the route tool. Agents:\n")
+	for i, a := range agents {
+		names[i] = a.Name
+		sb.WriteString("- " + a.Name + ": " + a.Description + "\n")
+	}
+	tool := routeTool{spec: step.ToolSpec{
+		Name:        routeToolName,
+		Description: "Hand the conversation to the named agent.",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"agent": map[string]any{"type": "string", "enum": names}},
+			"required":   []string{"agent"},
+		},
+	}}
+	system := sb.String()
+
+	return func(ctx context.Context, history []step.Message) (string, *step.Usage, error) {
+		result, err := step.Step(ctx, step.StepRequest{Provider: provider, SystemPrompt: system, History: history, Tools: []step.Tool{tool}})
+		if err != nil {
+			return "", nil, err
+		}
+		msg := result[0].(step.AssistantMessage)
+		for _, part := range msg.Parts {
+			if call, ok := part.(step.ToolCallPart); ok && call.Name == routeToolName {
+				var args struct {
+					Agent string `json:"agent"`
+				}
+				if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+					return "", msg.Usage, fmt.Errorf("orchestrate: invalid route arguments: %w", err)
+				}
+				return args.Agent, msg.Usage, nil
+			}
+		}
+		return "", msg.Usage, errors.New("orchestrate: router model did not choose an agent")
+	}
+}
+
+// routeTool only records the choice; the router never continues the conversation.
+type routeTool struct {
+	spec step.ToolSpec
+}
+
+func (t routeTool) Spec() step.ToolSpec { return t.spec }
+
+func (t routeTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "routed"}}}, nil
+}
+
+// Task is one unit of fan-out work.
+type Task struct {
+	Agent   step.Agent
+	History []step.Message
+}
+
+// Prompt returns a Task that runs agent on a single user prompt.
+func Prompt(agent step.Agent, prompt string) Task {
+	return Task{Agent: agent, History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: prompt}}}}}
+}
+
+// MergeFunc combines fan-out results, given in task order, into a final answer.
+// Usage reported by the merge is counted in the Result.
+type MergeFunc func(ctx context.Context, tasks []Task, runs []step.RunResult) (string, *step.Usage, error)
+
+// FanOut runs tasks concurrently, at most limit at a time (no limit if limit <= 0),
+// and merges their results. A failed task cancels the others and its error is
+// returned; the Result still holds every run.
+func FanOut(ctx context.Context, tasks []Task, limit int, merge MergeFunc, opts ...step.StepOption) (Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	runs := make([]step.RunResult, len(tasks))
+	errs := make([]error, len(tasks))
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					errs[i] = ctx.Err()
+					return
+				}
+			}
+			runs[i], errs[i] = step.Run(ctx, task.Agent, task.History, opts...)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var res Result
+	for i, run := range runs {
+		res.add(tasks[i].Agent.Name, run)
+	}
+	// Report the root cause rather than the cancellations it triggered.
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && (firstErr == nil || errors.Is(firstErr, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return res, firstErr
+	}
+	if merge == nil {
+		merge = ConcatMerge
+	}
+	text, usage, err := merge(ctx, tasks, runs)
+	res.Usage.Add(usage)
+	res.Text = text
+	return res, err
+}
+
+// ConcatMerge joins the final answers under a heading per agent.
+func ConcatMerge(_ context.Context, tasks []Task, runs []step.RunResult) (string, *step.Usage, error) {
+	parts := make([]string, len(runs))
+	for i, run := range runs {
+		parts[i] = "## " + tasks[i].Agent.Name + "\n\n" + run.Text()
+	}
+	return strings.Join(parts, "\n\n"), nil, nil
+}
+
+// AgentMerge returns a MergeFunc that asks agent to synthesize the answers
+// into one, with instruction describing what to produce.
+func AgentMerge(agent step.Agent, instruction string) MergeFunc {
+	return func(ctx context.Context, tasks []Task, runs []step.RunResult) (string, *step.Usage, error) {
+		answers, _, _ := ConcatMerge(ctx, tasks, runs)
+		prompt := instruction + "\n\n" + answers
+		run, err := step.Run(ctx, agent, []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: prompt}}}})
+		return run.Text(), &run.Usage, err
+	}
+}
+
+// Critic runs a worker/critic refinement loop.
+type Critic struct {
+	Worker step.Agent
+	Critic step.Agent
+	// MaxRounds bounds the number of worker drafts. Defaults to 3.
+	MaxRounds int
+	// Approved reports whether the critic's review accepts the draft.
+	// Defaults to the review starting with "APPROVED".
+	Approved func(review string) bool
+}
+
+// ErrNotApproved is returned by Critic.Run when no draft was approved within MaxRounds.
+// The Result holds the last draft.
+var ErrNotApproved = errors.New("orchestrate: critic did not approve within max rounds")
+
+// Run has the worker draft an answer to task, the critic review it, and the
+// worker revise with the review until the critic approves.
+func (c Critic) Run(ctx context.Context, task string, opts ...step.StepOption) (Result, error) {
+	maxRounds := c.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = 3
+	}
+	approved := c.Approved
+	if approved == nil {
+		approved = func(review string) bool {
+			return strings.HasPrefix(strings.TrimSpace(review), "APPROVED")
+		}
+	}
+
+	var res Result
+	worker := []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: task}}}}
+	for range maxRounds {
+		draft, err := step.Run(ctx, c.Worker, worker, opts...)
+		res.add(c.Worker.Name, draft)
+		if err != nil {
+			return res, err
+		}
+		res.Text = draft.Text()
+		worker = append(worker, draft.Messages...)
+
+		reviewPrompt := "Task:\n" + task + "\n\nDraft:\n" + res.Text +
+			"\n\nReview the draft. Reply APPROVED if it fully solves the task; otherwise list the problems to fix."
+		review, err := step.Run(ctx, c.Critic, []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: reviewPrompt}}}}, opts...)
+		res.add(c.Critic.Name, review)
+		if err != nil {
+			return res, err
+		}
+		if approved(review.Text()) {
+			return res, nil
+		}
+		worker = append(worker, step.UserMessage{Parts: []step.Part{step.TextPart{Text: "A reviewer found problems with your answer:\n\n" + review.Text() + "\n\nRevise your answer."}}})
+	}
+	return res, ErrNotApproved
+}
@@ -0,0 +1,168 @@
+package orchestrate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/orchestrate"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func text(s string, output int) mock.Response {
+	r := mock.Text(s)
+	r.Message.Usage = &step.Usage{OutputTokens: output}
+	return r
+}
+
+func userTurn(s string) []step.Message {
+	return []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: s}}}}
+}
+
+func TestRouter_ModelRoute(t *testing.T) {
+	billing := step.Agent{Name: "billing", Description: "Invoices and payments", Provider: mock.New(text("Refund issued.", 4))}
+	support := step.Agent{Name: "support", Description: "Technical problems", Provider: mock.New()}
+	args, _ := json.Marshal(map[string]string{"agent": "billing"})
+	route := mock.ToolCalls(step.ToolCallPart{CallID: "r1", Name: "route", ArgsJSON: args})
+	route.Message.Usage = &step.Usage{OutputTokens: 2}
+	routerModel := mock.New(route)
+
+	agents := []step.Agent{billing, support}
+	res, err := orchestrate.Router{Agents: agents, Route: orchestrate.ModelRoute(routerModel, agents)}.Run(context.Background(), userTurn("I was charged twice."))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Text != "Refund issued." || res.Agents[0] != "billing" || res.Usage.OutputTokens != 6 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if sys := routerModel.Requests()[0].SystemPrompt; sys == "" {
+		t.Error("expected the router prompt to describe the agents")
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	tasks := []orchestrate.Task{
+		orchestrate.Prompt(step.Agent{Name: "pros", Provider: mock.New(text("Fast.", 1))}, "List pros."),
+		orchestrate.Prompt(step.Agent{Name: "cons", Provider: mock.New(text("Costly.", 2))}, "List cons."),
+	}
+	res, err := orchestrate.FanOut(context.Background(), tasks, 1, nil)
+	if err != nil {
+		t.Fatalf("FanOut failed: %v", err)
+	}
+	if want := "## pros\n\nFast.\n\n## cons\n\nCostly."; res.Text != want {
+		t.Errorf("unexpected merge %q", res.Text)
+	}
+
+	synth := step.Agent{Name: "synth", Provider: mock.New(text("Fast but costly.", 4))}
+	tasks = []orchestrate.Task{
+		orchestrate.Prompt(step.Agent{Name: "pros", Provider: mock.New(text("Fast.", 1))}, "List pros."),
+		orchestrate.Prompt(step.Agent{Name: "cons", Provider: mock.New(text("Costly.", 2))}, "List cons."),
+	}
+	res, err = orchestrate.FanOut(context.Background(), tasks, 0, orchestrate.AgentMerge(synth, "Summarize in one sentence."))
+	if err != nil {
+		t.Fatalf("FanOut failed: %v", err)
+	}
+	if res.Text != "Fast but costly." || res.Usage.OutputTokens != 7 {
+		t.Errorf("unexpected result %q with %d output tokens", res.Text, res.Usage.OutputTokens)
+	}
+}
+
+func TestFanOut_Error(t *testing.T) {
+	boom := errors.New("boom")
+	tasks := []orchestrate.Task{
+		orchestrate.Prompt(step.Agent{Name: "ok", Provider: mock.New(text("fine", 1))}, "a"),
+		orchestrate.Prompt(step.Agent{Name: "bad", Provider: mock.New(mock.Response{Err: boom})}, "b"),
+	}
+	if _, err := orchestrate.FanOut(context.Background(), tasks, 0, nil); !errors.Is(err, boom) {
+		t.Fatalf("expected task error, got %v", err)
+	}
+}
+
+func TestCritic(t *testing.T) {
+	worker := mock.New(text("draft 1", 1), text("draft 2", 1))
+	critic := mock.New(text("Missing error handling.", 1), text("APPROVED", 1))
+	res, err := orchestrate.Critic{
+		Worker: step.Agent{Name: "worker", Provider: worker},
+		Critic: step.Agent{Name: "critic", Provider: critic},
+	}.Run(context.Background(), "Write a parser.")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Text != "draft 2" || len(res.Runs) != 4 || res.Usage.OutputTokens != 4 {
+		t.Errorf("unexpected result %q with %d runs", res.Text, len(res.Runs))
+	}
+	revision := worker.Requests()[1].History
+	if len(revision) != 3 {
+		t.Fatalf("expected the revision request to carry draft and review, got %d messages", len(revision))
+	}
+
+	_, err = orchestrate.Critic{
+		Worker:    step.Agent{Name: "worker", Provider: mock.New(text("draft", 1))},
+		Critic:    step.Agent{Name: "critic", Provider: mock.New(text("No.", 1))},
+		MaxRounds: 1,
+	}.Run(context.Background(), "Write a parser.")
+	if !errors.Is(err, orchestrate.ErrNotApproved) {
+		t.Errorf("expected ErrNotApproved, got %v", err)
+	}
+}
+
+func TestSwarm_Handoff(t *testing.T) {
+	billingModel := mock.New(text("Refund issued.", 4))
+	billing := step.Agent{Name: "billing", Description: "Invoices and payments", Provider: billingModel}
+	transfer := mock.ToolCalls(step.ToolCallPart{CallID: "h1", Name: "transfer_to_billing", ArgsJSON: json.RawMessage(`{"reason":"double charge"}`)})
+	transfer.Message.Parts = append([]step.Part{step.TextPart{Text: "Let me get billing."}}, transfer.Message.Parts...)
+	transfer.Message.Usage = &step.Usage{OutputTokens: 2}
+	triageModel := mock.New(transfer)
+	triage := step.Agent{Name: "triage", Provider: triageModel}
+
+	swarm := orchestrate.Swarm{Handoffs: map[string][]orchestrate.Handoff{
+		"triage": {{Agent: billing}},
+	}}
+	res, err := swarm.Run(context.Background(), triage, userTurn("I was charged twice."))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Text != "Refund issued." || res.Active != "billing" || res.Usage.OutputTokens != 6 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if len(res.Handoffs) != 1 || res.Handoffs[0] != (orchestrate.HandoffResult{From: "triage", To: "billing", Reason: "double charge"}) {
+		t.Errorf("unexpected handoffs %+v", res.Handoffs)
+	}
+	if tools := triageModel.Requests()[0].Tools; len(tools) != 1 || tools[0].Name != "transfer_to_billing" {
+		t.Errorf("unexpected triage tools %+v", tools)
+	}
+	// Billing sees the conversation without the transfer call it cannot resolve.
+	seen := billingModel.Requests()[0].History
+	if len(seen) != 2 {
+		t.Fatalf("expected user and triage text, got %d messages", len(seen))
+	}
+	if m := seen[1].(step.AssistantMessage); len(m.Parts) != 1 || m.StopReason == step.StopToolUse {
+		t.Errorf("transfer call was not removed: %+v", m)
+	}
+	if len(res.Messages) != 3 {
+		t.Errorf("expected the full transcript, got %d messages", len(res.Messages))
+	}
+}
+
+func TestHistoryFilters(t *testing.T) {
+	call := step.ToolCallPart{CallID: "c1", Name: "lookup", ArgsJSON: json.RawMessage(`{}`)}
+	history := []step.Message{
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "q"}}},
+		step.AssistantMessage{Parts: []step.Part{call}, StopReason: step.StopToolUse},
+		step.ToolResultMessage{CallID: "c1", Parts: []step.Part{step.TextPart{Text: "r"}}},
+		step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "a"}}, StopReason: step.StopStop},
+	}
+	if got := orchestrate.StripTools(history); len(got) != 2 {
+		t.Errorf("StripTools kept %d messages", len(got))
+	}
+	// The last two messages start with a tool result, so its call is kept too.
+	if got := orchestrate.KeepLast(2)(history); len(got) != 3 {
+		t.Errorf("KeepLast(2) kept %d messages", len(got))
+	}
+	decoded := map[string]any{"handoff": map[string]any{"from": "a", "to": "b"}}
+	if h := orchestrate.HandoffFromDetails(decoded); h == nil || h.To != "b" {
+		t.Errorf("unexpected decoded handoff %+v", h)
+	}
+}
@@ -1,8 +1,10 @@
 package step
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"slices"
 )
 
 // PartType describes the kind of content in a part.
@@ -13,6 +15,8 @@ const (
 	PartThinking PartType = "thinking"
 	PartImage    PartType = "image"
 	PartToolCall PartType = "tool_call"
+	PartCitation PartType = "citation"
+	PartAudio    PartType = "audio"
 )
 
 // Part is a structured message fragment.
@@ -22,7 +26,8 @@ type Part interface {
 
 // TextPart represents text content.
 type TextPart struct {
-	Text string `json:"text"`
+	Text     string   `json:"text"`
+	Metadata Metadata `json:"metadata,omitempty"`
 }
 
 func (TextPart) partType() PartType { return PartText }
@@ -30,9 +35,10 @@ func (TextPart) partType() PartType { return PartText }
 func (p TextPart) MarshalJSON() ([]byte, error) {
 	type alias TextPart
 	return json.Marshal(struct {
-		Type PartType `json:"type"`
+		Type    PartType `json:"type"`
+		Version int      `json:"version"`
 		alias
-	}{PartText, alias(p)})
+	}{PartText, MessageVersion, alias(p)})
 }
 
 // ThinkingPart represents model reasoning content.
@@ -45,7 +51,8 @@ type ThinkingPart struct {
 	// Format for OpenRouter's reasoning_detail.format
 	Format string `json:"format,omitempty"`
 	// ModelName identifies the source model for cross-model degradation
-	ModelName string `json:"model_name,omitempty"`
+	ModelName string   `json:"model_name,omitempty"`
+	Metadata  Metadata `json:"metadata,omitempty"`
 }
 
 func (ThinkingPart) partType() PartType { return PartThinking }
@@ -53,15 +60,17 @@ func (ThinkingPart) partType() PartType { return PartThinking }
 func (p ThinkingPart) MarshalJSON() ([]byte, error) {
 	type alias ThinkingPart
 	return json.Marshal(struct {
-		Type PartType `json:"type"`
+		Type    PartType `json:"type"`
+		Version int      `json:"version"`
 		alias
-	}{PartThinking, alias(p)})
+	}{PartThinking, MessageVersion, alias(p)})
 }
 
 // ImagePart represents image content.
 type ImagePart struct {
-	MimeType string `json:"mime_type"`
-	DataB64  string `json:"data_b64"`
+	MimeType string   `json:"mime_type"`
+	DataB64  string   `json:"data_b64"`
+	Metadata Metadata `json:"metadata,omitempty"`
 }
 
 func (ImagePart) partType() PartType { return PartImage }
@@ -69,9 +78,31 @@ func (ImagePart) partType() PartType { return PartImage }
 func (p ImagePart) MarshalJSON() ([]byte, error) {
 	type alias ImagePart
 	return json.Marshal(struct {
-		Type PartType `json:"type"`
+		Type    PartType `json:"type"`
+		Version int      `json:"version"`
+		alias
+	}{PartImage, MessageVersion, alias(p)})
+}
+
+// AudioPart represents spoken content, e.g. from a realtime session. Providers
+// without audio support skip it; Transcript keeps the words for history.
+type AudioPart struct {
+	MimeType string `json:"mime_type"`
+	DataB64  string `json:"data_b64,omitempty"`
+	// Transcript is the text of the audio, if known.
+	Transcript string   `json:"transcript,omitempty"`
+	Metadata   Metadata `json:"metadata,omitempty"`
+}
+
+func (AudioPart) partType() PartType { return PartAudio }
+
+func (p AudioPart) MarshalJSON() ([]byte, error) {
+	type alias AudioPart
+	return json.Marshal(struct {
+		Type    PartType `json:"type"`
+		Version int      `json:"version"`
 		alias
-	}{PartImage, alias(p)})
+	}{PartAudio, MessageVersion, alias(p)})
 }
 
 // ToolCallPart represents a tool call request.
@@ -79,6 +110,7 @@ type ToolCallPart struct {
 	CallID   string          `json:"call_id"`
 	Name     string          `json:"name"`
 	ArgsJSON json.RawMessage `json:"args_json,omitempty"`
+	Metadata Metadata        `json:"metadata,omitempty"`
 }
 
 func (ToolCallPart) partType() PartType { return PartToolCall }
@@ -86,12 +118,116 @@ func (ToolCallPart) partType() PartType { return PartToolCall }
 func (p ToolCallPart) MarshalJSON() ([]byte, error) {
 	type alias ToolCallPart
 	return json.Marshal(struct {
-		Type PartType `json:"type"`
+		Type    PartType `json:"type"`
+		Version int      `json:"version"`
+		alias
+	}{PartToolCall, MessageVersion, alias(p)})
+}
+
+// CitationPart attributes a span of the response text to a source, e.g. a web
+// search grounding result. Citations are informational and are not sent back to providers.
+type CitationPart struct {
+	URL   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+	// Text is the cited span of the response.
+	Text string `json:"text,omitempty"`
+	// StartIndex and EndIndex are byte offsets of Text in the message text, as reported by the provider.
+	StartIndex int      `json:"start_index,omitempty"`
+	EndIndex   int      `json:"end_index,omitempty"`
+	Metadata   Metadata `json:"metadata,omitempty"`
+}
+
+func (CitationPart) partType() PartType { return PartCitation }
+
+func (p CitationPart) MarshalJSON() ([]byte, error) {
+	type alias CitationPart
+	return json.Marshal(struct {
+		Type    PartType `json:"type"`
+		Version int      `json:"version"`
 		alias
-	}{PartToolCall, alias(p)})
+	}{PartCitation, MessageVersion, alias(p)})
+}
+
+// OpaquePart is a part of a type this version does not know, such as one
+// written by a newer version. UnmarshalPart keeps its JSON in Raw and
+// MarshalJSON writes it back, so histories pass through older code without
+// losing parts. Step leaves opaque parts out of provider requests, which
+// could not represent them, but keeps them in the history it returns.
+type OpaquePart struct {
+	Type PartType
+	Raw  json.RawMessage
 }
 
-// UnmarshalPart decodes a JSON object into a concrete Part type.
+func (p OpaquePart) partType() PartType { return p.Type }
+
+func (p OpaquePart) MarshalJSON() ([]byte, error) {
+	if len(p.Raw) == 0 {
+		return json.Marshal(struct {
+			Type PartType `json:"type"`
+		}{p.Type})
+	}
+	return p.Raw, nil
+}
+
+// withoutOpaqueParts returns history without OpaqueParts, sharing the
+// messages that have none. User and assistant messages left without parts
+// are dropped; tool results are kept to answer their calls.
+func withoutOpaqueParts(history []Message) []Message {
+	var out []Message
+	for i, msg := range history {
+		parts := messageParts(msg)
+		if !slices.ContainsFunc(parts, isOpaque) {
+			if out != nil {
+				out = append(out, msg)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]Message, 0, len(history)), history[:i]...)
+		}
+		parts = slices.DeleteFunc(slices.Clone(parts), isOpaque)
+		switch m := msg.(type) {
+		case UserMessage:
+			if len(parts) > 0 {
+				m.Parts = parts
+				out = append(out, m)
+			}
+		case AssistantMessage:
+			if len(parts) > 0 {
+				m.Parts = parts
+				out = append(out, m)
+			}
+		case ToolResultMessage:
+			m.Parts = parts
+			out = append(out, m)
+		}
+	}
+	if out == nil {
+		return history
+	}
+	return out
+}
+
+func isOpaque(p Part) bool {
+	_, ok := p.(OpaquePart)
+	return ok
+}
+
+func messageParts(msg Message) []Part {
+	switch m := msg.(type) {
+	case UserMessage:
+		return m.Parts
+	case AssistantMessage:
+		return m.Parts
+	case ToolResultMessage:
+		return m.Parts
+	}
+	return nil
+}
+
+// UnmarshalPart decodes a JSON object into a concrete Part type. Like
+// UnmarshalMessage it reads parts of any version; objects of an unknown type
+// become an OpaquePart.
 func UnmarshalPart(data []byte) (Part, error) {
 	var raw struct {
 		Type PartType `json:"type"`
@@ -119,14 +255,29 @@ func UnmarshalPart(data []byte) (Part, error) {
 			return nil, err
 		}
 		return p, nil
+	case PartAudio:
+		var p AudioPart
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+		return p, nil
 	case PartToolCall:
 		var p ToolCallPart
 		if err := json.Unmarshal(data, &p); err != nil {
 			return nil, err
 		}
 		return p, nil
+	case PartCitation:
+		var p CitationPart
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+		return p, nil
 	default:
-		return nil, fmt.Errorf("unknown part type: %s", raw.Type)
+		if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '{' {
+			return nil, fmt.Errorf("part is not an object: %s", data)
+		}
+		return OpaquePart{Type: raw.Type, Raw: bytes.Clone(data)}, nil
 	}
 }
 
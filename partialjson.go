@@ -0,0 +1,274 @@
+package step
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// WithPartialToolArgs sets ToolCallDelta.PartialArgs on every tool call
+// delta, so consumers can show arguments (such as the command about to run)
+// while the model is still writing them. Arguments are parsed incrementally
+// with a PartialJSON per call.
+func WithPartialToolArgs() StepOption {
+	return func(c *stepConfig) { c.partialArgs = true }
+}
+
+// partialArgsEmitter returns e with PartialArgs filled in on tool call deltas.
+func partialArgsEmitter(e stepEmitter, enabled bool) stepEmitter {
+	if !enabled || e.onDelta == nil {
+		return e
+	}
+	var mu sync.Mutex
+	calls := make(map[string]*PartialJSON)
+	onDelta := e.onDelta
+	e.onDelta = func(d MessageDelta) {
+		if tc, ok := d.(ToolCallDelta); ok {
+			mu.Lock()
+			p := calls[tc.CallID]
+			if p == nil {
+				p = &PartialJSON{}
+				calls[tc.CallID] = p
+			}
+			p.Write(tc.ArgsDelta)
+			if v, ok := p.Value(); ok {
+				tc.PartialArgs, _ = v.(map[string]any)
+			}
+			mu.Unlock()
+			d = tc
+		}
+		onDelta(d)
+	}
+	return e
+}
+
+type jsonExpect int
+
+const (
+	expectValue jsonExpect = iota
+	expectValueOrClose
+	expectKey
+	expectKeyOrClose
+	expectColon
+	expectCommaOrClose
+	expectEnd
+)
+
+// PartialJSON incrementally parses a JSON document that arrives in fragments,
+// such as streamed tool call arguments. Each byte is scanned once; Value
+// closes whatever is still open to produce the document so far.
+//
+// The zero value is ready to use.
+type PartialJSON struct {
+	buf   []byte
+	stack []byte // open containers, '{' or '['
+	next  jsonExpect
+	err   error
+
+	str      int  // offset past the opening quote of the open string, or 0
+	key      bool // the open string is an object key
+	esc      int  // -1 after a backslash, 1-4 while reading \u digits
+	escStart int
+	lit      int // offset past the start of the open number or literal, or 0
+
+	// buf[:safe] with safeStack closed is the last valid prefix.
+	safe      int
+	safeStack []byte
+}
+
+// Write appends a fragment. Once the input is known to be invalid, further
+// fragments are ignored and Err reports why.
+func (p *PartialJSON) Write(fragment string) {
+	for i := 0; i < len(fragment) && p.err == nil; i++ {
+		p.scan(fragment[i])
+	}
+}
+
+// Err returns a non-nil error if the input so far is not a prefix of valid JSON.
+func (p *PartialJSON) Err() error { return p.err }
+
+// Complete reports whether the input is a complete JSON document. A bare
+// top-level number is only complete once followed by whitespace.
+func (p *PartialJSON) Complete() bool { return p.err == nil && p.next == expectEnd }
+
+// Value returns the document parsed so far. Open strings, arrays and objects
+// are closed; an object key without a value and an unfinished number or
+// literal are left out. It returns false before any value has started or if
+// the input is invalid.
+func (p *PartialJSON) Value() (any, bool) {
+	if p.err != nil {
+		return nil, false
+	}
+	doc := p.snapshot()
+	if len(doc) == 0 {
+		return nil, false
+	}
+	var v any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func (p *PartialJSON) snapshot() []byte {
+	switch {
+	case p.str > 0 && !p.key:
+		end := len(p.buf)
+		if p.esc != 0 {
+			end = p.escStart
+		}
+		end = p.str + completeRunes(string(p.buf[p.str:end]))
+		return closeJSON(append(append([]byte(nil), p.buf[:end]...), '"'), p.stack)
+	case p.lit > 0 && json.Valid(p.buf[p.lit-1:]):
+		return closeJSON(append([]byte(nil), p.buf...), p.stack)
+	}
+	return closeJSON(append([]byte(nil), p.buf[:p.safe]...), p.safeStack)
+}
+
+func closeJSON(doc, stack []byte) []byte {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			doc = append(doc, '}')
+		} else {
+			doc = append(doc, ']')
+		}
+	}
+	return doc
+}
+
+func (p *PartialJSON) scan(c byte) {
+	p.buf = append(p.buf, c)
+	n := len(p.buf)
+
+	if p.str > 0 {
+		switch {
+		case p.esc == -1:
+			switch c {
+			case 'u':
+				p.esc = 4
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				p.esc = 0
+			default:
+				p.fail(n - 1)
+			}
+		case p.esc > 0:
+			if !isHex(c) {
+				p.fail(n - 1)
+				return
+			}
+			p.esc--
+		case c == '\\':
+			p.esc, p.escStart = -1, n-1
+		case c == '"':
+			p.str = 0
+			if p.key {
+				p.next = expectColon
+			} else {
+				p.valueDone(n)
+			}
+		case c < 0x20:
+			p.fail(n - 1)
+		}
+		return
+	}
+
+	if p.lit > 0 {
+		if isLiteralByte(c) {
+			return
+		}
+		if !json.Valid(p.buf[p.lit-1 : n-1]) {
+			p.fail(p.lit - 1)
+			return
+		}
+		p.lit = 0
+		p.valueDone(n - 1)
+	}
+
+	switch c {
+	case ' ', '\t', '\n', '\r':
+		return
+	}
+	switch p.next {
+	case expectValue, expectValueOrClose:
+		switch {
+		case c == '{':
+			p.open(c, expectKeyOrClose, n)
+		case c == '[':
+			p.open(c, expectValueOrClose, n)
+		case c == '"':
+			p.str, p.key = n, false
+		case c == ']' && p.next == expectValueOrClose:
+			p.close(n)
+		case c == '-' || c >= '0' && c <= '9' || c == 't' || c == 'f' || c == 'n':
+			p.lit = n
+		default:
+			p.fail(n - 1)
+		}
+	case expectKey, expectKeyOrClose:
+		switch {
+		case c == '"':
+			p.str, p.key = n, true
+		case c == '}' && p.next == expectKeyOrClose:
+			p.close(n)
+		default:
+			p.fail(n - 1)
+		}
+	case expectColon:
+		if c != ':' {
+			p.fail(n - 1)
+			return
+		}
+		p.next = expectValue
+	case expectCommaOrClose:
+		top := p.stack[len(p.stack)-1]
+		switch {
+		case c == ',' && top == '{':
+			p.next = expectKey
+		case c == ',':
+			p.next = expectValue
+		case c == '}' && top == '{', c == ']' && top == '[':
+			p.close(n)
+		default:
+			p.fail(n - 1)
+		}
+	default:
+		p.fail(n - 1)
+	}
+}
+
+func (p *PartialJSON) open(c byte, next jsonExpect, end int) {
+	p.stack = append(p.stack, c)
+	p.next = next
+	p.markSafe(end)
+}
+
+func (p *PartialJSON) close(end int) {
+	p.stack = p.stack[:len(p.stack)-1]
+	p.valueDone(end)
+}
+
+func (p *PartialJSON) valueDone(end int) {
+	if len(p.stack) == 0 {
+		p.next = expectEnd
+	} else {
+		p.next = expectCommaOrClose
+	}
+	p.markSafe(end)
+}
+
+func (p *PartialJSON) markSafe(end int) {
+	p.safe = end
+	p.safeStack = append(p.safeStack[:0], p.stack...)
+}
+
+func (p *PartialJSON) fail(offset int) {
+	p.err = fmt.Errorf("step: invalid JSON at offset %d", offset)
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isLiteralByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c == '.' || c == '+' || c == '-' || c == 'E'
+}
@@ -0,0 +1,116 @@
+package step_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/inspirepan/step"
+)
+
+func TestPartialJSON_Prefixes(t *testing.T) {
+	doc := `{"command": "echo \"hé\" é\n", "args": [1, -2.5e3, true, null, {"k": []}], "n": 42}`
+	var want any
+	if err := json.Unmarshal([]byte(doc), &want); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every prefix, fed one byte at a time, yields a valid document.
+	var p step.PartialJSON
+	for i := 0; i < len(doc); i++ {
+		p.Write(doc[i : i+1])
+		if err := p.Err(); err != nil {
+			t.Fatalf("prefix %q: %v", doc[:i+1], err)
+		}
+		if _, ok := p.Value(); !ok {
+			t.Fatalf("prefix %q: no value", doc[:i+1])
+		}
+	}
+	if !p.Complete() {
+		t.Error("expected the document to be complete")
+	}
+	if got, _ := p.Value(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPartialJSON_Value(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"command": "ls -`, `{"command":"ls -"}`},
+		{`{"command": "a\`, `{"command":"a"}`},
+		{`{"command": "a\u00`, `{"command":"a"}`},
+		{"{\"command\": \"h\xc3", `{"command":"h"}`},
+		{`{"a": 1, "comm`, `{"a":1}`},
+		{`{"a": 1, "command":`, `{"a":1}`},
+		{`{"a": 12`, `{"a":12}`},
+		{`{"a": -`, `{}`},
+		{`{"a": tr`, `{}`},
+		{`{"a": [1, {"b": "c`, `{"a":[1,{"b":"c"}]}`},
+	}
+	for _, tt := range tests {
+		var p step.PartialJSON
+		p.Write(tt.in)
+		v, ok := p.Value()
+		got, _ := json.Marshal(v)
+		if !ok || string(got) != tt.want {
+			t.Errorf("%q: got %s (%v), want %s", tt.in, got, ok, tt.want)
+		}
+		if p.Complete() {
+			t.Errorf("%q: unexpectedly complete", tt.in)
+		}
+	}
+
+	var p step.PartialJSON
+	if _, ok := p.Value(); ok {
+		t.Error("expected no value before any input")
+	}
+}
+
+func TestPartialJSON_Invalid(t *testing.T) {
+	for _, in := range []string{`{"a" 1}`, `{"a": 1]`, `{"a": 1} x`, `[1,]`, `{"a": tx}`, "\"a\x01"} {
+		var p step.PartialJSON
+		p.Write(in)
+		if p.Err() == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+		if _, ok := p.Value(); ok {
+			t.Errorf("%q: expected no value", in)
+		}
+	}
+}
+
+// toolArgsProvider streams a tool call with the given argument fragments.
+type toolArgsProvider []string
+
+func (p toolArgsProvider) Stream(context.Context, step.ProviderRequest) (step.ProviderStream, error) {
+	var args string
+	var ups []step.ProviderUpdate
+	for _, chunk := range p {
+		args += chunk
+		ups = append(ups, step.ProviderDeltaUpdate{Delta: step.ToolCallDelta{CallID: "call_1", Name: "bash", ArgsDelta: chunk}})
+	}
+	call := step.ToolCallPart{CallID: "call_1", Name: "bash", ArgsJSON: json.RawMessage(args)}
+	ups = append(ups, step.ProviderMessageUpdate{Message: step.AssistantMessage{Parts: []step.Part{call}, StopReason: step.StopToolUse}})
+	return &updateStream{ups: ups}, nil
+}
+
+func TestStep_PartialToolArgs(t *testing.T) {
+	provider := toolArgsProvider{`{"comm`, `and": "rm`, ` -rf /tmp/x`, `", "timeout": 3`, `0}`}
+	var got []any
+	_, err := step.Step(context.Background(), step.StepRequest{Provider: provider}, step.WithPartialToolArgs(), step.WithOnDelta(func(d step.MessageDelta) {
+		if tc, ok := d.(step.ToolCallDelta); ok {
+			got = append(got, tc.PartialArgs["command"])
+		}
+	}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	want := []any{nil, "rm", "rm -rf /tmp/x", "rm -rf /tmp/x", "rm -rf /tmp/x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+package step
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// MetadataToolPending is set to true on placeholder tool results of deferred
+// tool calls; see ToolResult.Pending.
+const MetadataToolPending = "step.tool_pending"
+
+var (
+	// ErrPaused is returned by Run and Resume when the run was paused with a
+	// Pauser or is waiting on deferred tool results. RunResult.State then
+	// holds the state to pass to Resume.
+	ErrPaused = errors.New("step: run paused")
+
+	// ErrToolPending is returned by Resume while deferred tool results are
+	// still missing; see RunState.CompleteToolCall.
+	ErrToolPending = errors.New("step: waiting on deferred tool results")
+)
+
+// Pauser pauses a Run in progress, e.g. while a tool call waits on an external
+// approval. The zero value is ready to use; a Pauser is safe for concurrent use.
+type Pauser struct {
+	requested atomic.Bool
+}
+
+// Pause asks the run to stop after its current step. Called before the step's
+// tools run, such as from a WithOnAfterAssistant hook, it also leaves the
+// step's tool calls pending; they run when the run is resumed. A pause
+// requested as the run finishes anyway is dropped.
+func (p *Pauser) Pause() { p.requested.Store(true) }
+
+// take reports whether a pause was requested, clearing the request.
+func (p *Pauser) take() bool {
+	return p != nil && p.requested.Swap(false)
+}
+
+// WithPauser lets p pause Run and Resume; see Pauser.Pause. Step and
+// StepStream return ErrPaused without running the step's tools when paused
+// before them.
+func WithPauser(p *Pauser) StepOption {
+	return func(c *stepConfig) { c.pauser = p }
+}
+
+// RunState is the resumable state of a paused run. It encodes to JSON, so a
+// run can be paused in one process and resumed in another.
+type RunState struct {
+	RunID string
+	// History is the full conversation, including the input history of the
+	// original Run.
+	History []Message
+	Steps   int
+	Usage   Usage
+
+	// Model and ReasoningEffort record WithModel and WithReasoningEffort;
+	// Resume applies them before its own options.
+	Model           string
+	ReasoningEffort ReasoningEffort
+}
+
+// PendingToolCalls returns the tool calls of the last assistant message that
+// have no result in History. Resume runs them first; to reject a call, append
+// an error ToolResultMessage for it before resuming.
+func (s RunState) PendingToolCalls() []ToolCallPart {
+	done := make(map[string]bool)
+	for i := len(s.History) - 1; i >= 0; i-- {
+		switch m := s.History[i].(type) {
+		case ToolResultMessage:
+			done[m.CallID] = true
+		case AssistantMessage:
+			var pending []ToolCallPart
+			for _, call := range extractToolCalls(m) {
+				if !done[call.CallID] {
+					pending = append(pending, call)
+				}
+			}
+			return pending
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// DeferredResults returns the placeholder results of deferred tool calls that
+// have not been completed; see ToolResult.Pending.
+func (s RunState) DeferredResults() []ToolResultMessage {
+	var out []ToolResultMessage
+	for _, msg := range s.History {
+		if m, ok := msg.(ToolResultMessage); ok && isPending(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// CompleteToolCall replaces the placeholder result of the deferred call
+// callID with res, keeping its position and ID in History.
+func (s *RunState) CompleteToolCall(callID string, res ToolResult) error {
+	for i, msg := range s.History {
+		m, ok := msg.(ToolResultMessage)
+		if !ok || m.CallID != callID || !isPending(m) {
+			continue
+		}
+		m.Parts, m.IsError, m.Details = res.Parts, res.IsError, res.Details
+		m.Timestamp = time.Now().UnixMilli()
+		m.Metadata = m.Metadata.Clone()
+		m.Metadata.Delete(MetadataToolPending)
+		s.History[i] = m
+		return nil
+	}
+	return fmt.Errorf("step: no deferred result for tool call %q", callID)
+}
+
+func isPending(m ToolResultMessage) bool {
+	pending, _ := m.Metadata.Bool(MetadataToolPending)
+	return pending
+}
+
+// awaitingResults reports whether the tool results ending history include a
+// deferred placeholder.
+func awaitingResults(history []Message) bool {
+	for i := len(history) - 1; i >= 0; i-- {
+		m, ok := history[i].(ToolResultMessage)
+		if !ok {
+			return false
+		}
+		if isPending(m) {
+			return true
+		}
+	}
+	return false
+}
+
+type runStateJSON struct {
+	RunID           string            `json:"run_id,omitempty"`
+	History         []json.RawMessage `json:"history,omitempty"`
+	Steps           int               `json:"steps"`
+	Usage           Usage             `json:"usage"`
+	Model           string            `json:"model,omitempty"`
+	ReasoningEffort ReasoningEffort   `json:"reasoning_effort,omitempty"`
+}
+
+// MarshalJSON encodes the state with its messages in their JSON form.
+func (s RunState) MarshalJSON() ([]byte, error) {
+	out := runStateJSON{RunID: s.RunID, Steps: s.Steps, Usage: s.Usage, Model: s.Model, ReasoningEffort: s.ReasoningEffort}
+	for _, msg := range s.History {
+		raw, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		out.History = append(out.History, raw)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a state written by MarshalJSON.
+func (s *RunState) UnmarshalJSON(data []byte) error {
+	var in runStateJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*s = RunState{RunID: in.RunID, Steps: in.Steps, Usage: in.Usage, Model: in.Model, ReasoningEffort: in.ReasoningEffort}
+	for _, raw := range in.History {
+		msg, err := UnmarshalMessage(raw)
+		if err != nil {
+			return err
+		}
+		s.History = append(s.History, msg)
+	}
+	return nil
+}
+
+// Resume continues a paused run from state: it runs the pending tool calls,
+// then steps as Run does. It returns ErrToolPending if deferred tool results
+// have not all been completed. MaxSteps counts the steps taken before the pause.
+// The result's Messages, Usage and Steps cover this call only; a State from a
+// later pause covers the whole run.
+func Resume(ctx context.Context, agent Agent, state RunState, opts ...StepOption) (RunResult, error) {
+	if n := len(state.DeferredResults()); n > 0 {
+		return RunResult{State: &state}, fmt.Errorf("%w: %d remaining", ErrToolPending, n)
+	}
+	var saved []StepOption
+	if state.Model != "" {
+		saved = append(saved, WithModel(state.Model))
+	}
+	if state.ReasoningEffort != "" {
+		saved = append(saved, WithReasoningEffort(state.ReasoningEffort))
+	}
+	opts = append(saved, opts...)
+	cfg := newStepConfig(opts)
+	if state.RunID == "" {
+		state.RunID = NewRunID()
+	}
+
+	history := append([]Message(nil), state.History...)
+	res := RunResult{RunID: state.RunID}
+	if calls := state.PendingToolCalls(); len(calls) > 0 {
+		parentID := ""
+		for i := len(history) - 1; i >= 0; i-- {
+			if m, ok := history[i].(AssistantMessage); ok {
+				parentID = m.ID
+				break
+			}
+		}
+		results := executeTools(ctx, calls, agent.Tools, cfg.toolExec, cfg.stepEmitter, parentID)
+		history = append(history, results...)
+		res.add(results)
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+	}
+	return runLoop(ctx, agent, history, state, res, cfg, opts)
+}
@@ -0,0 +1,169 @@
+package step_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+// countTool counts its executions.
+type countTool struct {
+	name  string
+	calls *int
+	after func()
+}
+
+func (t countTool) Spec() step.ToolSpec { return step.ToolSpec{Name: t.name} }
+
+func (t countTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	*t.calls++
+	if t.after != nil {
+		t.after()
+	}
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "ok"}}}, nil
+}
+
+func TestRun_PauseForApproval(t *testing.T) {
+	var deploys int
+	pauser := &step.Pauser{}
+	agent := step.Agent{
+		Provider: mock.New(withUsage(mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "deploy", ArgsJSON: []byte(`{}`)}), 4)),
+		Tools:    []step.Tool{countTool{name: "deploy", calls: &deploys}},
+	}
+	res, err := step.Run(context.Background(), agent, []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Ship it."}}}},
+		step.WithModel("big"),
+		step.WithPauser(pauser),
+		step.WithOnAfterAssistant(func(m step.AssistantMessage) {
+			pauser.Pause() // every call needs approval
+		}),
+	)
+	if !errors.Is(err, step.ErrPaused) || res.State == nil {
+		t.Fatalf("expected ErrPaused with a state, got %v", err)
+	}
+	if deploys != 0 {
+		t.Fatal("expected the tool call to wait for approval")
+	}
+
+	// Save the state and resume in what could be another process.
+	data, err := json.Marshal(res.State)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state step.RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.History) != 2 || state.Steps != 1 || state.Usage.OutputTokens != 4 || state.Model != "big" {
+		t.Errorf("unexpected state after round trip: %+v", state)
+	}
+	if calls := state.PendingToolCalls(); len(calls) != 1 || calls[0].CallID != "c1" {
+		t.Fatalf("expected c1 to be pending, got %v", calls)
+	}
+
+	provider := mock.New(mock.Text("Deployed."))
+	agent.Provider = provider
+	resumed, err := step.Resume(context.Background(), agent, state)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if deploys != 1 || resumed.Text() != "Deployed." || resumed.Steps != 1 || len(resumed.Messages) != 2 {
+		t.Errorf("expected the approved call to run before the next step, got %d runs and %v", deploys, resumed.Messages)
+	}
+	if provider.Requests()[0].ModelOverride != "big" {
+		t.Error("expected Resume to apply the saved model")
+	}
+	if resumed.RunID == "" || resumed.RunID != res.RunID {
+		t.Errorf("expected the resumed run to keep ID %q, got %q", res.RunID, resumed.RunID)
+	}
+}
+
+func TestRun_PauseRejectedCall(t *testing.T) {
+	var deploys int
+	state := step.RunState{History: []step.Message{
+		step.UserMessage{ID: "u1", Parts: []step.Part{step.TextPart{Text: "Ship it."}}},
+		step.AssistantMessage{ID: "a1", StopReason: step.StopToolUse, Parts: []step.Part{step.ToolCallPart{CallID: "c1", Name: "deploy"}}},
+	}}
+	state.History = append(state.History, step.ToolResultMessage{CallID: "c1", Name: "deploy", IsError: true, Parts: []step.Part{step.TextPart{Text: "rejected by reviewer"}}})
+
+	agent := step.Agent{Provider: mock.New(mock.Text("Understood.")), Tools: []step.Tool{countTool{name: "deploy", calls: &deploys}}}
+	res, err := step.Resume(context.Background(), agent, state)
+	if err != nil || deploys != 0 || res.Text() != "Understood." {
+		t.Errorf("expected the rejected call to stay unexecuted, got %d runs, %q (%v)", deploys, res.Text(), err)
+	}
+}
+
+func TestRun_PauseBetweenSteps(t *testing.T) {
+	var runs int
+	pauser := &step.Pauser{}
+	call := mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "work", ArgsJSON: []byte(`{}`)})
+	agent := step.Agent{
+		Provider: mock.New(call, mock.Text("Finished.")),
+		Tools:    []step.Tool{countTool{name: "work", calls: &runs, after: pauser.Pause}},
+		MaxSteps: 2,
+	}
+	res, err := step.Run(context.Background(), agent, nil, step.WithPauser(pauser))
+	if !errors.Is(err, step.ErrPaused) || runs != 1 || len(res.State.PendingToolCalls()) != 0 {
+		t.Fatalf("expected a pause after the tool ran, got %v after %d runs", err, runs)
+	}
+	resumed, err := step.Resume(context.Background(), agent, *res.State, step.WithPauser(pauser))
+	if err != nil || resumed.Text() != "Finished." {
+		t.Errorf("expected the run to finish within MaxSteps, got %q (%v)", resumed.Text(), err)
+	}
+}
+
+// ciTool starts a CI job and reports its result later.
+type ciTool struct{}
+
+func (ciTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "run_ci"} }
+
+func (ciTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Pending: true, Parts: []step.Part{step.TextPart{Text: "CI job 42 started"}}, Details: map[string]any{"job": 42}}, nil
+}
+
+func TestRun_DeferredToolResult(t *testing.T) {
+	agent := step.Agent{
+		Provider: mock.New(mock.ToolCalls(
+			step.ToolCallPart{CallID: "c1", Name: "run_ci", ArgsJSON: []byte(`{}`)},
+			step.ToolCallPart{CallID: "c2", Name: "lint", ArgsJSON: []byte(`{}`)},
+		)),
+		Tools: []step.Tool{ciTool{}, countTool{name: "lint", calls: new(int)}},
+	}
+	res, err := step.Run(context.Background(), agent, []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Test it."}}}})
+	if !errors.Is(err, step.ErrPaused) {
+		t.Fatalf("expected the run to suspend, got %v", err)
+	}
+	state := *res.State
+	deferred := state.DeferredResults()
+	if len(deferred) != 1 || deferred[0].CallID != "c1" || deferred[0].Details["job"] != 42 {
+		t.Fatalf("expected a placeholder for c1, got %v", deferred)
+	}
+	if _, err := step.Resume(context.Background(), agent, state); !errors.Is(err, step.ErrToolPending) {
+		t.Errorf("expected ErrToolPending before the result arrives, got %v", err)
+	}
+
+	if err := state.CompleteToolCall("c9", step.ToolResult{}); err == nil {
+		t.Error("expected an error for an unknown call")
+	}
+	if err := state.CompleteToolCall("c1", step.ToolResult{Parts: []step.Part{step.TextPart{Text: "all tests passed"}}}); err != nil {
+		t.Fatalf("CompleteToolCall failed: %v", err)
+	}
+	placeholder := deferred[0]
+	completed := state.History[2].(step.ToolResultMessage)
+	if completed.ID != placeholder.ID || completed.Parts[0].(step.TextPart).Text != "all tests passed" || len(state.DeferredResults()) != 0 {
+		t.Errorf("expected the placeholder to be replaced in place, got %#v", completed)
+	}
+
+	provider := mock.New(mock.Text("CI is green."))
+	agent.Provider = provider
+	resumed, err := step.Resume(context.Background(), agent, state)
+	if err != nil || resumed.Text() != "CI is green." {
+		t.Fatalf("expected the run to finish, got %q (%v)", resumed.Text(), err)
+	}
+	if history := provider.Requests()[0].History; len(history) != 4 {
+		t.Errorf("expected the completed results to be sent, got %d messages", len(history))
+	}
+}
@@ -0,0 +1,46 @@
+package step
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ErrDryRunUnsupported is returned by DryRun when the provider cannot build its payload.
+var ErrDryRunUnsupported = errors.New("step: provider does not support dry run")
+
+// Payload is the serialized request a provider sends for one call.
+type Payload struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// URL is the endpoint the request is sent to.
+	URL string `json:"url"`
+	// Headers are request-specific headers such as beta flags and extra headers.
+	// Credentials are never included.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the exact JSON request body, including extra body fields.
+	Body json.RawMessage `json:"body"`
+}
+
+// PayloadBuilder is implemented by providers that can build their request
+// payload without sending it.
+type PayloadBuilder interface {
+	BuildPayload(ctx context.Context, req ProviderRequest) (Payload, error)
+}
+
+// DryRun builds the request the provider would send for req without sending it,
+// for debugging prompt construction and cache breakpoints offline.
+// Options that shape the request, such as WithOnBeforeProviderCall hooks,
+// WithModel and WithEfficientTools, are applied; others have no effect.
+// It returns ErrDryRunUnsupported if the provider does not implement PayloadBuilder.
+func DryRun(ctx context.Context, req StepRequest, opts ...StepOption) (Payload, error) {
+	if req.Provider == nil {
+		return Payload{}, ErrNoProvider
+	}
+	builder, ok := req.Provider.(PayloadBuilder)
+	if !ok {
+		return Payload{}, ErrDryRunUnsupported
+	}
+	providerReq, _ := buildProviderRequest(req, newStepConfig(opts))
+	return builder.BuildPayload(ctx, providerReq)
+}
@@ -0,0 +1,46 @@
+package step_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+// payloadProvider reports the system prompt it was asked to send.
+type payloadProvider struct {
+	step.Provider
+}
+
+func (p payloadProvider) BuildPayload(_ context.Context, req step.ProviderRequest) (step.Payload, error) {
+	body, err := json.Marshal(map[string]any{"system": req.SystemPrompt, "messages": len(req.History)})
+	return step.Payload{Provider: "fake", Model: "fake-model", URL: "https://example.com", Body: body}, err
+}
+
+func TestDryRun(t *testing.T) {
+	req := step.StepRequest{
+		Provider:     payloadProvider{mock.New()},
+		SystemPrompt: "Be brief.",
+		History:      []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}},
+	}
+	payload, err := step.DryRun(context.Background(), req, step.WithOnBeforeProviderCall(func(r *step.ProviderRequest) {
+		r.SystemPrompt += " Today is Monday."
+	}))
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	want := `{"messages":1,"system":"Be brief. Today is Monday."}`
+	if string(payload.Body) != want {
+		t.Errorf("expected body %s, got %s", want, payload.Body)
+	}
+}
+
+func TestDryRunUnsupported(t *testing.T) {
+	_, err := step.DryRun(context.Background(), step.StepRequest{Provider: mock.New()})
+	if !errors.Is(err, step.ErrDryRunUnsupported) {
+		t.Fatalf("expected ErrDryRunUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+// Package policy allows or denies tool calls by their arguments with
+// declarative rules, so safety rules live in config rather than inside each
+// tool:
+//
+//	{
+//	  "rules": [
+//	    {"name": "no-rm-rf", "tool": "bash", "arg": "command", "match": "\\brm\\s+-[a-zA-Z]*(rf|fr)", "effect": "deny"},
+//	    {"tool": "write_file", "arg": "path", "within": ["/workspace"], "effect": "allow"},
+//	    {"tool": "write_file", "effect": "deny", "message": "write_file is restricted to /workspace"}
+//	  ]
+//	}
+//
+// Rules are checked in order and the first matching rule decides; calls no
+// rule matches get the default effect, allow unless set. Wrap tools with
+// Policy.Wrap; denied calls never reach the tool and return an error result
+// naming the rule, so the model can adjust.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/inspirepan/step"
+)
+
+// Effect is the outcome of a rule.
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// Rule matches tool calls by tool name and argument. All conditions that are
+// set must hold for the rule to match.
+type Rule struct {
+	// Name identifies the rule in denials. Defaults to "rule N", counting from 1.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Tool is the tool name, or a glob such as "*" or "mcp_*".
+	Tool string `json:"tool" yaml:"tool"`
+	// Arg selects the argument the conditions apply to, with dots for nested
+	// objects, e.g. "path" or "options.cwd". Empty means the whole arguments
+	// JSON. A rule with conditions does not match calls without the argument.
+	// For array arguments each element is checked: Match matches if any
+	// element does, Within if all elements are within.
+	Arg string `json:"arg,omitempty" yaml:"arg,omitempty"`
+	// Match is a regular expression the argument must match. Non-string
+	// arguments are matched in their JSON form.
+	Match string `json:"match,omitempty" yaml:"match,omitempty"`
+	// Within lists directories the argument, a file path, must be inside.
+	// Paths are compared lexically after cleaning; symlinks are not resolved,
+	// and relative paths are only within relative directories.
+	Within []string `json:"within,omitempty" yaml:"within,omitempty"`
+	Effect Effect   `json:"effect" yaml:"effect"`
+	// Message is added to denials, e.g. to tell the model what is allowed.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// Policy is an ordered list of rules.
+type Policy struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+	// Default applies to calls no rule matches. Defaults to Allow.
+	Default Effect `json:"default,omitempty" yaml:"default,omitempty"`
+
+	once    sync.Once
+	res     []*regexp.Regexp
+	compErr error
+}
+
+// Decision is the outcome of evaluating a call.
+type Decision struct {
+	Effect Effect
+	// Rule is a copy of the matching rule with its Name set, or nil if the
+	// default applied.
+	Rule *Rule
+	// Value is the argument value the rule matched, if it has conditions.
+	Value string
+}
+
+// Load reads a JSON policy file.
+func Load(name string) (*Policy, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return p, nil
+}
+
+// Parse parses and validates a JSON policy. Unknown fields are rejected.
+func Parse(data []byte) (*Policy, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks effects, globs and regular expressions.
+func (p *Policy) Validate() error {
+	p.once.Do(p.compile)
+	return p.compErr
+}
+
+func (p *Policy) compile() {
+	p.res = make([]*regexp.Regexp, len(p.Rules))
+	if p.Default != "" && p.Default != Allow && p.Default != Deny {
+		p.compErr = fmt.Errorf("policy: invalid default effect %q", p.Default)
+		return
+	}
+	for i, r := range p.Rules {
+		if r.Effect != Allow && r.Effect != Deny {
+			p.compErr = fmt.Errorf("policy: %s: invalid effect %q", ruleName(r, i), r.Effect)
+			return
+		}
+		if _, err := path.Match(r.Tool, ""); err != nil {
+			p.compErr = fmt.Errorf("policy: %s: invalid tool pattern %q", ruleName(r, i), r.Tool)
+			return
+		}
+		if r.Match != "" {
+			re, err := regexp.Compile(r.Match)
+			if err != nil {
+				p.compErr = fmt.Errorf("policy: %s: %w", ruleName(r, i), err)
+				return
+			}
+			p.res[i] = re
+		}
+	}
+}
+
+func ruleName(r Rule, i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("rule %d", i+1)
+}
+
+// Evaluate decides call. An invalid policy denies every call.
+func (p *Policy) Evaluate(call step.ToolCallPart) Decision {
+	if err := p.Validate(); err != nil {
+		return Decision{Effect: Deny, Rule: &Rule{Name: "invalid policy", Message: err.Error()}}
+	}
+	var args any
+	if len(call.ArgsJSON) > 0 {
+		// Unparsable arguments match only rules without conditions; the
+		// tool reports the error itself.
+		_ = json.Unmarshal(call.ArgsJSON, &args)
+	}
+	for i := range p.Rules {
+		r := p.Rules[i]
+		r.Name = ruleName(r, i)
+		if ok, _ := path.Match(r.Tool, call.Name); !ok {
+			continue
+		}
+		if r.Match == "" && len(r.Within) == 0 {
+			return Decision{Effect: r.Effect, Rule: &r}
+		}
+		values, ok := argValues(args, call.ArgsJSON, r.Arg)
+		if !ok {
+			continue
+		}
+		if value, ok := p.matches(i, values); ok {
+			return Decision{Effect: r.Effect, Rule: &r, Value: value}
+		}
+	}
+	if p.Default == Deny {
+		return Decision{Effect: Deny}
+	}
+	return Decision{Effect: Allow}
+}
+
+// matches reports whether the conditions of rule i hold for values,
+// returning the value that decided it.
+func (p *Policy) matches(i int, values []string) (string, bool) {
+	r := p.Rules[i]
+	decided := strings.Join(values, ", ")
+	if re := p.res[i]; re != nil {
+		hit := false
+		for _, v := range values {
+			if re.MatchString(v) {
+				hit, decided = true, v
+				break
+			}
+		}
+		if !hit {
+			return "", false
+		}
+	}
+	for _, v := range values {
+		if !withinAny(v, r.Within) {
+			return "", false
+		}
+	}
+	return decided, true
+}
+
+// argValues returns the argument at the dotted path key as strings.
+func argValues(args any, raw json.RawMessage, key string) ([]string, bool) {
+	if key == "" {
+		return []string{string(raw)}, len(raw) > 0
+	}
+	v := args
+	for _, field := range strings.Split(key, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[field]; !ok {
+			return nil, false
+		}
+	}
+	if list, ok := v.([]any); ok {
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			out = append(out, stringify(item))
+		}
+		return out, true
+	}
+	return []string{stringify(v)}, true
+}
+
+func stringify(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func withinAny(p string, dirs []string) bool {
+	if len(dirs) == 0 {
+		return true
+	}
+	p = filepath.Clean(p)
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if filepath.IsAbs(p) != filepath.IsAbs(dir) {
+			continue
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Denial returns the error result for a denied call, naming the rule and the
+// matched argument. Details hold the decision under "policy".
+func (d Decision) Denial(call step.ToolCallPart) step.ToolResult {
+	var sb strings.Builder
+	details := map[string]any{"effect": string(d.Effect)}
+	if d.Rule == nil {
+		sb.WriteString("Denied by policy: no rule allows " + call.Name + ".")
+	} else {
+		name := d.Rule.Name
+		fmt.Fprintf(&sb, "Denied by policy rule %q", name)
+		details["rule"] = name
+		if d.Value != "" {
+			arg := d.Rule.Arg
+			if arg == "" {
+				arg = "arguments"
+			}
+			fmt.Fprintf(&sb, ": %s %q", arg, d.Value)
+			details["arg"], details["value"] = arg, d.Value
+			if d.Rule.Match != "" {
+				fmt.Fprintf(&sb, " matches %q", d.Rule.Match)
+			}
+		}
+		sb.WriteString(".")
+		if d.Rule.Message != "" {
+			sb.WriteString(" " + d.Rule.Message)
+		}
+	}
+	return step.ToolResult{
+		CallID:  call.CallID,
+		Name:    call.Name,
+		IsError: true,
+		Parts:   []step.Part{step.TextPart{Text: sb.String()}},
+		Details: map[string]any{"policy": details},
+	}
+}
+
+// Wrap returns tools with every call checked against p before it runs.
+func (p *Policy) Wrap(tools []step.Tool) []step.Tool {
+	out := make([]step.Tool, len(tools))
+	for i, t := range tools {
+		out[i] = p.Tool(t)
+	}
+	return out
+}
+
+// Tool returns t with every call checked against p before it runs.
+func (p *Policy) Tool(t step.Tool) step.Tool {
+	return policyTool{Tool: t, policy: p}
+}
+
+type policyTool struct {
+	step.Tool
+	policy *Policy
+}
+
+func (t policyTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	if d := t.policy.Evaluate(call); d.Effect == Deny {
+		return d.Denial(call), nil
+	}
+	return t.Tool.Execute(ctx, call)
+}
@@ -0,0 +1,96 @@
+package policy_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/policy"
+)
+
+const rules = `{
+  "rules": [
+    {"name": "no-rm-rf", "tool": "bash", "arg": "command", "match": "\\brm\\s+-[a-zA-Z]*(rf|fr)", "effect": "deny"},
+    {"tool": "write_file", "arg": "path", "within": ["/workspace"], "effect": "allow"},
+    {"tool": "write_file", "effect": "deny", "message": "write_file is restricted to /workspace."},
+    {"tool": "mcp_*", "arg": "urls", "match": "^http://", "effect": "deny"}
+  ]
+}`
+
+type recordTool struct {
+	name string
+	ran  *int
+}
+
+func (t recordTool) Spec() step.ToolSpec { return step.ToolSpec{Name: t.name} }
+
+func (t recordTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	*t.ran++
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "ok"}}}, nil
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	p, err := policy.Parse([]byte(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tool, args string
+		want       policy.Effect
+		rule       string
+	}{
+		{"bash", `{"command": "ls -la"}`, policy.Allow, ""},
+		{"bash", `{"command": "cd / && rm -rf *"}`, policy.Deny, "no-rm-rf"},
+		{"write_file", `{"path": "/workspace/src/main.go"}`, policy.Allow, "rule 2"},
+		{"write_file", `{"path": "/workspace/../etc/passwd"}`, policy.Deny, "rule 3"},
+		{"write_file", `{}`, policy.Deny, "rule 3"},
+		{"mcp_fetch", `{"urls": ["https://a.example", "http://b.example"]}`, policy.Deny, "rule 4"},
+		{"mcp_fetch", `{"urls": ["https://a.example"]}`, policy.Allow, ""},
+	}
+	for _, tt := range tests {
+		d := p.Evaluate(step.ToolCallPart{Name: tt.tool, ArgsJSON: []byte(tt.args)})
+		rule := ""
+		if d.Rule != nil {
+			rule = d.Rule.Name
+		}
+		if d.Effect != tt.want || rule != tt.rule {
+			t.Errorf("%s %s: got %s by %q, want %s by %q", tt.tool, tt.args, d.Effect, rule, tt.want, tt.rule)
+		}
+	}
+}
+
+func TestPolicy_Wrap(t *testing.T) {
+	p, err := policy.Parse([]byte(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := 0
+	tool := p.Tool(recordTool{"bash", &ran})
+	res, err := tool.Execute(context.Background(), step.ToolCallPart{CallID: "c1", Name: "bash", ArgsJSON: []byte(`{"command": "rm -fr /"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := res.Parts[0].(step.TextPart).Text
+	if ran != 0 || !res.IsError || !strings.Contains(text, `"no-rm-rf"`) || !strings.Contains(text, `"rm -fr /"`) {
+		t.Errorf("expected a denial naming the rule, got %q (tool ran %d times)", text, ran)
+	}
+	if res.Details["policy"].(map[string]any)["rule"] != "no-rm-rf" {
+		t.Errorf("unexpected details: %v", res.Details)
+	}
+	if _, err := tool.Execute(context.Background(), step.ToolCallPart{Name: "bash", ArgsJSON: []byte(`{"command": "ls"}`)}); err != nil || ran != 1 {
+		t.Errorf("expected an allowed call to run, got %v", err)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`{"rules": [{"tool": "bash", "effect": "maybe"}]}`,
+		`{"rules": [{"tool": "bash", "match": "(", "effect": "deny"}]}`,
+		`{"rules": [{"tool": "bash", "effect": "deny", "typo": 1}]}`,
+	} {
+		if _, err := policy.Parse([]byte(data)); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
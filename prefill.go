@@ -0,0 +1,69 @@
+package step
+
+// Prefill returns the trailing partial assistant message of history, if any.
+//
+// A prefill is an AssistantMessage placed last in the history with no
+// StopReason and no tool calls. Providers that support it send it as a
+// response prefix (Anthropic prefill, OpenRouter/DeepSeek assistant prefix)
+// so the model continues from its text; use it only with those providers.
+// Step merges the prefill with the continuation: the resulting AssistantMessage
+// holds the complete text and keeps the prefill's ID, so it replaces the
+// prefill in the history.
+func Prefill(history []Message) (AssistantMessage, bool) {
+	if len(history) == 0 {
+		return AssistantMessage{}, false
+	}
+	var m AssistantMessage
+	switch v := history[len(history)-1].(type) {
+	case AssistantMessage:
+		m = v
+	case *AssistantMessage:
+		m = *v
+	default:
+		return AssistantMessage{}, false
+	}
+	if m.StopReason != "" || len(extractToolCalls(m)) > 0 {
+		return AssistantMessage{}, false
+	}
+	return m, true
+}
+
+// mergePrefill prepends the prefill text to the continuation's first text part.
+// Thinking that precedes the text stays in front of it.
+func mergePrefill(prefill, msg AssistantMessage) AssistantMessage {
+	if prefill.ID != "" {
+		msg.ID = prefill.ID
+	}
+	if prefill.ParentID != "" {
+		msg.ParentID = prefill.ParentID
+	}
+	text := prefill.Text()
+	if text == "" {
+		return msg
+	}
+
+	parts := make([]Part, 0, len(msg.Parts)+1)
+	merged := false
+	for _, part := range msg.Parts {
+		if !merged {
+			switch p := part.(type) {
+			case ThinkingPart, *ThinkingPart:
+			case TextPart:
+				part = TextPart{Text: text + p.Text}
+				merged = true
+			case *TextPart:
+				part = TextPart{Text: text + p.Text}
+				merged = true
+			default:
+				parts = append(parts, TextPart{Text: text})
+				merged = true
+			}
+		}
+		parts = append(parts, part)
+	}
+	if !merged {
+		parts = append(parts, TextPart{Text: text})
+	}
+	msg.Parts = parts
+	return msg
+}
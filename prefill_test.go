@@ -0,0 +1,64 @@
+package step_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestStepPrefill(t *testing.T) {
+	provider := mock.New(mock.Response{Message: step.AssistantMessage{
+		Parts: []step.Part{
+			step.ThinkingPart{Thinking: "colors"},
+			step.TextPart{Text: `"red", "green", "blue"]`},
+		},
+		StopReason: step.StopStop,
+	}})
+	history := step.AssignIDs([]step.Message{
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "List the primary colors as a JSON array."}}},
+		step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "["}}},
+	})
+
+	var streamed string
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history},
+		step.WithOnDelta(func(d step.MessageDelta) {
+			if td, ok := d.(step.TextDelta); ok {
+				streamed += td.Delta
+			}
+		}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+
+	msg := result[0].(step.AssistantMessage)
+	want := `["red", "green", "blue"]`
+	if msg.Text() != want {
+		t.Errorf("expected merged text %q, got %q", want, msg.Text())
+	}
+	if _, ok := msg.Parts[0].(step.ThinkingPart); !ok {
+		t.Errorf("expected thinking to stay first, got %T", msg.Parts[0])
+	}
+	if streamed != want {
+		t.Errorf("expected streamed text %q, got %q", want, streamed)
+	}
+	if msg.ID != step.MessageID(history[1]) || msg.ParentID != step.MessageID(history[0]) {
+		t.Errorf("expected merged message to replace the prefill, got id=%s parent=%s", msg.ID, msg.ParentID)
+	}
+}
+
+func TestPrefill(t *testing.T) {
+	user := step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}
+	done := step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "hello"}}, StopReason: step.StopStop}
+	if _, ok := step.Prefill([]step.Message{user, done}); ok {
+		t.Error("a completed assistant message is not a prefill")
+	}
+	if _, ok := step.Prefill([]step.Message{user}); ok {
+		t.Error("a user message is not a prefill")
+	}
+	partial := step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "Hel"}}}
+	if m, ok := step.Prefill([]step.Message{user, &partial}); !ok || m.Text() != "Hel" {
+		t.Errorf("expected prefill Hel, got %v %v", m, ok)
+	}
+}
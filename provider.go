@@ -1,16 +1,55 @@
 package step
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 // ProviderRequest is the provider-agnostic generation input.
 type ProviderRequest struct {
 	SystemPrompt string
 	History      []Message
 	Tools        []ToolSpec
+
+	// N is the number of candidate responses to generate; 0 means 1.
+	// Providers that support it emit one ProviderMessageUpdate per candidate
+	// and stream deltas for candidate 0 only. Others ignore it.
+	N int
+
+	// Raw asks providers to emit a ProviderRawUpdate for every chunk they
+	// receive; see WithOnRawChunk. Providers that do not support it ignore it.
+	Raw bool
+
+	// ModelOverride, if set, replaces the provider's configured model for
+	// this request, so one provider instance can serve several models; see
+	// WithModel. Providers whose clients are bound to a model ignore it.
+	ModelOverride string
+
+	// ReasoningEffort, if set, replaces the provider's configured reasoning
+	// effort or thinking budget for this request; see WithReasoningEffort.
+	ReasoningEffort ReasoningEffort
+
+	// EfficientTools asks for the provider's token-efficient tool use where
+	// it has one; see WithEfficientTools. Others ignore it.
+	EfficientTools bool
 }
 
+// ReasoningEffort is a provider-neutral reasoning effort level. Providers map
+// it to an effort parameter or a thinking token budget.
+type ReasoningEffort string
+
+const (
+	// ReasoningEffortNone disables thinking where the model allows it.
+	ReasoningEffortNone    ReasoningEffort = "none"
+	ReasoningEffortMinimal ReasoningEffort = "minimal"
+	ReasoningEffortLow     ReasoningEffort = "low"
+	ReasoningEffortMedium  ReasoningEffort = "medium"
+	ReasoningEffortHigh    ReasoningEffort = "high"
+)
+
 // ProviderUpdate is the union-style streaming output from providers.
-// It is either a ProviderDeltaUpdate or a ProviderMessageUpdate.
+// It is a ProviderDeltaUpdate, a ProviderMessageUpdate or, when requested,
+// a ProviderRawUpdate.
 type ProviderUpdate interface {
 	isProviderUpdate()
 }
@@ -25,10 +64,24 @@ func (ProviderDeltaUpdate) isProviderUpdate() {}
 // ProviderMessageUpdate emits the final assistant message.
 type ProviderMessageUpdate struct {
 	Message AssistantMessage
+	// Candidate is the candidate index when ProviderRequest.N > 1.
+	Candidate int
 }
 
 func (ProviderMessageUpdate) isProviderUpdate() {}
 
+// ProviderRawUpdate carries one chunk as the provider sent it, for consumers
+// that need provider-specific fields not modeled by step. It is emitted only
+// when ProviderRequest.Raw is set, before the updates produced from the chunk.
+type ProviderRawUpdate struct {
+	// Provider is the provider name, e.g. "anthropic".
+	Provider string
+	// Data is the chunk payload, typically the data of one SSE event.
+	Data json.RawMessage
+}
+
+func (ProviderRawUpdate) isProviderUpdate() {}
+
 // ProviderStream is the unified provider stream.
 // It emits ProviderUpdate values until io.EOF.
 type ProviderStream interface {
@@ -40,3 +93,9 @@ type ProviderStream interface {
 type Provider interface {
 	Stream(ctx context.Context, req ProviderRequest) (ProviderStream, error)
 }
+
+// Pinger is implemented by providers that can cheaply verify their credentials
+// and endpoint without generating. See the health package.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,311 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/anthropic"
+	"github.com/inspirepan/step/providers/base"
+	"github.com/inspirepan/step/testkit"
+)
+
+const (
+	envKey = "ANTHROPIC_API_KEY"
+	model  = "claude-haiku-4-5"
+)
+
+func TestAnthropic_Conformance(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	testkit.RunConformanceSuite(t, anthropic.New(model))
+}
+
+func TestAnthropic_ThinkingRoundTrip(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := anthropic.New(model, anthropic.WithThinking(2048))
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestThinkingRoundTrip(t, cfg)
+}
+
+func TestAnthropic_CacheUsage(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := anthropic.New(model, anthropic.WithCacheStrategy(base.DefaultCacheStrategy()))
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// The cacheable prefix must exceed the model's minimum cacheable length.
+	system := "You are a terse assistant.\n"
+	for range 1000 {
+		system += "Always answer in as few words as possible. "
+	}
+	req := step.StepRequest{
+		Provider:     provider,
+		SystemPrompt: system,
+		History:      []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Say hi."}}}},
+	}
+
+	var usage step.Usage
+	for range 2 {
+		result, err := step.Step(ctx, req)
+		if err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+		for _, msg := range result {
+			if am, ok := msg.(step.AssistantMessage); ok {
+				usage.Add(am.Usage)
+			}
+		}
+	}
+	if usage.CacheWriteTokens == 0 && usage.CachedReadTokens == 0 {
+		t.Errorf("expected cache read or write tokens, got %+v", usage)
+	}
+	t.Logf("usage: %+v", usage)
+}
+
+func TestAnthropic_InterleavedThinkingToolCalling(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := anthropic.New(model,
+		anthropic.WithThinking(2048),
+		anthropic.WithInterleavedThinking(),
+		anthropic.WithFineGrainedToolStreaming(),
+	)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestToolCalling(t, cfg)
+	testkit.TestParallelToolCalls(t, cfg)
+}
+
+func TestAnthropic_Prefill(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	history := []step.Message{
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "List the three primary colors of light as a JSON array of lowercase strings."}}},
+		step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: `["`}}},
+	}
+	result, err := step.Step(ctx, step.StepRequest{Provider: anthropic.New(model, anthropic.WithJSONMode()), History: history})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	text := result[0].(step.AssistantMessage).Text()
+	if !strings.HasPrefix(text, `["`) || !strings.Contains(text, "red") {
+		t.Errorf("expected the continuation to be merged with the prefill, got %q", text)
+	}
+}
+
+func TestAnthropic_DryRun(t *testing.T) {
+	provider := anthropic.New(model, anthropic.WithAPIKey("test"), anthropic.WithBaseURL("https://proxy.example.com/"), anthropic.WithInterleavedThinking())
+	payload, err := step.DryRun(context.Background(), step.StepRequest{
+		Provider:     provider,
+		SystemPrompt: "Be brief.",
+		History:      []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}},
+	})
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if payload.URL != "https://proxy.example.com/v1/messages" {
+		t.Errorf("unexpected URL %q", payload.URL)
+	}
+	if payload.Headers["anthropic-beta"] != anthropic.BetaInterleavedThinking {
+		t.Errorf("expected beta header, got %v", payload.Headers)
+	}
+	body := string(payload.Body)
+	for _, want := range []string{`"stream":true`, `"cache_control"`, `"model":"` + model + `"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in body: %s", want, body)
+		}
+	}
+}
+
+func TestAnthropic_BetaFeatures(t *testing.T) {
+	provider := anthropic.New(model, anthropic.WithAPIKey("test"), anthropic.WithInterleavedThinking(),
+		anthropic.WithBetaFeatures(anthropic.BetaContext1M, anthropic.BetaInterleavedThinking))
+	payload, err := step.DryRun(context.Background(), step.StepRequest{Provider: provider})
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if want := anthropic.BetaInterleavedThinking + "," + anthropic.BetaContext1M; payload.Headers["anthropic-beta"] != want {
+		t.Errorf("beta header %q, want %q", payload.Headers["anthropic-beta"], want)
+	}
+
+	payload, err = step.DryRun(context.Background(), step.StepRequest{Provider: provider}, step.WithEfficientTools())
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if want := anthropic.BetaInterleavedThinking + "," + anthropic.BetaContext1M + "," + anthropic.BetaTokenEfficientTools; payload.Headers["anthropic-beta"] != want {
+		t.Errorf("beta header %q with efficient tools, want %q", payload.Headers["anthropic-beta"], want)
+	}
+}
+
+// TestAnthropic_SplitMultibyteChunks serves an SSE stream in 3-byte writes, so
+// multibyte characters arrive split across network chunks.
+func TestAnthropic_SplitMultibyteChunks(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"` + model + `","content":[],"usage":{"input_tokens":3,"output_tokens":0}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"héllo 日本"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"語 😀"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":6}}`,
+		`{"type":"message_stop"}`,
+	}
+	var sse strings.Builder
+	for _, e := range events {
+		var head struct{ Type string }
+		_ = json.Unmarshal([]byte(e), &head)
+		sse.WriteString("event: " + head.Type + "\ndata: " + e + "\n\n")
+	}
+	body := sse.String()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < len(body); i += 3 {
+			_, _ = io.WriteString(w, body[i:min(i+3, len(body))])
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	var deltas strings.Builder
+	provider := anthropic.New(model, anthropic.WithAPIKey("test"), anthropic.WithBaseURL(server.URL))
+	result, err := step.Step(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}},
+	}, step.WithOnDelta(func(d step.MessageDelta) {
+		if td, ok := d.(step.TextDelta); ok {
+			if !utf8.ValidString(td.Delta) {
+				t.Errorf("invalid UTF-8 delta %q", td.Delta)
+			}
+			deltas.WriteString(td.Delta)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	const want = "héllo 日本語 😀"
+	if got := result[0].(step.AssistantMessage).Text(); got != want {
+		t.Errorf("accumulated %q, want %q", got, want)
+	}
+	if deltas.String() != want {
+		t.Errorf("deltas joined to %q, want %q", deltas.String(), want)
+	}
+}
+
+func TestAnthropic_IdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"` + model + `","content":[],"usage":{"input_tokens":3,"output_tokens":0}}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":0}}`,
+			`{"type":"message_stop"}`,
+		} {
+			var head struct{ Type string }
+			_ = json.Unmarshal([]byte(e), &head)
+			io.WriteString(w, "event: "+head.Type+"\ndata: "+e+"\n\n")
+		}
+	}))
+	defer server.Close()
+
+	req := step.StepRequest{
+		Provider: anthropic.New(model, anthropic.WithAPIKey("test"), anthropic.WithBaseURL(server.URL)),
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+	}
+	for range 2 {
+		if _, err := step.Step(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] == keys[1] {
+		t.Errorf("keys = %q, want a distinct key per step", keys)
+	}
+}
+
+func TestAnthropic_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("after_id") == "" {
+			io.WriteString(w, `{"data":[{"id":"claude-b","type":"model","display_name":"Claude B","created_at":"2025-10-01T00:00:00Z"}],"has_more":true,"first_id":"claude-b","last_id":"claude-b"}`)
+			return
+		}
+		io.WriteString(w, `{"data":[{"id":"claude-a","type":"model","display_name":"Claude A","created_at":"2025-01-01T00:00:00Z"}],"has_more":false,"first_id":"claude-a","last_id":"claude-a"}`)
+	}))
+	defer server.Close()
+
+	models, err := step.ListModels(context.Background(), anthropic.New(model, anthropic.WithAPIKey("test"), anthropic.WithBaseURL(server.URL)))
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if len(models) != 2 || models[0].Name != "Claude B" || models[1].ID != "claude-a" || models[1].Created.Year() != 2025 {
+		t.Fatalf("unexpected models %+v", models)
+	}
+	if models[0].ContextWindow != 200_000 {
+		t.Errorf("context window %d, want 200k", models[0].ContextWindow)
+	}
+}
+
+func TestAnthropic_Context1M(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data":[{"id":"claude-sonnet-4-5","type":"model","display_name":"Claude Sonnet 4.5","created_at":"2025-09-29T00:00:00Z"},{"id":"claude-haiku-4-5","type":"model","display_name":"Claude Haiku 4.5","created_at":"2025-10-01T00:00:00Z"}],"has_more":false}`)
+	}))
+	defer server.Close()
+
+	provider := anthropic.New(model, anthropic.WithAPIKey("test"), anthropic.WithBaseURL(server.URL), anthropic.WithContext1M())
+	models, err := step.ListModels(context.Background(), provider)
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if len(models) != 2 || models[0].ContextWindow != 1_000_000 || models[1].ContextWindow != 200_000 {
+		t.Errorf("unexpected context windows %+v", models)
+	}
+	payload, err := step.DryRun(context.Background(), step.StepRequest{Provider: provider})
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if payload.Headers["anthropic-beta"] != anthropic.BetaContext1M {
+		t.Errorf("beta header %q", payload.Headers["anthropic-beta"])
+	}
+}
+
+func TestAnthropic_ReasoningEffort(t *testing.T) {
+	provider := anthropic.New(model, anthropic.WithAPIKey("test"), anthropic.WithThinking(2048))
+	req := step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}},
+	}
+	tests := []struct {
+		effort step.ReasoningEffort
+		want   string
+	}{
+		{"", `"budget_tokens":2048`},
+		{step.ReasoningEffortMinimal, `"budget_tokens":1024`},
+		{step.ReasoningEffortHigh, `"budget_tokens":32000`},
+		{step.ReasoningEffortNone, ""},
+	}
+	for _, tt := range tests {
+		payload, err := step.DryRun(context.Background(), req, step.WithReasoningEffort(tt.effort))
+		if err != nil {
+			t.Fatalf("DryRun failed: %v", err)
+		}
+		body := string(payload.Body)
+		if tt.want == "" && strings.Contains(body, `"thinking"`) {
+			t.Errorf("%q: expected thinking to be disabled: %s", tt.effort, body)
+		}
+		if tt.want != "" && !strings.Contains(body, tt.want) {
+			t.Errorf("%q: expected %s in body: %s", tt.effort, tt.want, body)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+// redactedThinkingFormat marks a ThinkingPart that carries a redacted_thinking block.
+// The encrypted payload is stored in Signature.
+const redactedThinkingFormat = "anthropic-redacted"
+
+// BuildParams converts a step request to Anthropic Messages API params.
+// Model, MaxTokens and sampling options are left to the caller.
+// A trailing prefill (see step.Prefill) is sent as the final assistant turn.
+func BuildParams(req step.ProviderRequest, targetModel string, cache base.CacheStrategy) anthropic.MessageNewParams {
+	params := anthropic.MessageNewParams{}
+	cacheControl := cacheControlParam(cache)
+
+	cacheSystem := cache.Enabled() && cache.System && req.SystemPrompt != ""
+	if req.SystemPrompt != "" {
+		block := anthropic.TextBlockParam{Text: req.SystemPrompt}
+		if cacheSystem {
+			block.CacheControl = cacheControl
+		}
+		params.System = []anthropic.TextBlockParam{block}
+	}
+
+	history := req.History
+	prefill, hasPrefill := step.Prefill(history)
+	if hasPrefill {
+		history = history[:len(history)-1]
+	}
+	for _, msg := range history {
+		switch m := msg.(type) {
+		case step.UserMessage:
+			params.Messages = appendUser(params.Messages, convertUserMessage(m))
+		case *step.UserMessage:
+			params.Messages = appendUser(params.Messages, convertUserMessage(*m))
+		case step.AssistantMessage:
+			params.Messages = append(params.Messages, convertAssistantMessage(m, targetModel))
+		case *step.AssistantMessage:
+			params.Messages = append(params.Messages, convertAssistantMessage(*m, targetModel))
+		case step.ToolMessage:
+			params.Messages = appendUser(params.Messages, convertToolMessage(m))
+		case *step.ToolMessage:
+			params.Messages = appendUser(params.Messages, convertToolMessage(*m))
+		}
+	}
+
+	// The API rejects a final assistant turn that ends with whitespace.
+	if text := strings.TrimRightFunc(prefill.Text(), unicode.IsSpace); hasPrefill && text != "" {
+		params.Messages = append(params.Messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(text)))
+	}
+
+	for _, tool := range req.Tools {
+		params.Tools = append(params.Tools, convertToolSpec(tool))
+	}
+	cacheTools := cache.Enabled() && cache.Tools && len(params.Tools) > 0
+	if cacheTools {
+		params.Tools[len(params.Tools)-1].OfTool.CacheControl = cacheControl
+	}
+
+	if n := cache.MessageBreakpoints(cacheSystem, cacheTools); n > 0 {
+		addCacheControlToLastMessages(params.Messages, n, cacheControl)
+	}
+
+	return params
+}
+
+func cacheControlParam(cache base.CacheStrategy) anthropic.CacheControlEphemeralParam {
+	cc := anthropic.NewCacheControlEphemeralParam()
+	if cache.TTL != base.CacheTTLDefault {
+		cc.TTL = anthropic.CacheControlEphemeralTTL(cache.TTL)
+	}
+	return cc
+}
+
+// appendUser appends user content, merging it into a preceding user turn.
+// Tool results for one assistant turn must all be sent in a single user message.
+func appendUser(messages []anthropic.MessageParam, blocks []anthropic.ContentBlockParamUnion) []anthropic.MessageParam {
+	if n := len(messages); n > 0 && messages[n-1].Role == anthropic.MessageParamRoleUser {
+		messages[n-1].Content = append(messages[n-1].Content, blocks...)
+		return messages
+	}
+	return append(messages, anthropic.NewUserMessage(blocks...))
+}
+
+// addCacheControlToLastMessages marks the last content block of the last n user messages.
+func addCacheControlToLastMessages(messages []anthropic.MessageParam, n int, cc anthropic.CacheControlEphemeralParam) {
+	for i := len(messages) - 1; i >= 0 && n > 0; i-- {
+		msg := &messages[i]
+		if msg.Role != anthropic.MessageParamRoleUser || len(msg.Content) == 0 {
+			continue
+		}
+		if setCacheControl(&msg.Content[len(msg.Content)-1], cc) {
+			n--
+		}
+	}
+}
+
+func setCacheControl(block *anthropic.ContentBlockParamUnion, cc anthropic.CacheControlEphemeralParam) bool {
+	switch {
+	case block.OfText != nil:
+		block.OfText.CacheControl = cc
+	case block.OfImage != nil:
+		block.OfImage.CacheControl = cc
+	case block.OfToolResult != nil:
+		block.OfToolResult.CacheControl = cc
+	case block.OfToolUse != nil:
+		block.OfToolUse.CacheControl = cc
+	default:
+		return false
+	}
+	return true
+}
+
+func convertUserMessage(m step.UserMessage) []anthropic.ContentBlockParamUnion {
+	var blocks []anthropic.ContentBlockParamUnion
+	for _, part := range m.Parts {
+		switch p := part.(type) {
+		case step.TextPart:
+			blocks = append(blocks, anthropic.NewTextBlock(p.Text))
+		case *step.TextPart:
+			blocks = append(blocks, anthropic.NewTextBlock(p.Text))
+		case step.ImagePart:
+			blocks = append(blocks, anthropic.NewImageBlockBase64(p.MimeType, p.DataB64))
+		case *step.ImagePart:
+			blocks = append(blocks, anthropic.NewImageBlockBase64(p.MimeType, p.DataB64))
+		}
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, anthropic.NewTextBlock("."))
+	}
+	return blocks
+}
+
+func convertAssistantMessage(m step.AssistantMessage, targetModel string) anthropic.MessageParam {
+	// Thinking without a model name is attributed to the model that produced the message.
+	sourceModel := ""
+	if m.Provenance != nil {
+		sourceModel = m.Provenance.Model
+	}
+
+	var blocks []anthropic.ContentBlockParamUnion
+	for _, part := range m.Parts {
+		switch p := part.(type) {
+		case step.TextPart:
+			if p.Text != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(p.Text))
+			}
+		case *step.TextPart:
+			if p.Text != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(p.Text))
+			}
+		case step.ThinkingPart:
+			blocks = appendThinking(blocks, p, sourceModel, targetModel)
+		case *step.ThinkingPart:
+			blocks = appendThinking(blocks, *p, sourceModel, targetModel)
+		case step.ToolCallPart:
+			blocks = append(blocks, convertToolCallPart(p))
+		case *step.ToolCallPart:
+			blocks = append(blocks, convertToolCallPart(*p))
+		}
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, anthropic.NewTextBlock("."))
+	}
+	return anthropic.NewAssistantMessage(blocks...)
+}
+
+// appendThinking replays signed thinking from the same model and degrades
+// anything else to plain text so the context is not lost.
+func appendThinking(blocks []anthropic.ContentBlockParamUnion, p step.ThinkingPart, sourceModel, targetModel string) []anthropic.ContentBlockParamUnion {
+	if p.ModelName == "" {
+		p.ModelName = sourceModel
+	}
+	sameModel := p.ModelName == "" || p.ModelName == targetModel
+	if sameModel && p.Signature != "" {
+		if p.Format == redactedThinkingFormat {
+			return append(blocks, anthropic.NewRedactedThinkingBlock(p.Signature))
+		}
+		return append(blocks, anthropic.NewThinkingBlock(p.Signature, p.Thinking))
+	}
+	if p.Thinking == "" {
+		return blocks
+	}
+	return append(blocks, anthropic.NewTextBlock("<thinking>\n"+p.Thinking+"\n</thinking>\n"))
+}
+
+func convertToolCallPart(p step.ToolCallPart) anthropic.ContentBlockParamUnion {
+	var input any = json.RawMessage(p.ArgsJSON)
+	if len(p.ArgsJSON) == 0 || !json.Valid(p.ArgsJSON) {
+		input = map[string]any{}
+	}
+	return anthropic.NewToolUseBlock(p.CallID, input, p.Name)
+}
+
+func convertToolMessage(m step.ToolMessage) []anthropic.ContentBlockParamUnion {
+	result := anthropic.ToolResultBlockParam{ToolUseID: m.CallID}
+	if m.IsError {
+		result.IsError = anthropic.Bool(true)
+	}
+	for _, part := range m.Parts {
+		switch p := part.(type) {
+		case step.TextPart:
+			result.Content = append(result.Content, anthropic.ToolResultBlockParamContentUnion{OfText: &anthropic.TextBlockParam{Text: p.Text}})
+		case *step.TextPart:
+			result.Content = append(result.Content, anthropic.ToolResultBlockParamContentUnion{OfText: &anthropic.TextBlockParam{Text: p.Text}})
+		case step.ImagePart:
+			result.Content = append(result.Content, toolResultImage(p))
+		case *step.ImagePart:
+			result.Content = append(result.Content, toolResultImage(*p))
+		}
+	}
+	if len(result.Content) == 0 {
+		result.Content = append(result.Content, anthropic.ToolResultBlockParamContentUnion{OfText: &anthropic.TextBlockParam{
+			Text: "<system-reminder>Tool ran without output or errors</system-reminder>",
+		}})
+	}
+	return []anthropic.ContentBlockParamUnion{{OfToolResult: &result}}
+}
+
+func toolResultImage(p step.ImagePart) anthropic.ToolResultBlockParamContentUnion {
+	return anthropic.ToolResultBlockParamContentUnion{OfImage: anthropic.NewImageBlockBase64(p.MimeType, p.DataB64).OfImage}
+}
+
+func convertToolSpec(spec step.ToolSpec) anthropic.ToolUnionParam {
+	schema := anthropic.ToolInputSchemaParam{}
+	for k, v := range spec.Parameters {
+		switch k {
+		case "type":
+		case "properties":
+			schema.Properties = v
+		case "required":
+			schema.Required = toStrings(v)
+		default:
+			if schema.ExtraFields == nil {
+				schema.ExtraFields = make(map[string]any)
+			}
+			schema.ExtraFields[k] = v
+		}
+	}
+	tool := anthropic.ToolParam{Name: spec.Name, InputSchema: schema}
+	if spec.Description != "" {
+		tool.Description = anthropic.String(spec.Description)
+	}
+	return anthropic.ToolUnionParam{OfTool: &tool}
+}
+
+func toStrings(v any) []string {
+	switch vs := v.(type) {
+	case []string:
+		return vs
+	case []any:
+		out := make([]string, 0, len(vs))
+		for _, s := range vs {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
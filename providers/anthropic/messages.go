@@ -2,7 +2,9 @@ package anthropic
 
 import (
 	"context"
-	"errors"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -17,6 +19,30 @@ type Config struct {
 	// Thinking options
 	ThinkingEnabled bool
 	ThinkingBudget  *int
+
+	// Betas are sent in the anthropic-beta header; see WithBetaFeatures.
+	Betas []string
+}
+
+// Beta feature names for the anthropic-beta header.
+const (
+	BetaInterleavedThinking      = "interleaved-thinking-2025-05-14"
+	BetaFineGrainedToolStreaming = "fine-grained-tool-streaming-2025-05-14"
+	BetaContext1M                = "context-1m-2025-08-07"
+	// BetaTokenEfficientTools reduces the output tokens of tool calls on
+	// Claude 3.7 Sonnet; Claude 4 models do so without it. It is sent for
+	// steps run with step.WithEfficientTools.
+	BetaTokenEfficientTools = "token-efficient-tools-2025-02-19"
+)
+
+// betaHeader returns the anthropic-beta header value for req, or "" for
+// none. step.WithEfficientTools adds BetaTokenEfficientTools.
+func (c Config) betaHeader(req step.ProviderRequest) string {
+	betas := c.Betas
+	if req.EfficientTools && !slices.Contains(betas, BetaTokenEfficientTools) {
+		betas = append(slices.Clip(betas), BetaTokenEfficientTools)
+	}
+	return strings.Join(betas, ",")
 }
 
 // Option is a functional option for this provider.
@@ -27,6 +53,18 @@ func WithAPIKey(key string) Option {
 	return func(c *Config) { c.APIKey = key }
 }
 
+// WithAPIKeys spreads requests over several API keys, moving to the next key
+// when one is rejected (401) or rate limited (429). See base.KeyRotation.
+func WithAPIKeys(keys ...string) Option {
+	return func(c *Config) { c.Keys = base.StaticKeys(keys) }
+}
+
+// WithKeyProvider fetches API keys at request time, e.g. from a secret
+// manager, rotating between them like WithAPIKeys.
+func WithKeyProvider(keys base.KeyProvider) Option {
+	return func(c *Config) { c.Keys = keys }
+}
+
 // WithBaseURL sets a custom base URL.
 func WithBaseURL(url string) Option {
 	return func(c *Config) { c.BaseURL = url }
@@ -42,11 +80,24 @@ func WithMaxOutputTokens(n int) Option {
 	return func(c *Config) { c.MaxOutputTokens = &n }
 }
 
+// WithJSONMode requests a single JSON object as output without a schema.
+// The Messages API has no native JSON mode, so the system prompt asks for JSON;
+// the final text is validated and a *step.InvalidJSONError returned if it does not parse.
+func WithJSONMode() Option {
+	return func(c *Config) { c.ResponseFormat = base.ResponseFormatJSONObject }
+}
+
 // WithDebug enables JSONL debug logging to the specified file path.
 func WithDebug(path string) Option {
 	return func(c *Config) { c.DebugPath = path }
 }
 
+// WithDebugSink sends debug records to sink, in addition to or instead of a
+// WithDebug file.
+func WithDebugSink(sink base.DebugSink) Option {
+	return func(c *Config) { c.DebugSink = sink }
+}
+
 // WithExtraHeader adds a custom header to requests.
 func WithExtraHeader(key, value string) Option {
 	return func(c *Config) {
@@ -67,6 +118,24 @@ func WithExtraBody(key string, value any) Option {
 	}
 }
 
+// WithFinishReason maps the raw provider finish reason raw to reason,
+// overriding the built-in mapping. The raw value is always kept in
+// Provenance.FinishReason.
+func WithFinishReason(raw string, reason step.StopReason) Option {
+	return func(c *Config) {
+		if c.FinishReasons == nil {
+			c.FinishReasons = make(map[string]step.StopReason)
+		}
+		c.FinishReasons[raw] = reason
+	}
+}
+
+// WithCacheStrategy sets where prompt cache breakpoints are placed.
+// Defaults to base.DefaultCacheStrategy: system prompt, tools and the latest user/tool message.
+func WithCacheStrategy(strategy base.CacheStrategy) Option {
+	return func(c *Config) { c.Cache = &strategy }
+}
+
 // WithThinking enables extended thinking.
 func WithThinking(budget int) Option {
 	return func(c *Config) {
@@ -75,6 +144,38 @@ func WithThinking(budget int) Option {
 	}
 }
 
+// WithBetaFeatures enables Anthropic beta features by name, e.g. BetaContext1M.
+// Features already enabled are not repeated, so options enabling the same
+// feature combine.
+func WithBetaFeatures(names ...string) Option {
+	return func(c *Config) {
+		for _, name := range names {
+			if !slices.Contains(c.Betas, name) {
+				c.Betas = append(c.Betas, name)
+			}
+		}
+	}
+}
+
+// WithInterleavedThinking lets the model think between tool calls when thinking is enabled.
+func WithInterleavedThinking() Option {
+	return WithBetaFeatures(BetaInterleavedThinking)
+}
+
+// WithContext1M enables the one million token context window on models that
+// support it (Claude Sonnet 4 and later Sonnets); others keep 200k. Input
+// beyond 200k tokens is billed at long-context rates. ListModels reports the
+// larger window for supporting models.
+func WithContext1M() Option {
+	return WithBetaFeatures(BetaContext1M)
+}
+
+// WithFineGrainedToolStreaming streams tool arguments without buffering for JSON validation.
+// Arguments cut off by max_tokens may be invalid JSON; they are wrapped as {"INVALID_JSON": "<raw>"}.
+func WithFineGrainedToolStreaming() Option {
+	return WithBetaFeatures(BetaFineGrainedToolStreaming)
+}
+
 // New creates a Provider using Anthropic Messages API.
 // It reads ANTHROPIC_API_KEY and ANTHROPIC_BASE_URL from environment if not explicitly set.
 func New(model string, opts ...Option) step.Provider {
@@ -91,6 +192,17 @@ func New(model string, opts ...Option) step.Provider {
 	if cfg.BaseURL != "" {
 		clientOpts = append(clientOpts, option.WithBaseURL(cfg.BaseURL))
 	}
+	if betas := cfg.betaHeader(step.ProviderRequest{}); betas != "" {
+		clientOpts = append(clientOpts, option.WithHeader("anthropic-beta", betas))
+	}
+	for k, v := range cfg.ExtraHeaders {
+		clientOpts = append(clientOpts, option.WithHeader(k, v))
+	}
+	for k, v := range cfg.ExtraBody {
+		clientOpts = append(clientOpts, option.WithJSONSet(k, v))
+	}
+	keys := base.NewKeyRotation(cfg.Keys, base.HeaderKey("X-Api-Key"))
+	clientOpts = append(clientOpts, option.WithMiddleware(keys.RoundTrip, base.TransferMiddleware(cfg.CompressRequests)))
 	client := anthropic.NewClient(clientOpts...)
 	return &provider{model: model, cfg: cfg, client: client}
 }
@@ -101,8 +213,117 @@ type provider struct {
 	client anthropic.Client
 }
 
+const (
+	defaultMaxTokens = 8192
+	defaultBaseURL   = "https://api.anthropic.com"
+)
+
+// buildParams converts req to request params, applying the provider config.
+func (p *provider) buildParams(req step.ProviderRequest) anthropic.MessageNewParams {
+	cache := base.DefaultCacheStrategy()
+	if p.cfg.Cache != nil {
+		cache = *p.cfg.Cache
+	}
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		req.SystemPrompt = base.JSONModeSystemPrompt(req.SystemPrompt)
+	}
+	model := base.RequestModel(req, p.model)
+	params := BuildParams(req, model, cache)
+	params.Model = anthropic.Model(model)
+
+	maxTokens := defaultMaxTokens
+	if p.cfg.MaxOutputTokens != nil {
+		maxTokens = *p.cfg.MaxOutputTokens
+	}
+	thinking, budget := p.cfg.ThinkingEnabled, 1024
+	if p.cfg.ThinkingBudget != nil {
+		budget = *p.cfg.ThinkingBudget
+	}
+	if req.ReasoningEffort != "" {
+		budget, thinking = base.ThinkingBudget(req.ReasoningEffort)
+	}
+	if thinking {
+		// max_tokens must exceed the thinking budget
+		if maxTokens <= budget {
+			maxTokens = budget + defaultMaxTokens
+		}
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(budget))
+	} else if p.cfg.Temperature != nil {
+		// Temperature is not allowed together with extended thinking
+		params.Temperature = anthropic.Float(*p.cfg.Temperature)
+	}
+	params.MaxTokens = int64(maxTokens)
+	return params
+}
+
+var _ step.PayloadBuilder = (*provider)(nil)
+
+// BuildPayload returns the request Stream would send, without sending it.
+func (p *provider) BuildPayload(_ context.Context, req step.ProviderRequest) (step.Payload, error) {
+	extra := map[string]any{"stream": true}
+	for k, v := range p.cfg.ExtraBody {
+		extra[k] = v
+	}
+	body, err := base.MarshalWithExtra(p.buildParams(req), extra)
+	if err != nil {
+		return step.Payload{}, err
+	}
+
+	baseURL := p.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = os.Getenv("ANTHROPIC_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	headers := map[string]string{
+		"Content-Type":      "application/json",
+		"anthropic-version": "2023-06-01",
+	}
+	if betas := p.cfg.betaHeader(req); betas != "" {
+		headers["anthropic-beta"] = betas
+	}
+	for k, v := range p.cfg.ExtraHeaders {
+		headers[k] = v
+	}
+	return step.Payload{
+		Provider: providerName,
+		Model:    base.RequestModel(req, p.model),
+		URL:      strings.TrimRight(baseURL, "/") + "/v1/messages",
+		Headers:  headers,
+		Body:     body,
+	}, nil
+}
+
 func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
-	_ = ctx
-	_ = req
-	return nil, errors.New("step/providers/anthropic: not implemented")
+	model := base.RequestModel(req, p.model)
+	params := p.buildParams(req)
+
+	debug, err := p.cfg.DebugLogger()
+	if err != nil {
+		return nil, err
+	}
+	if debug != nil {
+		rec := base.NewDebugRecord("request", params)
+		rec.Provider = providerName
+		rec.Model = model
+		rec.IdempotencyKey = step.IdempotencyKey(ctx)
+		_ = debug.Log(rec)
+	}
+
+	var opts []option.RequestOption
+	if req.EfficientTools {
+		opts = append(opts, option.WithHeader("anthropic-beta", p.cfg.betaHeader(req)))
+	}
+	if key := step.IdempotencyKey(ctx); key != "" {
+		opts = append(opts, option.WithHeader(base.IdempotencyHeader, key))
+	}
+	ctx, stats := base.TrackTransfer(ctx)
+	stream := p.client.Messages.NewStreaming(ctx, params, opts...)
+	out := base.ReportTransfer(NewStream(model, stream, debug).EmitRaw(req.Raw), stats)
+	out = base.MapFinishReasons(out, p.cfg.FinishReasons)
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		out = base.ValidateJSON(out, base.PrefillText(req.History))
+	}
+	return out, nil
 }
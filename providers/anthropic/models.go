@@ -0,0 +1,47 @@
+package anthropic
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/inspirepan/step"
+)
+
+var (
+	_ step.ModelLister = (*provider)(nil)
+	_ step.Pinger      = (*provider)(nil)
+)
+
+// Context windows in tokens. The Models API does not report them.
+const (
+	defaultContextWindow = 200_000
+	longContextWindow    = 1_000_000
+)
+
+// ListModels lists the models available to the API key, newest first.
+func (p *provider) ListModels(ctx context.Context) ([]step.ModelInfo, error) {
+	var models []step.ModelInfo
+	iter := p.client.Models.ListAutoPaging(ctx, anthropic.ModelListParams{})
+	for iter.Next() {
+		m := iter.Current()
+		models = append(models, step.ModelInfo{ID: m.ID, Name: m.DisplayName, Created: m.CreatedAt, ContextWindow: p.contextWindow(m.ID)})
+	}
+	return models, iter.Err()
+}
+
+// contextWindow returns the context window of model under the configured betas.
+func (p *provider) contextWindow(model string) int {
+	if slices.Contains(p.cfg.Betas, BetaContext1M) && strings.HasPrefix(model, "claude-sonnet-4") {
+		return longContextWindow
+	}
+	return defaultContextWindow
+}
+
+// Ping checks the API key by fetching the configured model, which also
+// verifies that the model ID exists.
+func (p *provider) Ping(ctx context.Context) error {
+	_, err := p.client.Models.Get(ctx, p.model, anthropic.ModelGetParams{})
+	return err
+}
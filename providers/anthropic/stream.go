@@ -0,0 +1,319 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/ssestream"
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+const providerName = "anthropic"
+
+// Stream implements step.ProviderStream for the Anthropic Messages API.
+type Stream struct {
+	modelName string
+	stream    *ssestream.Stream[anthropic.MessageStreamEventUnion]
+	debug     *base.DebugLogger
+	raw       bool
+
+	mu sync.Mutex
+
+	done bool
+	err  error
+
+	pending []step.ProviderUpdate
+
+	// blocks accumulates content blocks by index, preserving the order the model produced them.
+	blocks []*blockAccumulator
+
+	finishReason string
+	requestID    string
+	servedModel  string
+	usage        anthropic.MessageDeltaUsage
+	hasUsage     bool
+	startedAt    time.Time
+}
+
+type blockAccumulator struct {
+	kind      string // text, thinking, redacted_thinking, tool_use
+	text      string
+	signature string
+	id        string
+	name      string
+	args      string
+}
+
+// NewStream wraps an Anthropic SSE stream.
+func NewStream(modelName string, stream *ssestream.Stream[anthropic.MessageStreamEventUnion], debug *base.DebugLogger) *Stream {
+	return &Stream{
+		modelName: modelName,
+		stream:    stream,
+		debug:     debug,
+		startedAt: time.Now(),
+	}
+}
+
+// EmitRaw makes the stream emit a step.ProviderRawUpdate for every chunk
+// when enabled; see step.ProviderRequest.Raw.
+func (s *Stream) EmitRaw(enabled bool) *Stream {
+	s.raw = enabled
+	return s
+}
+
+func (s *Stream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) > 0 {
+		return s.dequeue()
+	}
+	if s.done {
+		return nil, io.EOF
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Finalize a partial message so callers still get a coherent AssistantMessage.
+			if !s.done {
+				s.finalize()
+				if len(s.pending) > 0 {
+					return s.dequeue()
+				}
+			}
+			return nil, io.EOF
+		default:
+		}
+
+		if !s.stream.Next() {
+			if err := s.stream.Err(); err != nil {
+				s.err = err
+				return nil, s.err
+			}
+			s.finalize()
+			if len(s.pending) > 0 {
+				return s.dequeue()
+			}
+			return nil, io.EOF
+		}
+
+		s.processEvent(s.stream.Current())
+		if len(s.pending) > 0 {
+			return s.dequeue()
+		}
+	}
+}
+
+func (s *Stream) Close() error {
+	if s.debug != nil {
+		_ = s.debug.Close()
+	}
+	return s.stream.Close()
+}
+
+func (s *Stream) enqueue(up step.ProviderUpdate) {
+	s.pending = append(s.pending, up)
+}
+
+func (s *Stream) dequeue() (step.ProviderUpdate, error) {
+	up := s.pending[0]
+	s.pending = s.pending[1:]
+
+	if s.debug != nil {
+		rec := base.NewDebugRecord("update", up)
+		rec.Provider = providerName
+		rec.Model = s.modelName
+		_ = s.debug.Log(rec)
+	}
+
+	return up, nil
+}
+
+func (s *Stream) block(index int64) *blockAccumulator {
+	for int(index) >= len(s.blocks) {
+		s.blocks = append(s.blocks, nil)
+	}
+	if s.blocks[index] == nil {
+		s.blocks[index] = &blockAccumulator{}
+	}
+	return s.blocks[index]
+}
+
+func (s *Stream) processEvent(ev anthropic.MessageStreamEventUnion) {
+	if s.debug != nil {
+		rec := base.NewDebugRecord("chunk", ev.RawJSON())
+		rec.Provider = providerName
+		rec.Model = s.modelName
+		_ = s.debug.Log(rec)
+	}
+	if s.raw {
+		s.enqueue(step.ProviderRawUpdate{Provider: providerName, Data: json.RawMessage(ev.RawJSON())})
+	}
+
+	switch ev.Type {
+	case "message_start":
+		s.requestID = ev.Message.ID
+		s.servedModel = string(ev.Message.Model)
+		u := ev.Message.Usage
+		s.usage = anthropic.MessageDeltaUsage{
+			InputTokens:              u.InputTokens,
+			OutputTokens:             u.OutputTokens,
+			CacheReadInputTokens:     u.CacheReadInputTokens,
+			CacheCreationInputTokens: u.CacheCreationInputTokens,
+		}
+		s.hasUsage = true
+
+	case "content_block_start":
+		acc := s.block(ev.Index)
+		cb := ev.ContentBlock
+		acc.kind = cb.Type
+		switch cb.Type {
+		case "text":
+			acc.text = cb.Text
+		case "thinking":
+			acc.text = cb.Thinking
+			acc.signature = cb.Signature
+		case "redacted_thinking":
+			acc.signature = cb.Data
+		case "tool_use":
+			acc.id = cb.ID
+			acc.name = cb.Name
+			s.enqueue(step.ProviderDeltaUpdate{Delta: step.ToolCallDelta{CallID: acc.id, Name: acc.name}})
+		}
+
+	case "content_block_delta":
+		acc := s.block(ev.Index)
+		d := ev.Delta
+		switch d.Type {
+		case "text_delta":
+			acc.text += d.Text
+			s.enqueue(step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: d.Text}})
+		case "thinking_delta":
+			acc.text += d.Thinking
+			s.enqueue(step.ProviderDeltaUpdate{Delta: step.ThinkingDelta{Delta: d.Thinking}})
+		case "signature_delta":
+			acc.signature += d.Signature
+			s.enqueue(step.ProviderDeltaUpdate{Delta: step.ThinkingDelta{Signature: d.Signature}})
+		case "input_json_delta":
+			acc.args += d.PartialJSON
+			if d.PartialJSON != "" {
+				s.enqueue(step.ProviderDeltaUpdate{Delta: step.ToolCallDelta{CallID: acc.id, Name: acc.name, ArgsDelta: d.PartialJSON}})
+			}
+		}
+
+	case "message_delta":
+		if ev.Delta.StopReason != "" {
+			s.finishReason = string(ev.Delta.StopReason)
+		}
+		// message_delta usage is cumulative; input fields are only present on some API versions.
+		s.usage.OutputTokens = ev.Usage.OutputTokens
+		if ev.Usage.InputTokens > 0 {
+			s.usage.InputTokens = ev.Usage.InputTokens
+		}
+		if ev.Usage.CacheReadInputTokens > 0 {
+			s.usage.CacheReadInputTokens = ev.Usage.CacheReadInputTokens
+		}
+		if ev.Usage.CacheCreationInputTokens > 0 {
+			s.usage.CacheCreationInputTokens = ev.Usage.CacheCreationInputTokens
+		}
+		s.hasUsage = true
+	}
+}
+
+func (s *Stream) finalize() {
+	s.done = true
+
+	var parts []step.Part
+	for _, acc := range s.blocks {
+		if acc == nil {
+			continue
+		}
+		switch acc.kind {
+		case "text":
+			if acc.text != "" {
+				parts = append(parts, step.TextPart{Text: acc.text})
+			}
+		case "thinking":
+			parts = append(parts, step.ThinkingPart{Thinking: acc.text, Signature: acc.signature, ModelName: s.modelName})
+		case "redacted_thinking":
+			parts = append(parts, step.ThinkingPart{Signature: acc.signature, Format: redactedThinkingFormat, ModelName: s.modelName})
+		case "tool_use":
+			if acc.id == "" || acc.name == "" {
+				continue
+			}
+			parts = append(parts, step.ToolCallPart{CallID: acc.id, Name: acc.name, ArgsJSON: toolArgs(acc.args)})
+		}
+	}
+
+	now := time.Now()
+	msg := step.AssistantMessage{
+		Parts:      parts,
+		Timestamp:  now.UnixMilli(),
+		Usage:      s.stepUsage(),
+		StopReason: mapStopReason(s.finishReason),
+		Provenance: &step.Provenance{
+			Provider:     providerName,
+			Model:        s.modelName,
+			ServedModel:  s.servedModel,
+			RequestID:    s.requestID,
+			LatencyMs:    now.Sub(s.startedAt).Milliseconds(),
+			FinishReason: s.finishReason,
+		},
+	}
+	s.enqueue(step.ProviderMessageUpdate{Message: msg})
+}
+
+// toolArgs returns the accumulated tool input as JSON. With fine-grained tool
+// streaming the input is not validated server-side and may be truncated, so
+// invalid JSON is wrapped the way Anthropic recommends instead of being dropped.
+func toolArgs(raw string) json.RawMessage {
+	if raw == "" {
+		return json.RawMessage("{}")
+	}
+	if json.Valid([]byte(raw)) {
+		return json.RawMessage(raw)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"INVALID_JSON": raw})
+	return wrapped
+}
+
+// stepUsage converts Anthropic usage. Anthropic's input_tokens excludes cached
+// tokens, so InputTokens is normalized to the full prompt size like other providers.
+func (s *Stream) stepUsage() *step.Usage {
+	if !s.hasUsage {
+		return nil
+	}
+	u := s.usage
+	input := int(u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens)
+	return &step.Usage{
+		InputTokens:      input,
+		OutputTokens:     int(u.OutputTokens),
+		CachedReadTokens: int(u.CacheReadInputTokens),
+		CacheWriteTokens: int(u.CacheCreationInputTokens),
+		TotalTokens:      input + int(u.OutputTokens),
+	}
+}
+
+func mapStopReason(reason string) step.StopReason {
+	switch anthropic.StopReason(reason) {
+	case anthropic.StopReasonEndTurn, anthropic.StopReasonStopSequence, anthropic.StopReasonPauseTurn:
+		return step.StopStop
+	case anthropic.StopReasonMaxTokens:
+		return step.StopLength
+	case anthropic.StopReasonToolUse:
+		return step.StopToolUse
+	case anthropic.StopReasonRefusal:
+		return step.StopContentFilter
+	default:
+		return step.StopStop
+	}
+}
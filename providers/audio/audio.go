@@ -0,0 +1,389 @@
+// Package audio provides speech transcription and synthesis over the OpenAI
+// audio API, which compatible servers such as Groq and local Whisper servers
+// also implement.
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+// Config configures the audio client.
+type Config struct {
+	base.Config
+
+	// TranscribeModel defaults to gpt-4o-mini-transcribe.
+	TranscribeModel string
+	// SpeechModel defaults to gpt-4o-mini-tts.
+	SpeechModel string
+	// Voice defaults to alloy.
+	Voice string
+}
+
+// Option is a functional option for the client.
+type Option func(*Config)
+
+// WithAPIKey sets the API key.
+func WithAPIKey(key string) Option {
+	return func(c *Config) { c.APIKey = key }
+}
+
+// WithAPIKeys spreads requests over several API keys, moving to the next key
+// when one is rejected (401) or rate limited (429). See base.KeyRotation.
+func WithAPIKeys(keys ...string) Option {
+	return func(c *Config) { c.Keys = base.StaticKeys(keys) }
+}
+
+// WithBaseURL sets a custom base URL.
+func WithBaseURL(url string) Option {
+	return func(c *Config) { c.BaseURL = url }
+}
+
+// WithExtraHeader adds a custom header to requests.
+func WithExtraHeader(key, value string) Option {
+	return func(c *Config) {
+		if c.ExtraHeaders == nil {
+			c.ExtraHeaders = make(map[string]string)
+		}
+		c.ExtraHeaders[key] = value
+	}
+}
+
+// WithTranscribeModel sets the default transcription model, e.g. whisper-1.
+func WithTranscribeModel(model string) Option {
+	return func(c *Config) { c.TranscribeModel = model }
+}
+
+// WithSpeechModel sets the default speech model, e.g. tts-1.
+func WithSpeechModel(model string) Option {
+	return func(c *Config) { c.SpeechModel = model }
+}
+
+// WithVoice sets the default voice.
+func WithVoice(voice string) Option {
+	return func(c *Config) { c.Voice = voice }
+}
+
+const (
+	defaultBaseURL         = "https://api.openai.com/v1"
+	defaultTranscribeModel = "gpt-4o-mini-transcribe"
+	defaultSpeechModel     = "gpt-4o-mini-tts"
+	defaultVoice           = "alloy"
+
+	providerName = "openai"
+)
+
+// Client transcribes and synthesizes speech. It implements step.Transcriber,
+// step.StreamTranscriber and step.Synthesizer.
+type Client struct {
+	cfg  Config
+	keys *base.KeyRotation
+}
+
+var (
+	_ step.Transcriber       = (*Client)(nil)
+	_ step.StreamTranscriber = (*Client)(nil)
+	_ step.Synthesizer       = (*Client)(nil)
+)
+
+// New creates a Client.
+// It reads OPENAI_API_KEY and OPENAI_BASE_URL from environment if not explicitly set.
+func New(opts ...Option) *Client {
+	cfg := Config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	base.ApplyEnvDefaults(&cfg.Config, "OPENAI_API_KEY", "OPENAI_BASE_URL")
+	return &Client{cfg: cfg, keys: base.NewKeyRotation(cfg.Keys, base.BearerKey)}
+}
+
+// Transcribe transcribes req.Audio.
+func (c *Client) Transcribe(ctx context.Context, req step.TranscribeRequest) (step.Transcript, error) {
+	resp, err := c.transcribe(ctx, req, false)
+	if err != nil {
+		return step.Transcript{}, err
+	}
+	defer resp.Body.Close()
+	var out transcription
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return step.Transcript{}, fmt.Errorf("step/providers/audio: decode transcript: %w", err)
+	}
+	tr := step.Transcript{
+		Text:     out.Text,
+		Language: cmp.Or(out.Language, req.Language),
+		Duration: time.Duration(out.Duration * float64(time.Second)),
+		Usage:    out.Usage.toStep(),
+	}
+	if tr.Duration == 0 && out.Usage != nil && out.Usage.Type == "duration" {
+		tr.Duration = time.Duration(out.Usage.Seconds * float64(time.Second))
+	}
+	return tr, nil
+}
+
+// TranscribeStream streams the transcript of req.Audio as text deltas.
+// whisper-1 does not stream; use Transcribe or step.TranscribeStream.
+func (c *Client) TranscribeStream(ctx context.Context, req step.TranscribeRequest) (step.ProviderStream, error) {
+	resp, err := c.transcribe(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &transcriptStream{
+		model:     cmp.Or(req.Model, c.cfg.TranscribeModel, defaultTranscribeModel),
+		body:      resp.Body,
+		scanner:   scanner,
+		requestID: resp.Header.Get("x-request-id"),
+		startedAt: time.Now(),
+	}, nil
+}
+
+func (c *Client) transcribe(ctx context.Context, req step.TranscribeRequest, stream bool) (*http.Response, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	file, err := w.CreateFormFile("file", "audio"+extension(req.Audio.MimeType))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(req.Audio.Data); err != nil {
+		return nil, err
+	}
+	fields := [][2]string{
+		{"model", cmp.Or(req.Model, c.cfg.TranscribeModel, defaultTranscribeModel)},
+		{"language", req.Language},
+		{"prompt", req.Prompt},
+		{"response_format", "json"},
+	}
+	if stream {
+		fields = append(fields, [2]string{"stream", "true"})
+	}
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return c.post(ctx, "/audio/transcriptions", w.FormDataContentType(), body.Bytes())
+}
+
+// extension returns the file extension for an audio mime type. The API
+// detects the format from the file name.
+func extension(mimeType string) string {
+	mt, _, _ := mime.ParseMediaType(mimeType)
+	switch mt {
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/mp4", "audio/m4a", "audio/x-m4a":
+		return ".m4a"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return ".wav"
+	case "audio/webm":
+		return ".webm"
+	case "audio/ogg", "audio/opus":
+		return ".ogg"
+	case "audio/flac":
+		return ".flac"
+	}
+	if exts, _ := mime.ExtensionsByType(mt); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".wav"
+}
+
+// Synthesize speaks req.Text. The default format is mp3.
+func (c *Client) Synthesize(ctx context.Context, req step.SynthesizeRequest) (step.Audio, error) {
+	format := cmp.Or(req.Format, "mp3")
+	payload, err := json.Marshal(speechRequest{
+		Model:          cmp.Or(req.Model, c.cfg.SpeechModel, defaultSpeechModel),
+		Input:          req.Text,
+		Voice:          cmp.Or(req.Voice, c.cfg.Voice, defaultVoice),
+		ResponseFormat: format,
+		Instructions:   req.Instructions,
+		Speed:          req.Speed,
+	})
+	if err != nil {
+		return step.Audio{}, err
+	}
+	resp, err := c.post(ctx, "/audio/speech", "application/json", payload)
+	if err != nil {
+		return step.Audio{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return step.Audio{}, err
+	}
+	mimeType := formats[format]
+	if mimeType == "" {
+		mimeType = resp.Header.Get("Content-Type")
+	}
+	return step.Audio{MimeType: mimeType, Data: data}, nil
+}
+
+// formats maps speech response formats to mime types; the API does not
+// always send a precise Content-Type.
+var formats = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"pcm":  "audio/L16;rate=24000",
+}
+
+func (c *Client) post(ctx context.Context, path, contentType string, payload []byte) (*http.Response, error) {
+	baseURL := c.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	if c.cfg.APIKey != "" {
+		base.BearerKey(httpReq.Header, c.cfg.APIKey)
+	}
+	for k, v := range c.cfg.ExtraHeaders {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := c.keys.RoundTrip(httpReq, http.DefaultClient.Do)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("step/providers/audio: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+type speechRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format,omitempty"`
+	Instructions   string  `json:"instructions,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+type transcription struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language,omitempty"`
+	Duration float64             `json:"duration,omitempty"`
+	Usage    *transcriptionUsage `json:"usage,omitempty"`
+}
+
+// transcriptionUsage is billed either by tokens or by audio duration.
+type transcriptionUsage struct {
+	Type         string  `json:"type"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalTokens  int     `json:"total_tokens"`
+	Seconds      float64 `json:"seconds"`
+}
+
+func (u *transcriptionUsage) toStep() *step.Usage {
+	if u == nil || u.Type == "duration" {
+		return nil
+	}
+	return &step.Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens, TotalTokens: u.TotalTokens}
+}
+
+// transcriptStream implements step.ProviderStream for streamed transcription
+// events: transcript.text.delta events followed by transcript.text.done.
+type transcriptStream struct {
+	model     string
+	body      io.ReadCloser
+	scanner   *bufio.Scanner
+	requestID string
+	startedAt time.Time
+
+	mu   sync.Mutex
+	text strings.Builder
+	done bool
+}
+
+type transcriptEvent struct {
+	Type  string              `json:"type"`
+	Delta string              `json:"delta"`
+	Text  string              `json:"text"`
+	Usage *transcriptionUsage `json:"usage,omitempty"`
+}
+
+func (s *transcriptStream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.done {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !s.scanner.Scan() {
+			if err := s.scanner.Err(); err != nil {
+				return nil, err
+			}
+			// The stream ended without a done event; finish with what arrived.
+			return s.finish(s.text.String(), nil), nil
+		}
+		data, ok := strings.CutPrefix(s.scanner.Text(), "data:")
+		data = strings.TrimSpace(data)
+		if !ok || data == "" || data == "[DONE]" {
+			continue
+		}
+		var ev transcriptEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return nil, fmt.Errorf("step/providers/audio: decode event: %w", err)
+		}
+		switch ev.Type {
+		case "transcript.text.delta":
+			if ev.Delta == "" {
+				continue
+			}
+			s.text.WriteString(ev.Delta)
+			return step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: ev.Delta}}, nil
+		case "transcript.text.done":
+			return s.finish(cmp.Or(ev.Text, s.text.String()), ev.Usage.toStep()), nil
+		}
+	}
+	return nil, io.EOF
+}
+
+func (s *transcriptStream) finish(text string, usage *step.Usage) step.ProviderUpdate {
+	s.done = true
+	return step.ProviderMessageUpdate{Message: step.AssistantMessage{
+		Parts:      []step.Part{step.TextPart{Text: text}},
+		Timestamp:  time.Now().UnixMilli(),
+		Usage:      usage,
+		StopReason: step.StopStop,
+		Provenance: &step.Provenance{
+			Provider:  providerName,
+			Model:     s.model,
+			RequestID: s.requestID,
+			LatencyMs: time.Since(s.startedAt).Milliseconds(),
+		},
+	}}
+}
+
+func (s *transcriptStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,119 @@
+package audio_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/audio"
+)
+
+func TestTranscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer test" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "audio.mp3" || string(data) != "mp3 bytes" || r.FormValue("model") != "whisper-1" || r.FormValue("language") != "en" {
+			http.Error(w, fmt.Sprintf("unexpected form %q %q %v", header.Filename, data, r.MultipartForm.Value), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"text":"Hello.","usage":{"type":"duration","seconds":2.5}}`)
+	}))
+	defer server.Close()
+
+	client := audio.New(audio.WithAPIKey("test"), audio.WithBaseURL(server.URL), audio.WithTranscribeModel("whisper-1"))
+	tr, err := client.Transcribe(context.Background(), step.TranscribeRequest{
+		Audio:    step.Audio{MimeType: "audio/mpeg", Data: []byte("mp3 bytes")},
+		Language: "en",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Text != "Hello." || tr.Language != "en" || tr.Duration.Seconds() != 2.5 || tr.Usage != nil {
+		t.Errorf("unexpected transcript %+v", tr)
+	}
+}
+
+func TestTranscribeStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("stream") != "true" {
+			http.Error(w, "expected stream=true", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"type\":\"transcript.text.delta\",\"delta\":\"Hel\"}\n\n")
+		fmt.Fprint(w, "data: {\"type\":\"transcript.text.delta\",\"delta\":\"lo.\"}\n\n")
+		fmt.Fprint(w, "data: {\"type\":\"transcript.text.done\",\"text\":\"Hello.\",\"usage\":{\"type\":\"tokens\",\"input_tokens\":12,\"output_tokens\":3,\"total_tokens\":15}}\n\n")
+	}))
+	defer server.Close()
+
+	stream, err := audio.New(audio.WithAPIKey("test"), audio.WithBaseURL(server.URL)).TranscribeStream(context.Background(), step.TranscribeRequest{
+		Audio: step.Audio{MimeType: "audio/wav", Data: []byte("wav bytes")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var deltas []string
+	var final step.AssistantMessage
+	for {
+		up, err := stream.Next(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch up := up.(type) {
+		case step.ProviderDeltaUpdate:
+			deltas = append(deltas, up.Delta.(step.TextDelta).Delta)
+		case step.ProviderMessageUpdate:
+			final = up.Message
+		}
+	}
+	if len(deltas) != 2 || deltas[0] != "Hel" || deltas[1] != "lo." {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if final.Parts[0].(step.TextPart).Text != "Hello." || final.Usage == nil || final.Usage.TotalTokens != 15 || final.Provenance.Model != "gpt-4o-mini-transcribe" {
+		t.Errorf("unexpected final message %+v", final)
+	}
+}
+
+func TestSynthesize(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, "opus bytes")
+	}))
+	defer server.Close()
+
+	out, err := audio.New(audio.WithAPIKey("test"), audio.WithBaseURL(server.URL), audio.WithVoice("nova")).Synthesize(context.Background(), step.SynthesizeRequest{
+		Text:         "Hi there",
+		Format:       "opus",
+		Instructions: "Whisper",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.MimeType != "audio/ogg" || string(out.Data) != "opus bytes" {
+		t.Errorf("unexpected audio %+v", out)
+	}
+	want := map[string]any{"model": "gpt-4o-mini-tts", "input": "Hi there", "voice": "nova", "response_format": "opus", "instructions": "Whisper"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("request = %v, want %v", got, want)
+	}
+}
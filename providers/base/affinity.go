@@ -0,0 +1,41 @@
+package base
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// RankByAffinity returns members ordered by rendezvous hashing of key: the
+// same key always yields the same order, different keys spread evenly over
+// the members, and adding or removing a member only moves the keys that
+// ranked it first. Use it with a session ID to keep a conversation on one
+// upstream, so its prompt cache stays warm.
+func RankByAffinity(key string, members []string) []string {
+	type ranked struct {
+		name  string
+		score uint64
+	}
+	scores := make([]ranked, len(members))
+	for i, m := range members {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(m))
+		scores[i] = ranked{m, mix(h.Sum64())}
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+	out := make([]string, len(scores))
+	for i, s := range scores {
+		out[i] = s.name
+	}
+	return out
+}
+
+// mix is the splitmix64 finalizer; FNV alone spreads similar inputs poorly.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,70 @@
+package base
+
+// CacheTTL is the lifetime of a prompt cache entry.
+type CacheTTL string
+
+const (
+	CacheTTLDefault CacheTTL = ""   // provider default (5 minutes for Anthropic)
+	CacheTTL5m      CacheTTL = "5m" // 5 minutes
+	CacheTTL1h      CacheTTL = "1h" // 1 hour, billed at a higher write rate
+)
+
+// maxCacheBreakpoints is the number of cache_control breakpoints Anthropic accepts per request.
+const maxCacheBreakpoints = 4
+
+// CacheStrategy controls where prompt cache breakpoints (cache_control) are placed.
+// It is shared by providers that speak Anthropic-style explicit caching.
+type CacheStrategy struct {
+	// Disabled turns off all breakpoints.
+	Disabled bool
+	// System places a breakpoint on the system prompt.
+	System bool
+	// Tools places a breakpoint on the last tool definition.
+	// Providers whose wire format cannot carry it ignore this.
+	Tools bool
+	// Messages is the number of most recent user/tool messages that get a breakpoint.
+	// The total number of breakpoints is capped at 4.
+	Messages int
+	// TTL sets the cache entry lifetime. Empty uses the provider default.
+	TTL CacheTTL
+}
+
+// DefaultCacheStrategy caches the system prompt, the tools and the latest user/tool message.
+func DefaultCacheStrategy() CacheStrategy {
+	return CacheStrategy{System: true, Tools: true, Messages: 1}
+}
+
+// NoCache returns a strategy that places no breakpoints.
+func NoCache() CacheStrategy {
+	return CacheStrategy{Disabled: true}
+}
+
+// Enabled reports whether any breakpoint will be placed.
+func (s CacheStrategy) Enabled() bool {
+	return !s.Disabled && (s.System || s.Tools || s.Messages > 0)
+}
+
+// MessageBreakpoints returns how many message breakpoints may be placed given
+// the breakpoints already used by the system prompt and tools.
+func (s CacheStrategy) MessageBreakpoints(systemUsed, toolsUsed bool) int {
+	if s.Disabled || s.Messages <= 0 {
+		return 0
+	}
+	left := maxCacheBreakpoints
+	if systemUsed {
+		left--
+	}
+	if toolsUsed {
+		left--
+	}
+	return max(0, min(s.Messages, left))
+}
+
+// CacheControl returns the cache_control object for raw JSON request bodies.
+func (s CacheStrategy) CacheControl() map[string]any {
+	cc := map[string]any{"type": "ephemeral"}
+	if s.TTL != CacheTTLDefault {
+		cc["ttl"] = string(s.TTL)
+	}
+	return cc
+}
@@ -3,6 +3,7 @@ package base
 import (
 	"os"
 
+	"github.com/inspirepan/step"
 	"github.com/joho/godotenv"
 )
 
@@ -22,13 +23,32 @@ type Config struct {
 	APIKey  string
 	BaseURL string
 
+	// Keys, when set, supplies API keys per request instead of APIKey and
+	// rotates between them; see KeyRotation.
+	Keys KeyProvider
+
 	// Debug options
 	// DebugPath writes JSONL debug records (request/chunk/event) when set.
 	DebugPath string
+	// DebugSink receives the same records, e.g. to ship them to a central
+	// log store; see NewWriterSink, NewHTTPSink and NewOTLPSink.
+	DebugSink DebugSink
 
 	// Generation options
 	MaxOutputTokens *int
 	Temperature     *float64
+	ResponseFormat  ResponseFormat
+
+	// Cache controls prompt cache breakpoints. Nil uses the provider default.
+	Cache *CacheStrategy
+
+	// FinishReasons maps raw provider finish reasons to stop reasons, taking
+	// precedence over the provider's built-in mapping. See MapFinishReasons.
+	FinishReasons map[string]step.StopReason
+
+	// CompressRequests gzip-encodes large request bodies. Only endpoints
+	// that accept Content-Encoding: gzip should enable it.
+	CompressRequests bool
 
 	// Extra options
 	ExtraHeaders map[string]string
@@ -44,3 +64,29 @@ func ApplyEnvDefaults(cfg *Config, apiKeyEnv, baseURLEnv string) {
 		cfg.BaseURL = os.Getenv(baseURLEnv)
 	}
 }
+
+// RequestModel returns the model to use for req: its ModelOverride if set,
+// otherwise the provider's configured model.
+func RequestModel(req step.ProviderRequest, model string) string {
+	if req.ModelOverride != "" {
+		return req.ModelOverride
+	}
+	return model
+}
+
+// ThinkingBudget maps a reasoning effort to a thinking token budget for
+// providers configured by budget. It reports false for ReasoningEffortNone.
+func ThinkingBudget(effort step.ReasoningEffort) (int, bool) {
+	switch effort {
+	case step.ReasoningEffortNone:
+		return 0, false
+	case step.ReasoningEffortMinimal:
+		return 1024, true
+	case step.ReasoningEffortLow:
+		return 4096, true
+	case step.ReasoningEffortHigh:
+		return 32000, true
+	default:
+		return 12000, true
+	}
+}
@@ -2,17 +2,33 @@ package base
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"sync"
 	"time"
 )
 
-// DebugLogger writes JSON objects as JSONL.
-// It is safe for concurrent use.
+// DebugLogger writes JSON objects as JSONL to a file, and records to a
+// DebugSink. It is safe for concurrent use.
 type DebugLogger struct {
-	mu  sync.Mutex
-	f   *os.File
-	enc *json.Encoder
+	mu   sync.Mutex
+	f    *os.File
+	enc  *json.Encoder
+	sink DebugSink
+}
+
+// DebugLogger returns a logger for c's DebugPath and DebugSink, or nil if
+// neither is set (debug logging disabled).
+func (c Config) DebugLogger() (*DebugLogger, error) {
+	l, err := NewDebugLogger(c.DebugPath)
+	if err != nil || c.DebugSink == nil {
+		return l, err
+	}
+	if l == nil {
+		l = &DebugLogger{}
+	}
+	l.sink = c.DebugSink
+	return l, nil
 }
 
 // NewDebugLogger creates a new debug logger that writes to the specified path.
@@ -28,6 +44,7 @@ func NewDebugLogger(path string) (*DebugLogger, error) {
 	return &DebugLogger{f: f, enc: json.NewEncoder(f)}, nil
 }
 
+// Close closes the file. The sink is shared between loggers and left open.
 func (l *DebugLogger) Close() error {
 	if l == nil || l.f == nil {
 		return nil
@@ -37,23 +54,38 @@ func (l *DebugLogger) Close() error {
 	return l.f.Close()
 }
 
-// Log writes a JSON line.
+// Log writes a JSON line, and passes v to the sink. Values other than a
+// DebugRecord reach the sink as the data of a "log" record.
 func (l *DebugLogger) Log(v any) error {
-	if l == nil || l.enc == nil {
+	if l == nil {
 		return nil
 	}
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	return l.enc.Encode(v)
+	var err error
+	if l.enc != nil {
+		l.mu.Lock()
+		err = l.enc.Encode(v)
+		l.mu.Unlock()
+	}
+	if l.sink != nil {
+		rec, ok := v.(DebugRecord)
+		if !ok {
+			rec = NewDebugRecord("log", v)
+		}
+		err = errors.Join(err, l.sink.WriteDebugRecord(rec))
+	}
+	return err
 }
 
-// DebugRecord is a normalized JSONL entry.
+// DebugRecord is a normalized JSONL entry. Package debugread reads them back.
 type DebugRecord struct {
 	Time     string `json:"time"`
 	Provider string `json:"provider,omitempty"`
 	Model    string `json:"model,omitempty"`
 	Type     string `json:"type"`
-	Data     any    `json:"data,omitempty"`
+	// IdempotencyKey is the step's idempotency key on request records, for
+	// correlating them with provider logs and retries.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	Data           any    `json:"data,omitempty"`
 }
 
 func NewDebugRecord(recordType string, data any) DebugRecord {
@@ -63,3 +95,7 @@ func NewDebugRecord(recordType string, data any) DebugRecord {
 		Data: data,
 	}
 }
+
+// IdempotencyHeader is the header carrying step.IdempotencyKey to providers
+// that deduplicate retried requests by it.
+const IdempotencyHeader = "Idempotency-Key"
@@ -0,0 +1,241 @@
+package debugread
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// Request is one provider call: its request record and the chunks and
+// updates logged after it.
+//
+// Records are attributed to the latest request before them, so requests that
+// ran concurrently against the same debug path are not told apart.
+type Request struct {
+	Start          time.Time
+	Provider       string
+	Model          string
+	IdempotencyKey string
+	Body           json.RawMessage
+	Chunks         []Record
+	Updates        []Record
+}
+
+// Group splits records into requests. Records before the first request
+// record are dropped.
+func Group(records []Record) []Request {
+	var reqs []Request
+	for _, rec := range records {
+		if rec.Type == TypeRequest {
+			reqs = append(reqs, Request{
+				Start:          rec.Time,
+				Provider:       rec.Provider,
+				Model:          rec.Model,
+				IdempotencyKey: rec.IdempotencyKey,
+				Body:           rec.Data,
+			})
+			continue
+		}
+		if len(reqs) == 0 {
+			continue
+		}
+		r := &reqs[len(reqs)-1]
+		switch rec.Type {
+		case TypeChunk:
+			r.Chunks = append(r.Chunks, rec)
+		case TypeUpdate:
+			r.Updates = append(r.Updates, rec)
+		}
+	}
+	return reqs
+}
+
+// Final returns the final message of the first candidate, if one was logged.
+func (r Request) Final() (step.AssistantMessage, bool) {
+	for _, u := range r.Updates {
+		if u.Message != nil && u.Candidate == 0 {
+			return *u.Message, true
+		}
+	}
+	return step.AssistantMessage{}, false
+}
+
+// Usage returns the usage reported with the final message, or nil.
+func (r Request) Usage() *step.Usage {
+	if msg, ok := r.Final(); ok {
+		return msg.Usage
+	}
+	return nil
+}
+
+// Duration returns the time from the request to its last logged record.
+func (r Request) Duration() time.Duration {
+	end := r.Start
+	for _, recs := range [][]Record{r.Chunks, r.Updates} {
+		if n := len(recs); n > 0 && recs[n-1].Time.After(end) {
+			end = recs[n-1].Time
+		}
+	}
+	return end.Sub(r.Start)
+}
+
+// TimeToFirstChunk returns the time from the request to its first chunk, or
+// zero without chunks.
+func (r Request) TimeToFirstChunk() time.Duration {
+	if len(r.Chunks) == 0 {
+		return 0
+	}
+	return r.Chunks[0].Time.Sub(r.Start)
+}
+
+// ChunkGaps returns the time between consecutive chunks.
+func (r Request) ChunkGaps() []time.Duration {
+	if len(r.Chunks) < 2 {
+		return nil
+	}
+	gaps := make([]time.Duration, len(r.Chunks)-1)
+	for i := range gaps {
+		gaps[i] = r.Chunks[i+1].Time.Sub(r.Chunks[i].Time)
+	}
+	return gaps
+}
+
+// Message returns the assistant message of the request: the logged final
+// message, or, when the stream broke off before one, a message rebuilt from
+// the logged deltas with StopAborted.
+func (r Request) Message() step.AssistantMessage {
+	if msg, ok := r.Final(); ok {
+		return msg
+	}
+	var (
+		thinking, text strings.Builder
+		signature      string
+		calls          []step.ToolCallPart
+		args           []string
+	)
+	for _, u := range r.Updates {
+		switch d := u.Delta.(type) {
+		case step.ThinkingDelta:
+			thinking.WriteString(d.Delta)
+			signature += d.Signature
+		case step.TextDelta:
+			text.WriteString(d.Delta)
+		case step.AudioDelta:
+			text.WriteString(d.Transcript)
+		case step.ToolCallDelta:
+			if len(calls) == 0 || d.CallID != "" && d.CallID != calls[len(calls)-1].CallID {
+				calls = append(calls, step.ToolCallPart{CallID: d.CallID, Name: d.Name})
+				args = append(args, "")
+			}
+			args[len(args)-1] += d.ArgsDelta
+		}
+	}
+	msg := step.AssistantMessage{StopReason: step.StopAborted}
+	if thinking.Len() > 0 || signature != "" {
+		msg.Parts = append(msg.Parts, step.ThinkingPart{Thinking: thinking.String(), Signature: signature})
+	}
+	if text.Len() > 0 {
+		msg.Parts = append(msg.Parts, step.TextPart{Text: text.String()})
+	}
+	for i, c := range calls {
+		if json.Valid([]byte(args[i])) {
+			c.ArgsJSON = json.RawMessage(args[i])
+		}
+		msg.Parts = append(msg.Parts, c)
+	}
+	return msg
+}
+
+// Transcript returns the assistant messages of reqs in order; see
+// Request.Message.
+func Transcript(reqs []Request) []step.AssistantMessage {
+	msgs := make([]step.AssistantMessage, len(reqs))
+	for i, r := range reqs {
+		msgs[i] = r.Message()
+	}
+	return msgs
+}
+
+// TotalUsage sums the usage of reqs. Requests without usage count zero.
+func TotalUsage(reqs []Request) step.Usage {
+	var total step.Usage
+	for _, r := range reqs {
+		total.Add(r.Usage())
+	}
+	return total
+}
+
+// UsageByUpstream sums the usage of reqs by the upstream that served them,
+// for comparing cost across the upstreams of a router. Requests without a
+// reported upstream count under their Provider.
+func UsageByUpstream(reqs []Request) map[string]step.Usage {
+	out := make(map[string]step.Usage)
+	for _, r := range reqs {
+		key := r.Provider
+		msg, ok := r.Final()
+		if ok && msg.Provenance != nil && msg.Provenance.Upstream != "" {
+			key = msg.Provenance.Upstream
+		}
+		u := out[key]
+		u.Add(msg.Usage)
+		out[key] = u
+	}
+	return out
+}
+
+// DefaultBuckets are the upper bounds ChunkTiming uses without any given.
+var DefaultBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Histogram counts durations by bucket.
+type Histogram struct {
+	// Bounds are the ascending upper bounds of the buckets.
+	Bounds []time.Duration
+	// Counts[i] counts durations up to Bounds[i] and above Bounds[i-1];
+	// the last count, one past Bounds, counts durations above all of them.
+	Counts []int
+	Max    time.Duration
+	Total  time.Duration
+	N      int
+}
+
+// Mean returns the mean duration, or zero for an empty histogram.
+func (h Histogram) Mean() time.Duration {
+	if h.N == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(h.N)
+}
+
+// ChunkTiming returns the histogram of the gaps between chunks across reqs,
+// the inter-chunk latency a streaming consumer sees.
+func ChunkTiming(reqs []Request, bounds ...time.Duration) Histogram {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+	h := Histogram{Bounds: bounds, Counts: make([]int, len(bounds)+1)}
+	for _, r := range reqs {
+		for _, gap := range r.ChunkGaps() {
+			i := 0
+			for i < len(bounds) && gap > bounds[i] {
+				i++
+			}
+			h.Counts[i]++
+			h.Total += gap
+			h.N++
+			h.Max = max(h.Max, gap)
+		}
+	}
+	return h
+}
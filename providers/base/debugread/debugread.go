@@ -0,0 +1,197 @@
+// Package debugread reads the JSONL debug logs that providers write when
+// configured with a debug path, and analyzes them: token totals per request,
+// chunk timing and the assistant messages the requests produced.
+package debugread
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// Record types written by the providers.
+const (
+	// TypeRequest records the request body as sent, once per provider call.
+	TypeRequest = "request"
+	// TypeChunk records one chunk as received, typically an SSE event.
+	TypeChunk = "chunk"
+	// TypeUpdate records one step.ProviderUpdate as emitted.
+	TypeUpdate = "update"
+)
+
+// Record is one decoded line of a debug log; see base.DebugRecord.
+type Record struct {
+	Time           time.Time
+	Provider       string
+	Model          string
+	Type           string
+	IdempotencyKey string
+	// Data is the payload. Chunks that providers logged as JSON strings are
+	// unquoted, so Data holds the chunk's JSON either way.
+	Data json.RawMessage
+
+	// Delta is set on update records carrying a delta.
+	Delta step.MessageDelta
+	// Message and Candidate are set on update records carrying a final message.
+	Message   *step.AssistantMessage
+	Candidate int
+}
+
+// Reader decodes records from a debug log.
+type Reader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	sc := bufio.NewScanner(r)
+	// Request records hold the whole conversation.
+	sc.Buffer(make([]byte, 0, 64*1024), 256<<20)
+	return &Reader{sc: sc}
+}
+
+// Next returns the next record, or io.EOF at the end of the log. Blank lines
+// are skipped.
+func (r *Reader) Next() (Record, error) {
+	for r.sc.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rec, err := decode(line)
+		if err != nil {
+			return Record{}, fmt.Errorf("debugread: line %d: %w", r.line, err)
+		}
+		return rec, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+// ReadAll returns the remaining records.
+func (r *Reader) ReadAll() ([]Record, error) {
+	var records []Record
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// ReadFile returns the records of the debug log at path.
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReader(f).ReadAll()
+}
+
+func decode(line []byte) (Record, error) {
+	var raw struct {
+		Time           string          `json:"time"`
+		Provider       string          `json:"provider"`
+		Model          string          `json:"model"`
+		Type           string          `json:"type"`
+		IdempotencyKey string          `json:"idempotency_key"`
+		Data           json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return Record{}, err
+	}
+	rec := Record{
+		Provider:       raw.Provider,
+		Model:          raw.Model,
+		Type:           raw.Type,
+		IdempotencyKey: raw.IdempotencyKey,
+		Data:           raw.Data,
+	}
+	if raw.Time != "" {
+		t, err := time.Parse(time.RFC3339Nano, raw.Time)
+		if err != nil {
+			return Record{}, err
+		}
+		rec.Time = t
+	}
+	switch rec.Type {
+	case TypeChunk:
+		var s string
+		if json.Unmarshal(raw.Data, &s) == nil && json.Valid([]byte(s)) {
+			rec.Data = json.RawMessage(s)
+		}
+	case TypeUpdate:
+		if err := decodeUpdate(&rec); err != nil {
+			return Record{}, err
+		}
+	}
+	return rec, nil
+}
+
+// decodeUpdate decodes the update in rec.Data. Updates are logged with the
+// default encoding, which does not name the delta type, so it is inferred
+// from the fields.
+func decodeUpdate(rec *Record) error {
+	var up struct {
+		Delta     map[string]json.RawMessage
+		Message   json.RawMessage
+		Candidate int
+	}
+	if err := json.Unmarshal(rec.Data, &up); err != nil {
+		return err
+	}
+	if len(up.Message) > 0 {
+		var msg step.AssistantMessage
+		if err := json.Unmarshal(up.Message, &msg); err != nil {
+			return err
+		}
+		rec.Message, rec.Candidate = &msg, up.Candidate
+		return nil
+	}
+	if up.Delta == nil {
+		return nil
+	}
+	var target step.MessageDelta
+	switch d := up.Delta; {
+	case d["CallID"] != nil:
+		target = &step.ToolCallDelta{}
+	case d["Signature"] != nil:
+		target = &step.ThinkingDelta{}
+	case d["MimeType"] != nil:
+		target = &step.AudioDelta{}
+	case d["Delta"] != nil:
+		target = &step.TextDelta{}
+	default:
+		return nil
+	}
+	data, _ := json.Marshal(up.Delta)
+	if err := json.Unmarshal(data, target); err != nil {
+		return err
+	}
+	switch v := target.(type) {
+	case *step.ToolCallDelta:
+		rec.Delta = *v
+	case *step.ThinkingDelta:
+		rec.Delta = *v
+	case *step.AudioDelta:
+		rec.Delta = *v
+	case *step.TextDelta:
+		rec.Delta = *v
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package debugread_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+	"github.com/inspirepan/step/providers/base/debugread"
+)
+
+func writeLog(t *testing.T, records ...base.DebugRecord) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "debug.jsonl")
+	logger, err := base.NewDebugLogger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, rec := range records {
+		rec.Time = start.Add(time.Duration(i) * 20 * time.Millisecond).Format(time.RFC3339Nano)
+		rec.Provider, rec.Model = "anthropic", "claude-test"
+		if err := logger.Log(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func request(key string) base.DebugRecord {
+	rec := base.NewDebugRecord("request", map[string]any{"model": "claude-test"})
+	rec.IdempotencyKey = key
+	return rec
+}
+
+func update(up step.ProviderUpdate) base.DebugRecord {
+	return base.NewDebugRecord("update", up)
+}
+
+func TestReadAndAnalyze(t *testing.T) {
+	path := writeLog(t,
+		request("key-1"),
+		// Some providers log chunks as JSON strings.
+		base.NewDebugRecord("chunk", `{"type":"content_block_delta"}`),
+		update(step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: "Hel"}}),
+		base.NewDebugRecord("chunk", json.RawMessage(`{"type":"content_block_delta"}`)),
+		update(step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: "lo"}}),
+		update(step.ProviderMessageUpdate{Message: step.AssistantMessage{
+			Parts:      []step.Part{step.TextPart{Text: "Hello"}},
+			StopReason: step.StopStop,
+			Usage:      &step.Usage{InputTokens: 10, OutputTokens: 2, TotalTokens: 12},
+			Provenance: &step.Provenance{Upstream: "Vertex"},
+		}}),
+		// The second request broke off mid-stream.
+		request("key-2"),
+		base.NewDebugRecord("chunk", `{}`),
+		update(step.ProviderDeltaUpdate{Delta: step.ThinkingDelta{Delta: "Hmm"}}),
+		update(step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: "Let me look."}}),
+		update(step.ProviderDeltaUpdate{Delta: step.ToolCallDelta{CallID: "c1", Name: "read", ArgsDelta: `{"path":`}}),
+		update(step.ProviderDeltaUpdate{Delta: step.ToolCallDelta{ArgsDelta: `"a.go"}`}}),
+	)
+
+	records, err := debugread.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 12 || records[0].Provider != "anthropic" || records[0].IdempotencyKey != "key-1" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if string(records[1].Data) != `{"type":"content_block_delta"}` {
+		t.Errorf("string chunk not unquoted: %s", records[1].Data)
+	}
+	if d, ok := records[2].Delta.(step.TextDelta); !ok || d.Delta != "Hel" {
+		t.Errorf("delta = %#v", records[2].Delta)
+	}
+
+	reqs := debugread.Group(records)
+	if len(reqs) != 2 || len(reqs[0].Chunks) != 2 || len(reqs[0].Updates) != 3 || reqs[1].IdempotencyKey != "key-2" {
+		t.Fatalf("unexpected requests %+v", reqs)
+	}
+	if reqs[0].TimeToFirstChunk() != 20*time.Millisecond || reqs[0].Duration() != 100*time.Millisecond {
+		t.Errorf("timing = %s, %s", reqs[0].TimeToFirstChunk(), reqs[0].Duration())
+	}
+	if total := debugread.TotalUsage(reqs); total.TotalTokens != 12 {
+		t.Errorf("total usage = %+v", total)
+	}
+	if byUpstream := debugread.UsageByUpstream(reqs); len(byUpstream) != 2 || byUpstream["Vertex"].TotalTokens != 12 {
+		t.Errorf("usage by upstream = %+v", byUpstream)
+	}
+
+	h := debugread.ChunkTiming(reqs, 10*time.Millisecond, 50*time.Millisecond)
+	if h.N != 1 || h.Counts[1] != 1 || h.Mean() != 40*time.Millisecond {
+		t.Errorf("histogram = %+v", h)
+	}
+
+	transcript := debugread.Transcript(reqs)
+	if transcript[0].Text() != "Hello" || transcript[0].StopReason != step.StopStop {
+		t.Errorf("first message = %+v", transcript[0])
+	}
+	partial := transcript[1]
+	if partial.StopReason != step.StopAborted || len(partial.Parts) != 3 || partial.Text() != "Let me look." {
+		t.Fatalf("rebuilt message = %+v", partial)
+	}
+	if call := partial.Parts[2].(step.ToolCallPart); call.Name != "read" || string(call.ArgsJSON) != `{"path":"a.go"}` {
+		t.Errorf("rebuilt call = %+v", call)
+	}
+}
+
+func TestReadError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debug.jsonl")
+	logger, _ := base.NewDebugLogger(path)
+	_ = logger.Log(request(""))
+	_ = logger.Log("not a record")
+	logger.Close()
+	if _, err := debugread.ReadFile(path); err == nil {
+		t.Error("expected an error for a malformed line")
+	}
+}
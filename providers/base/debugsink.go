@@ -0,0 +1,286 @@
+package base
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DebugSink receives debug records. Implementations must be safe for
+// concurrent use, and WriteDebugRecord should not block on the network: it
+// is called for every streamed chunk.
+type DebugSink interface {
+	WriteDebugRecord(rec DebugRecord) error
+}
+
+// ErrDebugSinkFull is returned by HTTPSink when its buffer is full and the
+// record was dropped.
+var ErrDebugSinkFull = errors.New("step/providers/base: debug sink buffer full")
+
+// ErrDebugSinkClosed is returned by HTTPSink after Close.
+var ErrDebugSinkClosed = errors.New("step/providers/base: debug sink closed")
+
+// NewWriterSink returns a sink writing records to w as JSONL, e.g. to
+// os.Stderr for a container's log collector.
+func NewWriterSink(w io.Writer) DebugSink {
+	return &writerSink{enc: json.NewEncoder(w)}
+}
+
+type writerSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (s *writerSink) WriteDebugRecord(rec DebugRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(rec)
+}
+
+// SinkOptions configures an HTTPSink.
+type SinkOptions struct {
+	// Header is added to every request, e.g. for authentication.
+	Header map[string]string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// BatchSize is the most records sent in one request; 0 means 100.
+	BatchSize int
+	// FlushInterval is how long a record may wait for a batch to fill;
+	// 0 means one second.
+	FlushInterval time.Duration
+	// Buffer is the number of records held while a batch is being sent;
+	// 0 means 1000. Records arriving at a full buffer are dropped.
+	Buffer int
+	// OnError, if set, is called with the errors of failed requests, which
+	// are otherwise dropped.
+	OnError func(error)
+	// ServiceName is the OTLP service.name resource attribute; it defaults
+	// to "step". Plain HTTP sinks ignore it.
+	ServiceName string
+}
+
+// HTTPSink sends records in batches from a background goroutine, so writes
+// never wait for the network. Call Close to send the remaining records.
+type HTTPSink struct {
+	url         string
+	contentType string
+	encode      func([]DebugRecord) ([]byte, error)
+	opts        SinkOptions
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan DebugRecord
+	done   chan struct{}
+}
+
+// NewHTTPSink returns a sink that POSTs batches of records to url as JSONL
+// (Content-Type application/x-ndjson).
+func NewHTTPSink(url string, opts SinkOptions) *HTTPSink {
+	return newHTTPSink(url, "application/x-ndjson", encodeJSONL, opts)
+}
+
+// NewOTLPSink returns a sink that exports records as OTLP logs over
+// HTTP/JSON. endpoint is the collector's base URL, e.g.
+// http://localhost:4318; /v1/logs is appended unless it has a path already.
+// Each record becomes a log record whose body is the record's data as JSON,
+// with the provider, model, record type and idempotency key as attributes.
+func NewOTLPSink(endpoint string, opts SinkOptions) *HTTPSink {
+	if u, err := url.Parse(endpoint); err == nil && (u.Path == "" || u.Path == "/") {
+		u.Path = "/v1/logs"
+		endpoint = u.String()
+	}
+	service := cmp.Or(opts.ServiceName, "step")
+	return newHTTPSink(endpoint, "application/json", func(recs []DebugRecord) ([]byte, error) {
+		return encodeOTLP(service, recs)
+	}, opts)
+}
+
+func newHTTPSink(url, contentType string, encode func([]DebugRecord) ([]byte, error), opts SinkOptions) *HTTPSink {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 1000
+	}
+	s := &HTTPSink{
+		url:         url,
+		contentType: contentType,
+		encode:      encode,
+		opts:        opts,
+		queue:       make(chan DebugRecord, opts.Buffer),
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// WriteDebugRecord queues rec. It returns ErrDebugSinkFull when the buffer
+// is full.
+func (s *HTTPSink) WriteDebugRecord(rec DebugRecord) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrDebugSinkClosed
+	}
+	select {
+	case s.queue <- rec:
+		return nil
+	default:
+		return ErrDebugSinkFull
+	}
+}
+
+// Close sends the queued records and stops the sink.
+func (s *HTTPSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]DebugRecord, 0, s.opts.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			if err := s.send(batch); err != nil && s.opts.OnError != nil {
+				s.opts.OnError(err)
+			}
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case rec, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if batch = append(batch, rec); len(batch) >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (s *HTTPSink) send(batch []DebugRecord) error {
+	body, err := s.encode(batch)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	for k, v := range s.opts.Header {
+		req.Header.Set(k, v)
+	}
+	client := s.opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("step/providers/base: debug sink: %s", resp.Status)
+	}
+	return nil
+}
+
+func encodeJSONL(recs []DebugRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// The OTLP/JSON log types; only the fields used here are declared.
+type (
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano   string     `json:"timeUnixNano"`
+		SeverityNumber int        `json:"severityNumber"`
+		SeverityText   string     `json:"severityText"`
+		Body           otlpValue  `json:"body"`
+		Attributes     []otlpAttr `json:"attributes,omitempty"`
+	}
+)
+
+func encodeOTLP(service string, recs []DebugRecord) ([]byte, error) {
+	logs := make([]otlpLogRecord, 0, len(recs))
+	for _, rec := range recs {
+		data, err := json.Marshal(rec.Data)
+		if err != nil {
+			return nil, err
+		}
+		var ts string
+		if t, err := time.Parse(time.RFC3339Nano, rec.Time); err == nil {
+			ts = strconv.FormatInt(t.UnixNano(), 10)
+		}
+		attrs := []otlpAttr{{Key: "step.debug.type", Value: otlpValue{rec.Type}}}
+		for _, a := range []otlpAttr{
+			{Key: "gen_ai.system", Value: otlpValue{rec.Provider}},
+			{Key: "gen_ai.request.model", Value: otlpValue{rec.Model}},
+			{Key: "step.idempotency_key", Value: otlpValue{rec.IdempotencyKey}},
+		} {
+			if a.Value.StringValue != "" {
+				attrs = append(attrs, a)
+			}
+		}
+		logs = append(logs, otlpLogRecord{
+			TimeUnixNano:   ts,
+			SeverityNumber: 5, // DEBUG
+			SeverityText:   "DEBUG",
+			Body:           otlpValue{string(data)},
+			Attributes:     attrs,
+		})
+	}
+	return json.Marshal(map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otlpAttr{{Key: "service.name", Value: otlpValue{service}}},
+			},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": "github.com/inspirepan/step"},
+				"logRecords": logs,
+			}},
+		}},
+	})
+}
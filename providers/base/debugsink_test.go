@@ -0,0 +1,91 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDebugLoggerSink(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := Config{DebugSink: NewWriterSink(&buf)}.DebugLogger()
+	if err != nil || logger == nil {
+		t.Fatalf("expected a logger for the sink, got %v, %v", logger, err)
+	}
+	rec := NewDebugRecord("request", map[string]any{"model": "m"})
+	rec.Provider = "test"
+	if err := logger.Log(rec); err != nil {
+		t.Fatal(err)
+	}
+	var got DebugRecord
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || got.Provider != "test" || got.Type != "request" {
+		t.Errorf("sink got %q (%v)", buf.String(), err)
+	}
+	if logger, _ := (Config{}).DebugLogger(); logger != nil {
+		t.Error("expected no logger without a path or sink")
+	}
+}
+
+func TestHTTPSinks(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+		paths  []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+		paths = append(paths, r.URL.Path+" "+r.Header.Get("Content-Type")+" "+r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	rec := NewDebugRecord("chunk", map[string]any{"delta": "hi"})
+	rec.Provider, rec.Model, rec.IdempotencyKey = "anthropic", "claude", "key-1"
+
+	opts := SinkOptions{Header: map[string]string{"Authorization": "Bearer t"}, BatchSize: 2}
+	jsonl := NewHTTPSink(server.URL+"/ingest", opts)
+	for range 3 {
+		if err := jsonl.WriteDebugRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jsonl.Close()
+	if err := jsonl.WriteDebugRecord(rec); err != ErrDebugSinkClosed {
+		t.Errorf("write after close: %v", err)
+	}
+
+	otlp := NewOTLPSink(server.URL, opts)
+	_ = otlp.WriteDebugRecord(rec)
+	otlp.Close()
+
+	if len(bodies) != 3 {
+		t.Fatalf("got %d requests, want two JSONL batches and one OTLP export", len(bodies))
+	}
+	if paths[0] != "/ingest application/x-ndjson Bearer t" || strings.Count(bodies[0], "\n") != 2 || strings.Count(bodies[1], "\n") != 1 {
+		t.Errorf("JSONL requests %q: %q", paths[:2], bodies[:2])
+	}
+	if paths[2] != "/v1/logs application/json Bearer t" {
+		t.Errorf("OTLP request %q", paths[2])
+	}
+	var export struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []otlpLogRecord
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(bodies[2]), &export); err != nil {
+		t.Fatal(err)
+	}
+	lr := export.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if lr.Body.StringValue != `{"delta":"hi"}` || lr.TimeUnixNano == "" || len(lr.Attributes) != 4 {
+		t.Errorf("log record %+v", lr)
+	}
+}
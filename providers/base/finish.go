@@ -0,0 +1,39 @@
+package base
+
+import (
+	"context"
+
+	"github.com/inspirepan/step"
+)
+
+// MapFinishReasons wraps stream so that final messages whose raw finish reason
+// (Provenance.FinishReason, then Provenance.NativeFinishReason) is a key of
+// reasons get the mapped StopReason, overriding the provider's built-in
+// mapping. A nil or empty map returns stream unchanged.
+func MapFinishReasons(stream step.ProviderStream, reasons map[string]step.StopReason) step.ProviderStream {
+	if len(reasons) == 0 {
+		return stream
+	}
+	return &finishStream{ProviderStream: stream, reasons: reasons}
+}
+
+type finishStream struct {
+	step.ProviderStream
+	reasons map[string]step.StopReason
+}
+
+func (s *finishStream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	up, err := s.ProviderStream.Next(ctx)
+	u, ok := up.(step.ProviderMessageUpdate)
+	if !ok || u.Message.Provenance == nil {
+		return up, err
+	}
+	prov := u.Message.Provenance
+	for _, raw := range []string{prov.FinishReason, prov.NativeFinishReason} {
+		if reason, ok := s.reasons[raw]; ok && raw != "" {
+			u.Message.StopReason = reason
+			return u, err
+		}
+	}
+	return up, err
+}
@@ -0,0 +1,31 @@
+package base
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestMapFinishReasons(t *testing.T) {
+	msg := step.AssistantMessage{
+		Parts:      []step.Part{step.TextPart{Text: "partial"}},
+		StopReason: step.StopStop,
+		Provenance: &step.Provenance{FinishReason: "stop", NativeFinishReason: "guardrail"},
+	}
+	reasons := map[string]step.StopReason{"guardrail": step.StopContentFilter}
+	stream := MapFinishReasons(mock.NewStream(msg, 0), reasons)
+	for {
+		up, err := stream.Next(context.Background())
+		if err != nil {
+			t.Fatal("stream ended without a message")
+		}
+		if u, ok := up.(step.ProviderMessageUpdate); ok {
+			if u.Message.StopReason != step.StopContentFilter || u.Message.Provenance.FinishReason != "stop" {
+				t.Errorf("unexpected message %+v", u.Message)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+// ResponseFormat selects the shape of the model's text output.
+type ResponseFormat string
+
+const (
+	// ResponseFormatText is unconstrained text (the default).
+	ResponseFormatText ResponseFormat = ""
+	// ResponseFormatJSONObject asks for a single JSON value without a schema.
+	ResponseFormatJSONObject ResponseFormat = "json_object"
+)
+
+// JSONModeInstruction is appended to the system prompt in JSON mode unless the
+// prompt already mentions JSON. OpenAI rejects json_object requests whose input
+// never mentions JSON, and Anthropic has no native JSON mode at all.
+const JSONModeInstruction = "Respond with a single valid JSON object and no other text."
+
+// JSONModeSystemPrompt returns prompt with JSONModeInstruction appended when needed.
+func JSONModeSystemPrompt(prompt string) string {
+	if strings.Contains(strings.ToLower(prompt), "json") {
+		return prompt
+	}
+	if prompt == "" {
+		return JSONModeInstruction
+	}
+	return prompt + "\n\n" + JSONModeInstruction
+}
+
+// ValidateJSON wraps stream so that a final assistant message whose text does
+// not parse as JSON is reported as a *step.InvalidJSONError instead of being
+// emitted. Messages that stop for tool use, errors or cancellation pass through.
+// prefix is the prefill text the response continues, if any (see step.Prefill).
+func ValidateJSON(stream step.ProviderStream, prefix string) step.ProviderStream {
+	return &jsonStream{ProviderStream: stream, prefix: prefix}
+}
+
+type jsonStream struct {
+	step.ProviderStream
+	prefix string
+}
+
+func (s *jsonStream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	up, err := s.ProviderStream.Next(ctx)
+	mu, ok := up.(step.ProviderMessageUpdate)
+	if !ok {
+		return up, err
+	}
+	switch mu.Message.StopReason {
+	case step.StopStop, step.StopLength:
+	default:
+		return up, err
+	}
+	var v any
+	if jsonErr := json.Unmarshal([]byte(s.prefix+mu.Message.Text()), &v); jsonErr != nil {
+		return nil, &step.InvalidJSONError{Message: mu.Message, Err: jsonErr}
+	}
+	return up, err
+}
+
+// PrefillText returns the text of the trailing prefill in history, or "".
+func PrefillText(history []step.Message) string {
+	if m, ok := step.Prefill(history); ok {
+		return m.Text()
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package base_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+type jsonProvider struct{ *mock.Provider }
+
+func (p jsonProvider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	s, err := p.Provider.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return base.ValidateJSON(s, ""), nil
+}
+
+func TestValidateJSON(t *testing.T) {
+	history := []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}}
+	provider := jsonProvider{mock.New(
+		mock.Text(`{"ok": true}`),
+		mock.Text("Sure! Here is the JSON: {"),
+		mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "missing", ArgsJSON: []byte(`{}`)}),
+	)}
+
+	if _, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history}); err != nil {
+		t.Fatalf("valid JSON: unexpected error %v", err)
+	}
+
+	_, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history})
+	var invalid *step.InvalidJSONError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected InvalidJSONError, got %v", err)
+	}
+	if len(invalid.Message.Parts) != 1 {
+		t.Errorf("expected the rejected message on the error, got %+v", invalid.Message)
+	}
+
+	if _, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history}); err != nil {
+		t.Fatalf("tool calls should not be validated: %v", err)
+	}
+}
+
+func TestJSONModeSystemPrompt(t *testing.T) {
+	if got := base.JSONModeSystemPrompt(""); got != base.JSONModeInstruction {
+		t.Errorf("empty prompt: got %q", got)
+	}
+	if got := base.JSONModeSystemPrompt("Reply in JSON."); got != "Reply in JSON." {
+		t.Errorf("prompt mentioning JSON should be unchanged, got %q", got)
+	}
+	if got := base.JSONModeSystemPrompt("Be brief."); got != "Be brief.\n\n"+base.JSONModeInstruction {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// ErrNoAPIKeys is returned when a KeyProvider returns no keys.
+var ErrNoAPIKeys = errors.New("step/providers/base: no API keys")
+
+// KeyProvider supplies API keys at request time, for example from a secret
+// manager, so rotated credentials are picked up without a restart.
+// Implementations must be safe for concurrent use and should cache keys, since
+// APIKeys is called for every request.
+type KeyProvider interface {
+	// APIKeys returns the keys to use, in order of preference.
+	APIKeys(ctx context.Context) ([]string, error)
+}
+
+// StaticKeys is a KeyProvider with a fixed set of keys.
+type StaticKeys []string
+
+func (k StaticKeys) APIKeys(context.Context) ([]string, error) { return k, nil }
+
+// KeyFunc adapts a function to a KeyProvider.
+type KeyFunc func(ctx context.Context) ([]string, error)
+
+func (f KeyFunc) APIKeys(ctx context.Context) ([]string, error) { return f(ctx) }
+
+// SetKey writes an API key into request headers.
+type SetKey func(h http.Header, key string)
+
+// BearerKey sends the key as an Authorization bearer token.
+func BearerKey(h http.Header, key string) { h.Set("Authorization", "Bearer "+key) }
+
+// HeaderKey sends the key in the named header.
+func HeaderKey(name string) SetKey {
+	return func(h http.Header, key string) { h.Set(name, key) }
+}
+
+// KeyRotation spreads requests over several API keys. Each request uses the
+// current key; a 401 or 429 response moves on to the next key and retries,
+// until every key has been tried once. The key that last worked stays current
+// for later requests.
+type KeyRotation struct {
+	keys KeyProvider
+	set  SetKey
+	next atomic.Uint64
+}
+
+// NewKeyRotation returns a KeyRotation over keys, or nil if keys is nil.
+// A nil *KeyRotation sends requests unchanged.
+func NewKeyRotation(keys KeyProvider, set SetKey) *KeyRotation {
+	if keys == nil {
+		return nil
+	}
+	return &KeyRotation{keys: keys, set: set}
+}
+
+// RoundTrip sends req through next with the current key, rotating as
+// described above. It has the signature of an SDK middleware.
+func (r *KeyRotation) RoundTrip(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if r == nil {
+		return next(req)
+	}
+	keys, err := r.keys.APIKeys(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoAPIKeys
+	}
+
+	n := uint64(len(keys))
+	start := r.next.Load()
+	attempt := req
+	for i := uint64(0); ; i++ {
+		idx := (start + i) % n
+		r.set(attempt.Header, keys[idx])
+		resp, err := next(attempt)
+		if err != nil || !rotate(resp.StatusCode) {
+			if err == nil && i > 0 {
+				r.next.CompareAndSwap(start, idx)
+			}
+			return resp, err
+		}
+		if i == n-1 {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+		if attempt, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func rotate(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusTooManyRequests
+}
+
+// rewind returns a copy of req with a fresh body for another attempt.
+func rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("step/providers/base: cannot retry a request without GetBody")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
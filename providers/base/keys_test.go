@@ -0,0 +1,88 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKeyRotation(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		body, _ := io.ReadAll(r.Body)
+		seen = append(seen, key+":"+string(body))
+		switch key {
+		case "revoked":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	keys := NewKeyRotation(StaticKeys{"revoked", "limited", "good"}, BearerKey)
+	send := func() int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("body"))
+		resp, err := keys.RoundTrip(req, http.DefaultClient.Do)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := send(); status != http.StatusOK {
+		t.Fatalf("expected the third key to succeed, got %d", status)
+	}
+	// The working key stays current.
+	if status := send(); status != http.StatusOK {
+		t.Fatalf("expected the current key to succeed, got %d", status)
+	}
+	want := []string{"revoked:body", "limited:body", "good:body", "good:body"}
+	if strings.Join(seen, " ") != strings.Join(want, " ") {
+		t.Errorf("got attempts %q, want %q", seen, want)
+	}
+
+	// When every key fails, the last response is returned.
+	seen = nil
+	keys = NewKeyRotation(StaticKeys{"revoked", "limited"}, BearerKey)
+	if status := send(); status != http.StatusTooManyRequests || len(seen) != 2 {
+		t.Errorf("expected two attempts ending in 429, got %d after %q", status, seen)
+	}
+}
+
+func TestKeyRotation_KeyProvider(t *testing.T) {
+	var calls int
+	keys := NewKeyRotation(KeyFunc(func(context.Context) ([]string, error) {
+		calls++
+		if calls > 1 {
+			return nil, nil
+		}
+		return []string{"k"}, nil
+	}), HeaderKey("X-Api-Key"))
+
+	var got string
+	next := func(req *http.Request) (*http.Response, error) {
+		got = req.Header.Get("X-Api-Key")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.invalid", nil)
+	if _, err := keys.RoundTrip(req, next); err != nil || got != "k" {
+		t.Errorf("expected key k, got %q (%v)", got, err)
+	}
+	if _, err := keys.RoundTrip(req, next); !errors.Is(err, ErrNoAPIKeys) {
+		t.Errorf("expected keys to be fetched per request, got %v", err)
+	}
+
+	// A nil rotation leaves requests alone.
+	var none *KeyRotation
+	got = ""
+	if _, err := none.RoundTrip(req, next); err != nil || got != "k" {
+		t.Errorf("expected the request unchanged, got %q (%v)", got, err)
+	}
+}
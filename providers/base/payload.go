@@ -0,0 +1,24 @@
+package base
+
+import "encoding/json"
+
+// MarshalWithExtra encodes v and merges extra top-level fields into the object,
+// the way SDK clients apply ExtraBody. Extra fields override fields of v.
+func MarshalWithExtra(v any, extra map[string]any) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	for k, val := range extra {
+		raw, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		obj[k] = raw
+	}
+	return json.Marshal(obj)
+}
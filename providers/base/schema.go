@@ -0,0 +1,208 @@
+package base
+
+import "slices"
+
+// Schema normalizers adapt one ToolSpec.Parameters JSON schema to what each
+// provider accepts. They never modify their input.
+
+// StrictSchema returns a copy of schema that satisfies OpenAI strict mode:
+// every object has additionalProperties:false and lists all of its properties
+// as required. Properties that were optional become nullable so the model can
+// still omit a value by sending null.
+func StrictSchema(schema map[string]any) map[string]any {
+	out, _ := strictNode(cloneSchema(schema)).(map[string]any)
+	return out
+}
+
+func strictNode(node any) any {
+	switch v := node.(type) {
+	case map[string]any:
+		for _, key := range []string{"items", "not", "additionalItems"} {
+			if child, ok := v[key]; ok {
+				v[key] = strictNode(child)
+			}
+		}
+		for _, key := range []string{"anyOf", "oneOf", "allOf", "prefixItems"} {
+			if list, ok := v[key].([]any); ok {
+				for i := range list {
+					list[i] = strictNode(list[i])
+				}
+			}
+		}
+		for _, key := range []string{"$defs", "definitions"} {
+			if defs, ok := v[key].(map[string]any); ok {
+				for name, def := range defs {
+					defs[name] = strictNode(def)
+				}
+			}
+		}
+		props, isObject := v["properties"].(map[string]any)
+		if !isObject && v["type"] != "object" {
+			return v
+		}
+		required := stringList(v["required"])
+		names := make([]string, 0, len(props))
+		for name, prop := range props {
+			prop = strictNode(prop)
+			if !slices.Contains(required, name) {
+				prop = nullable(prop)
+			}
+			props[name] = prop
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		if props != nil {
+			v["properties"] = props
+		} else {
+			v["properties"] = map[string]any{}
+		}
+		v["required"] = names
+		v["additionalProperties"] = false
+		return v
+	default:
+		return node
+	}
+}
+
+// nullable widens a property schema to also accept null.
+func nullable(prop any) any {
+	m, ok := prop.(map[string]any)
+	if !ok {
+		return prop
+	}
+	switch t := m["type"].(type) {
+	case string:
+		if t != "null" {
+			m["type"] = []any{t, "null"}
+		}
+	case []any:
+		if !slices.Contains(t, any("null")) {
+			m["type"] = append(t, "null")
+		}
+	default:
+		if anyOf, ok := m["anyOf"].([]any); ok {
+			m["anyOf"] = append(anyOf, map[string]any{"type": "null"})
+		}
+	}
+	if enum, ok := m["enum"].([]any); ok && !slices.Contains(enum, nil) {
+		m["enum"] = append(enum, nil)
+	}
+	return m
+}
+
+// geminiUnsupported lists JSON schema keywords Gemini function declarations reject.
+var geminiUnsupported = []string{
+	"$schema", "$id", "$comment", "$defs", "definitions", "$ref",
+	"additionalProperties", "unevaluatedProperties", "patternProperties",
+	"examples", "default", "const", "if", "then", "else", "not",
+	"dependentRequired", "dependentSchemas", "contentEncoding", "contentMediaType",
+}
+
+// GeminiSchema returns a copy of schema restricted to the OpenAPI subset Gemini
+// accepts: unsupported keywords are dropped, const becomes a one-value enum, and
+// nullable type unions like ["string","null"] become type plus nullable:true.
+func GeminiSchema(schema map[string]any) map[string]any {
+	out, _ := geminiNode(cloneSchema(schema)).(map[string]any)
+	return out
+}
+
+func geminiNode(node any) any {
+	v, ok := node.(map[string]any)
+	if !ok {
+		return node
+	}
+	if c, ok := v["const"]; ok {
+		if _, hasEnum := v["enum"]; !hasEnum {
+			v["enum"] = []any{c}
+		}
+	}
+	for _, key := range geminiUnsupported {
+		delete(v, key)
+	}
+	if types, ok := v["type"].([]any); ok {
+		var rest []any
+		for _, t := range types {
+			if t == "null" {
+				v["nullable"] = true
+			} else {
+				rest = append(rest, t)
+			}
+		}
+		if len(rest) == 1 {
+			v["type"] = rest[0]
+		} else {
+			v["type"] = rest
+		}
+	}
+	if enum, ok := v["enum"].([]any); ok {
+		// Gemini enums must be strings.
+		v["enum"] = slices.DeleteFunc(enum, func(e any) bool {
+			if e == nil {
+				v["nullable"] = true
+			}
+			_, isString := e.(string)
+			return !isString
+		})
+	}
+	if props, ok := v["properties"].(map[string]any); ok {
+		for name, prop := range props {
+			props[name] = geminiNode(prop)
+		}
+	}
+	if items, ok := v["items"]; ok {
+		v["items"] = geminiNode(items)
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		if list, ok := v[key].([]any); ok {
+			for i := range list {
+				list[i] = geminiNode(list[i])
+			}
+		}
+	}
+	return v
+}
+
+// cloneSchema deep-copies the maps and slices of a decoded JSON schema.
+func cloneSchema(schema map[string]any) map[string]any {
+	if schema == nil {
+		return nil
+	}
+	out, _ := cloneValue(schema).(map[string]any)
+	return out
+}
+
+func cloneValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(t))
+		for k, val := range t {
+			m[k] = cloneValue(val)
+		}
+		return m
+	case []any:
+		s := make([]any, len(t))
+		for i, val := range t {
+			s[i] = cloneValue(val)
+		}
+		return s
+	case []string:
+		s := make([]any, len(t))
+		for i, val := range t {
+			s[i] = val
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func stringList(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
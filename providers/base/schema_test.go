@@ -0,0 +1,90 @@
+package base_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/inspirepan/step/providers/base"
+)
+
+func decode(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func encode(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStrictSchema(t *testing.T) {
+	in := decode(t, `{
+		"type": "object",
+		"properties": {
+			"path": {"type": "string"},
+			"limit": {"type": "integer"},
+			"mode": {"type": "string", "enum": ["a", "b"]},
+			"opts": {"type": "object", "properties": {"deep": {"type": "boolean"}}}
+		},
+		"required": ["path"]
+	}`)
+	before := encode(t, in)
+
+	got := encode(t, base.StrictSchema(in))
+	want := encode(t, decode(t, `{
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["limit", "mode", "opts", "path"],
+		"properties": {
+			"path": {"type": "string"},
+			"limit": {"type": ["integer", "null"]},
+			"mode": {"type": ["string", "null"], "enum": ["a", "b", null]},
+			"opts": {
+				"type": ["object", "null"],
+				"additionalProperties": false,
+				"required": ["deep"],
+				"properties": {"deep": {"type": ["boolean", "null"]}}
+			}
+		}
+	}`))
+	if got != want {
+		t.Errorf("StrictSchema:\n got %s\nwant %s", got, want)
+	}
+	if encode(t, in) != before {
+		t.Error("StrictSchema modified its input")
+	}
+}
+
+func TestGeminiSchema(t *testing.T) {
+	in := decode(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": ["string", "null"], "default": "x"},
+			"kind": {"const": "file"},
+			"tags": {"type": "array", "items": {"type": "string", "examples": ["a"]}}
+		}
+	}`)
+
+	got := encode(t, base.GeminiSchema(in))
+	want := encode(t, decode(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "nullable": true},
+			"kind": {"enum": ["file"]},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`))
+	if got != want {
+		t.Errorf("GeminiSchema:\n got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package base
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/inspirepan/step"
+)
+
+// MinCompressBytes is the smallest request body that is gzip-compressed
+// when compression is enabled; smaller bodies are not worth the overhead.
+const MinCompressBytes = 1024
+
+// TransferStats counts the bytes of one provider request and its response.
+// It is safe for concurrent use.
+type TransferStats struct {
+	requestBytes  atomic.Int64
+	responseBytes atomic.Int64
+}
+
+// RequestBytes is the size of the serialized request body before compression.
+func (s *TransferStats) RequestBytes() int64 { return s.requestBytes.Load() }
+
+// ResponseBytes is the number of response body bytes read so far.
+func (s *TransferStats) ResponseBytes() int64 { return s.responseBytes.Load() }
+
+type transferStatsKey struct{}
+
+// TrackTransfer returns a context whose requests are counted by TransferMiddleware.
+func TrackTransfer(ctx context.Context) (context.Context, *TransferStats) {
+	stats := &TransferStats{}
+	return context.WithValue(ctx, transferStatsKey{}, stats), stats
+}
+
+// TransferMiddleware returns an SDK middleware that records request and response
+// sizes for requests made with a TrackTransfer context. When compress is true,
+// request bodies of at least MinCompressBytes are sent gzip-encoded; only enable
+// it for endpoints that accept Content-Encoding: gzip.
+func TransferMiddleware(compress bool) func(*http.Request, func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	return func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		return RoundTrip(req, next, compress)
+	}
+}
+
+// RoundTrip sends req through next, counting and optionally compressing it
+// as described in TransferMiddleware.
+func RoundTrip(req *http.Request, next func(*http.Request) (*http.Response, error), compress bool) (*http.Response, error) {
+	stats, _ := req.Context().Value(transferStatsKey{}).(*TransferStats)
+	if req.Body != nil && req.Body != http.NoBody && (stats != nil || compress) {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if stats != nil {
+			// Retries send the same body again; the last attempt is what counts.
+			stats.requestBytes.Store(int64(len(body)))
+			stats.responseBytes.Store(0)
+		}
+		if compress && len(body) >= MinCompressBytes {
+			if body, err = gzipBytes(body); err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		req.ContentLength = int64(len(body))
+	}
+
+	resp, err := next(req)
+	if err != nil || stats == nil {
+		return resp, err
+	}
+	resp.Body = &countingReader{ReadCloser: resp.Body, n: &stats.responseBytes}
+	return resp, nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// ReportTransfer wraps stream so final messages carry the sizes recorded in stats
+// in Provenance.RequestBytes and Provenance.ResponseBytes.
+func ReportTransfer(stream step.ProviderStream, stats *TransferStats) step.ProviderStream {
+	return &transferStream{ProviderStream: stream, stats: stats}
+}
+
+type transferStream struct {
+	step.ProviderStream
+	stats *TransferStats
+}
+
+func (s *transferStream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	up, err := s.ProviderStream.Next(ctx)
+	if u, ok := up.(step.ProviderMessageUpdate); ok {
+		prov := step.Provenance{}
+		if u.Message.Provenance != nil {
+			prov = *u.Message.Provenance
+		}
+		prov.RequestBytes = s.stats.RequestBytes()
+		prov.ResponseBytes = s.stats.ResponseBytes()
+		u.Message.Provenance = &prov
+		up = u
+	}
+	return up, err
+}
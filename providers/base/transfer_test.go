@@ -0,0 +1,65 @@
+package base
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var encoding string
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		var body io.Reader = r.Body
+		if encoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("invalid gzip body: %v", err)
+				return
+			}
+			body = zr
+		}
+		received, _ = io.ReadAll(body)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name     string
+		size     int
+		compress bool
+		want     string
+	}{
+		{"plain", 2048, false, ""},
+		{"small", 16, true, ""},
+		{"compressed", 2048, true, "gzip"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := []byte(`"` + strings.Repeat("a", tc.size-2) + `"`)
+			ctx, stats := TrackTransfer(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, bytes.NewReader(payload))
+			resp, err := RoundTrip(req, http.DefaultClient.Do, tc.compress)
+			if err != nil {
+				t.Fatalf("RoundTrip failed: %v", err)
+			}
+			_, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+
+			if encoding != tc.want {
+				t.Errorf("expected Content-Encoding %q, got %q", tc.want, encoding)
+			}
+			if !bytes.Equal(received, payload) {
+				t.Errorf("server received %d bytes, want %d", len(received), len(payload))
+			}
+			if stats.RequestBytes() != int64(tc.size) || stats.ResponseBytes() != 2 {
+				t.Errorf("expected %d/2 bytes, got %d/%d", tc.size, stats.RequestBytes(), stats.ResponseBytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Package cache provides a Provider wrapper that caches final assistant messages,
+// so repeated identical requests (deterministic test suites, eval reruns) are
+// answered without calling the model. It is unrelated to provider prompt caching;
+// see base.CacheStrategy for that.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/health"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+// MetadataHit is set to true on messages served from the cache.
+const MetadataHit = "cache.hit"
+
+// Store persists cached messages by key. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the message stored under key, if any.
+	Get(ctx context.Context, key string) (step.AssistantMessage, bool, error)
+	// Put stores msg under key, replacing any existing entry.
+	Put(ctx context.Context, key string, msg step.AssistantMessage) error
+}
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithNamespace mixes name into every key, so providers sharing a store do not
+// see each other's entries. It is needed for providers that do not implement
+// step.PayloadBuilder, whose keys cannot include the model and parameters.
+func WithNamespace(name string) Option {
+	return func(p *Provider) { p.namespace = name }
+}
+
+// Provider serves requests from a Store and forwards misses to the wrapped provider.
+//
+// The key is a hash of the request payload when the wrapped provider implements
+// step.PayloadBuilder, which covers the model, messages and every parameter sent.
+// Otherwise it hashes the system prompt, history and tool specs. Normalizers
+// (see WithDevMode) rewrite the request before it is hashed.
+//
+// Only complete messages are stored: errors, aborted or cancelled turns and
+// multi-candidate requests are never cached. Store failures are treated as
+// misses and never fail the request.
+type Provider struct {
+	provider    step.Provider
+	store       Store
+	namespace   string
+	normalizers []Normalizer
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+var (
+	_ step.Provider       = (*Provider)(nil)
+	_ step.PayloadBuilder = (*Provider)(nil)
+	_ step.ModelLister    = (*Provider)(nil)
+	_ step.Pinger         = (*Provider)(nil)
+)
+
+// New wraps provider with a response cache backed by store.
+func New(provider step.Provider, store Store, opts ...Option) *Provider {
+	p := &Provider{provider: provider, store: store}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Hits returns the number of requests served from the cache.
+func (p *Provider) Hits() int64 { return p.hits.Load() }
+
+// Misses returns the number of cacheable requests forwarded to the wrapped provider.
+func (p *Provider) Misses() int64 { return p.misses.Load() }
+
+func (p *Provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	if req.N > 1 {
+		return p.provider.Stream(ctx, req)
+	}
+	key, err := p.Key(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if msg, ok, err := p.store.Get(ctx, key); err == nil && ok {
+		p.hits.Add(1)
+		msg.Timestamp = time.Now().UnixMilli()
+		msg.Metadata = msg.Metadata.Clone()
+		msg.Metadata.Set(MetadataHit, true)
+		return mock.NewStream(msg, 0), nil
+	}
+
+	p.misses.Add(1)
+	stream, err := p.provider.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingStream{ProviderStream: stream, store: p.store, key: key}, nil
+}
+
+// BuildPayload forwards to the wrapped provider, so step.DryRun sees through the cache.
+func (p *Provider) BuildPayload(ctx context.Context, req step.ProviderRequest) (step.Payload, error) {
+	builder, ok := p.provider.(step.PayloadBuilder)
+	if !ok {
+		return step.Payload{}, step.ErrDryRunUnsupported
+	}
+	return builder.BuildPayload(ctx, req)
+}
+
+// ListModels forwards to the wrapped provider; model lists are never cached.
+func (p *Provider) ListModels(ctx context.Context) ([]step.ModelInfo, error) {
+	return step.ListModels(ctx, p.provider)
+}
+
+// Ping checks the wrapped provider; see health.Check.
+func (p *Provider) Ping(ctx context.Context) error {
+	return health.Check(ctx, p.provider)
+}
+
+// Key returns the cache key for req, after applying any normalizers.
+func (p *Provider) Key(ctx context.Context, req step.ProviderRequest) (string, error) {
+	for _, n := range p.normalizers {
+		req = n(req)
+	}
+	h := sha256.New()
+	h.Write([]byte(p.namespace))
+	h.Write([]byte{0})
+	if builder, ok := p.provider.(step.PayloadBuilder); ok {
+		payload, err := builder.BuildPayload(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(payload.URL))
+		h.Write([]byte{0})
+		h.Write(payload.Body)
+	} else {
+		data, err := json.Marshal(struct {
+			SystemPrompt string          `json:"system_prompt"`
+			History      []step.Message  `json:"history"`
+			Tools        []step.ToolSpec `json:"tools"`
+			Model        string          `json:"model,omitempty"`
+		}{req.SystemPrompt, stripIdentity(req.History), req.Tools, req.ModelOverride})
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stripIdentity clears fields that differ between otherwise identical histories
+// and are never sent to providers.
+func stripIdentity(history []step.Message) []step.Message {
+	out := make([]step.Message, len(history))
+	for i, msg := range history {
+		switch m := msg.(type) {
+		case step.UserMessage:
+			m.ID, m.ParentID, m.Timestamp, m.Metadata = "", "", 0, nil
+			out[i] = m
+		case step.AssistantMessage:
+			m.ID, m.ParentID, m.Timestamp, m.Metadata = "", "", 0, nil
+			m.Usage, m.Provenance, m.Stats = nil, nil, nil
+			out[i] = m
+		case step.ToolResultMessage:
+			m.ID, m.ParentID, m.Timestamp, m.Metadata = "", "", 0, nil
+			m.Details = nil
+			out[i] = m
+		default:
+			out[i] = msg
+		}
+	}
+	return out
+}
+
+// recordingStream stores the final message of a successful stream.
+type recordingStream struct {
+	step.ProviderStream
+	store Store
+	key   string
+}
+
+func (s *recordingStream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	up, err := s.ProviderStream.Next(ctx)
+	if u, ok := up.(step.ProviderMessageUpdate); ok && u.Candidate == 0 && ctx.Err() == nil && cacheable(u.Message.StopReason) {
+		msg := u.Message
+		msg.ID, msg.ParentID = "", ""
+		_ = s.store.Put(ctx, s.key, msg)
+	}
+	return up, err
+}
+
+func cacheable(reason step.StopReason) bool {
+	switch reason {
+	case step.StopStop, step.StopLength, step.StopToolUse:
+		return true
+	default:
+		return false
+	}
+}
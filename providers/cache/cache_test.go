@@ -0,0 +1,126 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/cache"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func userTurn(text string) []step.Message {
+	return step.AssignIDs([]step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: text}}}})
+}
+
+func TestCache_ExactMatch(t *testing.T) {
+	inner := mock.New(mock.Text("4"), mock.Text("6"))
+	store := cache.NewMemoryStore()
+	provider := cache.New(inner, store)
+
+	run := func(text string) step.AssistantMessage {
+		t.Helper()
+		result, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: userTurn(text)})
+		if err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+		return result[0].(step.AssistantMessage)
+	}
+
+	first := run("2+2?")
+	if hit, _ := first.Metadata.Bool(cache.MetadataHit); hit {
+		t.Error("first call must not be a cache hit")
+	}
+	// Message IDs and timestamps differ between runs but do not affect the key.
+	second := run("2+2?")
+	if hit, _ := second.Metadata.Bool(cache.MetadataHit); !hit || second.Text() != "4" {
+		t.Errorf("expected cached answer, got %q (metadata %v)", second.Text(), second.Metadata)
+	}
+	if second.ID == "" || second.ID == first.ID {
+		t.Errorf("cached message must get a fresh ID, got %q", second.ID)
+	}
+	if got := run("3+3?").Text(); got != "6" {
+		t.Errorf("expected a miss for a different prompt, got %q", got)
+	}
+	if provider.Hits() != 1 || provider.Misses() != 2 || len(inner.Requests()) != 2 {
+		t.Errorf("expected 1 hit and 2 misses, got %d/%d with %d requests", provider.Hits(), provider.Misses(), len(inner.Requests()))
+	}
+}
+
+func TestCache_SkipsErrors(t *testing.T) {
+	inner := mock.New(
+		mock.Response{Message: step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "partial"}}, StopReason: step.StopError}},
+		mock.Text("done"),
+	)
+	provider := cache.New(inner, cache.NewMemoryStore())
+	req := step.StepRequest{Provider: provider, History: userTurn("hello")}
+	for range 2 {
+		if _, err := step.Step(context.Background(), req); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	if provider.Hits() != 0 || inner.Remaining() != 0 {
+		t.Errorf("error responses must not be cached, got %d hits", provider.Hits())
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	store, err := cache.NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}, StopReason: step.StopStop}
+	if err := store.Put(ctx, "k", msg); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	reopened, err := cache.NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := reopened.Get(ctx, "k")
+	if err != nil || !ok || got.Text() != "hi" {
+		t.Fatalf("expected stored message, got %v %v %v", got, ok, err)
+	}
+	if _, ok, err := reopened.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("expected clean miss, got %v %v", ok, err)
+	}
+}
+
+func TestCache_ProviderError(t *testing.T) {
+	boom := errors.New("boom")
+	provider := cache.New(mock.New(mock.Response{Err: boom}), cache.NewMemoryStore())
+	_, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: userTurn("hello")})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+}
+
+func TestCache_DevMode(t *testing.T) {
+	inner := mock.New(mock.Text("ok"), mock.Text("changed"))
+	provider := cache.New(inner, cache.NewMemoryStore(), cache.WithDevMode())
+
+	run := func(system, text string) string {
+		t.Helper()
+		result, err := step.Step(context.Background(), step.StepRequest{Provider: provider, SystemPrompt: system, History: userTurn(text)})
+		if err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+		return result[0].(step.AssistantMessage).Text()
+	}
+
+	run("Today is 2025-03-01.", "List files.<system-reminder>3 todos open</system-reminder>")
+	if got := run("Today is 2025-03-02.", "List files.<system-reminder>1 todo open</system-reminder>"); got != "ok" {
+		t.Errorf("expected a hit for a prompt differing in volatile content, got %q", got)
+	}
+	if got := run("Today is 2025-03-02.", "List directories."); got != "changed" {
+		t.Errorf("expected a miss for a changed prompt, got %q", got)
+	}
+	sent := inner.Requests()[0].History[0].(step.UserMessage).Parts[0].(step.TextPart).Text
+	if sent != "List files.<system-reminder>3 todos open</system-reminder>" {
+		t.Errorf("normalization must not change the request sent, got %q", sent)
+	}
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"regexp"
+
+	"github.com/inspirepan/step"
+)
+
+// Normalizer rewrites a request before its cache key is computed. It only
+// affects the key; the wrapped provider always receives the original request.
+type Normalizer func(step.ProviderRequest) step.ProviderRequest
+
+// WithNormalizer adds a Normalizer. Normalizers run in the order given.
+func WithNormalizer(n Normalizer) Option {
+	return func(p *Provider) { p.normalizers = append(p.normalizers, n) }
+}
+
+// WithDevMode keys requests on normalized prompts, so agent turns that differ only
+// in volatile content (reminders, timestamps) are answered from the cache while
+// iterating on downstream code. extra patterns are ignored in addition to
+// DefaultIgnorePatterns. A dev-mode cache may replay answers to prompts that are
+// not byte-identical; do not use it where that matters.
+func WithDevMode(extra ...*regexp.Regexp) Option {
+	return WithNormalizer(IgnorePatterns(append(DefaultIgnorePatterns(), extra...)...))
+}
+
+// DefaultIgnorePatterns returns the patterns WithDevMode ignores:
+// <system-reminder> blocks, ISO 8601 dates and times, and clock times.
+func DefaultIgnorePatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile(`(?s)<system-reminder>.*?</system-reminder>`),
+		regexp.MustCompile(`\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?)?`),
+		regexp.MustCompile(`\b\d{1,2}:\d{2}(?::\d{2})?(?:\s?[AaPp][Mm])?\b`),
+	}
+}
+
+// IgnorePatterns returns a Normalizer that removes matches of patterns from the
+// system prompt and from text parts of every message.
+func IgnorePatterns(patterns ...*regexp.Regexp) Normalizer {
+	clean := func(s string) string {
+		for _, re := range patterns {
+			s = re.ReplaceAllString(s, "")
+		}
+		return s
+	}
+	cleanParts := func(parts []step.Part) []step.Part {
+		out := make([]step.Part, len(parts))
+		for i, part := range parts {
+			if tp, ok := part.(step.TextPart); ok {
+				tp.Text = clean(tp.Text)
+				part = tp
+			}
+			out[i] = part
+		}
+		return out
+	}
+	return func(req step.ProviderRequest) step.ProviderRequest {
+		req.SystemPrompt = clean(req.SystemPrompt)
+		history := make([]step.Message, len(req.History))
+		for i, msg := range req.History {
+			switch m := msg.(type) {
+			case step.UserMessage:
+				m.Parts = cleanParts(m.Parts)
+				history[i] = m
+			case step.AssistantMessage:
+				m.Parts = cleanParts(m.Parts)
+				history[i] = m
+			case step.ToolResultMessage:
+				m.Parts = cleanParts(m.Parts)
+				history[i] = m
+			default:
+				history[i] = msg
+			}
+		}
+		req.History = history
+		return req
+	}
+}
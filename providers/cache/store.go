@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/inspirepan/step"
+)
+
+// MemoryStore is an in-process Store.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]step.AssistantMessage
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]step.AssistantMessage)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (step.AssistantMessage, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	msg, ok := s.entries[key]
+	return msg, ok, nil
+}
+
+func (s *MemoryStore) Put(_ context.Context, key string, msg step.AssistantMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = msg
+	return nil
+}
+
+// Len returns the number of stored entries.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// DirStore persists each entry as a JSON file in a directory, so a cache
+// survives across processes and can be committed alongside test fixtures.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a DirStore rooted at dir, creating it if needed.
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+func (s *DirStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *DirStore) Get(_ context.Context, key string) (step.AssistantMessage, bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return step.AssistantMessage{}, false, nil
+	}
+	if err != nil {
+		return step.AssistantMessage{}, false, err
+	}
+	var msg step.AssistantMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return step.AssistantMessage{}, false, err
+	}
+	return msg, true, nil
+}
+
+func (s *DirStore) Put(_ context.Context, key string, msg step.AssistantMessage) error {
+	data, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temp file and rename so concurrent readers never see a partial entry.
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
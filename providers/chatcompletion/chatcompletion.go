@@ -2,16 +2,23 @@ package chatcompletion
 
 import (
 	"context"
+	"strings"
 
 	"github.com/inspirepan/step"
 	"github.com/inspirepan/step/providers/base"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
 )
 
 // Config configures OpenAI Chat Completions API provider.
 type Config struct {
 	base.Config
+
+	// NewReasoningHandler creates the handler for reasoning in requests and
+	// responses; nil uses NewDefaultReasoningHandler. It is called per
+	// request and per response choice, since handlers accumulate state.
+	NewReasoningHandler func(model string) ReasoningHandler
 }
 
 // Option is a functional option for this provider.
@@ -22,6 +29,18 @@ func WithAPIKey(key string) Option {
 	return func(c *Config) { c.APIKey = key }
 }
 
+// WithAPIKeys spreads requests over several API keys, moving to the next key
+// when one is rejected (401) or rate limited (429). See base.KeyRotation.
+func WithAPIKeys(keys ...string) Option {
+	return func(c *Config) { c.Keys = base.StaticKeys(keys) }
+}
+
+// WithKeyProvider fetches API keys at request time, e.g. from a secret
+// manager, rotating between them like WithAPIKeys.
+func WithKeyProvider(keys base.KeyProvider) Option {
+	return func(c *Config) { c.Keys = keys }
+}
+
 // WithBaseURL sets a custom base URL.
 func WithBaseURL(url string) Option {
 	return func(c *Config) { c.BaseURL = url }
@@ -37,11 +56,29 @@ func WithMaxOutputTokens(n int) Option {
 	return func(c *Config) { c.MaxOutputTokens = &n }
 }
 
+// WithJSONMode requests a single JSON object as output without a schema.
+// The final text is validated; a *step.InvalidJSONError is returned if it does not parse.
+func WithJSONMode() Option {
+	return func(c *Config) { c.ResponseFormat = base.ResponseFormatJSONObject }
+}
+
+// WithRequestCompression gzip-encodes large request bodies.
+// Only enable it for endpoints that accept Content-Encoding: gzip.
+func WithRequestCompression() Option {
+	return func(c *Config) { c.CompressRequests = true }
+}
+
 // WithDebug enables JSONL debug logging to the specified file path.
 func WithDebug(path string) Option {
 	return func(c *Config) { c.DebugPath = path }
 }
 
+// WithDebugSink sends debug records to sink, in addition to or instead of a
+// WithDebug file.
+func WithDebugSink(sink base.DebugSink) Option {
+	return func(c *Config) { c.DebugSink = sink }
+}
+
 // WithExtraHeader adds a custom header to requests.
 func WithExtraHeader(key, value string) Option {
 	return func(c *Config) {
@@ -62,6 +99,25 @@ func WithExtraBody(key string, value any) Option {
 	}
 }
 
+// WithFinishReason maps the raw provider finish reason raw to reason,
+// overriding the built-in mapping. The raw value is always kept in
+// Provenance.FinishReason.
+func WithFinishReason(raw string, reason step.StopReason) Option {
+	return func(c *Config) {
+		if c.FinishReasons == nil {
+			c.FinishReasons = make(map[string]step.StopReason)
+		}
+		c.FinishReasons[raw] = reason
+	}
+}
+
+// WithReasoningHandler replaces the default reasoning handler, for
+// OpenAI-compatible gateways with their own reasoning fields. See
+// NewFieldReasoningHandler for gateways that only rename the field.
+func WithReasoningHandler(factory func(model string) ReasoningHandler) Option {
+	return func(c *Config) { c.NewReasoningHandler = factory }
+}
+
 // New creates a Provider using OpenAI Chat Completions API.
 // It reads OPENAI_API_KEY and OPENAI_BASE_URL from environment if not explicitly set.
 func New(model string, opts ...Option) step.Provider {
@@ -84,6 +140,8 @@ func New(model string, opts ...Option) step.Provider {
 	for k, v := range cfg.ExtraBody {
 		clientOpts = append(clientOpts, option.WithJSONSet(k, v))
 	}
+	keys := base.NewKeyRotation(cfg.Keys, base.BearerKey)
+	clientOpts = append(clientOpts, option.WithMiddleware(keys.RoundTrip, base.TransferMiddleware(cfg.CompressRequests)))
 	client := openai.NewClient(clientOpts...)
 	return &provider{model: model, cfg: cfg, client: client}
 }
@@ -94,10 +152,27 @@ type provider struct {
 	client openai.Client
 }
 
-func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
-	reasoningHandler := NewDefaultReasoningHandler(p.model)
-	params := BuildMessages(req, reasoningHandler, p.model, false)
-	params.Model = p.model
+const defaultBaseURL = "https://api.openai.com/v1"
+
+func (p *provider) reasoningHandler(model string) ReasoningHandler {
+	if p.cfg.NewReasoningHandler != nil {
+		return p.cfg.NewReasoningHandler(model)
+	}
+	return NewDefaultReasoningHandler(model)
+}
+
+// buildParams converts req to request params, applying the provider config.
+func (p *provider) buildParams(req step.ProviderRequest, reasoningHandler ReasoningHandler) openai.ChatCompletionNewParams {
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		req.SystemPrompt = base.JSONModeSystemPrompt(req.SystemPrompt)
+	}
+	model := base.RequestModel(req, p.model)
+	params := BuildMessages(req, reasoningHandler, model, false)
+	params.Model = model
+	MarkPrefix(&params, req.History)
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONObject: &shared.ResponseFormatJSONObjectParam{}}
+	}
 
 	// Apply config options
 	if p.cfg.Temperature != nil {
@@ -106,18 +181,78 @@ func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.P
 	if p.cfg.MaxOutputTokens != nil {
 		params.MaxTokens = openai.Int(int64(*p.cfg.MaxOutputTokens))
 	}
+	if req.ReasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(req.ReasoningEffort)
+	}
+	if req.N > 1 {
+		params.N = openai.Int(int64(req.N))
+	}
+	return params
+}
+
+var _ step.PayloadBuilder = (*provider)(nil)
+
+// BuildPayload returns the request Stream would send, without sending it.
+func (p *provider) BuildPayload(_ context.Context, req step.ProviderRequest) (step.Payload, error) {
+	params := p.buildParams(req, p.reasoningHandler(base.RequestModel(req, p.model)))
+	baseURL := p.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return NewPayload("chatcompletion", baseURL, params, p.cfg.ExtraBody, p.cfg.ExtraHeaders)
+}
 
-	debug, err := base.NewDebugLogger(p.cfg.DebugPath)
+// NewPayload serializes params the way the SDK streams them to baseURL.
+// extraBody fields are merged into the body, as option.WithJSONSet does.
+func NewPayload(providerName, baseURL string, params openai.ChatCompletionNewParams, extraBody map[string]any, extraHeaders map[string]string) (step.Payload, error) {
+	extra := map[string]any{"stream": true}
+	for k, v := range extraBody {
+		extra[k] = v
+	}
+	body, err := base.MarshalWithExtra(params, extra)
+	if err != nil {
+		return step.Payload{}, err
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range extraHeaders {
+		headers[k] = v
+	}
+	return step.Payload{
+		Provider: providerName,
+		Model:    params.Model,
+		URL:      strings.TrimRight(baseURL, "/") + "/chat/completions",
+		Headers:  headers,
+		Body:     body,
+	}, nil
+}
+
+func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	model := base.RequestModel(req, p.model)
+	newHandler := func() ReasoningHandler { return p.reasoningHandler(model) }
+	params := p.buildParams(req, newHandler())
+
+	debug, err := p.cfg.DebugLogger()
 	if err != nil {
 		return nil, err
 	}
 	if debug != nil {
 		rec := base.NewDebugRecord("request", params)
 		rec.Provider = "chatcompletion"
-		rec.Model = p.model
+		rec.Model = model
+		rec.IdempotencyKey = step.IdempotencyKey(ctx)
 		_ = debug.Log(rec)
 	}
 
-	stream := p.client.Chat.Completions.NewStreaming(ctx, params)
-	return NewStream("chatcompletion", p.model, stream, reasoningHandler, debug), nil
+	ctx, stats := base.TrackTransfer(ctx)
+	var opts []option.RequestOption
+	if key := step.IdempotencyKey(ctx); key != "" {
+		opts = append(opts, option.WithHeader(base.IdempotencyHeader, key))
+	}
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params, opts...)
+	out := base.ReportTransfer(NewMultiStream("chatcompletion", model, stream, newHandler, debug).EmitRaw(req.Raw), stats)
+	out = base.MapFinishReasons(out, p.cfg.FinishReasons)
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		out = base.ValidateJSON(out, base.PrefillText(req.History))
+	}
+	return out, nil
 }
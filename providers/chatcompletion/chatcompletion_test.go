@@ -1,42 +1,533 @@
 package chatcompletion_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
-	"github.com/inspirepan/step/internal/testutil"
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
 	cc "github.com/inspirepan/step/providers/chatcompletion"
+	"github.com/inspirepan/step/testkit"
 )
 
 const envKey = "OPENAI_API_KEY"
 
 func TestOpenAI_BasicTextGeneration(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := cc.New("gpt-4o-mini")
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestBasicTextGeneration(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestBasicTextGeneration(t, cfg)
 }
 
 func TestOpenAI_ToolCalling(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := cc.New("gpt-4o-mini")
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestToolCalling(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestToolCalling(t, cfg)
 }
 
 func TestOpenAI_SystemPrompt(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := cc.New("gpt-4o-mini")
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestSystemPrompt(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestSystemPrompt(t, cfg)
 }
 
 func TestOpenAI_MultiTurn(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := cc.New("gpt-4o-mini")
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestMultiTurn(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestMultiTurn(t, cfg)
+}
+
+func TestOpenAI_ThinkingRoundTrip(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := cc.New("gpt-4o-mini")
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestThinkingRoundTrip(t, cfg)
+}
+
+func TestOpenAI_ImageInput(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := cc.New("gpt-4o-mini")
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestImageInput(t, cfg)
+}
+
+func TestOpenAI_Cancellation(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := cc.New("gpt-4o-mini")
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestCancellation(t, cfg)
+}
+
+func TestOpenAI_ParallelToolCalls(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := cc.New("gpt-4o-mini")
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestParallelToolCalls(t, cfg)
+}
+
+func TestOpenAI_EmptyResponse(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := cc.New("gpt-4o-mini")
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestEmptyResponse(t, cfg)
+}
+
+func TestBuildMessages_ContentArrays(t *testing.T) {
+	req := step.ProviderRequest{History: []step.Message{
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "look"}}},
+		step.AssistantMessage{Parts: []step.Part{
+			step.TextPart{Text: "first"},
+			step.TextPart{Text: "second"},
+			step.ToolCallPart{CallID: "c1", Name: "shot", ArgsJSON: json.RawMessage(`{}`)},
+			step.ToolCallPart{CallID: "c2", Name: "read", ArgsJSON: json.RawMessage(`{}`)},
+		}},
+		step.ToolMessage{CallID: "c1", Name: "shot", Parts: []step.Part{
+			step.TextPart{Text: "before"},
+			step.ImagePart{MimeType: "image/png", DataB64: "AAAA"},
+			step.TextPart{Text: "after"},
+		}},
+		step.ToolMessage{CallID: "c2", Name: "read", Parts: []step.Part{step.TextPart{Text: "contents"}}},
+	}}
+	params := cc.BuildMessages(req, nil, "gpt-4o-mini", false)
+	data, err := json.Marshal(params.Messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		t.Fatal(err)
+	}
+
+	roles := make([]string, len(msgs))
+	for i, m := range msgs {
+		roles[i] = m.Role
+	}
+	// Images from tool results follow the run of tool messages.
+	want := []string{"user", "assistant", "tool", "tool", "user"}
+	if len(roles) != len(want) {
+		t.Fatalf("expected roles %v, got %v", want, roles)
+	}
+	for i := range want {
+		if roles[i] != want[i] {
+			t.Fatalf("expected roles %v, got %v", want, roles)
+		}
+	}
+
+	var assistant []map[string]any
+	if err := json.Unmarshal(msgs[1].Content, &assistant); err != nil || len(assistant) != 2 {
+		t.Errorf("expected two assistant text parts, got %s", msgs[1].Content)
+	}
+	var tool []map[string]any
+	if err := json.Unmarshal(msgs[2].Content, &tool); err != nil || len(tool) != 2 || tool[0]["text"] != "before" || tool[1]["text"] != "after" {
+		t.Errorf("expected tool text parts [before after], got %s", msgs[2].Content)
+	}
+	if string(msgs[3].Content) != `"contents"` {
+		t.Errorf("expected a single tool text as a string, got %s", msgs[3].Content)
+	}
+	var images []map[string]any
+	if err := json.Unmarshal(msgs[4].Content, &images); err != nil || len(images) != 2 || images[1]["type"] != "image_url" {
+		t.Errorf("expected a caption and the image, got %s", msgs[4].Content)
+	}
+}
+
+func TestBuildMessages_CacheControl(t *testing.T) {
+	req := step.ProviderRequest{
+		SystemPrompt: "Be brief.",
+		History:      []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+	}
+	for _, tt := range []struct {
+		name   string
+		params func() any
+		want   bool
+	}{
+		{"off", func() any { return cc.BuildMessages(req, nil, "m", false).Messages }, false},
+		{"on", func() any { return cc.BuildMessages(req, nil, "m", true).Messages }, true},
+		{"disabled", func() any { return cc.BuildMessagesWithCache(req, nil, "m", base.NoCache()).Messages }, false},
+		{"default", func() any { return cc.BuildMessagesWithCache(req, nil, "m", base.DefaultCacheStrategy()).Messages }, true},
+	} {
+		data, err := json.Marshal(tt.params())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), "cache_control"); got != tt.want {
+			t.Errorf("%s: cache_control = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fakeChat serves scripted SSE chunks, one response per request, and records request bodies.
+type fakeChat struct {
+	mu        sync.Mutex
+	responses [][]string
+	requests  []map[string]any
+	keys      []string
+}
+
+func (f *fakeChat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req map[string]any
+	_ = json.Unmarshal(body, &req)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.keys = append(f.keys, r.Header.Get("Idempotency-Key"))
+	chunks := f.responses[0]
+	f.responses = f.responses[1:]
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, c := range chunks {
+		fmt.Fprintf(w, "data: %s\n\n", c)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func chunk(choices string, extra string) string {
+	return `{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-test","choices":[` + choices + `]` + extra + `}`
+}
+
+func TestChatCompletion_MultipleChoices(t *testing.T) {
+	fake := &fakeChat{responses: [][]string{{
+		// Role-only first deltas carry no content.
+		chunk(`{"index":0,"delta":{"role":"assistant","content":""}},{"index":1,"delta":{"role":"assistant"}}`, ""),
+		chunk(`{"index":1,"delta":{"content":"Tails"}}`, ""),
+		chunk(`{"index":0,"delta":{"content":"Heads"},"finish_reason":"stop"}`, ""),
+		chunk(`{"index":1,"delta":{},"finish_reason":"length"}`, ""),
+		// Usage arrives in a final chunk with an empty choices array.
+		chunk(``, `,"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}`),
+	}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL))
+	msgs, err := step.Candidates(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Flip a coin"}}}},
+	}, 2)
+	if err != nil {
+		t.Fatalf("Candidates failed: %v", err)
+	}
+	if n, _ := fake.requests[0]["n"].(float64); n != 2 {
+		t.Errorf("expected n=2 in the request, got %v", fake.requests[0]["n"])
+	}
+	if len(fake.requests) != 1 || len(msgs) != 2 {
+		t.Fatalf("expected 2 candidates from one request, got %d from %d", len(msgs), len(fake.requests))
+	}
+	if msgs[0].Text() != "Heads" || msgs[0].StopReason != step.StopStop || msgs[0].Usage == nil || msgs[0].Usage.TotalTokens != 7 {
+		t.Errorf("unexpected first candidate %+v", msgs[0])
+	}
+	if msgs[1].Text() != "Tails" || msgs[1].StopReason != step.StopLength || msgs[1].Usage != nil {
+		t.Errorf("unexpected second candidate %+v", msgs[1])
+	}
+}
+
+func TestChatCompletion_IdempotencyKey(t *testing.T) {
+	ok := []string{chunk(`{"index":0,"delta":{"content":"ok"},"finish_reason":"stop"}`, "")}
+	fake := &fakeChat{responses: [][]string{ok, ok, ok}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL))
+	req := step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+	}
+	retry := func(next step.StepFunc) step.StepFunc {
+		return func(ctx context.Context, req step.StepRequest) (step.StepResult, error) {
+			_, _ = next(ctx, req)
+			return next(ctx, req)
+		}
+	}
+	if _, err := step.Step(context.Background(), req, step.WithMiddleware(retry)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := step.Step(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if k := fake.keys; k[0] == "" || k[0] != k[1] || k[1] == k[2] {
+		t.Errorf("keys = %q, want one shared by the retry and a new one for the next step", k)
+	}
+}
+
+func TestChatCompletion_EmptyChoices(t *testing.T) {
+	fake := &fakeChat{responses: [][]string{{
+		chunk(``, ""),
+		chunk(`{"index":0,"delta":{"role":"assistant"}}`, ""),
+		chunk(`{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}`, ""),
+	}, {
+		// A stream that never carries a choice still ends in an (empty) message.
+		chunk(``, `,"usage":{"prompt_tokens":5,"completion_tokens":0,"total_tokens":5}`),
+	}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL))
+	req := step.StepRequest{Provider: provider, History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}}}
+	var deltas []step.MessageDelta
+	result, err := step.Step(context.Background(), req, step.WithOnDelta(func(d step.MessageDelta) {
+		if _, ok := d.(step.TextDelta); ok {
+			deltas = append(deltas, d)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if got := result[0].(step.AssistantMessage).Text(); got != "Hi" || len(deltas) != 1 {
+		t.Errorf("got %q with %d text deltas", got, len(deltas))
+	}
+
+	result, err = step.Step(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if msg := result[0].(step.AssistantMessage); len(msg.Parts) != 0 || msg.Usage == nil {
+		t.Errorf("unexpected empty-stream message %+v", msg)
+	}
+}
+
+// drain reads a provider stream to the end, returning its deltas and final message.
+func drain(t *testing.T, stream step.ProviderStream) ([]step.MessageDelta, step.AssistantMessage) {
+	t.Helper()
+	defer stream.Close()
+	var deltas []step.MessageDelta
+	var msg step.AssistantMessage
+	for {
+		up, err := stream.Next(context.Background())
+		if err == io.EOF {
+			return deltas, msg
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		switch u := up.(type) {
+		case step.ProviderDeltaUpdate:
+			deltas = append(deltas, u.Delta)
+		case step.ProviderMessageUpdate:
+			msg = u.Message
+		}
+	}
+}
+
+func TestChatCompletion_OutOfOrderChunks(t *testing.T) {
+	fake := &fakeChat{responses: [][]string{{
+		chunk(`{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"read","arguments":"{\"path\":"}}]}}`, ""),
+		chunk(`{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"list","arguments":"{\"dir\":"}}]}}`, ""),
+		chunk(`{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.txt\""}}]}}`, ""),
+		chunk(`{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\".\"}"}}]}}`, ""),
+		// Duplicate usage-only chunks, the last one late.
+		chunk(``, `,"usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}`),
+		chunk(`{"index":0,"delta":{},"finish_reason":"stop"}`, ""),
+		// Tool call fragments after finish_reason.
+		chunk(`{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"}"}}]}}`, ""),
+		chunk(``, `,"usage":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}`),
+		chunk(``, `,"usage":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}`),
+	}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL))
+	stream, err := provider.Stream(context.Background(), step.ProviderRequest{
+		History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Look around"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	deltas, msg := drain(t, stream)
+
+	// Each call's deltas are contiguous, so the first one is complete before the second starts.
+	var got []string
+	for _, d := range deltas {
+		tc, ok := d.(step.ToolCallDelta)
+		if !ok {
+			continue
+		}
+		if n := len(got); n > 0 && got[n-1][:6] == tc.CallID {
+			got[n-1] += tc.ArgsDelta
+		} else {
+			got = append(got, tc.CallID+tc.ArgsDelta)
+		}
+	}
+	if want := []string{`call_a{"path":"a.txt"}`, `call_b{"dir":"."}`}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected tool call deltas %q, got %q", want, got)
+	}
+
+	var calls []step.ToolCallPart
+	for _, part := range msg.Parts {
+		if tc, ok := part.(step.ToolCallPart); ok {
+			calls = append(calls, tc)
+		}
+	}
+	if len(calls) != 2 || string(calls[0].ArgsJSON) != `{"path":"a.txt"}` || string(calls[1].ArgsJSON) != `{"dir":"."}` {
+		t.Errorf("unexpected tool calls %+v", calls)
+	}
+	if msg.StopReason != step.StopToolUse || msg.Provenance.FinishReason != "stop" {
+		t.Errorf("expected tool use stop from raw %q, got %q", msg.Provenance.FinishReason, msg.StopReason)
+	}
+	if msg.Usage == nil || msg.Usage.TotalTokens != 14 {
+		t.Errorf("expected the last usage report, got %+v", msg.Usage)
+	}
+}
+
+func TestChatCompletion_ZeroArgumentToolCall(t *testing.T) {
+	fake := &fakeChat{responses: [][]string{{
+		chunk(`{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"now","arguments":""}}]}}`, ""),
+		chunk(`{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"read","arguments":"{\"path\":"}}]}}`, ""),
+		chunk(`{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"a.txt\"}"}}]}}`, ""),
+		chunk(`{"index":0,"delta":{},"finish_reason":"tool_calls"}`, ""),
+	}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL))
+	stream, err := provider.Stream(context.Background(), step.ProviderRequest{
+		History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Read a.txt"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	deltas, msg := drain(t, stream)
+
+	// The second call streams fragment by fragment, not in one delta when the
+	// stream ends.
+	var args []string
+	for _, d := range deltas {
+		if tc, ok := d.(step.ToolCallDelta); ok && tc.CallID == "call_b" {
+			args = append(args, tc.ArgsDelta)
+		}
+	}
+	if want := []string{`{"path":`, `"a.txt"}`}; fmt.Sprint(args) != fmt.Sprint(want) {
+		t.Errorf("expected call_b deltas %q, got %q", want, args)
+	}
+	if len(msg.Parts) != 2 || msg.Parts[0].(step.ToolCallPart).Name != "now" || string(msg.Parts[1].(step.ToolCallPart).ArgsJSON) != `{"path":"a.txt"}` {
+		t.Errorf("unexpected parts %+v", msg.Parts)
+	}
+}
+
+func TestChatCompletion_BracesInArguments(t *testing.T) {
+	// Braces and escaped quotes inside strings do not end call_a's arguments,
+	// so call_b is held back until they are complete.
+	fake := &fakeChat{responses: [][]string{{
+		chunk(`{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"write","arguments":"{\"code\":\"f() {}\\\"}"}}]}}`, ""),
+		chunk(`{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"list","arguments":"{}"}}]}}`, ""),
+		chunk(`{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":" {\"}"}}]}}`, ""),
+		chunk(`{"index":0,"delta":{},"finish_reason":"tool_calls"}`, ""),
+	}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL))
+	stream, err := provider.Stream(context.Background(), step.ProviderRequest{
+		History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Write"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	deltas, msg := drain(t, stream)
+
+	var ids []string
+	for _, d := range deltas {
+		if tc, ok := d.(step.ToolCallDelta); ok {
+			ids = append(ids, tc.CallID)
+		}
+	}
+	if want := []string{"call_a", "call_a", "call_b"}; fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("expected deltas for %v, got %v", want, ids)
+	}
+	if len(msg.Parts) != 2 || string(msg.Parts[0].(step.ToolCallPart).ArgsJSON) != `{"code":"f() {}\"} {"}` {
+		t.Errorf("unexpected parts %+v", msg.Parts)
+	}
+}
+
+func TestChatCompletion_RawChunks(t *testing.T) {
+	chunks := []string{
+		chunk(`{"index":0,"delta":{"role":"assistant","content":"Hi"},"x_upstream":{"id":7}}`, ""),
+		chunk(`{"index":0,"delta":{},"finish_reason":"stop"}`, ""),
+	}
+	fake := &fakeChat{responses: [][]string{chunks}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL))
+	var raw []string
+	result, err := step.Step(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}},
+	}, step.WithOnRawChunk(func(u step.ProviderRawUpdate) {
+		if u.Provider != "chatcompletion" {
+			t.Errorf("unexpected provider %q", u.Provider)
+		}
+		raw = append(raw, string(u.Data))
+	}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if fmt.Sprint(raw) != fmt.Sprint(chunks) {
+		t.Errorf("expected raw chunks %q, got %q", chunks, raw)
+	}
+	if got := result[0].(step.AssistantMessage).Text(); got != "Hi" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestChatCompletion_ReasoningHandler(t *testing.T) {
+	fake := &fakeChat{responses: [][]string{{
+		chunk(`{"index":0,"delta":{"role":"assistant","reasoning_content":"Think"}}`, ""),
+		chunk(`{"index":0,"delta":{"reasoning_content":"ing."}}`, ""),
+		chunk(`{"index":0,"delta":{"content":"Done"},"finish_reason":"stop"}`, ""),
+	}, {
+		chunk(`{"index":0,"delta":{"content":"Again"},"finish_reason":"stop"}`, ""),
+	}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL),
+		cc.WithReasoningHandler(func(model string) cc.ReasoningHandler {
+			return cc.NewFieldReasoningHandler(model, "reasoning_content")
+		}))
+	history := []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}}
+	var thinking string
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history}, step.WithOnDelta(func(d step.MessageDelta) {
+		if td, ok := d.(step.ThinkingDelta); ok {
+			thinking += td.Delta
+		}
+	}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	msg := result[0].(step.AssistantMessage)
+	if tp, ok := msg.Parts[0].(step.ThinkingPart); !ok || tp.Thinking != "Thinking." || thinking != "Thinking." {
+		t.Fatalf("expected thinking from reasoning_content, got %+v (deltas %q)", msg.Parts, thinking)
+	}
+
+	// The reasoning is sent back in the same field.
+	history = append(history, msg, step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Again"}}})
+	if _, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history}); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	msgs, _ := fake.requests[1]["messages"].([]any)
+	if len(msgs) != 3 || msgs[1].(map[string]any)["reasoning_content"] != "Thinking." {
+		t.Errorf("expected reasoning_content on the assistant message, got %v", msgs)
+	}
 }
@@ -1,29 +1,51 @@
 package chatcompletion
 
 import (
+	"slices"
+
 	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/shared"
 )
 
 // BuildMessages converts step request to OpenAI chat completion params.
+// useCacheControl places cache breakpoints by base.DefaultCacheStrategy; use
+// BuildMessagesWithCache to choose them.
 func BuildMessages(
 	req step.ProviderRequest,
 	reasoningHandler ReasoningHandler,
 	targetModel string,
 	useCacheControl bool,
+) openai.ChatCompletionNewParams {
+	cache := base.NoCache()
+	if useCacheControl {
+		cache = base.DefaultCacheStrategy()
+	}
+	return BuildMessagesWithCache(req, reasoningHandler, targetModel, cache)
+}
+
+// BuildMessagesWithCache is BuildMessages with cache breakpoints placed
+// according to cache; the Tools breakpoint is not representable in the chat
+// completion format and is ignored.
+func BuildMessagesWithCache(
+	req step.ProviderRequest,
+	reasoningHandler ReasoningHandler,
+	targetModel string,
+	cache base.CacheStrategy,
 ) openai.ChatCompletionNewParams {
 	params := openai.ChatCompletionNewParams{}
+	cacheSystem := cache.Enabled() && cache.System && req.SystemPrompt != ""
 
 	// System message
 	if req.SystemPrompt != "" {
-		if useCacheControl {
+		if cacheSystem {
 			// Use content array format with cache_control for OpenRouter
 			textPart := openai.ChatCompletionContentPartTextParam{
 				Text: req.SystemPrompt,
 			}
 			textPart.SetExtraFields(map[string]any{
-				"cache_control": map[string]any{"type": "ephemeral"},
+				"cache_control": cache.CacheControl(),
 			})
 			params.Messages = append(params.Messages, openai.SystemMessage([]openai.ChatCompletionContentPartTextParam{textPart}))
 		} else {
@@ -31,23 +53,46 @@ func BuildMessages(
 		}
 	}
 
-	// Convert history messages
-	for _, msg := range req.History {
+	// Convert history messages; an empty prefill is dropped, since an assistant
+	// message without content is invalid.
+	history := req.History
+	if prefill, ok := step.Prefill(history); ok && prefill.Text() == "" {
+		history = history[:len(history)-1]
+	}
+	// Tool messages cannot carry images, so images from tool results are sent in
+	// a user message after the run of tool messages they belong to.
+	var toolImages []openai.ChatCompletionContentPartUnionParam
+	flushToolImages := func() {
+		if len(toolImages) > 0 {
+			params.Messages = append(params.Messages, openai.UserMessage(toolImages))
+			toolImages = nil
+		}
+	}
+	for _, msg := range history {
 		switch m := msg.(type) {
 		case step.UserMessage:
+			flushToolImages()
 			params.Messages = append(params.Messages, convertUserMessage(m))
 		case *step.UserMessage:
+			flushToolImages()
 			params.Messages = append(params.Messages, convertUserMessage(*m))
 		case step.AssistantMessage:
+			flushToolImages()
 			params.Messages = append(params.Messages, convertAssistantMessage(m, reasoningHandler, targetModel))
 		case *step.AssistantMessage:
+			flushToolImages()
 			params.Messages = append(params.Messages, convertAssistantMessage(*m, reasoningHandler, targetModel))
 		case step.ToolMessage:
-			params.Messages = append(params.Messages, convertToolMessage(m))
+			toolMsg, images := convertToolMessage(m)
+			params.Messages = append(params.Messages, toolMsg)
+			toolImages = append(toolImages, images...)
 		case *step.ToolMessage:
-			params.Messages = append(params.Messages, convertToolMessage(*m))
+			toolMsg, images := convertToolMessage(*m)
+			params.Messages = append(params.Messages, toolMsg)
+			toolImages = append(toolImages, images...)
 		}
 	}
+	flushToolImages()
 
 	// Convert tools
 	for _, tool := range req.Tools {
@@ -61,17 +106,35 @@ func BuildMessages(
 		params.ParallelToolCalls = openai.Bool(true)
 	}
 
-	// Add cache_control to the last user/tool message
-	if useCacheControl {
-		addCacheControlToLastMessage(params.Messages)
+	// Add cache_control to the most recent user/tool messages
+	if n := cache.MessageBreakpoints(cacheSystem, false); n > 0 {
+		addCacheControlToLastMessages(params.Messages, n, cache.CacheControl())
 	}
 
 	return params
 }
 
-// addCacheControlToLastMessage adds cache_control to the last text part of the last user/tool message.
-func addCacheControlToLastMessage(messages []openai.ChatCompletionMessageParamUnion) {
-	for i := len(messages) - 1; i >= 0; i-- {
+// MarkPrefix flags a trailing prefill (see step.Prefill) with "prefix": true,
+// which DeepSeek requires for chat prefix completion. OpenRouter continues a
+// trailing assistant message without it.
+func MarkPrefix(params *openai.ChatCompletionNewParams, history []step.Message) {
+	if _, ok := step.Prefill(history); !ok || len(params.Messages) == 0 {
+		return
+	}
+	last := params.Messages[len(params.Messages)-1].OfAssistant
+	if last == nil {
+		return
+	}
+	extra := map[string]any{"prefix": true}
+	for k, v := range last.ExtraFields() {
+		extra[k] = v
+	}
+	last.SetExtraFields(extra)
+}
+
+// addCacheControlToLastMessages adds cache_control to the last text part of the last n user/tool messages.
+func addCacheControlToLastMessages(messages []openai.ChatCompletionMessageParamUnion, n int, cacheControl map[string]any) {
+	for i := len(messages) - 1; i >= 0 && n > 0; i-- {
 		msg := &messages[i]
 		if msg.OfUser != nil {
 			// User message: find and modify last text part
@@ -79,22 +142,23 @@ func addCacheControlToLastMessage(messages []openai.ChatCompletionMessageParamUn
 				for j := len(parts) - 1; j >= 0; j-- {
 					if parts[j].OfText != nil {
 						parts[j].OfText.SetExtraFields(map[string]any{
-							"cache_control": map[string]any{"type": "ephemeral"},
+							"cache_control": cacheControl,
 						})
-						return
+						n--
+						break
 					}
 				}
 			}
-			return
+			continue
 		}
 		if msg.OfTool != nil {
 			// Tool message: modify last part
 			if parts := msg.OfTool.Content.OfArrayOfContentParts; len(parts) > 0 {
 				parts[len(parts)-1].SetExtraFields(map[string]any{
-					"cache_control": map[string]any{"type": "ephemeral"},
+					"cache_control": cacheControl,
 				})
+				n--
 			}
-			return
 		}
 	}
 }
@@ -131,21 +195,35 @@ func convertAssistantMessage(m step.AssistantMessage, handler ReasoningHandler,
 		Role: "assistant",
 	}
 
-	var textContent string
+	var texts []string
 	var thinkingParts []step.ThinkingPart
 	var toolCalls []openai.ChatCompletionMessageToolCallUnionParam
 
+	// Thinking without a model name is attributed to the model that produced the message,
+	// so cross-model degradation still applies to histories from other providers.
+	sourceModel := ""
+	if m.Provenance != nil {
+		sourceModel = m.Provenance.Model
+	}
+
 	// Collect all parts
 	for _, part := range m.Parts {
 		switch p := part.(type) {
 		case step.TextPart:
-			textContent += p.Text
+			texts = append(texts, p.Text)
 		case *step.TextPart:
-			textContent += p.Text
+			texts = append(texts, p.Text)
 		case step.ThinkingPart:
+			if p.ModelName == "" {
+				p.ModelName = sourceModel
+			}
 			thinkingParts = append(thinkingParts, p)
 		case *step.ThinkingPart:
-			thinkingParts = append(thinkingParts, *p)
+			tp := *p
+			if tp.ModelName == "" {
+				tp.ModelName = sourceModel
+			}
+			thinkingParts = append(thinkingParts, tp)
 		case step.ToolCallPart:
 			toolCalls = append(toolCalls, convertToolCallPart(p))
 		case *step.ToolCallPart:
@@ -167,11 +245,27 @@ func convertAssistantMessage(m step.AssistantMessage, handler ReasoningHandler,
 		}
 	}
 
-	// Build content: prepend degraded thinking if any
-	fullContent := degradedThinking + textContent
-	if fullContent != "" {
+	// Build content: prepend degraded thinking if any. A single text is sent as a
+	// string; several keep their boundaries as separate content parts.
+	if degradedThinking != "" {
+		texts = append([]string{degradedThinking}, texts...)
+	}
+	texts = slices.DeleteFunc(texts, func(t string) bool { return t == "" })
+	switch len(texts) {
+	case 0:
+	case 1:
+		msg.Content = openai.ChatCompletionAssistantMessageParamContentUnion{
+			OfString: openai.String(texts[0]),
+		}
+	default:
+		parts := make([]openai.ChatCompletionAssistantMessageParamContentArrayOfContentPartUnion, 0, len(texts))
+		for _, text := range texts {
+			parts = append(parts, openai.ChatCompletionAssistantMessageParamContentArrayOfContentPartUnion{
+				OfText: &openai.ChatCompletionContentPartTextParam{Text: text},
+			})
+		}
 		msg.Content = openai.ChatCompletionAssistantMessageParamContentUnion{
-			OfString: openai.String(fullContent),
+			OfArrayOfContentParts: parts,
 		}
 	}
 
@@ -194,28 +288,61 @@ func convertToolCallPart(p step.ToolCallPart) openai.ChatCompletionMessageToolCa
 	}
 }
 
-func convertToolMessage(m step.ToolMessage) openai.ChatCompletionMessageParamUnion {
-	var content string
+// convertToolMessage sends a single text as a string and keeps several text
+// parts as separate content parts.
+// Images are returned separately, each preceded by a text part naming the call.
+func convertToolMessage(m step.ToolMessage) (openai.ChatCompletionMessageParamUnion, []openai.ChatCompletionContentPartUnionParam) {
+	var content []openai.ChatCompletionContentPartTextParam
+	var images []openai.ChatCompletionContentPartUnionParam
+	addImage := func(p step.ImagePart) {
+		images = append(images,
+			openai.TextContentPart("Image returned by tool call "+m.CallID+":"),
+			openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+				URL: formatDataURL(p.MimeType, p.DataB64),
+			}))
+	}
 	for _, part := range m.Parts {
 		switch p := part.(type) {
 		case step.TextPart:
-			content += p.Text
+			if p.Text != "" {
+				content = append(content, openai.ChatCompletionContentPartTextParam{Text: p.Text})
+			}
 		case *step.TextPart:
-			content += p.Text
+			if p.Text != "" {
+				content = append(content, openai.ChatCompletionContentPartTextParam{Text: p.Text})
+			}
+		case step.ImagePart:
+			addImage(p)
+		case *step.ImagePart:
+			addImage(*p)
 		}
 	}
-	if content == "" {
-		content = "<system-reminder>Tool ran without output or errors</system-reminder>"
+	if len(content) == 0 {
+		text := "<system-reminder>Tool ran without output or errors</system-reminder>"
+		if len(images) > 0 {
+			text = "<system-reminder>Tool returned images, attached in the next message</system-reminder>"
+		}
+		content = append(content, openai.ChatCompletionContentPartTextParam{Text: text})
+	}
+	if len(content) == 1 {
+		// Several OpenAI-compatible servers accept only string content for
+		// tool messages.
+		return openai.ToolMessage(content[0].Text, m.CallID), images
 	}
-	return openai.ToolMessage(content, m.CallID)
+	return openai.ToolMessage(content, m.CallID), images
 }
 
 func convertToolSpec(spec step.ToolSpec) openai.ChatCompletionToolUnionParam {
-	return openai.ChatCompletionFunctionTool(shared.FunctionDefinitionParam{
+	def := shared.FunctionDefinitionParam{
 		Name:        spec.Name,
 		Description: openai.String(spec.Description),
 		Parameters:  shared.FunctionParameters(spec.Parameters),
-	})
+	}
+	if spec.Strict {
+		def.Strict = openai.Bool(true)
+		def.Parameters = shared.FunctionParameters(base.StrictSchema(spec.Parameters))
+	}
+	return openai.ChatCompletionFunctionTool(def)
 }
 
 func formatDataURL(mimeType, dataB64 string) string {
@@ -0,0 +1,47 @@
+package chatcompletion
+
+import (
+	"context"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/openai/openai-go/v3"
+)
+
+var (
+	_ step.ModelLister = (*provider)(nil)
+	_ step.Pinger      = (*provider)(nil)
+)
+
+// ListModels lists the models served at the configured base URL.
+func (p *provider) ListModels(ctx context.Context) ([]step.ModelInfo, error) {
+	return ListModels(ctx, &p.client)
+}
+
+// Ping checks the API key and base URL by fetching the first page of models.
+func (p *provider) Ping(ctx context.Context) error {
+	return Ping(ctx, &p.client)
+}
+
+// ListModels lists models from the /models endpoint of an OpenAI-compatible
+// server. Only the ID and creation time are reported.
+func ListModels(ctx context.Context, client *openai.Client) ([]step.ModelInfo, error) {
+	var models []step.ModelInfo
+	iter := client.Models.ListAutoPaging(ctx)
+	for iter.Next() {
+		m := iter.Current()
+		info := step.ModelInfo{ID: m.ID}
+		if m.Created > 0 {
+			info.Created = time.Unix(m.Created, 0)
+		}
+		models = append(models, info)
+	}
+	return models, iter.Err()
+}
+
+// Ping fetches the first page of models from an OpenAI-compatible server.
+func Ping(ctx context.Context, client *openai.Client) error {
+	iter := client.Models.ListAutoPaging(ctx)
+	iter.Next()
+	return iter.Err()
+}
@@ -44,15 +44,28 @@ func (h *NoOpReasoningHandler) FlushThinking() []step.ThinkingPart {
 	return nil
 }
 
-// DefaultReasoningHandler handles reasoning_content (used by some OpenAI-compatible APIs).
+// DefaultReasoningHandler handles plain-text reasoning in a message field,
+// ReasoningField unless created with NewFieldReasoningHandler.
 type DefaultReasoningHandler struct {
 	modelName           string
+	fields              []string
 	accumulatedThinking []string
 }
 
 func NewDefaultReasoningHandler(modelName string) *DefaultReasoningHandler {
+	return NewFieldReasoningHandler(modelName, ReasoningField)
+}
+
+// NewFieldReasoningHandler returns a handler for gateways that put reasoning
+// text in other fields, such as "reasoning_content" or "thinking". Deltas are
+// read from the first of fields present; reasoning is sent back in fields[0].
+func NewFieldReasoningHandler(modelName string, fields ...string) *DefaultReasoningHandler {
+	if len(fields) == 0 {
+		fields = []string{ReasoningField}
+	}
 	return &DefaultReasoningHandler{
 		modelName:           modelName,
+		fields:              fields,
 		accumulatedThinking: make([]string, 0),
 	}
 }
@@ -78,13 +91,15 @@ func (h *DefaultReasoningHandler) ConvertThinkingToExtra(parts []step.ThinkingPa
 		return "", nil, degradedText
 	}
 
-	return ReasoningField, reasoning, degradedText
+	return h.fields[0], reasoning, degradedText
 }
 
 func (h *DefaultReasoningHandler) ExtractThinking(delta map[string]any) (string, bool) {
-	if reasoning, ok := delta[ReasoningField].(string); ok && reasoning != "" {
-		h.accumulatedThinking = append(h.accumulatedThinking, reasoning)
-		return reasoning, true
+	for _, field := range h.fields {
+		if reasoning, ok := delta[field].(string); ok && reasoning != "" {
+			h.accumulatedThinking = append(h.accumulatedThinking, reasoning)
+			return reasoning, true
+		}
 	}
 	return "", false
 }
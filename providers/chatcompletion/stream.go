@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"io"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,36 +17,104 @@ import (
 )
 
 // Stream implements step.ProviderStream for OpenAI Chat Completions API.
+//
+// Each choice (see ProviderRequest.N) is accumulated separately and emitted as
+// its own ProviderMessageUpdate, in index order; deltas are streamed for
+// choice 0 only.
 type Stream struct {
 	providerName string
 	modelName    string
 	stream       *ssestream.Stream[openai.ChatCompletionChunk]
 	debug        *base.DebugLogger
+	raw          bool
 
-	reasoningHandler ReasoningHandler
+	newHandler func() ReasoningHandler
 
 	mu sync.Mutex
 
-	done  bool
-	err   error
+	done bool
+	err  error
 
 	pending []step.ProviderUpdate
 
-	// Accumulators
+	choices map[int]*choiceState
+
+	requestID   string
+	servedModel string
+	upstream    string
+	usage       *step.Usage
+	startedAt   time.Time
+}
+
+// choiceState accumulates one choice of the response.
+type choiceState struct {
+	index            int
+	reasoningHandler ReasoningHandler
+
 	textContent []string
 	toolCalls   map[int]*toolCallAccumulator
+	// activeTool is the tool call whose argument deltas are being streamed, or -1.
+	activeTool int
 
-	stopReason step.StopReason
-	usage      *step.Usage
-	parts      []step.Part
+	stopReason   step.StopReason
+	finishReason string
+	nativeFinish string
 }
 
 type toolCallAccumulator struct {
 	id      string
 	name    string
 	argsStr string
+
+	pending []string // argument fragments not yet emitted as deltas
+	done    bool     // no longer streamed; see streamToolCalls
+
+	// Scan state of argsStr, so completeness is known without rescanning it.
+	depth    int
+	inString bool
+	escaped  bool
+	closed   bool // a top-level value opened and closed again
+}
+
+// appendArgs adds an argument fragment, tracking where the top-level JSON
+// value ends.
+func (acc *toolCallAccumulator) appendArgs(fragment string) {
+	acc.argsStr += fragment
+	acc.pending = append(acc.pending, fragment)
+	for i := 0; i < len(fragment); i++ {
+		b := fragment[i]
+		switch {
+		case acc.escaped:
+			acc.escaped = false
+		case acc.inString:
+			switch b {
+			case '\\':
+				acc.escaped = true
+			case '"':
+				acc.inString = false
+			}
+		case b == '"':
+			acc.inString = true
+		case b == '{' || b == '[':
+			acc.depth++
+		case b == '}' || b == ']':
+			acc.depth--
+			if acc.depth == 0 {
+				acc.closed = true
+			}
+		}
+	}
 }
 
+// complete reports whether the arguments received so far form a whole value,
+// or are still empty.
+func (acc *toolCallAccumulator) complete() bool {
+	return acc.argsStr == "" || acc.closed && acc.depth == 0
+}
+
+// NewStream wraps an SDK stream. handler extracts the reasoning of the first
+// choice; nil ignores reasoning. Streams of several choices need a handler per
+// choice, see NewMultiStream.
 func NewStream(
 	providerName string,
 	modelName string,
@@ -52,17 +122,57 @@ func NewStream(
 	handler ReasoningHandler,
 	debug *base.DebugLogger,
 ) *Stream {
-	if handler == nil {
-		handler = &NoOpReasoningHandler{}
+	var newHandler func() ReasoningHandler
+	if handler != nil {
+		newHandler = func() ReasoningHandler {
+			h := handler
+			handler = &NoOpReasoningHandler{}
+			return h
+		}
+	}
+	return NewMultiStream(providerName, modelName, stream, newHandler, debug)
+}
+
+// NewMultiStream wraps an SDK stream. newHandler is called once per choice to
+// extract its reasoning; nil ignores reasoning.
+func NewMultiStream(
+	providerName string,
+	modelName string,
+	stream *ssestream.Stream[openai.ChatCompletionChunk],
+	newHandler func() ReasoningHandler,
+	debug *base.DebugLogger,
+) *Stream {
+	if newHandler == nil {
+		newHandler = func() ReasoningHandler { return &NoOpReasoningHandler{} }
 	}
-	return &Stream{
-		providerName:     providerName,
-		modelName:        modelName,
-		stream:           stream,
-		debug:            debug,
-		reasoningHandler: handler,
-		toolCalls:        make(map[int]*toolCallAccumulator),
+	s := &Stream{
+		providerName: providerName,
+		modelName:    modelName,
+		stream:       stream,
+		debug:        debug,
+		newHandler:   newHandler,
+		choices:      make(map[int]*choiceState),
+		startedAt:    time.Now(),
 	}
+	// Choice 0 always produces a message, even if the stream carries no choices.
+	s.choice(0)
+	return s
+}
+
+// EmitRaw makes the stream emit a step.ProviderRawUpdate for every chunk
+// when enabled; see step.ProviderRequest.Raw.
+func (s *Stream) EmitRaw(enabled bool) *Stream {
+	s.raw = enabled
+	return s
+}
+
+func (s *Stream) choice(idx int) *choiceState {
+	c, ok := s.choices[idx]
+	if !ok {
+		c = &choiceState{index: idx, reasoningHandler: s.newHandler(), toolCalls: make(map[int]*toolCallAccumulator), activeTool: -1}
+		s.choices[idx] = c
+	}
+	return c
 }
 
 func (s *Stream) Next(ctx context.Context) (step.ProviderUpdate, error) {
@@ -146,8 +256,28 @@ func (s *Stream) processChunk(chunk openai.ChatCompletionChunk) {
 		rec.Model = s.modelName
 		_ = s.debug.Log(rec)
 	}
+	if s.raw {
+		s.enqueue(step.ProviderRawUpdate{Provider: s.providerName, Data: json.RawMessage(chunk.RawJSON())})
+	}
+
+	if s.requestID == "" && chunk.ID != "" {
+		s.requestID = chunk.ID
+	}
+	if s.servedModel == "" && chunk.Model != "" {
+		s.servedModel = chunk.Model
+	}
+	// OpenRouter names the upstream provider that served the request
+	if s.upstream == "" {
+		if f, ok := chunk.JSON.ExtraFields["provider"]; ok {
+			var name string
+			if json.Unmarshal([]byte(f.Raw()), &name) == nil {
+				s.upstream = name
+			}
+		}
+	}
 
-	// Usage
+	// Usage. Some upstreams resend the usage-only chunk or report cumulative
+	// usage on every chunk; the last non-empty report wins.
 	if chunk.Usage.TotalTokens > 0 {
 		s.usage = &step.Usage{
 			InputTokens:  int(chunk.Usage.PromptTokens),
@@ -157,45 +287,70 @@ func (s *Stream) processChunk(chunk openai.ChatCompletionChunk) {
 		if chunk.Usage.PromptTokensDetails.CachedTokens > 0 {
 			s.usage.CachedReadTokens = int(chunk.Usage.PromptTokensDetails.CachedTokens)
 		}
+		// OpenRouter reports cache writes as a non-standard prompt_tokens_details field
+		if f, ok := chunk.Usage.PromptTokensDetails.JSON.ExtraFields["cache_write_tokens"]; ok {
+			if n, err := strconv.Atoi(f.Raw()); err == nil {
+				s.usage.CacheWriteTokens = n
+			}
+		}
+		if f, ok := chunk.Usage.JSON.ExtraFields["cost"]; ok {
+			if cost, err := strconv.ParseFloat(f.Raw(), 64); err == nil {
+				s.usage.Cost = cost
+			}
+		}
 	}
 
-	if len(chunk.Choices) == 0 {
-		return
+	// Usage-only chunks (stream_options.include_usage) carry no choices.
+	for _, choice := range chunk.Choices {
+		s.processChoice(s.choice(int(choice.Index)), choice)
 	}
+}
 
-	choice := chunk.Choices[0]
+func (s *Stream) delta(c *choiceState, d step.MessageDelta) {
+	if c.index == 0 {
+		s.enqueue(step.ProviderDeltaUpdate{Delta: d})
+	}
+}
+
+func (s *Stream) processChoice(c *choiceState, choice openai.ChatCompletionChunkChoice) {
 	delta := choice.Delta
 
 	if choice.FinishReason != "" {
-		s.stopReason = mapFinishReason(string(choice.FinishReason))
+		c.finishReason = string(choice.FinishReason)
+		c.stopReason = mapFinishReason(c.finishReason)
+	}
+	// OpenRouter reports the upstream finish reason alongside the normalized one
+	if f, ok := choice.JSON.ExtraFields["native_finish_reason"]; ok {
+		var native string
+		if json.Unmarshal([]byte(f.Raw()), &native) == nil && native != "" {
+			c.nativeFinish = native
+		}
 	}
 
 	// Thinking (may be interleaved with text/tool calls in the same chunk)
-	if s.reasoningHandler != nil {
-		deltaMap := deltaToMap(delta)
-		if text, isThinking := s.reasoningHandler.ExtractThinking(deltaMap); isThinking {
-			// Some providers (e.g. OpenRouter+Gemini) may emit reasoning.encrypted with no text.
-			if text != "" {
-				s.enqueue(step.ProviderDeltaUpdate{Delta: step.ThinkingDelta{Delta: text}})
-			}
-			// Do not return: the same chunk can also include content/tool_calls.
+	deltaMap := deltaToMap(delta)
+	if text, isThinking := c.reasoningHandler.ExtractThinking(deltaMap); isThinking {
+		// Some providers (e.g. OpenRouter+Gemini) may emit reasoning.encrypted with no text.
+		if text != "" {
+			s.delta(c, step.ThinkingDelta{Delta: text})
 		}
+		// Do not return: the same chunk can also include content/tool_calls.
 	}
 
-	// Text (may be interleaved with tool calls)
+	// Text (may be interleaved with tool calls). A role-only first delta has none.
 	if delta.Content != "" {
-		s.textContent = append(s.textContent, delta.Content)
-		s.enqueue(step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: delta.Content}})
+		c.textContent = append(c.textContent, delta.Content)
+		s.delta(c, step.TextDelta{Delta: delta.Content})
 		// Do not return: the same chunk can also include tool_calls.
 	}
 
 	// Tool calls
 	for _, tc := range delta.ToolCalls {
 		idx := int(tc.Index)
-		if _, exists := s.toolCalls[idx]; !exists {
-			s.toolCalls[idx] = &toolCallAccumulator{}
+		if _, exists := c.toolCalls[idx]; !exists {
+			c.toolCalls[idx] = &toolCallAccumulator{}
 		}
-		acc := s.toolCalls[idx]
+		acc := c.toolCalls[idx]
 		if tc.ID != "" {
 			acc.id = tc.ID
 		}
@@ -203,69 +358,148 @@ func (s *Stream) processChunk(chunk openai.ChatCompletionChunk) {
 			acc.name = tc.Function.Name
 		}
 		if tc.Function.Arguments != "" {
-			acc.argsStr += tc.Function.Arguments
-			s.enqueue(step.ProviderDeltaUpdate{Delta: step.ToolCallDelta{CallID: acc.id, Name: acc.name, ArgsDelta: tc.Function.Arguments}})
+			acc.appendArgs(tc.Function.Arguments)
+		}
+	}
+	s.streamToolCalls(c)
+}
+
+// streamToolCalls emits pending tool call argument deltas one call at a time.
+//
+// Some upstreams interleave fragments of parallel tool calls, so indices are
+// not monotonic. Consumers treat a delta for another call as the end of the
+// previous one, so fragments of other calls are held back until the active
+// call's arguments form a complete JSON value; held fragments are released in
+// index order by finalize if they never do. A call without argument text yet,
+// such as a call taking no arguments, ends once another call starts; argument
+// text arriving for it later is released by finalize.
+func (s *Stream) streamToolCalls(c *choiceState) {
+	for {
+		next := -1
+		for idx, acc := range c.toolCalls {
+			if idx == c.activeTool || acc.done || acc.id == "" {
+				continue
+			}
+			if next < 0 || idx < next {
+				next = idx
+			}
+		}
+		if c.activeTool >= 0 {
+			acc := c.toolCalls[c.activeTool]
+			s.flushToolCall(c, acc)
+			if next < 0 || !acc.complete() {
+				return
+			}
+		}
+		if next < 0 {
+			return
+		}
+		if c.activeTool >= 0 {
+			c.toolCalls[c.activeTool].done = true
 		}
+		c.activeTool = next
 	}
 }
 
+func (s *Stream) flushToolCall(c *choiceState, acc *toolCallAccumulator) {
+	if len(acc.pending) == 0 || acc.id == "" {
+		return
+	}
+	s.delta(c, step.ToolCallDelta{CallID: acc.id, Name: acc.name, ArgsDelta: strings.Join(acc.pending, "")})
+	acc.pending = nil
+}
+
 func (s *Stream) finalize() {
 	s.done = true
 
-	if s.stopReason == "" {
-		s.stopReason = step.StopStop
+	idxs := make([]int, 0, len(s.choices))
+	for idx := range s.choices {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+
+	now := time.Now()
+	for _, idx := range idxs {
+		s.choices[idx].flushToolCalls(s)
+	}
+	for _, idx := range idxs {
+		msg := s.choices[idx].message(s, now)
+		// Usage is reported for the whole request; it is attached to the first choice.
+		if idx == idxs[0] {
+			msg.Usage = s.usage
+		}
+		s.enqueue(step.ProviderMessageUpdate{Message: msg, Candidate: idx})
+	}
+}
+
+// flushToolCalls emits every held tool call fragment, in index order.
+func (c *choiceState) flushToolCalls(s *Stream) {
+	for _, idx := range c.toolIndexes() {
+		s.flushToolCall(c, c.toolCalls[idx])
+	}
+}
+
+func (c *choiceState) toolIndexes() []int {
+	idxs := make([]int, 0, len(c.toolCalls))
+	for idx := range c.toolCalls {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+	return idxs
+}
+
+func (c *choiceState) message(s *Stream, now time.Time) step.AssistantMessage {
+	stopReason := c.stopReason
+	if stopReason == "" {
+		stopReason = step.StopStop
 	}
 
 	// Fixed final assembly order:
 	// 1) thinking parts (always included if present)
 	// 2) user-visible content parts (text today; future: text+image order)
 	// 3) tool calls
-	if thinkingParts := s.reasoningHandler.FlushThinking(); len(thinkingParts) > 0 {
-		for _, part := range thinkingParts {
-			s.parts = append(s.parts, part)
-		}
+	var parts []step.Part
+	for _, part := range c.reasoningHandler.FlushThinking() {
+		parts = append(parts, part)
 	}
 	// Text
-	s.flushText()
+	if len(c.textContent) > 0 {
+		parts = append(parts, step.TextPart{Text: strings.Join(c.textContent, "")})
+	}
 	// Tool calls (stable by tool index)
-	if len(s.toolCalls) > 0 {
-		idxs := make([]int, 0, len(s.toolCalls))
-		for idx := range s.toolCalls {
-			idxs = append(idxs, idx)
-		}
-		sort.Ints(idxs)
-		for _, idx := range idxs {
-			acc := s.toolCalls[idx]
-			if acc == nil || acc.id == "" || acc.name == "" {
-				continue
-			}
-			s.parts = append(s.parts, step.ToolCallPart{
-				CallID:   acc.id,
-				Name:     acc.name,
-				ArgsJSON: json.RawMessage(acc.argsStr),
-			})
+	var hasToolCall bool
+	for _, idx := range c.toolIndexes() {
+		acc := c.toolCalls[idx]
+		if acc == nil || acc.id == "" || acc.name == "" {
+			continue
 		}
+		parts = append(parts, step.ToolCallPart{
+			CallID:   acc.id,
+			Name:     acc.name,
+			ArgsJSON: json.RawMessage(acc.argsStr),
+		})
+		hasToolCall = true
 	}
-
-	msg := step.AssistantMessage{
-		Parts:      s.parts,
-		Timestamp:  time.Now().UnixMilli(),
-		Usage:      s.usage,
-		StopReason: s.stopReason,
+	// Some upstreams send tool call deltas after finish_reason "stop".
+	if hasToolCall && stopReason == step.StopStop {
+		stopReason = step.StopToolUse
 	}
-	s.enqueue(step.ProviderMessageUpdate{Message: msg})
-}
 
-func (s *Stream) flushText() {
-	if len(s.textContent) == 0 {
-		return
-	}
-	text := ""
-	for _, t := range s.textContent {
-		text += t
+	return step.AssistantMessage{
+		Parts:      parts,
+		Timestamp:  now.UnixMilli(),
+		StopReason: stopReason,
+		Provenance: &step.Provenance{
+			Provider:           s.providerName,
+			Model:              s.modelName,
+			ServedModel:        s.servedModel,
+			Upstream:           s.upstream,
+			RequestID:          s.requestID,
+			LatencyMs:          now.Sub(s.startedAt).Milliseconds(),
+			FinishReason:       c.finishReason,
+			NativeFinishReason: c.nativeFinish,
+		},
 	}
-	s.parts = append(s.parts, step.TextPart{Text: text})
-	s.textContent = nil
 }
 
 func mapFinishReason(reason string) step.StopReason {
@@ -274,8 +508,10 @@ func mapFinishReason(reason string) step.StopReason {
 		return step.StopStop
 	case "length":
 		return step.StopLength
-	case "tool_calls":
+	case "tool_calls", "function_call":
 		return step.StopToolUse
+	case "content_filter":
+		return step.StopContentFilter
 	default:
 		return step.StopStop
 	}
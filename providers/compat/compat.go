@@ -0,0 +1,269 @@
+// Package compat provides a Provider for OpenAI-compatible servers, with
+// declarative profiles for the quirks of common gateways and local servers.
+package compat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+	cc "github.com/inspirepan/step/providers/chatcompletion"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+// ErrTooManyTools is returned for requests with more tools than Profile.MaxTools.
+var ErrTooManyTools = errors.New("compat: too many tools")
+
+// Config configures an OpenAI-compatible provider.
+type Config struct {
+	base.Config
+
+	// NewReasoningHandler overrides the handler derived from
+	// Profile.ReasoningFields.
+	NewReasoningHandler func(model string) cc.ReasoningHandler
+}
+
+// Option is a functional option for this provider.
+type Option func(*Config)
+
+// WithAPIKey sets the API key.
+func WithAPIKey(key string) Option {
+	return func(c *Config) { c.APIKey = key }
+}
+
+// WithAPIKeys spreads requests over several API keys, moving to the next key
+// when one is rejected (401) or rate limited (429). See base.KeyRotation.
+func WithAPIKeys(keys ...string) Option {
+	return func(c *Config) { c.Keys = base.StaticKeys(keys) }
+}
+
+// WithKeyProvider fetches API keys at request time, e.g. from a secret
+// manager, rotating between them like WithAPIKeys.
+func WithKeyProvider(keys base.KeyProvider) Option {
+	return func(c *Config) { c.Keys = keys }
+}
+
+// WithBaseURL sets a custom base URL.
+func WithBaseURL(url string) Option {
+	return func(c *Config) { c.BaseURL = url }
+}
+
+// WithTemperature sets the temperature.
+func WithTemperature(t float64) Option {
+	return func(c *Config) { c.Temperature = &t }
+}
+
+// WithMaxOutputTokens sets the max output tokens.
+func WithMaxOutputTokens(n int) Option {
+	return func(c *Config) { c.MaxOutputTokens = &n }
+}
+
+// WithJSONMode requests a single JSON object as output without a schema.
+// The final text is validated; a *step.InvalidJSONError is returned if it does not parse.
+func WithJSONMode() Option {
+	return func(c *Config) { c.ResponseFormat = base.ResponseFormatJSONObject }
+}
+
+// WithDebug enables JSONL debug logging to the specified file path.
+func WithDebug(path string) Option {
+	return func(c *Config) { c.DebugPath = path }
+}
+
+// WithDebugSink sends debug records to sink, in addition to or instead of a
+// WithDebug file.
+func WithDebugSink(sink base.DebugSink) Option {
+	return func(c *Config) { c.DebugSink = sink }
+}
+
+// WithExtraHeader adds a custom header to requests.
+func WithExtraHeader(key, value string) Option {
+	return func(c *Config) {
+		if c.ExtraHeaders == nil {
+			c.ExtraHeaders = make(map[string]string)
+		}
+		c.ExtraHeaders[key] = value
+	}
+}
+
+// WithExtraBody adds a custom field to the request body.
+func WithExtraBody(key string, value any) Option {
+	return func(c *Config) {
+		if c.ExtraBody == nil {
+			c.ExtraBody = make(map[string]any)
+		}
+		c.ExtraBody[key] = value
+	}
+}
+
+// WithFinishReason maps the raw finish reason raw to reason, overriding the
+// built-in and profile mappings.
+func WithFinishReason(raw string, reason step.StopReason) Option {
+	return func(c *Config) {
+		if c.FinishReasons == nil {
+			c.FinishReasons = make(map[string]step.StopReason)
+		}
+		c.FinishReasons[raw] = reason
+	}
+}
+
+// WithCacheStrategy sets where prompt cache breakpoints are placed. It only
+// applies to profiles with CacheControl; no breakpoints are placed by default.
+func WithCacheStrategy(strategy base.CacheStrategy) Option {
+	return func(c *Config) { c.Cache = &strategy }
+}
+
+// WithReasoningHandler replaces the reasoning handler derived from the profile.
+func WithReasoningHandler(factory func(model string) cc.ReasoningHandler) Option {
+	return func(c *Config) { c.NewReasoningHandler = factory }
+}
+
+// New creates a Provider for the OpenAI-compatible server described by profile.
+// The API key and base URL are read from the profile's environment variables
+// if not explicitly set; the base URL then falls back to profile.BaseURL.
+func New(model string, profile Profile, opts ...Option) step.Provider {
+	cfg := Config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.APIKey == "" && profile.APIKeyEnv != "" {
+		cfg.APIKey = os.Getenv(profile.APIKeyEnv)
+	}
+	if cfg.BaseURL == "" && profile.BaseURLEnv != "" {
+		cfg.BaseURL = os.Getenv(profile.BaseURLEnv)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = profile.BaseURL
+	}
+	reasons := make(map[string]step.StopReason, len(profile.FinishReasons)+len(cfg.FinishReasons))
+	for raw, reason := range profile.FinishReasons {
+		reasons[raw] = reason
+	}
+	for raw, reason := range cfg.FinishReasons {
+		reasons[raw] = reason
+	}
+	cfg.FinishReasons = reasons
+
+	var clientOpts []option.RequestOption
+	// Local servers usually need no key, but the SDK requires one.
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = "none"
+	}
+	clientOpts = append(clientOpts, option.WithAPIKey(apiKey))
+	if cfg.BaseURL != "" {
+		clientOpts = append(clientOpts, option.WithBaseURL(cfg.BaseURL))
+	}
+	for k, v := range cfg.ExtraHeaders {
+		clientOpts = append(clientOpts, option.WithHeader(k, v))
+	}
+	for k, v := range cfg.ExtraBody {
+		clientOpts = append(clientOpts, option.WithJSONSet(k, v))
+	}
+	keys := base.NewKeyRotation(cfg.Keys, base.BearerKey)
+	clientOpts = append(clientOpts, option.WithMiddleware(keys.RoundTrip, base.TransferMiddleware(cfg.CompressRequests)))
+	client := openai.NewClient(clientOpts...)
+	return &provider{model: model, profile: profile, cfg: cfg, client: client}
+}
+
+type provider struct {
+	model   string
+	profile Profile
+	cfg     Config
+	client  openai.Client
+}
+
+func (p *provider) reasoningHandler(model string) cc.ReasoningHandler {
+	if p.cfg.NewReasoningHandler != nil {
+		return p.cfg.NewReasoningHandler(model)
+	}
+	if len(p.profile.ReasoningFields) == 0 {
+		return &cc.NoOpReasoningHandler{}
+	}
+	return cc.NewFieldReasoningHandler(model, p.profile.ReasoningFields...)
+}
+
+// buildParams converts req to request params, applying the profile and config.
+func (p *provider) buildParams(req step.ProviderRequest, handler cc.ReasoningHandler) (openai.ChatCompletionNewParams, error) {
+	if p.profile.MaxTools > 0 && len(req.Tools) > p.profile.MaxTools {
+		return openai.ChatCompletionNewParams{}, fmt.Errorf("%w: %s accepts at most %d, got %d", ErrTooManyTools, p.profile.Name, p.profile.MaxTools, len(req.Tools))
+	}
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		req.SystemPrompt = base.JSONModeSystemPrompt(req.SystemPrompt)
+	}
+	cache := base.NoCache()
+	if p.profile.CacheControl && p.cfg.Cache != nil {
+		cache = *p.cfg.Cache
+	}
+	model := base.RequestModel(req, p.model)
+	params := cc.BuildMessagesWithCache(req, handler, model, cache)
+	params.Model = model
+	cc.MarkPrefix(&params, req.History)
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONObject: &shared.ResponseFormatJSONObjectParam{}}
+	}
+
+	// Apply config options
+	if p.cfg.Temperature != nil {
+		params.Temperature = openai.Float(*p.cfg.Temperature)
+	}
+	if p.cfg.MaxOutputTokens != nil {
+		params.MaxTokens = openai.Int(int64(*p.cfg.MaxOutputTokens))
+	}
+	if req.ReasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(req.ReasoningEffort)
+	}
+	if req.N > 1 {
+		params.N = openai.Int(int64(req.N))
+	}
+	return params, nil
+}
+
+var _ step.PayloadBuilder = (*provider)(nil)
+
+// BuildPayload returns the request Stream would send, without sending it.
+func (p *provider) BuildPayload(_ context.Context, req step.ProviderRequest) (step.Payload, error) {
+	params, err := p.buildParams(req, p.reasoningHandler(base.RequestModel(req, p.model)))
+	if err != nil {
+		return step.Payload{}, err
+	}
+	return cc.NewPayload(p.profile.Name, p.cfg.BaseURL, params, p.cfg.ExtraBody, p.cfg.ExtraHeaders)
+}
+
+func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	model := base.RequestModel(req, p.model)
+	newHandler := func() cc.ReasoningHandler { return p.reasoningHandler(model) }
+	params, err := p.buildParams(req, newHandler())
+	if err != nil {
+		return nil, err
+	}
+
+	debug, err := p.cfg.DebugLogger()
+	if err != nil {
+		return nil, err
+	}
+	if debug != nil {
+		rec := base.NewDebugRecord("request", params)
+		rec.Provider = p.profile.Name
+		rec.Model = model
+		rec.IdempotencyKey = step.IdempotencyKey(ctx)
+		_ = debug.Log(rec)
+	}
+
+	ctx, stats := base.TrackTransfer(ctx)
+	var opts []option.RequestOption
+	if key := step.IdempotencyKey(ctx); key != "" {
+		opts = append(opts, option.WithHeader(base.IdempotencyHeader, key))
+	}
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params, opts...)
+	out := base.ReportTransfer(cc.NewMultiStream(p.profile.Name, model, stream, newHandler, debug).EmitRaw(req.Raw), stats)
+	out = base.MapFinishReasons(out, p.cfg.FinishReasons)
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		out = base.ValidateJSON(out, base.PrefillText(req.History))
+	}
+	return out, nil
+}
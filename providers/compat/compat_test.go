@@ -0,0 +1,174 @@
+package compat_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+	"github.com/inspirepan/step/providers/compat"
+)
+
+// serveChunks serves the given SSE data lines for every request and records request bodies.
+func serveChunks(t *testing.T, chunks ...string) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]any
+		_ = json.Unmarshal(body, &req)
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var hello = []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}}
+
+func TestCompat_VLLMProfile(t *testing.T) {
+	server, _ := serveChunks(t,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"qwen","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Hmm."}}]}`,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"qwen","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"abort"}]}`,
+	)
+	provider := compat.New("qwen", compat.ProfileVLLM, compat.WithBaseURL(server.URL))
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: hello})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	msg := result[0].(step.AssistantMessage)
+	if tp, ok := msg.Parts[0].(step.ThinkingPart); !ok || tp.Thinking != "Hmm." || msg.Text() != "Hi" {
+		t.Errorf("unexpected parts %+v", msg.Parts)
+	}
+	if msg.StopReason != step.StopAborted || msg.Provenance.Provider != "vllm" {
+		t.Errorf("expected an aborted vllm message, got %q from %q", msg.StopReason, msg.Provenance.Provider)
+	}
+}
+
+func TestCompat_FinishReasonOverride(t *testing.T) {
+	server, _ := serveChunks(t,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"eos"}]}`,
+	)
+	provider := compat.New("m", compat.ProfileTogether, compat.WithBaseURL(server.URL), compat.WithFinishReason("eos", step.StopLength))
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: hello})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if got := result[0].(step.AssistantMessage).StopReason; got != step.StopLength {
+		t.Errorf("expected the option to override the profile, got %q", got)
+	}
+	if compat.ProfileTogether.FinishReasons["eos"] != step.StopStop {
+		t.Error("the option must not modify the profile")
+	}
+}
+
+func TestCompat_ReasoningEffort(t *testing.T) {
+	server, requests := serveChunks(t,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`,
+	)
+	provider := compat.New("m", compat.ProfileVLLM, compat.WithBaseURL(server.URL))
+	_, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: hello}, step.WithReasoningEffort(step.ReasoningEffortHigh))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if got := (*requests)[0]["reasoning_effort"]; got != "high" {
+		t.Errorf("expected reasoning_effort high, got %v", got)
+	}
+}
+
+func TestCompat_IdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := compat.New("m", compat.ProfileVLLM, compat.WithBaseURL(server.URL))
+	req := step.StepRequest{Provider: provider, History: hello}
+	retry := func(next step.StepFunc) step.StepFunc {
+		return func(ctx context.Context, req step.StepRequest) (step.StepResult, error) {
+			_, _ = next(ctx, req)
+			return next(ctx, req)
+		}
+	}
+	if _, err := step.Step(context.Background(), req, step.WithMiddleware(retry)); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("keys = %q, want one key shared by the retry", keys)
+	}
+}
+
+func TestCompat_Profiles(t *testing.T) {
+	strategy := base.DefaultCacheStrategy()
+	req := step.ProviderRequest{SystemPrompt: "Be brief.", History: hello}
+
+	t.Setenv("LLAMACPP_BASE_URL", "http://gpu-box:8080/v1")
+	for _, tt := range []struct {
+		profile compat.Profile
+		url     string
+		cache   bool
+	}{
+		{compat.ProfileLiteLLM, "http://localhost:4000/chat/completions", true},
+		{compat.ProfileLlamaCpp, "http://gpu-box:8080/v1/chat/completions", false},
+		{compat.ProfileFireworks, "https://api.fireworks.ai/inference/v1/chat/completions", false},
+	} {
+		provider := compat.New("m", tt.profile, compat.WithCacheStrategy(strategy))
+		payload, err := provider.(step.PayloadBuilder).BuildPayload(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: BuildPayload failed: %v", tt.profile.Name, err)
+		}
+		if payload.Provider != tt.profile.Name || payload.URL != tt.url {
+			t.Errorf("%s: unexpected payload target %s %s", tt.profile.Name, payload.Provider, payload.URL)
+		}
+		if got := strings.Contains(string(payload.Body), "cache_control"); got != tt.cache {
+			t.Errorf("%s: cache_control sent = %v, want %v", tt.profile.Name, got, tt.cache)
+		}
+	}
+}
+
+func TestCompat_MaxTools(t *testing.T) {
+	profile := compat.ProfileLMStudio
+	profile.MaxTools = 1
+	provider := compat.New("m", profile)
+	req := step.ProviderRequest{History: hello, Tools: []step.ToolSpec{{Name: "a"}, {Name: "b"}}}
+	if _, err := provider.Stream(context.Background(), req); !errors.Is(err, compat.ErrTooManyTools) {
+		t.Errorf("expected ErrTooManyTools, got %v", err)
+	}
+}
+
+func TestCompat_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"llama3.2:latest","object":"model","created":1700000000,"owned_by":"library"},{"id":"qwen3:8b","object":"model","created":1700000001,"owned_by":"library"}]}`)
+	}))
+	defer server.Close()
+
+	provider := compat.New("qwen3:8b", compat.ProfileOllama, compat.WithBaseURL(server.URL+"/v1"))
+	models, err := step.ListModels(context.Background(), provider)
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	m, ok := step.FindModel(models, "qwen3:8b")
+	if len(models) != 2 || !ok || m.Created.Unix() != 1700000001 {
+		t.Errorf("unexpected models %+v", models)
+	}
+}
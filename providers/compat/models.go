@@ -0,0 +1,23 @@
+package compat
+
+import (
+	"context"
+
+	"github.com/inspirepan/step"
+	cc "github.com/inspirepan/step/providers/chatcompletion"
+)
+
+var (
+	_ step.ModelLister = (*provider)(nil)
+	_ step.Pinger      = (*provider)(nil)
+)
+
+// ListModels lists the models the server reports at its /models endpoint.
+func (p *provider) ListModels(ctx context.Context) ([]step.ModelInfo, error) {
+	return cc.ListModels(ctx, &p.client)
+}
+
+// Ping checks that the server is up and accepts the API key.
+func (p *provider) Ping(ctx context.Context) error {
+	return cc.Ping(ctx, &p.client)
+}
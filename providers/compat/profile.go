@@ -0,0 +1,108 @@
+package compat
+
+import "github.com/inspirepan/step"
+
+// Profile describes how an OpenAI-compatible server deviates from the
+// OpenAI Chat Completions API.
+type Profile struct {
+	// Name identifies the server in provenance, payloads and debug logs.
+	Name string
+
+	// BaseURL is the default endpoint, used when neither WithBaseURL nor
+	// BaseURLEnv provides one.
+	BaseURL string
+	// APIKeyEnv and BaseURLEnv name the environment variables read when the
+	// API key or base URL are not set explicitly.
+	APIKeyEnv  string
+	BaseURLEnv string
+
+	// ReasoningFields are the message fields carrying reasoning text. Deltas
+	// are read from the first one present and reasoning is sent back in the
+	// first. Empty ignores reasoning.
+	ReasoningFields []string
+
+	// CacheControl reports whether the server accepts Anthropic-style
+	// cache_control on message content. Without it WithCacheStrategy is ignored.
+	CacheControl bool
+
+	// MaxTools is the most tool definitions the server accepts per request;
+	// 0 means no limit. Requests with more fail with ErrTooManyTools.
+	MaxTools int
+
+	// FinishReasons maps nonstandard finish reasons; WithFinishReason takes
+	// precedence.
+	FinishReasons map[string]step.StopReason
+}
+
+// Profiles for common servers. Local servers default to their standard
+// port on localhost; set the base URL to reach another host.
+var (
+	// ProfileVLLM is vLLM's OpenAI-compatible server. Reasoning models
+	// served with a reasoning parser report reasoning_content, or reasoning
+	// on newer releases; aborted requests finish with "abort".
+	ProfileVLLM = Profile{
+		Name:            "vllm",
+		BaseURL:         "http://localhost:8000/v1",
+		APIKeyEnv:       "VLLM_API_KEY",
+		BaseURLEnv:      "VLLM_BASE_URL",
+		ReasoningFields: []string{"reasoning_content", "reasoning"},
+		FinishReasons:   map[string]step.StopReason{"abort": step.StopAborted},
+	}
+
+	// ProfileLiteLLM is the LiteLLM proxy, which forwards cache_control to
+	// upstreams that support it.
+	ProfileLiteLLM = Profile{
+		Name:            "litellm",
+		BaseURL:         "http://localhost:4000",
+		APIKeyEnv:       "LITELLM_API_KEY",
+		BaseURLEnv:      "LITELLM_BASE_URL",
+		ReasoningFields: []string{"reasoning_content"},
+		CacheControl:    true,
+	}
+
+	// ProfileLlamaCpp is the llama.cpp server (llama-server).
+	ProfileLlamaCpp = Profile{
+		Name:            "llamacpp",
+		BaseURL:         "http://localhost:8080/v1",
+		APIKeyEnv:       "LLAMACPP_API_KEY",
+		BaseURLEnv:      "LLAMACPP_BASE_URL",
+		ReasoningFields: []string{"reasoning_content"},
+	}
+
+	// ProfileLMStudio is the LM Studio local server.
+	ProfileLMStudio = Profile{
+		Name:            "lmstudio",
+		BaseURL:         "http://localhost:1234/v1",
+		APIKeyEnv:       "LMSTUDIO_API_KEY",
+		BaseURLEnv:      "LMSTUDIO_BASE_URL",
+		ReasoningFields: []string{"reasoning_content"},
+	}
+
+	// ProfileOllama is Ollama's OpenAI-compatible endpoint.
+	ProfileOllama = Profile{
+		Name:            "ollama",
+		BaseURL:         "http://localhost:11434/v1",
+		APIKeyEnv:       "OLLAMA_API_KEY",
+		BaseURLEnv:      "OLLAMA_BASE_URL",
+		ReasoningFields: []string{"reasoning"},
+	}
+
+	// ProfileTogether is Together AI. Some models finish with "eos".
+	ProfileTogether = Profile{
+		Name:            "together",
+		BaseURL:         "https://api.together.xyz/v1",
+		APIKeyEnv:       "TOGETHER_API_KEY",
+		BaseURLEnv:      "TOGETHER_BASE_URL",
+		ReasoningFields: []string{"reasoning"},
+		FinishReasons:   map[string]step.StopReason{"eos": step.StopStop},
+	}
+
+	// ProfileFireworks is Fireworks AI.
+	ProfileFireworks = Profile{
+		Name:            "fireworks",
+		BaseURL:         "https://api.fireworks.ai/inference/v1",
+		APIKeyEnv:       "FIREWORKS_API_KEY",
+		BaseURLEnv:      "FIREWORKS_BASE_URL",
+		ReasoningFields: []string{"reasoning_content"},
+	}
+)
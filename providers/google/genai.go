@@ -1,8 +1,13 @@
 package google
 
 import (
+	"bytes"
 	"context"
-	"errors"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
 
 	"github.com/inspirepan/step"
 	"github.com/inspirepan/step/providers/base"
@@ -15,8 +20,30 @@ type Config struct {
 	// Thinking options
 	ThinkingEnabled bool
 	ThinkingBudget  *int
+
+	// BuiltinTools are server-side tools executed by Gemini itself.
+	BuiltinTools []BuiltinTool
+
+	// LongContext reports the full input window of models, beyond the
+	// standard pricing tier; see WithLongContext.
+	LongContext bool
 }
 
+// BuiltinTool is a Gemini server-side tool.
+type BuiltinTool string
+
+const (
+	// BuiltinCodeExecution lets the model write and run Python. Code and output
+	// are returned as fenced text in the assistant message.
+	BuiltinCodeExecution BuiltinTool = "code_execution"
+	// BuiltinGoogleSearch grounds answers in Google Search results, returned as CitationParts.
+	BuiltinGoogleSearch BuiltinTool = "google_search"
+)
+
+// MetadataSearchQueries is the AssistantMessage metadata key holding the web
+// search queries Gemini issued for grounding ([]string).
+const MetadataSearchQueries = "google.search_queries"
+
 // Option is a functional option for this provider.
 type Option func(*Config)
 
@@ -25,6 +52,18 @@ func WithAPIKey(key string) Option {
 	return func(c *Config) { c.APIKey = key }
 }
 
+// WithAPIKeys spreads requests over several API keys, moving to the next key
+// when one is rejected (401) or rate limited (429). See base.KeyRotation.
+func WithAPIKeys(keys ...string) Option {
+	return func(c *Config) { c.Keys = base.StaticKeys(keys) }
+}
+
+// WithKeyProvider fetches API keys at request time, e.g. from a secret
+// manager, rotating between them like WithAPIKeys.
+func WithKeyProvider(keys base.KeyProvider) Option {
+	return func(c *Config) { c.Keys = keys }
+}
+
 // WithBaseURL sets a custom base URL.
 func WithBaseURL(url string) Option {
 	return func(c *Config) { c.BaseURL = url }
@@ -40,11 +79,28 @@ func WithMaxOutputTokens(n int) Option {
 	return func(c *Config) { c.MaxOutputTokens = &n }
 }
 
+// WithJSONMode requests a single JSON value as output (responseMimeType application/json).
+// The final text is validated; a *step.InvalidJSONError is returned if it does not parse.
+func WithJSONMode() Option {
+	return func(c *Config) { c.ResponseFormat = base.ResponseFormatJSONObject }
+}
+
+// WithRequestCompression gzip-encodes large request bodies.
+func WithRequestCompression() Option {
+	return func(c *Config) { c.CompressRequests = true }
+}
+
 // WithDebug enables JSONL debug logging to the specified file path.
 func WithDebug(path string) Option {
 	return func(c *Config) { c.DebugPath = path }
 }
 
+// WithDebugSink sends debug records to sink, in addition to or instead of a
+// WithDebug file.
+func WithDebugSink(sink base.DebugSink) Option {
+	return func(c *Config) { c.DebugSink = sink }
+}
+
 // WithExtraHeader adds a custom header to requests.
 func WithExtraHeader(key, value string) Option {
 	return func(c *Config) {
@@ -65,6 +121,18 @@ func WithExtraBody(key string, value any) Option {
 	}
 }
 
+// WithFinishReason maps the raw provider finish reason raw to reason,
+// overriding the built-in mapping. The raw value is always kept in
+// Provenance.FinishReason.
+func WithFinishReason(raw string, reason step.StopReason) Option {
+	return func(c *Config) {
+		if c.FinishReasons == nil {
+			c.FinishReasons = make(map[string]step.StopReason)
+		}
+		c.FinishReasons[raw] = reason
+	}
+}
+
 // WithThinking enables thinking mode.
 func WithThinking(budget int) Option {
 	return func(c *Config) {
@@ -73,6 +141,19 @@ func WithThinking(budget int) Option {
 	}
 }
 
+// WithBuiltinTools enables Gemini server-side tools.
+func WithBuiltinTools(tools ...BuiltinTool) Option {
+	return func(c *Config) { c.BuiltinTools = append(c.BuiltinTools, tools...) }
+}
+
+// WithLongContext opts into Gemini's long context: ListModels reports the
+// full input window of each model (up to a million tokens or more) instead of
+// the 200k tokens of the standard pricing tier. Prompts beyond 200k tokens
+// are billed at long-context rates.
+func WithLongContext() Option {
+	return func(c *Config) { c.LongContext = true }
+}
+
 // New creates a Provider using Google Generative AI API.
 // It reads GEMINI_API_KEY (or GOOGLE_API_KEY) and GEMINI_BASE_URL from environment if not explicitly set.
 func New(model string, opts ...Option) step.Provider {
@@ -84,16 +165,121 @@ func New(model string, opts ...Option) step.Provider {
 	if cfg.APIKey == "" {
 		base.ApplyEnvDefaults(&cfg.Config, "GOOGLE_API_KEY", "")
 	}
-	return &provider{model: model, cfg: cfg}
+	return &provider{model: model, cfg: cfg, keys: base.NewKeyRotation(cfg.Keys, base.HeaderKey("x-goog-api-key"))}
 }
 
+const defaultBaseURL = "https://generativelanguage.googleapis.com"
+
 type provider struct {
 	model string
 	cfg   Config
+	keys  *base.KeyRotation
+}
+
+var _ step.PayloadBuilder = (*provider)(nil)
+
+// BuildPayload returns the request Stream would send, without sending it.
+func (p *provider) BuildPayload(_ context.Context, req step.ProviderRequest) (step.Payload, error) {
+	body, err := p.buildBody(req)
+	if err != nil {
+		return step.Payload{}, err
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range p.cfg.ExtraHeaders {
+		headers[k] = v
+	}
+	model := base.RequestModel(req, p.model)
+	return step.Payload{Provider: providerName, Model: model, URL: p.endpoint(model), Headers: headers, Body: body}, nil
+}
+
+func (p *provider) endpoint(model string) string {
+	baseURL := p.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + "/v1beta/models/" + model + ":streamGenerateContent?alt=sse"
+}
+
+func (p *provider) buildBody(req step.ProviderRequest) (json.RawMessage, error) {
+	body := buildRequest(req, base.RequestModel(req, p.model))
+	for _, bt := range p.cfg.BuiltinTools {
+		switch bt {
+		case BuiltinCodeExecution:
+			body.Tools = append(body.Tools, tool{CodeExecution: &struct{}{}})
+		case BuiltinGoogleSearch:
+			body.Tools = append(body.Tools, tool{GoogleSearch: &struct{}{}})
+		default:
+			return nil, fmt.Errorf("step/providers/google: unknown builtin tool %q", bt)
+		}
+	}
+	gen := &generationConfig{Temperature: p.cfg.Temperature, MaxOutputTokens: p.cfg.MaxOutputTokens}
+	if req.ReasoningEffort != "" {
+		// A zero budget turns thinking off on models that allow it.
+		budget, thinking := base.ThinkingBudget(req.ReasoningEffort)
+		gen.ThinkingConfig = &thinkingConfig{ThinkingBudget: &budget, IncludeThoughts: thinking}
+	} else if p.cfg.ThinkingEnabled {
+		gen.ThinkingConfig = &thinkingConfig{ThinkingBudget: p.cfg.ThinkingBudget, IncludeThoughts: true}
+	}
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		gen.ResponseMimeType = "application/json"
+	}
+	if req.N > 1 {
+		gen.CandidateCount = req.N
+	}
+	if *gen != (generationConfig{}) {
+		body.GenerationConfig = gen
+	}
+	return base.MarshalWithExtra(body, p.cfg.ExtraBody)
 }
 
 func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
-	_ = ctx
-	_ = req
-	return nil, errors.New("step/providers/google: not implemented")
+	model := base.RequestModel(req, p.model)
+	payload, err := p.buildBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	debug, err := p.cfg.DebugLogger()
+	if err != nil {
+		return nil, err
+	}
+	if debug != nil {
+		rec := base.NewDebugRecord("request", payload)
+		rec.Provider = providerName
+		rec.Model = model
+		rec.IdempotencyKey = step.IdempotencyKey(ctx)
+		_ = debug.Log(rec)
+	}
+
+	ctx, stats := base.TrackTransfer(ctx)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint(model), bytes.NewReader(payload))
+	if err != nil {
+		_ = debug.Close()
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.cfg.APIKey)
+	for k, v := range p.cfg.ExtraHeaders {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := p.keys.RoundTrip(httpReq, func(req *http.Request) (*http.Response, error) {
+		return base.RoundTrip(req, http.DefaultClient.Do, p.cfg.CompressRequests)
+	})
+	if err != nil {
+		_ = debug.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		_ = debug.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("step/providers/google: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	out := base.ReportTransfer(NewStream(model, resp.Body, debug).EmitRaw(req.Raw), stats)
+	out = base.MapFinishReasons(out, p.cfg.FinishReasons)
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		out = base.ValidateJSON(out, base.PrefillText(req.History))
+	}
+	return out, nil
 }
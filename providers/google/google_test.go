@@ -0,0 +1,518 @@
+package google_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/google"
+	"github.com/inspirepan/step/testkit"
+)
+
+const (
+	envKey = "GEMINI_API_KEY"
+	model  = "gemini-3-flash-preview"
+)
+
+func TestGoogle_Conformance(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	testkit.RunConformanceSuite(t, google.New(model))
+}
+
+func TestGoogle_ThinkingRoundTrip(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := google.New(model, google.WithThinking(1024))
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestThinkingRoundTrip(t, cfg)
+}
+
+// fakeGemini serves scripted SSE responses and records request bodies.
+type fakeGemini struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []map[string]any
+}
+
+func (f *fakeGemini) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req map[string]any
+	_ = json.Unmarshal(body, &req)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprintf(w, "data: %s\r\n\r\n", resp)
+}
+
+type echoTool struct{}
+
+func (echoTool) Spec() step.ToolSpec {
+	return step.ToolSpec{Name: "echo", Description: "Echo the input", Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"text": map[string]any{"type": "string"}},
+	}}
+}
+
+func (echoTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: string(call.ArgsJSON)}}}, nil
+}
+
+// TestGoogle_ThoughtSignatureRoundTrip checks that signatures on function calls
+// survive the step loop and a JSON round-trip of the history, and are sent back
+// on the same function call part.
+func TestGoogle_ThoughtSignatureRoundTrip(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[` +
+			`{"text":"Let me call echo twice.","thought":true},` +
+			`{"functionCall":{"name":"echo","args":{"text":"a"}},"thoughtSignature":"sig-A"},` +
+			`{"functionCall":{"name":"echo","args":{"text":"b"}}}` +
+			`]},"finishReason":"STOP"}],"modelVersion":"gemini-3-flash-preview"}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"done"},{"text":"","thoughtSignature":"sig-B"}]},"finishReason":"STOP"}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]},"finishReason":"STOP"}]}`,
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := google.New(model, google.WithAPIKey("test"), google.WithBaseURL(server.URL))
+	ctx := context.Background()
+	history := []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "echo a and b"}}}}
+
+	for turn := 0; turn < 3; turn++ {
+		result, err := step.Step(ctx, step.StepRequest{Provider: provider, History: history, Tools: []step.Tool{echoTool{}}})
+		if err != nil {
+			t.Fatalf("turn %d: Step failed: %v", turn, err)
+		}
+		history = append(history, result...)
+		if turn == 1 {
+			history = append(history, step.UserMessage{Parts: []step.Part{step.TextPart{Text: "thanks"}}})
+		}
+
+		// Persist and restore the history between turns.
+		data, err := json.Marshal(history)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatal(err)
+		}
+		history = history[:0]
+		for _, r := range raw {
+			m, err := step.UnmarshalMessage(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			history = append(history, m)
+		}
+	}
+
+	// Second request: model turn carries sig-A on the first call only; results are merged.
+	contents := fake.requests[1]["contents"].([]any)
+	if len(contents) != 3 {
+		t.Fatalf("expected user, model, user contents, got %d", len(contents))
+	}
+	modelParts := contents[1].(map[string]any)["parts"].([]any)
+	var sigs []any
+	for _, p := range modelParts {
+		pm := p.(map[string]any)
+		if _, ok := pm["functionCall"]; ok {
+			sigs = append(sigs, pm["thoughtSignature"])
+		}
+	}
+	if len(sigs) != 2 || sigs[0] != "sig-A" || sigs[1] != nil {
+		t.Errorf("expected signatures [sig-A <nil>], got %v", sigs)
+	}
+	results := contents[2].(map[string]any)["parts"].([]any)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 function responses in one turn, got %d", len(results))
+	}
+	for _, r := range results {
+		fr := r.(map[string]any)["functionResponse"].(map[string]any)
+		if fr["name"] != "echo" {
+			t.Errorf("expected function response name echo, got %v", fr["name"])
+		}
+		if _, ok := fr["id"]; ok {
+			t.Errorf("expected locally generated call id to be omitted, got %v", fr["id"])
+		}
+	}
+
+	// Third request: the trailing text signature is sent back.
+	contents = fake.requests[2]["contents"].([]any)
+	last := contents[len(contents)-2].(map[string]any)["parts"].([]any)
+	found := false
+	for _, p := range last {
+		if p.(map[string]any)["thoughtSignature"] == "sig-B" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected sig-B in model turn, got %v", last)
+	}
+}
+
+func TestGoogle_GroundingCitations(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Go 1.25 was released in August 2025."}]},"finishReason":"STOP",` +
+			`"groundingMetadata":{"webSearchQueries":["go 1.25 release date"],` +
+			`"groundingChunks":[{"web":{"uri":"https://go.dev/doc/go1.25","title":"go.dev"}},{"web":{"uri":"https://example.com","title":"example.com"}}],` +
+			`"groundingSupports":[{"segment":{"startIndex":0,"endIndex":36,"text":"Go 1.25 was released in August 2025."},"groundingChunkIndices":[0]}]}}]}`,
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := google.New(model, google.WithAPIKey("test"), google.WithBaseURL(server.URL), google.WithBuiltinTools(google.BuiltinGoogleSearch))
+	result, err := step.Step(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "When was Go 1.25 released?"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+
+	tools := fake.requests[0]["tools"].([]any)
+	if _, ok := tools[0].(map[string]any)["googleSearch"]; !ok {
+		t.Errorf("expected googleSearch tool, got %v", tools)
+	}
+
+	msg := result[0].(step.AssistantMessage)
+	var cites []step.CitationPart
+	for _, p := range msg.Parts {
+		if c, ok := p.(step.CitationPart); ok {
+			cites = append(cites, c)
+		}
+	}
+	if len(cites) != 2 {
+		t.Fatalf("expected 2 citations, got %d", len(cites))
+	}
+	if cites[0].URL != "https://go.dev/doc/go1.25" || cites[0].EndIndex != 36 {
+		t.Errorf("unexpected first citation: %+v", cites[0])
+	}
+	if cites[1].URL != "https://example.com" || cites[1].Text != "" {
+		t.Errorf("unexpected uncited source: %+v", cites[1])
+	}
+	queries, ok := step.MetadataValue[[]string](msg.Metadata, google.MetadataSearchQueries)
+	if !ok || len(queries) != 1 {
+		t.Errorf("expected search queries in metadata, got %v", msg.Metadata)
+	}
+}
+
+func TestGoogle_JSONMode(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"answer\": 42}"}]},"finishReason":"STOP"}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"The answer is 42."}]},"finishReason":"STOP"}]}`,
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := google.New(model, google.WithAPIKey("test"), google.WithBaseURL(server.URL), google.WithJSONMode())
+	req := step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "What is the answer?"}}}},
+	}
+	if _, err := step.Step(context.Background(), req); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	gen := fake.requests[0]["generationConfig"].(map[string]any)
+	if gen["responseMimeType"] != "application/json" {
+		t.Errorf("expected responseMimeType application/json, got %v", gen)
+	}
+
+	_, err := step.Step(context.Background(), req)
+	var invalid *step.InvalidJSONError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected InvalidJSONError, got %v", err)
+	}
+}
+
+func TestGoogle_Candidates(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"index":0,"content":{"role":"model","parts":[{"text":"Heads"}]},"finishReason":"STOP"},` +
+			`{"index":1,"content":{"role":"model","parts":[{"text":"Tails"}]},"finishReason":"STOP"}],` +
+			`"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2}}`,
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := google.New(model, google.WithAPIKey("test"), google.WithBaseURL(server.URL))
+	cands, err := step.Candidates(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Flip a coin."}}}},
+	}, 2)
+	if err != nil {
+		t.Fatalf("Candidates failed: %v", err)
+	}
+	gen := fake.requests[0]["generationConfig"].(map[string]any)
+	if gen["candidateCount"] != float64(2) {
+		t.Errorf("expected candidateCount 2, got %v", gen)
+	}
+	if len(cands) != 2 || cands[0].Text() != "Heads" || cands[1].Text() != "Tails" {
+		t.Fatalf("unexpected candidates: %+v", cands)
+	}
+	if cands[0].Usage == nil || cands[0].Usage.OutputTokens != 2 || cands[1].Usage != nil {
+		t.Errorf("expected request usage on the first candidate only")
+	}
+}
+
+func TestGoogle_DryRun(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi"}]},"finishReason":"STOP"}]}`,
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := google.New(model, google.WithAPIKey("test"), google.WithBaseURL(server.URL), google.WithExtraBody("labels", map[string]any{"team": "eval"}))
+	req := step.StepRequest{
+		Provider:     provider,
+		SystemPrompt: "Be brief.",
+		History:      []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}},
+	}
+	payload, err := step.DryRun(context.Background(), req)
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if len(fake.requests) != 0 {
+		t.Fatalf("DryRun sent %d requests", len(fake.requests))
+	}
+	if want := server.URL + "/v1beta/models/" + model + ":streamGenerateContent?alt=sse"; payload.URL != want {
+		t.Errorf("expected URL %q, got %q", want, payload.URL)
+	}
+	if _, ok := payload.Headers["x-goog-api-key"]; ok {
+		t.Error("payload must not include the API key")
+	}
+
+	result, err := step.Step(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	prov := result[0].(step.AssistantMessage).Provenance
+	if prov.RequestBytes != int64(len(payload.Body)) || prov.ResponseBytes == 0 {
+		t.Errorf("expected %d request bytes and a response size, got %d and %d", len(payload.Body), prov.RequestBytes, prov.ResponseBytes)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(payload.Body, &body); err != nil {
+		t.Fatalf("invalid payload body: %v", err)
+	}
+	got, _ := json.Marshal(body)
+	sent, _ := json.Marshal(fake.requests[0])
+	if string(got) != string(sent) {
+		t.Errorf("payload body differs from the sent request:\n%s\n%s", got, sent)
+	}
+}
+
+// TestGoogle_SplitMultibyteChunks reads the SSE body one byte at a time, so
+// multibyte characters arrive split across reads.
+func TestGoogle_SplitMultibyteChunks(t *testing.T) {
+	body := "data: " + `{"candidates":[{"content":{"role":"model","parts":[{"text":"héllo 日本"}]}}]}` + "\r\n\r\n" +
+		"data: " + `{"candidates":[{"content":{"role":"model","parts":[{"text":"語 😀"}]},"finishReason":"STOP"}]}` + "\r\n\r\n"
+	stream := google.NewStream(model, io.NopCloser(iotest.OneByteReader(strings.NewReader(body))), nil)
+	defer stream.Close()
+
+	var deltas strings.Builder
+	var final step.AssistantMessage
+	for {
+		up, err := stream.Next(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		switch u := up.(type) {
+		case step.ProviderDeltaUpdate:
+			if td, ok := u.Delta.(step.TextDelta); ok {
+				deltas.WriteString(td.Delta)
+			}
+		case step.ProviderMessageUpdate:
+			final = u.Message
+		}
+	}
+	const want = "héllo 日本語 😀"
+	if final.Text() != want || deltas.String() != want {
+		t.Errorf("got message %q and deltas %q, want %q", final.Text(), deltas.String(), want)
+	}
+}
+
+func TestGoogle_FinishReasons(t *testing.T) {
+	fake := &fakeGemini{responses: []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"I can't"}]},"finishReason":"SAFETY"}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi"}]},"finishReason":"OTHER"}]}`,
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider := google.New(model, google.WithAPIKey("test"), google.WithBaseURL(server.URL), google.WithFinishReason("OTHER", step.StopStop))
+	history := []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}}
+	for _, want := range []step.StopReason{step.StopContentFilter, step.StopStop} {
+		result, err := step.Step(context.Background(), step.StepRequest{Provider: provider, History: history})
+		if err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+		msg := result[0].(step.AssistantMessage)
+		if msg.StopReason != want || msg.Provenance.FinishReason == "" {
+			t.Errorf("got stop reason %q (raw %q), want %q", msg.StopReason, msg.Provenance.FinishReason, want)
+		}
+	}
+}
+
+func TestGoogle_Ping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "good" {
+			http.Error(w, `{"error":{"message":"API key not valid"}}`, http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path != "/v1beta/models/"+model {
+			http.Error(w, `{"error":{"message":"model not found"}}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"name":"models/%s"}`, model)
+	}))
+	defer server.Close()
+
+	if err := google.New(model, google.WithAPIKey("good"), google.WithBaseURL(server.URL)).(step.Pinger).Ping(context.Background()); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+	if err := google.New(model, google.WithAPIKey("bad"), google.WithBaseURL(server.URL)).(step.Pinger).Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "API key not valid") {
+		t.Errorf("expected an invalid key error, got %v", err)
+	}
+	if err := google.New("gemini-nope", google.WithAPIKey("good"), google.WithBaseURL(server.URL)).(step.Pinger).Ping(context.Background()); err == nil {
+		t.Error("expected an unknown model error")
+	}
+}
+
+func TestGoogle_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			io.WriteString(w, `{"models":[{"name":"models/gemini-2.5-pro","displayName":"Gemini 2.5 Pro","inputTokenLimit":1048576,"outputTokenLimit":65536,"supportedGenerationMethods":["generateContent","countTokens"]},{"name":"models/embedding-001","supportedGenerationMethods":["embedContent"]}],"nextPageToken":"p2"}`)
+			return
+		}
+		io.WriteString(w, `{"models":[{"name":"models/gemini-2.0-flash-lite","displayName":"Gemini 2.0 Flash-Lite","inputTokenLimit":131072,"outputTokenLimit":8192,"supportedGenerationMethods":["generateContent"]}]}`)
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		opts []google.Option
+		want []int
+	}{
+		{nil, []int{200_000, 131_072}},
+		{[]google.Option{google.WithLongContext()}, []int{1_048_576, 131_072}},
+	} {
+		opts := append([]google.Option{google.WithAPIKey("test"), google.WithBaseURL(server.URL)}, tt.opts...)
+		models, err := step.ListModels(context.Background(), google.New(model, opts...))
+		if err != nil {
+			t.Fatalf("ListModels failed: %v", err)
+		}
+		if len(models) != 2 || models[0].ID != "gemini-2.5-pro" || models[0].MaxOutputTokens != 65536 || models[1].Name != "Gemini 2.0 Flash-Lite" {
+			t.Fatalf("unexpected models %+v", models)
+		}
+		if models[0].ContextWindow != tt.want[0] || models[1].ContextWindow != tt.want[1] {
+			t.Errorf("context windows %d, %d, want %v", models[0].ContextWindow, models[1].ContextWindow, tt.want)
+		}
+	}
+}
+
+func TestGoogle_APIKeys(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "good" {
+			http.Error(w, `{"error":{"message":"quota exceeded"}}`, http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprintf(w, `{"name":"models/%s"}`, model)
+	}))
+	defer server.Close()
+
+	provider := google.New(model, google.WithAPIKeys("exhausted", "good"), google.WithBaseURL(server.URL))
+	if err := provider.(step.Pinger).Ping(context.Background()); err != nil {
+		t.Errorf("expected to fail over to the second key, got %v", err)
+	}
+}
+
+func TestGoogle_ModelOverride(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hi"}]},"finishReason":"STOP"}]}`+"\r\n\r\n")
+	}))
+	defer server.Close()
+
+	req := step.StepRequest{
+		Provider: google.New(model, google.WithAPIKey("test"), google.WithBaseURL(server.URL)),
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hello"}}}},
+	}
+	result, err := step.Step(context.Background(), req, step.WithModel("gemini-other"))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if want := "/v1beta/models/gemini-other:streamGenerateContent"; len(paths) != 1 || paths[0] != want {
+		t.Errorf("expected a request to %s, got %v", want, paths)
+	}
+	if got := result[0].(step.AssistantMessage).Provenance.Model; got != "gemini-other" {
+		t.Errorf("expected provenance for the override, got %q", got)
+	}
+}
+
+func TestGoogle_Speech(t *testing.T) {
+	var paths []string
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, body)
+		if strings.HasSuffix(r.URL.Path, "-tts:generateContent") {
+			// "AAEC" is the PCM bytes 0, 1, 2.
+			fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=16000","data":"AAEC"}}]}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"Hello there.\n"}]}}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":4}}`)
+	}))
+	defer server.Close()
+
+	provider := google.New(model, google.WithAPIKey("test"), google.WithBaseURL(server.URL))
+	tr, err := provider.(step.Transcriber).Transcribe(context.Background(), step.TranscribeRequest{
+		Audio: step.Audio{MimeType: "audio/wav", Data: []byte("wav")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Text != "Hello there." || tr.Usage == nil || tr.Usage.TotalTokens != 24 {
+		t.Errorf("unexpected transcript %+v", tr)
+	}
+	parts := bodies[0]["contents"].([]any)[0].(map[string]any)["parts"].([]any)
+	if blob := parts[1].(map[string]any)["inlineData"].(map[string]any); blob["mimeType"] != "audio/wav" || blob["data"] != "d2F2" {
+		t.Errorf("unexpected audio part %v", blob)
+	}
+
+	out, err := provider.(step.Synthesizer).Synthesize(context.Background(), step.SynthesizeRequest{Text: "Hi", Voice: "Puck", Format: "wav"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/v1beta/models/gemini-2.5-flash-preview-tts:generateContent"; paths[1] != want {
+		t.Errorf("synthesized with %s, want %s", paths[1], want)
+	}
+	voice := bodies[1]["generationConfig"].(map[string]any)["speechConfig"].(map[string]any)["voiceConfig"].(map[string]any)["prebuiltVoiceConfig"].(map[string]any)["voiceName"]
+	if voice != "Puck" {
+		t.Errorf("voice = %v", voice)
+	}
+	if out.MimeType != "audio/wav" || len(out.Data) != 47 || string(out.Data[:4]) != "RIFF" || string(out.Data[44:]) != "\x00\x01\x02" {
+		t.Errorf("unexpected audio %s %q", out.MimeType, out.Data)
+	}
+	if rate := uint32(out.Data[24]) | uint32(out.Data[25])<<8 | uint32(out.Data[26])<<16; rate != 16000 {
+		t.Errorf("sample rate = %d, want 16000", rate)
+	}
+}
@@ -0,0 +1,197 @@
+package google
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+// ThoughtSignatureFormat marks a ThinkingPart that only carries a Gemini
+// thought signature. Gemini attaches signatures to the response part they
+// belong to (usually a function call); the provider stores them as a
+// signature-only ThinkingPart placed immediately before that part and
+// re-attaches them when the history is sent back.
+const ThoughtSignatureFormat = "google-thought-signature"
+
+// skipSignature is Gemini's documented placeholder for function calls whose
+// signature is unavailable, e.g. history produced by another model.
+const skipSignature = "skip_thought_signature_validator"
+
+// syntheticCallPrefix marks call IDs generated locally because the API returned none.
+const syntheticCallPrefix = "gcall_"
+
+func buildRequest(req step.ProviderRequest, targetModel string) generateRequest {
+	out := generateRequest{}
+	if req.SystemPrompt != "" {
+		out.SystemInstruction = &content{Parts: []part{{Text: req.SystemPrompt}}}
+	}
+
+	for _, msg := range req.History {
+		switch m := msg.(type) {
+		case step.UserMessage:
+			out.Contents = appendUser(out.Contents, convertUserMessage(m))
+		case *step.UserMessage:
+			out.Contents = appendUser(out.Contents, convertUserMessage(*m))
+		case step.AssistantMessage:
+			out.Contents = append(out.Contents, convertAssistantMessage(m, targetModel))
+		case *step.AssistantMessage:
+			out.Contents = append(out.Contents, convertAssistantMessage(*m, targetModel))
+		case step.ToolMessage:
+			out.Contents = appendUser(out.Contents, convertToolMessage(m))
+		case *step.ToolMessage:
+			out.Contents = appendUser(out.Contents, convertToolMessage(*m))
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		decls := make([]functionDeclaration, 0, len(req.Tools))
+		for _, spec := range req.Tools {
+			decls = append(decls, functionDeclaration{
+				Name:        spec.Name,
+				Description: spec.Description,
+				Parameters:  base.GeminiSchema(spec.Parameters),
+			})
+		}
+		out.Tools = append(out.Tools, tool{FunctionDeclarations: decls})
+	}
+	return out
+}
+
+// appendUser merges consecutive user turns; all function responses for one
+// model turn must be sent together.
+func appendUser(contents []content, parts []part) []content {
+	if n := len(contents); n > 0 && contents[n-1].Role == "user" {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, content{Role: "user", Parts: parts})
+}
+
+func convertUserMessage(m step.UserMessage) []part {
+	var parts []part
+	for _, p := range m.Parts {
+		switch v := p.(type) {
+		case step.TextPart:
+			parts = append(parts, part{Text: v.Text})
+		case *step.TextPart:
+			parts = append(parts, part{Text: v.Text})
+		case step.ImagePart:
+			parts = append(parts, part{InlineData: &blob{MimeType: v.MimeType, Data: v.DataB64}})
+		case *step.ImagePart:
+			parts = append(parts, part{InlineData: &blob{MimeType: v.MimeType, Data: v.DataB64}})
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, part{Text: "."})
+	}
+	return parts
+}
+
+func convertAssistantMessage(m step.AssistantMessage, targetModel string) content {
+	sourceModel := ""
+	if m.Provenance != nil {
+		sourceModel = m.Provenance.Model
+	}
+
+	var parts []part
+	var pendingSignature string
+	sawCall := false
+	for _, p := range m.Parts {
+		switch v := p.(type) {
+		case *step.ThinkingPart:
+			p = *v
+		case *step.TextPart:
+			p = *v
+		case *step.ToolCallPart:
+			p = *v
+		}
+		switch v := p.(type) {
+		case step.ThinkingPart:
+			model := v.ModelName
+			if model == "" {
+				model = sourceModel
+			}
+			sameModel := model == "" || model == targetModel
+			if v.Format == ThoughtSignatureFormat {
+				if sameModel {
+					pendingSignature = v.Signature
+				}
+				continue
+			}
+			if !sameModel {
+				if v.Thinking != "" {
+					parts = append(parts, part{Text: "<thinking>\n" + v.Thinking + "\n</thinking>\n"})
+				}
+				continue
+			}
+			if v.Thinking == "" && v.Signature == "" {
+				continue
+			}
+			parts = append(parts, part{Text: v.Thinking, Thought: true, ThoughtSignature: v.Signature})
+		case step.TextPart:
+			parts = append(parts, part{Text: v.Text, ThoughtSignature: pendingSignature})
+			pendingSignature = ""
+		case step.ToolCallPart:
+			sig := pendingSignature
+			pendingSignature = ""
+			// The first call of a turn must carry a signature for Gemini 3 validation.
+			if sig == "" && !sawCall && strings.HasPrefix(targetModel, "gemini-3") {
+				sig = skipSignature
+			}
+			sawCall = true
+			args := json.RawMessage(v.ArgsJSON)
+			if len(args) == 0 || !json.Valid(args) {
+				args = json.RawMessage("{}")
+			}
+			id := v.CallID
+			if strings.HasPrefix(id, syntheticCallPrefix) {
+				id = ""
+			}
+			parts = append(parts, part{
+				FunctionCall:     &functionCall{ID: id, Name: v.Name, Args: args},
+				ThoughtSignature: sig,
+			})
+		}
+	}
+	if pendingSignature != "" {
+		// A trailing signature belongs to an empty final part.
+		parts = append(parts, part{ThoughtSignature: pendingSignature})
+	}
+	if len(parts) == 0 {
+		parts = append(parts, part{Text: "."})
+	}
+	return content{Role: "model", Parts: parts}
+}
+
+func convertToolMessage(m step.ToolMessage) []part {
+	var text strings.Builder
+	var images []part
+	for _, p := range m.Parts {
+		switch v := p.(type) {
+		case step.TextPart:
+			text.WriteString(v.Text)
+		case *step.TextPart:
+			text.WriteString(v.Text)
+		case step.ImagePart:
+			images = append(images, part{InlineData: &blob{MimeType: v.MimeType, Data: v.DataB64}})
+		case *step.ImagePart:
+			images = append(images, part{InlineData: &blob{MimeType: v.MimeType, Data: v.DataB64}})
+		}
+	}
+	output := text.String()
+	if output == "" {
+		output = "<system-reminder>Tool ran without output or errors</system-reminder>"
+	}
+	key := "output"
+	if m.IsError {
+		key = "error"
+	}
+	id := m.CallID
+	if strings.HasPrefix(id, syntheticCallPrefix) {
+		id = ""
+	}
+	parts := []part{{FunctionResponse: &functionResponse{ID: id, Name: m.Name, Response: map[string]any{key: output}}}}
+	return append(parts, images...)
+}
@@ -0,0 +1,70 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+var _ step.ModelLister = (*provider)(nil)
+
+// standardContextWindow is the prompt size, in tokens, up to which Gemini
+// bills at standard rates; see WithLongContext.
+const standardContextWindow = 200_000
+
+type modelList struct {
+	Models []struct {
+		Name                       string   `json:"name"`
+		DisplayName                string   `json:"displayName"`
+		InputTokenLimit            int      `json:"inputTokenLimit"`
+		OutputTokenLimit           int      `json:"outputTokenLimit"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// ListModels lists the models that can generate content. Their context
+// window is capped at the standard tier unless WithLongContext is set.
+func (p *provider) ListModels(ctx context.Context) ([]step.ModelInfo, error) {
+	var models []step.ModelInfo
+	query := url.Values{"pageSize": {"1000"}}
+	for {
+		body, err := p.get(ctx, "/v1beta/models?"+query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var page modelList
+		err = json.NewDecoder(body).Decode(&page)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range page.Models {
+			if !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+				continue
+			}
+			models = append(models, step.ModelInfo{
+				ID:              strings.TrimPrefix(m.Name, "models/"),
+				Name:            m.DisplayName,
+				ContextWindow:   p.contextWindow(m.InputTokenLimit),
+				MaxOutputTokens: m.OutputTokenLimit,
+			})
+		}
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// contextWindow returns the usable window of a model accepting limit tokens.
+func (p *provider) contextWindow(limit int) int {
+	if p.cfg.LongContext || limit == 0 {
+		return limit
+	}
+	return min(limit, standardContextWindow)
+}
@@ -0,0 +1,49 @@
+package google
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+var _ step.Pinger = (*provider)(nil)
+
+// Ping checks the API key by fetching the configured model, which also
+// verifies that the model ID exists.
+func (p *provider) Ping(ctx context.Context) error {
+	body, err := p.get(ctx, "/v1beta/models/"+p.model)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// get sends an authenticated GET to path and returns the body of a 200 response.
+func (p *provider) get(ctx context.Context, path string) (io.ReadCloser, error) {
+	baseURL := p.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", p.cfg.APIKey)
+	for k, v := range p.cfg.ExtraHeaders {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.keys.RoundTrip(req, http.DefaultClient.Do)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("step/providers/google: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,211 @@
+package google
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+var (
+	_ step.Transcriber       = (*provider)(nil)
+	_ step.StreamTranscriber = (*provider)(nil)
+	_ step.Synthesizer       = (*provider)(nil)
+)
+
+const (
+	// defaultSpeechModel synthesizes speech; text models cannot produce audio.
+	defaultSpeechModel = "gemini-2.5-flash-preview-tts"
+	defaultVoice       = "Kore"
+	// pcmRate is the sample rate of Gemini's 16-bit mono PCM output.
+	pcmRate = 24000
+)
+
+// Transcribe transcribes req.Audio with the provider's model, or req.Model.
+func (p *provider) Transcribe(ctx context.Context, req step.TranscribeRequest) (step.Transcript, error) {
+	resp, err := p.post(ctx, cmp.Or(req.Model, p.model), "generateContent", transcribeRequest(req))
+	if err != nil {
+		return step.Transcript{}, err
+	}
+	defer resp.Body.Close()
+	var out generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return step.Transcript{}, fmt.Errorf("step/providers/google: decode transcript: %w", err)
+	}
+	var text strings.Builder
+	if len(out.Candidates) > 0 {
+		for _, part := range out.Candidates[0].Content.Parts {
+			if !part.Thought {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+	tr := step.Transcript{Text: strings.TrimSpace(text.String()), Language: req.Language}
+	if u := out.UsageMetadata; u != nil {
+		output := u.CandidatesTokenCount + u.ThoughtsTokenCount
+		tr.Usage = &step.Usage{
+			InputTokens:      u.PromptTokenCount,
+			OutputTokens:     output,
+			CachedReadTokens: u.CachedContentTokenCount,
+			TotalTokens:      u.PromptTokenCount + output,
+		}
+	}
+	return tr, nil
+}
+
+// TranscribeStream streams the transcript of req.Audio as text deltas.
+func (p *provider) TranscribeStream(ctx context.Context, req step.TranscribeRequest) (step.ProviderStream, error) {
+	model := cmp.Or(req.Model, p.model)
+	resp, err := p.post(ctx, model, "streamGenerateContent?alt=sse", transcribeRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return NewStream(model, resp.Body, nil), nil
+}
+
+func transcribeRequest(req step.TranscribeRequest) generateRequest {
+	prompt := "Transcribe the speech in this audio verbatim. Reply with the transcript only, without timestamps or speaker labels."
+	if req.Language != "" {
+		prompt += " The speech is in " + req.Language + "."
+	}
+	if req.Prompt != "" {
+		prompt += "\n\nContext for the transcription:\n" + req.Prompt
+	}
+	return generateRequest{Contents: []content{{
+		Role: "user",
+		Parts: []part{
+			{Text: prompt},
+			{InlineData: &blob{MimeType: req.Audio.MimeType, Data: base64.StdEncoding.EncodeToString(req.Audio.Data)}},
+		},
+	}}}
+}
+
+// Synthesize speaks req.Text with a Gemini TTS model, by default
+// gemini-2.5-flash-preview-tts with the "Kore" voice. Instructions are
+// prepended to the text, which is how Gemini takes style directions. The
+// audio is 24 kHz 16-bit mono PCM, or a WAV file of it when req.Format is
+// "wav"; Speed is not supported.
+func (p *provider) Synthesize(ctx context.Context, req step.SynthesizeRequest) (step.Audio, error) {
+	if req.Format != "" && req.Format != "pcm" && req.Format != "wav" {
+		return step.Audio{}, fmt.Errorf("step/providers/google: unsupported speech format %q; use pcm or wav", req.Format)
+	}
+	text := req.Text
+	if req.Instructions != "" {
+		text = req.Instructions + ": " + text
+	}
+	body := speechRequest{
+		Contents:         []content{{Role: "user", Parts: []part{{Text: text}}}},
+		GenerationConfig: speechGenerationConfig{ResponseModalities: []string{"AUDIO"}},
+	}
+	body.GenerationConfig.SpeechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName = cmp.Or(req.Voice, defaultVoice)
+
+	resp, err := p.post(ctx, cmp.Or(req.Model, defaultSpeechModel), "generateContent", body)
+	if err != nil {
+		return step.Audio{}, err
+	}
+	defer resp.Body.Close()
+	var out generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return step.Audio{}, fmt.Errorf("step/providers/google: decode speech: %w", err)
+	}
+	var pcm []byte
+	mimeType := fmt.Sprintf("audio/L16;rate=%d", pcmRate)
+	for _, c := range out.Candidates {
+		for _, part := range c.Content.Parts {
+			if part.InlineData == nil {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+			if err != nil {
+				return step.Audio{}, fmt.Errorf("step/providers/google: decode speech: %w", err)
+			}
+			pcm = append(pcm, data...)
+			mimeType = part.InlineData.MimeType
+		}
+	}
+	if len(pcm) == 0 {
+		return step.Audio{}, errors.New("step/providers/google: the response has no audio")
+	}
+	if req.Format == "wav" {
+		return step.Audio{MimeType: "audio/wav", Data: wav(pcm, sampleRate(mimeType))}, nil
+	}
+	return step.Audio{MimeType: mimeType, Data: pcm}, nil
+}
+
+// sampleRate reads the rate parameter of a PCM mime type such as
+// "audio/L16;codec=pcm;rate=24000".
+func sampleRate(mimeType string) int {
+	_, params, _ := mime.ParseMediaType(mimeType)
+	if rate, err := strconv.Atoi(params["rate"]); err == nil && rate > 0 {
+		return rate
+	}
+	return pcmRate
+}
+
+// wav wraps 16-bit little-endian mono PCM in a WAV header.
+func wav(pcm []byte, rate int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	le := func(v any) { _ = binary.Write(&buf, binary.LittleEndian, v) }
+	buf.WriteString("RIFF")
+	le(uint32(36 + len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	le(uint32(16))       // fmt chunk size
+	le(uint16(1))        // PCM
+	le(uint16(1))        // channels
+	le(uint32(rate))     // sample rate
+	le(uint32(rate * 2)) // byte rate
+	le(uint16(2))        // block align
+	le(uint16(16))       // bits per sample
+	buf.WriteString("data")
+	le(uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// post sends body to the method endpoint of model, e.g. "generateContent",
+// and returns the response if it succeeded.
+func (p *provider) post(ctx context.Context, model, method string, body any) (*http.Response, error) {
+	payload, err := base.MarshalWithExtra(body, p.cfg.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := p.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	url := strings.TrimRight(baseURL, "/") + "/v1beta/models/" + model + ":" + method
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.cfg.APIKey)
+	for k, v := range p.cfg.ExtraHeaders {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := p.keys.RoundTrip(httpReq, func(req *http.Request) (*http.Response, error) {
+		return base.RoundTrip(req, http.DefaultClient.Do, p.cfg.CompressRequests)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("step/providers/google: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,390 @@
+package google
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+const providerName = "google"
+
+// Stream implements step.ProviderStream for the Gemini streamGenerateContent SSE endpoint.
+type Stream struct {
+	modelName string
+	body      io.ReadCloser
+	scanner   *bufio.Scanner
+	debug     *base.DebugLogger
+	raw       bool
+
+	mu sync.Mutex
+
+	done bool
+	err  error
+
+	pending []step.ProviderUpdate
+
+	// candidates accumulates each candidate by index; deltas are streamed for candidate 0 only.
+	candidates map[int]*candidateState
+
+	requestID   string
+	servedModel string
+	usage       *step.Usage
+	startedAt   time.Time
+}
+
+type candidateState struct {
+	index int
+	// parts are built in the order the model produced them. open is the index of
+	// the text or thinking part still receiving chunks, or -1.
+	parts     []step.Part
+	open      int
+	hasCalls  bool
+	grounding *groundingMetadata
+
+	finishReason string
+}
+
+// NewStream wraps an SSE response body.
+func NewStream(modelName string, body io.ReadCloser, debug *base.DebugLogger) *Stream {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &Stream{
+		modelName:  modelName,
+		body:       body,
+		scanner:    scanner,
+		debug:      debug,
+		candidates: map[int]*candidateState{0: {open: -1}},
+		startedAt:  time.Now(),
+	}
+}
+
+// EmitRaw makes the stream emit a step.ProviderRawUpdate for every chunk
+// when enabled; see step.ProviderRequest.Raw.
+func (s *Stream) EmitRaw(enabled bool) *Stream {
+	s.raw = enabled
+	return s
+}
+
+func (s *Stream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) > 0 {
+		return s.dequeue()
+	}
+	if s.done {
+		return nil, io.EOF
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Finalize a partial message so callers still get a coherent AssistantMessage.
+			if !s.done {
+				s.finalize()
+				if len(s.pending) > 0 {
+					return s.dequeue()
+				}
+			}
+			return nil, io.EOF
+		default:
+		}
+
+		if !s.scanner.Scan() {
+			if err := s.scanner.Err(); err != nil && ctx.Err() == nil {
+				s.err = err
+				return nil, s.err
+			}
+			s.finalize()
+			if len(s.pending) > 0 {
+				return s.dequeue()
+			}
+			return nil, io.EOF
+		}
+
+		line := s.scanner.Text()
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		var chunk generateResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			s.err = err
+			return nil, s.err
+		}
+		s.processChunk(chunk, data)
+		if len(s.pending) > 0 {
+			return s.dequeue()
+		}
+	}
+}
+
+func (s *Stream) Close() error {
+	if s.debug != nil {
+		_ = s.debug.Close()
+	}
+	return s.body.Close()
+}
+
+func (s *Stream) enqueue(up step.ProviderUpdate) {
+	s.pending = append(s.pending, up)
+}
+
+func (s *Stream) dequeue() (step.ProviderUpdate, error) {
+	up := s.pending[0]
+	s.pending = s.pending[1:]
+
+	if s.debug != nil {
+		rec := base.NewDebugRecord("update", up)
+		rec.Provider = providerName
+		rec.Model = s.modelName
+		_ = s.debug.Log(rec)
+	}
+
+	return up, nil
+}
+
+func (s *Stream) processChunk(chunk generateResponse, raw string) {
+	if s.debug != nil {
+		rec := base.NewDebugRecord("chunk", json.RawMessage(strings.TrimSpace(raw)))
+		rec.Provider = providerName
+		rec.Model = s.modelName
+		_ = s.debug.Log(rec)
+	}
+	if s.raw {
+		s.enqueue(step.ProviderRawUpdate{Provider: providerName, Data: json.RawMessage(strings.TrimSpace(raw))})
+	}
+
+	if chunk.ResponseID != "" {
+		s.requestID = chunk.ResponseID
+	}
+	if chunk.ModelVersion != "" {
+		s.servedModel = chunk.ModelVersion
+	}
+	if u := chunk.UsageMetadata; u != nil {
+		output := u.CandidatesTokenCount + u.ThoughtsTokenCount
+		s.usage = &step.Usage{
+			InputTokens:      u.PromptTokenCount,
+			OutputTokens:     output,
+			CachedReadTokens: u.CachedContentTokenCount,
+			TotalTokens:      u.PromptTokenCount + output,
+		}
+	}
+	for _, cand := range chunk.Candidates {
+		c, ok := s.candidates[cand.Index]
+		if !ok {
+			c = &candidateState{index: cand.Index, open: -1}
+			s.candidates[cand.Index] = c
+		}
+		if cand.FinishReason != "" {
+			c.finishReason = cand.FinishReason
+		}
+		if cand.GroundingMetadata != nil {
+			c.grounding = cand.GroundingMetadata
+		}
+		for _, p := range cand.Content.Parts {
+			s.processPart(c, p)
+		}
+	}
+}
+
+func (s *Stream) delta(c *candidateState, d step.MessageDelta) {
+	if c.index == 0 {
+		s.enqueue(step.ProviderDeltaUpdate{Delta: d})
+	}
+}
+
+func (s *Stream) processPart(c *candidateState, p part) {
+	// Built-in code execution is surfaced as fenced text so it stays visible in history.
+	if code := p.ExecutableCode; code != nil {
+		p = part{Text: "\n```" + strings.ToLower(code.Language) + "\n" + code.Code + "\n```\n", ThoughtSignature: p.ThoughtSignature}
+	} else if r := p.CodeExecutionResult; r != nil {
+		p = part{Text: "\n```output\n" + r.Output + "\n```\n", ThoughtSignature: p.ThoughtSignature}
+	}
+
+	switch {
+	case p.FunctionCall != nil:
+		c.open = -1
+		c.addSignature(p.ThoughtSignature, s.modelName)
+		id := p.FunctionCall.ID
+		if id == "" {
+			id = newSyntheticCallID()
+		}
+		args := string(p.FunctionCall.Args)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		c.parts = append(c.parts, step.ToolCallPart{CallID: id, Name: p.FunctionCall.Name, ArgsJSON: json.RawMessage(args)})
+		c.hasCalls = true
+		s.delta(c, step.ToolCallDelta{CallID: id, Name: p.FunctionCall.Name, ArgsDelta: args})
+
+	case p.Thought:
+		if tp, ok := c.openPart().(step.ThinkingPart); ok {
+			tp.Thinking += p.Text
+			if p.ThoughtSignature != "" {
+				tp.Signature = p.ThoughtSignature
+			}
+			c.parts[c.open] = tp
+		} else {
+			c.parts = append(c.parts, step.ThinkingPart{Thinking: p.Text, Signature: p.ThoughtSignature, ModelName: s.modelName})
+			c.open = len(c.parts) - 1
+		}
+		if p.Text != "" || p.ThoughtSignature != "" {
+			s.delta(c, step.ThinkingDelta{Delta: p.Text, Signature: p.ThoughtSignature})
+		}
+
+	default:
+		if p.ThoughtSignature != "" {
+			// Signatures on text parts (often an empty final part) are kept in front of the text they belong to.
+			if _, ok := c.openPart().(step.TextPart); ok {
+				c.parts = slices.Insert(c.parts, c.open, step.Part(signaturePart(p.ThoughtSignature, s.modelName)))
+				c.open++
+			} else {
+				c.open = -1
+				c.addSignature(p.ThoughtSignature, s.modelName)
+			}
+		}
+		if p.Text == "" {
+			return
+		}
+		if tp, ok := c.openPart().(step.TextPart); ok {
+			tp.Text += p.Text
+			c.parts[c.open] = tp
+		} else {
+			c.parts = append(c.parts, step.TextPart{Text: p.Text})
+			c.open = len(c.parts) - 1
+		}
+		s.delta(c, step.TextDelta{Delta: p.Text})
+	}
+}
+
+func (c *candidateState) openPart() step.Part {
+	if c.open < 0 {
+		return nil
+	}
+	return c.parts[c.open]
+}
+
+// addSignature appends a signature-only ThinkingPart that precedes the next part.
+func (c *candidateState) addSignature(sig, model string) {
+	if sig != "" {
+		c.parts = append(c.parts, signaturePart(sig, model))
+	}
+}
+
+func signaturePart(sig, model string) step.ThinkingPart {
+	return step.ThinkingPart{Signature: sig, Format: ThoughtSignatureFormat, ModelName: model}
+}
+
+func (s *Stream) finalize() {
+	s.done = true
+
+	idxs := make([]int, 0, len(s.candidates))
+	for idx := range s.candidates {
+		idxs = append(idxs, idx)
+	}
+	slices.Sort(idxs)
+
+	now := time.Now()
+	for _, idx := range idxs {
+		c := s.candidates[idx]
+		stop := mapFinishReason(c.finishReason)
+		if c.hasCalls && stop == step.StopStop {
+			stop = step.StopToolUse
+		}
+
+		parts := c.parts
+		var metadata step.Metadata
+		if g := c.grounding; g != nil {
+			parts = append(parts, citations(g)...)
+			if len(g.WebSearchQueries) > 0 {
+				metadata.Set(MetadataSearchQueries, g.WebSearchQueries)
+			}
+		}
+
+		// Usage is reported for the whole request; it is attached to the first candidate.
+		var usage *step.Usage
+		if idx == idxs[0] {
+			usage = s.usage
+		}
+
+		msg := step.AssistantMessage{
+			Metadata:   metadata,
+			Parts:      parts,
+			Timestamp:  now.UnixMilli(),
+			Usage:      usage,
+			StopReason: stop,
+			Provenance: &step.Provenance{
+				Provider:     providerName,
+				Model:        s.modelName,
+				ServedModel:  s.servedModel,
+				RequestID:    s.requestID,
+				LatencyMs:    now.Sub(s.startedAt).Milliseconds(),
+				FinishReason: c.finishReason,
+			},
+		}
+		s.enqueue(step.ProviderMessageUpdate{Message: msg, Candidate: idx})
+	}
+}
+
+// citations converts grounding metadata to CitationParts, one per supported
+// segment and source. Sources without supports are cited without a span.
+func citations(g *groundingMetadata) []step.Part {
+	var out []step.Part
+	cited := make(map[int]bool)
+	for _, sup := range g.GroundingSupports {
+		for _, idx := range sup.GroundingChunkIndices {
+			if idx < 0 || idx >= len(g.GroundingChunks) || g.GroundingChunks[idx].Web == nil {
+				continue
+			}
+			web := g.GroundingChunks[idx].Web
+			cited[idx] = true
+			out = append(out, step.CitationPart{
+				URL:        web.URI,
+				Title:      web.Title,
+				Text:       sup.Segment.Text,
+				StartIndex: sup.Segment.StartIndex,
+				EndIndex:   sup.Segment.EndIndex,
+			})
+		}
+	}
+	for i, chunk := range g.GroundingChunks {
+		if chunk.Web != nil && !cited[i] {
+			out = append(out, step.CitationPart{URL: chunk.Web.URI, Title: chunk.Web.Title})
+		}
+	}
+	return out
+}
+
+func mapFinishReason(reason string) step.StopReason {
+	switch reason {
+	case "", "STOP", "FINISH_REASON_UNSPECIFIED":
+		return step.StopStop
+	case "MAX_TOKENS":
+		return step.StopLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return step.StopContentFilter
+	default:
+		// MALFORMED_FUNCTION_CALL, OTHER, ...
+		return step.StopError
+	}
+}
+
+func newSyntheticCallID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return syntheticCallPrefix + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,146 @@
+package google
+
+import "encoding/json"
+
+// Wire types for the Gemini generateContent REST API.
+
+type generateRequest struct {
+	SystemInstruction *content          `json:"systemInstruction,omitempty"`
+	Contents          []content         `json:"contents"`
+	Tools             []tool            `json:"tools,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`
+	ThoughtSignature string            `json:"thoughtSignature,omitempty"`
+	InlineData       *blob             `json:"inlineData,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+
+	ExecutableCode      *executableCode      `json:"executableCode,omitempty"`
+	CodeExecutionResult *codeExecutionResult `json:"codeExecutionResult,omitempty"`
+}
+
+type executableCode struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
+
+type codeExecutionResult struct {
+	Outcome string `json:"outcome"`
+	Output  string `json:"output,omitempty"`
+}
+
+type blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type functionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type functionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations,omitempty"`
+	CodeExecution        *struct{}             `json:"codeExecution,omitempty"`
+	GoogleSearch         *struct{}             `json:"googleSearch,omitempty"`
+}
+
+type functionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type generationConfig struct {
+	Temperature     *float64        `json:"temperature,omitempty"`
+	MaxOutputTokens *int            `json:"maxOutputTokens,omitempty"`
+	ThinkingConfig  *thinkingConfig `json:"thinkingConfig,omitempty"`
+
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
+	CandidateCount   int    `json:"candidateCount,omitempty"`
+}
+
+type thinkingConfig struct {
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+}
+
+type generateResponse struct {
+	Candidates    []candidate    `json:"candidates"`
+	UsageMetadata *usageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string         `json:"modelVersion,omitempty"`
+	ResponseID    string         `json:"responseId,omitempty"`
+}
+
+type candidate struct {
+	Index             int                `json:"index,omitempty"`
+	Content           content            `json:"content"`
+	FinishReason      string             `json:"finishReason,omitempty"`
+	GroundingMetadata *groundingMetadata `json:"groundingMetadata,omitempty"`
+}
+
+type groundingMetadata struct {
+	WebSearchQueries  []string           `json:"webSearchQueries,omitempty"`
+	GroundingChunks   []groundingChunk   `json:"groundingChunks,omitempty"`
+	GroundingSupports []groundingSupport `json:"groundingSupports,omitempty"`
+}
+
+type groundingChunk struct {
+	Web *struct {
+		URI   string `json:"uri"`
+		Title string `json:"title"`
+	} `json:"web,omitempty"`
+}
+
+type groundingSupport struct {
+	Segment struct {
+		StartIndex int    `json:"startIndex"`
+		EndIndex   int    `json:"endIndex"`
+		Text       string `json:"text"`
+	} `json:"segment"`
+	GroundingChunkIndices []int `json:"groundingChunkIndices"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// speechRequest is a generateContent request for audio output, which takes
+// generation options that text requests do not.
+type speechRequest struct {
+	Contents         []content              `json:"contents"`
+	GenerationConfig speechGenerationConfig `json:"generationConfig"`
+}
+
+type speechGenerationConfig struct {
+	ResponseModalities []string     `json:"responseModalities"`
+	SpeechConfig       speechConfig `json:"speechConfig"`
+}
+
+type speechConfig struct {
+	VoiceConfig struct {
+		PrebuiltVoiceConfig struct {
+			VoiceName string `json:"voiceName"`
+		} `json:"prebuiltVoiceConfig"`
+	} `json:"voiceConfig"`
+}
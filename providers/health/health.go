@@ -0,0 +1,62 @@
+// Package health verifies provider credentials and endpoints, so deployments
+// can fail at startup rather than on the first user request.
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// ErrUnsupported is returned for providers that can only be checked by generating.
+var ErrUnsupported = errors.New("health: provider does not support health checks")
+
+// Check verifies that provider is reachable and accepts its credentials. It
+// uses step.Pinger when implemented, and otherwise lists models with
+// step.ModelLister. No tokens are generated.
+func Check(ctx context.Context, provider step.Provider) error {
+	switch p := provider.(type) {
+	case nil:
+		return step.ErrNoProvider
+	case step.Pinger:
+		return p.Ping(ctx)
+	case step.ModelLister:
+		_, err := p.ListModels(ctx)
+		return err
+	default:
+		return ErrUnsupported
+	}
+}
+
+// Result is the outcome of checking one provider.
+type Result struct {
+	Name    string
+	Latency time.Duration
+	Err     error
+}
+
+// CheckAll checks the named providers concurrently and returns the results
+// sorted by name. Use errors.Join over the Err fields to fail on any of them.
+func CheckAll(ctx context.Context, providers map[string]step.Provider) []Result {
+	results := make([]Result, 0, len(providers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, provider := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := Check(ctx, provider)
+			mu.Lock()
+			results = append(results, Result{Name: name, Latency: time.Since(start), Err: err})
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
@@ -0,0 +1,55 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/cache"
+	"github.com/inspirepan/step/providers/health"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+type pinger struct {
+	*mock.Provider
+	err error
+}
+
+func (p pinger) Ping(context.Context) error { return p.err }
+
+type lister struct{ *mock.Provider }
+
+func (lister) ListModels(context.Context) ([]step.ModelInfo, error) {
+	return nil, errors.New("401 Unauthorized")
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	if err := health.Check(ctx, pinger{Provider: mock.New()}); err != nil {
+		t.Errorf("expected a healthy pinger, got %v", err)
+	}
+	if err := health.Check(ctx, lister{mock.New()}); err == nil || err.Error() != "401 Unauthorized" {
+		t.Errorf("expected the list error, got %v", err)
+	}
+	if err := health.Check(ctx, mock.New()); !errors.Is(err, health.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if err := health.Check(ctx, cache.New(mock.New(), cache.NewMemoryStore())); !errors.Is(err, health.ErrUnsupported) {
+		t.Errorf("expected the cache to check the wrapped provider, got %v", err)
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	down := errors.New("connection refused")
+	results := health.CheckAll(context.Background(), map[string]step.Provider{
+		"local":   pinger{Provider: mock.New(), err: down},
+		"primary": pinger{Provider: mock.New()},
+	})
+	if len(results) != 2 || results[0].Name != "local" || results[1].Name != "primary" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if !errors.Is(results[0].Err, down) || results[1].Err != nil {
+		t.Errorf("unexpected errors %v, %v", results[0].Err, results[1].Err)
+	}
+}
@@ -0,0 +1,160 @@
+// Package mock provides a scripted Provider for tests and evaluations.
+package mock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// ErrExhausted is returned when the provider has no scripted responses left.
+var ErrExhausted = errors.New("step/providers/mock: no scripted responses left")
+
+// Response is one scripted provider turn.
+type Response struct {
+	// Message is the final assistant message. Deltas are synthesized from its parts.
+	Message step.AssistantMessage
+	// Err, if set, is returned from Stream instead of a stream.
+	Err error
+	// Delay is applied before the first update is emitted.
+	Delay time.Duration
+}
+
+// Text returns a Response with a single text part.
+func Text(text string) Response {
+	return Response{Message: step.AssistantMessage{
+		Parts:      []step.Part{step.TextPart{Text: text}},
+		StopReason: step.StopStop,
+	}}
+}
+
+// ToolCalls returns a Response that requests the given tool calls.
+func ToolCalls(calls ...step.ToolCallPart) Response {
+	parts := make([]step.Part, 0, len(calls))
+	for _, c := range calls {
+		parts = append(parts, c)
+	}
+	return Response{Message: step.AssistantMessage{
+		Parts:      parts,
+		StopReason: step.StopToolUse,
+	}}
+}
+
+// Provider replays scripted responses in order. It is safe for concurrent use.
+type Provider struct {
+	mu        sync.Mutex
+	responses []Response
+	requests  []step.ProviderRequest
+}
+
+var _ step.Provider = (*Provider)(nil)
+
+// New creates a Provider that returns the given responses in order.
+func New(responses ...Response) *Provider {
+	return &Provider{responses: responses}
+}
+
+// Push appends responses to the script.
+func (p *Provider) Push(responses ...Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = append(p.responses, responses...)
+}
+
+// Requests returns a copy of every request received so far.
+func (p *Provider) Requests() []step.ProviderRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]step.ProviderRequest(nil), p.requests...)
+}
+
+// Remaining returns the number of unused scripted responses.
+func (p *Provider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.responses)
+}
+
+func (p *Provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		p.mu.Unlock()
+		return nil, ErrExhausted
+	}
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	p.mu.Unlock()
+
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return NewStream(resp.Message, resp.Delay), nil
+}
+
+// Stream emits synthesized deltas for a message followed by the message itself.
+type Stream struct {
+	delay   time.Duration
+	pending []step.ProviderUpdate
+}
+
+var _ step.ProviderStream = (*Stream)(nil)
+
+// NewStream creates a stream that replays msg. Delay is applied before the first update.
+func NewStream(msg step.AssistantMessage, delay time.Duration) *Stream {
+	if msg.Timestamp == 0 {
+		msg.Timestamp = time.Now().UnixMilli()
+	}
+	if msg.StopReason == "" {
+		msg.StopReason = step.StopStop
+	}
+	if msg.Provenance == nil {
+		msg.Provenance = &step.Provenance{Provider: "mock", FinishReason: string(msg.StopReason)}
+	}
+	var pending []step.ProviderUpdate
+	for _, part := range msg.Parts {
+		if d := deltaForPart(part); d != nil {
+			pending = append(pending, step.ProviderDeltaUpdate{Delta: d})
+		}
+	}
+	pending = append(pending, step.ProviderMessageUpdate{Message: msg})
+	return &Stream{delay: delay, pending: pending}
+}
+
+func (s *Stream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	if s.delay > 0 {
+		timer := time.NewTimer(s.delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		s.delay = 0
+	}
+	if len(s.pending) == 0 {
+		return nil, io.EOF
+	}
+	up := s.pending[0]
+	s.pending = s.pending[1:]
+	return up, nil
+}
+
+func (s *Stream) Close() error { return nil }
+
+func deltaForPart(part step.Part) step.MessageDelta {
+	switch p := part.(type) {
+	case step.TextPart:
+		return step.TextDelta{Delta: p.Text}
+	case step.ThinkingPart:
+		return step.ThinkingDelta{ID: p.ID, Delta: p.Thinking, Signature: p.Signature}
+	case step.ToolCallPart:
+		return step.ToolCallDelta{CallID: p.CallID, Name: p.Name, ArgsDelta: string(p.ArgsJSON)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package openrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/inspirepan/step/providers/base"
+)
+
+// ErrGenerationNotFound is returned by GetGeneration when OpenRouter has no stats
+// for the ID yet. Stats usually become available a few seconds after the stream ends.
+var ErrGenerationNotFound = errors.New("step/providers/openrouter: generation not found")
+
+// Generation holds OpenRouter's post-hoc stats for a single generation.
+type Generation struct {
+	ID                     string  `json:"id"`
+	Model                  string  `json:"model"`
+	ProviderName           string  `json:"provider_name"`
+	TotalCost              float64 `json:"total_cost"`
+	CacheDiscount          float64 `json:"cache_discount"`
+	Latency                int64   `json:"latency"`         // ms until first token
+	GenerationTime         int64   `json:"generation_time"` // ms spent generating
+	TokensPrompt           int     `json:"tokens_prompt"`
+	TokensCompletion       int     `json:"tokens_completion"`
+	NativeTokensPrompt     int     `json:"native_tokens_prompt"`
+	NativeTokensCompletion int     `json:"native_tokens_completion"`
+	NativeTokensReasoning  int     `json:"native_tokens_reasoning"`
+	NativeTokensCached     int     `json:"native_tokens_cached"`
+	FinishReason           string  `json:"finish_reason"`
+	NativeFinishReason     string  `json:"native_finish_reason"`
+	Streamed               bool    `json:"streamed"`
+	CreatedAt              string  `json:"created_at"`
+}
+
+// GetGeneration queries the /generation endpoint for exact cost and latency of a
+// completed request. id is AssistantMessage.Provenance.RequestID. Only the API
+// key or keys, BaseURL and ExtraHeaders are used from opts.
+func GetGeneration(ctx context.Context, id string, opts ...Option) (*Generation, error) {
+	cfg := Config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = os.Getenv("OPENROUTER_API_KEY")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+
+	endpoint := strings.TrimRight(cfg.BaseURL, "/") + "/generation?id=" + url.QueryEscape(id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	for k, v := range cfg.ExtraHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := base.NewKeyRotation(cfg.Keys, base.BearerKey).RoundTrip(req, http.DefaultClient.Do)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrGenerationNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("step/providers/openrouter: generation %s: %s: %s", id, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var out struct {
+		Data Generation `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out.Data, nil
+}
@@ -0,0 +1,85 @@
+package openrouter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+var (
+	_ step.ModelLister = (*provider)(nil)
+	_ step.Pinger      = (*provider)(nil)
+)
+
+// Ping checks the API key against the /key endpoint. Listing models does not
+// need a key on OpenRouter, so it cannot be used for this.
+func (p *provider) Ping(ctx context.Context) error {
+	var out json.RawMessage
+	return p.get(ctx, "key", &out)
+}
+
+// ListModels lists the models available on OpenRouter, with their context
+// window and output limit.
+func (p *provider) ListModels(ctx context.Context) ([]step.ModelInfo, error) {
+	var out struct {
+		Data []struct {
+			ID            string `json:"id"`
+			Name          string `json:"name"`
+			Created       int64  `json:"created"`
+			ContextLength int    `json:"context_length"`
+			TopProvider   struct {
+				MaxCompletionTokens int `json:"max_completion_tokens"`
+			} `json:"top_provider"`
+		} `json:"data"`
+	}
+	if err := p.get(ctx, "models", &out); err != nil {
+		return nil, err
+	}
+	models := make([]step.ModelInfo, 0, len(out.Data))
+	for _, m := range out.Data {
+		info := step.ModelInfo{
+			ID:              m.ID,
+			Name:            m.Name,
+			ContextWindow:   m.ContextLength,
+			MaxOutputTokens: m.TopProvider.MaxCompletionTokens,
+		}
+		if m.Created > 0 {
+			info.Created = time.Unix(m.Created, 0)
+		}
+		models = append(models, info)
+	}
+	return models, nil
+}
+
+// get decodes the JSON response of an authenticated GET request to path.
+func (p *provider) get(ctx context.Context, path string, out any) error {
+	endpoint := strings.TrimRight(p.cfg.BaseURL, "/") + "/" + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if p.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+	for k, v := range p.cfg.ExtraHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.keys.RoundTrip(req, http.DefaultClient.Do)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("step/providers/openrouter: %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -2,22 +2,43 @@ package openrouter
 
 import (
 	"context"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/anthropic"
 	"github.com/inspirepan/step/providers/base"
 	cc "github.com/inspirepan/step/providers/chatcompletion"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
 )
 
-func isClaudeModel(model string) bool {
-	return strings.Contains(strings.ToLower(model), "claude")
-}
+// ModelFamily is the vendor family of a model. It decides the vendor-specific
+// request features sent through OpenRouter: Anthropic beta headers for
+// Claude, and cache_control breakpoints for Claude and Gemini.
+type ModelFamily string
+
+const (
+	FamilyOther  ModelFamily = ""
+	FamilyClaude ModelFamily = "claude"
+	FamilyGemini ModelFamily = "gemini"
+)
 
-func isGeminiModel(model string) bool {
-	return strings.Contains(strings.ToLower(model), "gemini")
+// DefaultModelFamily detects the family from the model ID: IDs containing
+// "claude" are FamilyClaude and IDs containing "gemini" FamilyGemini.
+func DefaultModelFamily(model string) ModelFamily {
+	lower := strings.ToLower(model)
+	switch {
+	case strings.Contains(lower, "claude"):
+		return FamilyClaude
+	case strings.Contains(lower, "gemini"):
+		return FamilyGemini
+	default:
+		return FamilyOther
+	}
 }
 
 const defaultBaseURL = "https://openrouter.ai/api/v1"
@@ -60,12 +81,32 @@ type AnthropicThinkingConfig struct {
 	MaxTokens int
 }
 
+// DataCollectionPolicy controls whether providers that store or train on data may be used.
+type DataCollectionPolicy string
+
+const (
+	DataCollectionAllow DataCollectionPolicy = "allow"
+	DataCollectionDeny  DataCollectionPolicy = "deny"
+)
+
+// MaxPrice caps the price OpenRouter may route to, in USD per million tokens
+// (Request is USD per request, Image is USD per image). Zero fields are unset.
+type MaxPrice struct {
+	Prompt     float64
+	Completion float64
+	Request    float64
+	Image      float64
+}
+
 // ProviderRouting configures OpenRouter's provider routing preferences.
 type ProviderRouting struct {
-	Order  []string             // Preferred provider order
-	Only   []string             // Only use these providers
-	Ignore []string             // Ignore these providers
-	Sort   ProviderSortStrategy // Sorting strategy when order is not specified
+	Order          []string             // Preferred provider order
+	Only           []string             // Only use these providers
+	Ignore         []string             // Ignore these providers
+	Sort           ProviderSortStrategy // Sorting strategy when order is not specified
+	ZDR            bool                 // Only use zero data retention endpoints
+	DataCollection DataCollectionPolicy // Whether providers that collect data are allowed
+	MaxPrice       *MaxPrice            // Upper price bound for routing
 }
 
 // Config configures OpenRouter API provider.
@@ -77,8 +118,47 @@ type Config struct {
 	ReasoningEffort   ReasoningEffort
 	Verbosity         Verbosity
 	ProviderRouting   *ProviderRouting
+	FallbackModels    []string
+	Transforms        []string
+
+	// SessionAffinity lists the upstream providers a session is pinned to;
+	// see WithSessionAffinity.
+	SessionAffinity []string
+
+	// ModelFamilies sets the family of exact model IDs, taking precedence
+	// over FamilyMatcher; see WithModelFamily.
+	ModelFamilies map[string]ModelFamily
+	// FamilyMatcher detects the family of other model IDs. Nil uses
+	// DefaultModelFamily.
+	FamilyMatcher func(model string) ModelFamily
+
+	// AnthropicBetas are sent in the x-anthropic-beta header for Claude
+	// models. Nil sends DefaultAnthropicBetas; an empty slice sends none.
+	AnthropicBetas []string
+}
+
+// DefaultAnthropicBetas returns the Anthropic beta features enabled for
+// Claude models unless Config.AnthropicBetas is set: fine-grained tool
+// streaming and interleaved thinking.
+func DefaultAnthropicBetas() []string {
+	return []string{anthropic.BetaFineGrainedToolStreaming, anthropic.BetaInterleavedThinking}
+}
+
+// family returns the family of model.
+func (c Config) family(model string) ModelFamily {
+	if f, ok := c.ModelFamilies[model]; ok {
+		return f
+	}
+	if c.FamilyMatcher != nil {
+		return c.FamilyMatcher(model)
+	}
+	return DefaultModelFamily(model)
 }
 
+// TransformMiddleOut compresses prompts that exceed the context window by
+// removing or truncating messages from the middle of the conversation.
+const TransformMiddleOut = "middle-out"
+
 // Option is a functional option for this provider.
 type Option func(*Config)
 
@@ -87,6 +167,18 @@ func WithAPIKey(key string) Option {
 	return func(c *Config) { c.APIKey = key }
 }
 
+// WithAPIKeys spreads requests over several API keys, moving to the next key
+// when one is rejected (401) or rate limited (429). See base.KeyRotation.
+func WithAPIKeys(keys ...string) Option {
+	return func(c *Config) { c.Keys = base.StaticKeys(keys) }
+}
+
+// WithKeyProvider fetches API keys at request time, e.g. from a secret
+// manager, rotating between them like WithAPIKeys.
+func WithKeyProvider(keys base.KeyProvider) Option {
+	return func(c *Config) { c.Keys = keys }
+}
+
 // WithTemperature sets the temperature.
 func WithTemperature(t float64) Option {
 	return func(c *Config) { c.Temperature = &t }
@@ -97,11 +189,23 @@ func WithMaxOutputTokens(n int) Option {
 	return func(c *Config) { c.MaxOutputTokens = &n }
 }
 
+// WithJSONMode requests a single JSON object as output without a schema.
+// The final text is validated; a *step.InvalidJSONError is returned if it does not parse.
+func WithJSONMode() Option {
+	return func(c *Config) { c.ResponseFormat = base.ResponseFormatJSONObject }
+}
+
 // WithDebug enables JSONL debug logging to the specified file path.
 func WithDebug(path string) Option {
 	return func(c *Config) { c.DebugPath = path }
 }
 
+// WithDebugSink sends debug records to sink, in addition to or instead of a
+// WithDebug file.
+func WithDebugSink(sink base.DebugSink) Option {
+	return func(c *Config) { c.DebugSink = sink }
+}
+
 // WithExtraHeader adds a custom header to requests.
 func WithExtraHeader(key, value string) Option {
 	return func(c *Config) {
@@ -122,6 +226,24 @@ func WithExtraBody(key string, value any) Option {
 	}
 }
 
+// WithFinishReason maps the raw provider finish reason raw to reason,
+// overriding the built-in mapping. The raw value is always kept in
+// Provenance.FinishReason.
+func WithFinishReason(raw string, reason step.StopReason) Option {
+	return func(c *Config) {
+		if c.FinishReasons == nil {
+			c.FinishReasons = make(map[string]step.StopReason)
+		}
+		c.FinishReasons[raw] = reason
+	}
+}
+
+// WithCacheStrategy sets where prompt cache breakpoints are placed.
+// By default Claude and Gemini models cache the system prompt and the latest user/tool message.
+func WithCacheStrategy(strategy base.CacheStrategy) Option {
+	return func(c *Config) { c.Cache = &strategy }
+}
+
 // WithThinkingBudget configures thinking/reasoning budget for Anthropic models.
 // See: https://openrouter.ai/docs/use-cases/reasoning-tokens#anthropic-models-with-reasoning-tokens
 func WithThinkingBudget(maxTokens int) Option {
@@ -170,7 +292,8 @@ func WithProviderOnly(providers ...string) Option {
 	}
 }
 
-// WithProviderOrder sets the preferred provider order.
+// WithProviderOrder sets the preferred provider order. The provider that
+// served the request is reported in AssistantMessage.Provenance.Upstream.
 func WithProviderOrder(providers ...string) Option {
 	return func(c *Config) {
 		if c.ProviderRouting == nil {
@@ -190,6 +313,104 @@ func WithProviderIgnore(providers ...string) Option {
 	}
 }
 
+// WithSessionAffinity keeps each session on one upstream provider, so its
+// prompt cache is not lost to OpenRouter switching upstreams mid-conversation.
+// Requests whose context carries a step.SessionID send the upstreams as the
+// provider order, ranked by a hash of the session ID: a session always tries
+// the same upstream first, while sessions spread over all of them. Fallbacks
+// still apply when that upstream is unavailable. For these requests the
+// ranking replaces WithProviderOrder.
+func WithSessionAffinity(upstreams ...string) Option {
+	return func(c *Config) { c.SessionAffinity = upstreams }
+}
+
+// WithModelFamily declares the family of the given model IDs, for aliases and
+// fine-tunes whose IDs do not name their vendor, or FamilyOther for IDs that
+// name one by accident. It also applies to models set per step with
+// step.WithModel.
+func WithModelFamily(family ModelFamily, models ...string) Option {
+	return func(c *Config) {
+		if c.ModelFamilies == nil {
+			c.ModelFamilies = make(map[string]ModelFamily)
+		}
+		for _, m := range models {
+			c.ModelFamilies[m] = family
+		}
+	}
+}
+
+// WithBetaFeatures enables Anthropic beta features for Claude models in
+// addition to DefaultAnthropicBetas, e.g. anthropic.BetaContext1M. OpenRouter
+// forwards them in the x-anthropic-beta header.
+func WithBetaFeatures(names ...string) Option {
+	return func(c *Config) {
+		if c.AnthropicBetas == nil {
+			c.AnthropicBetas = DefaultAnthropicBetas()
+		}
+		for _, name := range names {
+			if !slices.Contains(c.AnthropicBetas, name) {
+				c.AnthropicBetas = append(c.AnthropicBetas, name)
+			}
+		}
+	}
+}
+
+// WithContext1M enables Anthropic's one million token context window for
+// Claude models that support it. See anthropic.WithContext1M.
+func WithContext1M() Option {
+	return WithBetaFeatures(anthropic.BetaContext1M)
+}
+
+// WithFamilyMatcher replaces DefaultModelFamily for detecting the family of
+// model IDs not declared with WithModelFamily.
+func WithFamilyMatcher(fn func(model string) ModelFamily) Option {
+	return func(c *Config) { c.FamilyMatcher = fn }
+}
+
+// WithFallbackModels sets models OpenRouter tries, in order, when the primary model
+// is unavailable or rejects the request. The model that served the request is
+// reported in AssistantMessage.Provenance.ServedModel.
+func WithFallbackModels(models ...string) Option {
+	return func(c *Config) { c.FallbackModels = models }
+}
+
+// WithTransforms sets OpenRouter prompt transforms, e.g. TransformMiddleOut.
+// Calling it with no arguments sends an empty list, which disables the transforms
+// OpenRouter applies by default to small-context models.
+func WithTransforms(transforms ...string) Option {
+	return func(c *Config) { c.Transforms = append([]string{}, transforms...) }
+}
+
+// WithZDR restricts routing to endpoints with a zero data retention policy.
+func WithZDR() Option {
+	return func(c *Config) {
+		if c.ProviderRouting == nil {
+			c.ProviderRouting = &ProviderRouting{}
+		}
+		c.ProviderRouting.ZDR = true
+	}
+}
+
+// WithDataCollection sets whether providers that may store or train on data can be used.
+func WithDataCollection(policy DataCollectionPolicy) Option {
+	return func(c *Config) {
+		if c.ProviderRouting == nil {
+			c.ProviderRouting = &ProviderRouting{}
+		}
+		c.ProviderRouting.DataCollection = policy
+	}
+}
+
+// WithMaxPrice sets the maximum price OpenRouter may route to.
+func WithMaxPrice(price MaxPrice) Option {
+	return func(c *Config) {
+		if c.ProviderRouting == nil {
+			c.ProviderRouting = &ProviderRouting{}
+		}
+		c.ProviderRouting.MaxPrice = &price
+	}
+}
+
 // New creates a Provider using OpenRouter API.
 // It reads OPENROUTER_API_KEY from environment if not explicitly set.
 // BaseURL is fixed to https://openrouter.ai/api/v1.
@@ -212,37 +433,60 @@ func New(model string, opts ...Option) step.Provider {
 	if cfg.BaseURL != "" {
 		clientOpts = append(clientOpts, option.WithBaseURL(cfg.BaseURL))
 	}
-	for k, v := range cfg.ExtraHeaders {
+
+	headers, body := requestExtras(model, cfg)
+	for k, v := range headers {
 		clientOpts = append(clientOpts, option.WithHeader(k, v))
 	}
+	for k, v := range body {
+		clientOpts = append(clientOpts, option.WithJSONSet(k, v))
+	}
+	keys := base.NewKeyRotation(cfg.Keys, base.BearerKey)
+	clientOpts = append(clientOpts, option.WithMiddleware(keys.RoundTrip, base.TransferMiddleware(cfg.CompressRequests)))
+	client := openai.NewClient(clientOpts...)
+	return &provider{model: model, cfg: cfg, client: client, keys: keys, headers: headers, body: body}
+}
 
+// requestExtras returns the headers and body fields sent on top of the
+// Chat Completions params, including cfg.ExtraHeaders and cfg.ExtraBody.
+func requestExtras(model string, cfg Config) (map[string]string, map[string]any) {
+	headers := make(map[string]string)
 	// Add Anthropic beta headers for Claude models
-	if isClaudeModel(model) {
-		clientOpts = append(clientOpts, option.WithHeader(
-			"x-anthropic-beta",
-			"fine-grained-tool-streaming-2025-05-14,interleaved-thinking-2025-05-14",
-		))
+	betas := cfg.AnthropicBetas
+	if betas == nil {
+		betas = DefaultAnthropicBetas()
+	}
+	if cfg.family(model) == FamilyClaude && len(betas) > 0 {
+		headers["x-anthropic-beta"] = strings.Join(betas, ",")
+	}
+	for k, v := range cfg.ExtraHeaders {
+		headers[k] = v
 	}
 
-	// Apply OpenRouter-specific options to ExtraBody
 	// Include usage info to get cache tokens at the end of the response
-	clientOpts = append(clientOpts, option.WithJSONSet("usage", map[string]any{
-		"include": true,
-	}))
+	body := map[string]any{
+		"usage": map[string]any{"include": true},
+	}
 
 	if cfg.AnthropicThinking != nil {
-		clientOpts = append(clientOpts, option.WithJSONSet("reasoning", map[string]any{
+		body["reasoning"] = map[string]any{
 			"enable":     cfg.AnthropicThinking.Enable,
 			"max_tokens": cfg.AnthropicThinking.MaxTokens,
-		}))
+		}
 	} else if cfg.ReasoningEffort != "" {
-		clientOpts = append(clientOpts, option.WithJSONSet("reasoning", map[string]any{
+		body["reasoning"] = map[string]any{
 			"effort": string(cfg.ReasoningEffort),
-		}))
+		}
 	}
 
+	if len(cfg.FallbackModels) > 0 {
+		body["models"] = append([]string{model}, cfg.FallbackModels...)
+	}
+	if cfg.Transforms != nil {
+		body["transforms"] = cfg.Transforms
+	}
 	if cfg.Verbosity != "" {
-		clientOpts = append(clientOpts, option.WithJSONSet("verbosity", string(cfg.Verbosity)))
+		body["verbosity"] = string(cfg.Verbosity)
 	}
 
 	if cfg.ProviderRouting != nil {
@@ -259,30 +503,70 @@ func New(model string, opts ...Option) step.Provider {
 		if cfg.ProviderRouting.Sort != "" {
 			provider["sort"] = string(cfg.ProviderRouting.Sort)
 		}
+		if cfg.ProviderRouting.ZDR {
+			provider["zdr"] = true
+		}
+		if cfg.ProviderRouting.DataCollection != "" {
+			provider["data_collection"] = string(cfg.ProviderRouting.DataCollection)
+		}
+		if mp := cfg.ProviderRouting.MaxPrice; mp != nil {
+			price := make(map[string]any)
+			if mp.Prompt > 0 {
+				price["prompt"] = mp.Prompt
+			}
+			if mp.Completion > 0 {
+				price["completion"] = mp.Completion
+			}
+			if mp.Request > 0 {
+				price["request"] = mp.Request
+			}
+			if mp.Image > 0 {
+				price["image"] = mp.Image
+			}
+			if len(price) > 0 {
+				provider["max_price"] = price
+			}
+		}
 		if len(provider) > 0 {
-			clientOpts = append(clientOpts, option.WithJSONSet("provider", provider))
+			body["provider"] = provider
 		}
 	}
 
 	for k, v := range cfg.ExtraBody {
-		clientOpts = append(clientOpts, option.WithJSONSet(k, v))
+		body[k] = v
 	}
-	client := openai.NewClient(clientOpts...)
-	return &provider{model: model, cfg: cfg, client: client}
+	return headers, body
 }
 
 type provider struct {
 	model  string
 	cfg    Config
 	client openai.Client
+	keys   *base.KeyRotation
+
+	headers map[string]string
+	body    map[string]any
 }
 
-func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
-	handler := NewReasoningHandler(p.model)
+// buildParams converts req to request params, applying the provider config.
+func (p *provider) buildParams(req step.ProviderRequest, handler cc.ReasoningHandler) openai.ChatCompletionNewParams {
+	jsonMode := p.cfg.ResponseFormat == base.ResponseFormatJSONObject
+	if jsonMode {
+		req.SystemPrompt = base.JSONModeSystemPrompt(req.SystemPrompt)
+	}
 	// Enable cache_control for Claude and Gemini models via OpenRouter
-	useCacheControl := isClaudeModel(p.model) || isGeminiModel(p.model)
-	params := cc.BuildMessages(req, handler, p.model, useCacheControl)
-	params.Model = p.model
+	model := base.RequestModel(req, p.model)
+	cache := base.NoCache()
+	if p.cfg.Cache != nil {
+		cache = *p.cfg.Cache
+	} else if f := p.cfg.family(model); f == FamilyClaude || f == FamilyGemini {
+		cache = base.DefaultCacheStrategy()
+	}
+	params := cc.BuildMessagesWithCache(req, handler, model, cache)
+	params.Model = model
+	if jsonMode {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONObject: &shared.ResponseFormatJSONObjectParam{}}
+	}
 
 	// Apply config options
 	if p.cfg.Temperature != nil {
@@ -291,18 +575,114 @@ func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.P
 	if p.cfg.MaxOutputTokens != nil {
 		params.MaxTokens = openai.Int(int64(*p.cfg.MaxOutputTokens))
 	}
+	return params
+}
+
+var _ step.PayloadBuilder = (*provider)(nil)
+
+// BuildPayload returns the request Stream would send, without sending it.
+func (p *provider) BuildPayload(ctx context.Context, req step.ProviderRequest) (step.Payload, error) {
+	model := base.RequestModel(req, p.model)
+	params := p.buildParams(req, NewReasoningHandler(model))
+	headers, body := p.extras(req)
+	if order := p.sessionOrder(ctx); order != nil {
+		body = maps.Clone(body)
+		routing := map[string]any{}
+		if r, ok := body["provider"].(map[string]any); ok {
+			routing = maps.Clone(r)
+		}
+		routing["order"] = order
+		body["provider"] = routing
+	}
+	return cc.NewPayload("openrouter", p.cfg.BaseURL, params, body, headers)
+}
+
+// sessionOrder returns the upstream order for the session of ctx, or nil
+// without session affinity or a session ID.
+func (p *provider) sessionOrder(ctx context.Context) []string {
+	session := step.SessionID(ctx)
+	if len(p.cfg.SessionAffinity) == 0 || session == "" {
+		return nil
+	}
+	return base.RankByAffinity(session, p.cfg.SessionAffinity)
+}
 
-	debug, err := base.NewDebugLogger(p.cfg.DebugPath)
+// extras returns the headers and body fields sent for req. They differ from
+// the client's defaults when req overrides the model or reasoning effort, or
+// asks for efficient tools.
+func (p *provider) extras(req step.ProviderRequest) (map[string]string, map[string]any) {
+	if !p.overridesExtras(req) {
+		return p.headers, p.body
+	}
+	cfg := p.cfg
+	if req.ReasoningEffort != "" {
+		cfg.AnthropicThinking = nil
+		cfg.ReasoningEffort = ReasoningEffort(req.ReasoningEffort)
+	}
+	if req.EfficientTools {
+		WithBetaFeatures(anthropic.BetaTokenEfficientTools)(&cfg)
+	}
+	return requestExtras(base.RequestModel(req, p.model), cfg)
+}
+
+// overridesExtras reports whether req needs other headers or body fields
+// than the client's defaults.
+func (p *provider) overridesExtras(req step.ProviderRequest) bool {
+	return base.RequestModel(req, p.model) != p.model || req.ReasoningEffort != "" || req.EfficientTools
+}
+
+// requestOptions returns the request options that adapt the client's default
+// headers and body fields to req.
+func (p *provider) requestOptions(req step.ProviderRequest) []option.RequestOption {
+	if !p.overridesExtras(req) {
+		return nil
+	}
+	headers, body := p.extras(req)
+	var opts []option.RequestOption
+	for k := range p.headers {
+		if _, ok := headers[k]; !ok {
+			opts = append(opts, option.WithHeaderDel(k))
+		}
+	}
+	for k, v := range headers {
+		opts = append(opts, option.WithHeader(k, v))
+	}
+	for k, v := range body {
+		opts = append(opts, option.WithJSONSet(k, v))
+	}
+	return opts
+}
+
+func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	model := base.RequestModel(req, p.model)
+	newHandler := func() cc.ReasoningHandler { return NewReasoningHandler(model) }
+	params := p.buildParams(req, newHandler())
+
+	debug, err := p.cfg.DebugLogger()
 	if err != nil {
 		return nil, err
 	}
 	if debug != nil {
 		rec := base.NewDebugRecord("request", params)
 		rec.Provider = "openrouter"
-		rec.Model = p.model
+		rec.Model = model
+		rec.IdempotencyKey = step.IdempotencyKey(ctx)
 		_ = debug.Log(rec)
 	}
 
-	stream := p.client.Chat.Completions.NewStreaming(ctx, params)
-	return cc.NewStream("openrouter", p.model, stream, handler, debug), nil
+	ctx, stats := base.TrackTransfer(ctx)
+	opts := p.requestOptions(req)
+	if order := p.sessionOrder(ctx); order != nil {
+		opts = append(opts, option.WithJSONSet("provider.order", order))
+	}
+	if key := step.IdempotencyKey(ctx); key != "" {
+		opts = append(opts, option.WithHeader(base.IdempotencyHeader, key))
+	}
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params, opts...)
+	out := base.ReportTransfer(cc.NewMultiStream("openrouter", model, stream, newHandler, debug).EmitRaw(req.Raw), stats)
+	out = base.MapFinishReasons(out, p.cfg.FinishReasons)
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		out = base.ValidateJSON(out, base.PrefillText(req.History))
+	}
+	return out, nil
 }
@@ -1,67 +1,339 @@
 package openrouter_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/inspirepan/step/internal/testutil"
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/anthropic"
 	"github.com/inspirepan/step/providers/openrouter"
+	"github.com/inspirepan/step/testkit"
 )
 
 const envKey = "OPENROUTER_API_KEY"
 
 func TestOpenRouter_BasicTextGeneration(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := openrouter.New(
 		"google/gemini-3-flash-preview",
 		openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal),
 		openrouter.WithDebug("openrouter.debug.log"),
 	)
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestBasicTextGeneration(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestBasicTextGeneration(t, cfg)
 }
 
 func TestOpenRouter_ToolCalling(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := openrouter.New("google/gemini-3-flash-preview", openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal))
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestToolCalling(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestToolCalling(t, cfg)
 }
 
 func TestOpenRouter_SystemPrompt(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := openrouter.New("google/gemini-3-flash-preview", openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal))
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestSystemPrompt(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestSystemPrompt(t, cfg)
 }
 
 func TestOpenRouter_MultiTurn(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := openrouter.New("google/gemini-3-flash-preview", openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal))
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestMultiTurn(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestMultiTurn(t, cfg)
+}
+
+func TestOpenRouter_ImageInput(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := openrouter.New("google/gemini-3-flash-preview", openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal))
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestImageInput(t, cfg)
+}
+
+func TestOpenRouter_Cancellation(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := openrouter.New("google/gemini-3-flash-preview", openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal))
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestCancellation(t, cfg)
+}
+
+func TestOpenRouter_ParallelToolCalls(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := openrouter.New("google/gemini-3-flash-preview", openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal))
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestParallelToolCalls(t, cfg)
+}
+
+func TestOpenRouter_EmptyResponse(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := openrouter.New("google/gemini-3-flash-preview", openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal))
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestEmptyResponse(t, cfg)
 }
 
 // TestOpenRouter_Claude tests Claude models via OpenRouter.
 func TestOpenRouter_Claude(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := openrouter.New("anthropic/claude-3.5-haiku")
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestBasicTextGeneration(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestBasicTextGeneration(t, cfg)
 }
 
 // TestOpenRouter_ClaudeWithThinking tests Claude models with thinking enabled.
 func TestOpenRouter_ClaudeWithThinking(t *testing.T) {
-	testutil.SkipIfNoEnv(t, envKey)
+	testkit.SkipIfNoEnv(t, envKey)
 
 	provider := openrouter.New(
 		"anthropic/claude-sonnet-4",
 		openrouter.WithThinkingBudget(5000),
 	)
-	cfg := testutil.DefaultConfig(provider)
-	testutil.TestBasicTextGeneration(t, cfg)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestBasicTextGeneration(t, cfg)
+}
+
+// TestOpenRouter_ThinkingRoundTrip tests reasoning_details round-trips for Gemini.
+func TestOpenRouter_ThinkingRoundTrip(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := openrouter.New("google/gemini-3-flash-preview", openrouter.WithReasoningEffort(openrouter.ReasoningEffortLow))
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestThinkingRoundTrip(t, cfg)
+}
+
+// TestOpenRouter_ClaudeThinkingRoundTrip tests signed thinking round-trips for Claude.
+func TestOpenRouter_ClaudeThinkingRoundTrip(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := openrouter.New(
+		"anthropic/claude-sonnet-4",
+		openrouter.WithThinkingBudget(2000),
+	)
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestThinkingRoundTrip(t, cfg)
+}
+
+// TestOpenRouter_FallbackModels tests that the serving model is reported when fallbacks are configured.
+func TestOpenRouter_FallbackModels(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := openrouter.New(
+		"google/gemini-3-flash-preview",
+		openrouter.WithFallbackModels("openai/gpt-4o-mini"),
+		openrouter.WithReasoningEffort(openrouter.ReasoningEffortMinimal),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	result, err := step.Step(ctx, step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Say hi."}}}},
+	})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	am, ok := result[0].(step.AssistantMessage)
+	if !ok || am.Provenance == nil {
+		t.Fatal("expected assistant message with provenance")
+	}
+	if am.Provenance.ServedModel == "" {
+		t.Error("expected served model to be reported")
+	}
+	t.Logf("served by %s", am.Provenance.ServedModel)
+}
+
+func TestOpenRouter_ListModels(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	models, err := step.ListModels(context.Background(), openrouter.New("google/gemini-3-flash-preview"))
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	m, ok := step.FindModel(models, "google/gemini-3-flash-preview")
+	if !ok || m.Name == "" || m.ContextWindow == 0 {
+		t.Errorf("unexpected model %+v (found %v among %d)", m, ok, len(models))
+	}
+}
+
+func TestOpenRouter_ModelOverride(t *testing.T) {
+	provider := openrouter.New("openai/gpt-4o-mini", openrouter.WithAPIKey("test"), openrouter.WithFallbackModels("openai/gpt-4o"))
+	payload, err := step.DryRun(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Say hi."}}}},
+	}, step.WithModel("anthropic/claude-sonnet-4.5"), step.WithReasoningEffort(step.ReasoningEffortLow))
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if payload.Model != "anthropic/claude-sonnet-4.5" {
+		t.Errorf("expected the override model, got %q", payload.Model)
+	}
+	if payload.Headers["x-anthropic-beta"] == "" {
+		t.Error("expected Claude headers for the override model")
+	}
+	var body struct {
+		Model     string         `json:"model"`
+		Models    []string       `json:"models"`
+		Reasoning map[string]any `json:"reasoning"`
+	}
+	if err := json.Unmarshal(payload.Body, &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if body.Model != "anthropic/claude-sonnet-4.5" || len(body.Models) != 2 || body.Models[0] != body.Model {
+		t.Errorf("expected the override to lead the fallback list, got %+v", body)
+	}
+	if body.Reasoning["effort"] != "low" {
+		t.Errorf("expected the step's reasoning effort, got %v", body.Reasoning)
+	}
+}
+
+func TestOpenRouter_SessionAffinity(t *testing.T) {
+	var sent []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"gen-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	upstreams := []string{"anthropic", "amazon-bedrock", "google-vertex"}
+	provider := openrouter.New("anthropic/claude-sonnet-4.5",
+		openrouter.WithAPIKey("test"),
+		openrouter.WithProviderSorting("latency"),
+		openrouter.WithSessionAffinity(upstreams...),
+		func(c *openrouter.Config) { c.BaseURL = server.URL },
+	)
+	req := step.StepRequest{Provider: provider, History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}}}
+
+	orders := map[string]string{}
+	distinct := map[string]bool{}
+	for _, session := range []string{"s1", "s1", "s2", "s3", "s4"} {
+		ctx := step.WithSessionID(context.Background(), session)
+		if _, err := step.Step(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		routing := sent[len(sent)-1]["provider"].(map[string]any)
+		order := fmt.Sprint(routing["order"])
+		if routing["sort"] != "latency" || len(routing["order"].([]any)) != 3 {
+			t.Errorf("session %s routing = %v", session, routing)
+		}
+		if prev, ok := orders[session]; ok && prev != order {
+			t.Errorf("session %s moved from %s to %s", session, prev, order)
+		}
+		orders[session] = order
+		distinct[order] = true
+
+		payload, err := step.DryRun(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct{ Provider map[string]any }
+		_ = json.Unmarshal(payload.Body, &body)
+		if fmt.Sprint(body.Provider["order"]) != order {
+			t.Errorf("DryRun order %v, sent %s", body.Provider["order"], order)
+		}
+	}
+	if len(distinct) < 2 {
+		t.Errorf("sessions all got the same order %v", orders)
+	}
+
+	if _, err := step.Step(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if routing := sent[len(sent)-1]["provider"].(map[string]any); routing["order"] != nil {
+		t.Errorf("request without a session got order %v", routing["order"])
+	}
+}
+
+func TestOpenRouter_Upstream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"gen-1","provider":"Amazon Bedrock","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := openrouter.New("anthropic/claude-sonnet-4.5", openrouter.WithAPIKey("test"), func(c *openrouter.Config) { c.BaseURL = server.URL })
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prov := result[0].(step.AssistantMessage).Provenance; prov == nil || prov.Upstream != "Amazon Bedrock" {
+		t.Errorf("provenance = %+v, want upstream Amazon Bedrock", prov)
+	}
+}
+
+func TestOpenRouter_ModelFamily(t *testing.T) {
+	req := step.StepRequest{History: []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}}}
+	claudeFeatures := func(t *testing.T, provider step.Provider) bool {
+		t.Helper()
+		req.Provider = provider
+		payload, err := step.DryRun(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		beta := payload.Headers["x-anthropic-beta"] != ""
+		if cached := strings.Contains(string(payload.Body), "cache_control"); cached != beta {
+			t.Errorf("beta header %v but cache_control %v", beta, cached)
+		}
+		return beta
+	}
+
+	if claudeFeatures(t, openrouter.New("acme/my-sonnet-finetune")) {
+		t.Error("undeclared alias treated as Claude")
+	}
+	if !claudeFeatures(t, openrouter.New("acme/my-sonnet-finetune", openrouter.WithModelFamily(openrouter.FamilyClaude, "acme/my-sonnet-finetune"))) {
+		t.Error("declared alias not treated as Claude")
+	}
+	if claudeFeatures(t, openrouter.New("acme/claude-lookalike", openrouter.WithModelFamily(openrouter.FamilyOther, "acme/claude-lookalike"))) {
+		t.Error("model declared FamilyOther treated as Claude")
+	}
+	matcher := openrouter.WithFamilyMatcher(func(model string) openrouter.ModelFamily {
+		if strings.HasPrefix(model, "acme/") {
+			return openrouter.FamilyClaude
+		}
+		return openrouter.DefaultModelFamily(model)
+	})
+	if !claudeFeatures(t, openrouter.New("acme/anything", matcher)) {
+		t.Error("family matcher ignored")
+	}
+}
+
+func TestOpenRouter_BetaFeatures(t *testing.T) {
+	beta := func(t *testing.T, opts ...openrouter.Option) string {
+		t.Helper()
+		payload, err := step.DryRun(context.Background(), step.StepRequest{Provider: openrouter.New("anthropic/claude-sonnet-4.5", opts...)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload.Headers["x-anthropic-beta"]
+	}
+	defaults := strings.Join(openrouter.DefaultAnthropicBetas(), ",")
+	if got := beta(t); got != defaults {
+		t.Errorf("default beta header %q", got)
+	}
+	if got, want := beta(t, openrouter.WithContext1M()), defaults+","+anthropic.BetaContext1M; got != want {
+		t.Errorf("beta header %q, want %q", got, want)
+	}
+	if got, want := beta(t, openrouter.WithBetaFeatures("custom-beta", anthropic.BetaInterleavedThinking)), defaults+",custom-beta"; got != want {
+		t.Errorf("beta header %q, want %q", got, want)
+	}
+	if got := beta(t, func(c *openrouter.Config) { c.AnthropicBetas = []string{} }); got != "" {
+		t.Errorf("beta header %q after opting out", got)
+	}
 }
@@ -0,0 +1,80 @@
+// Package pool spreads requests over interchangeable providers, such as the
+// same model on several accounts or regions, while keeping each session on
+// one of them. Switching upstreams mid-conversation throws away the prompt
+// cache, so a session only moves when its provider fails.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+// Member is one provider of a pool.
+type Member struct {
+	// Name identifies the member when ranking sessions; renaming a member
+	// moves its sessions elsewhere.
+	Name     string
+	Provider step.Provider
+}
+
+// Pool is a step.Provider over its members.
+type Pool struct {
+	members map[string]step.Provider
+	names   []string
+	next    atomic.Uint64
+}
+
+var _ step.Provider = (*Pool)(nil)
+
+// New returns a pool over members.
+func New(members ...Member) *Pool {
+	p := &Pool{members: make(map[string]step.Provider, len(members))}
+	for _, m := range members {
+		if _, dup := p.members[m.Name]; !dup {
+			p.names = append(p.names, m.Name)
+		}
+		p.members[m.Name] = m.Provider
+	}
+	return p
+}
+
+// Order returns the member names in the order Stream tries them for the
+// session of ctx: ranked by base.RankByAffinity of the step.SessionID, or,
+// without one, rotated by one member per call.
+func (p *Pool) Order(ctx context.Context) []string {
+	if session := step.SessionID(ctx); session != "" {
+		return base.RankByAffinity(session, p.names)
+	}
+	n := len(p.names)
+	if n == 0 {
+		return nil
+	}
+	start := int(p.next.Add(1)-1) % n
+	return append(p.names[start:n:n], p.names[:start]...)
+}
+
+// Stream streams from the first member in Order. When a member fails to
+// start the stream, the next is tried. Errors after the stream started are
+// returned as they are: a partial response cannot move to another member.
+func (p *Pool) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	if len(p.names) == 0 {
+		return nil, step.ErrNoProvider
+	}
+	var errs []error
+	for _, name := range p.Order(ctx) {
+		stream, err := p.members[name].Stream(ctx, req)
+		if err == nil {
+			return stream, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	return nil, fmt.Errorf("step/providers/pool: every member failed: %w", errors.Join(errs...))
+}
@@ -0,0 +1,80 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/providers/pool"
+)
+
+// member answers with its name, or fails with err.
+type member struct {
+	name string
+	err  error
+}
+
+func (m member) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return mock.New(mock.Text(m.name)).Stream(ctx, req)
+}
+
+func served(t *testing.T, ctx context.Context, p *pool.Pool) string {
+	t.Helper()
+	result, err := step.Step(ctx, step.StepRequest{Provider: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result[0].(step.AssistantMessage).Text()
+}
+
+func TestPool(t *testing.T) {
+	members := []pool.Member{{Name: "a", Provider: member{name: "a"}}, {Name: "b", Provider: member{name: "b"}}, {Name: "c", Provider: member{name: "c"}}}
+	p := pool.New(members...)
+
+	counts := map[string]int{}
+	for i := range 30 {
+		ctx := step.WithSessionID(context.Background(), fmt.Sprint("session-", i))
+		first := served(t, ctx, p)
+		for range 3 {
+			if got := served(t, ctx, p); got != first {
+				t.Fatalf("session %d moved from %s to %s", i, first, got)
+			}
+		}
+		counts[first]++
+	}
+	if len(counts) != 3 {
+		t.Errorf("sessions were not spread over the members: %v", counts)
+	}
+
+	// Without a session ID requests rotate.
+	seen := map[string]bool{}
+	for range 3 {
+		seen[served(t, context.Background(), p)] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("requests without a session did not rotate: %v", seen)
+	}
+}
+
+func TestPool_Failover(t *testing.T) {
+	ctx := step.WithSessionID(context.Background(), "s")
+	healthy := pool.New(pool.Member{Name: "a", Provider: member{name: "a"}}, pool.Member{Name: "b", Provider: member{name: "b"}})
+	order := healthy.Order(ctx)
+
+	down := errors.New("unavailable")
+	p := pool.New(pool.Member{Name: order[0], Provider: member{err: down}}, pool.Member{Name: order[1], Provider: member{name: order[1]}})
+	if got := served(t, ctx, p); got != order[1] {
+		t.Errorf("served by %s, want the failover %s", got, order[1])
+	}
+
+	p = pool.New(pool.Member{Name: "a", Provider: member{err: down}})
+	if _, err := step.Step(ctx, step.StepRequest{Provider: p}); !errors.Is(err, down) {
+		t.Errorf("got %v, want the member's error", err)
+	}
+}
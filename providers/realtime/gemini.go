@@ -0,0 +1,331 @@
+package realtime
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+const (
+	defaultGeminiURL   = "wss://generativelanguage.googleapis.com"
+	defaultGeminiModel = "gemini-live-2.5-flash-preview"
+	geminiPath         = "/ws/google.ai.generativelanguage.v1beta.GenerativeService.BidiGenerateContent"
+	// geminiInputAudio is assumed for audio sent without a mime type.
+	geminiInputAudio = "audio/pcm;rate=16000"
+)
+
+// Gemini returns a Dialer for the Gemini Live API. It reads GEMINI_API_KEY
+// (or GOOGLE_API_KEY) and GEMINI_BASE_URL from environment if not explicitly
+// set.
+func Gemini(opts ...Option) Dialer {
+	cfg := base.Config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	base.ApplyEnvDefaults(&cfg, "GEMINI_API_KEY", "GEMINI_BASE_URL")
+	if cfg.APIKey == "" {
+		base.ApplyEnvDefaults(&cfg, "GOOGLE_API_KEY", "")
+	}
+	return &geminiDialer{cfg: cfg}
+}
+
+type geminiDialer struct{ cfg base.Config }
+
+func (d *geminiDialer) Dial(ctx context.Context, cfg SessionConfig) (*Session, error) {
+	model := cmp.Or(cfg.Model, defaultGeminiModel)
+	endpoint := strings.TrimRight(wsURL(cmp.Or(d.cfg.BaseURL, defaultGeminiURL)), "/") + geminiPath
+	header := map[string]string{"x-goog-api-key": d.cfg.APIKey}
+	for k, v := range d.cfg.ExtraHeaders {
+		header[k] = v
+	}
+	return dial(ctx, endpoint, header, cfg, &geminiProtocol{model: model, keepAudio: cfg.KeepAudio, manual: cfg.ManualTurns})
+}
+
+type geminiProtocol struct {
+	model     string
+	keepAudio bool
+	manual    bool
+
+	// speaking is set by sends between activityStart and activityEnd.
+	speaking bool
+
+	// The rest is response state, used by handle only.
+	resp  response
+	input strings.Builder
+	usage *step.Usage
+}
+
+func (p *geminiProtocol) setup(cfg SessionConfig) ([]any, error) {
+	gen := map[string]any{"responseModalities": []string{"AUDIO"}}
+	setup := map[string]any{"model": "models/" + p.model, "generationConfig": gen}
+	if cfg.TextOnly {
+		gen["responseModalities"] = []string{"TEXT"}
+	} else {
+		setup["outputAudioTranscription"] = map[string]any{}
+		if cfg.Voice != "" {
+			gen["speechConfig"] = map[string]any{"voiceConfig": map[string]any{"prebuiltVoiceConfig": map[string]any{"voiceName": cfg.Voice}}}
+		}
+	}
+	setup["inputAudioTranscription"] = map[string]any{}
+	if cfg.System != "" {
+		setup["systemInstruction"] = map[string]any{"parts": []map[string]any{{"text": cfg.System}}}
+	}
+	if len(cfg.Tools) > 0 {
+		decls := make([]map[string]any, 0, len(cfg.Tools))
+		for _, t := range cfg.Tools {
+			spec := t.Spec()
+			decls = append(decls, map[string]any{"name": spec.Name, "description": spec.Description, "parameters": spec.Parameters})
+		}
+		setup["tools"] = []map[string]any{{"functionDeclarations": decls}}
+	}
+	if cfg.ManualTurns {
+		setup["realtimeInputConfig"] = map[string]any{"automaticActivityDetection": map[string]any{"disabled": true}}
+	}
+	return []any{map[string]any{"setup": setup}}, nil
+}
+
+func (p *geminiProtocol) ready(data []byte) (bool, error) {
+	var msg struct {
+		SetupComplete *struct{} `json:"setupComplete"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return false, err
+	}
+	return msg.SetupComplete != nil, nil
+}
+
+func (p *geminiProtocol) audio(a step.Audio) ([]any, error) {
+	var out []any
+	if p.manual && !p.speaking {
+		out = append(out, map[string]any{"realtimeInput": map[string]any{"activityStart": map[string]any{}}})
+		p.speaking = true
+	}
+	blob := map[string]any{"mimeType": cmp.Or(a.MimeType, geminiInputAudio), "data": base64.StdEncoding.EncodeToString(a.Data)}
+	return append(out, map[string]any{"realtimeInput": map[string]any{"audio": blob}}), nil
+}
+
+// messages sends tool results on their own as a toolResponse, which lets the
+// model continue; anything else, including tool results within history, is
+// sent as conversation turns.
+func (p *geminiProtocol) messages(msgs []step.Message) []any {
+	onlyResults := true
+	for _, msg := range msgs {
+		if _, ok := msg.(step.ToolResultMessage); !ok {
+			onlyResults = false
+		}
+	}
+	if onlyResults {
+		responses := make([]map[string]any, 0, len(msgs))
+		for _, msg := range msgs {
+			responses = append(responses, functionResponse(msg.(step.ToolResultMessage)))
+		}
+		return []any{map[string]any{"toolResponse": map[string]any{"functionResponses": responses}}}
+	}
+
+	var turns []map[string]any
+	for _, msg := range msgs {
+		var role string
+		var parts []map[string]any
+		switch m := msg.(type) {
+		case step.UserMessage:
+			role = "user"
+			for _, part := range m.Parts {
+				switch v := part.(type) {
+				case step.TextPart:
+					parts = append(parts, map[string]any{"text": v.Text})
+				case step.AudioPart:
+					parts = append(parts, map[string]any{"text": v.Transcript})
+				case step.ImagePart:
+					parts = append(parts, map[string]any{"inlineData": map[string]any{"mimeType": v.MimeType, "data": v.DataB64}})
+				}
+			}
+		case step.AssistantMessage:
+			role = "model"
+			if text := transcriptText(m.Parts); text != "" {
+				parts = append(parts, map[string]any{"text": text})
+			}
+			for _, call := range toolCalls(m) {
+				args := json.RawMessage(call.ArgsJSON)
+				if len(args) == 0 {
+					args = json.RawMessage("{}")
+				}
+				parts = append(parts, map[string]any{"functionCall": map[string]any{"id": call.CallID, "name": call.Name, "args": args}})
+			}
+		case step.ToolResultMessage:
+			role = "user"
+			parts = append(parts, map[string]any{"functionResponse": functionResponse(m)})
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		// Consecutive messages of one role form a single turn.
+		if n := len(turns); n > 0 && turns[n-1]["role"] == role {
+			turns[n-1]["parts"] = append(turns[n-1]["parts"].([]map[string]any), parts...)
+			continue
+		}
+		turns = append(turns, map[string]any{"role": role, "parts": parts})
+	}
+	if len(turns) == 0 {
+		return nil
+	}
+	return []any{map[string]any{"clientContent": map[string]any{"turns": turns, "turnComplete": false}}}
+}
+
+func functionResponse(m step.ToolResultMessage) map[string]any {
+	key := "output"
+	if m.IsError {
+		key = "error"
+	}
+	return map[string]any{"id": m.CallID, "name": m.Name, "response": map[string]any{key: toolOutput(m)}}
+}
+
+func (p *geminiProtocol) respond(afterTools bool) []any {
+	switch {
+	case afterTools:
+		// The model continues on its own after a toolResponse.
+		return nil
+	case p.manual && p.speaking:
+		p.speaking = false
+		return []any{map[string]any{"realtimeInput": map[string]any{"activityEnd": map[string]any{}}}}
+	default:
+		return []any{map[string]any{"clientContent": map[string]any{"turnComplete": true}}}
+	}
+}
+
+// geminiMessage is a server message; only the fields used here are decoded.
+type geminiMessage struct {
+	ServerContent *struct {
+		ModelTurn *struct {
+			Parts []struct {
+				Text       string `json:"text"`
+				Thought    bool   `json:"thought"`
+				InlineData *struct {
+					MimeType string `json:"mimeType"`
+					Data     string `json:"data"`
+				} `json:"inlineData"`
+			} `json:"parts"`
+		} `json:"modelTurn"`
+		TurnComplete       bool `json:"turnComplete"`
+		Interrupted        bool `json:"interrupted"`
+		InputTranscription *struct {
+			Text string `json:"text"`
+		} `json:"inputTranscription"`
+		OutputTranscription *struct {
+			Text string `json:"text"`
+		} `json:"outputTranscription"`
+	} `json:"serverContent"`
+	ToolCall *struct {
+		FunctionCalls []struct {
+			ID   string          `json:"id"`
+			Name string          `json:"name"`
+			Args json.RawMessage `json:"args"`
+		} `json:"functionCalls"`
+	} `json:"toolCall"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		ResponseTokenCount      int `json:"responseTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func (p *geminiProtocol) handle(data []byte) ([]Event, error) {
+	var msg geminiMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("step/providers/realtime: decode message: %w", err)
+	}
+	if u := msg.UsageMetadata; u != nil {
+		p.usage = &step.Usage{
+			InputTokens:      u.PromptTokenCount,
+			OutputTokens:     u.ResponseTokenCount,
+			CachedReadTokens: u.CachedContentTokenCount,
+			TotalTokens:      u.PromptTokenCount + u.ResponseTokenCount,
+		}
+	}
+	var events []Event
+	if sc := msg.ServerContent; sc != nil {
+		if t := sc.InputTranscription; t != nil {
+			p.input.WriteString(t.Text)
+		}
+		if sc.ModelTurn != nil || sc.OutputTranscription != nil {
+			events = p.flushInput(events)
+		}
+		if sc.ModelTurn != nil {
+			for _, part := range sc.ModelTurn.Parts {
+				switch {
+				case part.InlineData != nil:
+					audio, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+					if err != nil {
+						return nil, fmt.Errorf("step/providers/realtime: decode audio: %w", err)
+					}
+					p.resp.addAudio(part.InlineData.MimeType, audio)
+					events = append(events, delta(step.AudioDelta{MimeType: part.InlineData.MimeType, Data: audio})...)
+				case part.Text != "" && !part.Thought:
+					p.resp.start()
+					p.resp.text.WriteString(part.Text)
+					events = append(events, delta(step.TextDelta{Delta: part.Text})...)
+				}
+			}
+		}
+		if t := sc.OutputTranscription; t != nil && t.Text != "" {
+			p.resp.start()
+			p.resp.transcript.WriteString(t.Text)
+			events = append(events, delta(step.AudioDelta{Transcript: t.Text})...)
+		}
+		if sc.Interrupted {
+			events = p.flushInput(events)
+			events = append(events, Event{Interrupted: true})
+			events = p.finish(events, step.StopAborted)
+		}
+		if sc.TurnComplete {
+			events = p.flushInput(events)
+			events = p.finish(events, step.StopStop)
+		}
+	}
+	if tc := msg.ToolCall; tc != nil {
+		events = p.flushInput(events)
+		p.resp.start()
+		for _, fc := range tc.FunctionCalls {
+			args := fc.Args
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			p.resp.calls = append(p.resp.calls, step.ToolCallPart{CallID: fc.ID, Name: fc.Name, ArgsJSON: args})
+			events = append(events, delta(step.ToolCallDelta{CallID: fc.ID, Name: fc.Name, ArgsDelta: string(args)})...)
+		}
+		events = p.finish(events, step.StopToolUse)
+	}
+	return events, nil
+}
+
+// flushInput emits the user's transcribed speech, once the model starts
+// responding to it.
+func (p *geminiProtocol) flushInput(events []Event) []Event {
+	if p.input.Len() == 0 {
+		return events
+	}
+	msg := step.UserMessage{
+		ID:        step.NewMessageID(),
+		Parts:     []step.Part{step.AudioPart{MimeType: geminiInputAudio, Transcript: strings.TrimSpace(p.input.String())}},
+		Timestamp: time.Now().UnixMilli(),
+	}
+	p.input.Reset()
+	return append(events, Event{Input: &msg})
+}
+
+// finish emits the final message of a response that produced output; a turn
+// completing after an interruption has nothing left to emit.
+func (p *geminiProtocol) finish(events []Event, stop step.StopReason) []Event {
+	if p.resp.startedAt.IsZero() {
+		return events
+	}
+	msg := p.resp.message(p.keepAudio, p.usage, stop, step.Provenance{Provider: "google", Model: p.model})
+	p.usage = nil
+	return append(events, Event{Update: step.ProviderMessageUpdate{Message: msg}})
+}
@@ -0,0 +1,303 @@
+package realtime
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+const (
+	defaultOpenAIURL   = "wss://api.openai.com/v1"
+	defaultOpenAIModel = "gpt-realtime"
+	// openAIAudio is the only audio format used here: 24 kHz 16-bit mono PCM.
+	openAIAudio = "audio/pcm;rate=24000"
+)
+
+// OpenAI returns a Dialer for the OpenAI Realtime API. It reads
+// OPENAI_API_KEY and OPENAI_BASE_URL from environment if not explicitly set.
+func OpenAI(opts ...Option) Dialer {
+	cfg := base.Config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	base.ApplyEnvDefaults(&cfg, "OPENAI_API_KEY", "OPENAI_BASE_URL")
+	return &openAIDialer{cfg: cfg}
+}
+
+type openAIDialer struct{ cfg base.Config }
+
+func (d *openAIDialer) Dial(ctx context.Context, cfg SessionConfig) (*Session, error) {
+	model := cmp.Or(cfg.Model, defaultOpenAIModel)
+	endpoint := strings.TrimRight(wsURL(cmp.Or(d.cfg.BaseURL, defaultOpenAIURL)), "/") + "/realtime?model=" + url.QueryEscape(model)
+	header := map[string]string{}
+	if d.cfg.APIKey != "" {
+		header["Authorization"] = "Bearer " + d.cfg.APIKey
+	}
+	for k, v := range d.cfg.ExtraHeaders {
+		header[k] = v
+	}
+	return dial(ctx, endpoint, header, cfg, &openAIProtocol{model: model, keepAudio: cfg.KeepAudio, manual: cfg.ManualTurns})
+}
+
+type openAIProtocol struct {
+	model     string
+	keepAudio bool
+	manual    bool
+
+	// buffered is set by sends when audio awaits a manual commit.
+	buffered bool
+
+	// The rest is response state, used by handle only.
+	resp       response
+	active     bool
+	responseID string
+	calls      map[string]step.ToolCallPart // by item ID, until the arguments are done
+}
+
+func (p *openAIProtocol) setup(cfg SessionConfig) ([]any, error) {
+	modalities := []string{"audio"}
+	if cfg.TextOnly {
+		modalities = []string{"text"}
+	}
+	format := map[string]any{"type": "audio/pcm", "rate": 24000}
+	var turnDetection any = map[string]any{"type": "server_vad"}
+	if cfg.ManualTurns {
+		turnDetection = nil
+	}
+	session := map[string]any{
+		"type":              "realtime",
+		"output_modalities": modalities,
+		"audio": map[string]any{
+			"input": map[string]any{
+				"format":         format,
+				"transcription":  map[string]any{"model": "gpt-4o-mini-transcribe"},
+				"turn_detection": turnDetection,
+			},
+			"output": map[string]any{"format": format, "voice": cmp.Or(cfg.Voice, "alloy")},
+		},
+	}
+	if cfg.System != "" {
+		session["instructions"] = cfg.System
+	}
+	if len(cfg.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(cfg.Tools))
+		for _, t := range cfg.Tools {
+			spec := t.Spec()
+			tools = append(tools, map[string]any{"type": "function", "name": spec.Name, "description": spec.Description, "parameters": spec.Parameters})
+		}
+		session["tools"] = tools
+	}
+	return []any{map[string]any{"type": "session.update", "session": session}}, nil
+}
+
+func (p *openAIProtocol) ready(data []byte) (bool, error) {
+	var ev openAIEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return false, err
+	}
+	switch ev.Type {
+	case "session.updated":
+		return true, nil
+	case "error":
+		return false, ev.err()
+	}
+	return false, nil
+}
+
+func (p *openAIProtocol) audio(a step.Audio) ([]any, error) {
+	if mt, params, _ := mime.ParseMediaType(a.MimeType); a.MimeType != "" && (mt != "audio/pcm" && mt != "audio/l16" || params["rate"] != "" && params["rate"] != "24000") {
+		return nil, fmt.Errorf("step/providers/realtime: OpenAI takes %s audio, got %s", openAIAudio, a.MimeType)
+	}
+	p.buffered = true
+	return []any{map[string]any{"type": "input_audio_buffer.append", "audio": base64.StdEncoding.EncodeToString(a.Data)}}, nil
+}
+
+func (p *openAIProtocol) messages(msgs []step.Message) []any {
+	var out []any
+	add := func(item map[string]any) {
+		out = append(out, map[string]any{"type": "conversation.item.create", "item": item})
+	}
+	for _, msg := range msgs {
+		switch m := msg.(type) {
+		case step.UserMessage:
+			var content []map[string]any
+			for _, part := range m.Parts {
+				switch v := part.(type) {
+				case step.TextPart:
+					content = append(content, map[string]any{"type": "input_text", "text": v.Text})
+				case step.AudioPart:
+					content = append(content, map[string]any{"type": "input_text", "text": v.Transcript})
+				case step.ImagePart:
+					content = append(content, map[string]any{"type": "input_image", "image_url": "data:" + v.MimeType + ";base64," + v.DataB64})
+				}
+			}
+			if len(content) > 0 {
+				add(map[string]any{"type": "message", "role": "user", "content": content})
+			}
+		case step.AssistantMessage:
+			if text := transcriptText(m.Parts); text != "" {
+				add(map[string]any{"type": "message", "role": "assistant", "content": []map[string]any{{"type": "output_text", "text": text}}})
+			}
+			for _, call := range toolCalls(m) {
+				add(map[string]any{"type": "function_call", "call_id": call.CallID, "name": call.Name, "arguments": string(call.ArgsJSON)})
+			}
+		case step.ToolResultMessage:
+			add(map[string]any{"type": "function_call_output", "call_id": m.CallID, "output": toolOutput(m)})
+		}
+	}
+	return out
+}
+
+func (p *openAIProtocol) respond(afterTools bool) []any {
+	var out []any
+	if p.manual && p.buffered && !afterTools {
+		out = append(out, map[string]any{"type": "input_audio_buffer.commit"})
+		p.buffered = false
+	}
+	return append(out, map[string]any{"type": "response.create"})
+}
+
+// openAIEvent is a server event; only the fields used here are decoded.
+type openAIEvent struct {
+	Type       string `json:"type"`
+	Delta      string `json:"delta"`
+	ItemID     string `json:"item_id"`
+	Arguments  string `json:"arguments"`
+	Transcript string `json:"transcript"`
+	Item       struct {
+		ID     string `json:"id"`
+		Type   string `json:"type"`
+		CallID string `json:"call_id"`
+		Name   string `json:"name"`
+	} `json:"item"`
+	Response struct {
+		ID            string `json:"id"`
+		Status        string `json:"status"`
+		StatusDetails struct {
+			Reason string `json:"reason"`
+		} `json:"status_details"`
+		Usage *struct {
+			InputTokens       int `json:"input_tokens"`
+			OutputTokens      int `json:"output_tokens"`
+			TotalTokens       int `json:"total_tokens"`
+			InputTokenDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"input_token_details"`
+		} `json:"usage"`
+	} `json:"response"`
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (ev *openAIEvent) err() error {
+	return fmt.Errorf("step/providers/realtime: %s: %s", cmp.Or(ev.Error.Code, ev.Error.Type), ev.Error.Message)
+}
+
+func delta(d step.MessageDelta) []Event {
+	return []Event{{Update: step.ProviderDeltaUpdate{Delta: d}}}
+}
+
+// handle accepts both the GA and the beta event names.
+func (p *openAIProtocol) handle(data []byte) ([]Event, error) {
+	var ev openAIEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return nil, fmt.Errorf("step/providers/realtime: decode event: %w", err)
+	}
+	switch ev.Type {
+	case "error":
+		return nil, ev.err()
+	case "response.created":
+		p.active = true
+		p.responseID = ev.Response.ID
+		p.resp.start()
+	case "input_audio_buffer.speech_started":
+		if p.active {
+			return []Event{{Interrupted: true}}, nil
+		}
+	case "conversation.item.input_audio_transcription.completed":
+		msg := step.UserMessage{
+			ID:        step.NewMessageID(),
+			Parts:     []step.Part{step.AudioPart{MimeType: openAIAudio, Transcript: ev.Transcript}},
+			Timestamp: time.Now().UnixMilli(),
+		}
+		return []Event{{Input: &msg}}, nil
+	case "response.output_audio.delta", "response.audio.delta":
+		audio, err := base64.StdEncoding.DecodeString(ev.Delta)
+		if err != nil {
+			return nil, fmt.Errorf("step/providers/realtime: decode audio: %w", err)
+		}
+		p.resp.addAudio(openAIAudio, audio)
+		return delta(step.AudioDelta{MimeType: openAIAudio, Data: audio}), nil
+	case "response.output_audio_transcript.delta", "response.audio_transcript.delta":
+		p.resp.start()
+		p.resp.transcript.WriteString(ev.Delta)
+		return delta(step.AudioDelta{Transcript: ev.Delta}), nil
+	case "response.output_text.delta", "response.text.delta":
+		p.resp.start()
+		p.resp.text.WriteString(ev.Delta)
+		return delta(step.TextDelta{Delta: ev.Delta}), nil
+	case "response.output_item.added":
+		if ev.Item.Type != "function_call" {
+			break
+		}
+		if p.calls == nil {
+			p.calls = map[string]step.ToolCallPart{}
+		}
+		p.calls[ev.Item.ID] = step.ToolCallPart{CallID: ev.Item.CallID, Name: ev.Item.Name}
+		return delta(step.ToolCallDelta{CallID: ev.Item.CallID, Name: ev.Item.Name}), nil
+	case "response.function_call_arguments.delta":
+		if call, ok := p.calls[ev.ItemID]; ok {
+			return delta(step.ToolCallDelta{CallID: call.CallID, Name: call.Name, ArgsDelta: ev.Delta}), nil
+		}
+	case "response.function_call_arguments.done":
+		if call, ok := p.calls[ev.ItemID]; ok {
+			call.ArgsJSON = json.RawMessage(cmp.Or(ev.Arguments, "{}"))
+			p.resp.calls = append(p.resp.calls, call)
+			delete(p.calls, ev.ItemID)
+		}
+	case "response.done":
+		p.active = false
+		r := ev.Response
+		var usage *step.Usage
+		if u := r.Usage; u != nil {
+			usage = &step.Usage{
+				InputTokens:      u.InputTokens,
+				OutputTokens:     u.OutputTokens,
+				CachedReadTokens: u.InputTokenDetails.CachedTokens,
+				TotalTokens:      u.TotalTokens,
+			}
+		}
+		var stop step.StopReason
+		switch r.Status {
+		case "cancelled":
+			stop = step.StopAborted
+		case "failed":
+			stop = step.StopError
+		case "incomplete":
+			stop = step.StopLength
+			if r.StatusDetails.Reason == "content_filter" {
+				stop = step.StopContentFilter
+			}
+		}
+		msg := p.resp.message(p.keepAudio, usage, stop, step.Provenance{
+			Provider:     "openai",
+			Model:        p.model,
+			RequestID:    cmp.Or(r.ID, p.responseID),
+			FinishReason: r.Status,
+		})
+		return []Event{{Update: step.ProviderMessageUpdate{Message: msg}}}, nil
+	}
+	return nil, nil
+}
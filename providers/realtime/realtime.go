@@ -0,0 +1,422 @@
+// Package realtime provides bidirectional voice sessions over the OpenAI
+// Realtime and Gemini Live websocket APIs. Their events are adapted onto
+// step's model: responses stream as AudioDelta, TextDelta and ToolCallDelta
+// updates and end with an AssistantMessage, so voice agents reuse the same
+// tools and history types as text agents.
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/internal/websocket"
+	"github.com/inspirepan/step/providers/base"
+)
+
+// Option is a functional option for a Dialer.
+type Option func(*base.Config)
+
+// WithAPIKey sets the API key.
+func WithAPIKey(key string) Option {
+	return func(c *base.Config) { c.APIKey = key }
+}
+
+// WithBaseURL sets a custom base URL, as for the provider's HTTP API, e.g.
+// https://api.openai.com/v1. http(s) URLs are converted to ws(s).
+func WithBaseURL(url string) Option {
+	return func(c *base.Config) { c.BaseURL = url }
+}
+
+// WithExtraHeader adds a custom header to the handshake.
+func WithExtraHeader(key, value string) Option {
+	return func(c *base.Config) {
+		if c.ExtraHeaders == nil {
+			c.ExtraHeaders = make(map[string]string)
+		}
+		c.ExtraHeaders[key] = value
+	}
+}
+
+// SessionConfig configures a session.
+type SessionConfig struct {
+	// Model defaults to the provider's realtime model.
+	Model string
+	// System is the system instruction.
+	System string
+	// Tools are offered to the model; RunTools executes them.
+	Tools []step.Tool
+	// History seeds the conversation. Audio parts are sent as their transcripts.
+	History []step.Message
+	// Voice is a provider voice name; empty uses the provider's default.
+	Voice string
+	// TextOnly makes the model respond in text instead of speech.
+	TextOnly bool
+	// ManualTurns turns off voice activity detection: the model responds
+	// only when Respond is called, which also ends the user's audio turn.
+	ManualTurns bool
+	// KeepAudio keeps the model's audio in the AudioParts of final messages.
+	// By default they carry only the transcript, since the audio is large and
+	// was already streamed as AudioDeltas.
+	KeepAudio bool
+}
+
+// Dialer opens sessions with one provider.
+type Dialer interface {
+	Dial(ctx context.Context, cfg SessionConfig) (*Session, error)
+}
+
+// Event is one update from a session. Exactly one field is set.
+type Event struct {
+	// Update is a step.ProviderDeltaUpdate while the model responds and a
+	// step.ProviderMessageUpdate when a response ends.
+	Update step.ProviderUpdate
+	// Input is the user's transcribed speech, once the provider has it.
+	Input *step.UserMessage
+	// Interrupted reports that the user started speaking over the response;
+	// stop playing its audio.
+	Interrupted bool
+}
+
+// protocol adapts one provider's wire events. Its messages are marshaled to
+// JSON and sent as text frames.
+type protocol interface {
+	// setup returns the messages configuring the session.
+	setup(cfg SessionConfig) ([]any, error)
+	// ready reports whether data acknowledges the setup.
+	ready(data []byte) (bool, error)
+	audio(a step.Audio) ([]any, error)
+	messages(msgs []step.Message) []any
+	// respond asks for a response; afterTools is set when answering tool calls.
+	respond(afterTools bool) []any
+	handle(data []byte) ([]Event, error)
+}
+
+// Session is a live conversation. Next must be called from one goroutine;
+// the send methods are safe to call concurrently with it.
+type Session struct {
+	conn  *websocket.Conn
+	cfg   SessionConfig
+	proto protocol
+
+	smu sync.Mutex // serializes protocol state changes from sends
+
+	frames  chan []byte
+	readErr error
+	pending []Event
+
+	hmu     sync.Mutex
+	history []step.Message
+}
+
+func dial(ctx context.Context, url string, header map[string]string, cfg SessionConfig, proto protocol) (*Session, error) {
+	h := make(map[string][]string, len(header))
+	for k, v := range header {
+		h[k] = []string{v}
+	}
+	conn, err := websocket.Dial(ctx, url, h)
+	if err != nil {
+		return nil, fmt.Errorf("step/providers/realtime: %w", err)
+	}
+	// Reads do not take a context; closing the connection ends a stuck setup.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	s := &Session{conn: conn, cfg: cfg, proto: proto, frames: make(chan []byte, 64)}
+	setup, err := proto.setup(cfg)
+	if err == nil {
+		err = s.write(setup)
+	}
+	for err == nil {
+		var data []byte
+		if data, err = conn.ReadMessage(); err != nil {
+			break
+		}
+		var ok bool
+		if ok, err = proto.ready(data); ok {
+			break
+		}
+	}
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("step/providers/realtime: setup: %w", err)
+	}
+	go s.read()
+	if len(cfg.History) > 0 {
+		if err := s.Send(ctx, cfg.History...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Session) read() {
+	defer close(s.frames)
+	for {
+		data, err := s.conn.ReadMessage()
+		if err != nil {
+			s.readErr = err
+			return
+		}
+		s.frames <- data
+	}
+}
+
+func (s *Session) write(msgs []any) error {
+	for _, m := range msgs {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := s.conn.WriteMessage(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendAudio streams a chunk of the user's speech. OpenAI takes 24 kHz 16-bit
+// mono PCM ("audio/pcm;rate=24000"); Gemini takes 16-bit PCM at any rate.
+func (s *Session) SendAudio(ctx context.Context, audio step.Audio) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.smu.Lock()
+	defer s.smu.Unlock()
+	msgs, err := s.proto.audio(audio)
+	if err != nil {
+		return err
+	}
+	return s.write(msgs)
+}
+
+// Send adds messages to the conversation, e.g. typed user input or tool
+// results, without asking for a response.
+func (s *Session) Send(ctx context.Context, msgs ...step.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.smu.Lock()
+	defer s.smu.Unlock()
+	if err := s.write(s.proto.messages(msgs)); err != nil {
+		return err
+	}
+	s.hmu.Lock()
+	s.history = append(s.history, msgs...)
+	s.hmu.Unlock()
+	return nil
+}
+
+// Respond asks the model to respond now. With voice activity detection the
+// model responds on its own when the user stops speaking.
+func (s *Session) Respond(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.smu.Lock()
+	defer s.smu.Unlock()
+	return s.write(s.proto.respond(false))
+}
+
+// RunTools executes the tool calls of msg with the session's tools, sends the
+// results and lets the model continue. It returns the result messages.
+func (s *Session) RunTools(ctx context.Context, msg step.AssistantMessage) ([]step.ToolResultMessage, error) {
+	calls := toolCalls(msg)
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	results := make([]step.ToolResultMessage, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := step.ExecuteTool(ctx, call, s.cfg.Tools)
+			results[i] = step.ToolResultMessage{
+				ID:        step.NewMessageID(),
+				ParentID:  msg.ID,
+				CallID:    res.CallID,
+				Name:      res.Name,
+				IsError:   res.IsError,
+				Parts:     res.Parts,
+				Timestamp: time.Now().UnixMilli(),
+				Details:   res.Details,
+			}
+		}()
+	}
+	wg.Wait()
+
+	msgs := make([]step.Message, len(results))
+	for i, r := range results {
+		msgs[i] = r
+	}
+	if err := s.Send(ctx, msgs...); err != nil {
+		return results, err
+	}
+	s.smu.Lock()
+	defer s.smu.Unlock()
+	return results, s.write(s.proto.respond(true))
+}
+
+// Next returns the next event. It returns io.EOF when the provider closes
+// the session. Provider error events are returned as errors; the session
+// stays usable after them.
+func (s *Session) Next(ctx context.Context) (Event, error) {
+	for len(s.pending) == 0 {
+		select {
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		case data, ok := <-s.frames:
+			if !ok {
+				if errors.Is(s.readErr, websocket.ErrClosed) {
+					return Event{}, io.EOF
+				}
+				return Event{}, s.readErr
+			}
+			events, err := s.proto.handle(data)
+			if err != nil {
+				return Event{}, err
+			}
+			s.pending = append(s.pending, events...)
+		}
+	}
+	ev := s.pending[0]
+	s.pending = s.pending[1:]
+	s.hmu.Lock()
+	switch {
+	case ev.Input != nil:
+		s.history = append(s.history, *ev.Input)
+	case ev.Update != nil:
+		if up, ok := ev.Update.(step.ProviderMessageUpdate); ok {
+			s.history = append(s.history, up.Message)
+		}
+	}
+	s.hmu.Unlock()
+	return ev, nil
+}
+
+// History returns the conversation so far: SessionConfig.History, sent
+// messages, transcribed user speech and the model's responses returned by
+// Next.
+func (s *Session) History() []step.Message {
+	s.hmu.Lock()
+	defer s.hmu.Unlock()
+	return append([]step.Message(nil), s.history...)
+}
+
+// Close ends the session.
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+// response accumulates the output of one model response.
+type response struct {
+	text       strings.Builder
+	transcript strings.Builder
+	audioMime  string
+	audio      []byte
+	calls      []step.ToolCallPart
+	startedAt  time.Time
+}
+
+func (r *response) start() {
+	if r.startedAt.IsZero() {
+		r.startedAt = time.Now()
+	}
+}
+
+func (r *response) addAudio(mimeType string, data []byte) {
+	r.start()
+	r.audioMime = mimeType
+	r.audio = append(r.audio, data...)
+}
+
+// message builds the final message and resets r.
+func (r *response) message(keepAudio bool, usage *step.Usage, stop step.StopReason, prov step.Provenance) step.AssistantMessage {
+	var parts []step.Part
+	if len(r.audio) > 0 || r.transcript.Len() > 0 {
+		audio := step.AudioPart{MimeType: r.audioMime, Transcript: r.transcript.String()}
+		if keepAudio {
+			audio.DataB64 = base64.StdEncoding.EncodeToString(r.audio)
+		}
+		parts = append(parts, audio)
+	}
+	if r.text.Len() > 0 {
+		parts = append(parts, step.TextPart{Text: r.text.String()})
+	}
+	for _, c := range r.calls {
+		parts = append(parts, c)
+	}
+	if !r.startedAt.IsZero() {
+		prov.LatencyMs = time.Since(r.startedAt).Milliseconds()
+	}
+	if stop == "" {
+		stop = step.StopStop
+		if len(r.calls) > 0 {
+			stop = step.StopToolUse
+		}
+	}
+	*r = response{}
+	return step.AssistantMessage{
+		ID:         step.NewMessageID(),
+		Parts:      parts,
+		Timestamp:  time.Now().UnixMilli(),
+		Usage:      usage,
+		StopReason: stop,
+		Provenance: &prov,
+	}
+}
+
+// transcriptText returns the text of a message for providers that take
+// history as text, using transcripts for audio.
+func transcriptText(parts []step.Part) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		switch v := p.(type) {
+		case step.TextPart:
+			sb.WriteString(v.Text)
+		case step.AudioPart:
+			sb.WriteString(v.Transcript)
+		}
+	}
+	return sb.String()
+}
+
+// toolOutput returns the text of a tool result.
+func toolOutput(m step.ToolResultMessage) string {
+	out := transcriptText(m.Parts)
+	if out == "" {
+		out = "<system-reminder>Tool ran without output or errors</system-reminder>"
+	}
+	return out
+}
+
+func toolCalls(msg step.AssistantMessage) []step.ToolCallPart {
+	var calls []step.ToolCallPart
+	for _, p := range msg.Parts {
+		if c, ok := p.(step.ToolCallPart); ok {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func wsURL(url string) string {
+	switch {
+	case strings.HasPrefix(url, "https://"):
+		return "wss://" + strings.TrimPrefix(url, "https://")
+	case strings.HasPrefix(url, "http://"):
+		return "ws://" + strings.TrimPrefix(url, "http://")
+	}
+	return url
+}
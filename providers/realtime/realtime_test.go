@@ -0,0 +1,273 @@
+package realtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/internal/websocket"
+	"github.com/inspirepan/step/providers/realtime"
+)
+
+// fakeServer accepts one websocket session and plays the server side with
+// serve; recv returns the next client message, or nil once the client is gone.
+func fakeServer(t *testing.T, serve func(conn *websocket.Conn, recv func() map[string]any)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn, func() map[string]any {
+			data, err := conn.ReadMessage()
+			if err != nil {
+				return nil
+			}
+			var msg map[string]any
+			_ = json.Unmarshal(data, &msg)
+			return msg
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func send(conn *websocket.Conn, events ...string) {
+	for _, ev := range events {
+		_ = conn.WriteMessage([]byte(ev))
+	}
+}
+
+// collect reads events until a final message.
+func collect(t *testing.T, s *realtime.Session) (deltas []step.MessageDelta, inputs []step.UserMessage, final step.AssistantMessage) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		ev, err := s.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		switch {
+		case ev.Input != nil:
+			inputs = append(inputs, *ev.Input)
+		case ev.Update != nil:
+			switch up := ev.Update.(type) {
+			case step.ProviderDeltaUpdate:
+				deltas = append(deltas, up.Delta)
+			case step.ProviderMessageUpdate:
+				return deltas, inputs, up.Message
+			}
+		}
+	}
+}
+
+type weatherTool struct{}
+
+func (weatherTool) Spec() step.ToolSpec {
+	return step.ToolSpec{Name: "weather", Description: "Get the weather", Parameters: map[string]any{"type": "object"}}
+}
+
+func (weatherTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "sunny"}}}, nil
+}
+
+func TestOpenAI(t *testing.T) {
+	received := make(chan map[string]any, 16)
+	server := fakeServer(t, func(conn *websocket.Conn, recv func() map[string]any) {
+		if msg := recv(); msg["type"] != "session.update" {
+			t.Errorf("first message = %v", msg)
+		}
+		send(conn, `{"type":"session.created"}`, `{"type":"session.updated"}`)
+		received <- recv() // audio
+		send(conn,
+			`{"type":"conversation.item.input_audio_transcription.completed","transcript":"What's the weather?"}`,
+			`{"type":"response.created","response":{"id":"resp_1"}}`,
+			`{"type":"response.output_audio.delta","delta":"AAE="}`,
+			`{"type":"response.output_audio_transcript.delta","delta":"Let me check."}`,
+			`{"type":"response.output_item.added","item":{"id":"item_1","type":"function_call","call_id":"call_1","name":"weather"}}`,
+			`{"type":"response.function_call_arguments.delta","item_id":"item_1","delta":"{}"}`,
+			`{"type":"response.function_call_arguments.done","item_id":"item_1","arguments":"{}"}`,
+			`{"type":"response.done","response":{"id":"resp_1","status":"completed","usage":{"input_tokens":10,"output_tokens":5,"total_tokens":15}}}`,
+		)
+		received <- recv() // tool output
+		received <- recv() // response.create
+		send(conn,
+			`{"type":"response.created","response":{"id":"resp_2"}}`,
+			`{"type":"response.output_text.delta","delta":"It's sunny."}`,
+			`{"type":"response.done","response":{"id":"resp_2","status":"completed"}}`,
+		)
+		recv()
+	})
+
+	dialer := realtime.OpenAI(realtime.WithAPIKey("test"), realtime.WithBaseURL(server.URL))
+	s, err := dialer.Dial(context.Background(), realtime.SessionConfig{Tools: []step.Tool{weatherTool{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.SendAudio(context.Background(), step.Audio{MimeType: "audio/pcm;rate=24000", Data: []byte{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-received; msg["type"] != "input_audio_buffer.append" || msg["audio"] != "AQI=" {
+		t.Errorf("audio message = %v", msg)
+	}
+	if err := s.SendAudio(context.Background(), step.Audio{MimeType: "audio/pcm;rate=16000"}); err == nil {
+		t.Error("expected an error for 16 kHz audio")
+	}
+
+	deltas, inputs, final := collect(t, s)
+	if len(inputs) != 1 || inputs[0].Parts[0].(step.AudioPart).Transcript != "What's the weather?" {
+		t.Errorf("inputs = %+v", inputs)
+	}
+	if audio, ok := deltas[0].(step.AudioDelta); !ok || string(audio.Data) != "\x00\x01" {
+		t.Errorf("first delta = %+v", deltas[0])
+	}
+	if final.StopReason != step.StopToolUse || final.Usage.TotalTokens != 15 || final.Provenance.RequestID != "resp_1" {
+		t.Errorf("unexpected final message %+v", final)
+	}
+	audio := final.Parts[0].(step.AudioPart)
+	call := final.Parts[1].(step.ToolCallPart)
+	if audio.Transcript != "Let me check." || audio.DataB64 != "" || call.CallID != "call_1" || call.Name != "weather" {
+		t.Errorf("unexpected parts %+v", final.Parts)
+	}
+
+	results, err := s.RunTools(context.Background(), final)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Parts[0].(step.TextPart).Text != "sunny" {
+		t.Errorf("results = %+v", results)
+	}
+	item := (<-received)["item"].(map[string]any)
+	if item["type"] != "function_call_output" || item["call_id"] != "call_1" || item["output"] != "sunny" {
+		t.Errorf("tool output item = %v", item)
+	}
+	if msg := <-received; msg["type"] != "response.create" {
+		t.Errorf("expected response.create, got %v", msg)
+	}
+
+	_, _, final = collect(t, s)
+	if final.Text() != "It's sunny." || final.StopReason != step.StopStop {
+		t.Errorf("unexpected final message %+v", final)
+	}
+	if h := s.History(); len(h) != 4 {
+		t.Errorf("history has %d messages, want user, assistant, tool result and assistant", len(h))
+	}
+}
+
+func TestGemini(t *testing.T) {
+	received := make(chan map[string]any, 16)
+	server := fakeServer(t, func(conn *websocket.Conn, recv func() map[string]any) {
+		received <- recv() // setup
+		send(conn, `{"setupComplete":{}}`)
+		received <- recv() // history
+		received <- recv() // activityStart
+		received <- recv() // audio
+		received <- recv() // activityEnd
+		send(conn,
+			`{"serverContent":{"inputTranscription":{"text":"Weather "}}}`,
+			`{"serverContent":{"inputTranscription":{"text":"please"}}}`,
+			`{"serverContent":{"modelTurn":{"parts":[{"inlineData":{"mimeType":"audio/pcm;rate=24000","data":"AAE="}}]}}}`,
+			`{"serverContent":{"outputTranscription":{"text":"Checking."}}}`,
+			`{"toolCall":{"functionCalls":[{"id":"fc_1","name":"weather","args":{"city":"Paris"}}]}}`,
+		)
+		received <- recv() // toolResponse
+		send(conn,
+			`{"serverContent":{"outputTranscription":{"text":"Sunny."}}}`,
+			`{"serverContent":{"turnComplete":true},"usageMetadata":{"promptTokenCount":30,"responseTokenCount":6}}`,
+		)
+		recv()
+	})
+
+	dialer := realtime.Gemini(realtime.WithAPIKey("test"), realtime.WithBaseURL(server.URL))
+	s, err := dialer.Dial(context.Background(), realtime.SessionConfig{
+		System:      "Be brief.",
+		Tools:       []step.Tool{weatherTool{}},
+		History:     []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Hi"}}}},
+		ManualTurns: true,
+		KeepAudio:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	setup := (<-received)["setup"].(map[string]any)
+	if setup["model"] != "models/gemini-live-2.5-flash-preview" || setup["realtimeInputConfig"] == nil || setup["tools"] == nil {
+		t.Errorf("setup = %v", setup)
+	}
+	if turns := (<-received)["clientContent"].(map[string]any)["turns"].([]any); len(turns) != 1 {
+		t.Errorf("history turns = %v", turns)
+	}
+
+	if err := s.SendAudio(context.Background(), step.Audio{MimeType: "audio/pcm;rate=16000", Data: []byte{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Respond(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"activityStart", "audio", "activityEnd"} {
+		if msg := (<-received)["realtimeInput"].(map[string]any); msg[want] == nil {
+			t.Errorf("expected %s, got %v", want, msg)
+		}
+	}
+
+	_, inputs, final := collect(t, s)
+	if len(inputs) != 1 || inputs[0].Parts[0].(step.AudioPart).Transcript != "Weather please" {
+		t.Errorf("inputs = %+v", inputs)
+	}
+	audio := final.Parts[0].(step.AudioPart)
+	call := final.Parts[1].(step.ToolCallPart)
+	if final.StopReason != step.StopToolUse || audio.DataB64 != "AAE=" || audio.Transcript != "Checking." || string(call.ArgsJSON) != `{"city":"Paris"}` {
+		t.Errorf("unexpected final message %+v", final)
+	}
+
+	if _, err := s.RunTools(context.Background(), final); err != nil {
+		t.Fatal(err)
+	}
+	resp := (<-received)["toolResponse"].(map[string]any)["functionResponses"].([]any)[0].(map[string]any)
+	if resp["id"] != "fc_1" || resp["response"].(map[string]any)["output"] != "sunny" {
+		t.Errorf("tool response = %v", resp)
+	}
+
+	_, _, final = collect(t, s)
+	if final.Parts[0].(step.AudioPart).Transcript != "Sunny." || final.Usage.TotalTokens != 36 || final.StopReason != step.StopStop {
+		t.Errorf("unexpected final message %+v", final)
+	}
+}
+
+func TestSessionClosed(t *testing.T) {
+	server := fakeServer(t, func(conn *websocket.Conn, recv func() map[string]any) {
+		recv()
+		send(conn, `{"type":"session.updated"}`)
+	})
+	s, err := realtime.OpenAI(realtime.WithAPIKey("test"), realtime.WithBaseURL(server.URL)).Dial(context.Background(), realtime.SessionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Next(context.Background()); !errors.Is(err, io.EOF) {
+		t.Errorf("got %v, want io.EOF after the server closed", err)
+	}
+}
+
+func TestDialError(t *testing.T) {
+	server := fakeServer(t, func(conn *websocket.Conn, recv func() map[string]any) {
+		recv()
+		send(conn, `{"type":"error","error":{"type":"invalid_request_error","message":"bad voice"}}`)
+	})
+	_, err := realtime.OpenAI(realtime.WithAPIKey("test"), realtime.WithBaseURL(server.URL)).Dial(context.Background(), realtime.SessionConfig{})
+	if err == nil || !strings.Contains(err.Error(), "bad voice") {
+		t.Errorf("got %v, want the setup error", err)
+	}
+}
@@ -0,0 +1,163 @@
+// Package replay turns a captured debug log back into a provider, so
+// streaming bugs reported from a user's log can be reproduced locally. The
+// logged chunks are fed through chatcompletion.NewMultiStream at their original
+// timing, or faster, producing the same deltas and messages in the same order.
+//
+// Only Chat Completions logs can be replayed: those of the chatcompletion,
+// openrouter and compat providers.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base/debugread"
+	cc "github.com/inspirepan/step/providers/chatcompletion"
+	"github.com/inspirepan/step/providers/openrouter"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/ssestream"
+)
+
+// ErrExhausted is returned by Stream once every logged request was replayed.
+var ErrExhausted = errors.New("step/providers/replay: no more logged requests")
+
+// Option is a functional option for New.
+type Option func(*config)
+
+type config struct {
+	speed      float64
+	newHandler func(provider, model string) cc.ReasoningHandler
+}
+
+// WithSpeed scales the replay timing: 1 (the default) waits as long as the
+// original stream did before each chunk, 10 replays ten times faster, and 0
+// replays without waiting.
+func WithSpeed(speed float64) Option {
+	return func(c *config) { c.speed = speed }
+}
+
+// WithReasoningHandler sets the reasoning handler for the replayed streams.
+// By default openrouter logs use openrouter.NewReasoningHandler and others
+// cc.NewDefaultReasoningHandler; compat providers with custom reasoning
+// fields need their own.
+func WithReasoningHandler(fn func(provider, model string) cc.ReasoningHandler) Option {
+	return func(c *config) { c.newHandler = fn }
+}
+
+// Provider replays one logged request per Stream call, in log order, so a
+// multi-step run replays the steps it logged. The request passed to Stream
+// is ignored.
+type Provider struct {
+	cfg config
+
+	mu   sync.Mutex
+	reqs []debugread.Request
+}
+
+var _ step.Provider = (*Provider)(nil)
+
+// New returns a provider replaying the requests in records that have chunks.
+func New(records []debugread.Record, opts ...Option) (*Provider, error) {
+	cfg := config{speed: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.newHandler == nil {
+		cfg.newHandler = defaultHandler
+	}
+	var reqs []debugread.Request
+	for _, r := range debugread.Group(records) {
+		switch r.Provider {
+		case "anthropic", "google", "responses":
+			return nil, fmt.Errorf("step/providers/replay: cannot replay %s logs, only Chat Completions", r.Provider)
+		}
+		if len(r.Chunks) > 0 {
+			reqs = append(reqs, r)
+		}
+	}
+	if len(reqs) == 0 {
+		return nil, errors.New("step/providers/replay: log has no chunks; was it written with a debug path set?")
+	}
+	return &Provider{cfg: cfg, reqs: reqs}, nil
+}
+
+// Open returns a provider replaying the debug log at path.
+func Open(path string, opts ...Option) (*Provider, error) {
+	records, err := debugread.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(records, opts...)
+}
+
+// Remaining returns the number of requests not yet replayed.
+func (p *Provider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.reqs)
+}
+
+func (p *Provider) Stream(ctx context.Context, _ step.ProviderRequest) (step.ProviderStream, error) {
+	p.mu.Lock()
+	if len(p.reqs) == 0 {
+		p.mu.Unlock()
+		return nil, ErrExhausted
+	}
+	r := p.reqs[0]
+	p.reqs = p.reqs[1:]
+	p.mu.Unlock()
+
+	dec := &decoder{ctx: ctx, chunks: r.Chunks, logStart: r.Start, start: time.Now(), speed: p.cfg.speed}
+	stream := ssestream.NewStream[openai.ChatCompletionChunk](dec, nil)
+	newHandler := func() cc.ReasoningHandler { return p.cfg.newHandler(r.Provider, r.Model) }
+	return cc.NewMultiStream(r.Provider, r.Model, stream, newHandler, nil), nil
+}
+
+func defaultHandler(provider, model string) cc.ReasoningHandler {
+	if provider == "openrouter" {
+		return openrouter.NewReasoningHandler(model)
+	}
+	return cc.NewDefaultReasoningHandler(model)
+}
+
+// decoder is an ssestream.Decoder over logged chunks. It waits before each
+// chunk until the time it arrived at in the original stream, scaled by speed.
+type decoder struct {
+	ctx      context.Context
+	chunks   []debugread.Record
+	logStart time.Time
+	start    time.Time
+	speed    float64
+
+	cur ssestream.Event
+	err error
+}
+
+func (d *decoder) Next() bool {
+	if len(d.chunks) == 0 || d.err != nil {
+		return false
+	}
+	c := d.chunks[0]
+	if d.speed > 0 && !c.Time.IsZero() {
+		at := d.start.Add(time.Duration(float64(c.Time.Sub(d.logStart)) / d.speed))
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+		case <-d.ctx.Done():
+			timer.Stop()
+			d.err = d.ctx.Err()
+			return false
+		}
+	}
+	d.chunks = d.chunks[1:]
+	d.cur = ssestream.Event{Data: c.Data}
+	return true
+}
+
+func (d *decoder) Event() ssestream.Event { return d.cur }
+func (d *decoder) Close() error           { return nil }
+func (d *decoder) Err() error             { return d.err }
@@ -0,0 +1,101 @@
+package replay_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	cc "github.com/inspirepan/step/providers/chatcompletion"
+	"github.com/inspirepan/step/providers/replay"
+)
+
+var chunks = []string{
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-test","choices":[{"index":0,"delta":{"role":"assistant","content":"Let me "}}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-test","choices":[{"index":0,"delta":{"content":"check."}}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-test","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read","arguments":"{\"path\":"}}]}}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-test","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.go\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":9,"completion_tokens":4,"total_tokens":13}}`,
+}
+
+const gap = 30 * time.Millisecond
+
+// capture runs a step against a server streaming chunks gap apart, logging
+// to a debug file, and returns the file with the deltas and message seen.
+func capture(t *testing.T) (string, []step.MessageDelta, step.AssistantMessage) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			time.Sleep(gap)
+			fmt.Fprintf(w, "data: %s\n\n", c)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "debug.jsonl")
+	provider := cc.New("gpt-test", cc.WithAPIKey("test"), cc.WithBaseURL(server.URL), cc.WithDebug(path))
+	deltas, msg := run(t, provider)
+	return path, deltas, msg
+}
+
+func run(t *testing.T, provider step.Provider) ([]step.MessageDelta, step.AssistantMessage) {
+	t.Helper()
+	var deltas []step.MessageDelta
+	var msg step.AssistantMessage
+	_, err := step.Step(context.Background(), step.StepRequest{Provider: provider},
+		step.WithOnDelta(func(d step.MessageDelta) {
+			switch d.(type) {
+			case step.StepStatusDelta, step.ToolExecEndDelta:
+			default:
+				deltas = append(deltas, d)
+			}
+		}),
+		step.WithOnMessage(func(m step.Message) {
+			if a, ok := m.(step.AssistantMessage); ok {
+				msg = a
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deltas, msg
+}
+
+func TestReplay(t *testing.T) {
+	path, wantDeltas, want := capture(t)
+
+	provider, err := replay.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	deltas, got := run(t, provider)
+	if elapsed := time.Since(start); elapsed < 3*gap {
+		t.Errorf("replay took %s, want the original timing", elapsed)
+	}
+	if !reflect.DeepEqual(deltas, wantDeltas) {
+		t.Errorf("deltas = %+v, want %+v", deltas, wantDeltas)
+	}
+	if got.Text() != want.Text() || !reflect.DeepEqual(got.Parts, want.Parts) || got.StopReason != step.StopToolUse || got.Usage.TotalTokens != 13 {
+		t.Errorf("message = %+v, want %+v", got, want)
+	}
+	if _, err := provider.Stream(context.Background(), step.ProviderRequest{}); !errors.Is(err, replay.ErrExhausted) {
+		t.Errorf("got %v after the last request, want ErrExhausted", err)
+	}
+
+	fast, err := replay.Open(path, replay.WithSpeed(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	if _, got := run(t, fast); got.Text() != want.Text() || time.Since(start) > 2*gap {
+		t.Errorf("unthrottled replay took %s", time.Since(start))
+	}
+}
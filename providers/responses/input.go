@@ -0,0 +1,208 @@
+package responses
+
+import (
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+)
+
+// ReasoningFormat marks ThinkingParts produced from Responses API reasoning items.
+// ID holds the reasoning item ID.
+const ReasoningFormat = "openai-responses-v1"
+
+// buildInput converts history to Responses API input items.
+// Items are plain maps so the request body is independent of SDK union types.
+// When stateless is set, reasoning items can only be replayed with their
+// encrypted content, since the server has not stored them.
+func buildInput(history []step.Message, targetModel string, stateless bool) []map[string]any {
+	var items []map[string]any
+	for _, msg := range history {
+		switch m := msg.(type) {
+		case step.UserMessage:
+			items = append(items, convertUserMessage(m))
+		case *step.UserMessage:
+			items = append(items, convertUserMessage(*m))
+		case step.AssistantMessage:
+			items = append(items, convertAssistantMessage(m, targetModel, stateless)...)
+		case *step.AssistantMessage:
+			items = append(items, convertAssistantMessage(*m, targetModel, stateless)...)
+		case step.ToolMessage:
+			items = append(items, convertToolMessage(m))
+		case *step.ToolMessage:
+			items = append(items, convertToolMessage(*m))
+		}
+	}
+	return items
+}
+
+func convertUserMessage(m step.UserMessage) map[string]any {
+	var content []map[string]any
+	for _, part := range m.Parts {
+		switch p := part.(type) {
+		case step.TextPart:
+			content = append(content, map[string]any{"type": "input_text", "text": p.Text})
+		case *step.TextPart:
+			content = append(content, map[string]any{"type": "input_text", "text": p.Text})
+		case step.ImagePart:
+			content = append(content, inputImage(p))
+		case *step.ImagePart:
+			content = append(content, inputImage(*p))
+		}
+	}
+	if len(content) == 0 {
+		content = append(content, map[string]any{"type": "input_text", "text": "."})
+	}
+	return map[string]any{"role": "user", "content": content}
+}
+
+func inputImage(p step.ImagePart) map[string]any {
+	return map[string]any{"type": "input_image", "image_url": "data:" + p.MimeType + ";base64," + p.DataB64}
+}
+
+func convertAssistantMessage(m step.AssistantMessage, targetModel string, stateless bool) []map[string]any {
+	sourceModel := ""
+	if m.Provenance != nil {
+		sourceModel = m.Provenance.Model
+	}
+
+	var items []map[string]any
+	var degraded string
+	for _, part := range m.Parts {
+		switch p := part.(type) {
+		case *step.ThinkingPart:
+			part = *p
+		case *step.TextPart:
+			part = *p
+		case *step.ToolCallPart:
+			part = *p
+		}
+		switch p := part.(type) {
+		case step.ThinkingPart:
+			model := p.ModelName
+			if model == "" {
+				model = sourceModel
+			}
+			replayable := p.Format == ReasoningFormat && p.ID != "" && (model == "" || model == targetModel)
+			if stateless && p.Signature == "" {
+				replayable = false
+			}
+			if replayable {
+				items = append(items, reasoningItem(p))
+			} else if p.Thinking != "" {
+				degraded += "<thinking>\n" + p.Thinking + "\n</thinking>\n"
+			}
+		case step.TextPart:
+			if text := degraded + p.Text; text != "" {
+				items = append(items, assistantText(text))
+			}
+			degraded = ""
+		case step.ToolCallPart:
+			args := string(p.ArgsJSON)
+			if args == "" {
+				args = "{}"
+			}
+			items = append(items, map[string]any{
+				"type":      "function_call",
+				"call_id":   p.CallID,
+				"name":      p.Name,
+				"arguments": args,
+			})
+		}
+	}
+	if degraded != "" {
+		items = append(items, assistantText(degraded))
+	}
+	return items
+}
+
+func assistantText(text string) map[string]any {
+	return map[string]any{
+		"type":    "message",
+		"role":    "assistant",
+		"content": []map[string]any{{"type": "output_text", "text": text}},
+	}
+}
+
+func reasoningItem(p step.ThinkingPart) map[string]any {
+	summary := []map[string]any{}
+	if p.Thinking != "" {
+		summary = append(summary, map[string]any{"type": "summary_text", "text": p.Thinking})
+	}
+	item := map[string]any{"type": "reasoning", "id": p.ID, "summary": summary}
+	if p.Signature != "" {
+		item["encrypted_content"] = p.Signature
+	}
+	return item
+}
+
+// convertToolMessage sends a single text output as a string and anything else
+// as an array of input_text/input_image items, keeping part order.
+func convertToolMessage(m step.ToolMessage) map[string]any {
+	var content []map[string]any
+	for _, part := range m.Parts {
+		switch p := part.(type) {
+		case step.TextPart:
+			if p.Text != "" {
+				content = append(content, map[string]any{"type": "input_text", "text": p.Text})
+			}
+		case *step.TextPart:
+			if p.Text != "" {
+				content = append(content, map[string]any{"type": "input_text", "text": p.Text})
+			}
+		case step.ImagePart:
+			content = append(content, inputImage(p))
+		case *step.ImagePart:
+			content = append(content, inputImage(*p))
+		}
+	}
+	var output any
+	switch {
+	case len(content) == 0:
+		output = "<system-reminder>Tool ran without output or errors</system-reminder>"
+	case len(content) == 1 && content[0]["type"] == "input_text":
+		output = content[0]["text"]
+	default:
+		output = content
+	}
+	return map[string]any{"type": "function_call_output", "call_id": m.CallID, "output": output}
+}
+
+func convertTools(specs []step.ToolSpec) []map[string]any {
+	tools := make([]map[string]any, 0, len(specs))
+	for _, spec := range specs {
+		params := spec.Parameters
+		if spec.Strict {
+			params = base.StrictSchema(params)
+		}
+		tools = append(tools, map[string]any{
+			"type":        "function",
+			"name":        spec.Name,
+			"description": spec.Description,
+			"parameters":  params,
+			"strict":      spec.Strict,
+		})
+	}
+	return tools
+}
+
+// splitAtPreviousResponse finds the last assistant message produced by this
+// provider for targetModel and returns its response ID and the messages after it.
+// It returns "" and the full history when there is no such message.
+func splitAtPreviousResponse(history []step.Message, targetModel string) (string, []step.Message) {
+	for i := len(history) - 1; i >= 0; i-- {
+		var am step.AssistantMessage
+		switch m := history[i].(type) {
+		case step.AssistantMessage:
+			am = m
+		case *step.AssistantMessage:
+			am = *m
+		default:
+			continue
+		}
+		pv := am.Provenance
+		if pv == nil || pv.Provider != providerName || pv.Model != targetModel || pv.RequestID == "" {
+			return "", history
+		}
+		return pv.RequestID, history[i+1:]
+	}
+	return "", history
+}
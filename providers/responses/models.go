@@ -0,0 +1,35 @@
+package responses
+
+import (
+	"context"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+var (
+	_ step.ModelLister = (*provider)(nil)
+	_ step.Pinger      = (*provider)(nil)
+)
+
+// ListModels lists the models available to the API key.
+func (p *provider) ListModels(ctx context.Context) ([]step.ModelInfo, error) {
+	var models []step.ModelInfo
+	iter := p.client.Models.ListAutoPaging(ctx)
+	for iter.Next() {
+		m := iter.Current()
+		info := step.ModelInfo{ID: m.ID}
+		if m.Created > 0 {
+			info.Created = time.Unix(m.Created, 0)
+		}
+		models = append(models, info)
+	}
+	return models, iter.Err()
+}
+
+// Ping checks the API key and base URL by fetching the first page of models.
+func (p *provider) Ping(ctx context.Context) error {
+	iter := p.client.Models.ListAutoPaging(ctx)
+	iter.Next()
+	return iter.Err()
+}
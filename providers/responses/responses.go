@@ -2,12 +2,13 @@ package responses
 
 import (
 	"context"
-	"errors"
+	"strings"
 
 	"github.com/inspirepan/step"
 	"github.com/inspirepan/step/providers/base"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/responses"
 	"github.com/openai/openai-go/v3/shared"
 )
 
@@ -17,6 +18,14 @@ type Config struct {
 
 	// Reasoning options
 	Reasoning shared.ReasoningParam
+
+	// UsePreviousResponseID sends previous_response_id and only the new messages
+	// when the history ends with a response from this provider and model.
+	UsePreviousResponseID bool
+
+	// Store controls whether OpenAI stores responses. Nil uses the API default (stored).
+	// With Store=false, reasoning is round-tripped via encrypted content instead.
+	Store *bool
 }
 
 // Option is a functional option for this provider.
@@ -27,6 +36,18 @@ func WithAPIKey(key string) Option {
 	return func(c *Config) { c.APIKey = key }
 }
 
+// WithAPIKeys spreads requests over several API keys, moving to the next key
+// when one is rejected (401) or rate limited (429). See base.KeyRotation.
+func WithAPIKeys(keys ...string) Option {
+	return func(c *Config) { c.Keys = base.StaticKeys(keys) }
+}
+
+// WithKeyProvider fetches API keys at request time, e.g. from a secret
+// manager, rotating between them like WithAPIKeys.
+func WithKeyProvider(keys base.KeyProvider) Option {
+	return func(c *Config) { c.Keys = keys }
+}
+
 // WithBaseURL sets a custom base URL.
 func WithBaseURL(url string) Option {
 	return func(c *Config) { c.BaseURL = url }
@@ -42,11 +63,23 @@ func WithMaxOutputTokens(n int) Option {
 	return func(c *Config) { c.MaxOutputTokens = &n }
 }
 
+// WithJSONMode requests a single JSON object as output without a schema.
+// The final text is validated; a *step.InvalidJSONError is returned if it does not parse.
+func WithJSONMode() Option {
+	return func(c *Config) { c.ResponseFormat = base.ResponseFormatJSONObject }
+}
+
 // WithDebug enables JSONL debug logging to the specified file path.
 func WithDebug(path string) Option {
 	return func(c *Config) { c.DebugPath = path }
 }
 
+// WithDebugSink sends debug records to sink, in addition to or instead of a
+// WithDebug file.
+func WithDebugSink(sink base.DebugSink) Option {
+	return func(c *Config) { c.DebugSink = sink }
+}
+
 // WithExtraHeader adds a custom header to requests.
 func WithExtraHeader(key, value string) Option {
 	return func(c *Config) {
@@ -67,6 +100,18 @@ func WithExtraBody(key string, value any) Option {
 	}
 }
 
+// WithFinishReason maps the raw provider finish reason raw to reason,
+// overriding the built-in mapping. The raw value is always kept in
+// Provenance.FinishReason.
+func WithFinishReason(raw string, reason step.StopReason) Option {
+	return func(c *Config) {
+		if c.FinishReasons == nil {
+			c.FinishReasons = make(map[string]step.StopReason)
+		}
+		c.FinishReasons[raw] = reason
+	}
+}
+
 // WithReasoningEffort sets reasoning effort level.
 func WithReasoningEffort(effort shared.ReasoningEffort) Option {
 	return func(c *Config) { c.Reasoning.Effort = effort }
@@ -77,6 +122,24 @@ func WithReasoningSummary(summary shared.ReasoningSummary) Option {
 	return func(c *Config) { c.Reasoning.Summary = summary }
 }
 
+// WithPreviousResponseID reuses server-side conversation state: when the latest
+// assistant message in history came from this provider and model, only the
+// messages after it are sent, together with its response ID as previous_response_id.
+// The response ID is read from AssistantMessage.Provenance.RequestID, so persisted
+// histories keep working across processes. Requires responses to be stored
+// (OpenAI's default); stored responses expire after 30 days.
+func WithPreviousResponseID() Option {
+	return func(c *Config) { c.UsePreviousResponseID = true }
+}
+
+// WithStore sets whether responses are stored server-side. Setting it to false
+// (e.g. for zero data retention) requests reasoning.encrypted_content, which is
+// kept in ThinkingPart.Signature and sent back with the reasoning item on later
+// turns. WithPreviousResponseID has no effect when store is false.
+func WithStore(store bool) Option {
+	return func(c *Config) { c.Store = &store }
+}
+
 // New creates a Provider using OpenAI Responses API.
 // It reads OPENAI_API_KEY and OPENAI_BASE_URL from environment if not explicitly set.
 func New(model string, opts ...Option) step.Provider {
@@ -99,6 +162,8 @@ func New(model string, opts ...Option) step.Provider {
 	for k, v := range cfg.ExtraBody {
 		clientOpts = append(clientOpts, option.WithJSONSet(k, v))
 	}
+	keys := base.NewKeyRotation(cfg.Keys, base.BearerKey)
+	clientOpts = append(clientOpts, option.WithMiddleware(keys.RoundTrip, base.TransferMiddleware(cfg.CompressRequests)))
 	client := openai.NewClient(clientOpts...)
 	return &provider{model: model, cfg: cfg, client: client}
 }
@@ -109,8 +174,121 @@ type provider struct {
 	client openai.Client
 }
 
+const defaultBaseURL = "https://api.openai.com/v1"
+
+// buildBody returns the request fields for req. The body is built as JSON fields
+// rather than SDK params; the SDK only handles transport and SSE.
+func (p *provider) buildBody(req step.ProviderRequest) map[string]any {
+	model := base.RequestModel(req, p.model)
+	stateless := p.cfg.Store != nil && !*p.cfg.Store
+	jsonMode := p.cfg.ResponseFormat == base.ResponseFormatJSONObject
+	if jsonMode {
+		req.SystemPrompt = base.JSONModeSystemPrompt(req.SystemPrompt)
+	}
+	history := req.History
+	var previousID string
+	if p.cfg.UsePreviousResponseID && !stateless {
+		previousID, history = splitAtPreviousResponse(history, model)
+	}
+
+	body := map[string]any{
+		"model": model,
+		"input": buildInput(history, model, stateless),
+	}
+	if p.cfg.Store != nil {
+		body["store"] = *p.cfg.Store
+	}
+	if stateless {
+		body["include"] = []string{"reasoning.encrypted_content"}
+	}
+	if previousID != "" {
+		body["previous_response_id"] = previousID
+	}
+	if req.SystemPrompt != "" {
+		// instructions are not carried over by previous_response_id, so always send them
+		body["instructions"] = req.SystemPrompt
+	}
+	if len(req.Tools) > 0 {
+		body["tools"] = convertTools(req.Tools)
+		body["parallel_tool_calls"] = true
+	}
+	reasoning := p.cfg.Reasoning
+	if req.ReasoningEffort != "" {
+		reasoning.Effort = shared.ReasoningEffort(req.ReasoningEffort)
+	}
+	if reasoning.Effort != "" || reasoning.Summary != "" {
+		body["reasoning"] = reasoning
+	}
+	if p.cfg.Temperature != nil {
+		body["temperature"] = *p.cfg.Temperature
+	}
+	if p.cfg.MaxOutputTokens != nil {
+		body["max_output_tokens"] = *p.cfg.MaxOutputTokens
+	}
+	if jsonMode {
+		body["text"] = map[string]any{"format": map[string]any{"type": "json_object"}}
+	}
+	return body
+}
+
+var _ step.PayloadBuilder = (*provider)(nil)
+
+// BuildPayload returns the request Stream would send, without sending it.
+func (p *provider) BuildPayload(_ context.Context, req step.ProviderRequest) (step.Payload, error) {
+	extra := map[string]any{"stream": true}
+	for k, v := range p.cfg.ExtraBody {
+		extra[k] = v
+	}
+	body, err := base.MarshalWithExtra(p.buildBody(req), extra)
+	if err != nil {
+		return step.Payload{}, err
+	}
+	baseURL := p.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range p.cfg.ExtraHeaders {
+		headers[k] = v
+	}
+	return step.Payload{
+		Provider: providerName,
+		Model:    base.RequestModel(req, p.model),
+		URL:      strings.TrimRight(baseURL, "/") + "/responses",
+		Headers:  headers,
+		Body:     body,
+	}, nil
+}
+
 func (p *provider) Stream(ctx context.Context, req step.ProviderRequest) (step.ProviderStream, error) {
-	_ = ctx
-	_ = req
-	return nil, errors.New("step/providers/responses: not implemented")
+	model := base.RequestModel(req, p.model)
+	body := p.buildBody(req)
+
+	debug, err := p.cfg.DebugLogger()
+	if err != nil {
+		return nil, err
+	}
+	if debug != nil {
+		rec := base.NewDebugRecord("request", body)
+		rec.Provider = providerName
+		rec.Model = model
+		rec.IdempotencyKey = step.IdempotencyKey(ctx)
+		_ = debug.Log(rec)
+	}
+
+	opts := make([]option.RequestOption, 0, len(body)+1)
+	for k, v := range body {
+		opts = append(opts, option.WithJSONSet(k, v))
+	}
+	if key := step.IdempotencyKey(ctx); key != "" {
+		opts = append(opts, option.WithHeader(base.IdempotencyHeader, key))
+	}
+	ctx, stats := base.TrackTransfer(ctx)
+	stream := p.client.Responses.NewStreaming(ctx, responses.ResponseNewParams{}, opts...)
+	out := base.ReportTransfer(NewStream(model, stream, debug).EmitRaw(req.Raw), stats)
+	out = base.MapFinishReasons(out, p.cfg.FinishReasons)
+	if p.cfg.ResponseFormat == base.ResponseFormatJSONObject {
+		out = base.ValidateJSON(out, base.PrefillText(req.History))
+	}
+	return out, nil
 }
@@ -0,0 +1,36 @@
+package responses_test
+
+import (
+	"testing"
+
+	"github.com/inspirepan/step/providers/responses"
+	"github.com/inspirepan/step/testkit"
+)
+
+const envKey = "OPENAI_API_KEY"
+
+func TestResponses_Conformance(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	testkit.RunConformanceSuite(t, responses.New("gpt-5-mini"))
+}
+
+// TestResponses_PreviousResponseID runs multi-turn and tool loops with server-side state.
+func TestResponses_PreviousResponseID(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := responses.New("gpt-5-mini", responses.WithPreviousResponseID())
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestMultiTurn(t, cfg)
+	testkit.TestToolCalling(t, cfg)
+}
+
+// TestResponses_StatelessReasoning round-trips encrypted reasoning with store=false.
+func TestResponses_StatelessReasoning(t *testing.T) {
+	testkit.SkipIfNoEnv(t, envKey)
+
+	provider := responses.New("gpt-5-mini", responses.WithStore(false), responses.WithReasoningSummary("auto"))
+	cfg := testkit.DefaultConfig(provider)
+	testkit.TestThinkingRoundTrip(t, cfg)
+	testkit.TestToolCalling(t, cfg)
+}
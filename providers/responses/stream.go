@@ -0,0 +1,395 @@
+package responses
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/base"
+	"github.com/openai/openai-go/v3/packages/ssestream"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+const providerName = "responses"
+
+// Stream implements step.ProviderStream for the OpenAI Responses API.
+// Events are decoded from their raw JSON so only the fields used here are relied upon.
+type Stream struct {
+	modelName string
+	stream    *ssestream.Stream[responses.ResponseStreamEventUnion]
+	debug     *base.DebugLogger
+	raw       bool
+
+	mu sync.Mutex
+
+	done bool
+	err  error
+
+	pending []step.ProviderUpdate
+
+	// items accumulates output items by output_index; byID maps item IDs to output indexes.
+	items map[int]*itemAccumulator
+	byID  map[string]int
+
+	finishReason string
+	requestID    string
+	servedModel  string
+	usage        *step.Usage
+	startedAt    time.Time
+}
+
+type itemAccumulator struct {
+	kind      string // message, reasoning, function_call
+	id        string
+	callID    string
+	name      string
+	text      string
+	args      string
+	encrypted string
+}
+
+type streamEvent struct {
+	Type        string          `json:"type"`
+	Delta       string          `json:"delta"`
+	ItemID      string          `json:"item_id"`
+	OutputIndex int             `json:"output_index"`
+	Item        *outputItem     `json:"item"`
+	Response    *responseObject `json:"response"`
+	Code        string          `json:"code"`
+	Message     string          `json:"message"`
+}
+
+type outputItem struct {
+	Type             string `json:"type"`
+	ID               string `json:"id"`
+	CallID           string `json:"call_id"`
+	Name             string `json:"name"`
+	Arguments        string `json:"arguments"`
+	EncryptedContent string `json:"encrypted_content"`
+	Content          []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Summary []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"summary"`
+}
+
+type responseObject struct {
+	ID                string `json:"id"`
+	Model             string `json:"model"`
+	Status            string `json:"status"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Usage *struct {
+		InputTokens        int `json:"input_tokens"`
+		OutputTokens       int `json:"output_tokens"`
+		TotalTokens        int `json:"total_tokens"`
+		InputTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"input_tokens_details"`
+	} `json:"usage"`
+}
+
+// NewStream wraps a Responses API event stream.
+func NewStream(modelName string, stream *ssestream.Stream[responses.ResponseStreamEventUnion], debug *base.DebugLogger) *Stream {
+	return &Stream{
+		modelName: modelName,
+		stream:    stream,
+		debug:     debug,
+		items:     make(map[int]*itemAccumulator),
+		byID:      make(map[string]int),
+		startedAt: time.Now(),
+	}
+}
+
+// EmitRaw makes the stream emit a step.ProviderRawUpdate for every chunk
+// when enabled; see step.ProviderRequest.Raw.
+func (s *Stream) EmitRaw(enabled bool) *Stream {
+	s.raw = enabled
+	return s
+}
+
+func (s *Stream) Next(ctx context.Context) (step.ProviderUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) > 0 {
+		return s.dequeue()
+	}
+	if s.done {
+		return nil, io.EOF
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Finalize a partial message so callers still get a coherent AssistantMessage.
+			if !s.done {
+				s.finalize()
+				if len(s.pending) > 0 {
+					return s.dequeue()
+				}
+			}
+			return nil, io.EOF
+		default:
+		}
+
+		if !s.stream.Next() {
+			if err := s.stream.Err(); err != nil {
+				s.err = err
+				return nil, s.err
+			}
+			s.finalize()
+			if len(s.pending) > 0 {
+				return s.dequeue()
+			}
+			return nil, io.EOF
+		}
+
+		if err := s.processEvent(s.stream.Current().RawJSON()); err != nil {
+			s.err = err
+			return nil, s.err
+		}
+		if len(s.pending) > 0 {
+			return s.dequeue()
+		}
+	}
+}
+
+func (s *Stream) Close() error {
+	if s.debug != nil {
+		_ = s.debug.Close()
+	}
+	return s.stream.Close()
+}
+
+func (s *Stream) enqueue(up step.ProviderUpdate) {
+	s.pending = append(s.pending, up)
+}
+
+func (s *Stream) dequeue() (step.ProviderUpdate, error) {
+	up := s.pending[0]
+	s.pending = s.pending[1:]
+
+	if s.debug != nil {
+		rec := base.NewDebugRecord("update", up)
+		rec.Provider = providerName
+		rec.Model = s.modelName
+		_ = s.debug.Log(rec)
+	}
+
+	return up, nil
+}
+
+func (s *Stream) item(index int) *itemAccumulator {
+	acc, ok := s.items[index]
+	if !ok {
+		acc = &itemAccumulator{}
+		s.items[index] = acc
+	}
+	return acc
+}
+
+func (s *Stream) itemByID(id string) *itemAccumulator {
+	if idx, ok := s.byID[id]; ok {
+		return s.items[idx]
+	}
+	return nil
+}
+
+func (s *Stream) processEvent(raw string) error {
+	if s.debug != nil {
+		rec := base.NewDebugRecord("chunk", json.RawMessage(raw))
+		rec.Provider = providerName
+		rec.Model = s.modelName
+		_ = s.debug.Log(rec)
+	}
+	if s.raw {
+		s.enqueue(step.ProviderRawUpdate{Provider: providerName, Data: json.RawMessage(raw)})
+	}
+
+	var ev streamEvent
+	if err := json.Unmarshal([]byte(raw), &ev); err != nil {
+		return err
+	}
+
+	switch ev.Type {
+	case "response.created", "response.in_progress":
+		if r := ev.Response; r != nil {
+			s.requestID = r.ID
+			s.servedModel = r.Model
+		}
+
+	case "response.output_item.added", "response.output_item.done":
+		if ev.Item == nil {
+			return nil
+		}
+		acc := s.item(ev.OutputIndex)
+		acc.kind = ev.Item.Type
+		acc.id = ev.Item.ID
+		s.byID[ev.Item.ID] = ev.OutputIndex
+		switch ev.Item.Type {
+		case "function_call":
+			acc.callID = ev.Item.CallID
+			acc.name = ev.Item.Name
+			if ev.Type == "response.output_item.added" {
+				s.enqueue(step.ProviderDeltaUpdate{Delta: step.ToolCallDelta{CallID: acc.callID, Name: acc.name}})
+			} else {
+				acc.args = ev.Item.Arguments
+			}
+		case "reasoning":
+			if ev.Type == "response.output_item.done" {
+				var sb strings.Builder
+				for _, sum := range ev.Item.Summary {
+					if sb.Len() > 0 {
+						sb.WriteString("\n\n")
+					}
+					sb.WriteString(sum.Text)
+				}
+				acc.text = sb.String()
+				acc.encrypted = ev.Item.EncryptedContent
+			}
+		case "message":
+			if ev.Type == "response.output_item.done" {
+				var sb strings.Builder
+				for _, c := range ev.Item.Content {
+					sb.WriteString(c.Text)
+				}
+				acc.text = sb.String()
+			}
+		}
+
+	case "response.output_text.delta":
+		if acc := s.itemByID(ev.ItemID); acc != nil {
+			acc.text += ev.Delta
+		}
+		s.enqueue(step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: ev.Delta}})
+
+	case "response.reasoning_summary_text.delta":
+		if acc := s.itemByID(ev.ItemID); acc != nil {
+			acc.text += ev.Delta
+		}
+		s.enqueue(step.ProviderDeltaUpdate{Delta: step.ThinkingDelta{ID: ev.ItemID, Delta: ev.Delta}})
+
+	case "response.function_call_arguments.delta":
+		if acc := s.itemByID(ev.ItemID); acc != nil {
+			acc.args += ev.Delta
+			s.enqueue(step.ProviderDeltaUpdate{Delta: step.ToolCallDelta{CallID: acc.callID, Name: acc.name, ArgsDelta: ev.Delta}})
+		}
+
+	case "response.completed", "response.incomplete":
+		if r := ev.Response; r != nil {
+			s.finishReason = r.Status
+			if r.IncompleteDetails != nil && r.IncompleteDetails.Reason != "" {
+				s.finishReason = r.IncompleteDetails.Reason
+			}
+			if u := r.Usage; u != nil {
+				s.usage = &step.Usage{
+					InputTokens:      u.InputTokens,
+					OutputTokens:     u.OutputTokens,
+					CachedReadTokens: u.InputTokensDetails.CachedTokens,
+					TotalTokens:      u.TotalTokens,
+				}
+			}
+		}
+
+	case "response.failed":
+		if r := ev.Response; r != nil && r.Error != nil {
+			return fmt.Errorf("step/providers/responses: %s: %s", r.Error.Code, r.Error.Message)
+		}
+		return fmt.Errorf("step/providers/responses: response failed")
+
+	case "error":
+		return fmt.Errorf("step/providers/responses: %s: %s", ev.Code, ev.Message)
+	}
+	return nil
+}
+
+func (s *Stream) finalize() {
+	s.done = true
+
+	idxs := make([]int, 0, len(s.items))
+	for idx := range s.items {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+
+	var parts []step.Part
+	hasCalls := false
+	for _, idx := range idxs {
+		acc := s.items[idx]
+		switch acc.kind {
+		case "reasoning":
+			parts = append(parts, step.ThinkingPart{
+				ID:        acc.id,
+				Thinking:  acc.text,
+				Signature: acc.encrypted,
+				Format:    ReasoningFormat,
+				ModelName: s.modelName,
+			})
+		case "message":
+			if acc.text != "" {
+				parts = append(parts, step.TextPart{Text: acc.text})
+			}
+		case "function_call":
+			if acc.callID == "" || acc.name == "" {
+				continue
+			}
+			args := acc.args
+			if args == "" {
+				args = "{}"
+			}
+			parts = append(parts, step.ToolCallPart{CallID: acc.callID, Name: acc.name, ArgsJSON: json.RawMessage(args)})
+			hasCalls = true
+		}
+	}
+
+	stop := mapFinishReason(s.finishReason)
+	if hasCalls && stop == step.StopStop {
+		stop = step.StopToolUse
+	}
+
+	now := time.Now()
+	msg := step.AssistantMessage{
+		Parts:      parts,
+		Timestamp:  now.UnixMilli(),
+		Usage:      s.usage,
+		StopReason: stop,
+		Provenance: &step.Provenance{
+			Provider:     providerName,
+			Model:        s.modelName,
+			ServedModel:  s.servedModel,
+			RequestID:    s.requestID,
+			LatencyMs:    now.Sub(s.startedAt).Milliseconds(),
+			FinishReason: s.finishReason,
+		},
+	}
+	s.enqueue(step.ProviderMessageUpdate{Message: msg})
+}
+
+func mapFinishReason(reason string) step.StopReason {
+	switch reason {
+	case "max_output_tokens":
+		return step.StopLength
+	case "content_filter":
+		return step.StopContentFilter
+	default:
+		return step.StopStop
+	}
+}
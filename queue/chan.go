@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ChanQueue is an in-process Queue on a buffered channel. Enqueuing a job
+// whose ID is already queued or running does nothing.
+type ChanQueue struct {
+	jobs chan Job
+
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+var _ Queue = (*ChanQueue)(nil)
+
+// NewChanQueue returns a ChanQueue holding up to size jobs; Enqueue blocks
+// while it is full.
+func NewChanQueue(size int) *ChanQueue {
+	return &ChanQueue{jobs: make(chan Job, size), pending: make(map[string]bool)}
+}
+
+func (q *ChanQueue) Enqueue(ctx context.Context, job Job) error {
+	q.mu.Lock()
+	if q.pending[job.ID] {
+		q.mu.Unlock()
+		return nil
+	}
+	q.pending[job.ID] = true
+	q.mu.Unlock()
+
+	job.Attempt = 0
+	select {
+	case q.jobs <- job:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		delete(q.pending, job.ID)
+		q.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (q *ChanQueue) Dequeue(ctx context.Context) (Job, error) {
+	select {
+	case job := <-q.jobs:
+		job.Attempt++
+		return job, nil
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+}
+
+func (q *ChanQueue) Ack(_ context.Context, job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, job.ID)
+	return nil
+}
+
+// Nack queues job again after delay. The job stays pending meanwhile, so
+// enqueuing its ID again does nothing.
+func (q *ChanQueue) Nack(_ context.Context, job Job, delay time.Duration) error {
+	time.AfterFunc(delay, func() { q.jobs <- job })
+	return nil
+}
+
+// Len returns the number of jobs waiting in the channel.
+func (q *ChanQueue) Len() int { return len(q.jobs) }
@@ -0,0 +1,272 @@
+// Package queue runs agent runs as jobs taken from a queue, for server-side
+// agent workloads where the process accepting a request is not the one
+// running it:
+//
+//	q := queue.NewChanQueue(100)
+//	w := &queue.Worker{
+//		Queue:       q,
+//		Agents:      map[string]step.Agent{"support": support},
+//		Concurrency: 4,
+//		OnResult:    func(r queue.Result) { log.Println(r.JobID, r.Run.Text(), r.Err) },
+//	}
+//	go w.Run(ctx)
+//	err := q.Enqueue(ctx, queue.Job{ID: ticketID, Agent: "support", History: history})
+//
+// Queues deliver jobs at least once. The job ID is the idempotency key: a
+// Worker records finished jobs in its ResultStore and acknowledges a job
+// delivered again without running it twice. Failed runs are retried with
+// backoff up to Worker.MaxAttempts.
+//
+// ChanQueue is the in-process Queue; RedisQueue shows how to back a Queue by
+// an external broker.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// ErrUnknownAgent is the Result error of a job naming an agent the Worker
+// does not have. It is not retried.
+var ErrUnknownAgent = errors.New("queue: unknown agent")
+
+// Job is a request to run an agent. Jobs are plain data, so that queues can
+// store them; the agent is named and looked up by the Worker.
+type Job struct {
+	// ID identifies the job and is its idempotency key: once a job with
+	// the ID finished, deliveries of the ID are acknowledged without a run.
+	ID      string
+	Agent   string
+	History []step.Message
+	// Attempt is the number of the current delivery, from 1. Queues set it
+	// in Dequeue.
+	Attempt int
+}
+
+type jobJSON struct {
+	ID      string            `json:"id"`
+	Agent   string            `json:"agent"`
+	History []json.RawMessage `json:"history,omitempty"`
+	Attempt int               `json:"attempt,omitempty"`
+}
+
+// MarshalJSON encodes the job with its messages in their JSON form.
+func (j Job) MarshalJSON() ([]byte, error) {
+	out := jobJSON{ID: j.ID, Agent: j.Agent, Attempt: j.Attempt}
+	for _, msg := range j.History {
+		raw, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		out.History = append(out.History, raw)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a job written by MarshalJSON.
+func (j *Job) UnmarshalJSON(data []byte) error {
+	var in jobJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*j = Job{ID: in.ID, Agent: in.Agent, Attempt: in.Attempt}
+	for _, raw := range in.History {
+		msg, err := step.UnmarshalMessage(raw)
+		if err != nil {
+			return err
+		}
+		j.History = append(j.History, msg)
+	}
+	return nil
+}
+
+// Queue delivers jobs to workers at least once. Implementations must be safe
+// for concurrent use.
+type Queue interface {
+	// Enqueue adds job to the queue.
+	Enqueue(ctx context.Context, job Job) error
+	// Dequeue blocks until a job is available or ctx is done.
+	Dequeue(ctx context.Context) (Job, error)
+	// Ack removes a dequeued job for good.
+	Ack(ctx context.Context, job Job) error
+	// Nack returns a dequeued job to the queue, to be delivered again after
+	// delay.
+	Nack(ctx context.Context, job Job, delay time.Duration) error
+}
+
+// Result is the outcome of a job.
+type Result struct {
+	JobID string
+	Agent string
+	// Attempts is the number of runs, including retries.
+	Attempts int
+	Started  time.Time
+	Duration time.Duration
+	Run      step.RunResult
+	Err      error
+}
+
+// ResultStore records the results of finished jobs by job ID.
+type ResultStore interface {
+	Get(ctx context.Context, jobID string) (Result, bool, error)
+	Put(ctx context.Context, r Result) error
+}
+
+// MemoryResults is an in-process ResultStore.
+type MemoryResults struct {
+	mu      sync.RWMutex
+	results map[string]Result
+}
+
+// Get returns the result of the job, if it finished.
+func (m *MemoryResults) Get(_ context.Context, jobID string) (Result, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.results[jobID]
+	return r, ok, nil
+}
+
+// Put records r.
+func (m *MemoryResults) Put(_ context.Context, r Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.results == nil {
+		m.results = make(map[string]Result)
+	}
+	m.results[r.JobID] = r
+	return nil
+}
+
+// Worker runs the jobs of a queue until its context is done.
+type Worker struct {
+	Queue Queue
+	// Agents are the agents jobs may name.
+	Agents map[string]step.Agent
+	// Results records finished jobs for deduplication. Defaults to a
+	// MemoryResults, which only deduplicates within the process.
+	Results ResultStore
+	// Options are passed to every run. They are shared between runs, so
+	// leave out per-run state such as a Steering or Pauser.
+	Options []step.StepOption
+	// Concurrency is the number of jobs run at once. Defaults to 1.
+	Concurrency int
+	// MaxAttempts bounds the runs of a failing job. Defaults to 3.
+	MaxAttempts int
+	// Backoff returns the delay before the given retry, counted from 1.
+	// Defaults to one second, doubling up to a minute.
+	Backoff func(retry int) time.Duration
+	// Timeout bounds each run. Zero means no limit.
+	Timeout time.Duration
+	// OnResult, if set, receives the result of every job once it finished,
+	// successfully or after its last attempt. Calls are serialized.
+	OnResult func(Result)
+
+	mu sync.Mutex
+}
+
+// Run takes jobs from the queue and runs them until ctx is done. Jobs running
+// when ctx is done are canceled and returned to the queue for another worker.
+// Run returns ctx.Err(), or the error of Dequeue when it fails otherwise.
+func (w *Worker) Run(ctx context.Context) error {
+	if w.Results == nil {
+		w.Results = &MemoryResults{}
+	}
+	n := max(w.Concurrency, 1)
+	slots := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		job, err := w.Queue.Dequeue(ctx)
+		if err != nil {
+			<-slots
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("queue: dequeue: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			w.handle(ctx, job)
+		}()
+	}
+}
+
+// handle runs job once and acknowledges, retries or reports it.
+func (w *Worker) handle(ctx context.Context, job Job) {
+	if _, done, err := w.Results.Get(ctx, job.ID); err == nil && done {
+		_ = w.Queue.Ack(ctx, job)
+		return
+	}
+	res := w.run(ctx, job)
+	if ctx.Err() != nil {
+		// Shutting down: leave the job to another worker.
+		_ = w.Queue.Nack(context.WithoutCancel(ctx), job, 0)
+		return
+	}
+	if res.Err != nil && !errors.Is(res.Err, ErrUnknownAgent) && job.Attempt < w.maxAttempts() {
+		_ = w.Queue.Nack(ctx, job, w.backoff(job.Attempt))
+		return
+	}
+	if err := w.Results.Put(ctx, res); err != nil && res.Err == nil {
+		res.Err = fmt.Errorf("queue: store result: %w", err)
+	}
+	_ = w.Queue.Ack(ctx, job)
+	w.report(res)
+}
+
+func (w *Worker) run(ctx context.Context, job Job) (res Result) {
+	res = Result{JobID: job.ID, Agent: job.Agent, Attempts: job.Attempt, Started: time.Now()}
+	defer func() { res.Duration = time.Since(res.Started) }()
+	agent, ok := w.Agents[job.Agent]
+	if !ok {
+		res.Err = fmt.Errorf("%w %q", ErrUnknownAgent, job.Agent)
+		return res
+	}
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+	res.Run, res.Err = step.Run(ctx, agent, job.History, w.Options...)
+	return res
+}
+
+func (w *Worker) maxAttempts() int {
+	if w.MaxAttempts > 0 {
+		return w.MaxAttempts
+	}
+	return 3
+}
+
+func (w *Worker) backoff(retry int) time.Duration {
+	if w.Backoff != nil {
+		return w.Backoff(retry)
+	}
+	d := time.Second
+	for i := 1; i < retry && d < time.Minute; i++ {
+		d *= 2
+	}
+	return min(d, time.Minute)
+}
+
+func (w *Worker) report(r Result) {
+	if w.OnResult == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.OnResult(r)
+}
@@ -0,0 +1,259 @@
+package queue_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/queue"
+)
+
+func prompt(text string) []step.Message {
+	return []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: text}}}}
+}
+
+// work runs a Worker on q until want results arrived, and returns them.
+func work(t *testing.T, w *queue.Worker, want int) []queue.Result {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var results []queue.Result
+	w.OnResult = func(r queue.Result) {
+		if results = append(results, r); len(results) == want {
+			cancel()
+		}
+	}
+	if err := w.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	return results
+}
+
+func TestWorker_RunsJobs(t *testing.T) {
+	provider := mock.New(mock.Text("one"), mock.Text("two"))
+	q := queue.NewChanQueue(4)
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		if err := q.Enqueue(ctx, queue.Job{ID: id, Agent: "echo", History: prompt(id)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := &queue.Worker{Queue: q, Agents: map[string]step.Agent{"echo": {Provider: provider}}}
+	results := work(t, w, 2)
+	var texts []string
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("job %s: %v", r.JobID, r.Err)
+		}
+		if r.Attempts != 1 {
+			t.Errorf("job %s: expected 1 attempt, got %d", r.JobID, r.Attempts)
+		}
+		texts = append(texts, r.Run.Text())
+	}
+	if !slices.Equal(texts, []string{"one", "two"}) {
+		t.Errorf("unexpected texts %q", texts)
+	}
+}
+
+func TestWorker_Retries(t *testing.T) {
+	failure := errors.New("overloaded")
+	provider := mock.New(mock.Response{Err: failure}, mock.Response{Err: failure}, mock.Text("done"))
+	q := queue.NewChanQueue(1)
+	if err := q.Enqueue(context.Background(), queue.Job{ID: "a", Agent: "echo", History: prompt("hi")}); err != nil {
+		t.Fatal(err)
+	}
+	w := &queue.Worker{
+		Queue:   q,
+		Agents:  map[string]step.Agent{"echo": {Provider: provider}},
+		Backoff: func(int) time.Duration { return time.Millisecond },
+	}
+	results := work(t, w, 1)
+	if r := results[0]; r.Err != nil || r.Attempts != 3 || r.Run.Text() != "done" {
+		t.Fatalf("unexpected result: attempts %d, text %q, err %v", r.Attempts, r.Run.Text(), r.Err)
+	}
+}
+
+func TestWorker_GivesUp(t *testing.T) {
+	failure := errors.New("overloaded")
+	provider := mock.New(mock.Response{Err: failure}, mock.Response{Err: failure}, mock.Text("unused"))
+	q := queue.NewChanQueue(2)
+	ctx := context.Background()
+	for _, job := range []queue.Job{
+		{ID: "a", Agent: "echo", History: prompt("hi")},
+		{ID: "b", Agent: "missing", History: prompt("hi")},
+	} {
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := &queue.Worker{
+		Queue:       q,
+		Agents:      map[string]step.Agent{"echo": {Provider: provider}},
+		MaxAttempts: 2,
+		Backoff:     func(int) time.Duration { return time.Millisecond },
+	}
+	results := work(t, w, 2)
+	byID := map[string]queue.Result{}
+	for _, r := range results {
+		byID[r.JobID] = r
+	}
+	if r := byID["a"]; !errors.Is(r.Err, failure) || r.Attempts != 2 {
+		t.Errorf("job a: expected failure after 2 attempts, got %d attempts, err %v", r.Attempts, r.Err)
+	}
+	if r := byID["b"]; !errors.Is(r.Err, queue.ErrUnknownAgent) || r.Attempts != 1 {
+		t.Errorf("job b: expected unknown agent on the first attempt, got %d attempts, err %v", r.Attempts, r.Err)
+	}
+	if provider.Remaining() != 1 {
+		t.Errorf("expected 1 unused response, got %d", provider.Remaining())
+	}
+}
+
+func TestWorker_Idempotent(t *testing.T) {
+	provider := mock.New(mock.Text("once"), mock.Text("twice"))
+	results := &queue.MemoryResults{}
+	agents := map[string]step.Agent{"echo": {Provider: provider}}
+	for range 2 {
+		q := queue.NewChanQueue(1)
+		if err := q.Enqueue(context.Background(), queue.Job{ID: "a", Agent: "echo", History: prompt("hi")}); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		w := &queue.Worker{Queue: q, Agents: agents, Results: results, OnResult: func(queue.Result) { cancel() }}
+		go func() {
+			for q.Len() > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+		}()
+		_ = w.Run(ctx)
+		cancel()
+	}
+	if provider.Remaining() != 1 {
+		t.Fatalf("expected the job to run once, %d responses left", provider.Remaining())
+	}
+	r, ok, _ := results.Get(context.Background(), "a")
+	if !ok || r.Run.Text() != "once" {
+		t.Fatalf("unexpected stored result %v %q", ok, r.Run.Text())
+	}
+}
+
+func TestChanQueue_DedupesPending(t *testing.T) {
+	q := queue.NewChanQueue(4)
+	ctx := context.Background()
+	for range 3 {
+		if err := q.Enqueue(ctx, queue.Job{ID: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.Len() != 1 {
+		t.Fatalf("expected 1 queued job, got %d", q.Len())
+	}
+	job, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ack(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue(ctx, queue.Job{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("expected the acknowledged ID to be queued again, got %d", q.Len())
+	}
+}
+
+func TestJob_JSON(t *testing.T) {
+	job := queue.Job{ID: "a", Agent: "echo", History: prompt("hi"), Attempt: 2}
+	data, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got queue.Job
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "a" || got.Agent != "echo" || got.Attempt != 2 || len(got.History) != 1 {
+		t.Fatalf("unexpected job %+v", got)
+	}
+	if text := got.History[0].(step.UserMessage).Parts[0].(step.TextPart).Text; text != "hi" {
+		t.Fatalf("unexpected prompt %q", text)
+	}
+}
+
+// fakeRedis is an in-memory RedisClient.
+type fakeRedis struct {
+	mu    sync.Mutex
+	lists map[string][]string
+}
+
+func (r *fakeRedis) LPush(_ context.Context, key, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lists == nil {
+		r.lists = make(map[string][]string)
+	}
+	r.lists[key] = append([]string{value}, r.lists[key]...)
+	return nil
+}
+
+func (r *fakeRedis) BLMove(ctx context.Context, src, dst string, timeout time.Duration) (string, bool, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		r.mu.Lock()
+		if n := len(r.lists[src]); n > 0 {
+			v := r.lists[src][n-1]
+			r.lists[src] = r.lists[src][:n-1]
+			r.lists[dst] = append([]string{v}, r.lists[dst]...)
+			r.mu.Unlock()
+			return v, true, nil
+		}
+		r.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	return "", false, nil
+}
+
+func (r *fakeRedis) LRem(_ context.Context, key, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := slices.Index(r.lists[key], value); i >= 0 {
+		r.lists[key] = slices.Delete(r.lists[key], i, i+1)
+	}
+	return nil
+}
+
+func (r *fakeRedis) len(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.lists[key])
+}
+
+func TestRedisQueue(t *testing.T) {
+	failure := errors.New("overloaded")
+	provider := mock.New(mock.Response{Err: failure}, mock.Text("done"))
+	redis := &fakeRedis{}
+	q := &queue.RedisQueue{Client: redis, Key: "jobs"}
+	if err := q.Enqueue(context.Background(), queue.Job{ID: "a", Agent: "echo", History: prompt("hi")}); err != nil {
+		t.Fatal(err)
+	}
+	w := &queue.Worker{
+		Queue:   q,
+		Agents:  map[string]step.Agent{"echo": {Provider: provider}},
+		Backoff: func(int) time.Duration { return time.Millisecond },
+	}
+	results := work(t, w, 1)
+	if r := results[0]; r.Err != nil || r.Attempts != 2 || r.Run.Text() != "done" {
+		t.Fatalf("unexpected result: attempts %d, text %q, err %v", r.Attempts, r.Run.Text(), r.Err)
+	}
+	if n := redis.len("jobs") + redis.len("jobs:processing"); n != 0 {
+		t.Fatalf("expected empty lists, %d jobs left", n)
+	}
+}
@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// RedisClient is the part of a Redis client RedisQueue uses. With
+// github.com/redis/go-redis it is a thin wrapper:
+//
+//	type client struct{ rdb *redis.Client }
+//
+//	func (c client) LPush(ctx context.Context, key, value string) error {
+//		return c.rdb.LPush(ctx, key, value).Err()
+//	}
+//
+//	func (c client) BLMove(ctx context.Context, src, dst string, timeout time.Duration) (string, bool, error) {
+//		v, err := c.rdb.BLMove(ctx, src, dst, "RIGHT", "LEFT", timeout).Result()
+//		if errors.Is(err, redis.Nil) {
+//			return "", false, nil
+//		}
+//		return v, err == nil, err
+//	}
+//
+//	func (c client) LRem(ctx context.Context, key, value string) error {
+//		return c.rdb.LRem(ctx, key, 1, value).Err()
+//	}
+type RedisClient interface {
+	LPush(ctx context.Context, key, value string) error
+	// BLMove moves the last element of src to the head of dst and returns
+	// it, waiting up to timeout for one; ok is false when none came.
+	BLMove(ctx context.Context, src, dst string, timeout time.Duration) (value string, ok bool, err error)
+	// LRem removes the first element equal to value from key.
+	LRem(ctx context.Context, key, value string) error
+}
+
+// RedisQueue is a Queue on Redis lists, with the reliable queue pattern: a
+// dequeued job moves atomically from the list Key to Key+":processing", and
+// leaves it on Ack or Nack. Jobs of a worker that crashed stay in the
+// processing list, for an operator or a sweeper to push back.
+//
+// It is a reference for adapters to other brokers, such as NATS JetStream,
+// whose acknowledgements and redelivery map onto Ack and Nack directly.
+type RedisQueue struct {
+	Client RedisClient
+	Key    string
+
+	mu  sync.Mutex
+	raw map[string]string // encoded form of dequeued jobs, by ID
+}
+
+var _ Queue = (*RedisQueue)(nil)
+
+func (q *RedisQueue) processing() string { return q.Key + ":processing" }
+
+func (q *RedisQueue) Enqueue(ctx context.Context, job Job) error {
+	job.Attempt = 0
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.Client.LPush(ctx, q.Key, string(data))
+}
+
+// Dequeue polls Redis once a second until a job arrives or ctx is done.
+func (q *RedisQueue) Dequeue(ctx context.Context) (Job, error) {
+	for {
+		value, ok, err := q.Client.BLMove(ctx, q.Key, q.processing(), time.Second)
+		if err != nil {
+			return Job{}, err
+		}
+		if !ok {
+			if err := ctx.Err(); err != nil {
+				return Job{}, err
+			}
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal([]byte(value), &job); err != nil {
+			_ = q.Client.LRem(ctx, q.processing(), value)
+			return Job{}, err
+		}
+		q.mu.Lock()
+		if q.raw == nil {
+			q.raw = make(map[string]string)
+		}
+		q.raw[job.ID] = value
+		q.mu.Unlock()
+		job.Attempt++
+		return job, nil
+	}
+}
+
+func (q *RedisQueue) Ack(ctx context.Context, job Job) error {
+	return q.Client.LRem(ctx, q.processing(), q.take(job.ID))
+}
+
+// Nack pushes job back after delay. The delay is kept in this process: a
+// crash before it ends leaves the job in the processing list.
+func (q *RedisQueue) Nack(ctx context.Context, job Job, delay time.Duration) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	raw := q.take(job.ID)
+	requeue := func(ctx context.Context) error {
+		if err := q.Client.LPush(ctx, q.Key, string(data)); err != nil {
+			return err
+		}
+		return q.Client.LRem(ctx, q.processing(), raw)
+	}
+	if delay <= 0 {
+		return requeue(ctx)
+	}
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(delay, func() { _ = requeue(ctx) })
+	return nil
+}
+
+func (q *RedisQueue) take(id string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	raw := q.raw[id]
+	delete(q.raw, id)
+	return raw
+}
@@ -0,0 +1,195 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded matches every *QuotaError with errors.Is.
+var ErrQuotaExceeded = errors.New("step: quota exceeded")
+
+// QuotaManager enforces usage limits, e.g. per tenant in a multi-tenant
+// server. See WithQuota and MemoryQuota.
+type QuotaManager interface {
+	// Check is called before each provider call, with the request about to
+	// be sent. An error, typically a *QuotaError, fails the step without
+	// calling the provider.
+	Check(ctx context.Context, req *ProviderRequest) error
+	// Record is called with the usage of each provider call that reported it.
+	Record(ctx context.Context, usage Usage)
+}
+
+// WithQuota makes the step consult q before calling the provider and report
+// the call's usage to it afterwards.
+func WithQuota(q QuotaManager) StepOption {
+	return func(c *stepConfig) { c.quota = q }
+}
+
+// QuotaResource names the resource a quota limits.
+type QuotaResource string
+
+// Resources limited by QuotaLimits.
+const (
+	QuotaTokens   QuotaResource = "tokens"
+	QuotaRequests QuotaResource = "requests"
+	QuotaCost     QuotaResource = "cost"
+)
+
+// QuotaError reports an exceeded quota.
+type QuotaError struct {
+	Tenant   string
+	Resource QuotaResource
+	Used     float64
+	Limit    float64
+	// ResetAt is when the quota window restarts; zero if it never does.
+	ResetAt time.Time
+}
+
+func (e *QuotaError) Error() string {
+	msg := fmt.Sprintf("step: %s quota exceeded for tenant %q (%g of %g)", e.Resource, e.Tenant, e.Used, e.Limit)
+	if !e.ResetAt.IsZero() {
+		msg += ", resets at " + e.ResetAt.Format(time.RFC3339)
+	}
+	return msg
+}
+
+// Is reports whether target is ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool { return target == ErrQuotaExceeded }
+
+// QuotaLimits are the limits of one tenant. Zero fields are unlimited.
+type QuotaLimits struct {
+	Tokens   int
+	Requests int
+	// Cost is in USD, as reported in Usage.Cost.
+	Cost float64
+	// Window is the period after which usage resets, counted from the first
+	// request of the window. Zero means usage never resets.
+	Window time.Duration
+}
+
+// QuotaUsage is the usage of one tenant in its current window.
+type QuotaUsage struct {
+	Tokens   int
+	Requests int
+	Cost     float64
+	Start    time.Time
+}
+
+// MemoryQuota is an in-memory QuotaManager for a single process. Tenants are
+// identified by TenantID, falling back to UserID; calls with neither are
+// charged to the "" tenant. Token and cost limits are checked against usage
+// already recorded, so the call that crosses a limit completes and the next
+// one is refused. MemoryQuota is safe for concurrent use.
+type MemoryQuota struct {
+	mu       sync.Mutex
+	defaults QuotaLimits
+	limits   map[string]QuotaLimits
+	usage    map[string]*QuotaUsage
+}
+
+var _ QuotaManager = (*MemoryQuota)(nil)
+
+// NewMemoryQuota creates a MemoryQuota applying defaults to every tenant
+// without limits of its own.
+func NewMemoryQuota(defaults QuotaLimits) *MemoryQuota {
+	return &MemoryQuota{
+		defaults: defaults,
+		limits:   make(map[string]QuotaLimits),
+		usage:    make(map[string]*QuotaUsage),
+	}
+}
+
+// SetLimits sets the limits of tenant, replacing the defaults.
+func (q *MemoryQuota) SetLimits(tenant string, limits QuotaLimits) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limits[tenant] = limits
+}
+
+// Usage returns the usage of tenant in its current window.
+func (q *MemoryQuota) Usage(tenant string) QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *q.current(tenant)
+}
+
+// Reset clears the usage of tenant.
+func (q *MemoryQuota) Reset(tenant string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.usage, tenant)
+}
+
+// Check counts the request and refuses it if any limit of the tenant is reached.
+func (q *MemoryQuota) Check(ctx context.Context, _ *ProviderRequest) error {
+	tenant := quotaTenant(ctx)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	limits := q.limitsFor(tenant)
+	u := q.current(tenant)
+	if u.Start.IsZero() {
+		u.Start = time.Now()
+	}
+	exceeded := func(resource QuotaResource, used, limit float64) error {
+		err := &QuotaError{Tenant: tenant, Resource: resource, Used: used, Limit: limit}
+		if limits.Window > 0 {
+			err.ResetAt = u.Start.Add(limits.Window)
+		}
+		return err
+	}
+	switch {
+	case limits.Requests > 0 && u.Requests >= limits.Requests:
+		return exceeded(QuotaRequests, float64(u.Requests), float64(limits.Requests))
+	case limits.Tokens > 0 && u.Tokens >= limits.Tokens:
+		return exceeded(QuotaTokens, float64(u.Tokens), float64(limits.Tokens))
+	case limits.Cost > 0 && u.Cost >= limits.Cost:
+		return exceeded(QuotaCost, u.Cost, limits.Cost)
+	}
+	u.Requests++
+	return nil
+}
+
+// Record adds usage to the tenant's current window.
+func (q *MemoryQuota) Record(ctx context.Context, usage Usage) {
+	tenant := quotaTenant(ctx)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.current(tenant)
+	tokens := usage.TotalTokens
+	if tokens == 0 {
+		tokens = usage.InputTokens + usage.OutputTokens
+	}
+	u.Tokens += tokens
+	u.Cost += usage.Cost
+}
+
+func (q *MemoryQuota) limitsFor(tenant string) QuotaLimits {
+	if l, ok := q.limits[tenant]; ok {
+		return l
+	}
+	return q.defaults
+}
+
+// current returns the usage of tenant, starting a new window if the last one
+// has expired. The caller must hold q.mu.
+func (q *MemoryQuota) current(tenant string) *QuotaUsage {
+	u, ok := q.usage[tenant]
+	if !ok {
+		u = &QuotaUsage{}
+		q.usage[tenant] = u
+	}
+	if w := q.limitsFor(tenant).Window; w > 0 && !u.Start.IsZero() && !time.Now().Before(u.Start.Add(w)) {
+		*u = QuotaUsage{}
+	}
+	return u
+}
+
+func quotaTenant(ctx context.Context) string {
+	if id := TenantID(ctx); id != "" {
+		return id
+	}
+	return UserID(ctx)
+}
@@ -0,0 +1,68 @@
+package step_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestMemoryQuota(t *testing.T) {
+	quota := step.NewMemoryQuota(step.QuotaLimits{Requests: 2})
+	quota.SetLimits("acme", step.QuotaLimits{Tokens: 10})
+	provider := mock.New()
+	for range 6 {
+		provider.Push(withUsage(mock.Text("ok"), 6))
+	}
+	call := func(ctx context.Context) error {
+		_, err := step.Step(ctx, step.StepRequest{Provider: provider}, step.WithQuota(quota))
+		return err
+	}
+
+	alice := step.WithUserID(context.Background(), "alice")
+	for i := range 2 {
+		if err := call(alice); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	var qerr *step.QuotaError
+	if err := call(alice); !errors.As(err, &qerr) || !errors.Is(err, step.ErrQuotaExceeded) || qerr.Resource != step.QuotaRequests || qerr.Tenant != "alice" {
+		t.Fatalf("expected a requests quota error for alice, got %v", err)
+	}
+
+	// The tenant takes precedence over the user, and has its own limits.
+	acme := step.WithTenantID(alice, "acme")
+	for i := range 2 {
+		if err := call(acme); err != nil {
+			t.Fatalf("acme request %d: %v", i, err)
+		}
+	}
+	if err := call(acme); !errors.As(err, &qerr) || qerr.Resource != step.QuotaTokens || qerr.Used != 12 {
+		t.Fatalf("expected a tokens quota error for acme, got %v", err)
+	}
+	if u := quota.Usage("acme"); u.Requests != 2 || u.Tokens != 12 {
+		t.Errorf("unexpected acme usage: %+v", u)
+	}
+	if provider.Remaining() != 2 {
+		t.Errorf("expected refused calls not to reach the provider, %d responses left", provider.Remaining())
+	}
+}
+
+func TestMemoryQuota_Window(t *testing.T) {
+	quota := step.NewMemoryQuota(step.QuotaLimits{Requests: 1, Window: 20 * time.Millisecond})
+	ctx := step.WithUserID(context.Background(), "bob")
+	if err := quota.Check(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	var qerr *step.QuotaError
+	if err := quota.Check(ctx, nil); !errors.As(err, &qerr) || qerr.ResetAt.IsZero() {
+		t.Fatalf("expected a quota error with a reset time, got %v", err)
+	}
+	time.Sleep(time.Until(qerr.ResetAt))
+	if err := quota.Check(ctx, nil); err != nil {
+		t.Fatalf("expected the quota to reset, got %v", err)
+	}
+}
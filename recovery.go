@@ -0,0 +1,74 @@
+package step
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// DetailsRecovery is the ToolResult.Details key of a hint, as a string, on
+// what the model should try after an error result, e.g. "Read the file to
+// get its current content, then retry the edit." Step appends the hint to the
+// result text in the same format for every tool.
+const DetailsRecovery = "recovery"
+
+// recoveryPrefix introduces the recovery hint in the result text.
+const recoveryPrefix = "What to try next: "
+
+// RecoveryError is an error returned by Tool.Execute with a recovery hint.
+// The error result of the call carries Hint under DetailsRecovery.
+type RecoveryError struct {
+	Err  error
+	Hint string
+}
+
+func (e *RecoveryError) Error() string { return e.Err.Error() }
+func (e *RecoveryError) Unwrap() error { return e.Err }
+
+// ToolRecovery returns a recovery hint for an error result that has none, or
+// "" to leave it without. It may classify the errors of tools that do not
+// give hints themselves.
+type ToolRecovery func(call ToolCallPart, res ToolResult) string
+
+// WithToolRecovery asks classify for a hint for each error result of a tool
+// without one. See DetailsRecovery.
+func WithToolRecovery(classify ToolRecovery) StepOption {
+	return func(c *stepConfig) { c.toolExec.recovery = classify }
+}
+
+// withRecovery sets the recovery hint of an error result, asking classify when
+// the tool gave none, and appends it to the text of res.
+func withRecovery(call ToolCallPart, res ToolResult, err error, classify ToolRecovery) ToolResult {
+	if !res.IsError {
+		return res
+	}
+	hint, _ := res.Details[DetailsRecovery].(string)
+	if re := (*RecoveryError)(nil); hint == "" && errors.As(err, &re) {
+		hint = re.Hint
+	}
+	if hint == "" && classify != nil {
+		hint = classify(call, res)
+	}
+	if hint == "" {
+		return res
+	}
+
+	details := make(map[string]any, len(res.Details)+1)
+	for k, v := range res.Details {
+		details[k] = v
+	}
+	details[DetailsRecovery] = hint
+	res.Details = details
+
+	parts := slices.Clone(res.Parts)
+	if n := len(parts); n > 0 {
+		if text, ok := parts[n-1].(TextPart); ok {
+			text.Text = strings.TrimRight(text.Text, "\n") + "\n\n" + recoveryPrefix + hint
+			parts[n-1] = text
+			res.Parts = parts
+			return res
+		}
+	}
+	res.Parts = append(parts, TextPart{Text: recoveryPrefix + hint})
+	return res
+}
@@ -0,0 +1,82 @@
+package step
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownProvider is returned by GetProvider for names that were never registered.
+var ErrUnknownProvider = errors.New("step: unknown provider")
+
+// ProviderFactory creates a registered provider on first use.
+type ProviderFactory func() (Provider, error)
+
+type registeredProvider struct {
+	mu       sync.Mutex
+	factory  ProviderFactory
+	provider Provider
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*registeredProvider)
+)
+
+// RegisterProvider registers factory under name, so code on top of step can
+// refer to providers by role ("fast", "smart", "cheap") and the
+// implementation is chosen in one place. The factory runs on the first
+// GetProvider call for name. Registering a name again replaces it; providers
+// already returned by GetProvider are unaffected.
+func RegisterProvider(name string, factory ProviderFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = &registeredProvider{factory: factory}
+}
+
+// RegisterProviderInstance registers an already constructed provider under name.
+func RegisterProviderInstance(name string, provider Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = &registeredProvider{provider: provider}
+}
+
+// GetProvider returns the provider registered under name, constructing it on
+// first use. Later calls return the same Provider. If the factory fails, the
+// error is returned and the next call tries again.
+func GetProvider(name string) (Provider, error) {
+	registryMu.RLock()
+	r, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownProvider, name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.provider != nil {
+		return r.provider, nil
+	}
+	provider, err := r.factory()
+	if err != nil {
+		return nil, fmt.Errorf("step: provider %q: %w", name, err)
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("step: provider %q: factory returned nil", name)
+	}
+	r.provider = provider
+	return provider, nil
+}
+
+// RegisteredProviders returns the registered provider names, sorted.
+func RegisteredProviders() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,64 @@
+package step_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestRegistry_Lazy(t *testing.T) {
+	var calls int
+	fail := true
+	step.RegisterProvider("registry-lazy", func() (step.Provider, error) {
+		calls++
+		if fail {
+			return nil, errors.New("no credentials")
+		}
+		return mock.New(), nil
+	})
+	if calls != 0 {
+		t.Fatal("expected the factory not to run at registration")
+	}
+	if _, err := step.GetProvider("registry-lazy"); err == nil {
+		t.Fatal("expected the factory error")
+	}
+
+	fail = false
+	var wg sync.WaitGroup
+	providers := make([]step.Provider, 8)
+	for i := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			providers[i], _ = step.GetProvider("registry-lazy")
+		}()
+	}
+	wg.Wait()
+	if calls != 2 {
+		t.Errorf("expected one retry after the failure, got %d factory calls", calls)
+	}
+	for _, p := range providers {
+		if p == nil || p != providers[0] {
+			t.Fatalf("expected every caller to get the same provider, got %v", providers)
+		}
+	}
+}
+
+func TestRegistry_Replace(t *testing.T) {
+	first, second := mock.New(), mock.New()
+	step.RegisterProviderInstance("registry-swap", first)
+	step.RegisterProviderInstance("registry-swap", second)
+	if p, err := step.GetProvider("registry-swap"); err != nil || p != step.Provider(second) {
+		t.Errorf("expected the replacement provider, got %v (%v)", p, err)
+	}
+	if !slices.Contains(step.RegisteredProviders(), "registry-swap") {
+		t.Errorf("expected registry-swap in %v", step.RegisteredProviders())
+	}
+	if _, err := step.GetProvider("registry-missing"); !errors.Is(err, step.ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got %v", err)
+	}
+}
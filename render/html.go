@@ -0,0 +1,96 @@
+package render
+
+import (
+	"html"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+const htmlStyle = `body{font-family:system-ui,sans-serif;max-width:860px;margin:2em auto;padding:0 1em;line-height:1.5}
+section{border-left:4px solid #ccc;padding:0 1em;margin:1.5em 0}
+section.user{border-color:#3b82f6}section.assistant{border-color:#10b981}section.tool{border-color:#a855f7}section.error{border-color:#ef4444}
+h3{margin:.5em 0}pre{background:#f6f8fa;padding:.75em;overflow-x:auto;white-space:pre-wrap}
+details{color:#555;margin:.5em 0}img{max-width:100%}.text{white-space:pre-wrap}`
+
+// HTML renders msgs as a self-contained HTML document. Thinking is collapsed
+// in <details> elements and images are embedded as data URIs.
+func HTML(msgs []step.Message, opts ...Option) string {
+	o := newOptions(opts)
+	title := o.title
+	if title == "" {
+		title = "Transcript"
+	}
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	sb.WriteString("<style>\n" + htmlStyle + "\n</style>\n</head>\n<body>\n")
+	if o.title != "" {
+		sb.WriteString("<h1>" + html.EscapeString(o.title) + "</h1>\n")
+	}
+	for _, msg := range msgs {
+		sb.WriteString("<section class=\"" + sectionClass(msg) + "\">\n")
+		sb.WriteString("<h3>" + html.EscapeString(heading(msg)) + "</h3>\n")
+		_, isTool := msg.(step.ToolResultMessage)
+		var citations []step.CitationPart
+		for _, part := range messageParts(msg) {
+			switch p := part.(type) {
+			case step.TextPart:
+				if isTool {
+					sb.WriteString("<pre>" + html.EscapeString(p.Text) + "</pre>\n")
+				} else if p.Text != "" {
+					sb.WriteString("<div class=\"text\">" + html.EscapeString(p.Text) + "</div>\n")
+				}
+			case step.ThinkingPart:
+				if o.thinking && p.Thinking != "" {
+					sb.WriteString("<details><summary>Thinking</summary><div class=\"text\">" + html.EscapeString(p.Thinking) + "</div></details>\n")
+				}
+			case step.ImagePart:
+				if o.images {
+					sb.WriteString("<img alt=\"image\" src=\"" + html.EscapeString(dataURI(p)) + "\">\n")
+				} else {
+					sb.WriteString("<p>" + html.EscapeString(imagePlaceholder(p)) + "</p>\n")
+				}
+			case step.AudioPart:
+				sb.WriteString("<p><em>(audio)</em> " + html.EscapeString(p.Transcript) + "</p>\n")
+			case step.ToolCallPart:
+				sb.WriteString("<p><strong>Tool call</strong> <code>" + html.EscapeString(p.Name) + "</code> (<code>" + html.EscapeString(p.CallID) + "</code>)</p>\n")
+				sb.WriteString("<pre>" + html.EscapeString(prettyArgs(p.ArgsJSON)) + "</pre>\n")
+			case step.CitationPart:
+				citations = append(citations, p)
+			case step.OpaquePart:
+				sb.WriteString("<p><em>(unsupported " + html.EscapeString(string(p.Type)) + " part)</em></p>\n")
+			}
+		}
+		if len(citations) > 0 {
+			sb.WriteString("<p>Sources:</p>\n<ul>\n")
+			for _, c := range citations {
+				title := c.Title
+				if title == "" {
+					title = c.URL
+				}
+				sb.WriteString("<li><a href=\"" + html.EscapeString(c.URL) + "\">" + html.EscapeString(title) + "</a></li>\n")
+			}
+			sb.WriteString("</ul>\n")
+		}
+		sb.WriteString("</section>\n")
+	}
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
+
+func sectionClass(msg step.Message) string {
+	switch m := msg.(type) {
+	case step.UserMessage:
+		return "user"
+	case step.AssistantMessage:
+		return "assistant"
+	case step.ToolResultMessage:
+		if m.IsError {
+			return "tool error"
+		}
+		return "tool"
+	default:
+		return ""
+	}
+}
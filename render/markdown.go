@@ -0,0 +1,71 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+// Markdown renders msgs as a Markdown document. Thinking is wrapped in
+// <details> blocks, which GitHub and most viewers render collapsed.
+func Markdown(msgs []step.Message, opts ...Option) string {
+	o := newOptions(opts)
+	var sb strings.Builder
+	if o.title != "" {
+		sb.WriteString("# " + o.title + "\n\n")
+	}
+	for i, msg := range msgs {
+		if i > 0 {
+			sb.WriteString("---\n\n")
+		}
+		sb.WriteString("### " + heading(msg) + "\n\n")
+		_, isTool := msg.(step.ToolResultMessage)
+		var citations []step.CitationPart
+		for _, part := range messageParts(msg) {
+			switch p := part.(type) {
+			case step.TextPart:
+				if isTool {
+					writeCode(&sb, "text", p.Text)
+				} else if p.Text != "" {
+					sb.WriteString(p.Text + "\n\n")
+				}
+			case step.ThinkingPart:
+				if o.thinking && p.Thinking != "" {
+					sb.WriteString("<details>\n<summary>Thinking</summary>\n\n" + p.Thinking + "\n\n</details>\n\n")
+				}
+			case step.ImagePart:
+				if o.images {
+					sb.WriteString("![image](" + dataURI(p) + ")\n\n")
+				} else {
+					sb.WriteString(imagePlaceholder(p) + "\n\n")
+				}
+			case step.AudioPart:
+				sb.WriteString("*(audio)* " + p.Transcript + "\n\n")
+			case step.ToolCallPart:
+				sb.WriteString("**Tool call** `" + p.Name + "` (`" + p.CallID + "`)\n\n")
+				writeCode(&sb, "json", prettyArgs(p.ArgsJSON))
+			case step.CitationPart:
+				citations = append(citations, p)
+			case step.OpaquePart:
+				sb.WriteString("*(unsupported " + string(p.Type) + " part)*\n\n")
+			}
+		}
+		if len(citations) > 0 {
+			sb.WriteString("Sources:\n\n")
+			for _, c := range citations {
+				title := c.Title
+				if title == "" {
+					title = c.URL
+				}
+				sb.WriteString("- [" + title + "](" + c.URL + ")\n")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func writeCode(sb *strings.Builder, lang, code string) {
+	f := fence(code)
+	sb.WriteString(f + lang + "\n" + strings.TrimRight(code, "\n") + "\n" + f + "\n\n")
+}
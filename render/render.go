@@ -0,0 +1,117 @@
+// Package render converts transcripts into readable Markdown or HTML for logs,
+// reviews of agent runs and bug reports. Thinking is collapsed, tool calls are
+// shown with their arguments and results, and images are embedded inline.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+// Option configures rendering.
+type Option func(*options)
+
+type options struct {
+	thinking bool
+	images   bool
+	title    string
+}
+
+func newOptions(opts []Option) options {
+	o := options{thinking: true, images: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithoutThinking omits thinking parts.
+func WithoutThinking() Option {
+	return func(o *options) { o.thinking = false }
+}
+
+// WithoutImages replaces images with a short placeholder, keeping output small.
+func WithoutImages() Option {
+	return func(o *options) { o.images = false }
+}
+
+// WithTitle sets the document title (a heading in Markdown, <title> in HTML).
+func WithTitle(title string) Option {
+	return func(o *options) { o.title = title }
+}
+
+// heading returns the section heading for msg.
+func heading(msg step.Message) string {
+	switch m := msg.(type) {
+	case step.UserMessage:
+		return "User"
+	case step.AssistantMessage:
+		h := "Assistant"
+		if m.Provenance != nil && m.Provenance.Model != "" {
+			h += " (" + m.Provenance.Model + ")"
+		}
+		if m.StopReason != "" && m.StopReason != step.StopStop && m.StopReason != step.StopToolUse {
+			h += " [" + string(m.StopReason) + "]"
+		}
+		return h
+	case step.ToolResultMessage:
+		h := "Tool result: " + m.Name
+		if m.IsError {
+			h += " [error]"
+		}
+		return h
+	default:
+		return fmt.Sprintf("%T", msg)
+	}
+}
+
+func messageParts(msg step.Message) []step.Part {
+	switch m := msg.(type) {
+	case step.UserMessage:
+		return m.Parts
+	case step.AssistantMessage:
+		return m.Parts
+	case step.ToolResultMessage:
+		return m.Parts
+	default:
+		return nil
+	}
+}
+
+// prettyArgs indents tool arguments, falling back to the raw text if they are not valid JSON.
+func prettyArgs(args json.RawMessage) string {
+	if len(args) == 0 {
+		return "{}"
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, args, "", "  "); err != nil {
+		return string(args)
+	}
+	return buf.String()
+}
+
+func imagePlaceholder(p step.ImagePart) string {
+	return fmt.Sprintf("[image: %s, %d bytes]", p.MimeType, len(p.DataB64)*3/4)
+}
+
+func dataURI(p step.ImagePart) string {
+	return "data:" + p.MimeType + ";base64," + p.DataB64
+}
+
+// fence returns a code fence longer than any backtick run in s.
+func fence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
@@ -0,0 +1,81 @@
+package render_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/render"
+	"github.com/inspirepan/step/testkit"
+)
+
+func transcript() []step.Message {
+	return []step.Message{
+		step.UserMessage{Parts: []step.Part{
+			step.TextPart{Text: "What is in this picture?"},
+			step.ImagePart{MimeType: "image/png", DataB64: "iVBORw0KGgo="},
+		}},
+		step.AssistantMessage{
+			Parts: []step.Part{
+				step.ThinkingPart{Thinking: "I should look at the file first."},
+				step.TextPart{Text: "Let me check."},
+				step.ToolCallPart{CallID: "call_1", Name: "read_file", ArgsJSON: json.RawMessage(`{"path":"a.go"}`)},
+			},
+			StopReason: step.StopToolUse,
+			Provenance: &step.Provenance{Model: "test-model"},
+		},
+		step.ToolResultMessage{CallID: "call_1", Name: "read_file", Parts: []step.Part{step.TextPart{Text: "```go\npackage a\n```"}}},
+		step.AssistantMessage{
+			Parts: []step.Part{
+				step.TextPart{Text: "It is a Go file <a.go>."},
+				step.CitationPart{URL: "https://go.dev", Title: "Go"},
+			},
+			StopReason: step.StopStop,
+		},
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	got := render.Markdown(transcript(), render.WithTitle("Run 1"))
+	path := filepath.Join("testdata", "transcript.md")
+	if os.Getenv(testkit.UpdateGoldenEnv) != "" {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden: %v (set %s=1 to create it)", err, testkit.UpdateGoldenEnv)
+	}
+	if got != string(want) {
+		t.Errorf("markdown mismatch:\n%s", got)
+	}
+}
+
+func TestMarkdownOptions(t *testing.T) {
+	got := render.Markdown(transcript(), render.WithoutThinking(), render.WithoutImages())
+	if strings.Contains(got, "Thinking") || strings.Contains(got, "base64") {
+		t.Errorf("expected thinking and images to be omitted:\n%s", got)
+	}
+	if !strings.Contains(got, "[image: image/png, 9 bytes]") {
+		t.Errorf("expected image placeholder:\n%s", got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	got := render.HTML(transcript())
+	for _, want := range []string{
+		"<details><summary>Thinking</summary>",
+		`<img alt="image" src="data:image/png;base64,iVBORw0KGgo=">`,
+		"It is a Go file &lt;a.go&gt;.",
+		`<a href="https://go.dev">Go</a>`,
+		"&#34;path&#34;: &#34;a.go&#34;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in HTML output", want)
+		}
+	}
+}
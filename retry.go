@@ -0,0 +1,102 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy retries tool calls that failed transiently, such as a request
+// of a network tool that timed out, before the model sees the failure. It is
+// separate from retries of provider requests. Set it only for idempotent
+// tools: a retried call may have had effects the first time.
+type RetryPolicy struct {
+	// MaxAttempts is the number of executions of a call, including the
+	// first. Values below 2 disable retrying.
+	MaxAttempts int
+	// Backoff returns the wait before the given retry, counted from 1.
+	// Defaults to ExponentialBackoff(200*time.Millisecond, 5*time.Second).
+	Backoff func(retry int) time.Duration
+	// RetryOn reports whether a failed execution is worth retrying. Defaults
+	// to errors returned by Execute, except cancellation; results with
+	// IsError set are final.
+	RetryOn func(res ToolResult, err error) bool
+}
+
+// ExponentialBackoff returns a RetryPolicy.Backoff that waits base before the
+// first retry and doubles the wait for each next one, up to limit.
+func ExponentialBackoff(base, limit time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < limit; i++ {
+			d *= 2
+		}
+		return min(d, limit)
+	}
+}
+
+var defaultToolBackoff = ExponentialBackoff(200*time.Millisecond, 5*time.Second)
+
+// WithToolRetry retries calls of the named tools with policy, in place of
+// their ToolSpec.Retry. Without names it applies to every tool whose spec
+// declares AccessReadOnly and no policy of its own.
+func WithToolRetry(policy RetryPolicy, names ...string) StepOption {
+	return func(c *stepConfig) {
+		if len(names) == 0 {
+			c.toolExec.readOnlyRetry = &policy
+			return
+		}
+		if c.toolExec.retry == nil {
+			c.toolExec.retry = make(map[string]RetryPolicy)
+		}
+		for _, name := range names {
+			c.toolExec.retry[name] = policy
+		}
+	}
+}
+
+// retryPolicy returns the policy for calls of the tool with spec, if any.
+func (c toolExecConfig) retryPolicy(spec ToolSpec) (RetryPolicy, bool) {
+	if p, ok := c.retry[spec.Name]; ok {
+		return p, p.MaxAttempts > 1
+	}
+	if spec.Retry != nil {
+		return *spec.Retry, spec.Retry.MaxAttempts > 1
+	}
+	if c.readOnlyRetry != nil && spec.Access == AccessReadOnly {
+		return *c.readOnlyRetry, c.readOnlyRetry.MaxAttempts > 1
+	}
+	return RetryPolicy{}, false
+}
+
+func (p RetryPolicy) retryOn(res ToolResult, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if p.RetryOn != nil {
+		return p.RetryOn(res, err)
+	}
+	return err != nil
+}
+
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(retry)
+	}
+	return defaultToolBackoff(retry)
+}
+
+// wait sleeps for d, reporting false when ctx is done first.
+func wait(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,281 @@
+package step
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxSteps = 10
+
+// ErrMaxSteps is returned by Run when the agent still calls tools after MaxSteps steps.
+var ErrMaxSteps = errors.New("step: max steps reached")
+
+// DetailsUsage is the ToolResult.Details key under which tools that call models,
+// such as Agent.AsTool, report their Usage. Run adds it to RunResult.Usage.
+const DetailsUsage = "usage"
+
+// MetadataSteering is set to true on user messages Run took from a Steering queue.
+const MetadataSteering = "step.steering"
+
+// Agent is a provider with its instructions and tools, run by Run.
+type Agent struct {
+	// Name identifies the agent, e.g. as a tool name in AsTool or for routing.
+	Name string
+	// Description says what the agent is for; used as the AsTool description.
+	Description  string
+	Provider     Provider
+	SystemPrompt string
+	Tools        []Tool
+	// MaxSteps bounds the agent loop. Defaults to 10.
+	MaxSteps int
+}
+
+// RunResult is the outcome of Run.
+type RunResult struct {
+	// RunID identifies the run; tools read it with RunID. A resumed run keeps
+	// its ID.
+	RunID string
+	// Messages are the messages produced by the run, excluding the input history.
+	Messages []Message
+	// Usage sums the usage of every assistant message and of tool results
+	// reporting DetailsUsage, so nested agents are included.
+	Usage Usage
+	Steps int
+	// State is set when the run returns ErrPaused; pass it to Resume.
+	State *RunState
+}
+
+// Text returns the text of the last assistant message.
+func (r RunResult) Text() string {
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if m, ok := r.Messages[i].(AssistantMessage); ok {
+			return m.Text()
+		}
+	}
+	return ""
+}
+
+// Run runs agent steps on history until the model stops calling tools and no
+// steering messages are queued (see WithSteering).
+// On error the result holds everything produced so far.
+func Run(ctx context.Context, agent Agent, history []Message, opts ...StepOption) (RunResult, error) {
+	cfg := newStepConfig(opts)
+	runID := NewRunID()
+	return runLoop(ctx, agent, append([]Message(nil), history...), RunState{RunID: runID}, RunResult{RunID: runID}, cfg, opts)
+}
+
+// runLoop steps agent on history, adding to res. prior holds the run ID and
+// the steps and usage of the run before a pause.
+func runLoop(ctx context.Context, agent Agent, history []Message, prior RunState, res RunResult, cfg stepConfig, opts []StepOption) (RunResult, error) {
+	maxSteps := agent.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultMaxSteps
+	}
+	paused := func() (RunResult, error) {
+		state := RunState{RunID: prior.RunID, History: history, Steps: prior.Steps + res.Steps, Usage: prior.Usage, Model: cfg.model, ReasoningEffort: cfg.effort}
+		state.Usage.Add(&res.Usage)
+		res.State = &state
+		return res, ErrPaused
+	}
+	for {
+		if cfg.pauser.take() || awaitingResults(history) {
+			return paused()
+		}
+		if prior.Steps+res.Steps >= maxSteps {
+			return res, ErrMaxSteps
+		}
+		steered := cfg.steering.take(history, cfg.stepEmitter)
+		history = append(history, steered...)
+		res.Messages = append(res.Messages, steered...)
+
+		stepCtx := withRunStep(ctx, prior.RunID, prior.Steps+res.Steps)
+		result, err := Step(stepCtx, StepRequest{
+			Provider:     agent.Provider,
+			SystemPrompt: agent.SystemPrompt,
+			History:      history,
+			Tools:        agent.Tools,
+		}, opts...)
+		res.Steps++
+		history = append(history, result...)
+		res.add(result)
+		if errors.Is(err, ErrPaused) {
+			return paused()
+		}
+		if err != nil {
+			return res, err
+		}
+		if !result.HasToolCall() && cfg.steering.Pending() == 0 {
+			// A pause requested as the run finishes has nothing left to pause.
+			cfg.pauser.take()
+			return res, nil
+		}
+	}
+}
+
+// add appends msgs to r, adding their usage.
+func (r *RunResult) add(msgs []Message) {
+	r.Messages = append(r.Messages, msgs...)
+	for _, msg := range msgs {
+		switch m := msg.(type) {
+		case AssistantMessage:
+			r.Usage.Add(m.Usage)
+		case ToolResultMessage:
+			r.Usage.Add(UsageFromDetails(m.Details))
+		}
+	}
+}
+
+// Steering queues user messages for a Run in progress, so the user of an
+// interactive agent can redirect it without interrupting the current step.
+// Run adds queued messages to the history before its next provider call, and
+// keeps going if the model had stopped. Messages sent after Run returns stay
+// queued for the next Run. The zero value is ready to use; a Steering is safe
+// for concurrent use.
+type Steering struct {
+	mu    sync.Mutex
+	queue []UserMessage
+}
+
+// WithSteering makes Run take messages queued on s. Each message is reported
+// to the message hook when it enters the history, with MetadataSteering set,
+// acknowledging it. Step ignores it.
+func WithSteering(s *Steering) StepOption {
+	return func(c *stepConfig) { c.steering = s }
+}
+
+// Send queues msg for the next provider call.
+func (s *Steering) Send(msg UserMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, msg)
+}
+
+// Pending returns the number of queued messages.
+func (s *Steering) Pending() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// take removes the queued messages and prepares them to follow history.
+func (s *Steering) take(history []Message, emitter stepEmitter) []Message {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	queue := s.queue
+	s.queue = nil
+	s.mu.Unlock()
+	if len(queue) == 0 {
+		return nil
+	}
+
+	parentID := ""
+	if len(history) > 0 {
+		parentID = MessageID(history[len(history)-1])
+	}
+	out := make([]Message, 0, len(queue))
+	for _, msg := range queue {
+		if msg.ID == "" {
+			msg.ID = NewMessageID()
+		}
+		if msg.ParentID == "" {
+			msg.ParentID = parentID
+		}
+		parentID = msg.ID
+		if msg.Timestamp == 0 {
+			msg.Timestamp = time.Now().UnixMilli()
+		}
+		msg.Metadata = msg.Metadata.Clone()
+		msg.Metadata.Set(MetadataSteering, true)
+		emitter.message(msg)
+		out = append(out, msg)
+	}
+	return out
+}
+
+// UsageFromDetails returns the Usage stored under DetailsUsage, or nil. It
+// accepts both the original value and the form decoded from JSON.
+func UsageFromDetails(details map[string]any) *Usage {
+	switch v := details[DetailsUsage].(type) {
+	case nil:
+		return nil
+	case Usage:
+		return &v
+	case *Usage:
+		return v
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var u Usage
+		if err := json.Unmarshal(raw, &u); err != nil {
+			return nil
+		}
+		return &u
+	}
+}
+
+// AsTool exposes the agent as a tool another agent can delegate to. The tool
+// takes a self-contained task, runs the agent on it in a fresh conversation and
+// returns its final answer. Sub-agent usage is reported under DetailsUsage.
+// The tool is parallel, so independent sub-tasks run concurrently.
+func (a Agent) AsTool() Tool {
+	return agentTool{agent: a}
+}
+
+type agentTool struct {
+	agent Agent
+}
+
+func (t agentTool) Spec() ToolSpec {
+	desc := t.agent.Description
+	if desc == "" {
+		desc = "Delegate a task to the " + t.agent.Name + " agent."
+	}
+	return ToolSpec{
+		Name:        t.agent.Name,
+		Description: desc,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"task": map[string]any{
+					"type":        "string",
+					"description": "The complete task, including all context the agent needs; it cannot see this conversation",
+				},
+			},
+			"required": []string{"task"},
+		},
+		Parallel: true,
+	}
+}
+
+func (t agentTool) Execute(ctx context.Context, call ToolCallPart) (ToolResult, error) {
+	var args struct {
+		Task string `json:"task"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil || strings.TrimSpace(args.Task) == "" {
+		return ToolResult{IsError: true, Parts: []Part{TextPart{Text: t.agent.Name + " requires a non-empty task"}}}, nil
+	}
+	res, err := Run(ctx, t.agent, []Message{UserMessage{Parts: []Part{TextPart{Text: args.Task}}}})
+	details := map[string]any{DetailsUsage: res.Usage, "steps": res.Steps}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ToolResult{}, err
+		}
+		return ToolResult{IsError: true, Parts: []Part{TextPart{Text: t.agent.Name + " failed: " + err.Error()}}, Details: details}, nil
+	}
+	text := res.Text()
+	if text == "" {
+		text = "(no answer)"
+	}
+	return ToolResult{Parts: []Part{TextPart{Text: text}}, Details: details}, nil
+}
@@ -0,0 +1,166 @@
+package step_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func withUsage(r mock.Response, output int) mock.Response {
+	r.Message.Usage = &step.Usage{OutputTokens: output, TotalTokens: output}
+	return r
+}
+
+func TestRun_AgentAsTool(t *testing.T) {
+	researcher := step.Agent{
+		Name:        "researcher",
+		Description: "Looks things up.",
+		Provider:    mock.New(withUsage(mock.Text("Paris"), 3)),
+	}
+	args, _ := json.Marshal(map[string]string{"task": "What is the capital of France?"})
+	lead := step.Agent{
+		Name: "lead",
+		Provider: mock.New(
+			withUsage(mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "researcher", ArgsJSON: args}), 5),
+			withUsage(mock.Text("The capital is Paris."), 7),
+		),
+		Tools: []step.Tool{researcher.AsTool()},
+	}
+
+	res, err := step.Run(context.Background(), lead, []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Capital of France?"}}}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Text() != "The capital is Paris." || res.Steps != 2 || len(res.Messages) != 3 {
+		t.Errorf("unexpected result: %q after %d steps, %d messages", res.Text(), res.Steps, len(res.Messages))
+	}
+	if got := res.Messages[1].(step.ToolResultMessage).Parts[0].(step.TextPart).Text; got != "Paris" {
+		t.Errorf("expected sub-agent answer, got %q", got)
+	}
+	if res.Usage.OutputTokens != 15 {
+		t.Errorf("expected combined usage of 15 output tokens, got %d", res.Usage.OutputTokens)
+	}
+}
+
+func TestRun_MaxSteps(t *testing.T) {
+	call := mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "missing"})
+	agent := step.Agent{Provider: mock.New(call, call, call), MaxSteps: 2}
+	res, err := step.Run(context.Background(), agent, nil)
+	if !errors.Is(err, step.ErrMaxSteps) || res.Steps != 2 {
+		t.Fatalf("expected ErrMaxSteps after 2 steps, got %v after %d", err, res.Steps)
+	}
+}
+
+// steerTool queues a steering message while it runs, like a user typing
+// during tool execution.
+type steerTool struct{ steering *step.Steering }
+
+func (steerTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "work"} }
+
+func (t steerTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	t.steering.Send(step.UserMessage{Parts: []step.Part{step.TextPart{Text: "use metric units"}}})
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "done"}}}, nil
+}
+
+func TestRun_Steering(t *testing.T) {
+	steering := &step.Steering{}
+	provider := mock.New(
+		mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "work", ArgsJSON: []byte(`{}`)}),
+		mock.Text("It is 20 degrees."),
+		mock.Text("Anything else?"),
+	)
+	agent := step.Agent{Provider: provider, Tools: []step.Tool{steerTool{steering}}}
+
+	var acked []step.UserMessage
+	res, err := step.Run(context.Background(), agent, []step.Message{step.UserMessage{ID: "u1", Parts: []step.Part{step.TextPart{Text: "Weather?"}}}},
+		step.WithSteering(steering),
+		step.WithOnMessage(func(m step.Message) {
+			if u, ok := m.(step.UserMessage); ok {
+				acked = append(acked, u)
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Steps != 2 || len(res.Messages) != 4 || res.Text() != "It is 20 degrees." {
+		t.Fatalf("expected the steered message before the second step, got %d steps: %v", res.Steps, res.Messages)
+	}
+	steered, ok := res.Messages[2].(step.UserMessage)
+	if !ok || steered.ID == "" || steered.ParentID != step.MessageID(res.Messages[1]) {
+		t.Errorf("expected a linked user message after the tool result, got %#v", res.Messages[2])
+	}
+	if v, _ := steered.Metadata.Bool(step.MetadataSteering); !v {
+		t.Error("expected MetadataSteering on the steered message")
+	}
+	if len(acked) != 1 || acked[0].ID != steered.ID {
+		t.Errorf("expected the message hook to acknowledge the steered message, got %v", acked)
+	}
+	history := provider.Requests()[1].History
+	if last, ok := history[len(history)-1].(step.UserMessage); !ok || last.ID != steered.ID {
+		t.Errorf("expected the second request to end with the steered message, got %v", history)
+	}
+}
+
+func TestRun_SteeringAfterStop(t *testing.T) {
+	steering := &step.Steering{}
+	agent := step.Agent{Provider: mock.New(mock.Text("Done."), mock.Text("Sure, in French: Fini."))}
+	sent := false
+	res, err := step.Run(context.Background(), agent, nil,
+		step.WithSteering(steering),
+		step.WithOnMessage(func(m step.Message) {
+			if _, ok := m.(step.AssistantMessage); ok && !sent {
+				sent = true
+				steering.Send(step.UserMessage{Parts: []step.Part{step.TextPart{Text: "In French please."}}})
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Steps != 2 || res.Text() != "Sure, in French: Fini." || steering.Pending() != 0 {
+		t.Errorf("expected Run to continue with the steered message, got %d steps and %q", res.Steps, res.Text())
+	}
+}
+
+// ctxTool records the request-scoped values of the context it runs with.
+type ctxTool struct{ seen *[]string }
+
+func (ctxTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "whoami"} }
+
+func (t ctxTool) Execute(ctx context.Context, _ step.ToolCallPart) (step.ToolResult, error) {
+	index, _ := step.StepIndex(ctx)
+	*t.seen = append(*t.seen, fmt.Sprintf("%s/%s/%s/%d", step.SessionID(ctx), step.UserID(ctx), step.RunID(ctx), index))
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "ok"}}}, nil
+}
+
+func TestRun_ContextValues(t *testing.T) {
+	call := func(id string) mock.Response {
+		return mock.ToolCalls(step.ToolCallPart{CallID: id, Name: "whoami", ArgsJSON: []byte(`{}`)})
+	}
+	var seen []string
+	agent := step.Agent{Provider: mock.New(call("c1"), call("c2"), mock.Text("done")), Tools: []step.Tool{ctxTool{&seen}}}
+
+	ctx := step.WithUserID(step.WithSessionID(context.Background(), "s1"), "u1")
+	res, err := step.Run(ctx, agent, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.HasPrefix(res.RunID, "run_") {
+		t.Fatalf("expected a run ID, got %q", res.RunID)
+	}
+	want := []string{"s1/u1/" + res.RunID + "/0", "s1/u1/" + res.RunID + "/1"}
+	if !slices.Equal(seen, want) {
+		t.Errorf("tools saw %v, want %v", seen, want)
+	}
+	if _, ok := step.StepIndex(ctx); ok || step.RunID(ctx) != "" {
+		t.Error("expected no run values outside a run")
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"errors"
 	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,30 +16,67 @@ func runStep(ctx context.Context, req StepRequest, cfg stepConfig) (StepResult,
 		return nil, ErrNoProvider
 	}
 
-	emitter := cfg.stepEmitter
+	emitter := partialArgsEmitter(cfg.stepEmitter, cfg.partialArgs)
+	emitter, flushDeltas := coalesceEmitter(emitter, cfg.coalesce)
+	defer flushDeltas()
+	emitter, releaseDeltas := splitEmitter(emitter, cfg.split)
+	defer releaseDeltas()
 
-	providerReq := ProviderRequest{
-		SystemPrompt: req.SystemPrompt,
-		History:      req.History,
-		Tools:        collectToolSpecs(req.Tools),
+	phases := startPhases(ctx, cfg.phases)
+	genCtx, cancelGen := phases.context(ctx, PhaseProvider)
+	defer cancelGen()
+
+	providerReq, savedTokens := buildProviderRequest(req, cfg)
+	if cfg.quota != nil {
+		if err := cfg.quota.Check(genCtx, &providerReq); err != nil {
+			return nil, err
+		}
 	}
 
-	stream, err := req.Provider.Stream(ctx, providerReq)
+	timing := newTokenTiming()
+	stream, err := req.Provider.Stream(genCtx, providerReq)
 	if err != nil {
+		if genCtx.Err() != nil {
+			return nil, phases.err(genCtx, PhaseProvider)
+		}
 		return nil, err
 	}
 	defer stream.Close()
+	if savedTokens > 0 {
+		stream = savingsStream{stream, savedTokens}
+	}
 
 	var assistantMsg AssistantMessage
 	hasAssistantMsg := false
+	parentID := ""
+	if len(req.History) > 0 {
+		parentID = MessageID(req.History[len(req.History)-1])
+	}
 
+	// A trailing partial assistant message is a prefill: the provider continues it,
+	// and the final message is merged with it so consumers see the complete text.
+	var prefill *AssistantMessage
+	if m, ok := Prefill(providerReq.History); ok {
+		prefill = &m
+		if _, own := Prefill(req.History); own {
+			parentID = ""
+			if len(req.History) > 1 {
+				parentID = MessageID(req.History[len(req.History)-2])
+			}
+		}
+		if text := m.Text(); text != "" {
+			emitter.delta(TextDelta{Delta: text})
+		}
+	}
+
+	partial := &partialMessage{}
 	for {
-		up, nextErr := stream.Next(ctx)
+		up, nextErr := stream.Next(genCtx)
 		if nextErr != nil {
 			if errors.Is(nextErr, io.EOF) {
 				// Some providers may return a final update along with io.EOF.
 				if up != nil {
-					msg, ok, err := handleProviderUpdate(up, emitter)
+					msg, ok, err := handleProviderUpdate(genCtx, up, emitter, parentID, prefill, timing, partial)
 					if err != nil {
 						return nil, err
 					}
@@ -46,9 +87,12 @@ func runStep(ctx context.Context, req StepRequest, cfg stepConfig) (StepResult,
 				}
 				break
 			}
-			return nil, nextErr
+			if hasAssistantMsg {
+				return nil, nextErr
+			}
+			return abortStream(genCtx, phases, nextErr, partial, emitter, parentID, prefill, timing)
 		}
-		msg, ok, err := handleProviderUpdate(up, emitter)
+		msg, ok, err := handleProviderUpdate(genCtx, up, emitter, parentID, prefill, timing, partial)
 		if err != nil {
 			return nil, err
 		}
@@ -61,38 +105,162 @@ func runStep(ctx context.Context, req StepRequest, cfg stepConfig) (StepResult,
 	if !hasAssistantMsg {
 		return nil, errors.New("step: provider stream finished without assistant message")
 	}
+	if cfg.quota != nil && assistantMsg.Usage != nil {
+		cfg.quota.Record(ctx, *assistantMsg.Usage)
+	}
+
+	for _, fn := range cfg.hooks.afterAssistant {
+		fn(assistantMsg)
+	}
 
+	// The tool calls of a message aborted by the provider phase's context run
+	// under that done context, only to record them as interrupted.
+	toolCtx, phase := genCtx, PhaseProvider
+	if assistantMsg.StopReason != StopAborted || genCtx.Err() == nil {
+		var cancelTools context.CancelFunc
+		toolCtx, cancelTools = phases.context(ctx, PhaseTools)
+		defer cancelTools()
+		phase = PhaseTools
+	}
 	toolCalls := extractToolCalls(assistantMsg)
-	toolMsgs := executeTools(ctx, toolCalls, req.Tools, emitter)
+	if len(toolCalls) > 0 && cfg.pauser.take() {
+		emitter.delta(StepStatusDelta{})
+		return StepResult{assistantMsg}, ErrPaused
+	}
+	toolMsgs := executeTools(toolCtx, toolCalls, req.Tools, cfg.toolExec, emitter, assistantMsg.ID)
+	if len(toolCalls) > 0 && len(cfg.hooks.afterTools) > 0 {
+		results := make([]ToolResultMessage, 0, len(toolMsgs))
+		for _, m := range toolMsgs {
+			if r, ok := m.(ToolResultMessage); ok {
+				results = append(results, r)
+			}
+		}
+		for _, fn := range cfg.hooks.afterTools {
+			fn(results)
+		}
+	}
 
 	result := StepResult(append([]Message{assistantMsg}, toolMsgs...))
-	cancelled := ctx.Err() != nil
-	emitter.delta(StepStatusDelta{Cancelled: cancelled})
+	cancelled := toolCtx.Err() != nil
+	status := StepStatusDelta{Cancelled: cancelled}
+	if cancelled {
+		status.Reason = contextCancelReason(toolCtx)
+	}
+	emitter.delta(status)
 
 	if cancelled {
-		return result, ctx.Err()
+		return result, phases.err(toolCtx, phase)
 	}
 	return result, nil
 }
 
-func handleProviderUpdate(up ProviderUpdate, emitter stepEmitter) (AssistantMessage, bool, error) {
+// buildProviderRequest converts req and applies WithOnBeforeProviderCall hooks.
+// It also returns the input tokens saved by WithEfficientTools.
+func buildProviderRequest(req StepRequest, cfg stepConfig) (ProviderRequest, int) {
+	providerReq := ProviderRequest{
+		SystemPrompt: req.SystemPrompt,
+		History:      withoutOpaqueParts(req.History),
+		Tools:        collectToolSpecs(req.Tools),
+		Raw:          cfg.onRaw != nil,
+
+		ModelOverride:   cfg.model,
+		ReasoningEffort: cfg.effort,
+		EfficientTools:  cfg.efficientTools,
+	}
+	var saved int
+	if cfg.efficientTools {
+		providerReq.Tools, saved = compactToolSpecs(providerReq.Tools)
+	}
+	if len(cfg.hooks.beforeProviderCall) > 0 {
+		// Hooks may modify the history slice; never let them write into the caller's.
+		providerReq.History = append([]Message(nil), providerReq.History...)
+		for _, fn := range cfg.hooks.beforeProviderCall {
+			fn(&providerReq)
+		}
+	}
+	return providerReq, saved
+}
+
+func handleProviderUpdate(ctx context.Context, up ProviderUpdate, emitter stepEmitter, parentID string, prefill *AssistantMessage, timing *tokenTiming, partial *partialMessage) (AssistantMessage, bool, error) {
 	switch u := up.(type) {
 	case nil:
 		return AssistantMessage{}, false, nil
 	case ProviderDeltaUpdate:
 		if u.Delta != nil {
+			timing.delta(u.Delta)
+			partial.add(u.Delta)
 			emitter.delta(u.Delta)
 		}
 		return AssistantMessage{}, false, nil
 	case ProviderMessageUpdate:
-		emitter.message(u.Message)
-		return u.Message, true, nil
+		if u.Candidate != 0 {
+			// Step continues with the first candidate only; see Candidates.
+			return AssistantMessage{}, false, nil
+		}
+		msg := u.Message
+		if ctx.Err() != nil {
+			// Providers finalize the partial message when the context is done.
+			abort(&msg, contextCancelReason(ctx))
+		}
+		msg = finishMessage(msg, parentID, prefill, timing)
+		emitter.message(msg)
+		return msg, true, nil
+	case ProviderRawUpdate:
+		if emitter.onRaw != nil {
+			emitter.onRaw(u)
+		}
+		return AssistantMessage{}, false, nil
 	default:
 		return AssistantMessage{}, false, errors.New("step: unknown provider update")
 	}
 }
 
-func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitter stepEmitter) []Message {
+// finishMessage completes a provider message for the history.
+func finishMessage(msg AssistantMessage, parentID string, prefill *AssistantMessage, timing *tokenTiming) AssistantMessage {
+	msg = validUTF8Message(msg)
+	timing.generation(msg.Usage)
+	msg.Stats = timing.messageStats()
+	if prefill != nil {
+		msg = mergePrefill(*prefill, msg)
+	}
+	if msg.ID == "" {
+		msg.ID = NewMessageID()
+	}
+	if msg.ParentID == "" {
+		msg.ParentID = parentID
+	}
+	return msg
+}
+
+// abortStream handles a stream that failed before its final message. When the
+// context is done or the connection dropped, the text streamed so far becomes
+// an aborted message, so the result matches a provider that finalized it.
+// Other errors, and aborts before any text, return no result.
+func abortStream(ctx context.Context, phases *phaseDeadlines, err error, partial *partialMessage, emitter stepEmitter, parentID string, prefill *AssistantMessage, timing *tokenTiming) (StepResult, error) {
+	var reason CancelReason
+	switch {
+	case ctx.Err() != nil:
+		reason, err = contextCancelReason(ctx), phases.err(ctx, PhaseProvider)
+	case isDisconnect(err):
+		reason = CancelDisconnect
+	default:
+		return nil, err
+	}
+	msg, ok := partial.message()
+	if !ok {
+		return nil, err
+	}
+	msg.Timestamp = time.Now().UnixMilli()
+	abort(&msg, reason)
+	msg = finishMessage(msg, parentID, prefill, timing)
+	emitter.message(msg)
+	emitter.delta(StepStatusDelta{Cancelled: true, Reason: reason})
+	return StepResult{msg}, err
+}
+
+// executeTools runs calls and returns their result messages; see ExecuteTools
+// for the guarantees.
+func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, exec toolExecConfig, emitter stepEmitter, parentID string) []Message {
 	if len(calls) == 0 {
 		return nil
 	}
@@ -104,6 +272,8 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 	}
 
 	results := make([]ToolResult, len(calls))
+	started := make([]time.Time, len(calls))
+	timings := make([]toolTiming, len(calls))
 	msgs := make([]Message, len(calls))
 	completed := make([]bool, len(calls))
 
@@ -112,17 +282,29 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 
 	// completions is buffered to avoid blocking tool goroutines when the step is cancelled.
 	type completion struct {
-		idx int
-		res ToolResult
+		idx    int
+		res    ToolResult
+		timing toolTiming
 	}
 	completions := make(chan completion, len(calls))
 	parallelIdx := make([]bool, len(calls))
 
-	execOne := func(idx int, call ToolCallPart) {
+	// begin emits the start of a call on this goroutine, before it runs.
+	begin := func(idx int, call ToolCallPart) {
+		started[idx] = time.Now()
 		emitter.delta(ToolExecStartDelta{Call: call})
-		res := executeSingleTool(toolCtx, call, toolMap)
+	}
+
+	run := func(call ToolCallPart) (ToolResult, toolTiming) {
+		start := time.Now()
+		res, attempts := executeSingleTool(toolCtx, call, toolMap, exec)
+		return res, toolTiming{start: start, duration: time.Since(start), attempts: attempts}
+	}
+
+	execOne := func(idx int, call ToolCallPart) {
+		res, timing := run(call)
 		select {
-		case completions <- completion{idx: idx, res: res}:
+		case completions <- completion{idx: idx, res: res, timing: timing}:
 		default:
 			// Drop if receiver stopped (e.g. cancelled), Step will emit interrupted results.
 		}
@@ -137,6 +319,8 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 			}
 			res := results[idx]
 			msg := ToolResultMessage{
+				ID:        NewMessageID(),
+				ParentID:  parentID,
 				CallID:    res.CallID,
 				Name:      res.Name,
 				IsError:   res.IsError,
@@ -144,6 +328,12 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 				Timestamp: time.Now().UnixMilli(),
 				Details:   res.Details,
 			}
+			if res.Pending {
+				msg.Metadata.Set(MetadataToolPending, true)
+			}
+			if t := timings[idx]; exec.timing && !t.start.IsZero() {
+				msg.Details = withTiming(msg.Details, t)
+			}
 			msgs[idx] = msg
 			emitter.message(msg)
 			*next = *next + 1
@@ -152,7 +342,7 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 
 	nextToEmit := 0
 
-	recordCompletion := func(idx int, res ToolResult) {
+	recordCompletion := func(idx int, res ToolResult, timing toolTiming) {
 		if idx < 0 || idx >= len(calls) {
 			return
 		}
@@ -160,28 +350,40 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 			return
 		}
 		results[idx] = res
+		timings[idx] = timing
 		completed[idx] = true
+		if !started[idx].IsZero() {
+			end := ToolExecEndDelta{
+				Call:        calls[idx],
+				Duration:    timing.duration,
+				Attempts:    timing.attempts,
+				IsError:     res.IsError,
+				Interrupted: timing.start.IsZero() || res.IsError && toolCtx.Err() != nil,
+				Truncated:   res.Details[DetailsTruncated] == true,
+			}
+			if timing.start.IsZero() {
+				end.Duration = time.Since(started[idx])
+			}
+			emitter.delta(end)
+		}
 		flushInOrder(&nextToEmit)
 	}
 
 	// Execute tools with a simple exclusivity rule:
-	// - tools with Spec().Parallel=true may run concurrently with each other
-	// - tools with Spec().Parallel=false run exclusively
+	// - tools whose Spec().RunsInParallel() may run concurrently with each other
+	// - other tools run exclusively
 	var runningParallel int
 
 	startParallel := func(idx int, call ToolCallPart) {
 		runningParallel++
 		parallelIdx[idx] = true
+		begin(idx, call)
 		go execOne(idx, call)
 	}
 
 	markInterruptedFrom := func(start int) {
 		for i := start; i < len(calls); i++ {
-			if completed[i] {
-				continue
-			}
-			results[i] = interruptedToolResult(calls[i])
-			completed[i] = true
+			recordCompletion(i, interruptedToolResult(calls[i]), toolTiming{})
 		}
 	}
 
@@ -193,7 +395,7 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 			flushInOrder(&nextToEmit)
 			return false
 		case c := <-completions:
-			recordCompletion(c.idx, c.res)
+			recordCompletion(c.idx, c.res, c.timing)
 			if c.idx >= 0 && c.idx < len(parallelIdx) && parallelIdx[c.idx] {
 				runningParallel--
 			}
@@ -201,15 +403,28 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 		}
 	}
 
-	for idx, call := range calls {
+	order, deps := executionOrder(calls, toolMap)
+	for _, idx := range order {
+		call := calls[idx]
 		if ctx.Err() != nil {
-			markInterruptedFrom(idx)
+			markInterruptedFrom(0)
 			flushInOrder(&nextToEmit)
 			break
 		}
 
 		tool, ok := toolMap[call.Name]
-		parallel := ok && tool.Spec().Parallel
+		parallel := ok && tool.Spec().RunsInParallel()
+
+		// Calls it depends on already started; wait for those still running.
+		for runningParallel > 0 && !allCompleted(completed, deps[idx]) {
+			if !recvOne() {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			recordCompletion(idx, interruptedToolResult(call), toolTiming{})
+			continue
+		}
 
 		if !parallel {
 			// Wait for any parallel tools to finish before executing a non-parallel tool.
@@ -219,12 +434,12 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 				}
 			}
 			if ctx.Err() != nil {
-				recordCompletion(idx, interruptedToolResult(call))
+				recordCompletion(idx, interruptedToolResult(call), toolTiming{})
 				continue
 			}
-			emitter.delta(ToolExecStartDelta{Call: call})
-			res := executeSingleTool(toolCtx, call, toolMap)
-			recordCompletion(idx, res)
+			begin(idx, call)
+			res, timing := run(call)
+			recordCompletion(idx, res, timing)
 			continue
 		}
 
@@ -242,16 +457,94 @@ func executeTools(ctx context.Context, calls []ToolCallPart, tools []Tool, emitt
 		if completed[i] {
 			continue
 		}
-		recordCompletion(i, interruptedToolResult(calls[i]))
+		recordCompletion(i, interruptedToolResult(calls[i]), toolTiming{})
 	}
 
 	flushInOrder(&nextToEmit)
 	return msgs
 }
 
+// toolTiming is when and how often a tool call ran; zero for calls whose
+// result is not the tool's.
+type toolTiming struct {
+	start    time.Time
+	duration time.Duration
+	attempts int
+}
+
+// executionOrder returns the order to run calls in and, for each call, the
+// calls that must finish before it starts; see ToolSpec.After. Calls run in
+// the model's order unless a dependency requires otherwise. Dependencies
+// that form a cycle are broken in the model's order.
+func executionOrder(calls []ToolCallPart, toolMap map[string]Tool) ([]int, [][]int) {
+	after := make([][]int, len(calls))
+	hasDeps := false
+	for j, call := range calls {
+		tool, ok := toolMap[call.Name]
+		if !ok {
+			continue
+		}
+		for _, name := range tool.Spec().After {
+			for i, dep := range calls {
+				if dep.Name == name && name != call.Name {
+					after[j] = append(after[j], i)
+					hasDeps = true
+				}
+			}
+		}
+	}
+	order := make([]int, 0, len(calls))
+	deps := make([][]int, len(calls))
+	if !hasDeps {
+		for i := range calls {
+			order = append(order, i)
+		}
+		return order, deps
+	}
+
+	placed := make([]bool, len(calls))
+	for len(order) < len(calls) {
+		next := -1
+		for j := range calls {
+			if !placed[j] && allCompleted(placed, after[j]) {
+				next = j
+				break
+			}
+		}
+		if next < 0 {
+			// A cycle: run the first remaining call without its unplaced dependencies.
+			for j := range calls {
+				if !placed[j] {
+					next = j
+					break
+				}
+			}
+		}
+		for _, i := range after[next] {
+			if placed[i] {
+				deps[next] = append(deps[next], i)
+			}
+		}
+		placed[next] = true
+		order = append(order, next)
+	}
+	return order, deps
+}
+
+// allCompleted reports whether done is set for every index in idxs.
+func allCompleted(done []bool, idxs []int) bool {
+	for _, i := range idxs {
+		if !done[i] {
+			return false
+		}
+	}
+	return true
+}
+
 type stepEmitter struct {
 	onDelta   func(MessageDelta)
 	onMessage func(Message)
+	onRaw     func(ProviderRawUpdate)
 }
 
 func (e stepEmitter) delta(d MessageDelta) {
@@ -268,21 +561,41 @@ func (e stepEmitter) message(m Message) {
 	e.onMessage(m)
 }
 
-func executeSingleTool(ctx context.Context, call ToolCallPart, toolMap map[string]Tool) ToolResult {
+// executeSingleTool runs call, retrying it per the tool's RetryPolicy, and
+// returns its result with the number of executions.
+func executeSingleTool(ctx context.Context, call ToolCallPart, toolMap map[string]Tool, exec toolExecConfig) (ToolResult, int) {
 	if ctx.Err() != nil {
-		return interruptedToolResult(call)
+		return interruptedToolResult(call), 0
 	}
 	tool, ok := toolMap[call.Name]
 	if !ok {
-		return toolNotFoundResult(call)
+		return withRecovery(call, toolNotFoundResult(call, toolMap), nil, exec.recovery), 0
+	}
+	spec := tool.Spec()
+	if exec.approve != nil && spec.Access == AccessDestructive {
+		if err := exec.approve(ctx, call); err != nil {
+			if ctx.Err() != nil {
+				return interruptedToolResult(call), 0
+			}
+			return deniedToolResult(call, err), 0
+		}
 	}
 
+	policy, retry := exec.retryPolicy(spec)
+	attempts := 1
 	res, err := tool.Execute(ctx, call)
+	for retry && attempts < policy.MaxAttempts && ctx.Err() == nil && policy.retryOn(res, err) {
+		if !wait(ctx, policy.backoff(attempts)) {
+			return interruptedToolResult(call), attempts
+		}
+		attempts++
+		res, err = tool.Execute(ctx, call)
+	}
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-			return interruptedToolResult(call)
+			return interruptedToolResult(call), attempts
 		}
-		return errorToolResult(call, err)
+		return withRecovery(call, errorToolResult(call, err), err, exec.recovery), attempts
 	}
 	if res.CallID == "" {
 		res.CallID = call.CallID
@@ -290,7 +603,7 @@ func executeSingleTool(ctx context.Context, call ToolCallPart, toolMap map[strin
 	if res.Name == "" {
 		res.Name = call.Name
 	}
-	return res
+	return withRecovery(call, res, nil, exec.recovery), attempts
 }
 
 func interruptedToolResult(call ToolCallPart) ToolResult {
@@ -322,13 +635,18 @@ func extractToolCalls(msg AssistantMessage) []ToolCallPart {
 	return calls
 }
 
-func toolNotFoundResult(call ToolCallPart) ToolResult {
-	return ToolResult{
+func toolNotFoundResult(call ToolCallPart, toolMap map[string]Tool) ToolResult {
+	res := ToolResult{
 		CallID:  call.CallID,
 		Name:    call.Name,
 		IsError: true,
 		Parts:   []Part{TextPart{Text: "tool not found"}},
 	}
+	if len(toolMap) > 0 {
+		names := slices.Sorted(maps.Keys(toolMap))
+		res.Details = map[string]any{DetailsRecovery: "Call one of the available tools: " + strings.Join(names, ", ") + "."}
+	}
+	return res
 }
 
 func errorToolResult(call ToolCallPart, err error) ToolResult {
@@ -339,3 +657,74 @@ func errorToolResult(call ToolCallPart, err error) ToolResult {
 		Parts:   []Part{TextPart{Text: err.Error()}},
 	}
 }
+
+// tokenTiming tracks time to first token and generation throughput. It backs
+// Stats for one provider call and StreamStats for a whole step, where deltas
+// may arrive from tool goroutines.
+type tokenTiming struct {
+	mu                           sync.Mutex
+	start, firstToken, generated time.Time
+	output                       int
+}
+
+func newTokenTiming() *tokenTiming { return &tokenTiming{start: time.Now()} }
+
+func (t *tokenTiming) delta(d MessageDelta) {
+	switch d.(type) {
+	case TextDelta, ThinkingDelta, ToolCallDelta:
+	default:
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.firstToken.IsZero() {
+		t.firstToken = time.Now()
+	}
+}
+
+// generation records the final assistant message of a provider call.
+func (t *tokenTiming) generation(usage *Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.generated = time.Now()
+	if usage != nil {
+		t.output = usage.OutputTokens
+	}
+}
+
+// rate returns the output tokens per second since the first token. The caller
+// holds t.mu.
+func (t *tokenTiming) rate() (time.Duration, float64) {
+	if t.firstToken.IsZero() || t.generated.IsZero() {
+		return 0, 0
+	}
+	gen := t.generated.Sub(t.firstToken)
+	if gen <= 0 || t.output <= 0 {
+		return gen, 0
+	}
+	return gen, float64(t.output) / gen.Seconds()
+}
+
+// messageStats returns Stats for the message recorded by generation.
+func (t *tokenTiming) messageStats() *Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := &Stats{DurationMs: t.generated.Sub(t.start).Milliseconds()}
+	if !t.firstToken.IsZero() {
+		st.TimeToFirstTokenMs = t.firstToken.Sub(t.start).Milliseconds()
+	}
+	_, st.OutputTokensPerSecond = t.rate()
+	return st
+}
+
+// streamStats returns StreamStats for the step so far.
+func (t *tokenTiming) streamStats() StreamStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := StreamStats{Duration: time.Since(t.start), OutputTokens: t.output}
+	if !t.firstToken.IsZero() {
+		st.TimeToFirstToken = t.firstToken.Sub(t.start)
+	}
+	st.GenerationDuration, st.TokensPerSecond = t.rate()
+	return st
+}
@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs.
+type Schedule interface {
+	// Next returns the first activation time after t, or the zero time if
+	// there is none.
+	Next(t time.Time) time.Time
+}
+
+type interval time.Duration
+
+// Every returns a Schedule that activates every d, counted from when the
+// scheduler starts. It panics if d is not positive.
+func Every(d time.Duration) Schedule {
+	if d <= 0 {
+		panic("schedule: non-positive interval for Every")
+	}
+	return interval(d)
+}
+
+func (i interval) Next(t time.Time) time.Time { return t.Add(time.Duration(i)) }
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cron is a parsed cron spec; each field is a bit set of allowed values.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted day fields, which change how
+	// the two combine: a day matches either restricted field.
+	domStar, dowStar bool
+}
+
+// Cron parses a standard five-field cron spec (minute, hour, day of month,
+// month, day of week) such as "*/15 9-17 * * 1-5", or one of @hourly,
+// @daily, @weekly, @monthly and @yearly. Fields accept *, values, ranges
+// (a-b), steps (*/n, a-b/n) and comma-separated lists; day of week is 0-6
+// from Sunday, with 7 also meaning Sunday. Times are in the location of the
+// time passed to Next.
+func Cron(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := descriptors[spec]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule: cron spec %q must have 5 fields, got %d", spec, len(fields))
+	}
+	var c cron
+	var err error
+	bounds := []struct {
+		dst      *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.dst, err = parseField(fields[i], b.min, b.max); err != nil {
+			return nil, fmt.Errorf("schedule: cron spec %q: %w", spec, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is Sunday too
+	}
+	c.domStar, c.dowStar = fields[2] == "*", fields[4] == "*"
+	return &c, nil
+}
+
+// MustCron is like Cron but panics on an invalid spec.
+func MustCron(spec string) Schedule {
+	s, err := Cron(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			var err error
+			if i := strings.IndexByte(rng, '-'); i >= 0 {
+				lo, err = strconv.Atoi(rng[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(rng[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(rng)
+				hi = lo
+				if step > 1 {
+					hi = max // "5/10" means from 5 to the end in steps of 10
+				}
+			}
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Matching times repeat at least every few years; give up after that.
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
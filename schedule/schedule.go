@@ -0,0 +1,215 @@
+// Package schedule triggers agent runs on a cron spec or a fixed interval, for
+// monitoring and reporting agents:
+//
+//	s := schedule.Scheduler{
+//		Jobs: []schedule.Job{{
+//			Name:     "daily-report",
+//			Schedule: schedule.MustCron("0 9 * * 1-5"),
+//			Agent:    reporter,
+//			Prompt:   "Summarize yesterday's incidents.",
+//		}},
+//		OnResult: func(r schedule.Result) { log.Println(r.Job, r.Run.Text(), r.Err) },
+//	}
+//	err := s.Run(ctx)
+//
+// Every run starts from a fresh history, so runs never share a conversation.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// Overlap decides what happens when a job is due while its previous run is
+// still in progress.
+type Overlap int
+
+const (
+	// OverlapSkip drops the activation and reports a skipped Result.
+	OverlapSkip Overlap = iota
+	// OverlapQueue runs once more as soon as the current run finishes.
+	// Activations while a run is already queued are skipped.
+	OverlapQueue
+	// OverlapAllow starts the new run alongside the current one.
+	OverlapAllow
+)
+
+// Job is an agent run on a schedule.
+type Job struct {
+	// Name identifies the job in Results; names must be unique.
+	Name     string
+	Schedule Schedule
+	Agent    step.Agent
+	// Prompt is the user message every run starts from.
+	Prompt string
+	// History, if set, builds the input of each run instead of Prompt, e.g.
+	// to include the current date. at is the scheduled activation time.
+	History func(ctx context.Context, at time.Time) ([]step.Message, error)
+	// Options are passed to every run. They are shared between runs, so
+	// leave out per-run state such as a Steering or Pauser.
+	Options []step.StepOption
+	Overlap Overlap
+	// Timeout bounds each run. Zero means no limit.
+	Timeout time.Duration
+}
+
+func (j Job) history(ctx context.Context, at time.Time) ([]step.Message, error) {
+	if j.History != nil {
+		return j.History(ctx, at)
+	}
+	return []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: j.Prompt}}}}, nil
+}
+
+// Result is the outcome of one activation of a job.
+type Result struct {
+	Job string
+	// Scheduled is the activation time; Started is when the run began, which
+	// is later for queued runs.
+	Scheduled time.Time
+	Started   time.Time
+	Duration  time.Duration
+	// Skipped is set when the activation was dropped by OverlapSkip or
+	// OverlapQueue; nothing ran.
+	Skipped bool
+	Run     step.RunResult
+	Err     error
+}
+
+// Scheduler runs jobs until its context is done.
+type Scheduler struct {
+	Jobs []Job
+	// OnResult, if set, receives every Result. Calls are serialized, even
+	// for concurrent runs.
+	OnResult func(Result)
+
+	mu sync.Mutex
+}
+
+// job is the overlap state of a Job during Run.
+type job struct {
+	Job
+	mu       sync.Mutex
+	running  int
+	queued   bool
+	queuedAt time.Time
+}
+
+// Run starts the jobs' schedules and blocks until ctx is done. In-flight runs
+// are canceled with ctx; Run returns once they have finished and reported,
+// with ctx.Err(). A job whose schedule has no further activation stops.
+func (s *Scheduler) Run(ctx context.Context) error {
+	seen := make(map[string]bool)
+	for _, j := range s.Jobs {
+		if j.Schedule == nil {
+			return fmt.Errorf("schedule: job %q has no schedule", j.Name)
+		}
+		if seen[j.Name] {
+			return fmt.Errorf("schedule: duplicate job %q", j.Name)
+		}
+		seen[j.Name] = true
+	}
+
+	var wg sync.WaitGroup
+	for _, j := range s.Jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, &job{Job: j}, &wg)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// loop triggers j at each activation. Activations missed while the machine
+// was busy or asleep are dropped, not made up.
+func (s *Scheduler) loop(ctx context.Context, j *job, wg *sync.WaitGroup) {
+	next := j.Schedule.Next(time.Now())
+	for !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.trigger(ctx, j, next, wg)
+		at := next
+		if next = j.Schedule.Next(at); !next.IsZero() && next.Before(time.Now()) {
+			next = j.Schedule.Next(time.Now())
+		}
+	}
+}
+
+// trigger starts a run of j for the activation at, applying its Overlap policy.
+func (s *Scheduler) trigger(ctx context.Context, j *job, at time.Time, wg *sync.WaitGroup) {
+	j.mu.Lock()
+	if j.running > 0 {
+		switch {
+		case j.Overlap == OverlapQueue && !j.queued:
+			j.queued, j.queuedAt = true, at
+			j.mu.Unlock()
+			return
+		case j.Overlap != OverlapAllow:
+			j.mu.Unlock()
+			s.report(Result{Job: j.Name, Scheduled: at, Skipped: true})
+			return
+		}
+	}
+	j.running++
+	j.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			s.report(s.run(ctx, j.Job, at))
+			j.mu.Lock()
+			if !j.queued || ctx.Err() != nil {
+				j.queued = false
+				j.running--
+				j.mu.Unlock()
+				return
+			}
+			j.queued, at = false, j.queuedAt
+			j.mu.Unlock()
+		}
+	}()
+}
+
+// run runs the agent of j once, on a history of its own.
+func (s *Scheduler) run(ctx context.Context, j Job, at time.Time) Result {
+	res := Result{Job: j.Name, Scheduled: at, Started: time.Now()}
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	history, err := j.history(ctx, at)
+	if err == nil {
+		res.Run, err = step.Run(ctx, j.Agent, history, j.Options...)
+	}
+	if j.Timeout > 0 && errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		err = fmt.Errorf("schedule: job %q timed out after %s: %w", j.Name, j.Timeout, err)
+	}
+	res.Err = err
+	res.Duration = time.Since(res.Started)
+	return res
+}
+
+func (s *Scheduler) report(r Result) {
+	if s.OnResult == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.OnResult(r)
+}
@@ -0,0 +1,157 @@
+package schedule_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/schedule"
+)
+
+func TestCron_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		spec, from, want string
+	}{
+		{"* * * * *", "2025-03-10 12:00", "2025-03-10 12:01"},
+		{"*/15 * * * *", "2025-03-10 12:07", "2025-03-10 12:15"},
+		{"0 9 * * 1-5", "2025-03-07 09:00", "2025-03-10 09:00"}, // Friday to Monday
+		{"30 8,17 * * *", "2025-03-10 09:00", "2025-03-10 17:30"},
+		{"0 0 31 * *", "2025-04-01 00:00", "2025-05-31 00:00"},
+		{"0 0 1 * 0", "2025-03-02 00:00", "2025-03-09 00:00"}, // day of month or Sunday
+		{"0 12 * * 7", "2025-03-10 12:00", "2025-03-16 12:00"},
+		{"@monthly", "2025-12-15 10:00", "2026-01-01 00:00"},
+		{"0 0 29 2 *", "2025-01-01 00:00", "2028-02-29 00:00"},
+	}
+	for _, tt := range tests {
+		s, err := schedule.Cron(tt.spec)
+		if err != nil {
+			t.Fatalf("Cron(%q): %v", tt.spec, err)
+		}
+		if got := s.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+			t.Errorf("Cron(%q).Next(%s) = %s, want %s", tt.spec, tt.from, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := schedule.Cron(spec); err == nil {
+			t.Errorf("Cron(%q): expected error", spec)
+		}
+	}
+}
+
+// scriptedAgent returns an agent answering n runs, each after delay.
+func scriptedAgent(n int, delay time.Duration) step.Agent {
+	provider := mock.New()
+	for range n {
+		r := mock.Text("ok")
+		r.Delay = delay
+		provider.Push(r)
+	}
+	return step.Agent{Provider: provider}
+}
+
+func TestScheduler_Isolation(t *testing.T) {
+	agent := scriptedAgent(10, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	var results []schedule.Result
+	s := schedule.Scheduler{
+		Jobs: []schedule.Job{{Name: "report", Schedule: schedule.Every(5 * time.Millisecond), Agent: agent, Prompt: "Report."}},
+		OnResult: func(r schedule.Result) {
+			if results = append(results, r); len(results) == 3 {
+				cancel()
+			}
+		},
+	}
+	if err := s.Run(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(results) < 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for _, r := range results[:3] {
+		if r.Err != nil || r.Skipped || r.Job != "report" || r.Run.Text() != "ok" {
+			t.Errorf("unexpected result: %+v", r)
+		}
+	}
+	for i, req := range agent.Provider.(*mock.Provider).Requests() {
+		if len(req.History) != 1 {
+			t.Errorf("run %d saw %d messages of history, want only its prompt", i, len(req.History))
+		}
+	}
+}
+
+func TestScheduler_Overlap(t *testing.T) {
+	tests := []struct {
+		overlap     schedule.Overlap
+		wantSkipped bool
+		wantOverlap bool
+	}{
+		{schedule.OverlapSkip, true, false},
+		{schedule.OverlapQueue, true, false},
+		{schedule.OverlapAllow, false, true},
+	}
+	for _, tt := range tests {
+		agent := scriptedAgent(20, 25*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+		var runs []schedule.Result
+		skipped := 0
+		s := schedule.Scheduler{
+			Jobs: []schedule.Job{{
+				Schedule: schedule.Every(10 * time.Millisecond),
+				Agent:    agent,
+				Overlap:  tt.overlap,
+			}},
+			OnResult: func(r schedule.Result) {
+				if r.Skipped {
+					skipped++
+				} else {
+					runs = append(runs, r)
+				}
+			},
+		}
+		s.Run(ctx)
+		cancel()
+		if len(runs) < 2 || (skipped > 0) != tt.wantSkipped {
+			t.Errorf("overlap %d: %d runs, %d skipped", tt.overlap, len(runs), skipped)
+			continue
+		}
+		slices.SortFunc(runs, func(a, b schedule.Result) int { return a.Started.Compare(b.Started) })
+		overlapped := false
+		for i := 1; i < len(runs); i++ {
+			prev := runs[i-1]
+			overlapped = overlapped || runs[i].Started.Before(prev.Started.Add(prev.Duration))
+		}
+		if overlapped != tt.wantOverlap {
+			t.Errorf("overlap %d: runs overlapped = %v", tt.overlap, overlapped)
+		}
+	}
+}
+
+func TestScheduler_Timeout(t *testing.T) {
+	agent := scriptedAgent(5, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	var got schedule.Result
+	s := schedule.Scheduler{
+		Jobs: []schedule.Job{{Name: "slow", Schedule: schedule.Every(time.Millisecond), Agent: agent, Timeout: 10 * time.Millisecond}},
+		OnResult: func(r schedule.Result) {
+			if !r.Skipped && got.Job == "" {
+				got = r
+				cancel()
+			}
+		},
+	}
+	s.Run(ctx)
+	if got.Err == nil || got.Duration >= time.Second {
+		t.Fatalf("expected the run to time out, got %+v", got)
+	}
+}
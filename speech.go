@@ -0,0 +1,114 @@
+package step
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Audio is encoded audio, e.g. MimeType "audio/mpeg" for MP3 or
+// "audio/L16;rate=24000" for raw 16-bit PCM.
+type Audio struct {
+	MimeType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+// TranscribeRequest is the input of Transcriber.Transcribe.
+type TranscribeRequest struct {
+	Audio Audio
+	// Model overrides the provider's transcription model.
+	Model string
+	// Language is the ISO-639-1 code of the spoken language, e.g. "en".
+	// Empty lets the model detect it.
+	Language string
+	// Prompt guides the transcription, e.g. with names and terms used or the
+	// preceding transcript.
+	Prompt string
+}
+
+// Transcript is the text of a transcribed recording.
+type Transcript struct {
+	Text string `json:"text"`
+	// Language is the detected language, if the provider reports it.
+	Language string `json:"language,omitempty"`
+	// Duration is the audio duration, if the provider reports it.
+	Duration time.Duration `json:"duration,omitempty"`
+	Usage    *Usage        `json:"usage,omitempty"`
+}
+
+// Transcriber is implemented by providers that turn speech into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, req TranscribeRequest) (Transcript, error)
+}
+
+// StreamTranscriber is implemented by transcribers that stream the
+// transcript as it is produced. The stream emits ProviderDeltaUpdates with
+// TextDeltas and then a ProviderMessageUpdate whose message text is the whole
+// transcript, like a Provider stream, so the same rendering code applies.
+type StreamTranscriber interface {
+	TranscribeStream(ctx context.Context, req TranscribeRequest) (ProviderStream, error)
+}
+
+// SynthesizeRequest is the input of Synthesizer.Synthesize.
+type SynthesizeRequest struct {
+	Text string
+	// Model overrides the provider's speech model.
+	Model string
+	// Voice is a provider voice name, e.g. "alloy" for OpenAI or "Kore" for
+	// Gemini. Empty uses the provider's default.
+	Voice string
+	// Format is the audio format, e.g. "mp3", "wav", "opus" or "pcm". Empty
+	// uses the provider's default; see Audio.MimeType of the result.
+	Format string
+	// Instructions steer the delivery, e.g. "Speak slowly and calmly".
+	Instructions string
+	// Speed is the playback speed multiplier, if the provider supports it.
+	// Zero is normal speed.
+	Speed float64
+}
+
+// Synthesizer is implemented by providers that turn text into speech.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, req SynthesizeRequest) (Audio, error)
+}
+
+// TranscribeStream streams the transcript of req with t. Transcribers that do
+// not implement StreamTranscriber emit the whole transcript as one delta.
+func TranscribeStream(ctx context.Context, t Transcriber, req TranscribeRequest) (ProviderStream, error) {
+	if st, ok := t.(StreamTranscriber); ok {
+		return st.TranscribeStream(ctx, req)
+	}
+	tr, err := t.Transcribe(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	msg := AssistantMessage{
+		Parts:      []Part{TextPart{Text: tr.Text}},
+		Timestamp:  time.Now().UnixMilli(),
+		Usage:      tr.Usage,
+		StopReason: StopStop,
+	}
+	return &updateStream{updates: []ProviderUpdate{
+		ProviderDeltaUpdate{Delta: TextDelta{Delta: tr.Text}},
+		ProviderMessageUpdate{Message: msg},
+	}}, nil
+}
+
+// updateStream is a ProviderStream of fixed updates.
+type updateStream struct {
+	updates []ProviderUpdate
+}
+
+func (s *updateStream) Next(ctx context.Context) (ProviderUpdate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.updates) == 0 {
+		return nil, io.EOF
+	}
+	up := s.updates[0]
+	s.updates = s.updates[1:]
+	return up, nil
+}
+
+func (s *updateStream) Close() error { return nil }
@@ -0,0 +1,48 @@
+package step_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/inspirepan/step"
+)
+
+type fakeTranscriber string
+
+func (f fakeTranscriber) Transcribe(context.Context, step.TranscribeRequest) (step.Transcript, error) {
+	return step.Transcript{Text: string(f)}, nil
+}
+
+func TestTranscribeStream_Fallback(t *testing.T) {
+	stream, err := step.TranscribeStream(context.Background(), fakeTranscriber("hello there"), step.TranscribeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var deltas string
+	var final step.AssistantMessage
+	for {
+		up, err := stream.Next(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch up := up.(type) {
+		case step.ProviderDeltaUpdate:
+			deltas += up.Delta.(step.TextDelta).Delta
+		case step.ProviderMessageUpdate:
+			final = up.Message
+		}
+	}
+	if deltas != "hello there" {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if len(final.Parts) != 1 || final.Parts[0].(step.TextPart).Text != "hello there" || final.StopReason != step.StopStop {
+		t.Errorf("unexpected final message %+v", final)
+	}
+}
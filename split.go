@@ -0,0 +1,234 @@
+package step
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DeltaSplit selects the boundaries WithDeltaSplitting breaks deltas on.
+type DeltaSplit int
+
+const (
+	// SplitRunes never splits a multibyte UTF-8 character across deltas.
+	SplitRunes DeltaSplit = iota + 1
+	// SplitWords also breaks text only after whitespace. A word longer than
+	// maxHeldWord bytes is released at a rune boundary instead of waiting.
+	SplitWords
+	// SplitLines breaks text only after a newline.
+	SplitLines
+)
+
+// maxHeldWord bounds how much text SplitWords holds back waiting for whitespace.
+const maxHeldWord = 64
+
+// WithDeltaSplitting re-splits text, thinking and tool call deltas so each
+// one ends on the given boundary, for consumers that render every delta as
+// it arrives. The tail after the last boundary is held back and prepended to
+// the next delta of the same block. Held text is released when another block
+// starts, before any message, and when the step ends. Tool call arguments are
+// only ever split on runes. Combined with WithDeltaCoalescing, splitting
+// applies first.
+//
+// Without this option steps behave as with SplitRunes: providers may cut a
+// chunk in the middle of a multibyte character, so deltas are always
+// realigned on runes, and invalid UTF-8 from a misbehaving provider is
+// replaced with U+FFFD. Deltas are never emitted as invalid UTF-8.
+func WithDeltaSplitting(mode DeltaSplit) StepOption {
+	return func(c *stepConfig) { c.split = mode }
+}
+
+type splitter struct {
+	mode DeltaSplit
+	out  func(MessageDelta)
+
+	mu   sync.Mutex
+	held MessageDelta
+}
+
+// splitEmitter returns e with deltas routed through a splitter, and a
+// function that releases any held text.
+func splitEmitter(e stepEmitter, mode DeltaSplit) (stepEmitter, func()) {
+	if e.onDelta == nil {
+		return e, func() {}
+	}
+	if mode == 0 {
+		mode = SplitRunes
+	}
+	s := &splitter{mode: mode, out: e.onDelta}
+	onMessage := e.onMessage
+	e.onDelta = s.push
+	e.onMessage = func(m Message) {
+		s.flush()
+		if onMessage != nil {
+			onMessage(m)
+		}
+	}
+	return e, s.flush
+}
+
+func (s *splitter) push(d MessageDelta) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held != nil {
+		if joined, ok := joinDelta(s.held, d); ok {
+			d = joined
+		} else {
+			s.out(sanitizeDelta(s.held))
+		}
+		s.held = nil
+	}
+
+	var emit, hold MessageDelta
+	switch d := d.(type) {
+	case TextDelta:
+		head, tail := cutDelta(d.Delta, s.mode)
+		emit, hold = nonEmpty(TextDelta{Delta: head}, head), nonEmpty(TextDelta{Delta: tail}, tail)
+		if emit == nil && hold == nil {
+			emit = d
+		}
+	case ThinkingDelta:
+		if d.Signature != "" {
+			d.Delta = validUTF8(d.Delta)
+			s.out(d)
+			return
+		}
+		head, tail := cutDelta(d.Delta, s.mode)
+		emit, hold = nonEmpty(ThinkingDelta{ID: d.ID, Delta: head}, head), nonEmpty(ThinkingDelta{ID: d.ID, Delta: tail}, tail)
+		if emit == nil && hold == nil {
+			emit = d
+		}
+	case ToolCallDelta:
+		head, tail := cutDelta(d.ArgsDelta, SplitRunes)
+		// The first delta of a call announces it even without arguments.
+		emit = ToolCallDelta{CallID: d.CallID, Name: d.Name, ArgsDelta: head}
+		hold = nonEmpty(ToolCallDelta{CallID: d.CallID, Name: d.Name, ArgsDelta: tail}, tail)
+	default:
+		s.out(d)
+		return
+	}
+	if emit != nil {
+		s.out(sanitizeDelta(emit))
+	}
+	s.held = hold
+}
+
+func (s *splitter) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held != nil {
+		s.out(sanitizeDelta(s.held))
+		s.held = nil
+	}
+}
+
+// cutDelta splits text into the part ending on a boundary and the tail to hold.
+func cutDelta(text string, mode DeltaSplit) (string, string) {
+	end := completeRunes(text)
+	if mode == SplitRunes || end == 0 {
+		return text[:end], text[end:]
+	}
+	var i int
+	if mode == SplitLines {
+		i = strings.LastIndexByte(text[:end], '\n') + 1
+	} else {
+		i = strings.LastIndexFunc(text[:end], unicode.IsSpace)
+		if i >= 0 {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+		} else {
+			i = 0
+		}
+		if len(text)-i > maxHeldWord {
+			i = end
+		}
+	}
+	return text[:i], text[i:]
+}
+
+// completeRunes returns the length of the longest prefix of text that does
+// not end in an incomplete UTF-8 sequence.
+func completeRunes(text string) int {
+	for i := len(text) - 1; i >= 0 && i >= len(text)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(text[i]) {
+			if utf8.FullRuneInString(text[i:]) {
+				return len(text)
+			}
+			return i
+		}
+	}
+	return len(text)
+}
+
+// sanitizeDelta replaces invalid UTF-8, such as a tail the provider never
+// completed, with U+FFFD.
+func sanitizeDelta(d MessageDelta) MessageDelta {
+	switch d := d.(type) {
+	case TextDelta:
+		d.Delta = validUTF8(d.Delta)
+		return d
+	case ThinkingDelta:
+		d.Delta = validUTF8(d.Delta)
+		return d
+	case ToolCallDelta:
+		d.ArgsDelta = validUTF8(d.ArgsDelta)
+		return d
+	}
+	return d
+}
+
+// validUTF8Message replaces invalid UTF-8 in the text parts of a final message.
+// Thinking is left alone since it may be covered by a provider signature.
+func validUTF8Message(msg AssistantMessage) AssistantMessage {
+	copied := false
+	for i, part := range msg.Parts {
+		p, ok := part.(TextPart)
+		if !ok || utf8.ValidString(p.Text) {
+			continue
+		}
+		if !copied {
+			msg.Parts = append([]Part(nil), msg.Parts...)
+			copied = true
+		}
+		p.Text = validUTF8(p.Text)
+		msg.Parts[i] = p
+	}
+	return msg
+}
+
+func validUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return strings.ToValidUTF8(s, string(utf8.RuneError))
+}
+
+func nonEmpty(d MessageDelta, text string) MessageDelta {
+	if text == "" {
+		return nil
+	}
+	return d
+}
+
+// joinDelta prepends held text to next when both belong to the same block.
+func joinDelta(held, next MessageDelta) (MessageDelta, bool) {
+	switch h := held.(type) {
+	case TextDelta:
+		if n, ok := next.(TextDelta); ok {
+			n.Delta = h.Delta + n.Delta
+			return n, true
+		}
+	case ThinkingDelta:
+		if n, ok := next.(ThinkingDelta); ok && n.ID == h.ID {
+			n.Delta = h.Delta + n.Delta
+			return n, true
+		}
+	case ToolCallDelta:
+		if n, ok := next.(ToolCallDelta); ok && n.CallID == h.CallID {
+			n.ArgsDelta = h.ArgsDelta + n.ArgsDelta
+			return n, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,112 @@
+package step_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/inspirepan/step"
+)
+
+// chunkProvider streams the given text deltas verbatim, then the joined message.
+type chunkProvider []string
+
+func (p chunkProvider) Stream(context.Context, step.ProviderRequest) (step.ProviderStream, error) {
+	var text string
+	ups := make([]step.ProviderUpdate, 0, len(p)+1)
+	for _, chunk := range p {
+		text += chunk
+		ups = append(ups, step.ProviderDeltaUpdate{Delta: step.TextDelta{Delta: chunk}})
+	}
+	ups = append(ups, step.ProviderMessageUpdate{Message: step.AssistantMessage{Parts: []step.Part{step.TextPart{Text: text}}, StopReason: step.StopStop}})
+	return &updateStream{ups: ups}, nil
+}
+
+type updateStream struct{ ups []step.ProviderUpdate }
+
+func (s *updateStream) Next(context.Context) (step.ProviderUpdate, error) {
+	if len(s.ups) == 0 {
+		return nil, io.EOF
+	}
+	up := s.ups[0]
+	s.ups = s.ups[1:]
+	return up, nil
+}
+
+func (s *updateStream) Close() error { return nil }
+
+func splitDeltas(t *testing.T, chunks []string, opts ...step.StepOption) []string {
+	t.Helper()
+	var got []string
+	opts = append(opts, step.WithOnDelta(func(d step.MessageDelta) {
+		if td, ok := d.(step.TextDelta); ok {
+			got = append(got, td.Delta)
+		}
+	}))
+	if _, err := step.Step(context.Background(), step.StepRequest{Provider: chunkProvider(chunks)}, opts...); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	return got
+}
+
+func TestDeltaSplitting(t *testing.T) {
+	// "héllo wörld\nbye" with both multibyte characters cut in half.
+	chunks := []string{"h\xc3", "\xa9llo w\xc3", "\xb6rld\nb", "ye"}
+
+	tests := []struct {
+		mode step.DeltaSplit
+		want []string
+	}{
+		{step.SplitRunes, []string{"h", "éllo w", "örld\nb", "ye"}},
+		{step.SplitWords, []string{"héllo ", "wörld\n", "bye"}},
+		{step.SplitLines, []string{"héllo wörld\n", "bye"}},
+	}
+	for _, tt := range tests {
+		got := splitDeltas(t, chunks, step.WithDeltaSplitting(tt.mode))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mode %d: got %q, want %q", tt.mode, got, tt.want)
+		}
+		for _, d := range got {
+			if !utf8.ValidString(d) {
+				t.Errorf("mode %d: invalid UTF-8 delta %q", tt.mode, d)
+			}
+		}
+	}
+}
+
+func TestDeltaSplitting_LongWordIsReleased(t *testing.T) {
+	long := string(make([]byte, 100))
+	got := splitDeltas(t, []string{long, "end"}, step.WithDeltaSplitting(step.SplitWords))
+	if len(got) != 2 || got[0] != long {
+		t.Errorf("expected the long word to be released, got %d deltas", len(got))
+	}
+}
+
+func TestStep_InvalidUTF8IsNeverEmitted(t *testing.T) {
+	// A provider that emits a stray byte mid-text and never completes a trailing sequence.
+	chunks := []string{"a\xffb", "€"[:2]}
+	var deltas []string
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: chunkProvider(chunks)}, step.WithOnDelta(func(d step.MessageDelta) {
+		if td, ok := d.(step.TextDelta); ok {
+			deltas = append(deltas, td.Delta)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if want := []string{"a�b", "�"}; !reflect.DeepEqual(deltas, want) {
+		t.Errorf("got deltas %q, want %q", deltas, want)
+	}
+	if text := result[0].(step.AssistantMessage).Text(); !utf8.ValidString(text) {
+		t.Errorf("final text is invalid UTF-8: %q", text)
+	}
+}
+
+func TestStep_SplitRuneIsReassembledByDefault(t *testing.T) {
+	got := splitDeltas(t, []string{"日", "本"[:1], "本"[1:] + "語"})
+	if want := []string{"日", "本語"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
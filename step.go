@@ -17,11 +17,39 @@ type StepOption func(*stepConfig)
 
 type stepConfig struct {
 	stepEmitter
+	hooks      stepHooks
+	middleware []Middleware
+	stream     streamConfig
+	coalesce   coalesceConfig
+	split      DeltaSplit
+
+	partialArgs    bool
+	model          string
+	effort         ReasoningEffort
+	efficientTools bool
+	steering       *Steering
+	pauser         *Pauser
+	quota          QuotaManager
+	toolExec       toolExecConfig
+	phases         phaseConfig
+}
+
+func newStepConfig(opts []StepOption) stepConfig {
+	var cfg stepConfig
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	return cfg
 }
 
 // StepCallbacks provides optional hooks for observing streaming updates.
 //
-// Callbacks are invoked sequentially in the caller goroutine. Keep them fast.
+// Callbacks are invoked sequentially in the caller goroutine. Keep them fast:
+// a slow OnDelta directly slows down the step, since deltas are never buffered
+// or dropped on this path. Use StepStream for buffered delivery with a
+// configurable backpressure policy.
 type StepCallbacks struct {
 	OnDelta   func(MessageDelta)
 	OnMessage func(Message)
@@ -49,6 +77,67 @@ func WithOnMessage(fn func(Message)) StepOption {
 	return func(c *stepConfig) { c.onMessage = fn }
 }
 
+// WithOnRawChunk configures a hook that receives every chunk exactly as the
+// provider sent it, for provider-specific fields not modeled by step. Setting
+// it asks the provider for raw chunks (see ProviderRequest.Raw); providers
+// that do not support it never call fn.
+func WithOnRawChunk(fn func(ProviderRawUpdate)) StepOption {
+	return func(c *stepConfig) { c.onRaw = fn }
+}
+
+// stepHooks are lifecycle hooks; each list runs in the order the hooks were added.
+type stepHooks struct {
+	beforeProviderCall []func(*ProviderRequest)
+	afterAssistant     []func(AssistantMessage)
+	afterTools         []func([]ToolResultMessage)
+}
+
+// WithOnBeforeProviderCall adds a hook that runs before the provider is called.
+// It may modify the request, e.g. to inject context or filter tools; changes
+// apply to this call only and never touch StepRequest.History.
+func WithOnBeforeProviderCall(fn func(req *ProviderRequest)) StepOption {
+	return func(c *stepConfig) {
+		if fn != nil {
+			c.hooks.beforeProviderCall = append(c.hooks.beforeProviderCall, fn)
+		}
+	}
+}
+
+// WithOnAfterAssistant adds a hook that runs with the final assistant message,
+// before any of its tool calls are executed.
+func WithOnAfterAssistant(fn func(msg AssistantMessage)) StepOption {
+	return func(c *stepConfig) {
+		if fn != nil {
+			c.hooks.afterAssistant = append(c.hooks.afterAssistant, fn)
+		}
+	}
+}
+
+// WithOnAfterTools adds a hook that runs with the tool results once all tool
+// calls of the step have finished, in call order. It does not run when the
+// assistant made no tool calls.
+func WithOnAfterTools(fn func(results []ToolResultMessage)) StepOption {
+	return func(c *stepConfig) {
+		if fn != nil {
+			c.hooks.afterTools = append(c.hooks.afterTools, fn)
+		}
+	}
+}
+
+// WithModel runs the step on model instead of the provider's configured
+// model, e.g. a cheaper model for a summarization step on the same provider.
+// See ProviderRequest.ModelOverride.
+func WithModel(model string) StepOption {
+	return func(c *stepConfig) { c.model = model }
+}
+
+// WithReasoningEffort sets the reasoning effort for the step, overriding the
+// provider's configured effort or thinking budget, e.g. high for planning and
+// minimal for routine tool loops. See ProviderRequest.ReasoningEffort.
+func WithReasoningEffort(effort ReasoningEffort) StepOption {
+	return func(c *stepConfig) { c.effort = effort }
+}
+
 // StepResult is the sequence of new messages produced by a step.
 // It is safe to append to the conversation history.
 type StepResult []Message
@@ -64,12 +153,10 @@ func (r StepResult) HasToolCall() bool {
 }
 
 // Step runs one step synchronously.
+//
+// When ctx is done or the provider connection drops mid-response, the result
+// holds the partial assistant message with StopAborted and its CancelReason,
+// along with the error.
 func Step(ctx context.Context, req StepRequest, opts ...StepOption) (StepResult, error) {
-	var cfg stepConfig
-	for _, opt := range opts {
-		if opt != nil {
-			opt(&cfg)
-		}
-	}
-	return runStep(ctx, req, cfg)
+	return newStepConfig(opts).stepFunc()(ctx, req)
 }
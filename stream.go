@@ -0,0 +1,294 @@
+package step
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StepEventType describes the kind of a StepEvent.
+type StepEventType string
+
+const (
+	StepEventDelta   StepEventType = "delta"
+	StepEventMessage StepEventType = "message"
+	StepEventDone    StepEventType = "done"
+)
+
+// StepEvent is a single update emitted by StepStream.
+type StepEvent struct {
+	Type StepEventType
+	// Seq increases by one for every event produced, starting at 1. Dropped deltas
+	// consume a sequence number, so gaps reveal dropped events.
+	Seq uint64
+	// Time is when the event was produced.
+	Time time.Time
+
+	// Delta is set for StepEventDelta.
+	Delta MessageDelta
+	// Message is set for StepEventMessage.
+	Message Message
+
+	// Result, Err, Dropped and Stats are set for StepEventDone.
+	Result  StepResult
+	Err     error
+	Dropped int64
+	Stats   StreamStats
+}
+
+// StreamStats summarizes the timing of a streamed step.
+type StreamStats struct {
+	// TimeToFirstToken is the time from step start to the first text, thinking or tool call delta.
+	// It is zero if no such delta was produced.
+	TimeToFirstToken time.Duration
+	// Duration is the total wall time of the step, including tool execution.
+	Duration time.Duration
+	// GenerationDuration is the time from the first token to the final assistant message.
+	GenerationDuration time.Duration
+	// OutputTokens is taken from the assistant message usage, if reported.
+	OutputTokens int
+	// TokensPerSecond is OutputTokens divided by GenerationDuration.
+	TokensPerSecond float64
+}
+
+// BackpressurePolicy controls what StepStream does when the consumer falls behind.
+//
+// Only delta events are ever dropped. Message and done events always block until
+// they are delivered, so the conversation history seen by the consumer is complete.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock blocks the step until the consumer makes room. This is the default.
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureBlockTimeout blocks up to the configured timeout, then drops the delta.
+	BackpressureBlockTimeout
+	// BackpressureDropOldest never blocks on deltas; when the buffer is full the oldest
+	// buffered delta is discarded to make room (ring buffer semantics).
+	BackpressureDropOldest
+)
+
+const defaultEventBuffer = 64
+
+type streamConfig struct {
+	buffer  int
+	policy  BackpressurePolicy
+	timeout time.Duration
+}
+
+// WithEventBuffer sets the StepStream event buffer size. Defaults to 64.
+func WithEventBuffer(n int) StepOption {
+	return func(c *stepConfig) { c.stream.buffer = n }
+}
+
+// WithBackpressure sets the StepStream backpressure policy.
+// timeout is only used by BackpressureBlockTimeout.
+func WithBackpressure(policy BackpressurePolicy, timeout time.Duration) StepOption {
+	return func(c *stepConfig) {
+		c.stream.policy = policy
+		c.stream.timeout = timeout
+	}
+}
+
+// StepEventStream delivers the events of a step running in the background.
+// Consumers must drain Events until it is closed, or call Close when they stop
+// reading early; the channel is closed after the done event.
+type StepEventStream struct {
+	events  chan StepEvent
+	dropped atomic.Int64
+	cancel  context.CancelFunc
+	stop    chan struct{}
+	stopped sync.Once
+
+	mu     sync.Mutex
+	seq    uint64
+	queue  []StepEvent
+	closed bool
+	notify chan struct{}
+	space  chan struct{}
+	cfg    streamConfig
+}
+
+// StepStream runs one step in a new goroutine and streams its events.
+// Callbacks configured via options are still invoked, before the event is queued.
+func StepStream(ctx context.Context, req StepRequest, opts ...StepOption) *StepEventStream {
+	cfg := newStepConfig(opts)
+	if cfg.stream.buffer <= 0 {
+		cfg.stream.buffer = defaultEventBuffer
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &StepEventStream{
+		events: make(chan StepEvent),
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		notify: make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+		cfg:    cfg.stream,
+	}
+
+	timing := newTokenTiming()
+	userDelta, userMessage := cfg.onDelta, cfg.onMessage
+	cfg.onDelta = func(d MessageDelta) {
+		if userDelta != nil {
+			userDelta(d)
+		}
+		timing.delta(d)
+		s.emit(StepEvent{Type: StepEventDelta, Delta: d})
+	}
+	cfg.onMessage = func(m Message) {
+		if userMessage != nil {
+			userMessage(m)
+		}
+		if am, ok := m.(AssistantMessage); ok {
+			timing.generation(am.Usage)
+		}
+		s.emit(StepEvent{Type: StepEventMessage, Message: m})
+	}
+
+	go s.pump()
+	go func() {
+		defer cancel()
+		result, err := cfg.stepFunc()(ctx, req)
+		s.emit(StepEvent{Type: StepEventDone, Result: result, Err: err, Dropped: s.dropped.Load(), Stats: timing.streamStats()})
+		s.close()
+	}()
+	return s
+}
+
+// Events returns the event channel.
+func (s *StepEventStream) Events() <-chan StepEvent { return s.events }
+
+// Dropped returns the number of delta events dropped so far due to backpressure.
+func (s *StepEventStream) Dropped() int64 { return s.dropped.Load() }
+
+// Close cancels the step and stops delivering its events, for consumers that
+// stop reading Events before the done event. Events is closed soon after and
+// undelivered events are discarded. Close may be called more than once, and
+// after the stream ended.
+func (s *StepEventStream) Close() {
+	s.stopped.Do(func() {
+		close(s.stop)
+		s.cancel()
+	})
+}
+
+// Wait drains the stream and returns the step result.
+func (s *StepEventStream) Wait() (StepResult, error) {
+	var result StepResult
+	var err error
+	for ev := range s.events {
+		if ev.Type == StepEventDone {
+			result, err = ev.Result, ev.Err
+		}
+	}
+	return result, err
+}
+
+// emit queues an event according to the backpressure policy. Seq is assigned
+// under s.mu, so the queue is in Seq order even with concurrent emitters.
+func (s *StepEventStream) emit(ev StepEvent) {
+	select {
+	case <-s.stop:
+		return
+	default:
+	}
+	ev.Time = time.Now()
+	droppable := ev.Type == StepEventDelta
+
+	var deadline <-chan time.Time
+	if droppable && s.cfg.policy == BackpressureBlockTimeout {
+		timer := time.NewTimer(s.cfg.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	s.mu.Lock()
+	if s.cfg.policy == BackpressureDropOldest {
+		// Non-droppable events never wait in ring mode; the queue may grow past the buffer.
+		if droppable && len(s.queue) >= s.cfg.buffer {
+			s.dropOldestDeltaLocked()
+		}
+	} else {
+		for len(s.queue) >= s.cfg.buffer {
+			s.mu.Unlock()
+			select {
+			case <-s.space:
+			case <-deadline:
+				// The dropped delta consumes a Seq, leaving a gap.
+				s.mu.Lock()
+				s.seq++
+				s.mu.Unlock()
+				s.dropped.Add(1)
+				return
+			case <-s.stop:
+				return
+			}
+			s.mu.Lock()
+		}
+	}
+	s.seq++
+	ev.Seq = s.seq
+	s.queue = append(s.queue, ev)
+	if len(s.queue) < s.cfg.buffer {
+		// Pass the wakeup on to other producers waiting for space.
+		signal(s.space)
+	}
+	s.mu.Unlock()
+	signal(s.notify)
+}
+
+func (s *StepEventStream) dropOldestDeltaLocked() {
+	for i, queued := range s.queue {
+		if queued.Type == StepEventDelta {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.dropped.Add(1)
+			return
+		}
+	}
+}
+
+func (s *StepEventStream) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	signal(s.notify)
+}
+
+// pump moves queued events to the consumer channel until the stream ends or
+// is closed.
+func (s *StepEventStream) pump() {
+	defer close(s.events)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-s.notify:
+			case <-s.stop:
+				return
+			}
+			continue
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		signal(s.space)
+		select {
+		case s.events <- ev:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,177 @@
+package step_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+func TestStepStreamDropOldest(t *testing.T) {
+	const n = 50
+	parts := make([]step.Part, n)
+	for i := range parts {
+		parts[i] = step.TextPart{Text: "x"}
+	}
+	provider := mock.New(mock.Response{Message: step.AssistantMessage{Parts: parts}})
+
+	s := step.StepStream(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+	}, step.WithEventBuffer(1), step.WithBackpressure(step.BackpressureDropOldest, 0))
+
+	// Let the step finish before consuming so the buffer overflows.
+	time.Sleep(50 * time.Millisecond)
+
+	var deltas, messages int
+	var done *step.StepEvent
+	var lastSeq uint64
+	for ev := range s.Events() {
+		if done != nil {
+			t.Fatalf("event after done: %+v", ev)
+		}
+		if ev.Seq <= lastSeq {
+			t.Fatalf("sequence not increasing: %d after %d", ev.Seq, lastSeq)
+		}
+		lastSeq = ev.Seq
+		switch ev.Type {
+		case step.StepEventDelta:
+			deltas++
+		case step.StepEventMessage:
+			messages++
+		case step.StepEventDone:
+			ev := ev
+			done = &ev
+		}
+	}
+	if done == nil {
+		t.Fatal("expected done event")
+	}
+	if done.Err != nil {
+		t.Fatalf("unexpected error: %v", done.Err)
+	}
+	if messages != 1 {
+		t.Errorf("expected 1 message event, got %d", messages)
+	}
+	if done.Dropped == 0 {
+		t.Error("expected dropped deltas")
+	}
+	// n text deltas plus the step status delta.
+	if got := int64(deltas) + done.Dropped; got != n+1 {
+		t.Errorf("delivered+dropped = %d, want %d", got, n+1)
+	}
+	if want := uint64(deltas+messages+1) + uint64(done.Dropped); done.Seq != want {
+		t.Errorf("done seq = %d, want %d", done.Seq, want)
+	}
+	if done.Stats.Duration <= 0 {
+		t.Error("expected positive duration")
+	}
+}
+
+func TestStepStreamBlock(t *testing.T) {
+	provider := mock.New(mock.Text("hello"))
+	s := step.StepStream(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+	}, step.WithEventBuffer(1))
+
+	result, err := s.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(result))
+	}
+	if s.Dropped() != 0 {
+		t.Errorf("expected no drops, got %d", s.Dropped())
+	}
+}
+
+func TestStepStreamBlockTimeout(t *testing.T) {
+	const n = 20
+	parts := make([]step.Part, n)
+	for i := range parts {
+		parts[i] = step.TextPart{Text: "x"}
+	}
+	provider := mock.New(mock.Response{Message: step.AssistantMessage{Parts: parts}})
+
+	s := step.StepStream(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+	}, step.WithEventBuffer(1), step.WithBackpressure(step.BackpressureBlockTimeout, time.Millisecond))
+
+	// A stalled consumer: deltas time out while the buffer is full.
+	time.Sleep(100 * time.Millisecond)
+
+	var lastSeq, gaps uint64
+	var deltas int
+	var done step.StepEvent
+	for ev := range s.Events() {
+		if ev.Seq <= lastSeq {
+			t.Fatalf("sequence not increasing: %d after %d", ev.Seq, lastSeq)
+		}
+		gaps += ev.Seq - lastSeq - 1
+		lastSeq = ev.Seq
+		switch ev.Type {
+		case step.StepEventDelta:
+			deltas++
+		case step.StepEventDone:
+			done = ev
+		}
+	}
+	if done.Err != nil {
+		t.Fatalf("unexpected error: %v", done.Err)
+	}
+	if s.Dropped() == 0 || done.Dropped != s.Dropped() {
+		t.Fatalf("dropped %d, done event reports %d; want drops", s.Dropped(), done.Dropped)
+	}
+	if gaps != uint64(s.Dropped()) {
+		t.Errorf("seq gaps %d, want one per dropped delta (%d)", gaps, s.Dropped())
+	}
+	// n text deltas plus the step status delta.
+	if got := int64(deltas) + s.Dropped(); got != n+1 {
+		t.Errorf("delivered+dropped = %d, want %d", got, n+1)
+	}
+}
+
+func TestStepStreamClose(t *testing.T) {
+	r := mock.Text("late")
+	r.Delay = time.Minute
+	s := step.StepStream(context.Background(), step.StepRequest{
+		Provider: mock.New(r),
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "hi"}}}},
+	}, step.WithEventBuffer(1))
+
+	s.Close()
+	s.Close()
+	select {
+	case _, ok := <-s.Events():
+		for ok {
+			_, ok = <-s.Events()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Events not closed after Close")
+	}
+}
+
+func TestStep_MessageStats(t *testing.T) {
+	resp := mock.Text("Hello there.")
+	resp.Delay = 20 * time.Millisecond
+	resp.Message.Usage = &step.Usage{OutputTokens: 3}
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: mock.New(resp)})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	st := result[0].(step.AssistantMessage).Stats
+	if st == nil {
+		t.Fatal("expected stats on the assistant message")
+	}
+	if st.TimeToFirstTokenMs < 20 || st.DurationMs < st.TimeToFirstTokenMs {
+		t.Errorf("unexpected timing %+v", st)
+	}
+	if st.OutputTokensPerSecond <= 0 {
+		t.Errorf("expected a token rate, got %+v", st)
+	}
+}
@@ -0,0 +1,45 @@
+package testkit
+
+import (
+	"testing"
+
+	"github.com/inspirepan/step"
+)
+
+// ConformanceTest is a single named conformance check.
+type ConformanceTest struct {
+	Name string
+	Run  func(t *testing.T, cfg TestConfig)
+}
+
+// ConformanceTests returns the checks run by RunConformanceSuite, in order.
+func ConformanceTests() []ConformanceTest {
+	return []ConformanceTest{
+		{Name: "BasicTextGeneration", Run: TestBasicTextGeneration},
+		{Name: "ToolCalling", Run: TestToolCalling},
+		{Name: "SystemPrompt", Run: TestSystemPrompt},
+		{Name: "MultiTurn", Run: TestMultiTurn},
+		{Name: "ThinkingRoundTrip", Run: TestThinkingRoundTrip},
+		{Name: "ImageInput", Run: TestImageInput},
+		{Name: "Cancellation", Run: TestCancellation},
+		{Name: "ParallelToolCalls", Run: TestParallelToolCalls},
+		{Name: "EmptyResponse", Run: TestEmptyResponse},
+	}
+}
+
+// RunConformanceSuite verifies that provider implements the step.Provider contract
+// against a live backend. Each check runs as a subtest.
+func RunConformanceSuite(t *testing.T, provider step.Provider) {
+	t.Helper()
+	RunConformanceSuiteWithConfig(t, DefaultConfig(provider))
+}
+
+// RunConformanceSuiteWithConfig is like RunConformanceSuite with a custom config.
+func RunConformanceSuiteWithConfig(t *testing.T, cfg TestConfig) {
+	t.Helper()
+	for _, tc := range ConformanceTests() {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.Run(t, cfg)
+		})
+	}
+}
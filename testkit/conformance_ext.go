@@ -0,0 +1,256 @@
+package testkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// streamOutcome collects everything a provider stream produced.
+type streamOutcome struct {
+	text    strings.Builder
+	deltas  []step.MessageDelta
+	message *step.AssistantMessage
+}
+
+// drain reads a stream to completion. It returns the first non-EOF error.
+func drain(ctx context.Context, stream step.ProviderStream, out *streamOutcome, onDelta func(step.MessageDelta)) error {
+	for {
+		up, err := stream.Next(ctx)
+		if up != nil {
+			switch u := up.(type) {
+			case step.ProviderDeltaUpdate:
+				out.deltas = append(out.deltas, u.Delta)
+				if d, ok := u.Delta.(step.TextDelta); ok {
+					out.text.WriteString(d.Delta)
+				}
+				if onDelta != nil {
+					onDelta(u.Delta)
+				}
+			case step.ProviderMessageUpdate:
+				msg := u.Message
+				out.message = &msg
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func streamOnce(t *testing.T, ctx context.Context, provider step.Provider, req step.ProviderRequest) *streamOutcome {
+	t.Helper()
+	stream, err := provider.Stream(ctx, req)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	defer stream.Close()
+
+	var out streamOutcome
+	if err := drain(ctx, stream, &out, nil); err != nil {
+		t.Fatalf("stream.Next failed: %v", err)
+	}
+	return &out
+}
+
+func partsOf[T step.Part](msg *step.AssistantMessage) []T {
+	if msg == nil {
+		return nil
+	}
+	var out []T
+	for _, part := range msg.Parts {
+		if p, ok := part.(T); ok {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// TestThinkingRoundTrip tests that thinking parts produced by the provider can be sent back in history.
+// It is skipped when the provider does not return any thinking.
+func TestThinkingRoundTrip(t *testing.T, cfg TestConfig) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	question := step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Think step by step: what is 17 * 23?"}}}
+	first := streamOnce(t, ctx, cfg.Provider, step.ProviderRequest{History: []step.Message{question}})
+	if first.message == nil {
+		t.Fatal("expected assistant message")
+	}
+	thinking := partsOf[step.ThinkingPart](first.message)
+	if len(thinking) == 0 {
+		t.Skip("provider returned no thinking parts")
+	}
+
+	second := streamOnce(t, ctx, cfg.Provider, step.ProviderRequest{History: []step.Message{
+		question,
+		*first.message,
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Now add 9 to that result. Reply with just the number."}}},
+	}})
+	if second.message == nil {
+		t.Fatal("expected assistant message on second turn")
+	}
+	if !strings.Contains(second.text.String()+textOf(second.message), "400") {
+		t.Errorf("expected 400 in response, got: %s", second.text.String())
+	}
+	t.Logf("thinking parts: %d, second response: %q", len(thinking), second.text.String())
+}
+
+// redSquarePNG returns a base64-encoded solid red PNG.
+func redSquarePNG() string {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// TestImageInput tests that ImagePart input is understood.
+func TestImageInput(t *testing.T, cfg TestConfig) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	out := streamOnce(t, ctx, cfg.Provider, step.ProviderRequest{History: []step.Message{
+		step.UserMessage{Parts: []step.Part{
+			step.ImagePart{MimeType: "image/png", DataB64: redSquarePNG()},
+			step.TextPart{Text: "What color is this image? Answer with one word."},
+		}},
+	}})
+	text := strings.ToLower(out.text.String() + textOf(out.message))
+	if !strings.Contains(text, "red") {
+		t.Errorf("expected response to mention red, got: %s", text)
+	}
+	t.Logf("response: %q", out.text.String())
+}
+
+// TestCancellation tests that cancelling the context mid-stream terminates the stream promptly.
+func TestCancellation(t *testing.T, cfg TestConfig) {
+	t.Helper()
+
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancelTimeout()
+	ctx, cancel := context.WithCancel(timeoutCtx)
+	defer cancel()
+
+	stream, err := cfg.Provider.Stream(ctx, step.ProviderRequest{History: []step.Message{
+		step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Count from 1 to 500, one number per line."}}},
+	}})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	defer stream.Close()
+
+	var out streamOutcome
+	var cancelledAt time.Time
+	err = drain(ctx, stream, &out, func(step.MessageDelta) {
+		if cancelledAt.IsZero() {
+			cancelledAt = time.Now()
+			cancel()
+		}
+	})
+	if cancelledAt.IsZero() {
+		t.Fatal("expected at least one delta before completion")
+	}
+	if elapsed := time.Since(cancelledAt); elapsed > 5*time.Second {
+		t.Errorf("stream took %s to stop after cancellation", elapsed)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Errorf("expected nil or context.Canceled, got %v", err)
+	}
+	if out.message != nil && strings.Count(textOf(out.message), "\n") > 400 {
+		t.Error("expected a partial message after cancellation")
+	}
+	t.Logf("deltas before stop: %d, final message: %v", len(out.deltas), out.message != nil)
+}
+
+// TestParallelToolCalls tests that independent tool calls can be returned in a single turn.
+// Providers that serialize calls are reported via t.Log rather than failing.
+func TestParallelToolCalls(t *testing.T, cfg TestConfig) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	tool := calculatorTool{}
+	out := streamOnce(t, ctx, cfg.Provider, step.ProviderRequest{
+		SystemPrompt: "You are a calculator assistant. Always call the add tool for every addition, all at once in parallel.",
+		History: []step.Message{
+			step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Compute 1+2, 3+4 and 5+6 using the add tool."}}},
+		},
+		Tools: []step.ToolSpec{tool.Spec()},
+	})
+	if out.message == nil {
+		t.Fatal("expected assistant message")
+	}
+	calls := partsOf[step.ToolCallPart](out.message)
+	if len(calls) == 0 {
+		t.Fatal("expected at least one tool call")
+	}
+	seen := map[string]bool{}
+	for _, c := range calls {
+		if c.CallID == "" {
+			t.Error("expected non-empty call id")
+		}
+		if seen[c.CallID] {
+			t.Errorf("duplicate call id %q", c.CallID)
+		}
+		seen[c.CallID] = true
+	}
+	if out.message.StopReason != step.StopToolUse {
+		t.Errorf("expected stop reason %q, got %q", step.StopToolUse, out.message.StopReason)
+	}
+	if len(calls) < 3 {
+		t.Logf("provider returned %d tool call(s) instead of 3 parallel calls", len(calls))
+	}
+}
+
+// TestEmptyResponse tests that a final message is emitted even when the model produces little or no text.
+func TestEmptyResponse(t *testing.T, cfg TestConfig) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	out := streamOnce(t, ctx, cfg.Provider, step.ProviderRequest{
+		SystemPrompt: "Reply with an empty message. Do not output any characters.",
+		History: []step.Message{
+			step.UserMessage{Parts: []step.Part{step.TextPart{Text: "."}}},
+		},
+	})
+	if out.message == nil {
+		t.Fatal("expected assistant message even for empty output")
+	}
+	if out.message.StopReason == "" {
+		t.Error("expected non-empty stop reason")
+	}
+	t.Logf("parts: %d, text: %q", len(out.message.Parts), out.text.String())
+}
+
+func textOf(msg *step.AssistantMessage) string {
+	var sb strings.Builder
+	for _, p := range partsOf[step.TextPart](msg) {
+		sb.WriteString(p.Text)
+	}
+	return sb.String()
+}
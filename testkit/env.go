@@ -0,0 +1,275 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/tools"
+)
+
+// Env is an in-memory environment for the standard tools: a filesystem, web
+// pages and a shell whose commands are scripted. Tests set its state, run an
+// agent on its Tools with the mock provider, and inspect the state and the
+// commands and requests made, deterministically and without touching the
+// host. It is safe for concurrent use.
+type Env struct {
+	mu       sync.Mutex
+	files    map[string][]byte
+	pages    map[string]Page
+	commands map[string]CommandFunc
+	ran      []string
+	fetched  []string
+}
+
+// Page is a fake web page served by an Env.
+type Page struct {
+	// Status defaults to 200.
+	Status int
+	// ContentType defaults to text/html.
+	ContentType string
+	Body        string
+}
+
+// CommandFunc runs a scripted shell command in an Env. args are the words of
+// the command line, starting with the command name.
+type CommandFunc func(env *Env, args []string) (output string, exitCode int)
+
+// NewEnv returns an empty Env whose shell knows cat, echo and ls.
+func NewEnv() *Env {
+	e := &Env{files: map[string][]byte{}, pages: map[string]Page{}, commands: map[string]CommandFunc{}}
+	e.Command("cat", catCommand)
+	e.Command("echo", func(_ *Env, args []string) (string, int) { return strings.Join(args[1:], " ") + "\n", 0 })
+	e.Command("ls", lsCommand)
+	return e
+}
+
+// cleanPath makes paths relative to the root of the Env.
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// SetFile sets the content of the file at path.
+func (e *Env) SetFile(path, content string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.files[cleanPath(path)] = []byte(content)
+}
+
+// File returns the content of the file at path.
+func (e *Env) File(path string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	data, ok := e.files[cleanPath(path)]
+	return string(data), ok
+}
+
+// Files returns the paths of all files, sorted.
+func (e *Env) Files() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]string, 0, len(e.files))
+	for p := range e.files {
+		out = append(out, p)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// SetPage serves page at url. Requests match on the URL without its query
+// when no page has the full URL.
+func (e *Env) SetPage(url string, page Page) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pages[url] = page
+}
+
+// Command scripts the shell command name, replacing any previous script.
+func (e *Env) Command(name string, fn CommandFunc) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.commands[name] = fn
+}
+
+// Commands returns the command lines run so far.
+func (e *Env) Commands() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.ran)
+}
+
+// Requests returns the requests made so far, as "METHOD URL".
+func (e *Env) Requests() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.fetched)
+}
+
+// Executor returns a tools.Executor on the files and shell of the Env. The
+// shell runs each line of a script as one command, split into words at
+// spaces; it has no quoting, pipes, variables or directories, and runs every
+// command at the root. The first failing command ends the script.
+func (e *Env) Executor() tools.Executor { return envExecutor{e} }
+
+// Client returns an HTTP client that serves the pages of the Env; URLs
+// without a page get 404.
+func (e *Env) Client() *http.Client { return &http.Client{Transport: envTransport{e}} }
+
+// Tools returns the bash, read_file, write_file, edit_file and http_request
+// tools on the Env.
+func (e *Env) Tools() []step.Tool {
+	exec := e.Executor()
+	return []step.Tool{
+		tools.Bash(exec),
+		tools.ReadFile(exec),
+		tools.WriteFile(exec),
+		tools.EditFile(exec),
+		tools.HTTPRequest(tools.HTTPOptions{Client: e.Client()}),
+	}
+}
+
+// Simulate runs an agent with the tools of env and a mock provider playing
+// responses on prompt. The run fails with mock.ErrExhausted when the agent
+// needs more responses than scripted.
+func Simulate(ctx context.Context, env *Env, prompt string, responses ...mock.Response) (step.RunResult, error) {
+	agent := step.Agent{Provider: mock.New(responses...), Tools: env.Tools(), MaxSteps: len(responses)}
+	history := []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: prompt}}}}
+	return step.Run(ctx, agent, history)
+}
+
+type envExecutor struct{ env *Env }
+
+func (x envExecutor) Run(ctx context.Context, cmd tools.Command) (tools.CommandResult, error) {
+	var out strings.Builder
+	for _, line := range strings.Split(cmd.Script, "\n") {
+		if err := ctx.Err(); err != nil {
+			return tools.CommandResult{Output: []byte(out.String())}, err
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		e := x.env
+		e.mu.Lock()
+		e.ran = append(e.ran, strings.Join(args, " "))
+		fn := e.commands[args[0]]
+		e.mu.Unlock()
+		if fn == nil {
+			fmt.Fprintf(&out, "bash: %s: command not found\n", args[0])
+			return tools.CommandResult{Output: []byte(out.String()), ExitCode: 127}, nil
+		}
+		output, code := fn(e, args)
+		out.WriteString(output)
+		if code != 0 {
+			return tools.CommandResult{Output: []byte(out.String()), ExitCode: code}, nil
+		}
+	}
+	return tools.CommandResult{Output: []byte(out.String())}, nil
+}
+
+func (x envExecutor) ReadFile(_ context.Context, path string) ([]byte, error) {
+	content, ok := x.env.File(path)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return []byte(content), nil
+}
+
+func (x envExecutor) WriteFile(_ context.Context, path string, data []byte) error {
+	x.env.SetFile(path, string(data))
+	return nil
+}
+
+func catCommand(env *Env, args []string) (string, int) {
+	var out strings.Builder
+	for _, p := range args[1:] {
+		content, ok := env.File(p)
+		if !ok {
+			fmt.Fprintf(&out, "cat: %s: No such file or directory\n", p)
+			return out.String(), 1
+		}
+		out.WriteString(content)
+	}
+	return out.String(), 0
+}
+
+// lsCommand lists the files and directories directly in its argument.
+func lsCommand(env *Env, args []string) (string, int) {
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	prefix := cleanPath(dir) + "/"
+	if prefix == "/" {
+		prefix = ""
+	}
+	var names []string
+	for _, p := range env.Files() {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			rest = rest[:i+1]
+		}
+		if !slices.Contains(names, rest) {
+			names = append(names, rest)
+		}
+	}
+	if len(names) == 0 {
+		if _, ok := env.File(dir); ok {
+			return dir + "\n", 0
+		}
+		if prefix != "" {
+			return fmt.Sprintf("ls: cannot access '%s': No such file or directory\n", dir), 2
+		}
+	}
+	slices.Sort(names)
+	return strings.Join(append(names, ""), "\n"), 0
+}
+
+type envTransport struct{ env *Env }
+
+func (t envTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	e := t.env
+	e.mu.Lock()
+	e.fetched = append(e.fetched, req.Method+" "+req.URL.String())
+	page, ok := e.pages[req.URL.String()]
+	if !ok {
+		u := *req.URL
+		u.RawQuery = ""
+		page, ok = e.pages[u.String()]
+	}
+	e.mu.Unlock()
+	if !ok {
+		page = Page{Status: http.StatusNotFound, ContentType: "text/plain", Body: "404 page not found"}
+	}
+	if page.Status == 0 {
+		page.Status = http.StatusOK
+	}
+	if page.ContentType == "" {
+		page.ContentType = "text/html"
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", page.Status, http.StatusText(page.Status)),
+		StatusCode:    page.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {page.ContentType}},
+		Body:          io.NopCloser(strings.NewReader(page.Body)),
+		ContentLength: int64(len(page.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,75 @@
+package testkit_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/testkit"
+	"github.com/inspirepan/step/tools"
+)
+
+func toolCall(id, name string, args map[string]any) step.ToolCallPart {
+	data, _ := json.Marshal(args)
+	return step.ToolCallPart{CallID: id, Name: name, ArgsJSON: data}
+}
+
+func TestSimulate(t *testing.T) {
+	env := testkit.NewEnv()
+	env.SetFile("go.mod", "module example\n\ngo 1.21\n")
+	env.SetPage("https://go.dev/VERSION", testkit.Page{ContentType: "text/plain", Body: "go1.23.0"})
+	env.Command("go", func(env *testkit.Env, args []string) (string, int) {
+		if mod, _ := env.File("go.mod"); !strings.Contains(mod, "go 1.23") {
+			return "go.mod is outdated\n", 1
+		}
+		return "ok\n", 0
+	})
+
+	res, err := testkit.Simulate(context.Background(), env, "Update go.mod to the latest Go and test.",
+		mock.ToolCalls(
+			toolCall("1", "http_request", map[string]any{"url": "https://go.dev/VERSION?m=text"}),
+			toolCall("2", "read_file", map[string]any{"path": "go.mod"}),
+		),
+		mock.ToolCalls(toolCall("3", "edit_file", map[string]any{"path": "./go.mod", "old_string": "go 1.21", "new_string": "go 1.23"})),
+		mock.ToolCalls(toolCall("4", "bash", map[string]any{"command": "go test ./...\nls"})),
+		mock.Text("Updated to Go 1.23; tests pass."),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range res.Messages {
+		if r, ok := m.(step.ToolResultMessage); ok && r.IsError {
+			t.Errorf("tool %s failed: %v", r.Name, r.Parts)
+		}
+	}
+	if mod, _ := env.File("go.mod"); mod != "module example\n\ngo 1.23\n" {
+		t.Errorf("go.mod = %q", mod)
+	}
+	if got := env.Commands(); !slices.Equal(got, []string{"go test ./...", "ls"}) {
+		t.Errorf("commands = %v", got)
+	}
+	if got := env.Requests(); !slices.Equal(got, []string{"GET https://go.dev/VERSION?m=text"}) {
+		t.Errorf("requests = %v", got)
+	}
+	if last := res.Messages[len(res.Messages)-2].(step.ToolResultMessage); !strings.HasPrefix(last.Parts[0].(step.TextPart).Text, "ok\ngo.mod\n") {
+		t.Errorf("bash output = %v", last.Parts)
+	}
+}
+
+func TestEnv_Shell(t *testing.T) {
+	env := testkit.NewEnv()
+	env.SetFile("src/a.go", "package a\n")
+	env.SetFile("README", "hi\n")
+	res, err := env.Executor().Run(context.Background(), tools.Command{Script: "ls\nls src\ncat missing\necho never"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "README\nsrc/\na.go\ncat: missing: No such file or directory\n"
+	if string(res.Output) != want || res.ExitCode != 1 {
+		t.Errorf("got %q (exit %d), want %q", res.Output, res.ExitCode, want)
+	}
+}
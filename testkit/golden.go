@@ -0,0 +1,250 @@
+package testkit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+)
+
+// UpdateGoldenEnv rewrites golden files instead of comparing when set to a non-empty value.
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// DefaultIgnoredFields are volatile fields masked before golden comparison.
+var DefaultIgnoredFields = []string{"timestamp", "id", "parent_id", "call_id", "CallID", "ID", "signature", "Signature", "usage", "provenance", "stats"}
+
+const ignoredPlaceholder = "<ignored>"
+
+// TranscriptRecord is one captured streaming event.
+type TranscriptRecord struct {
+	// Kind is "delta" or "message".
+	Kind string `json:"kind"`
+	// Type is the Go type of the delta or message, e.g. "step.TextDelta".
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Recorder captures step callbacks in order.
+type Recorder struct {
+	Records []TranscriptRecord
+	err     error
+}
+
+// Options returns step options that feed the recorder.
+func (r *Recorder) Options() []step.StepOption {
+	return []step.StepOption{
+		step.WithOnDelta(func(d step.MessageDelta) { r.add("delta", d) }),
+		step.WithOnMessage(func(m step.Message) { r.add("message", m) }),
+	}
+}
+
+// Err returns the first encoding error, if any.
+func (r *Recorder) Err() error { return r.err }
+
+func (r *Recorder) add(kind string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return
+	}
+	r.Records = append(r.Records, TranscriptRecord{Kind: kind, Type: fmt.Sprintf("%T", v), Data: data})
+}
+
+// CaptureTranscript runs one step and returns every emitted event in order.
+func CaptureTranscript(ctx context.Context, req step.StepRequest) ([]TranscriptRecord, step.StepResult, error) {
+	var rec Recorder
+	result, err := step.Step(ctx, req, rec.Options()...)
+	if err == nil {
+		err = rec.Err()
+	}
+	return rec.Records, result, err
+}
+
+// GoldenOptions configures golden comparison.
+type GoldenOptions struct {
+	// IgnoreFields lists JSON object keys (at any depth) whose values are masked.
+	// Defaults to DefaultIgnoredFields when nil.
+	IgnoreFields []string
+}
+
+// AssertGolden compares records against the JSONL golden file at path.
+// When UPDATE_GOLDEN is set, the golden file is rewritten instead.
+func AssertGolden(t *testing.T, path string, records []TranscriptRecord, opts GoldenOptions) {
+	t.Helper()
+
+	ignore := opts.IgnoreFields
+	if ignore == nil {
+		ignore = DefaultIgnoredFields
+	}
+	got, err := normalizeRecords(records, ignore)
+	if err != nil {
+		t.Fatalf("normalize transcript: %v", err)
+	}
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := writeGolden(path, got); err != nil {
+			t.Fatalf("write golden %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := readGolden(path)
+	if err != nil {
+		t.Fatalf("read golden %s: %v (set %s=1 to create it)", path, err, UpdateGoldenEnv)
+	}
+	if diffs := DiffTranscripts(want, got); len(diffs) > 0 {
+		t.Errorf("transcript differs from %s:\n%s", path, strings.Join(diffs, "\n"))
+	}
+}
+
+// DiffTranscripts returns human-readable field-level differences between two normalized transcripts.
+func DiffTranscripts(want, got []map[string]any) []string {
+	var diffs []string
+	n := max(len(want), len(got))
+	for i := 0; i < n; i++ {
+		switch {
+		case i >= len(want):
+			diffs = append(diffs, fmt.Sprintf("record %d: unexpected %v", i, summarize(got[i])))
+		case i >= len(got):
+			diffs = append(diffs, fmt.Sprintf("record %d: missing %v", i, summarize(want[i])))
+		default:
+			diffValues(fmt.Sprintf("record %d", i), want[i], got[i], &diffs)
+		}
+	}
+	return diffs
+}
+
+func summarize(rec map[string]any) string {
+	return fmt.Sprintf("%v %v", rec["kind"], rec["type"])
+}
+
+func diffValues(path string, want, got any, diffs *[]string) {
+	wm, wok := want.(map[string]any)
+	gm, gok := got.(map[string]any)
+	if wok && gok {
+		keys := map[string]struct{}{}
+		for k := range wm {
+			keys[k] = struct{}{}
+		}
+		for k := range gm {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			diffValues(path+"."+k, wm[k], gm[k], diffs)
+		}
+		return
+	}
+	wa, wok := want.([]any)
+	ga, gok := got.([]any)
+	if wok && gok && len(wa) == len(ga) {
+		for i := range wa {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), wa[i], ga[i], diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: want %s, got %s", path, compact(want), compact(got)))
+	}
+}
+
+func compact(v any) string {
+	if v == nil {
+		return "<absent>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func normalizeRecords(records []TranscriptRecord, ignore []string) ([]map[string]any, error) {
+	ignored := make(map[string]bool, len(ignore))
+	for _, f := range ignore {
+		ignored[f] = true
+	}
+	out := make([]map[string]any, 0, len(records))
+	for _, rec := range records {
+		raw, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]any
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+		maskFields(m, ignored)
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func maskFields(v any, ignored map[string]bool) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if ignored[k] {
+				t[k] = ignoredPlaceholder
+				continue
+			}
+			maskFields(child, ignored)
+		}
+	case []any:
+		for _, child := range t {
+			maskFields(child, ignored)
+		}
+	}
+}
+
+func readGolden(path string) ([]map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]any
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal(line, &m); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, sc.Err()
+}
+
+func writeGolden(path string, records []map[string]any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
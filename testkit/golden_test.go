@@ -0,0 +1,55 @@
+package testkit_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/testkit"
+)
+
+type upperTool struct{}
+
+func (upperTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "upper"} }
+
+func (upperTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "HELLO"}}}, nil
+}
+
+func TestGoldenTranscript(t *testing.T) {
+	provider := mock.New(mock.Response{Message: step.AssistantMessage{
+		Parts: []step.Part{
+			step.ThinkingPart{Thinking: "need upper"},
+			step.TextPart{Text: "Calling tool."},
+			step.ToolCallPart{CallID: "call_1", Name: "upper", ArgsJSON: json.RawMessage(`{"s":"hello"}`)},
+		},
+		StopReason: step.StopToolUse,
+	}})
+
+	records, _, err := testkit.CaptureTranscript(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "upper hello"}}}},
+		Tools:    []step.Tool{upperTool{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tool end event carries a measured duration.
+	ignore := append(slices.Clone(testkit.DefaultIgnoredFields), "Duration")
+	testkit.AssertGolden(t, "testdata/tool_step.golden.jsonl", records, testkit.GoldenOptions{IgnoreFields: ignore})
+}
+
+func TestDiffTranscripts(t *testing.T) {
+	want := []map[string]any{{"kind": "delta", "data": map[string]any{"Delta": "a"}}}
+	got := []map[string]any{{"kind": "delta", "data": map[string]any{"Delta": "b"}}, {"kind": "message"}}
+	diffs := testkit.DiffTranscripts(want, got)
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 diffs, got %v", diffs)
+	}
+	if diffs[0] != `record 0.data.Delta: want "a", got "b"` {
+		t.Errorf("unexpected diff: %s", diffs[0])
+	}
+}
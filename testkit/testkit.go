@@ -1,5 +1,5 @@
-// Package testutil provides common testing utilities for provider tests.
-package testutil
+// Package testkit provides conformance tests and testing utilities for Provider implementations.
+package testkit
 
 import (
 	"context"
@@ -124,6 +124,8 @@ func TestBasicTextGeneration(t *testing.T, cfg TestConfig) {
 // calculatorTool is a simple test tool for tool calling tests.
 type calculatorTool struct{}
 
+var _ step.Tool = calculatorTool{}
+
 func (c calculatorTool) Spec() step.ToolSpec {
 	return step.ToolSpec{
 		Name:        "add",
@@ -139,7 +141,7 @@ func (c calculatorTool) Spec() step.ToolSpec {
 	}
 }
 
-func (c calculatorTool) Execute(_ context.Context, call step.ToolCall) (step.ToolResult, error) {
+func (c calculatorTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
 	var args struct {
 		A float64 `json:"a"`
 		B float64 `json:"b"`
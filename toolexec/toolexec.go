@@ -0,0 +1,39 @@
+// Package toolexec runs the tool calls of an assistant message outside Step,
+// with the executor Step uses, for callers such as workers resuming calls
+// from a stored history:
+//
+//	results := toolexec.Execute(ctx, calls, tools, toolexec.WithTiming(), step.WithToolApproval(approve))
+//	for _, res := range results {
+//		history = append(history, res)
+//	}
+//
+// See step.ExecuteTools for the ordering and cancellation guarantees.
+package toolexec
+
+import (
+	"context"
+
+	"github.com/inspirepan/step"
+)
+
+// Details keys set by WithTiming on the result message of every call that
+// ran.
+const (
+	// DetailsStarted is when the call started, in Unix milliseconds.
+	DetailsStarted = step.DetailsToolStarted
+	// DetailsDuration is how long the call ran, in milliseconds.
+	DetailsDuration = step.DetailsToolDuration
+)
+
+// Execute runs calls with tools and returns one result message per call, in
+// the order of calls. It is step.ExecuteTools.
+func Execute(ctx context.Context, calls []step.ToolCallPart, tools []step.Tool, opts ...step.StepOption) []step.ToolResultMessage {
+	return step.ExecuteTools(ctx, calls, tools, opts...)
+}
+
+// WithTiming records the start and duration of each call in the Details of
+// its result under DetailsStarted and DetailsDuration. It is
+// step.WithToolTiming.
+func WithTiming() step.StepOption {
+	return step.WithToolTiming()
+}
@@ -0,0 +1,179 @@
+package toolexec_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/toolexec"
+)
+
+// writeTool runs alone and does nothing.
+type writeTool struct{}
+
+func (writeTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "write", Access: step.AccessWrite} }
+
+func (writeTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "ok"}}}, nil
+}
+
+// sleepTool runs in parallel and sleeps for the duration in its arguments,
+// or until its context is done for "forever".
+type sleepTool struct{}
+
+func (sleepTool) Spec() step.ToolSpec {
+	return step.ToolSpec{Name: "sleep", Access: step.AccessReadOnly}
+}
+
+func (sleepTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct{ For string }
+	_ = json.Unmarshal(call.ArgsJSON, &args)
+	if args.For == "forever" {
+		<-ctx.Done()
+		return step.ToolResult{}, ctx.Err()
+	}
+	d, _ := time.ParseDuration(args.For)
+	time.Sleep(d)
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: args.For}}}, nil
+}
+
+func sleep(id, d string) step.ToolCallPart {
+	return step.ToolCallPart{CallID: id, Name: "sleep", ArgsJSON: []byte(`{"for":"` + d + `"}`)}
+}
+
+func TestExecute(t *testing.T) {
+	tools := []step.Tool{sleepTool{}, writeTool{}}
+
+	t.Run("order", func(t *testing.T) {
+		var emitted []string
+		var starts int
+		// The first call finishes last, yet its result comes first.
+		results := toolexec.Execute(context.Background(), []step.ToolCallPart{sleep("a", "40ms"), sleep("b", "1ms"), sleep("c", "20ms")}, tools,
+			toolexec.WithTiming(),
+			step.WithOnMessage(func(m step.Message) { emitted = append(emitted, m.(step.ToolResultMessage).CallID) }),
+			step.WithOnDelta(func(d step.MessageDelta) {
+				if _, ok := d.(step.ToolExecStartDelta); ok {
+					starts++
+				}
+			}))
+		var got []string
+		for _, r := range results {
+			got = append(got, r.CallID)
+			if r.IsError {
+				t.Errorf("result %s is an error", r.CallID)
+			}
+			duration, ok := r.Details[toolexec.DetailsDuration].(int64)
+			if _, started := r.Details[toolexec.DetailsStarted].(int64); !ok || !started {
+				t.Errorf("result %s has no timing: %v", r.CallID, r.Details)
+			}
+			if r.CallID == "a" && duration < 40 {
+				t.Errorf("call a took %dms, want at least 40ms", duration)
+			}
+		}
+		if want := []string{"a", "b", "c"}; !slices.Equal(got, want) || !slices.Equal(emitted, want) || starts != 3 {
+			t.Errorf("results %v, emitted %v, %d starts; want %v", got, emitted, starts, want)
+		}
+	})
+
+	t.Run("cancel mid batch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := []step.ToolCallPart{sleep("done", "1ms"), sleep("running", "forever"), {CallID: "next", Name: "write", ArgsJSON: []byte(`{}`)}}
+		results := toolexec.Execute(ctx, calls, tools, toolexec.WithTiming(), step.WithOnMessage(func(m step.Message) {
+			// The finished call is emitted while the other still runs.
+			if m.(step.ToolResultMessage).CallID == "done" {
+				cancel()
+			}
+		}))
+		if len(results) != 3 || results[0].IsError {
+			t.Fatalf("results = %+v, want the finished result kept", results)
+		}
+		for _, r := range results[1:] {
+			if !r.IsError {
+				t.Errorf("result %s = %+v, want interrupted", r.CallID, r)
+			}
+			if _, ok := r.Details[toolexec.DetailsDuration]; ok && r.CallID == "next" {
+				t.Error("call after the cancellation ran")
+			}
+		}
+	})
+
+	t.Run("timing", func(t *testing.T) {
+		calls := []step.ToolCallPart{{CallID: "a", Name: "timed", ArgsJSON: []byte(`{}`)}}
+		tools := []step.Tool{timedTool{}}
+		if r := toolexec.Execute(context.Background(), calls, tools)[0]; len(r.Details) != 1 {
+			t.Errorf("details %v without WithTiming, want the tool's only", r.Details)
+		}
+		r := toolexec.Execute(context.Background(), calls, tools, toolexec.WithTiming())[0]
+		if r.Details[toolexec.DetailsDuration] != "tool-owned" {
+			t.Errorf("tool-owned %s overwritten: %v", toolexec.DetailsDuration, r.Details)
+		}
+		if _, ok := r.Details[toolexec.DetailsStarted].(int64); !ok {
+			t.Errorf("no %s in %v", toolexec.DetailsStarted, r.Details)
+		}
+	})
+}
+
+// timedTool sets a Details key the executor also uses for timing.
+type timedTool struct{}
+
+func (timedTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "timed"} }
+
+func (timedTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Details: map[string]any{toolexec.DetailsDuration: "tool-owned"}}, nil
+}
+
+// truncTool reports that it cut its output.
+type truncTool struct{}
+
+func (truncTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "trunc"} }
+
+func (truncTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "…"}}, Details: map[string]any{step.DetailsTruncated: true}}, nil
+}
+
+func TestExecute_EndDeltas(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	tools := []step.Tool{sleepTool{}, truncTool{}}
+	calls := []step.ToolCallPart{
+		sleep("a", "20ms"),
+		{CallID: "b", Name: "trunc", ArgsJSON: []byte(`{}`)},
+		{CallID: "c", Name: "missing", ArgsJSON: []byte(`{}`)},
+		sleep("d", "forever"),
+	}
+	ends := map[string]step.ToolExecEndDelta{}
+	var order []string
+	toolexec.Execute(ctx, calls, tools,
+		step.WithOnDelta(func(d step.MessageDelta) {
+			if end, ok := d.(step.ToolExecEndDelta); ok {
+				ends[end.Call.CallID] = end
+			}
+		}),
+		step.WithOnMessage(func(m step.Message) {
+			// Each end delta precedes its result.
+			id := m.(step.ToolResultMessage).CallID
+			if _, ok := ends[id]; !ok {
+				t.Errorf("result %s emitted before its end delta", id)
+			}
+			order = append(order, id)
+		}))
+
+	if a := ends["a"]; a.Duration < 20*time.Millisecond || a.Attempts != 1 || a.IsError || a.Truncated || a.Interrupted {
+		t.Errorf("a = %+v, want a successful 20ms call", a)
+	}
+	if b := ends["b"]; !b.Truncated || b.IsError {
+		t.Errorf("b = %+v, want truncated", b)
+	}
+	if c := ends["c"]; !c.IsError || c.Interrupted {
+		t.Errorf("c = %+v, want an error", c)
+	}
+	if d := ends["d"]; !d.Interrupted || !d.IsError || d.Duration < 30*time.Millisecond {
+		t.Errorf("d = %+v, want interrupted after the timeout", d)
+	}
+	if len(order) != 4 {
+		t.Errorf("emitted %v, want 4 results", order)
+	}
+}
@@ -3,6 +3,7 @@ package step
 import (
 	"context"
 	"encoding/json"
+	"maps"
 )
 
 // ToolSpec is the declarative tool schema exposed to LLM.
@@ -10,9 +11,48 @@ type ToolSpec struct {
 	Name        string         `json:"name"`
 	Description string         `json:"description"`
 	Parameters  map[string]any `json:"parameters"`
-	Parallel    bool           `json:"-"` // if true, tool can be executed in parallel, e.g. sub-agent, web_search, web_fetch and other read-only tools
+	// Access classifies what the tool does to the outside world. Read-only
+	// tools run in parallel; destructive ones need approval when
+	// WithToolApproval is set.
+	Access Access `json:"-"`
+	// Parallel lets a tool that is not read-only run in parallel with other
+	// parallel tools, e.g. a sub-agent.
+	Parallel bool `json:"-"`
+	// Retry retries failed calls of the tool; see RetryPolicy and
+	// WithToolRetry.
+	Retry *RetryPolicy `json:"-"`
+	// After lists tools whose calls in the same turn must finish before a
+	// call of this tool starts, even when the model issued them later, e.g.
+	// a write tool after the read tool. Results keep the model's order.
+	After []string `json:"-"`
+	// Strict requests exact schema adherence where supported (OpenAI strict mode).
+	// Providers close the schema automatically: optional properties become nullable.
+	Strict bool `json:"strict,omitempty"`
 }
 
+// RunsInParallel reports whether calls of the tool may run concurrently with
+// other such calls of a step: tools that are read-only or marked Parallel.
+func (s ToolSpec) RunsInParallel() bool {
+	return s.Parallel || s.Access == AccessReadOnly
+}
+
+// Access is what a tool does to the outside world.
+type Access string
+
+const (
+	// AccessUnspecified is the zero Access, for tools that declare none.
+	// They run exclusively unless marked Parallel and are not approved.
+	AccessUnspecified Access = ""
+	// AccessReadOnly tools only read state, e.g. search or read a file.
+	AccessReadOnly Access = "read_only"
+	// AccessWrite tools change state in ways that can be undone or
+	// reviewed, e.g. edit a file in a repository.
+	AccessWrite Access = "write"
+	// AccessDestructive tools may change state irreversibly, e.g. run
+	// arbitrary commands or delete data.
+	AccessDestructive Access = "destructive"
+)
+
 // ToolCall is the normalized tool call.
 type ToolCall struct {
 	CallID   string
@@ -27,6 +67,12 @@ type ToolResult struct {
 	Parts   []Part
 	IsError bool
 	Details map[string]any // extra data, e.g. diff text for edit tool UI rendering
+
+	// Pending marks a deferred result: the tool started work that completes
+	// later, such as a CI job, and Parts describe it for now. Its message is
+	// a placeholder marked with MetadataToolPending; Run suspends with
+	// ErrPaused until RunState.CompleteToolCall supplies the real result.
+	Pending bool
 }
 
 // Tool is an executable tool.
@@ -34,3 +80,107 @@ type Tool interface {
 	Spec() ToolSpec
 	Execute(ctx context.Context, call ToolCallPart) (ToolResult, error)
 }
+
+// ExecuteTool runs call with the tool of the same name in tools, the way Step
+// does: an unknown tool, an error or cancellation becomes an error result.
+// It is for callers driving tools outside Step, such as realtime sessions.
+// Calls are not approved; see WithToolApproval.
+func ExecuteTool(ctx context.Context, call ToolCallPart, tools []Tool) ToolResult {
+	toolMap := make(map[string]Tool, len(tools))
+	for _, t := range tools {
+		toolMap[t.Spec().Name] = t
+	}
+	res, _ := executeSingleTool(ctx, call, toolMap, toolExecConfig{})
+	return res
+}
+
+// DetailsTruncated is the ToolResult.Details key under which tools report,
+// as true, that they cut their output, e.g. to a size limit. It is surfaced
+// in ToolExecEndDelta.Truncated.
+const DetailsTruncated = "truncated"
+
+// Details keys set by WithToolTiming on the result message of every tool
+// call that ran. Keys the tool set itself are kept.
+const (
+	// DetailsToolStarted is when the call started, in Unix milliseconds.
+	DetailsToolStarted = "started_ms"
+	// DetailsToolDuration is how long the call ran, in milliseconds.
+	DetailsToolDuration = "duration_ms"
+)
+
+// WithToolTiming records the start and duration of each tool call in the
+// Details of its result message, under DetailsToolStarted and
+// DetailsToolDuration. ToolExecEndDelta reports the duration either way.
+func WithToolTiming() StepOption {
+	return func(c *stepConfig) { c.toolExec.timing = true }
+}
+
+// withTiming returns a copy of details with the timing of a call added.
+func withTiming(details map[string]any, t toolTiming) map[string]any {
+	out := make(map[string]any, len(details)+2)
+	out[DetailsToolStarted] = t.start.UnixMilli()
+	out[DetailsToolDuration] = t.duration.Milliseconds()
+	maps.Copy(out, details)
+	return out
+}
+
+// ExecuteTools runs calls with tools the way Step runs the tool calls of an
+// assistant message, and returns one result message per call:
+//
+//   - Results are in the order of calls, in the slice and as emitted with
+//     WithOnMessage: a result is emitted once it and all results before it
+//     are done, so the history order never depends on timing.
+//   - Calls run in order, except that tools with ToolSpec.After wait for the
+//     calls they depend on. Tools that RunsInParallel run concurrently with
+//     neighbouring such calls; other tools run alone.
+//   - Each call that runs emits a ToolExecStartDelta before it starts and a
+//     ToolExecEndDelta before its result.
+//   - When ctx is done, running calls are cancelled and every call without
+//     a result, running or not yet started, gets an interrupted error
+//     result. Results already done are kept.
+//   - With WithToolTiming, calls that ran carry DetailsToolStarted and
+//     DetailsToolDuration. A call retried by its RetryPolicy runs again
+//     before its result is done; the duration includes the retries.
+//
+// Of opts, WithToolApproval, WithToolRetry, WithToolRecovery, WithToolTiming
+// and the delta and message callbacks apply. Package toolexec exports the
+// same executor.
+func ExecuteTools(ctx context.Context, calls []ToolCallPart, tools []Tool, opts ...StepOption) []ToolResultMessage {
+	cfg := newStepConfig(opts)
+	msgs := executeTools(ctx, calls, tools, cfg.toolExec, cfg.stepEmitter, "")
+	out := make([]ToolResultMessage, len(msgs))
+	for i, m := range msgs {
+		out[i] = m.(ToolResultMessage)
+	}
+	return out
+}
+
+// ToolApprover decides whether a destructive tool call may run. It may block,
+// e.g. while a user is asked. A non-nil error denies the call; the model gets
+// an error result with its message.
+type ToolApprover func(ctx context.Context, call ToolCallPart) error
+
+// WithToolApproval requires approve to allow each call of a tool with
+// AccessDestructive before it runs. Other tools run without approval.
+func WithToolApproval(approve ToolApprover) StepOption {
+	return func(c *stepConfig) { c.toolExec.approve = approve }
+}
+
+// toolExecConfig is how executeTools runs each call.
+type toolExecConfig struct {
+	approve       ToolApprover
+	retry         map[string]RetryPolicy
+	readOnlyRetry *RetryPolicy
+	recovery      ToolRecovery
+	timing        bool
+}
+
+// deniedToolResult is the result of a call the ToolApprover denied.
+func deniedToolResult(call ToolCallPart, err error) ToolResult {
+	return ToolResult{
+		CallID:  call.CallID,
+		Name:    call.Name,
+		IsError: true,
+		Parts:   []Part{TextPart{Text: "Tool call denied: " + err.Error()}},
+	}
+}
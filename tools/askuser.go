@@ -0,0 +1,126 @@
+// Package tools provides built-in tools for common agent patterns: asking the
+// user, a todo plan, HTTP requests, and shell and file tools running through
+// an Executor on the host, in a Docker container or in a sandbox.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+// AskUserName is the tool name of AskUser.
+const AskUserName = "ask_user"
+
+// Question is what the model asks the user.
+type Question struct {
+	CallID string `json:"call_id"`
+	// Text is the question to show.
+	Text string `json:"question"`
+	// Options are suggested answers, if the model offered any.
+	Options []string `json:"options,omitempty"`
+}
+
+// AskFunc obtains the user's answer to q. It must return when ctx is done;
+// the step is then treated as interrupted.
+type AskFunc func(ctx context.Context, q Question) (string, error)
+
+// AskUser returns a tool that lets the model ask the user a question mid-run and
+// blocks on ask for the answer. The tool is not parallel, so questions are
+// asked one at a time.
+func AskUser(ask AskFunc) step.Tool {
+	return &askUserTool{ask: ask}
+}
+
+// Prompt is a pending question delivered by AskUserChan.
+type Prompt struct {
+	Question
+	reply chan string
+}
+
+// Reply answers the prompt. Only the first reply is used; replies after the
+// step was cancelled are discarded.
+func (p Prompt) Reply(answer string) {
+	select {
+	case p.reply <- answer:
+	default:
+	}
+}
+
+// AskUserChan is AskUser for hosts with an event loop: each question is sent on
+// the returned channel and the tool blocks until the Prompt is replied to.
+func AskUserChan() (step.Tool, <-chan Prompt) {
+	prompts := make(chan Prompt)
+	ask := func(ctx context.Context, q Question) (string, error) {
+		p := Prompt{Question: q, reply: make(chan string, 1)}
+		select {
+		case prompts <- p:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		select {
+		case answer := <-p.reply:
+			return answer, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	return AskUser(ask), prompts
+}
+
+type askUserTool struct {
+	ask AskFunc
+}
+
+func (t *askUserTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name: AskUserName,
+		Description: "Ask the user a question and wait for the answer. Use it when you need " +
+			"information or a decision only the user can provide; do not use it for progress updates.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"question": map[string]any{
+					"type":        "string",
+					"description": "The question to ask, phrased so it can be answered without further context",
+				},
+				"options": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Optional suggested answers",
+				},
+			},
+			"required": []string{"question"},
+		},
+	}
+}
+
+func (t *askUserTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Question string   `json:"question"`
+		Options  []string `json:"options"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil || strings.TrimSpace(args.Question) == "" {
+		return step.ToolResult{IsError: true, Parts: []step.Part{step.TextPart{Text: "ask_user requires a non-empty question"}}}, nil
+	}
+	q := Question{CallID: call.CallID, Text: args.Question, Options: args.Options}
+	answer, err := t.ask(ctx, q)
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			return step.ToolResult{}, err
+		}
+		return step.ToolResult{}, fmt.Errorf("ask user: %w", err)
+	}
+	text := answer
+	if strings.TrimSpace(text) == "" {
+		text = "(the user gave an empty answer)"
+	}
+	return step.ToolResult{
+		Parts:   []step.Part{step.TextPart{Text: text}},
+		Details: map[string]any{"question": q.Text, "answer": answer},
+	}, nil
+}
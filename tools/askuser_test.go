@@ -0,0 +1,78 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/tools"
+)
+
+func askCall(question string) mock.Response {
+	args, _ := json.Marshal(map[string]any{"question": question, "options": []string{"yes", "no"}})
+	return mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: tools.AskUserName, ArgsJSON: args})
+}
+
+func TestAskUser(t *testing.T) {
+	var asked tools.Question
+	tool := tools.AskUser(func(_ context.Context, q tools.Question) (string, error) {
+		asked = q
+		return "yes", nil
+	})
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: mock.New(askCall("Deploy now?")), Tools: []step.Tool{tool}})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if asked.Text != "Deploy now?" || len(asked.Options) != 2 || asked.CallID != "c1" {
+		t.Errorf("unexpected question %+v", asked)
+	}
+	res := result[1].(step.ToolResultMessage)
+	if res.IsError || res.Parts[0].(step.TextPart).Text != "yes" || res.Details["answer"] != "yes" {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestAskUserChan(t *testing.T) {
+	tool, prompts := tools.AskUserChan()
+	go func() {
+		p := <-prompts
+		p.Reply("no, wait for review")
+	}()
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: mock.New(askCall("Deploy now?")), Tools: []step.Tool{tool}})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if got := result[1].(step.ToolResultMessage).Parts[0].(step.TextPart).Text; got != "no, wait for review" {
+		t.Errorf("unexpected answer %q", got)
+	}
+}
+
+func TestAskUserCancel(t *testing.T) {
+	tool, prompts := tools.AskUserChan()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-prompts
+		cancel()
+	}()
+	done := make(chan struct{})
+	var result step.StepResult
+	var err error
+	go func() {
+		result, err = step.Step(ctx, step.StepRequest{Provider: mock.New(askCall("Deploy now?")), Tools: []step.Tool{tool}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Step did not return after cancellation")
+	}
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if res := result[1].(step.ToolResultMessage); !res.IsError {
+		t.Errorf("expected an interrupted tool result, got %+v", res)
+	}
+}
@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+// Tool names of the capture tools.
+const (
+	ScreenshotName   = "screenshot"
+	ReadTerminalName = "read_terminal"
+)
+
+// maxImageSize bounds screenshots; providers reject larger images.
+const maxImageSize = 5 << 20
+
+// Image is a captured image.
+type Image struct {
+	// MimeType defaults to one sniffed from Data.
+	MimeType string
+	Data     []byte
+}
+
+// CaptureFunc captures the screen, a window or a browser page.
+type CaptureFunc func(ctx context.Context) (Image, error)
+
+// Screenshot returns a tool that captures an image with capture and returns it
+// as an ImagePart, so the model can check the effect of its actions.
+func Screenshot(capture CaptureFunc) step.Tool {
+	return &screenshotTool{capture: capture}
+}
+
+// CaptureScreen returns a CaptureFunc taking a PNG screenshot of the whole
+// screen with exec, using the first of screencapture (macOS), grim (Wayland),
+// import (ImageMagick) or scrot found. With a DockerExecutor it captures the
+// container's display, e.g. an Xvfb server.
+func CaptureScreen(exec Executor) CaptureFunc {
+	return func(ctx context.Context) (Image, error) {
+		res, err := exec.Run(ctx, Command{Script: screenshotScript})
+		if err != nil {
+			return Image{}, err
+		}
+		out := strings.TrimSpace(string(res.Output))
+		if res.ExitCode != 0 {
+			return Image{}, fmt.Errorf("screenshot failed: %s", out)
+		}
+		file := out[strings.LastIndexByte(out, '\n')+1:]
+		data, err := exec.ReadFile(ctx, file)
+		// Remove the file even if the call was cancelled.
+		_, _ = exec.Run(context.WithoutCancel(ctx), Command{Script: "rm -f " + shellQuote(file)})
+		if err != nil {
+			return Image{}, err
+		}
+		return Image{MimeType: "image/png", Data: data}, nil
+	}
+}
+
+const screenshotScript = `f=$(mktemp) || exit 1
+if command -v screencapture >/dev/null 2>&1; then screencapture -x -t png "$f"
+elif [ -n "$WAYLAND_DISPLAY" ] && command -v grim >/dev/null 2>&1; then grim "$f"
+elif command -v import >/dev/null 2>&1; then import -window root "png:$f"
+elif command -v scrot >/dev/null 2>&1; then scrot -o "$f"
+else rm -f "$f"; echo "no screenshot command found; install screencapture, grim, import or scrot"; exit 127
+fi || { rm -f "$f"; exit 1; }
+echo "$f"`
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type screenshotTool struct{ capture CaptureFunc }
+
+func (t *screenshotTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name:        ScreenshotName,
+		Description: "Take a screenshot and return it as an image.",
+		Parameters:  map[string]any{"type": "object", "properties": map[string]any{}},
+		Access:      step.AccessReadOnly,
+	}
+}
+
+func (t *screenshotTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	img, err := t.capture(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return step.ToolResult{}, ctx.Err()
+		}
+		return errorResult(err.Error()), nil
+	}
+	if len(img.Data) == 0 {
+		return errorResult("the screenshot is empty"), nil
+	}
+	if len(img.Data) > maxImageSize {
+		return errorResult(fmt.Sprintf("the screenshot is %d bytes, over the %d byte limit; capture a smaller area or lower the resolution", len(img.Data), maxImageSize)), nil
+	}
+	mime := img.MimeType
+	if mime == "" {
+		mime = http.DetectContentType(img.Data)
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return errorResult("the capture is not an image (" + mime + ")"), nil
+	}
+	return step.ToolResult{
+		Parts:   []step.Part{step.ImagePart{MimeType: mime, DataB64: base64.StdEncoding.EncodeToString(img.Data)}},
+		Details: map[string]any{"mime_type": mime, "bytes": len(img.Data)},
+	}, nil
+}
+
+// ReadTerminal returns a parallel tool that reads the text of a tmux pane with
+// exec, e.g. one running a dev server or a TUI under test. target is a tmux
+// target such as "session:window.pane"; empty means the current pane. The
+// buffer is returned as text, which models read more reliably than an image
+// of it.
+func ReadTerminal(exec Executor, target string) step.Tool {
+	return &readTerminalTool{exec: exec, target: target}
+}
+
+type readTerminalTool struct {
+	exec   Executor
+	target string
+}
+
+func (t *readTerminalTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name:        ReadTerminalName,
+		Description: "Read the text currently shown in the terminal, optionally with scrollback history.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"history": map[string]any{"type": "integer", "description": "Lines of scrollback to include above the visible screen"},
+			},
+		},
+		Access: step.AccessReadOnly,
+	}
+}
+
+func (t *readTerminalTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		History int `json:"history"`
+	}
+	if len(call.ArgsJSON) > 0 {
+		if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+			return errorResult("invalid arguments: " + err.Error()), nil
+		}
+	}
+	script := "tmux capture-pane -p -J"
+	if t.target != "" {
+		script += " -t " + shellQuote(t.target)
+	}
+	if args.History > 0 {
+		script += fmt.Sprintf(" -S -%d", args.History)
+	}
+	res, err := t.exec.Run(ctx, Command{Script: script})
+	if err != nil {
+		if ctx.Err() != nil {
+			return step.ToolResult{}, ctx.Err()
+		}
+		return errorResult(err.Error()), nil
+	}
+	if res.ExitCode != 0 {
+		return errorResult("tmux capture-pane failed: " + strings.TrimSpace(string(res.Output))), nil
+	}
+	// Panes are padded with empty lines below the cursor.
+	text := string(bytes.TrimRight(res.Output, "\n"))
+	if strings.TrimSpace(text) == "" {
+		text = "(the terminal is empty)"
+	}
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: truncate([]byte(text))}}}, nil
+}
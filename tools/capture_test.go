@@ -0,0 +1,60 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/tools"
+)
+
+// fakeExecutor returns canned command output and file contents.
+type fakeExecutor struct {
+	scripts []string
+	output  string
+	files   map[string][]byte
+}
+
+func (e *fakeExecutor) Run(_ context.Context, cmd tools.Command) (tools.CommandResult, error) {
+	e.scripts = append(e.scripts, cmd.Script)
+	return tools.CommandResult{Output: []byte(e.output)}, nil
+}
+
+func (e *fakeExecutor) ReadFile(_ context.Context, path string) ([]byte, error) {
+	return e.files[path], nil
+}
+
+func (e *fakeExecutor) WriteFile(context.Context, string, []byte) error { return nil }
+
+func TestScreenshot(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nfake")
+	exec := &fakeExecutor{output: "/tmp/tmp.123\n", files: map[string][]byte{"/tmp/tmp.123": png}}
+	res := call(t, tools.Screenshot(tools.CaptureScreen(exec)), nil)
+	img, ok := res.Parts[0].(step.ImagePart)
+	if res.IsError || !ok || img.MimeType != "image/png" || img.DataB64 != base64.StdEncoding.EncodeToString(png) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if len(exec.scripts) != 2 || exec.scripts[1] != "rm -f '/tmp/tmp.123'" {
+		t.Errorf("expected the capture file to be removed, ran %q", exec.scripts)
+	}
+
+	notImage := tools.Screenshot(func(context.Context) (tools.Image, error) {
+		return tools.Image{Data: []byte("plain text")}, nil
+	})
+	if res := call(t, notImage, nil); !res.IsError {
+		t.Error("expected an error for a capture that is not an image")
+	}
+}
+
+func TestReadTerminal(t *testing.T) {
+	exec := &fakeExecutor{output: "$ make\nok\n\n\n"}
+	res := call(t, tools.ReadTerminal(exec, "dev:0"), map[string]any{"history": 50})
+	if text(res) != "$ make\nok" {
+		t.Errorf("unexpected text %q", text(res))
+	}
+	if want := "tmux capture-pane -p -J -t 'dev:0' -S -50"; !strings.Contains(exec.scripts[0], want) {
+		t.Errorf("ran %q, want %q", exec.scripts[0], want)
+	}
+}
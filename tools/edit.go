@@ -0,0 +1,399 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/internal/diff"
+)
+
+// EditFileName is the tool name of EditFile.
+const EditFileName = "edit_file"
+
+// Details keys of edit_file results.
+const (
+	// DetailsDiff holds the unified diff of the applied edit.
+	DetailsDiff = "diff"
+	// DetailsFuzzy is true if an anchor only matched ignoring whitespace.
+	DetailsFuzzy = "fuzzy"
+)
+
+// EditFile returns a tool that edits a text file with exec, replacing an
+// exact string or applying the hunks of a unified diff. Anchors that do not
+// match exactly are matched line by line ignoring trailing, then leading
+// whitespace, re-indenting the replacement to the file. When an anchor is
+// missing or ambiguous the error shows the closest lines, so the model can
+// retry.
+func EditFile(exec Executor) step.Tool {
+	return &editFileTool{exec: exec}
+}
+
+type editFileTool struct{ exec Executor }
+
+func (t *editFileTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name: EditFileName,
+		Description: "Edit a text file. Either replace old_string with new_string, where old_string must match " +
+			"exactly one location unless replace_all is set, or give a unified diff in patch. Include enough " +
+			"surrounding lines to make the location unique. To create a file, use an empty old_string.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":        map[string]any{"type": "string", "description": "File path, absolute or relative to the working directory"},
+				"old_string":  map[string]any{"type": "string", "description": "The text to replace"},
+				"new_string":  map[string]any{"type": "string", "description": "The replacement text"},
+				"replace_all": map[string]any{"type": "boolean", "description": "Replace every occurrence of old_string"},
+				"patch":       map[string]any{"type": "string", "description": "A unified diff of this file, instead of old_string and new_string"},
+			},
+			"required": []string{"path"},
+		},
+		Access: step.AccessWrite,
+		// Reads issued in the same turn see the file before the change.
+		After: []string{ReadFileName, GrepName},
+	}
+}
+
+func (t *editFileTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Path       string  `json:"path"`
+		OldString  *string `json:"old_string"`
+		NewString  string  `json:"new_string"`
+		ReplaceAll bool    `json:"replace_all"`
+		Patch      string  `json:"patch"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	var edits []edit
+	switch {
+	case args.Patch != "" && args.OldString != nil:
+		return errorResult("give either old_string and new_string or patch, not both"), nil
+	case args.Patch != "":
+		var err error
+		if edits, err = parseHunks(args.Patch); err != nil {
+			return errorResult(err.Error()), nil
+		}
+	case args.OldString != nil:
+		edits = []edit{{old: *args.OldString, new: args.NewString, replaceAll: args.ReplaceAll}}
+	default:
+		return errorResult("edit_file requires old_string and new_string, or patch"), nil
+	}
+
+	data, err := t.exec.ReadFile(ctx, args.Path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || len(edits) != 1 || edits[0].old != "" || edits[0].lines {
+			return errorResult(err.Error()), nil
+		}
+		// An empty old_string creates the file.
+		data = nil
+	} else if len(edits) == 1 && edits[0].old == "" && !edits[0].lines && len(data) > 0 {
+		return errorResult(args.Path + " already exists; give the old_string to replace"), nil
+	}
+
+	before := string(data)
+	crlf := strings.Contains(before, "\r\n")
+	if crlf {
+		before = strings.ReplaceAll(before, "\r\n", "\n")
+	}
+	after, fuzzy := before, false
+	for i, e := range edits {
+		var f bool
+		if after, f, err = e.apply(after); err != nil {
+			msg := err.Error()
+			if len(edits) > 1 {
+				msg = fmt.Sprintf("hunk %d: %s", i+1, msg)
+			}
+			res := errorResult(args.Path + ": " + msg + "\nNo changes were made.")
+			res.Details = map[string]any{step.DetailsRecovery: "Read the file with " + ReadFileName + " to see its current content, then retry with text copied from it."}
+			return res, nil
+		}
+		fuzzy = fuzzy || f
+	}
+	if after == before {
+		return errorResult(args.Path + ": the edit makes no changes"), nil
+	}
+	out := after
+	if crlf {
+		out = strings.ReplaceAll(out, "\n", "\r\n")
+	}
+	if err := t.exec.WriteFile(ctx, args.Path, []byte(out)); err != nil {
+		return errorResult(err.Error()), nil
+	}
+
+	d := diff.Unified("a/"+args.Path, "b/"+args.Path, before, after)
+	text := "Edited " + args.Path + ".\n" + d
+	if fuzzy {
+		text = "Edited " + args.Path + " (the anchor matched ignoring whitespace).\n" + d
+	}
+	return step.ToolResult{
+		Parts:   []step.Part{step.TextPart{Text: truncate([]byte(text))}},
+		Details: map[string]any{DetailsDiff: d, DetailsFuzzy: fuzzy},
+	}, nil
+}
+
+// edit replaces old with new. Line edits come from diff hunks and match
+// whole lines only.
+type edit struct {
+	old, new   string
+	replaceAll bool
+	lines      bool
+	// near is the 1-based line a hunk claims to start at, used to choose
+	// between several matches and to place hunks without context.
+	near int
+}
+
+// parseHunks turns the hunks of a unified diff into line edits. Line numbers
+// only disambiguate; models often get them wrong.
+func parseHunks(patch string) ([]edit, error) {
+	var (
+		edits    []edit
+		old, new []string
+		in       bool
+	)
+	flush := func() {
+		if in {
+			edits[len(edits)-1].old = joinLines(old)
+			edits[len(edits)-1].new = joinLines(new)
+		}
+		old, new, in = nil, nil, false
+	}
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(patch, "\r\n", "\n"), "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			in = true
+			e := edit{lines: true}
+			if _, err := fmt.Sscanf(line, "@@ -%d", &e.near); err != nil {
+				e.near = 0
+			}
+			edits = append(edits, e)
+		case strings.HasPrefix(line, "diff "),
+			// A removed line can start with "--", so --- is only a header
+			// when +++ follows.
+			strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			flush()
+		case !in, strings.HasPrefix(line, `\`):
+		case strings.HasPrefix(line, "-"):
+			old = append(old, line[1:])
+		case strings.HasPrefix(line, "+"):
+			new = append(new, line[1:])
+		case strings.HasPrefix(line, " "):
+			old, new = append(old, line[1:]), append(new, line[1:])
+		case line == "":
+			// Editors strip the space of empty context lines.
+			old, new = append(old, ""), append(new, "")
+		default:
+			return nil, fmt.Errorf("invalid patch line %q", line)
+		}
+	}
+	flush()
+	if len(edits) == 0 {
+		return nil, errors.New("patch has no hunks (lines starting with @@)")
+	}
+	return edits, nil
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// apply applies e to content, reporting whether whitespace was ignored.
+func (e edit) apply(content string) (string, bool, error) {
+	if e.old == "" {
+		if !e.lines {
+			return e.new, false, nil
+		}
+		// A hunk without context inserts after line near.
+		lines := strings.SplitAfter(content, "\n")
+		at := min(max(e.near, 0), len(lines))
+		if at > 0 && !strings.HasSuffix(lines[at-1], "\n") {
+			lines[at-1] += "\n"
+		}
+		return strings.Join(lines[:at], "") + e.new + strings.Join(lines[at:], ""), false, nil
+	}
+	if !e.lines {
+		switch n := strings.Count(content, e.old); {
+		case n == 1 || n > 1 && e.replaceAll:
+			return strings.ReplaceAll(content, e.old, e.new), false, nil
+		case n > 1:
+			return "", false, fmt.Errorf("old_string matches %d locations, at lines %s; include more surrounding lines to make it unique, or set replace_all",
+				n, lineList(matchLines(content, e.old)))
+		}
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	oldLines := strings.Split(strings.TrimSuffix(e.old, "\n"), "\n")
+	for level, norm := range []func(string) string{
+		func(s string) string { return s },
+		func(s string) string { return strings.TrimRight(s, " \t") },
+		strings.TrimSpace,
+	} {
+		starts := findLines(lines, oldLines, norm)
+		if len(starts) == 0 {
+			continue
+		}
+		if len(starts) > 1 && !e.replaceAll {
+			if e.near == 0 {
+				return "", false, fmt.Errorf("old_string matches %d locations, at lines %s; include more surrounding lines to make it unique, or set replace_all",
+					len(starts), lineList(starts))
+			}
+			starts = []int{nearest(starts, e.near-1)}
+		}
+		newText := e.new
+		if level == 2 {
+			newText = reindent(newText, oldLines, lines[starts[0]:starts[0]+len(oldLines)])
+		}
+		var sb strings.Builder
+		prev := 0
+		for _, s := range starts {
+			end := s + len(oldLines)
+			sb.WriteString(strings.Join(lines[prev:s], ""))
+			repl := newText
+			// Keep the line break after the last replaced line if old_string
+			// had none.
+			if !strings.HasSuffix(e.old, "\n") && strings.HasSuffix(lines[end-1], "\n") && repl != "" && !strings.HasSuffix(repl, "\n") {
+				repl += "\n"
+			}
+			sb.WriteString(repl)
+			prev = end
+		}
+		sb.WriteString(strings.Join(lines[prev:], ""))
+		return sb.String(), level > 0, nil
+	}
+	return "", false, notFound(lines, oldLines)
+}
+
+// findLines returns the indexes where want occurs in lines, comparing lines
+// with norm. Matches do not overlap.
+func findLines(lines, want []string, norm func(string) string) []int {
+	var starts []int
+	for i := 0; i+len(want) <= len(lines); i++ {
+		ok := true
+		for j, w := range want {
+			if norm(strings.TrimSuffix(lines[i+j], "\n")) != norm(w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			starts = append(starts, i)
+			i += len(want) - 1
+		}
+	}
+	return starts
+}
+
+// notFound returns an error showing the region that best matches want.
+func notFound(lines, want []string) error {
+	best, bestScore := 0, 0
+	for i := 0; i+len(want) <= len(lines) || i == 0 && i < len(lines); i++ {
+		score := 0
+		for j, w := range want {
+			if i+j < len(lines) && strings.TrimSpace(w) != "" && strings.TrimSpace(strings.TrimSuffix(lines[i+j], "\n")) == strings.TrimSpace(w) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	msg := "old_string was not found"
+	if bestScore == 0 {
+		return errors.New(msg + "; read the file and copy the text to replace exactly")
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s. The closest match is at lines %d-%d, where %d of %d lines match:\n", msg, best+1, min(best+len(want), len(lines)), bestScore, len(want))
+	for i := best; i < min(best+len(want), len(lines)); i++ {
+		fmt.Fprintf(&sb, "%6d\t%s\n", i+1, strings.TrimSuffix(lines[i], "\n"))
+	}
+	sb.WriteString("Copy the text to replace exactly from these lines.")
+	return errors.New(sb.String())
+}
+
+// matchLines returns the 0-based lines where old starts in content.
+func matchLines(content, old string) []int {
+	var starts []int
+	for offset := 0; ; {
+		i := strings.Index(content[offset:], old)
+		if i < 0 {
+			return starts
+		}
+		starts = append(starts, strings.Count(content[:offset+i], "\n"))
+		offset += i + len(old)
+	}
+}
+
+func lineList(starts []int) string {
+	s := make([]string, 0, len(starts))
+	for i, l := range starts {
+		if i == 10 {
+			s = append(s, "…")
+			break
+		}
+		s = append(s, fmt.Sprint(l+1))
+	}
+	return strings.Join(s, ", ")
+}
+
+func nearest(starts []int, line int) int {
+	best := starts[0]
+	for _, s := range starts[1:] {
+		if abs(s-line) < abs(best-line) {
+			best = s
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func indent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// reindent rewrites the indentation of text's lines to the file's, mapping
+// each indentation used in oldLines to that of the file line it matched.
+// Other indentations get the first line's offset replaced.
+func reindent(text string, oldLines, fileLines []string) string {
+	indents := make(map[string]string)
+	var base, fileBase string
+	for i, l := range oldLines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		from, to := indent(l), indent(fileLines[i])
+		if len(indents) == 0 {
+			base, fileBase = from, to
+		}
+		if _, ok := indents[from]; !ok {
+			indents[from] = to
+		}
+	}
+	lines := strings.SplitAfter(text, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		from := indent(l)
+		if to, ok := indents[from]; ok {
+			lines[i] = to + l[len(from):]
+		} else if rest, ok := strings.CutPrefix(l, base); ok {
+			lines[i] = fileBase + rest
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,118 @@
+package tools_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step/tools"
+)
+
+const editSource = `package main
+
+func main() {
+	if ok {
+		run()
+	}
+}
+
+func other() {
+	run()
+}
+`
+
+func TestEditFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		want    string
+		fuzzy   bool
+		wantErr string
+	}{
+		{
+			name: "exact",
+			args: map[string]any{"old_string": "func other() {\n\trun()", "new_string": "func other() {\n\tstop()"},
+			want: strings.Replace(editSource, "func other() {\n\trun()", "func other() {\n\tstop()", 1),
+		},
+		{
+			name:  "reindented",
+			args:  map[string]any{"old_string": "if ok {\n  run()\n}", "new_string": "if ok {\n  run()\n  done()\n}"},
+			want:  strings.Replace(editSource, "\t\trun()\n\t}", "\t\trun()\n\t\tdone()\n\t}", 1),
+			fuzzy: true,
+		},
+		{
+			name:    "ambiguous",
+			args:    map[string]any{"old_string": "run()", "new_string": "go()"},
+			wantErr: "old_string matches 2 locations, at lines 5, 10",
+		},
+		{
+			name: "replace all",
+			args: map[string]any{"old_string": "run()", "new_string": "go()", "replace_all": true},
+			want: strings.ReplaceAll(editSource, "run()", "go()"),
+		},
+		{
+			name:    "not found",
+			args:    map[string]any{"old_string": "func main() {\n\tif !ok {", "new_string": "x"},
+			wantErr: "The closest match is at lines 3-4, where 1 of 2 lines match:\n     3\tfunc main() {\n     4\t\tif ok {\n",
+		},
+		{
+			name: "patch",
+			// The line numbers are off; the context decides.
+			args: map[string]any{"patch": "--- a/main.go\n+++ b/main.go\n@@ -8,3 +8,3 @@\n func other() {\n-\trun()\n+\tstop()\n }\n"},
+			want: strings.Replace(editSource, "func other() {\n\trun()", "func other() {\n\tstop()", 1),
+		},
+		{
+			name: "patch picks the nearest match",
+			args: map[string]any{"patch": "@@ -9,1 +9,1 @@\n-\trun()\n+\tstop()\n"},
+			want: strings.Replace(editSource, "func other() {\n\trun()", "func other() {\n\tstop()", 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "main.go")
+			if err := os.WriteFile(file, []byte(editSource), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tt.args["path"] = "main.go"
+			res := call(t, tools.EditFile(&tools.LocalExecutor{Dir: dir}), tt.args)
+			got, _ := os.ReadFile(file)
+			if tt.wantErr != "" {
+				if !res.IsError || !strings.Contains(text(res), tt.wantErr) {
+					t.Errorf("got %q, want an error containing %q", text(res), tt.wantErr)
+				}
+				if string(got) != editSource {
+					t.Error("a failed edit changed the file")
+				}
+				return
+			}
+			if res.IsError {
+				t.Fatalf("unexpected error %q", text(res))
+			}
+			if string(got) != tt.want {
+				t.Errorf("file = %q, want %q", got, tt.want)
+			}
+			if res.Details[tools.DetailsFuzzy] != tt.fuzzy || !strings.HasPrefix(res.Details[tools.DetailsDiff].(string), "--- a/main.go\n+++ b/main.go\n") {
+				t.Errorf("unexpected details %v", res.Details)
+			}
+		})
+	}
+}
+
+func TestEditFile_CreateAndCRLF(t *testing.T) {
+	dir := t.TempDir()
+	edit := tools.EditFile(&tools.LocalExecutor{Dir: dir})
+	if res := call(t, edit, map[string]any{"path": "new.txt", "old_string": "", "new_string": "a\r\nb\r\n"}); res.IsError {
+		t.Fatalf("create failed: %s", text(res))
+	}
+	if res := call(t, edit, map[string]any{"path": "new.txt", "old_string": "b\n", "new_string": "c\n"}); res.IsError {
+		t.Fatalf("edit failed: %s", text(res))
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "new.txt")); string(data) != "a\r\nc\r\n" {
+		t.Errorf("file = %q, want CRLF line endings kept", data)
+	}
+	if res := call(t, edit, map[string]any{"path": "new.txt", "old_string": "", "new_string": "x"}); !res.IsError {
+		t.Error("expected an empty old_string to refuse overwriting an existing file")
+	}
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Executor runs commands and accesses files for the bash, read_file and
+// write_file tools, so the same tools work on the host, in a container or in a
+// sandbox. Relative paths are relative to the executor's working directory.
+type Executor interface {
+	// Run runs cmd and returns its combined output. A command that ran and
+	// failed is reported in CommandResult.ExitCode, not as an error.
+	Run(ctx context.Context, cmd Command) (CommandResult, error)
+	ReadFile(ctx context.Context, path string) ([]byte, error)
+	// WriteFile writes data to path, creating missing parent directories.
+	WriteFile(ctx context.Context, path string, data []byte) error
+}
+
+// Command is a shell script to run.
+type Command struct {
+	Script string
+	// Dir is the directory to run in, relative to the working directory.
+	Dir string
+}
+
+// CommandResult is the outcome of Executor.Run.
+type CommandResult struct {
+	Output   []byte
+	ExitCode int
+}
+
+// waitDelay bounds how long Run waits for output after the command was
+// killed, e.g. when a background child keeps the pipe open.
+const waitDelay = 5 * time.Second
+
+// LocalExecutor runs commands on the host with os/exec, with no isolation.
+type LocalExecutor struct {
+	// Dir is the working directory. Defaults to the process's.
+	Dir string
+	// Env is added to the process environment.
+	Env []string
+	// Shell defaults to "bash".
+	Shell string
+}
+
+var _ Executor = (*LocalExecutor)(nil)
+
+func (e *LocalExecutor) Run(ctx context.Context, cmd Command) (CommandResult, error) {
+	shell := e.Shell
+	if shell == "" {
+		shell = "bash"
+	}
+	c := exec.CommandContext(ctx, shell, "-c", cmd.Script)
+	c.Dir = e.path(cmd.Dir)
+	if len(e.Env) > 0 {
+		c.Env = append(os.Environ(), e.Env...)
+	}
+	c.WaitDelay = waitDelay
+	return runCommand(ctx, c)
+}
+
+func (e *LocalExecutor) ReadFile(_ context.Context, path string) ([]byte, error) {
+	return os.ReadFile(e.path(path))
+}
+
+func (e *LocalExecutor) WriteFile(_ context.Context, path string, data []byte) error {
+	path = e.path(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (e *LocalExecutor) path(p string) string {
+	if filepath.IsAbs(p) || e.Dir == "" {
+		return p
+	}
+	return filepath.Join(e.Dir, p)
+}
+
+// runCommand runs c, collecting its combined output and exit code.
+func runCommand(ctx context.Context, c *exec.Cmd) (CommandResult, error) {
+	var out bytes.Buffer
+	c.Stdout, c.Stderr = &out, &out
+	err := c.Run()
+	res := CommandResult{Output: out.Bytes()}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return res, ctx.Err()
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+		return res, nil
+	}
+	return res, err
+}
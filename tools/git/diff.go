@@ -0,0 +1,174 @@
+package git
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// DetailsDiff is the ToolResult.Details key holding the structured diff of a
+// git_diff, git_commit or git_apply call, as []FileDiff. Hosts read it with
+// DiffFromDetails.
+const DetailsDiff = "diff"
+
+// FileDiff is the change to one file in a unified diff.
+type FileDiff struct {
+	// OldPath is empty for a created file, NewPath for a deleted one.
+	OldPath string `json:"old_path,omitempty"`
+	NewPath string `json:"new_path,omitempty"`
+	Binary  bool   `json:"binary,omitempty"`
+	Hunks   []Hunk `json:"hunks,omitempty"`
+}
+
+// Hunk is a run of changed lines with their context.
+type Hunk struct {
+	OldStart int `json:"old_start"`
+	OldLines int `json:"old_lines"`
+	NewStart int `json:"new_start"`
+	NewLines int `json:"new_lines"`
+	// Header is the text after the range, usually the enclosing function.
+	Header string `json:"header,omitempty"`
+	// Lines keep their ' ', '-', '+' or '\' prefix.
+	Lines []string `json:"lines"`
+}
+
+// ParseDiff parses the unified diff text, as printed by git diff or diff -u.
+// Text outside file sections is ignored.
+func ParseDiff(text string) []FileDiff {
+	var (
+		files            []FileDiff
+		file             *FileDiff
+		hunk             *Hunk
+		oldLeft, newLeft int // lines left in the hunk
+	)
+	startFile := func() {
+		files = append(files, FileDiff{})
+		file, hunk = &files[len(files)-1], nil
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if hunk != nil {
+			switch {
+			case strings.HasPrefix(line, `\`):
+				hunk.Lines = append(hunk.Lines, line)
+				continue
+			case oldLeft > 0 || newLeft > 0:
+				switch {
+				case strings.HasPrefix(line, "-"):
+					oldLeft--
+				case strings.HasPrefix(line, "+"):
+					newLeft--
+				default:
+					// Editors strip trailing spaces, leaving empty context lines.
+					if line == "" {
+						line = " "
+					}
+					oldLeft--
+					newLeft--
+				}
+				hunk.Lines = append(hunk.Lines, line)
+				continue
+			}
+			hunk = nil
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			startFile()
+			a, b, _ := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/")
+			file.OldPath, file.NewPath = strings.TrimPrefix(a, "a/"), b
+		case strings.HasPrefix(line, "--- "):
+			// Plain diffs have no diff --git line; a --- line after hunks
+			// starts the next file.
+			if file == nil || len(file.Hunks) > 0 || file.Binary {
+				startFile()
+			}
+			file.OldPath = diffPath(line[4:], "a/")
+		case strings.HasPrefix(line, "+++ ") && file != nil:
+			file.NewPath = diffPath(line[4:], "b/")
+		case file == nil:
+		case strings.HasPrefix(line, "new file mode"):
+			file.OldPath = ""
+		case strings.HasPrefix(line, "deleted file mode"):
+			file.NewPath = ""
+		case strings.HasPrefix(line, "rename from "):
+			file.OldPath = line[len("rename from "):]
+		case strings.HasPrefix(line, "rename to "):
+			file.NewPath = line[len("rename to "):]
+		case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch":
+			file.Binary = true
+		case strings.HasPrefix(line, "@@ "):
+			h, ok := parseHunkHeader(line)
+			if !ok {
+				continue
+			}
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLeft, newLeft = h.OldLines, h.NewLines
+		}
+	}
+	return files
+}
+
+// diffPath returns the path of a ---/+++ line without its prefix and
+// timestamp, or "" for /dev/null.
+func diffPath(s, prefix string) string {
+	s, _, _ = strings.Cut(s, "\t")
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@ header".
+func parseHunkHeader(line string) (Hunk, bool) {
+	ranges, header, ok := strings.Cut(line[3:], " @@")
+	if !ok {
+		return Hunk{}, false
+	}
+	oldRange, newRange, ok := strings.Cut(ranges, " ")
+	if !ok || !strings.HasPrefix(oldRange, "-") || !strings.HasPrefix(newRange, "+") {
+		return Hunk{}, false
+	}
+	h := Hunk{Header: strings.TrimSpace(header), Lines: []string{}}
+	if h.OldStart, h.OldLines, ok = parseRange(oldRange[1:]); !ok {
+		return Hunk{}, false
+	}
+	if h.NewStart, h.NewLines, ok = parseRange(newRange[1:]); !ok {
+		return Hunk{}, false
+	}
+	return h, true
+}
+
+// parseRange parses "l,s" or "l", where a missing count is 1.
+func parseRange(s string) (start, count int, ok bool) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !hasCount {
+		return start, 1, true
+	}
+	count, err = strconv.Atoi(countStr)
+	return start, count, err == nil
+}
+
+// DiffFromDetails returns the structured diff stored under DetailsDiff,
+// whether Details holds []FileDiff or was decoded from JSON.
+func DiffFromDetails(details map[string]any) ([]FileDiff, bool) {
+	v, ok := details[DetailsDiff]
+	if !ok {
+		return nil, false
+	}
+	if files, ok := v.([]FileDiff); ok {
+		return files, true
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var files []FileDiff
+	if err := json.Unmarshal(raw, &files); err != nil {
+		return nil, false
+	}
+	return files, true
+}
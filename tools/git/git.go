@@ -0,0 +1,380 @@
+// Package git provides tools for working with a git repository through the git
+// CLI: git_status, git_diff, git_commit, git_branch and git_apply. Commands run
+// with a tools.Executor, so the tools work on the host, in a container or in a
+// sandbox, and in a tools.Workspace.
+//
+// The tools have safe defaults: there is no push or reset, commits never amend
+// or skip hooks, and branches can be listed, created and switched to but not
+// deleted or forced. Diffs are returned as text for the model and as []FileDiff
+// in Details under DetailsDiff for hosts to render.
+package git
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/tools"
+)
+
+// Tool names.
+const (
+	StatusName = "git_status"
+	DiffName   = "git_diff"
+	CommitName = "git_commit"
+	BranchName = "git_branch"
+	ApplyName  = "git_apply"
+)
+
+// DetailsStatus is the ToolResult.Details key holding the branch and files of
+// a git_status call, as RepoStatus.
+const DetailsStatus = "status"
+
+const (
+	timeout = time.Minute
+	// maxOutput bounds the bytes of git output returned to the model.
+	maxOutput = 30000
+)
+
+// Tools returns all git tools running with exec in the repository at its
+// working directory.
+func Tools(exec tools.Executor) []step.Tool {
+	return []step.Tool{Status(exec), Diff(exec), Commit(exec), Branch(exec), Apply(exec)}
+}
+
+// RepoStatus is the state of the working tree.
+type RepoStatus struct {
+	Branch string       `json:"branch"`
+	Files  []FileStatus `json:"files"`
+}
+
+// FileStatus is a changed or untracked file, with the two status letters of
+// git status --porcelain, e.g. "M" in the worktree or "?" for untracked.
+type FileStatus struct {
+	Path string `json:"path"`
+	// OrigPath is the source of a rename or copy.
+	OrigPath string `json:"orig_path,omitempty"`
+	Index    string `json:"index"`
+	Worktree string `json:"worktree"`
+}
+
+// Status returns a parallel tool that shows the current branch and the changed
+// and untracked files.
+func Status(exec tools.Executor) step.Tool { return &statusTool{exec: exec} }
+
+// Diff returns a parallel tool that shows unstaged, staged or ref-to-ref
+// changes.
+func Diff(exec tools.Executor) step.Tool { return &diffTool{exec: exec} }
+
+// Commit returns a tool that stages the given paths, or all changes, and
+// commits them. It never amends and runs the repository's hooks.
+func Commit(exec tools.Executor) step.Tool { return &commitTool{exec: exec} }
+
+// Branch returns a tool that lists branches, creates a branch or switches to
+// one. Switching refuses to discard local changes.
+func Branch(exec tools.Executor) step.Tool { return &branchTool{exec: exec} }
+
+// Apply returns a tool that applies a unified diff to the working tree. The
+// patch is applied entirely or not at all.
+func Apply(exec tools.Executor) step.Tool { return &applyTool{exec: exec} }
+
+// run runs script with exec and returns its output and whether it succeeded.
+// A non-zero exit or a timeout is a failed run with the reason in the output;
+// the error is set only if the command could not run or ctx is done.
+func run(ctx context.Context, exec tools.Executor, script string) (string, bool, error) {
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res, err := exec.Run(runCtx, tools.Command{Script: script})
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", false, ctx.Err()
+		}
+		if runCtx.Err() != nil {
+			return fmt.Sprintf("git timed out after %s", timeout), false, nil
+		}
+		return "", false, err
+	}
+	out := string(res.Output)
+	if res.ExitCode != 0 {
+		if strings.TrimSpace(out) == "" {
+			out = fmt.Sprintf("git exited with code %d", res.ExitCode)
+		}
+		return out, false, nil
+	}
+	return out, true, nil
+}
+
+// git returns the shell command running git with args, quoted.
+func git(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = quote(a)
+	}
+	// No pager, no colors and no editor, whatever the user's config says.
+	return "GIT_PAGER=cat GIT_EDITOR=true git -c color.ui=false " + strings.Join(quoted, " ")
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func textResult(text string, details map[string]any) step.ToolResult {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: text}}, Details: details}
+}
+
+func errorResult(text string) step.ToolResult {
+	return step.ToolResult{IsError: true, Parts: []step.Part{step.TextPart{Text: text}}}
+}
+
+// result turns the outcome of run into a tool result.
+func result(out string, ok bool, err error, details map[string]any) (step.ToolResult, error) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return step.ToolResult{}, err
+		}
+		return errorResult(err.Error()), nil
+	}
+	if !ok {
+		return errorResult(truncate(out)), nil
+	}
+	return textResult(truncate(out), details), nil
+}
+
+// truncate keeps the head and tail of long output.
+func truncate(out string) string {
+	if len(out) <= maxOutput {
+		return out
+	}
+	head, tail := out[:maxOutput/2], out[len(out)-maxOutput/2:]
+	return fmt.Sprintf("%s\n[... %d bytes omitted ...]\n%s", strings.ToValidUTF8(head, ""), len(out)-len(head)-len(tail), strings.ToValidUTF8(tail, ""))
+}
+
+func spec(name, description string, properties map[string]any, required []string, access step.Access) step.ToolSpec {
+	params := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		params["required"] = required
+	}
+	return step.ToolSpec{Name: name, Description: description, Parameters: params, Access: access}
+}
+
+type statusTool struct{ exec tools.Executor }
+
+func (t *statusTool) Spec() step.ToolSpec {
+	return spec(StatusName, "Show the current git branch and the changed, staged and untracked files.", map[string]any{}, nil, step.AccessReadOnly)
+}
+
+func (t *statusTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	out, ok, err := run(ctx, t.exec, git("status", "--porcelain=v1", "--branch", "--untracked-files=all"))
+	if err != nil || !ok {
+		return result(out, ok, err, nil)
+	}
+	status := parseStatus(out)
+	if len(status.Files) == 0 {
+		out += "(working tree clean)\n"
+	}
+	return textResult(truncate(out), map[string]any{DetailsStatus: status}), nil
+}
+
+// parseStatus parses git status --porcelain=v1 --branch.
+func parseStatus(out string) RepoStatus {
+	status := RepoStatus{Files: []FileStatus{}}
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if branch, ok := strings.CutPrefix(line, "## "); ok {
+			branch = strings.TrimPrefix(branch, "No commits yet on ")
+			branch, _, _ = strings.Cut(branch, "...")
+			branch, _, _ = strings.Cut(branch, " ")
+			status.Branch = branch
+			continue
+		}
+		if len(line) < 4 {
+			continue
+		}
+		f := FileStatus{Index: strings.TrimSpace(line[:1]), Worktree: strings.TrimSpace(line[1:2]), Path: line[3:]}
+		if from, to, ok := strings.Cut(f.Path, " -> "); ok {
+			f.OrigPath, f.Path = from, to
+		}
+		status.Files = append(status.Files, f)
+	}
+	return status
+}
+
+type diffTool struct{ exec tools.Executor }
+
+func (t *diffTool) Spec() step.ToolSpec {
+	return spec(DiffName, "Show changes as a unified diff: unstaged changes by default, staged changes with staged, or changes since a ref.", map[string]any{
+		"staged": map[string]any{"type": "boolean", "description": "Show staged changes instead of unstaged ones"},
+		"ref":    map[string]any{"type": "string", "description": "Compare the working tree with this commit, branch or range such as main...HEAD"},
+		"paths":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Limit the diff to these paths"},
+	}, nil, step.AccessReadOnly)
+}
+
+func (t *diffTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Staged bool     `json:"staged"`
+		Ref    string   `json:"ref"`
+		Paths  []string `json:"paths"`
+	}
+	if len(call.ArgsJSON) > 0 {
+		if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+			return errorResult("invalid arguments: " + err.Error()), nil
+		}
+	}
+	if strings.HasPrefix(args.Ref, "-") {
+		return errorResult("ref must not start with -"), nil
+	}
+	gitArgs := []string{"diff", "--no-ext-diff"}
+	if args.Staged {
+		gitArgs = append(gitArgs, "--cached")
+	}
+	if args.Ref != "" {
+		gitArgs = append(gitArgs, args.Ref)
+	}
+	gitArgs = append(append(gitArgs, "--"), args.Paths...)
+	out, ok, err := run(ctx, t.exec, git(gitArgs...))
+	if err != nil || !ok {
+		return result(out, ok, err, nil)
+	}
+	files := ParseDiff(out)
+	if out == "" {
+		out = "(no changes)"
+	}
+	return textResult(truncate(out), map[string]any{DetailsDiff: files}), nil
+}
+
+type commitTool struct{ exec tools.Executor }
+
+func (t *commitTool) Spec() step.ToolSpec {
+	return spec(CommitName, "Stage files and commit them. Give the paths to commit, or set all to commit every change including untracked files.", map[string]any{
+		"message": map[string]any{"type": "string", "description": "The commit message"},
+		"paths":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Files to stage before committing"},
+		"all":     map[string]any{"type": "boolean", "description": "Stage all changes, including untracked files"},
+	}, []string{"message"}, step.AccessWrite)
+}
+
+func (t *commitTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Message string   `json:"message"`
+		Paths   []string `json:"paths"`
+		All     bool     `json:"all"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	if strings.TrimSpace(args.Message) == "" {
+		return errorResult("git_commit requires a non-empty message"), nil
+	}
+	switch {
+	case args.All:
+		if out, ok, err := run(ctx, t.exec, git("add", "--all")); err != nil || !ok {
+			return result(out, ok, err, nil)
+		}
+	case len(args.Paths) > 0:
+		if out, ok, err := run(ctx, t.exec, git(append([]string{"add", "--"}, args.Paths...)...)); err != nil || !ok {
+			return result(out, ok, err, nil)
+		}
+	}
+	staged, ok, err := run(ctx, t.exec, git("diff", "--cached", "--no-ext-diff"))
+	if err != nil || !ok {
+		return result(staged, ok, err, nil)
+	}
+	if staged == "" {
+		return errorResult("nothing to commit: stage files with paths or all"), nil
+	}
+	delim := heredoc(args.Message)
+	out, ok, err := run(ctx, t.exec, git("commit", "--file=-", "--cleanup=strip")+" <<'"+delim+"'\n"+args.Message+"\n"+delim)
+	if err != nil || !ok {
+		return result(out, ok, err, nil)
+	}
+	details := map[string]any{DetailsDiff: ParseDiff(staged)}
+	if hash, ok, _ := run(ctx, t.exec, git("rev-parse", "HEAD")); ok {
+		details["commit"] = strings.TrimSpace(hash)
+	}
+	return textResult(truncate(out), details), nil
+}
+
+// heredoc returns a here-document delimiter that does not occur in text.
+func heredoc(text string) string {
+	for {
+		b := make([]byte, 8)
+		_, _ = rand.Read(b)
+		delim := "EOF_" + hex.EncodeToString(b)
+		if !strings.Contains(text, delim) {
+			return delim
+		}
+	}
+}
+
+type branchTool struct{ exec tools.Executor }
+
+func (t *branchTool) Spec() step.ToolSpec {
+	return spec(BranchName, "List branches, create a branch, or switch to one. Without arguments, lists branches.", map[string]any{
+		"name":   map[string]any{"type": "string", "description": "Branch to switch to, or to create with create"},
+		"create": map[string]any{"type": "boolean", "description": "Create the branch from the current commit and switch to it"},
+	}, nil, step.AccessWrite)
+}
+
+func (t *branchTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Name   string `json:"name"`
+		Create bool   `json:"create"`
+	}
+	if len(call.ArgsJSON) > 0 {
+		if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+			return errorResult("invalid arguments: " + err.Error()), nil
+		}
+	}
+	if args.Name == "" {
+		out, ok, err := run(ctx, t.exec, git("branch", "--list", "--no-color"))
+		return result(out, ok, err, nil)
+	}
+	if strings.HasPrefix(args.Name, "-") {
+		return errorResult("branch name must not start with -"), nil
+	}
+	gitArgs := []string{"switch", "--no-guess", args.Name}
+	if args.Create {
+		gitArgs = []string{"switch", "--create", args.Name}
+	}
+	out, ok, err := run(ctx, t.exec, git(gitArgs...))
+	return result(out, ok, err, map[string]any{"branch": args.Name})
+}
+
+type applyTool struct{ exec tools.Executor }
+
+func (t *applyTool) Spec() step.ToolSpec {
+	return spec(ApplyName, "Apply a unified diff, as printed by git diff, to the working tree. The patch is applied entirely or not at all.", map[string]any{
+		"patch": map[string]any{"type": "string", "description": "The unified diff"},
+	}, []string{"patch"}, step.AccessWrite)
+}
+
+func (t *applyTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Patch string `json:"patch"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	files := ParseDiff(args.Patch)
+	if len(files) == 0 {
+		return errorResult("patch contains no file changes"), nil
+	}
+	patch := strings.TrimSuffix(args.Patch, "\n")
+	delim := heredoc(patch)
+	out, ok, err := run(ctx, t.exec, git("apply", "--recount", "--whitespace=nowarn", "-")+" <<'"+delim+"'\n"+patch+"\n"+delim)
+	if err != nil || !ok {
+		return result(out, ok, err, nil)
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.NewPath
+		if paths[i] == "" {
+			paths[i] = f.OldPath + " (deleted)"
+		}
+	}
+	return textResult("Applied patch to "+strings.Join(paths, ", ")+".", map[string]any{DetailsDiff: files}), nil
+}
@@ -0,0 +1,125 @@
+package git_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/tools"
+	"github.com/inspirepan/step/tools/git"
+)
+
+func call(t *testing.T, tool step.Tool, args map[string]any) step.ToolResult {
+	t.Helper()
+	data, _ := json.Marshal(args)
+	res, err := tool.Execute(context.Background(), step.ToolCallPart{CallID: "c1", Name: tool.Spec().Name, ArgsJSON: data})
+	if err != nil {
+		t.Fatalf("%s failed: %v", tool.Spec().Name, err)
+	}
+	return res
+}
+
+func text(res step.ToolResult) string { return res.Parts[0].(step.TextPart).Text }
+
+func newRepo(t *testing.T) (string, tools.Executor) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	e := &tools.LocalExecutor{Dir: dir, Env: []string{
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+	}}
+	res, err := e.Run(context.Background(), tools.Command{Script: "git init -q -b main"})
+	if err != nil || res.ExitCode != 0 {
+		t.Fatalf("git init: %v %s", err, res.Output)
+	}
+	return dir, e
+}
+
+func TestGitTools(t *testing.T) {
+	dir, e := newRepo(t)
+	ts := git.Tools(e)
+	status, diff, commit, branch, apply := ts[0], ts[1], ts[2], ts[3], ts[4]
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := call(t, status, nil)
+	st := res.Details[git.DetailsStatus].(git.RepoStatus)
+	if st.Branch != "main" || len(st.Files) != 1 || st.Files[0] != (git.FileStatus{Path: "a.txt", Index: "?", Worktree: "?"}) {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if res := call(t, commit, map[string]any{"message": "Add a.txt\n\nIt's the first file.", "all": true}); res.IsError || res.Details["commit"] == "" {
+		t.Fatalf("commit failed: %s", text(res))
+	}
+	if res := call(t, commit, map[string]any{"message": "Empty", "all": true}); !res.IsError {
+		t.Errorf("expected an error for an empty commit, got %q", text(res))
+	}
+
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n"
+	res = call(t, apply, map[string]any{"patch": patch})
+	if res.IsError {
+		t.Fatalf("apply failed: %s", text(res))
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "one\n2\n" {
+		t.Errorf("a.txt after apply = %q", data)
+	}
+	if res := call(t, apply, map[string]any{"patch": patch}); !res.IsError {
+		t.Error("expected a conflicting patch to fail")
+	}
+
+	res = call(t, diff, nil)
+	files, ok := git.DiffFromDetails(res.Details)
+	want := []git.FileDiff{{OldPath: "a.txt", NewPath: "a.txt", Hunks: []git.Hunk{{OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2, Lines: []string{" one", "-two", "+2"}}}}}
+	if !ok || !reflect.DeepEqual(files, want) {
+		t.Errorf("diff details = %+v", files)
+	}
+
+	if res := call(t, branch, map[string]any{"name": "feature", "create": true}); res.IsError {
+		t.Fatalf("create branch failed: %s", text(res))
+	}
+	if res := call(t, branch, nil); !strings.Contains(text(res), "* feature") {
+		t.Errorf("branch list = %q", text(res))
+	}
+	if res := call(t, branch, map[string]any{"name": "--force"}); !res.IsError {
+		t.Error("expected option-like branch names to be refused")
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	text := `diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3e75765
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++new
+\ No newline at end of file
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1,2 +0,0 @@ func main() {
+--- dashes
+-x
+diff --git a/img.png b/img.png
+Binary files a/img.png and b/img.png differ
+`
+	want := []git.FileDiff{
+		{NewPath: "new.txt", Hunks: []git.Hunk{{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 1, Lines: []string{"+new", `\ No newline at end of file`}}}},
+		{OldPath: "old.txt", Hunks: []git.Hunk{{OldStart: 1, OldLines: 2, Header: "func main() {", Lines: []string{"--- dashes", "-x"}}}},
+		{OldPath: "img.png", NewPath: "img.png", Binary: true},
+	}
+	if got := git.ParseDiff(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDiff =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+// GrepName is the tool name of Grep.
+const GrepName = "grep"
+
+// Details keys of grep results.
+const (
+	DetailsMatches   = "matches"
+	DetailsFiles     = "files"
+	DetailsTruncated = "truncated"
+)
+
+const (
+	defaultMaxMatches = 100
+	maxMaxMatches     = 1000
+	maxContext        = 10
+	// maxGrepFileSize bounds the files searched; larger ones are skipped.
+	maxGrepFileSize = 10 << 20
+	// maxLineLength bounds printed lines, e.g. of minified files.
+	maxLineLength = 500
+)
+
+// Grep returns a parallel tool that searches the files of ws for a regular
+// expression, printing matches as path:line:text like ripgrep. Ignored paths,
+// binary files and files over 10 MiB are skipped. Files are read on the host,
+// whatever the workspace's Executor.
+func Grep(ws *Workspace) step.Tool {
+	return &grepTool{ws: ws}
+}
+
+type grepTool struct{ ws *Workspace }
+
+func (t *grepTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name: GrepName,
+		Description: "Search file contents for a regular expression (RE2 syntax) and print matching lines as path:line:text. " +
+			"Use glob to filter files and context to show surrounding lines.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"pattern":     map[string]any{"type": "string", "description": "The regular expression"},
+				"path":        map[string]any{"type": "string", "description": "File or directory to search, relative to the workspace root. Defaults to the root"},
+				"glob":        map[string]any{"type": "string", "description": `Only search files matching this glob, e.g. "*.go" or "src/*.ts"`},
+				"ignore_case": map[string]any{"type": "boolean", "description": "Match case-insensitively"},
+				"context":     map[string]any{"type": "integer", "description": fmt.Sprintf("Lines of context around each match, up to %d", maxContext)},
+				"max_matches": map[string]any{"type": "integer", "description": fmt.Sprintf("Stop after this many matches. Defaults to %d", defaultMaxMatches)},
+			},
+			"required": []string{"pattern"},
+		},
+		Access: step.AccessReadOnly,
+	}
+}
+
+func (t *grepTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Pattern    string `json:"pattern"`
+		Path       string `json:"path"`
+		Glob       string `json:"glob"`
+		IgnoreCase bool   `json:"ignore_case"`
+		Context    int    `json:"context"`
+		MaxMatches int    `json:"max_matches"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	pattern := args.Pattern
+	if args.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errorResult("invalid pattern: " + err.Error()), nil
+	}
+	if args.Glob != "" {
+		if _, err := path.Match(args.Glob, ""); err != nil {
+			return errorResult("invalid glob: " + err.Error()), nil
+		}
+	}
+	start, err := t.ws.resolve(args.Path)
+	if err != nil {
+		return errorResult(err.Error()), nil
+	}
+	g := grep{
+		re:         re,
+		glob:       strings.TrimPrefix(args.Glob, "**/"),
+		context:    min(max(args.Context, 0), maxContext),
+		maxMatches: min(args.MaxMatches, maxMaxMatches),
+	}
+	if g.maxMatches <= 0 {
+		g.maxMatches = defaultMaxMatches
+	}
+
+	err = filepath.WalkDir(start, func(full string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped rather than failing the search.
+			if d != nil && d.IsDir() && full != start {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(t.ws.root, full)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && t.ws.ignored(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !g.matchesGlob(rel) {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxGrepFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			return nil
+		}
+		if g.search(rel, data) {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return step.ToolResult{}, ctx.Err()
+		}
+		return errorResult(err.Error()), nil
+	}
+
+	text := g.out.String()
+	switch {
+	case g.matches == 0:
+		text = "No matches."
+	case g.truncated:
+		text += fmt.Sprintf("\n[stopped after %d matches; narrow the pattern, path or glob]", g.maxMatches)
+	}
+	return step.ToolResult{
+		Parts:   []step.Part{step.TextPart{Text: truncate([]byte(text))}},
+		Details: map[string]any{DetailsMatches: g.matches, DetailsFiles: g.files, DetailsTruncated: g.truncated},
+	}, nil
+}
+
+type grep struct {
+	re         *regexp.Regexp
+	glob       string
+	context    int
+	maxMatches int
+
+	out       strings.Builder
+	matches   int
+	files     int
+	truncated bool
+}
+
+func (g *grep) matchesGlob(rel string) bool {
+	if g.glob == "" {
+		return true
+	}
+	name := rel
+	if !strings.Contains(g.glob, "/") {
+		name = path.Base(rel)
+	}
+	ok, _ := path.Match(g.glob, name)
+	return ok
+}
+
+// search prints the matches in data and reports whether the match limit was
+// reached.
+func (g *grep) search(rel string, data []byte) bool {
+	// Like ripgrep, files with a NUL byte near the start are binary.
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return false
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	printed := -1 // last printed line index
+	found := false
+	for i, line := range lines {
+		if !g.re.MatchString(line) {
+			continue
+		}
+		if g.matches == g.maxMatches {
+			g.truncated = true
+			return true
+		}
+		g.matches++
+		if !found {
+			found = true
+			g.files++
+		}
+		from := max(i-g.context, printed+1)
+		if g.context > 0 && g.out.Len() > 0 && (printed < 0 || from > printed+1) {
+			g.out.WriteString("--\n")
+		}
+		for j := from; j < i; j++ {
+			g.printLine(rel, j, lines[j], '-')
+		}
+		g.printLine(rel, i, line, ':')
+		printed = i
+		// Trailing context stops at the next match, which prints its own.
+		for j := i + 1; j <= min(i+g.context, len(lines)-1) && !g.re.MatchString(lines[j]); j++ {
+			g.printLine(rel, j, lines[j], '-')
+			printed = j
+		}
+	}
+	return false
+}
+
+func (g *grep) printLine(rel string, i int, line string, sep byte) {
+	line = strings.TrimSuffix(line, "\r")
+	if len(line) > maxLineLength {
+		line = strings.ToValidUTF8(line[:maxLineLength], "") + "…"
+	}
+	fmt.Fprintf(&g.out, "%s%c%d%c%s\n", rel, sep, i+1, sep, line)
+}
+
+// resolve returns the absolute path of p, relative to the root, refusing
+// paths outside it.
+func (w *Workspace) resolve(p string) (string, error) {
+	full := filepath.Join(w.root, p)
+	if filepath.IsAbs(p) {
+		full = filepath.Clean(p)
+	}
+	if !inside(w.root, full) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideRoot, p)
+	}
+	if _, err := os.Stat(full); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%s does not exist", p)
+		}
+		return "", err
+	}
+	return full, nil
+}
@@ -0,0 +1,60 @@
+package tools_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/inspirepan/step/tools"
+)
+
+func TestGrep(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":           "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n\nfunc helper() {}\n",
+		"lib/util.go":       "package lib\n\n// Hello says hello.\nfunc Hello() {}\n",
+		"lib/notes.txt":     "hello from notes\n",
+		"node_modules/x.go": "func hello() {}\n",
+		"bin/tool":          "hello\x00binary",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := tools.NewWorkspace(root, tools.WithIgnore("node_modules"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	grep := tools.Grep(ws)
+	if !grep.Spec().RunsInParallel() {
+		t.Error("grep should be parallel")
+	}
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"ignore case", map[string]any{"pattern": "hello", "ignore_case": true},
+			"lib/notes.txt:1:hello from notes\nlib/util.go:3:// Hello says hello.\nlib/util.go:4:func Hello() {}\nmain.go:4:\tprintln(\"hello\")\n"},
+		{"glob", map[string]any{"pattern": "^func", "glob": "*.go", "path": "lib"},
+			"lib/util.go:4:func Hello() {}\n"},
+		{"context", map[string]any{"pattern": "^func", "glob": "main.go", "context": 1},
+			"main.go-2-\nmain.go:3:func main() {\nmain.go-4-\tprintln(\"hello\")\n--\nmain.go-6-\nmain.go:7:func helper() {}\n"},
+		{"max matches", map[string]any{"pattern": "func", "max_matches": 1},
+			"lib/util.go:4:func Hello() {}\n\n[stopped after 1 matches; narrow the pattern, path or glob]"},
+		{"no matches", map[string]any{"pattern": "nothing"}, "No matches."},
+	}
+	for _, tt := range tests {
+		res := call(t, grep, tt.args)
+		if res.IsError || text(res) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, text(res), tt.want)
+		}
+	}
+	if res := call(t, grep, map[string]any{"pattern": "x", "path": "../"}); !res.IsError {
+		t.Errorf("expected paths outside the root to be refused, got %q", text(res))
+	}
+}
@@ -0,0 +1,297 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/inspirepan/step"
+)
+
+// HTTPRequestName is the tool name of HTTPRequest.
+const HTTPRequestName = "http_request"
+
+// Details keys of http_request results.
+const (
+	DetailsStatusCode = "status_code"
+	DetailsURL        = "url"
+	// DetailsRetryable is true for failures that may pass when the request
+	// is repeated: timeouts, connection errors and statuses 429, 502, 503
+	// and 504.
+	DetailsRetryable = "retryable"
+)
+
+// ErrHostNotAllowed is returned for requests and redirects to hosts the
+// HTTPOptions do not allow.
+var ErrHostNotAllowed = errors.New("tools: host is not allowed")
+
+// HTTPOptions configures HTTPRequest.
+type HTTPOptions struct {
+	// AllowHosts lists the hosts the model may call, as names or globs such
+	// as "*.example.com". Empty allows every host not denied.
+	AllowHosts []string
+	// DenyHosts lists hosts the model may not call; it takes precedence over
+	// AllowHosts.
+	DenyHosts []string
+	// Methods lists the allowed methods. Defaults to GET, HEAD, POST, PUT,
+	// PATCH and DELETE.
+	Methods []string
+	// Headers are added to every request and override the model's, e.g. an
+	// Authorization header the model never sees.
+	Headers map[string]string
+	// MaxResponseSize bounds the bytes of response body read; longer bodies
+	// are cut. Defaults to 100 KiB.
+	MaxResponseSize int64
+	// Timeout bounds each request, including redirects. Defaults to 30s.
+	Timeout time.Duration
+	// Client sends the requests. Defaults to a client based on
+	// http.DefaultTransport. Its CheckRedirect is replaced to check hosts.
+	Client *http.Client
+}
+
+var defaultMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// HTTPRequest returns a tool that makes HTTP requests within opts. Only http
+// and https URLs are allowed, redirects are followed only to allowed hosts,
+// and JSON responses are pretty-printed. Responses with status 400 or above
+// are error results.
+func HTTPRequest(opts HTTPOptions) step.Tool {
+	if len(opts.Methods) == 0 {
+		opts.Methods = defaultMethods
+	}
+	opts.Methods = slices.Clone(opts.Methods)
+	for i, m := range opts.Methods {
+		opts.Methods[i] = strings.ToUpper(m)
+	}
+	if opts.MaxResponseSize <= 0 {
+		opts.MaxResponseSize = 100 << 10
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	client := &http.Client{}
+	if opts.Client != nil {
+		*client = *opts.Client
+	}
+	t := &httpTool{opts: opts, client: client}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errTooManyRedirects
+		}
+		return t.checkURL(req.URL)
+	}
+	return t
+}
+
+var errTooManyRedirects = errors.New("stopped after 10 redirects")
+
+type httpTool struct {
+	opts   HTTPOptions
+	client *http.Client
+}
+
+func (t *httpTool) Spec() step.ToolSpec {
+	desc := "Make an HTTP request and return the response status, headers and body. JSON bodies are pretty-printed."
+	if len(t.opts.AllowHosts) > 0 {
+		desc += " Allowed hosts: " + strings.Join(t.opts.AllowHosts, ", ") + "."
+	}
+	return step.ToolSpec{
+		Name:        HTTPRequestName,
+		Description: desc,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"method":  map[string]any{"type": "string", "enum": t.opts.Methods, "description": "The HTTP method. Defaults to GET"},
+				"url":     map[string]any{"type": "string", "description": "The http or https URL"},
+				"headers": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}, "description": "Request headers"},
+				"body":    map[string]any{"type": "string", "description": "The request body, e.g. JSON"},
+			},
+			"required": []string{"url"},
+		},
+		Access: t.access(),
+	}
+}
+
+// access is read-only when only GET and HEAD are allowed, and destructive
+// when DELETE is.
+func (t *httpTool) access() step.Access {
+	switch {
+	case slices.Contains(t.opts.Methods, "DELETE"):
+		return step.AccessDestructive
+	case slices.ContainsFunc(t.opts.Methods, func(m string) bool { return m != "GET" && m != "HEAD" }):
+		return step.AccessWrite
+	default:
+		return step.AccessReadOnly
+	}
+}
+
+func (t *httpTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Method  string            `json:"method"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Body    string            `json:"body"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	method := strings.ToUpper(args.Method)
+	if method == "" {
+		method = "GET"
+	}
+	if !slices.Contains(t.opts.Methods, method) {
+		return errorResult(fmt.Sprintf("method %s is not allowed; use one of %s", method, strings.Join(t.opts.Methods, ", "))), nil
+	}
+	u, err := url.Parse(args.URL)
+	if err != nil {
+		return errorResult("invalid url: " + err.Error()), nil
+	}
+	if err := t.checkURL(u); err != nil {
+		return errorResult(err.Error()), nil
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, t.opts.Timeout)
+	defer cancel()
+	var body io.Reader
+	if args.Body != "" {
+		body = strings.NewReader(args.Body)
+	}
+	req, err := http.NewRequestWithContext(reqCtx, method, u.String(), body)
+	if err != nil {
+		return errorResult(err.Error()), nil
+	}
+	for k, v := range args.Headers {
+		req.Header.Set(k, v)
+	}
+	if args.Body != "" && req.Header.Get("Content-Type") == "" && json.Valid([]byte(args.Body)) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range t.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return step.ToolResult{}, ctx.Err()
+		}
+		if reqCtx.Err() != nil {
+			return retryable(errorResult(fmt.Sprintf("request timed out after %s", t.opts.Timeout))), nil
+		}
+		if errors.Is(err, ErrHostNotAllowed) || errors.Is(err, errTooManyRedirects) {
+			return errorResult(err.Error()), nil
+		}
+		return retryable(errorResult(err.Error())), nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, t.opts.MaxResponseSize+1))
+	if err != nil {
+		if ctx.Err() != nil {
+			return step.ToolResult{}, ctx.Err()
+		}
+		return retryable(errorResult("reading response: " + err.Error())), nil
+	}
+	cut := int64(len(data)) > t.opts.MaxResponseSize
+	if cut {
+		data = data[:t.opts.MaxResponseSize]
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s\n", resp.Proto, resp.Status)
+	for _, k := range []string{"Content-Type", "Content-Length", "Location", "Retry-After"} {
+		if v := resp.Header.Get(k); v != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", k, v)
+		}
+	}
+	sb.WriteString("\n")
+	sb.WriteString(formatBody(data, resp.Header.Get("Content-Type"), cut))
+	if cut {
+		fmt.Fprintf(&sb, "\n[response cut at %d bytes]", t.opts.MaxResponseSize)
+	}
+	res := step.ToolResult{
+		IsError: resp.StatusCode >= 400,
+		Parts:   []step.Part{step.TextPart{Text: sb.String()}},
+		Details: map[string]any{DetailsStatusCode: resp.StatusCode, DetailsURL: resp.Request.URL.String()},
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		res.Details[DetailsRetryable] = true
+	}
+	return res, nil
+}
+
+// HTTPRetryOn is a step.RetryPolicy.RetryOn for http_request that retries
+// the failures marked with DetailsRetryable. Use it only for tools allowing
+// idempotent methods, e.g.
+//
+//	step.WithToolRetry(step.RetryPolicy{MaxAttempts: 3, RetryOn: tools.HTTPRetryOn}, tools.HTTPRequestName)
+func HTTPRetryOn(res step.ToolResult, err error) bool {
+	return err != nil || res.Details[DetailsRetryable] == true
+}
+
+// retryable marks res with DetailsRetryable.
+func retryable(res step.ToolResult) step.ToolResult {
+	if res.Details == nil {
+		res.Details = map[string]any{}
+	}
+	res.Details[DetailsRetryable] = true
+	return res
+}
+
+// checkURL checks the scheme and host of u against the options.
+func (t *httpTool) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q; use http or https", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return errors.New("url has no host")
+	}
+	if matchHost(host, t.opts.DenyHosts) || len(t.opts.AllowHosts) > 0 && !matchHost(host, t.opts.AllowHosts) {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	return nil
+}
+
+func matchHost(host string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// formatBody returns the body as text, pretty-printing complete JSON.
+func formatBody(data []byte, contentType string, cut bool) string {
+	if len(data) == 0 {
+		return "(empty body)"
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if !cut && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "") {
+		var buf bytes.Buffer
+		if json.Indent(&buf, data, "", "  ") == nil {
+			return buf.String()
+		}
+	}
+	if !utf8.Valid(data) {
+		if cut {
+			// The cut may split a UTF-8 sequence.
+			if s := strings.ToValidUTF8(string(data), ""); len(data)-len(s) < utf8.UTFMax {
+				return s
+			}
+		}
+		return fmt.Sprintf("(binary body, %d bytes)", len(data))
+	}
+	return string(data)
+}
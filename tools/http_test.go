@@ -0,0 +1,63 @@
+package tools_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step/tools"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"method":"`+r.Method+`","auth":"`+r.Header.Get("Authorization")+`","body":`+string(body)+`}`)
+		case "/big":
+			io.WriteString(w, strings.Repeat("x", 300))
+		case "/busy":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/away":
+			http.Redirect(w, r, "http://evil.example/", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tool := tools.HTTPRequest(tools.HTTPOptions{
+		AllowHosts:      []string{"127.0.0.1"},
+		Methods:         []string{"get", "post"},
+		Headers:         map[string]string{"Authorization": "Bearer secret"},
+		MaxResponseSize: 200,
+	})
+	res := call(t, tool, map[string]any{"method": "post", "url": srv.URL + "/echo", "body": `{"a":1}`, "headers": map[string]string{"Authorization": "mine"}})
+	want := "{\n  \"method\": \"POST\",\n  \"auth\": \"Bearer secret\",\n  \"body\": {\n    \"a\": 1\n  }\n}"
+	if res.IsError || !strings.HasSuffix(text(res), want) || res.Details[tools.DetailsStatusCode] != 200 {
+		t.Errorf("unexpected echo result %q", text(res))
+	}
+
+	if res := call(t, tool, map[string]any{"url": srv.URL + "/big"}); !strings.HasSuffix(text(res), strings.Repeat("x", 200)+"\n[response cut at 200 bytes]") {
+		t.Errorf("unexpected big result %q", text(res))
+	}
+	if res := call(t, tool, map[string]any{"url": srv.URL + "/missing"}); !res.IsError || res.Details[tools.DetailsStatusCode] != 404 || tools.HTTPRetryOn(res, nil) {
+		t.Errorf("expected a final error result for 404, got %q", text(res))
+	}
+	if res := call(t, tool, map[string]any{"url": srv.URL + "/busy"}); !res.IsError || !tools.HTTPRetryOn(res, nil) {
+		t.Errorf("expected a retryable error result for 503, got %q", text(res))
+	}
+	for name, args := range map[string]map[string]any{
+		"denied host": {"url": "http://example.com/"},
+		"redirect":    {"url": srv.URL + "/away"},
+		"method":      {"method": "DELETE", "url": srv.URL + "/echo"},
+		"scheme":      {"url": "file:///etc/passwd"},
+	} {
+		if res := call(t, tool, args); !res.IsError {
+			t.Errorf("%s: expected an error result, got %q", name, text(res))
+		}
+	}
+}
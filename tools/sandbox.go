@@ -0,0 +1,282 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DockerExecutor runs commands and file operations in a running Docker
+// container with docker exec. Use StartDocker to create an isolated container
+// for an agent.
+type DockerExecutor struct {
+	Container string
+	// Workdir is the working directory in the container. Defaults to the
+	// container's.
+	Workdir string
+	// User runs commands as another user, e.g. "1000:1000".
+	User string
+	// Docker is the docker binary. Defaults to "docker".
+	Docker string
+
+	started bool
+}
+
+var _ Executor = (*DockerExecutor)(nil)
+
+// DockerOptions configures StartDocker.
+type DockerOptions struct {
+	Image string
+	// Mounts are bind mounts as host:container[:ro], e.g. a project checkout.
+	Mounts []string
+	// Network enables networking; containers have none by default.
+	Network bool
+	Workdir string
+	User    string
+	// Memory and CPUs limit resources, e.g. "512m" and "1.5".
+	Memory string
+	CPUs   string
+	Docker string
+}
+
+// StartDocker starts a container from opts.Image that stays up until Close,
+// without networking unless opts.Network is set.
+func StartDocker(ctx context.Context, opts DockerOptions) (*DockerExecutor, error) {
+	docker := opts.Docker
+	if docker == "" {
+		docker = "docker"
+	}
+	args := []string{"run", "-d", "--rm", "--init"}
+	if !opts.Network {
+		args = append(args, "--network", "none")
+	}
+	for _, m := range opts.Mounts {
+		args = append(args, "-v", m)
+	}
+	if opts.Workdir != "" {
+		args = append(args, "-w", opts.Workdir)
+	}
+	if opts.Memory != "" {
+		args = append(args, "--memory", opts.Memory)
+	}
+	if opts.CPUs != "" {
+		args = append(args, "--cpus", opts.CPUs)
+	}
+	args = append(args, opts.Image, "sleep", "infinity")
+	out, err := exec.CommandContext(ctx, docker, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("docker run: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return &DockerExecutor{
+		Container: strings.TrimSpace(string(out)),
+		Workdir:   opts.Workdir,
+		User:      opts.User,
+		Docker:    docker,
+		started:   true,
+	}, nil
+}
+
+// Close removes the container if it was created by StartDocker.
+func (e *DockerExecutor) Close() error {
+	if !e.started {
+		return nil
+	}
+	out, err := exec.Command(e.docker(), "rm", "-f", e.Container).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker rm: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (e *DockerExecutor) docker() string {
+	if e.Docker == "" {
+		return "docker"
+	}
+	return e.Docker
+}
+
+// exec returns a docker exec command running args in dir.
+func (e *DockerExecutor) exec(ctx context.Context, dir string, stdin bool, args ...string) *exec.Cmd {
+	execArgs := []string{"exec"}
+	if stdin {
+		execArgs = append(execArgs, "-i")
+	}
+	if dir = e.path(dir); dir != "" {
+		execArgs = append(execArgs, "-w", dir)
+	}
+	if e.User != "" {
+		execArgs = append(execArgs, "-u", e.User)
+	}
+	execArgs = append(execArgs, e.Container)
+	c := exec.CommandContext(ctx, e.docker(), append(execArgs, args...)...)
+	c.WaitDelay = waitDelay
+	return c
+}
+
+func (e *DockerExecutor) path(p string) string {
+	if path.IsAbs(p) || e.Workdir == "" {
+		return p
+	}
+	return path.Join(e.Workdir, p)
+}
+
+func (e *DockerExecutor) Run(ctx context.Context, cmd Command) (CommandResult, error) {
+	return runCommand(ctx, e.exec(ctx, cmd.Dir, false, "sh", "-c", cmd.Script))
+}
+
+func (e *DockerExecutor) ReadFile(ctx context.Context, p string) ([]byte, error) {
+	c := e.exec(ctx, "", false, "cat", "--", e.path(p))
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w: %s", p, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+func (e *DockerExecutor) WriteFile(ctx context.Context, p string, data []byte) error {
+	c := e.exec(ctx, "", true, "sh", "-c", `mkdir -p "$(dirname "$1")" && cat > "$1"`, "sh", e.path(p))
+	c.Stdin = bytes.NewReader(data)
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("write %s: %w: %s", p, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// ErrOutsideRoot is returned by SandboxExecutor and Workspace tools for paths
+// outside their root.
+var ErrOutsideRoot = errors.New("tools: path is outside the sandbox root")
+
+// SandboxExecutor confines commands and files to a root directory. Commands
+// run in a bubblewrap (bwrap) sandbox on Linux: the root is the only
+// writable directory, system directories are mounted read-only, the
+// environment is cleared and networking is off unless Network is set. File
+// operations run on the host but are refused outside the root, including
+// through symlinks.
+type SandboxExecutor struct {
+	Root string
+	// ReadOnly lists host paths visible read-only in the sandbox. Defaults to
+	// /usr, /bin, /sbin, /lib, /lib64 and /etc; missing paths are skipped.
+	ReadOnly []string
+	Network  bool
+	// Env is the whole environment of commands. Defaults to a PATH and HOME
+	// set to the root.
+	Env []string
+	// Bwrap is the bubblewrap binary. Defaults to "bwrap".
+	Bwrap string
+}
+
+var _ Executor = (*SandboxExecutor)(nil)
+
+var defaultReadOnly = []string{"/usr", "/bin", "/sbin", "/lib", "/lib64", "/etc"}
+
+func (e *SandboxExecutor) Run(ctx context.Context, cmd Command) (CommandResult, error) {
+	root, err := filepath.Abs(e.Root)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	dir, err := e.confine(cmd.Dir)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	args := []string{"--die-with-parent", "--unshare-all", "--new-session"}
+	if e.Network {
+		args = append(args, "--share-net")
+	}
+	readOnly := e.ReadOnly
+	if readOnly == nil {
+		readOnly = defaultReadOnly
+	}
+	for _, p := range readOnly {
+		if _, err := os.Lstat(p); err == nil {
+			args = append(args, "--ro-bind", p, p)
+		}
+	}
+	args = append(args, "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp", "--bind", root, root, "--chdir", dir, "--clearenv")
+	env := e.Env
+	if env == nil {
+		env = []string{"PATH=/usr/local/bin:/usr/bin:/bin", "HOME=" + root}
+	}
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		args = append(args, "--setenv", k, v)
+	}
+	args = append(args, "--", "sh", "-c", cmd.Script)
+
+	bwrap := e.Bwrap
+	if bwrap == "" {
+		bwrap = "bwrap"
+	}
+	c := exec.CommandContext(ctx, bwrap, args...)
+	c.Env = []string{}
+	c.WaitDelay = waitDelay
+	return runCommand(ctx, c)
+}
+
+func (e *SandboxExecutor) ReadFile(_ context.Context, p string) ([]byte, error) {
+	p, err := e.confine(p)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (e *SandboxExecutor) WriteFile(_ context.Context, p string, data []byte) error {
+	p, err := e.confine(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+// confine resolves p against the root and checks that it, and the existing
+// part of it after following symlinks, stays inside the root.
+func (e *SandboxExecutor) confine(p string) (string, error) {
+	root, err := filepath.Abs(e.Root)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	p = filepath.Clean(p)
+	if !inside(root, p) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideRoot, p)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	// Resolve the longest existing prefix; the rest does not exist yet and
+	// cannot be a symlink.
+	existing, rest := p, ""
+	for {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !inside(realRoot, filepath.Join(real, rest)) {
+				return "", fmt.Errorf("%w: %s", ErrOutsideRoot, p)
+			}
+			return p, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+}
+
+func inside(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
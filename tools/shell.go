@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/inspirepan/step"
+)
+
+// Tool names of the shell and file tools.
+const (
+	BashName      = "bash"
+	ReadFileName  = "read_file"
+	WriteFileName = "write_file"
+)
+
+// DetailsExitCode is the ToolResult.Details key holding the exit code of a
+// bash call.
+const DetailsExitCode = "exit_code"
+
+const (
+	defaultBashTimeout = 2 * time.Minute
+	// maxOutput bounds the bytes of output and file content returned to the
+	// model; longer text keeps its head and tail.
+	maxOutput = 30000
+)
+
+// Bash returns a tool that runs shell commands with exec. Commands time out
+// after two minutes; a non-zero exit code is an error result.
+func Bash(exec Executor) step.Tool {
+	return &bashTool{exec: exec, timeout: defaultBashTimeout}
+}
+
+// ReadFile returns a parallel tool that reads text files with exec.
+func ReadFile(exec Executor) step.Tool {
+	return &readFileTool{exec: exec}
+}
+
+// WriteFile returns a tool that writes files with exec.
+func WriteFile(exec Executor) step.Tool {
+	return &writeFileTool{exec: exec}
+}
+
+type bashTool struct {
+	exec    Executor
+	timeout time.Duration
+}
+
+func (t *bashTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name:        BashName,
+		Description: "Run a bash command and return its combined stdout and stderr. Commands time out after " + t.timeout.String() + ".",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string", "description": "The command to run"},
+				"dir":     map[string]any{"type": "string", "description": "Directory to run in, relative to the working directory"},
+			},
+			"required": []string{"command"},
+		},
+		Access: step.AccessDestructive,
+	}
+}
+
+func (t *bashTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Command string `json:"command"`
+		Dir     string `json:"dir"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	runCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	res, err := t.exec.Run(runCtx, Command{Script: args.Command, Dir: args.Dir})
+	if err != nil {
+		if ctx.Err() != nil {
+			return step.ToolResult{}, ctx.Err()
+		}
+		if runCtx.Err() != nil {
+			return errorResult(fmt.Sprintf("%s\n[timed out after %s]", truncate(res.Output), t.timeout)), nil
+		}
+		return errorResult(err.Error()), nil
+	}
+	text := truncate(res.Output)
+	if res.ExitCode != 0 {
+		text += fmt.Sprintf("\n[exit code %d]", res.ExitCode)
+	}
+	return step.ToolResult{
+		IsError: res.ExitCode != 0,
+		Parts:   []step.Part{step.TextPart{Text: text}},
+		Details: map[string]any{DetailsExitCode: res.ExitCode},
+	}, nil
+}
+
+type readFileTool struct{ exec Executor }
+
+func (t *readFileTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name:        ReadFileName,
+		Description: "Read a text file.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{"type": "string", "description": "File path, absolute or relative to the working directory"},
+			},
+			"required": []string{"path"},
+		},
+		Access: step.AccessReadOnly,
+	}
+}
+
+func (t *readFileTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	data, err := t.exec.ReadFile(ctx, args.Path)
+	if err != nil {
+		return errorResult(err.Error()), nil
+	}
+	if !utf8.Valid(data) {
+		return errorResult(args.Path + " is not a text file"), nil
+	}
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: truncate(data)}}}, nil
+}
+
+type writeFileTool struct{ exec Executor }
+
+func (t *writeFileTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name:        WriteFileName,
+		Description: "Write a file, replacing it if it exists. Missing directories are created.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":    map[string]any{"type": "string", "description": "File path, absolute or relative to the working directory"},
+				"content": map[string]any{"type": "string", "description": "The complete file content"},
+			},
+			"required": []string{"path", "content"},
+		},
+		Access: step.AccessWrite,
+		// Reads issued in the same turn see the file before the change.
+		After: []string{ReadFileName, GrepName},
+	}
+}
+
+func (t *writeFileTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	if err := t.exec.WriteFile(ctx, args.Path, []byte(args.Content)); err != nil {
+		return errorResult(err.Error()), nil
+	}
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: fmt.Sprintf("Wrote %d bytes to %s.", len(args.Content), args.Path)}}}, nil
+}
+
+func errorResult(text string) step.ToolResult {
+	return step.ToolResult{IsError: true, Parts: []step.Part{step.TextPart{Text: text}}}
+}
+
+// truncate returns out as text, keeping the head and tail of long output.
+func truncate(out []byte) string {
+	if len(out) <= maxOutput {
+		return string(out)
+	}
+	head, tail := out[:maxOutput/2], out[len(out)-maxOutput/2:]
+	// The cuts may split a UTF-8 sequence.
+	return fmt.Sprintf("%s\n[... %d bytes omitted ...]\n%s", strings.ToValidUTF8(string(head), ""), len(out)-len(head)-len(tail), strings.ToValidUTF8(string(tail), ""))
+}
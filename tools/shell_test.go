@@ -0,0 +1,77 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/tools"
+)
+
+func call(t *testing.T, tool step.Tool, args map[string]any) step.ToolResult {
+	t.Helper()
+	data, _ := json.Marshal(args)
+	res, err := tool.Execute(context.Background(), step.ToolCallPart{CallID: "c1", Name: tool.Spec().Name, ArgsJSON: data})
+	if err != nil {
+		t.Fatalf("%s failed: %v", tool.Spec().Name, err)
+	}
+	return res
+}
+
+func text(res step.ToolResult) string { return res.Parts[0].(step.TextPart).Text }
+
+func TestShellTools_Local(t *testing.T) {
+	exec := &tools.LocalExecutor{Dir: t.TempDir()}
+	if res := call(t, tools.WriteFile(exec), map[string]any{"path": "src/hello.txt", "content": "hello"}); res.IsError {
+		t.Fatalf("write_file failed: %s", text(res))
+	}
+	if res := call(t, tools.ReadFile(exec), map[string]any{"path": "src/hello.txt"}); text(res) != "hello" {
+		t.Errorf("read_file returned %q", text(res))
+	}
+	res := call(t, tools.Bash(exec), map[string]any{"command": "cat hello.txt; echo; echo oops >&2; exit 3", "dir": "src"})
+	if !res.IsError || res.Details[tools.DetailsExitCode] != 3 || text(res) != "hello\noops\n\n[exit code 3]" {
+		t.Errorf("unexpected bash result %q (details %v)", text(res), res.Details)
+	}
+}
+
+func TestSandboxExecutor_Confinement(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("s3cret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	sandbox := &tools.SandboxExecutor{Root: root}
+	ctx := context.Background()
+
+	if err := sandbox.WriteFile(ctx, "a/b.txt", []byte("ok")); err != nil {
+		t.Fatalf("write inside the root failed: %v", err)
+	}
+	for _, p := range []string{"../escape.txt", filepath.Join(outside, "secret"), "link/secret", "link/new.txt"} {
+		if _, err := sandbox.ReadFile(ctx, p); !errors.Is(err, tools.ErrOutsideRoot) {
+			t.Errorf("read %s: expected ErrOutsideRoot, got %v", p, err)
+		}
+		if err := sandbox.WriteFile(ctx, p, nil); !errors.Is(err, tools.ErrOutsideRoot) {
+			t.Errorf("write %s: expected ErrOutsideRoot, got %v", p, err)
+		}
+	}
+
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		t.Skip("bwrap not installed")
+	}
+	res, err := sandbox.Run(ctx, tools.Command{Script: "cat a/b.txt; touch " + filepath.Join(outside, "x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(res.Output), "ok") || res.ExitCode == 0 {
+		t.Errorf("expected the sandbox to read the root and refuse writes outside, got %d: %s", res.ExitCode, res.Output)
+	}
+}
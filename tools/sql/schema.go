@@ -0,0 +1,117 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/inspirepan/step"
+)
+
+var listTablesQueries = map[Dialect]string{
+	SQLite: `SELECT name, type FROM sqlite_master
+WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name`,
+	Postgres: `SELECT table_schema, table_name, table_type FROM information_schema.tables
+WHERE table_schema NOT IN ('pg_catalog', 'information_schema') ORDER BY table_schema, table_name`,
+	MySQL: `SELECT table_name, table_type FROM information_schema.tables
+WHERE table_schema = DATABASE() ORDER BY table_name`,
+}
+
+// describeTableQueries take the table and schema names; an empty schema is
+// the default one.
+var describeTableQueries = map[Dialect]string{
+	SQLite: `SELECT name, type, "notnull" AS not_null, dflt_value AS default_value, pk AS primary_key
+FROM pragma_table_info(?, COALESCE(NULLIF(?, ''), 'main'))`,
+	Postgres: `SELECT column_name, data_type, is_nullable, column_default FROM information_schema.columns
+WHERE table_name = $1 AND table_schema = COALESCE(NULLIF($2, ''), current_schema()) ORDER BY ordinal_position`,
+	MySQL: `SELECT column_name, column_type, is_nullable, column_default, column_key FROM information_schema.columns
+WHERE table_name = ? AND table_schema = COALESCE(NULLIF(?, ''), DATABASE()) ORDER BY ordinal_position`,
+}
+
+type listTablesTool struct {
+	db   *dbsql.DB
+	opts Options
+}
+
+func (t *listTablesTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name:        ListTablesName,
+		Description: "List the tables and views in the database.",
+		Parameters:  map[string]any{"type": "object", "properties": map[string]any{}},
+		Access:      step.AccessReadOnly,
+	}
+}
+
+func (t *listTablesTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	query, ok := listTablesQueries[t.opts.Dialect]
+	if !ok {
+		return errorResult("list_tables does not support the " + string(t.opts.Dialect) + " dialect"), nil
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, t.opts.Timeout)
+	defer cancel()
+	rows, err := t.db.QueryContext(queryCtx, query)
+	if err != nil {
+		return queryError(ctx, queryCtx, t.opts.Timeout, err)
+	}
+	// Schemas can have many tables; list more of them than query results.
+	res, err := formatRows(rows, 10*t.opts.MaxRows)
+	if err != nil {
+		return queryError(ctx, queryCtx, t.opts.Timeout, err)
+	}
+	return res, nil
+}
+
+type describeTableTool struct {
+	db   *dbsql.DB
+	opts Options
+}
+
+func (t *describeTableTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name:        DescribeTableName,
+		Description: "List the columns of a table or view with their types, nullability and defaults.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"table": map[string]any{"type": "string", "description": "The table name, optionally qualified as schema.table"},
+			},
+			"required": []string{"table"},
+		},
+		Access: step.AccessReadOnly,
+	}
+}
+
+func (t *describeTableTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Table string `json:"table"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	query, ok := describeTableQueries[t.opts.Dialect]
+	if !ok {
+		return errorResult("describe_table does not support the " + string(t.opts.Dialect) + " dialect"), nil
+	}
+	schema, table, ok := strings.Cut(args.Table, ".")
+	if !ok {
+		schema, table = "", args.Table
+	}
+	if table == "" {
+		return errorResult("describe_table requires a table"), nil
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, t.opts.Timeout)
+	defer cancel()
+	rows, err := t.db.QueryContext(queryCtx, query, table, schema)
+	if err != nil {
+		return queryError(ctx, queryCtx, t.opts.Timeout, err)
+	}
+	res, err := formatRows(rows, 10*t.opts.MaxRows)
+	if err != nil {
+		return queryError(ctx, queryCtx, t.opts.Timeout, err)
+	}
+	if rows, _ := res.Details[DetailsRows].([][]string); len(rows) == 0 {
+		return errorResult("table " + args.Table + " not found; use list_tables to see the tables"), nil
+	}
+	return res, nil
+}
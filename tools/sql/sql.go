@@ -0,0 +1,417 @@
+// Package sql provides tools for querying a database through database/sql:
+// run_query, and list_tables and describe_table for schema introspection.
+// Results are formatted as markdown tables, cut at a row limit.
+//
+// Queries are read-only by default. A query must be a single statement
+// starting with SELECT, WITH, EXPLAIN, SHOW, DESCRIBE, VALUES or TABLE and
+// without data-modifying keywords, and it runs in a read-only transaction
+// that is always rolled back. The checks are a safety net, not a security
+// boundary: connect with a database user that only has the privileges the
+// agent needs.
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/inspirepan/step"
+)
+
+// Tool names.
+const (
+	RunQueryName      = "run_query"
+	ListTablesName    = "list_tables"
+	DescribeTableName = "describe_table"
+)
+
+// Details keys of tool results. Rows are [][]string as shown in the table.
+const (
+	DetailsColumns      = "columns"
+	DetailsRows         = "rows"
+	DetailsTruncated    = "truncated"
+	DetailsRowsAffected = "rows_affected"
+)
+
+// Dialect selects the schema queries of list_tables and describe_table.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+)
+
+// Options configures the tools.
+type Options struct {
+	// AllowWrites lets run_query execute any statement outside a transaction.
+	AllowWrites bool
+	// MaxRows bounds the rows returned per query. Defaults to 100.
+	MaxRows int
+	// Timeout bounds each query. Defaults to 30s.
+	Timeout time.Duration
+	// Dialect defaults to one detected from the driver's type name.
+	Dialect Dialect
+}
+
+const maxCell = 500
+
+// Tools returns run_query and, if the dialect is known, list_tables and
+// describe_table, all querying db.
+func Tools(db *dbsql.DB, opts Options) []step.Tool {
+	opts = withDefaults(db, opts)
+	ts := []step.Tool{RunQuery(db, opts)}
+	if opts.Dialect != "" {
+		ts = append(ts, ListTables(db, opts), DescribeTable(db, opts))
+	}
+	return ts
+}
+
+// RunQuery returns a tool that runs a SQL query and returns its rows as a
+// markdown table.
+func RunQuery(db *dbsql.DB, opts Options) step.Tool {
+	return &queryTool{db: db, opts: withDefaults(db, opts)}
+}
+
+// ListTables returns a parallel tool that lists the tables and views.
+func ListTables(db *dbsql.DB, opts Options) step.Tool {
+	return &listTablesTool{db: db, opts: withDefaults(db, opts)}
+}
+
+// DescribeTable returns a parallel tool that lists the columns of a table.
+func DescribeTable(db *dbsql.DB, opts Options) step.Tool {
+	return &describeTableTool{db: db, opts: withDefaults(db, opts)}
+}
+
+func withDefaults(db *dbsql.DB, opts Options) Options {
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = 100
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.Dialect == "" {
+		opts.Dialect = detectDialect(fmt.Sprintf("%T", db.Driver()))
+	}
+	return opts
+}
+
+func detectDialect(driverType string) Dialect {
+	t := strings.ToLower(driverType)
+	switch {
+	case strings.Contains(t, "sqlite"):
+		return SQLite
+	case strings.Contains(t, "pq.") || strings.Contains(t, "pgx") || strings.Contains(t, "postgres"):
+		return Postgres
+	case strings.Contains(t, "mysql"):
+		return MySQL
+	}
+	return ""
+}
+
+type queryTool struct {
+	db   *dbsql.DB
+	opts Options
+}
+
+func (t *queryTool) Spec() step.ToolSpec {
+	desc := fmt.Sprintf("Run a SQL query and return up to %d rows as a markdown table.", t.opts.MaxRows)
+	if t.opts.Dialect != "" {
+		desc += " The database is " + string(t.opts.Dialect) + "."
+	}
+	if !t.opts.AllowWrites {
+		desc += " Only read-only queries are allowed."
+	}
+	return step.ToolSpec{
+		Name:        RunQueryName,
+		Description: desc,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{"type": "string", "description": "A single SQL statement"},
+			},
+			"required": []string{"query"},
+		},
+		Access: t.access(),
+	}
+}
+
+// access is read-only unless writes are allowed, which include statements
+// such as DROP TABLE.
+func (t *queryTool) access() step.Access {
+	if t.opts.AllowWrites {
+		return step.AccessDestructive
+	}
+	return step.AccessReadOnly
+}
+
+func (t *queryTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return errorResult("invalid arguments: " + err.Error()), nil
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return errorResult("run_query requires a query"), nil
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, t.opts.Timeout)
+	defer cancel()
+
+	if t.opts.AllowWrites {
+		if !returnsRows(args.Query) {
+			res, err := t.db.ExecContext(queryCtx, args.Query)
+			if err != nil {
+				return queryError(ctx, queryCtx, t.opts.Timeout, err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return textResult("Statement executed.", nil), nil
+			}
+			return textResult(fmt.Sprintf("Statement executed; %d rows affected.", n), map[string]any{DetailsRowsAffected: n}), nil
+		}
+		rows, err := t.db.QueryContext(queryCtx, args.Query)
+		if err != nil {
+			return queryError(ctx, queryCtx, t.opts.Timeout, err)
+		}
+		return t.table(ctx, queryCtx, rows)
+	}
+
+	if err := checkReadOnly(args.Query); err != nil {
+		return errorResult(err.Error()), nil
+	}
+	tx, err := t.db.BeginTx(queryCtx, &dbsql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return queryError(ctx, queryCtx, t.opts.Timeout, err)
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(queryCtx, args.Query)
+	if err != nil {
+		return queryError(ctx, queryCtx, t.opts.Timeout, err)
+	}
+	return t.table(ctx, queryCtx, rows)
+}
+
+func (t *queryTool) table(ctx, queryCtx context.Context, rows *dbsql.Rows) (step.ToolResult, error) {
+	res, err := formatRows(rows, t.opts.MaxRows)
+	if err != nil {
+		return queryError(ctx, queryCtx, t.opts.Timeout, err)
+	}
+	return res, nil
+}
+
+// queryError turns a query error into an error result, or returns ctx's error
+// if the call was cancelled.
+func queryError(ctx, queryCtx context.Context, timeout time.Duration, err error) (step.ToolResult, error) {
+	if ctx.Err() != nil {
+		return step.ToolResult{}, ctx.Err()
+	}
+	if queryCtx.Err() != nil {
+		return errorResult(fmt.Sprintf("query timed out after %s", timeout)), nil
+	}
+	return errorResult(err.Error()), nil
+}
+
+// formatRows reads up to maxRows rows and formats them as a markdown table.
+func formatRows(rows *dbsql.Rows, maxRows int) (step.ToolResult, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return step.ToolResult{}, err
+	}
+	var (
+		table     [][]string
+		truncated bool
+	)
+	for rows.Next() {
+		if len(table) == maxRows {
+			truncated = true
+			break
+		}
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return step.ToolResult{}, err
+		}
+		row := make([]string, len(columns))
+		for i, v := range values {
+			row[i] = formatValue(v)
+		}
+		table = append(table, row)
+	}
+	if err := rows.Err(); err != nil {
+		return step.ToolResult{}, err
+	}
+
+	var sb strings.Builder
+	writeRow(&sb, columns)
+	sb.WriteString("|")
+	for range columns {
+		sb.WriteString(" --- |")
+	}
+	sb.WriteString("\n")
+	for _, row := range table {
+		writeRow(&sb, row)
+	}
+	switch {
+	case truncated:
+		fmt.Fprintf(&sb, "\n(showing the first %d rows; add a LIMIT or narrow the query)", maxRows)
+	case len(table) == 0:
+		sb.WriteString("\n(no rows)")
+	default:
+		fmt.Fprintf(&sb, "\n(%d rows)", len(table))
+	}
+	if table == nil {
+		table = [][]string{}
+	}
+	return textResult(sb.String(), map[string]any{
+		DetailsColumns:   columns,
+		DetailsRows:      table,
+		DetailsTruncated: truncated,
+	}), nil
+}
+
+func writeRow(sb *strings.Builder, cells []string) {
+	sb.WriteString("|")
+	for _, c := range cells {
+		c = strings.ReplaceAll(c, "|", `\|`)
+		c = strings.ReplaceAll(strings.ReplaceAll(c, "\r\n", " "), "\n", " ")
+		sb.WriteString(" " + c + " |")
+	}
+	sb.WriteString("\n")
+}
+
+func formatValue(v any) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if !utf8.Valid(v) {
+			return fmt.Sprintf("(%d bytes)", len(v))
+		}
+		s = string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if len(s) > maxCell {
+		s = strings.ToValidUTF8(s[:maxCell], "") + "…"
+	}
+	return s
+}
+
+// readOnlyStarts are the statements allowed in read-only mode.
+var readOnlyStarts = map[string]bool{
+	"SELECT": true, "WITH": true, "EXPLAIN": true, "SHOW": true,
+	"DESCRIBE": true, "DESC": true, "VALUES": true, "TABLE": true,
+}
+
+// writeKeywords are refused anywhere in a read-only query, e.g. in a
+// data-modifying CTE or SELECT INTO.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true,
+	"GRANT": true, "REVOKE": true, "ATTACH": true, "DETACH": true, "COPY": true,
+	"CALL": true, "EXEC": true, "EXECUTE": true, "INTO": true, "LOCK": true,
+	"VACUUM": true, "REINDEX": true, "SET": true,
+}
+
+// checkReadOnly refuses queries that are not a single read-only statement.
+func checkReadOnly(query string) error {
+	words, statements := scan(query)
+	if statements > 1 {
+		return errors.New("only a single statement is allowed")
+	}
+	if len(words) == 0 || !readOnlyStarts[words[0]] {
+		return errors.New("only read-only queries are allowed: start with SELECT, WITH, EXPLAIN, SHOW, DESCRIBE, VALUES or TABLE")
+	}
+	for _, w := range words {
+		if writeKeywords[w] {
+			return fmt.Errorf("only read-only queries are allowed: %s is not permitted", w)
+		}
+	}
+	return nil
+}
+
+// returnsRows reports whether query is run with Query rather than Exec.
+func returnsRows(query string) bool {
+	words, _ := scan(query)
+	return len(words) > 0 && (readOnlyStarts[words[0]] || words[0] == "PRAGMA")
+}
+
+// scan returns the upper-cased keywords and identifiers of query outside
+// comments, string literals and quoted identifiers, and the number of
+// non-empty statements.
+func scan(query string) (words []string, statements int) {
+	inStatement := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "--"):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return words, statements
+			}
+			i += end + 4
+			continue
+		case c == ';':
+			inStatement = false
+			i++
+			continue
+		case unicode.IsSpace(rune(c)):
+			i++
+			continue
+		}
+		if !inStatement {
+			inStatement = true
+			statements++
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// Doubled quotes escape themselves, so skipping to each closing
+			// quote in turn stays in sync.
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return words, statements
+			}
+			i += end + 2
+		case isWordByte(c):
+			start := i
+			for i < len(query) && isWordByte(query[i]) {
+				i++
+			}
+			words = append(words, strings.ToUpper(query[start:i]))
+		default:
+			i++
+		}
+	}
+	return words, statements
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= utf8.RuneSelf
+}
+
+func textResult(text string, details map[string]any) step.ToolResult {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: text}}, Details: details}
+}
+
+func errorResult(text string) step.ToolResult {
+	return step.ToolResult{IsError: true, Parts: []step.Part{step.TextPart{Text: text}}}
+}
@@ -0,0 +1,171 @@
+package sql_test
+
+import (
+	"context"
+	dbsql "database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/tools/sql"
+)
+
+// fakeSQLiteDriver answers canned queries; its type name makes the tools
+// detect the sqlite dialect.
+type fakeSQLiteDriver struct {
+	mu         sync.Mutex
+	readOnlyTx bool
+	rolledBack bool
+	execs      []string
+}
+
+func (d *fakeSQLiteDriver) Open(string) (driver.Conn, error) { return &fakeConn{d: d}, nil }
+
+type fakeConn struct{ d *fakeSQLiteDriver }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.readOnlyTx, c.d.rolledBack = opts.ReadOnly, false
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error { return nil }
+
+func (c *fakeConn) Rollback() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.rolledBack = true
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.execs = append(c.d.execs, query)
+	return driver.RowsAffected(2), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "sqlite_master"):
+		return &fakeRows{cols: []string{"name", "type"}, rows: [][]driver.Value{{"users", "table"}}}, nil
+	case strings.Contains(query, "pragma_table_info"):
+		if args[0].Value != "users" {
+			return &fakeRows{cols: []string{"name"}}, nil
+		}
+		return &fakeRows{cols: []string{"name", "type"}, rows: [][]driver.Value{{"id", "INTEGER"}, {"name", "TEXT"}}}, nil
+	case strings.Contains(query, "SELECT"):
+		return &fakeRows{cols: []string{"id", "name"}, rows: [][]driver.Value{
+			{int64(1), "Ada"}, {int64(2), nil}, {int64(3), []byte("a|b\nc")},
+		}}, nil
+	}
+	return nil, errors.New("syntax error")
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var fake = &fakeSQLiteDriver{}
+
+func init() { dbsql.Register("fakesqlite", fake) }
+
+func call(t *testing.T, tool step.Tool, args map[string]any) step.ToolResult {
+	t.Helper()
+	data, _ := json.Marshal(args)
+	res, err := tool.Execute(context.Background(), step.ToolCallPart{CallID: "c1", Name: tool.Spec().Name, ArgsJSON: data})
+	if err != nil {
+		t.Fatalf("%s failed: %v", tool.Spec().Name, err)
+	}
+	return res
+}
+
+func text(res step.ToolResult) string { return res.Parts[0].(step.TextPart).Text }
+
+func TestTools_ReadOnly(t *testing.T) {
+	db, err := dbsql.Open("fakesqlite", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ts := sql.Tools(db, sql.Options{MaxRows: 2})
+	if len(ts) != 3 {
+		t.Fatalf("expected the schema tools for a detected dialect, got %d tools", len(ts))
+	}
+	query, listTables, describe := ts[0], ts[1], ts[2]
+
+	res := call(t, query, map[string]any{"query": "SELECT id, name FROM users"})
+	want := "| id | name |\n| --- | --- |\n| 1 | Ada |\n| 2 | NULL |\n\n(showing the first 2 rows; add a LIMIT or narrow the query)"
+	if res.IsError || text(res) != want || res.Details[sql.DetailsTruncated] != true {
+		t.Errorf("unexpected query result %q", text(res))
+	}
+	if !fake.readOnlyTx || !fake.rolledBack {
+		t.Error("expected the query to run in a read-only transaction that is rolled back")
+	}
+	for _, q := range []string{
+		"DELETE FROM users",
+		"SELECT 1; DROP TABLE users",
+		"WITH d AS (DELETE FROM users RETURNING *) SELECT * FROM d",
+		"SELECT * INTO backup FROM users",
+	} {
+		if res := call(t, query, map[string]any{"query": q}); !res.IsError {
+			t.Errorf("%q: expected an error result", q)
+		}
+	}
+	for _, q := range []string{"SELECT 'drop; delete' AS s;", "-- delete\nSELECT \"update\" FROM users"} {
+		if res := call(t, query, map[string]any{"query": q}); res.IsError {
+			t.Errorf("%q: unexpected error %q", q, text(res))
+		}
+	}
+
+	if res := call(t, listTables, nil); !strings.Contains(text(res), "| users | table |") {
+		t.Errorf("unexpected list_tables result %q", text(res))
+	}
+	if res := call(t, describe, map[string]any{"table": "users"}); !strings.Contains(text(res), "| name | TEXT |") {
+		t.Errorf("unexpected describe_table result %q", text(res))
+	}
+	if res := call(t, describe, map[string]any{"table": "main.missing"}); !res.IsError {
+		t.Errorf("expected an error for a missing table, got %q", text(res))
+	}
+}
+
+func TestTools_AllowWrites(t *testing.T) {
+	db, err := dbsql.Open("fakesqlite", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	query := sql.RunQuery(db, sql.Options{AllowWrites: true})
+	res := call(t, query, map[string]any{"query": "UPDATE users SET name = 'x'"})
+	if res.IsError || res.Details[sql.DetailsRowsAffected] != int64(2) {
+		t.Errorf("unexpected exec result %q", text(res))
+	}
+	if res := call(t, query, map[string]any{"query": "SELECT id FROM users"}); !strings.Contains(text(res), `| 3 | a\|b c |`) {
+		t.Errorf("unexpected query result %q", text(res))
+	}
+}
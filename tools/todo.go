@@ -0,0 +1,207 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/inspirepan/step"
+)
+
+// TodoWriteName is the tool name of TodoList.Tool.
+const TodoWriteName = "todo_write"
+
+// DetailsTodos is the ToolResult.Details key holding the plan after a
+// todo_write call, as []TodoItem. Hosts read it with TodosFromDetails.
+const DetailsTodos = "todos"
+
+// TodoStatus is the state of a plan item.
+type TodoStatus string
+
+const (
+	TodoPending    TodoStatus = "pending"
+	TodoInProgress TodoStatus = "in_progress"
+	TodoCompleted  TodoStatus = "completed"
+)
+
+// TodoItem is one task in the plan.
+type TodoItem struct {
+	Content string     `json:"content"`
+	Status  TodoStatus `json:"status"`
+	// ActiveForm is the present-continuous description shown while in progress, e.g. "Running tests".
+	ActiveForm string `json:"active_form,omitempty"`
+}
+
+// TodoList holds an agent's task plan. The model replaces the whole list with
+// each todo_write call; every update is reported in the tool result Details
+// under DetailsTodos and to the OnChange callback. It is safe for concurrent use
+// and round-trips through JSON, so a plan can be saved with a session.
+type TodoList struct {
+	mu    sync.Mutex
+	items []TodoItem
+
+	// OnChange, if set, is called with a copy of the items after every successful update.
+	OnChange func([]TodoItem)
+}
+
+// Items returns a copy of the current plan.
+func (l *TodoList) Items() []TodoItem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]TodoItem(nil), l.items...)
+}
+
+// Set replaces the plan after validating it.
+func (l *TodoList) Set(items []TodoItem) error {
+	if err := validateTodos(items); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.items = append([]TodoItem(nil), items...)
+	onChange := l.OnChange
+	l.mu.Unlock()
+	if onChange != nil {
+		onChange(append([]TodoItem(nil), items...))
+	}
+	return nil
+}
+
+func (l *TodoList) MarshalJSON() ([]byte, error) {
+	items := l.Items()
+	if items == nil {
+		items = []TodoItem{}
+	}
+	return json.Marshal(items)
+}
+
+func (l *TodoList) UnmarshalJSON(data []byte) error {
+	var items []TodoItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = items
+	return nil
+}
+
+// String renders the plan as a checklist, the form the model sees.
+func (l *TodoList) String() string {
+	return formatTodos(l.Items())
+}
+
+// Tool returns the todo_write tool operating on l.
+func (l *TodoList) Tool() step.Tool {
+	return &todoTool{list: l}
+}
+
+// TodosFromDetails extracts the plan from a todo_write result's Details. It
+// accepts both the original []TodoItem and the form decoded from JSON.
+func TodosFromDetails(details map[string]any) ([]TodoItem, bool) {
+	v, ok := details[DetailsTodos]
+	if !ok {
+		return nil, false
+	}
+	if items, ok := v.([]TodoItem); ok {
+		return items, true
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var items []TodoItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, false
+	}
+	return items, true
+}
+
+func validateTodos(items []TodoItem) error {
+	inProgress := 0
+	for i, item := range items {
+		if strings.TrimSpace(item.Content) == "" {
+			return fmt.Errorf("todo %d has empty content", i+1)
+		}
+		switch item.Status {
+		case TodoPending, TodoCompleted:
+		case TodoInProgress:
+			inProgress++
+		default:
+			return fmt.Errorf("todo %d has invalid status %q (want pending, in_progress or completed)", i+1, item.Status)
+		}
+	}
+	if inProgress > 1 {
+		return fmt.Errorf("%d todos are in_progress; keep exactly one task in progress at a time", inProgress)
+	}
+	return nil
+}
+
+func formatTodos(items []TodoItem) string {
+	if len(items) == 0 {
+		return "(no todos)"
+	}
+	var sb strings.Builder
+	for i, item := range items {
+		mark := "[ ]"
+		switch item.Status {
+		case TodoInProgress:
+			mark = "[>]"
+		case TodoCompleted:
+			mark = "[x]"
+		}
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(mark + " " + item.Content)
+	}
+	return sb.String()
+}
+
+type todoTool struct {
+	list *TodoList
+}
+
+func (t *todoTool) Spec() step.ToolSpec {
+	return step.ToolSpec{
+		Name: TodoWriteName,
+		Description: "Create or update the task plan for the current work. Send the complete list each time. " +
+			"Use it for work with several steps: mark a task in_progress before starting it, keep exactly one task " +
+			"in progress, and mark it completed as soon as it is done.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"todos": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"content":     map[string]any{"type": "string", "description": "What needs to be done, in imperative form"},
+							"status":      map[string]any{"type": "string", "enum": []string{string(TodoPending), string(TodoInProgress), string(TodoCompleted)}},
+							"active_form": map[string]any{"type": "string", "description": "Present-continuous form shown while in progress"},
+						},
+						"required": []string{"content", "status"},
+					},
+				},
+			},
+			"required": []string{"todos"},
+		},
+	}
+}
+
+func (t *todoTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	var args struct {
+		Todos []TodoItem `json:"todos"`
+	}
+	if err := json.Unmarshal(call.ArgsJSON, &args); err != nil {
+		return step.ToolResult{IsError: true, Parts: []step.Part{step.TextPart{Text: "invalid arguments: " + err.Error()}}}, nil
+	}
+	if err := t.list.Set(args.Todos); err != nil {
+		return step.ToolResult{IsError: true, Parts: []step.Part{step.TextPart{Text: err.Error()}}}, nil
+	}
+	return step.ToolResult{
+		Parts:   []step.Part{step.TextPart{Text: "Todos updated:\n" + formatTodos(args.Todos)}},
+		Details: map[string]any{DetailsTodos: append([]TodoItem(nil), args.Todos...)},
+	}, nil
+}
@@ -0,0 +1,78 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/tools"
+)
+
+func todoCall(todos ...tools.TodoItem) mock.Response {
+	args, _ := json.Marshal(map[string]any{"todos": todos})
+	return mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: tools.TodoWriteName, ArgsJSON: args})
+}
+
+func TestTodoList(t *testing.T) {
+	var list tools.TodoList
+	var changes int
+	list.OnChange = func([]tools.TodoItem) { changes++ }
+	plan := []tools.TodoItem{
+		{Content: "Write tests", Status: tools.TodoCompleted},
+		{Content: "Fix bug", Status: tools.TodoInProgress, ActiveForm: "Fixing bug"},
+		{Content: "Open PR", Status: tools.TodoPending},
+	}
+
+	var seen step.ToolResultMessage
+	_, err := step.Step(context.Background(), step.StepRequest{Provider: mock.New(todoCall(plan...)), Tools: []step.Tool{list.Tool()}},
+		step.WithOnMessage(func(m step.Message) {
+			if r, ok := m.(step.ToolResultMessage); ok {
+				seen = r
+			}
+		}))
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if seen.IsError || changes != 1 || len(list.Items()) != 3 {
+		t.Fatalf("expected plan update, got %+v (changes %d)", seen, changes)
+	}
+	if want := "[x] Write tests\n[>] Fix bug\n[ ] Open PR"; list.String() != want {
+		t.Errorf("unexpected checklist %q", list.String())
+	}
+
+	// Details survive a JSON round trip, as when a session is saved and reloaded.
+	data, _ := json.Marshal(seen)
+	msg, err := step.UnmarshalMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, ok := tools.TodosFromDetails(msg.(step.ToolResultMessage).Details)
+	if !ok || len(items) != 3 || items[1].ActiveForm != "Fixing bug" {
+		t.Errorf("unexpected todos from details: %+v", items)
+	}
+
+	saved, _ := json.Marshal(&list)
+	var restored tools.TodoList
+	if err := json.Unmarshal(saved, &restored); err != nil || restored.String() != list.String() {
+		t.Errorf("expected restored plan, got %q (%v)", restored.String(), err)
+	}
+}
+
+func TestTodoList_Invalid(t *testing.T) {
+	var list tools.TodoList
+	result, err := step.Step(context.Background(), step.StepRequest{
+		Provider: mock.New(todoCall(
+			tools.TodoItem{Content: "A", Status: tools.TodoInProgress},
+			tools.TodoItem{Content: "B", Status: tools.TodoInProgress},
+		)),
+		Tools: []step.Tool{list.Tool()},
+	})
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if res := result[1].(step.ToolResultMessage); !res.IsError || len(list.Items()) != 0 {
+		t.Errorf("expected a rejected update, got %+v", res)
+	}
+}
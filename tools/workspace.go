@@ -0,0 +1,308 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/internal/diff"
+)
+
+// Workspace is the directory an agent works in, shared by its shell and file
+// tools. It snapshots the directory when created, so the host can review
+// everything the agent changed, by any tool, as a change set and roll it back.
+// Workspace is an Executor; by default commands and files run on the host in
+// the root directory.
+type Workspace struct {
+	root        string
+	ignore      []string
+	exec        Executor
+	maxFileSize int64
+
+	mu       sync.Mutex
+	baseline map[string]fileSnapshot
+}
+
+var _ Executor = (*Workspace)(nil)
+
+// WorkspaceOption configures a Workspace.
+type WorkspaceOption func(*Workspace)
+
+// WithIgnore sets the patterns of paths left out of change tracking, such as
+// build output. A pattern matches a path relative to the root, or any of its
+// elements, with path.Match syntax. Defaults to ".git".
+func WithIgnore(patterns ...string) WorkspaceOption {
+	return func(w *Workspace) { w.ignore = patterns }
+}
+
+// WithExecutor runs the workspace's commands and file operations with exec,
+// e.g. a DockerExecutor with the root mounted or a SandboxExecutor on the
+// root. Change tracking always reads the root on the host.
+func WithExecutor(exec Executor) WorkspaceOption {
+	return func(w *Workspace) { w.exec = exec }
+}
+
+// WithMaxFileSize sets the size above which files are tracked by size and
+// modification time only; their changes are listed without content and cannot
+// be rolled back. Defaults to 1 MiB.
+func WithMaxFileSize(n int64) WorkspaceOption {
+	return func(w *Workspace) { w.maxFileSize = n }
+}
+
+type fileSnapshot struct {
+	data    []byte // nil if the file is larger than maxFileSize
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// NewWorkspace creates a Workspace on root and snapshots it.
+func NewWorkspace(root string, opts ...WorkspaceOption) (*Workspace, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	w := &Workspace{root: abs, ignore: []string{".git"}, maxFileSize: 1 << 20}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.exec == nil {
+		w.exec = &LocalExecutor{Dir: abs}
+	}
+	if w.baseline, err = w.snapshot(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Root returns the absolute root directory.
+func (w *Workspace) Root() string { return w.root }
+
+// Tools returns the bash, read_file, write_file, edit_file and grep tools
+// working in w.
+func (w *Workspace) Tools() []step.Tool {
+	return []step.Tool{Bash(w), ReadFile(w), WriteFile(w), EditFile(w), Grep(w)}
+}
+
+func (w *Workspace) Run(ctx context.Context, cmd Command) (CommandResult, error) {
+	return w.exec.Run(ctx, cmd)
+}
+
+func (w *Workspace) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	return w.exec.ReadFile(ctx, path)
+}
+
+func (w *Workspace) WriteFile(ctx context.Context, path string, data []byte) error {
+	return w.exec.WriteFile(ctx, path, data)
+}
+
+// ChangeKind is the kind of a FileChange.
+type ChangeKind string
+
+const (
+	Created  ChangeKind = "created"
+	Modified ChangeKind = "modified"
+	Deleted  ChangeKind = "deleted"
+)
+
+// FileChange is a file that differs from the snapshot.
+type FileChange struct {
+	// Path is relative to the root, with forward slashes.
+	Path string
+	Kind ChangeKind
+	// Before and After are the contents, nil if the file did not exist or is
+	// larger than the maximum tracked size.
+	Before, After []byte
+	// Diff is a unified diff for text files, or a note for binary and large
+	// files.
+	Diff string
+}
+
+// Changes returns the files created, modified or deleted since the snapshot,
+// sorted by path.
+func (w *Workspace) Changes() ([]FileChange, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	current, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	var changes []FileChange
+	for p, before := range w.baseline {
+		after, ok := current[p]
+		switch {
+		case !ok:
+			changes = append(changes, w.change(p, Deleted, &before, nil))
+		case !sameFile(before, after):
+			changes = append(changes, w.change(p, Modified, &before, &after))
+		}
+	}
+	for p, after := range current {
+		if _, ok := w.baseline[p]; !ok {
+			changes = append(changes, w.change(p, Created, nil, &after))
+		}
+	}
+	slices.SortFunc(changes, func(a, b FileChange) int { return strings.Compare(a.Path, b.Path) })
+	return changes, nil
+}
+
+// Diff returns the unified diff of every change.
+func (w *Workspace) Diff() (string, error) {
+	changes, err := w.Changes()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, c := range changes {
+		sb.WriteString(c.Diff)
+	}
+	return sb.String(), nil
+}
+
+// Rollback restores the snapshot: created files are removed, and modified
+// and deleted files get their original content and mode back. Changes to
+// files too large to track are reported in the error.
+func (w *Workspace) Rollback() error {
+	changes, err := w.Changes()
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var errs []error
+	for _, c := range changes {
+		full := filepath.Join(w.root, filepath.FromSlash(c.Path))
+		if c.Kind == Created {
+			errs = append(errs, os.Remove(full))
+			continue
+		}
+		before := w.baseline[c.Path]
+		if before.data == nil && before.size > 0 {
+			errs = append(errs, fmt.Errorf("%s: too large to restore", c.Path))
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.WriteFile(full, before.data, before.mode); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, os.Chmod(full, before.mode))
+	}
+	return errors.Join(errs...)
+}
+
+// Accept takes a new snapshot, so the current state becomes the baseline of
+// Changes and Rollback.
+func (w *Workspace) Accept() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	baseline, err := w.snapshot()
+	if err != nil {
+		return err
+	}
+	w.baseline = baseline
+	return nil
+}
+
+func (w *Workspace) change(p string, kind ChangeKind, before, after *fileSnapshot) FileChange {
+	c := FileChange{Path: p, Kind: kind}
+	oldName, newName := "a/"+p, "b/"+p
+	var a, b []byte
+	large := false
+	if before != nil {
+		c.Before, a = before.data, before.data
+		large = large || (before.data == nil && before.size > 0)
+	} else {
+		oldName = "/dev/null"
+	}
+	if after != nil {
+		c.After, b = after.data, after.data
+		large = large || (after.data == nil && after.size > 0)
+	} else {
+		newName = "/dev/null"
+	}
+	switch {
+	case large:
+		c.Diff = fmt.Sprintf("Large file %s %s\n", p, kind)
+	case !utf8.Valid(a) || !utf8.Valid(b):
+		c.Diff = fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	default:
+		c.Diff = diff.Unified(oldName, newName, string(a), string(b))
+	}
+	return c
+}
+
+func sameFile(a, b fileSnapshot) bool {
+	if a.data == nil || b.data == nil {
+		return a.size == b.size && a.modTime.Equal(b.modTime) && a.mode == b.mode
+	}
+	return bytes.Equal(a.data, b.data) && a.mode == b.mode
+}
+
+// snapshot reads every regular file under the root that is not ignored.
+func (w *Workspace) snapshot() (map[string]fileSnapshot, error) {
+	files := make(map[string]fileSnapshot)
+	err := filepath.WalkDir(w.root, func(full string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(w.root, full)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if w.ignored(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		snap := fileSnapshot{size: info.Size(), modTime: info.ModTime(), mode: info.Mode().Perm()}
+		if info.Size() <= w.maxFileSize {
+			if snap.data, err = os.ReadFile(full); err != nil {
+				return err
+			}
+			if snap.data == nil {
+				snap.data = []byte{}
+			}
+		}
+		files[rel] = snap
+		return nil
+	})
+	return files, err
+}
+
+func (w *Workspace) ignored(rel string) bool {
+	for _, pattern := range w.ignore {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		for _, elem := range strings.Split(rel, "/") {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package tools_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inspirepan/step/tools"
+)
+
+func TestWorkspace_ChangesAndRollback(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"keep.txt":      "same\n",
+		"edit.txt":      "one\ntwo\n",
+		"gone.txt":      "bye\n",
+		"build/out.bin": "ignored",
+		".git/HEAD":     "ref: main\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := tools.NewWorkspace(root, tools.WithIgnore(".git", "build"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := ws.Tools()
+	bash, writeFile := ts[0], ts[2]
+	call(t, writeFile, map[string]any{"path": "edit.txt", "content": "one\n2\n"})
+	call(t, writeFile, map[string]any{"path": "src/new.txt", "content": "new\n"})
+	if res := call(t, bash, map[string]any{"command": "rm gone.txt && echo more > build/out.bin && echo x > .git/HEAD"}); res.IsError {
+		t.Fatalf("bash failed: %s", text(res))
+	}
+
+	changes, err := ws.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, string(c.Kind)+" "+c.Path)
+	}
+	if want := "modified edit.txt,deleted gone.txt,created src/new.txt"; strings.Join(got, ",") != want {
+		t.Fatalf("changes = %v, want %s", got, want)
+	}
+	wantDiff := "--- a/edit.txt\n+++ b/edit.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n"
+	if changes[0].Diff != wantDiff {
+		t.Errorf("edit diff = %q, want %q", changes[0].Diff, wantDiff)
+	}
+	if d, _ := ws.Diff(); !strings.Contains(d, "--- /dev/null\n+++ b/src/new.txt\n@@ -0,0 +1 @@\n+new\n") {
+		t.Errorf("combined diff misses the created file:\n%s", d)
+	}
+
+	if err := ws.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if changes, _ := ws.Changes(); len(changes) != 0 {
+		t.Errorf("changes after rollback: %v", changes)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "gone.txt")); string(data) != "bye\n" {
+		t.Errorf("gone.txt restored as %q", data)
+	}
+
+	call(t, writeFile, map[string]any{"path": "edit.txt", "content": "accepted\n"})
+	if err := ws.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	if changes, _ := ws.Changes(); len(changes) != 0 {
+		t.Errorf("changes after accept: %v", changes)
+	}
+}
@@ -0,0 +1,251 @@
+package step_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+)
+
+// accessTool is a tool with the given access. Its calls meet at barrier,
+// when set, so they only finish if they run concurrently.
+type accessTool struct {
+	name    string
+	access  step.Access
+	barrier *sync.WaitGroup
+	ran     *int
+}
+
+func (t accessTool) Spec() step.ToolSpec { return step.ToolSpec{Name: t.name, Access: t.access} }
+
+func (t accessTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	if t.barrier != nil {
+		t.barrier.Done()
+		met := make(chan struct{})
+		go func() { t.barrier.Wait(); close(met) }()
+		select {
+		case <-met:
+		case <-ctx.Done():
+			return step.ToolResult{}, ctx.Err()
+		}
+	}
+	if t.ran != nil {
+		*t.ran++
+	}
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "done"}}}, nil
+}
+
+func calls(names ...string) mock.Response {
+	var parts []step.ToolCallPart
+	for i, name := range names {
+		parts = append(parts, step.ToolCallPart{CallID: string(rune('a' + i)), Name: name, ArgsJSON: []byte(`{}`)})
+	}
+	return mock.ToolCalls(parts...)
+}
+
+func TestStep_ReadOnlyToolsRunInParallel(t *testing.T) {
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+	tool := accessTool{name: "read", access: step.AccessReadOnly, barrier: &barrier}
+	if !tool.Spec().RunsInParallel() {
+		t.Fatal("read-only tool does not run in parallel")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	result, err := step.Step(ctx, step.StepRequest{Provider: mock.New(calls("read", "read")), Tools: []step.Tool{tool}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range result[1:] {
+		if m.(step.ToolResultMessage).IsError {
+			t.Errorf("read-only calls did not run concurrently: %+v", m)
+		}
+	}
+}
+
+func TestStep_ToolApproval(t *testing.T) {
+	var ran int
+	tools := []step.Tool{
+		accessTool{name: "rm", access: step.AccessDestructive, ran: &ran},
+		accessTool{name: "edit", access: step.AccessWrite, ran: &ran},
+	}
+	var asked []string
+	approve := func(_ context.Context, call step.ToolCallPart) error {
+		asked = append(asked, call.Name)
+		return errors.New("not now")
+	}
+
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: mock.New(calls("rm", "edit")), Tools: tools}, step.WithToolApproval(approve))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(asked) != 1 || asked[0] != "rm" || ran != 1 {
+		t.Errorf("asked for %v and ran %d calls, want only rm asked and edit run", asked, ran)
+	}
+	denied := result[1].(step.ToolResultMessage)
+	if !denied.IsError || denied.Parts[0].(step.TextPart).Text != "Tool call denied: not now" {
+		t.Errorf("denied result = %+v", denied)
+	}
+
+	// Without an approver destructive tools run.
+	if _, err := step.Step(context.Background(), step.StepRequest{Provider: mock.New(calls("rm")), Tools: tools}); err != nil || ran != 2 {
+		t.Errorf("ran %d calls, err %v", ran, err)
+	}
+}
+
+// orderTool records the order its calls start in.
+type orderTool struct {
+	spec    step.ToolSpec
+	mu      *sync.Mutex
+	started *[]string
+}
+
+func (t orderTool) Spec() step.ToolSpec { return t.spec }
+
+func (t orderTool) Execute(context.Context, step.ToolCallPart) (step.ToolResult, error) {
+	t.mu.Lock()
+	*t.started = append(*t.started, t.spec.Name)
+	t.mu.Unlock()
+	return step.ToolResult{}, nil
+}
+
+func TestStep_ToolDependencies(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+	tools := []step.Tool{
+		orderTool{step.ToolSpec{Name: "write", Access: step.AccessWrite, After: []string{"read"}}, &mu, &started},
+		orderTool{step.ToolSpec{Name: "read", Access: step.AccessReadOnly}, &mu, &started},
+		orderTool{step.ToolSpec{Name: "notify", Parallel: true, After: []string{"write"}}, &mu, &started},
+	}
+	result, err := step.Step(context.Background(), step.StepRequest{Provider: mock.New(calls("notify", "write", "read")), Tools: tools})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"read", "write", "notify"}; !slices.Equal(started, want) {
+		t.Errorf("ran %v, want %v", started, want)
+	}
+	var names []string
+	for _, m := range result[1:] {
+		names = append(names, m.(step.ToolResultMessage).Name)
+	}
+	if want := []string{"notify", "write", "read"}; !slices.Equal(names, want) {
+		t.Errorf("results in order %v, want the model's %v", names, want)
+	}
+
+	// A cycle runs in the model's order.
+	started = nil
+	tools[1] = orderTool{step.ToolSpec{Name: "read", Access: step.AccessReadOnly, After: []string{"write"}}, &mu, &started}
+	if _, err := step.Step(context.Background(), step.StepRequest{Provider: mock.New(calls("write", "read")), Tools: tools}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"write", "read"}; !slices.Equal(started, want) {
+		t.Errorf("ran %v, want %v", started, want)
+	}
+}
+
+// flakyTool fails with an error until its call has run fails+1 times.
+type flakyTool struct {
+	fails int
+	runs  map[string]int
+	retry *step.RetryPolicy
+}
+
+func (t flakyTool) Spec() step.ToolSpec {
+	return step.ToolSpec{Name: "flaky", Access: step.AccessReadOnly, Retry: t.retry}
+}
+
+func (t flakyTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	t.runs[call.CallID]++
+	if t.runs[call.CallID] <= t.fails {
+		return step.ToolResult{}, errors.New("connection reset")
+	}
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "ok"}}}, nil
+}
+
+func TestWithToolRetry(t *testing.T) {
+	noWait := func(int) time.Duration { return 0 }
+	run := func(tool flakyTool, opts ...step.StepOption) (step.ToolResultMessage, int) {
+		var attempts int
+		opts = append(opts, step.WithOnDelta(func(d step.MessageDelta) {
+			if end, ok := d.(step.ToolExecEndDelta); ok {
+				attempts = end.Attempts
+			}
+		}))
+		results := step.ExecuteTools(context.Background(), []step.ToolCallPart{{CallID: "a", Name: "flaky", ArgsJSON: []byte(`{}`)}}, []step.Tool{tool}, opts...)
+		return results[0], attempts
+	}
+
+	if res, attempts := run(flakyTool{fails: 2, runs: map[string]int{}, retry: &step.RetryPolicy{MaxAttempts: 3, Backoff: noWait}}); res.IsError || attempts != 3 {
+		t.Errorf("got %+v after %d attempts, want success after 3", res, attempts)
+	}
+	if res, attempts := run(flakyTool{fails: 3, runs: map[string]int{}, retry: &step.RetryPolicy{MaxAttempts: 3, Backoff: noWait}}); !res.IsError || attempts != 3 {
+		t.Errorf("got %+v after %d attempts, want the error after 3", res, attempts)
+	}
+	if res, attempts := run(flakyTool{fails: 1, runs: map[string]int{}}); !res.IsError || attempts != 1 {
+		t.Errorf("got %+v after %d attempts, want no retry without a policy", res, attempts)
+	}
+
+	// WithToolRetry applies to tools without a policy, and its predicate
+	// decides what is retried.
+	never := step.RetryPolicy{MaxAttempts: 3, Backoff: noWait, RetryOn: func(step.ToolResult, error) bool { return false }}
+	if res, attempts := run(flakyTool{fails: 1, runs: map[string]int{}}, step.WithToolRetry(step.RetryPolicy{MaxAttempts: 2, Backoff: noWait})); res.IsError || attempts != 2 {
+		t.Errorf("got %+v after %d attempts, want success after 2", res, attempts)
+	}
+	if _, attempts := run(flakyTool{fails: 1, runs: map[string]int{}}, step.WithToolRetry(never, "flaky")); attempts != 1 {
+		t.Errorf("retried %d times, want none", attempts-1)
+	}
+
+	// A cancelled backoff ends the call.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	slow := flakyTool{fails: 1, runs: map[string]int{}, retry: &step.RetryPolicy{MaxAttempts: 2, Backoff: func(int) time.Duration { return time.Hour }}}
+	if results := step.ExecuteTools(ctx, []step.ToolCallPart{{CallID: "a", Name: "flaky", ArgsJSON: []byte(`{}`)}}, []step.Tool{slow}); !results[0].IsError || slow.runs["a"] != 1 {
+		t.Errorf("got %+v after %d runs, want an interrupted result", results[0], slow.runs["a"])
+	}
+}
+
+// hintTool fails with the error or result given by its call ID.
+type hintTool struct{}
+
+func (hintTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "hint"} }
+
+func (hintTool) Execute(ctx context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	switch call.CallID {
+	case "error":
+		return step.ToolResult{}, &step.RecoveryError{Err: errors.New("no such branch"), Hint: "List the branches first."}
+	case "result":
+		return step.ToolResult{IsError: true, Parts: []step.Part{step.TextPart{Text: "quota exceeded\n"}}, Details: map[string]any{step.DetailsRecovery: "Wait a minute."}}, nil
+	default:
+		return step.ToolResult{}, errors.New("boom")
+	}
+}
+
+func TestWithToolRecovery(t *testing.T) {
+	var calls []step.ToolCallPart
+	for _, id := range []string{"error", "result", "plain"} {
+		calls = append(calls, step.ToolCallPart{CallID: id, Name: "hint", ArgsJSON: []byte(`{}`)})
+	}
+	calls = append(calls, step.ToolCallPart{CallID: "missing", Name: "nope", ArgsJSON: []byte(`{}`)})
+	results := step.ExecuteTools(context.Background(), calls, []step.Tool{hintTool{}},
+		step.WithToolRecovery(func(call step.ToolCallPart, res step.ToolResult) string {
+			return "Try " + call.Name + " once more."
+		}))
+
+	want := []string{
+		"no such branch\n\nWhat to try next: List the branches first.",
+		"quota exceeded\n\nWhat to try next: Wait a minute.",
+		"boom\n\nWhat to try next: Try hint once more.",
+		"tool not found\n\nWhat to try next: Call one of the available tools: hint.",
+	}
+	for i, r := range results {
+		if got := r.Parts[0].(step.TextPart).Text; got != want[i] {
+			t.Errorf("result %s = %q, want %q", r.CallID, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package ui turns StepEvents into view models for terminal UIs: streaming text
+// and thinking buffers, tool call panels and a status spinner. It has no
+// dependency on a UI framework; a bubbletea program keeps a Model in its state,
+// feeds it events from StepStream in Update and renders the blocks in View.
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// BlockKind describes the content of a Block.
+type BlockKind string
+
+const (
+	BlockUser     BlockKind = "user"
+	BlockThinking BlockKind = "thinking"
+	BlockText     BlockKind = "text"
+	BlockTool     BlockKind = "tool"
+)
+
+// ToolState is the lifecycle state of a tool call panel.
+type ToolState string
+
+const (
+	// ToolPending means the model is still streaming the call's arguments.
+	ToolPending ToolState = "pending"
+	ToolRunning ToolState = "running"
+	ToolDone    ToolState = "done"
+	ToolFailed  ToolState = "failed"
+)
+
+// Status is the overall state shown next to the spinner.
+type Status string
+
+const (
+	StatusIdle         Status = "idle"
+	StatusThinking     Status = "thinking"
+	StatusResponding   Status = "responding"
+	StatusRunningTools Status = "running_tools"
+	StatusDone         Status = "done"
+	StatusCancelled    Status = "cancelled"
+	StatusError        Status = "error"
+)
+
+// Block is one renderable unit of the transcript, in display order.
+type Block struct {
+	Kind BlockKind
+	// Text is the content of user, thinking and text blocks.
+	Text string
+	// Streaming is true while deltas are still being appended to the block.
+	Streaming bool
+	// Tool is set for BlockTool.
+	Tool ToolPanel
+}
+
+// ToolPanel is the view model of a single tool call.
+type ToolPanel struct {
+	CallID string
+	Name   string
+	// Args holds the streamed arguments, reformatted with indentation once complete.
+	Args   string
+	State  ToolState
+	Output string
+	// Images is the number of images in the tool result.
+	Images    int
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// OutputPreview returns at most maxLines lines of the output, followed by a
+// "… N more lines" marker when lines were cut.
+func (p ToolPanel) OutputPreview(maxLines int) string {
+	out := strings.TrimRight(p.Output, "\n")
+	lines := strings.Split(out, "\n")
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return out
+	}
+	return strings.Join(lines[:maxLines], "\n") + "\n… " + strconv.Itoa(len(lines)-maxLines) + " more lines"
+}
+
+// Model accumulates StepEvents across one or more steps.
+// The zero value is ready to use.
+type Model struct {
+	Blocks []Block
+	Status Status
+	// Usage sums the usage of every assistant message seen.
+	Usage step.Usage
+	// Stats and Err are taken from the last done event.
+	Stats step.StreamStats
+	Err   error
+
+	// turnStart is the index of the first block of the current assistant turn.
+	turnStart int
+}
+
+// AddUser appends a user message, e.g. the prompt that starts the next step.
+func (m *Model) AddUser(msg step.UserMessage) {
+	m.closeStreaming()
+	var texts []string
+	for _, part := range msg.Parts {
+		if tp, ok := part.(step.TextPart); ok {
+			texts = append(texts, tp.Text)
+		}
+	}
+	m.Blocks = append(m.Blocks, Block{Kind: BlockUser, Text: strings.Join(texts, "\n")})
+	m.turnStart = len(m.Blocks)
+}
+
+// Apply updates the model with one event.
+func (m *Model) Apply(ev step.StepEvent) {
+	switch ev.Type {
+	case step.StepEventDelta:
+		m.applyDelta(ev.Delta, eventTime(ev))
+	case step.StepEventMessage:
+		m.applyMessage(ev.Message, eventTime(ev))
+	case step.StepEventDone:
+		m.closeStreaming()
+		m.Stats = ev.Stats
+		m.Err = ev.Err
+		switch {
+		case ev.Err != nil && m.Status != StatusCancelled:
+			m.Status = StatusError
+		case m.Status != StatusCancelled:
+			m.Status = StatusDone
+		}
+	}
+}
+
+// Active reports whether a step is in progress.
+func (m *Model) Active() bool {
+	switch m.Status {
+	case StatusThinking, StatusResponding, StatusRunningTools:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *Model) applyDelta(delta step.MessageDelta, now time.Time) {
+	switch d := delta.(type) {
+	case step.TextDelta:
+		m.appendText(BlockText, d.Delta)
+		m.Status = StatusResponding
+	case step.ThinkingDelta:
+		if d.Delta != "" {
+			m.appendText(BlockThinking, d.Delta)
+		}
+		m.Status = StatusThinking
+	case step.ToolCallDelta:
+		idx := m.findTool(d.CallID)
+		if idx < 0 && d.CallID == "" {
+			idx = m.lastTool()
+		}
+		if idx != len(m.Blocks)-1 {
+			m.closeStreaming()
+		}
+		if idx < 0 {
+			m.Blocks = append(m.Blocks, Block{Kind: BlockTool, Streaming: true, Tool: ToolPanel{CallID: d.CallID, Name: d.Name, State: ToolPending}})
+			idx = len(m.Blocks) - 1
+		}
+		panel := &m.Blocks[idx].Tool
+		if panel.Name == "" {
+			panel.Name = d.Name
+		}
+		panel.Args += d.ArgsDelta
+		m.Status = StatusResponding
+	case step.ToolExecStartDelta:
+		m.closeStreaming()
+		idx := m.ensureTool(d.Call)
+		panel := &m.Blocks[idx].Tool
+		panel.State = ToolRunning
+		panel.StartedAt = now
+		m.Status = StatusRunningTools
+	case step.StepStatusDelta:
+		if d.Cancelled {
+			m.Status = StatusCancelled
+		}
+	}
+}
+
+func (m *Model) applyMessage(msg step.Message, now time.Time) {
+	switch msg := msg.(type) {
+	case step.UserMessage:
+		m.AddUser(msg)
+	case step.AssistantMessage:
+		m.closeStreaming()
+		// Providers that do not stream deltas still produce visible blocks.
+		streamed := len(m.Blocks) > m.turnStart
+		for _, part := range msg.Parts {
+			switch p := part.(type) {
+			case step.TextPart:
+				if !streamed && p.Text != "" {
+					m.Blocks = append(m.Blocks, Block{Kind: BlockText, Text: p.Text})
+				}
+			case step.ThinkingPart:
+				if !streamed && p.Thinking != "" {
+					m.Blocks = append(m.Blocks, Block{Kind: BlockThinking, Text: p.Thinking})
+				}
+			case step.ToolCallPart:
+				m.ensureTool(p)
+			}
+		}
+		if msg.Usage != nil {
+			m.Usage.Add(msg.Usage)
+		}
+	case step.ToolResultMessage:
+		idx := m.findTool(msg.CallID)
+		if idx < 0 {
+			idx = m.ensureTool(step.ToolCallPart{CallID: msg.CallID, Name: msg.Name})
+		}
+		panel := &m.Blocks[idx].Tool
+		panel.State = ToolDone
+		if msg.IsError {
+			panel.State = ToolFailed
+		}
+		var texts []string
+		for _, part := range msg.Parts {
+			switch p := part.(type) {
+			case step.TextPart:
+				texts = append(texts, p.Text)
+			case step.ImagePart:
+				panel.Images++
+			}
+		}
+		panel.Output = strings.Join(texts, "\n")
+		if !panel.StartedAt.IsZero() {
+			panel.Duration = now.Sub(panel.StartedAt)
+		}
+		// The next assistant turn starts after the tool results.
+		m.turnStart = len(m.Blocks)
+	}
+}
+
+// appendText extends the streaming block of kind, or starts a new one.
+func (m *Model) appendText(kind BlockKind, text string) {
+	if n := len(m.Blocks); n > 0 && m.Blocks[n-1].Kind == kind && m.Blocks[n-1].Streaming {
+		m.Blocks[n-1].Text += text
+		return
+	}
+	m.closeStreaming()
+	m.Blocks = append(m.Blocks, Block{Kind: kind, Text: text, Streaming: true})
+}
+
+// closeStreaming marks the last block complete.
+func (m *Model) closeStreaming() {
+	n := len(m.Blocks)
+	if n == 0 || !m.Blocks[n-1].Streaming {
+		return
+	}
+	b := &m.Blocks[n-1]
+	b.Streaming = false
+	if b.Kind == BlockTool {
+		b.Tool.Args = indentJSON(b.Tool.Args)
+	}
+}
+
+// ensureTool returns the panel for call, creating it or completing its arguments.
+func (m *Model) ensureTool(call step.ToolCallPart) int {
+	idx := m.findTool(call.CallID)
+	if idx < 0 {
+		m.Blocks = append(m.Blocks, Block{Kind: BlockTool, Tool: ToolPanel{CallID: call.CallID, Name: call.Name, State: ToolPending}})
+		idx = len(m.Blocks) - 1
+	}
+	if len(call.ArgsJSON) > 0 {
+		m.Blocks[idx].Tool.Args = indentJSON(string(call.ArgsJSON))
+	}
+	return idx
+}
+
+func (m *Model) findTool(callID string) int {
+	if callID == "" {
+		return -1
+	}
+	for i := len(m.Blocks) - 1; i >= 0; i-- {
+		if m.Blocks[i].Kind == BlockTool && m.Blocks[i].Tool.CallID == callID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Model) lastTool() int {
+	if n := len(m.Blocks); n > 0 && m.Blocks[n-1].Kind == BlockTool && m.Blocks[n-1].Streaming {
+		return n - 1
+	}
+	return -1
+}
+
+func indentJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+func eventTime(ev step.StepEvent) time.Time {
+	if ev.Time.IsZero() {
+		return time.Now()
+	}
+	return ev.Time
+}
@@ -0,0 +1,90 @@
+package ui_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/ui"
+)
+
+type echoTool struct{}
+
+func (echoTool) Spec() step.ToolSpec { return step.ToolSpec{Name: "echo"} }
+
+func (echoTool) Execute(_ context.Context, call step.ToolCallPart) (step.ToolResult, error) {
+	return step.ToolResult{Parts: []step.Part{step.TextPart{Text: "a\nb\nc"}}}, nil
+}
+
+func TestModel_StepStream(t *testing.T) {
+	provider := mock.New(mock.Response{Message: step.AssistantMessage{
+		Parts: []step.Part{
+			step.ThinkingPart{Thinking: "Need to echo."},
+			step.TextPart{Text: "Running it."},
+			step.ToolCallPart{CallID: "c1", Name: "echo", ArgsJSON: json.RawMessage(`{"x":1}`)},
+		},
+		Usage:      &step.Usage{OutputTokens: 7},
+		StopReason: step.StopToolUse,
+	}})
+
+	var m ui.Model
+	m.AddUser(step.UserMessage{Parts: []step.Part{step.TextPart{Text: "echo something"}}})
+	stream := step.StepStream(context.Background(), step.StepRequest{
+		Provider: provider,
+		History:  []step.Message{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "echo something"}}}},
+		Tools:    []step.Tool{echoTool{}},
+	})
+	for {
+		msg := ui.WaitEvent(stream.Events())
+		if msg.Closed {
+			break
+		}
+		m.Apply(msg.Event)
+		if msg.Event.Type == step.StepEventDelta {
+			if _, ok := msg.Event.Delta.(step.ToolExecStartDelta); ok && (!m.Active() || m.Spinner(time.Now()) == "") {
+				t.Error("expected an active spinner while tools run")
+			}
+		}
+	}
+
+	kinds := []ui.BlockKind{ui.BlockUser, ui.BlockThinking, ui.BlockText, ui.BlockTool}
+	if len(m.Blocks) != len(kinds) {
+		t.Fatalf("expected %d blocks, got %+v", len(kinds), m.Blocks)
+	}
+	for i, k := range kinds {
+		if m.Blocks[i].Kind != k || m.Blocks[i].Streaming {
+			t.Errorf("block %d: expected complete %s, got %+v", i, k, m.Blocks[i])
+		}
+	}
+	panel := m.Blocks[3].Tool
+	if panel.State != ui.ToolDone || panel.Args != "{\n  \"x\": 1\n}" || panel.Output != "a\nb\nc" {
+		t.Errorf("unexpected tool panel %+v", panel)
+	}
+	if got := panel.OutputPreview(2); got != "a\nb\n… 1 more lines" {
+		t.Errorf("unexpected preview %q", got)
+	}
+	if m.Status != ui.StatusDone || m.Active() || m.Usage.OutputTokens != 7 {
+		t.Errorf("unexpected final state %s, usage %+v", m.Status, m.Usage)
+	}
+}
+
+func TestModel_StreamedToolArgs(t *testing.T) {
+	var m ui.Model
+	for _, d := range []step.MessageDelta{
+		step.ToolCallDelta{CallID: "c1", Name: "echo", ArgsDelta: `{"x"`},
+		step.ToolCallDelta{ArgsDelta: `:1}`},
+	} {
+		m.Apply(step.StepEvent{Type: step.StepEventDelta, Delta: d})
+	}
+	if len(m.Blocks) != 1 || !m.Blocks[0].Streaming || m.Blocks[0].Tool.Args != `{"x":1}` {
+		t.Fatalf("expected one streaming tool panel, got %+v", m.Blocks)
+	}
+	m.Apply(step.StepEvent{Type: step.StepEventDelta, Delta: step.StepStatusDelta{Cancelled: true}})
+	m.Apply(step.StepEvent{Type: step.StepEventDone, Err: context.Canceled})
+	if m.Status != ui.StatusCancelled || m.Blocks[0].Streaming {
+		t.Errorf("expected cancelled status with closed blocks, got %s %+v", m.Status, m.Blocks)
+	}
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/inspirepan/step"
+)
+
+// Spinner is a frame-based activity indicator. Frames are chosen from the
+// clock, so any number of views render the same frame without shared state.
+type Spinner struct {
+	Frames   []string
+	Interval time.Duration
+}
+
+// DefaultSpinner is a braille dot spinner.
+var DefaultSpinner = Spinner{
+	Frames:   []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
+	Interval: 80 * time.Millisecond,
+}
+
+// Frame returns the frame to show at t.
+func (s Spinner) Frame(t time.Time) string {
+	if len(s.Frames) == 0 || s.Interval <= 0 {
+		return ""
+	}
+	return s.Frames[int(t.UnixNano()/int64(s.Interval))%len(s.Frames)]
+}
+
+// Spinner returns the DefaultSpinner frame at t while a step is active, and "" otherwise.
+func (m *Model) Spinner(t time.Time) string {
+	if !m.Active() {
+		return ""
+	}
+	return DefaultSpinner.Frame(t)
+}
+
+// EventMsg carries one StepEvent into a UI event loop. Closed is true once the
+// event channel has been drained.
+type EventMsg struct {
+	Event  step.StepEvent
+	Closed bool
+}
+
+// WaitEvent blocks for the next event. In bubbletea, wrap it as a command and
+// issue it again after every EventMsg until Closed:
+//
+//	func() tea.Msg { return ui.WaitEvent(stream.Events()) }
+func WaitEvent(events <-chan step.StepEvent) EventMsg {
+	ev, ok := <-events
+	return EventMsg{Event: ev, Closed: !ok}
+}
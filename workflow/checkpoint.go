@@ -0,0 +1,157 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/inspirepan/step"
+)
+
+// Checkpoint is the resumable state of a run, saved after every node.
+type Checkpoint struct {
+	RunID string
+	// Next is the node to run next, or End once the run finished.
+	Next string
+	// Completed lists the nodes run so far, in order.
+	Completed []string
+	State     State
+}
+
+type checkpointJSON struct {
+	RunID     string            `json:"run_id"`
+	Next      string            `json:"next"`
+	Completed []string          `json:"completed,omitempty"`
+	Messages  []json.RawMessage `json:"messages,omitempty"`
+	Values    map[string]any    `json:"values,omitempty"`
+	Usage     step.Usage        `json:"usage"`
+}
+
+// MarshalJSON encodes the checkpoint with its messages in their JSON form.
+func (c Checkpoint) MarshalJSON() ([]byte, error) {
+	out := checkpointJSON{RunID: c.RunID, Next: c.Next, Completed: c.Completed, Values: c.State.Values, Usage: c.State.Usage}
+	for _, msg := range c.State.Messages {
+		raw, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		out.Messages = append(out.Messages, raw)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a checkpoint written by MarshalJSON.
+func (c *Checkpoint) UnmarshalJSON(data []byte) error {
+	var in checkpointJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*c = Checkpoint{RunID: in.RunID, Next: in.Next, Completed: in.Completed, State: State{Values: in.Values, Usage: in.Usage}}
+	for _, raw := range in.Messages {
+		msg, err := step.UnmarshalMessage(raw)
+		if err != nil {
+			return err
+		}
+		c.State.Messages = append(c.State.Messages, msg)
+	}
+	return nil
+}
+
+// CheckpointStore persists checkpoints by run ID.
+type CheckpointStore interface {
+	Save(ctx context.Context, cp Checkpoint) error
+	Load(ctx context.Context, runID string) (Checkpoint, bool, error)
+}
+
+// MemoryStore is an in-process CheckpointStore. It stores the JSON encoding,
+// so a resume sees the same State as with a persistent store.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string][]byte
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) Save(_ context.Context, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[cp.RunID] = data
+	return nil
+}
+
+func (s *MemoryStore) Load(_ context.Context, runID string) (Checkpoint, bool, error) {
+	s.mu.RLock()
+	data, ok := s.entries[runID]
+	s.mu.RUnlock()
+	if !ok {
+		return Checkpoint{}, false, nil
+	}
+	var cp Checkpoint
+	err := json.Unmarshal(data, &cp)
+	return cp, err == nil, err
+}
+
+// DirStore persists each run's latest checkpoint as a JSON file in a directory.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a DirStore rooted at dir, creating it if needed.
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+func (s *DirStore) path(runID string) string {
+	return filepath.Join(s.dir, runID+".json")
+}
+
+func (s *DirStore) Save(_ context.Context, cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temp file and rename so a crash never leaves a partial checkpoint.
+	tmp, err := os.CreateTemp(s.dir, cp.RunID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(cp.RunID))
+}
+
+func (s *DirStore) Load(_ context.Context, runID string) (Checkpoint, bool, error) {
+	data, err := os.ReadFile(s.path(runID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, false, err
+	}
+	return cp, true, nil
+}
@@ -0,0 +1,224 @@
+// Package workflow runs deterministic multi-stage pipelines as a graph. Nodes
+// are single steps, full agent loops or plain Go functions, all producing a
+// step.StepResult; edges choose the next node with conditions on that result.
+// State is checkpointed after every node so an interrupted run can be resumed.
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/inspirepan/step"
+)
+
+// End is the pseudo-node that finishes a run.
+const End = "__end__"
+
+// ErrMaxNodes is returned when a run visits more than the configured number of nodes.
+var ErrMaxNodes = errors.New("workflow: max nodes reached")
+
+// State flows through the graph. Values must be JSON-serializable to survive
+// checkpointing; after a resume they hold their JSON-decoded form.
+type State struct {
+	Messages []step.Message
+	Values   map[string]any
+	// Usage sums the usage of every assistant message produced by nodes.
+	Usage step.Usage
+}
+
+// Node runs one stage. The returned messages are appended to state.Messages
+// by the graph; a node that only updates Values returns nil.
+type Node func(ctx context.Context, state *State) (step.StepResult, error)
+
+// Step returns a Node that runs a single step of agent on the conversation.
+func Step(agent step.Agent, opts ...step.StepOption) Node {
+	return func(ctx context.Context, state *State) (step.StepResult, error) {
+		return step.Step(ctx, step.StepRequest{
+			Provider:     agent.Provider,
+			SystemPrompt: agent.SystemPrompt,
+			History:      state.Messages,
+			Tools:        agent.Tools,
+		}, opts...)
+	}
+}
+
+// Agent returns a Node that runs agent's free-form loop on the conversation
+// until it stops calling tools.
+func Agent(agent step.Agent, opts ...step.StepOption) Node {
+	return func(ctx context.Context, state *State) (step.StepResult, error) {
+		run, err := step.Run(ctx, agent, state.Messages, opts...)
+		return run.Messages, err
+	}
+}
+
+// Condition decides whether an edge is taken, given the result of the node it leaves.
+type Condition func(result step.StepResult, state *State) bool
+
+// HasToolCall is a Condition on the result containing tool calls.
+func HasToolCall(result step.StepResult, _ *State) bool { return result.HasToolCall() }
+
+// NoToolCall is a Condition on the result containing no tool calls.
+func NoToolCall(result step.StepResult, _ *State) bool { return !result.HasToolCall() }
+
+type edge struct {
+	to   string
+	cond Condition
+}
+
+// Graph is a workflow definition. Build it with New, Node and Edge; it is
+// safe for concurrent runs once built.
+type Graph struct {
+	start string
+	nodes map[string]Node
+	edges map[string][]edge
+}
+
+// New creates a graph starting at the node named start.
+func New(start string) *Graph {
+	return &Graph{start: start, nodes: make(map[string]Node), edges: make(map[string][]edge)}
+}
+
+// Node adds a node.
+func (g *Graph) Node(name string, node Node) *Graph {
+	g.nodes[name] = node
+	return g
+}
+
+// Edge adds an edge taken after from when cond holds; a nil cond always holds.
+// Edges are tried in the order added; a node with no matching edge ends the run.
+func (g *Graph) Edge(from, to string, cond Condition) *Graph {
+	g.edges[from] = append(g.edges[from], edge{to: to, cond: cond})
+	return g
+}
+
+// Validate reports references to undefined nodes.
+func (g *Graph) Validate() error {
+	if _, ok := g.nodes[g.start]; !ok {
+		return fmt.Errorf("workflow: start node %q is not defined", g.start)
+	}
+	for from, edges := range g.edges {
+		if _, ok := g.nodes[from]; !ok {
+			return fmt.Errorf("workflow: edge from undefined node %q", from)
+		}
+		for _, e := range edges {
+			if _, ok := g.nodes[e.to]; !ok && e.to != End {
+				return fmt.Errorf("workflow: edge %q -> %q to undefined node", from, e.to)
+			}
+		}
+	}
+	return nil
+}
+
+// Option configures a run.
+type Option func(*runConfig)
+
+type runConfig struct {
+	store    CheckpointStore
+	runID    string
+	maxNodes int
+	onNode   func(name string, result step.StepResult)
+}
+
+// WithCheckpoints saves a Checkpoint to store under runID after every node.
+func WithCheckpoints(store CheckpointStore, runID string) Option {
+	return func(c *runConfig) {
+		c.store = store
+		c.runID = runID
+	}
+}
+
+// WithMaxNodes bounds the number of nodes a run visits, guarding against
+// cycles that never exit. Defaults to 100.
+func WithMaxNodes(n int) Option {
+	return func(c *runConfig) { c.maxNodes = n }
+}
+
+// WithOnNode adds a hook that runs after each node with its result.
+func WithOnNode(fn func(name string, result step.StepResult)) Option {
+	return func(c *runConfig) { c.onNode = fn }
+}
+
+// Run executes the graph from its start node on state. On error the returned
+// state holds everything produced so far, and with checkpoints enabled the run
+// can be continued with Resume.
+func (g *Graph) Run(ctx context.Context, state State, opts ...Option) (State, error) {
+	return g.run(ctx, Checkpoint{Next: g.start, State: state}, opts)
+}
+
+// Resume continues the run saved in store under runID from its next node.
+// A finished run is returned unchanged.
+func (g *Graph) Resume(ctx context.Context, store CheckpointStore, runID string, opts ...Option) (State, error) {
+	cp, ok, err := store.Load(ctx, runID)
+	if err != nil {
+		return State{}, err
+	}
+	if !ok {
+		return State{}, fmt.Errorf("workflow: no checkpoint for run %q", runID)
+	}
+	return g.run(ctx, cp, append([]Option{WithCheckpoints(store, runID)}, opts...))
+}
+
+func (g *Graph) run(ctx context.Context, cp Checkpoint, opts []Option) (State, error) {
+	cfg := runConfig{maxNodes: 100}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if err := g.Validate(); err != nil {
+		return cp.State, err
+	}
+	cp.RunID = cfg.runID
+	cp.State.Messages = append([]step.Message(nil), cp.State.Messages...)
+	cp.State.Values = maps.Clone(cp.State.Values)
+	if cp.State.Values == nil {
+		cp.State.Values = make(map[string]any)
+	}
+	if cfg.store != nil && len(cp.Completed) == 0 {
+		if err := cfg.store.Save(ctx, cp); err != nil {
+			return cp.State, fmt.Errorf("workflow: save checkpoint: %w", err)
+		}
+	}
+
+	for visited := 0; cp.Next != End; visited++ {
+		if visited >= cfg.maxNodes {
+			return cp.State, ErrMaxNodes
+		}
+		name := cp.Next
+		node, ok := g.nodes[name]
+		if !ok {
+			return cp.State, fmt.Errorf("workflow: node %q is not defined", name)
+		}
+		result, err := node(ctx, &cp.State)
+		cp.State.Messages = append(cp.State.Messages, result...)
+		for _, msg := range result {
+			if m, ok := msg.(step.AssistantMessage); ok {
+				cp.State.Usage.Add(m.Usage)
+			}
+		}
+		if err != nil {
+			// Keep the checkpoint pointing at the failed node so Resume retries it.
+			return cp.State, fmt.Errorf("workflow: node %q: %w", name, err)
+		}
+		if cfg.onNode != nil {
+			cfg.onNode(name, result)
+		}
+
+		cp.Next = End
+		for _, e := range g.edges[name] {
+			if e.cond == nil || e.cond(result, &cp.State) {
+				cp.Next = e.to
+				break
+			}
+		}
+		cp.Completed = append(cp.Completed, name)
+		if cfg.store != nil {
+			if err := cfg.store.Save(ctx, cp); err != nil {
+				return cp.State, fmt.Errorf("workflow: save checkpoint: %w", err)
+			}
+		}
+	}
+	return cp.State, nil
+}
@@ -0,0 +1,102 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/inspirepan/step"
+	"github.com/inspirepan/step/providers/mock"
+	"github.com/inspirepan/step/workflow"
+)
+
+func TestGraph_ConditionalLoop(t *testing.T) {
+	call := mock.ToolCalls(step.ToolCallPart{CallID: "c1", Name: "missing"})
+	worker := step.Agent{Provider: mock.New(call, mock.Text("done"))}
+	var visited []string
+	g := workflow.New("plan").
+		Node("plan", func(_ context.Context, s *workflow.State) (step.StepResult, error) {
+			s.Values["plan"] = "outline"
+			return step.StepResult{step.UserMessage{Parts: []step.Part{step.TextPart{Text: "Follow the outline."}}}}, nil
+		}).
+		Node("work", workflow.Step(worker)).
+		Node("publish", func(_ context.Context, s *workflow.State) (step.StepResult, error) {
+			s.Values["published"] = true
+			return nil, nil
+		}).
+		Edge("plan", "work", nil).
+		Edge("work", "work", workflow.HasToolCall).
+		Edge("work", "publish", workflow.NoToolCall)
+
+	state, err := g.Run(context.Background(), workflow.State{}, workflow.WithOnNode(func(name string, _ step.StepResult) {
+		visited = append(visited, name)
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := []string{"plan", "work", "work", "publish"}; !slices.Equal(visited, want) {
+		t.Errorf("unexpected path %v", visited)
+	}
+	// user, tool call, tool result, final answer
+	if len(state.Messages) != 4 || state.Values["published"] != true {
+		t.Errorf("unexpected state %+v", state)
+	}
+}
+
+func TestGraph_ResumeFromCheckpoint(t *testing.T) {
+	dir, err := workflow.NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var firstRuns int
+	fail := true
+	g := workflow.New("first").
+		Node("first", func(_ context.Context, s *workflow.State) (step.StepResult, error) {
+			firstRuns++
+			s.Values["count"] = 1
+			return nil, nil
+		}).
+		Node("answer", workflow.Agent(step.Agent{Provider: mock.New(mock.Text("hello"))})).
+		Node("flaky", func(context.Context, *workflow.State) (step.StepResult, error) {
+			if fail {
+				return nil, errors.New("boom")
+			}
+			return nil, nil
+		}).
+		Edge("first", "answer", nil).
+		Edge("answer", "flaky", nil)
+
+	if _, err := g.Run(context.Background(), workflow.State{}, workflow.WithCheckpoints(dir, "run1")); err == nil {
+		t.Fatal("expected the flaky node to fail")
+	}
+	cp, ok, err := dir.Load(context.Background(), "run1")
+	if err != nil || !ok || cp.Next != "flaky" {
+		t.Fatalf("expected a checkpoint before the failed node, got %+v %v %v", cp, ok, err)
+	}
+
+	fail = false
+	state, err := g.Resume(context.Background(), dir, "run1")
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if firstRuns != 1 {
+		t.Errorf("completed nodes ran again: %d", firstRuns)
+	}
+	if len(state.Messages) != 1 || state.Messages[0].(step.AssistantMessage).Text() != "hello" {
+		t.Errorf("unexpected messages %+v", state.Messages)
+	}
+	if state.Values["count"] != float64(1) {
+		t.Errorf("expected values to survive the checkpoint, got %+v", state.Values)
+	}
+	if cp, _, _ := dir.Load(context.Background(), "run1"); cp.Next != workflow.End || len(cp.Completed) != 3 {
+		t.Errorf("unexpected final checkpoint %+v", cp)
+	}
+}
+
+func TestGraph_Validate(t *testing.T) {
+	g := workflow.New("a").Node("a", func(context.Context, *workflow.State) (step.StepResult, error) { return nil, nil }).Edge("a", "b", nil)
+	if err := g.Validate(); err == nil {
+		t.Error("expected an error for the undefined node")
+	}
+}